@@ -0,0 +1,71 @@
+package ddns
+
+import (
+	"context"
+	"net"
+
+	"github.com/justenwalker/ddns/daemon"
+	"github.com/justenwalker/ddns/event"
+	"github.com/justenwalker/ddns/ipdetect"
+	"github.com/justenwalker/ddns/state"
+)
+
+// APIVersion is the major version of the API of this package; see Compatibility in the package documentation
+const APIVersion = 1
+
+// Source detects the public addresses of the host, such as ipify.New or ipdetect.NewInterface
+type Source = ipdetect.Source
+
+// Provider is a named set of records updated together; see daemon.Provider
+type Provider = daemon.Provider
+
+// FamilyPolicy overrides the debounce and refresh of a Provider for one address family
+type FamilyPolicy = daemon.FamilyPolicy
+
+// Updater publishes addresses to a provider, such as the one returned by Dynu.
+// It returns ErrUnchanged if the provider already had them.
+type Updater = daemon.Updater
+
+// UpdaterFunc adapts a function to the Updater interface
+type UpdaterFunc = daemon.UpdaterFunc
+
+// Publisher publishes addresses to an account at a provider, reporting whether the account did not have them
+// already, such as a *dynu.Client; see Accounts
+type Publisher interface {
+	UpdateIPChangedContext(ctx context.Context, ips []net.IP) (changed bool, err error)
+}
+
+// ErrUnchanged may be returned by an Updater when the provider already had the addresses
+var ErrUnchanged = daemon.ErrUnchanged
+
+// Event is something that happened to the providers of an Engine, delivered to its subscribers and sinks
+type Event = event.Event
+
+// EventType is the type of an Event
+type EventType = event.Type
+
+// Types of events; see the event package for what each of them carries
+const (
+	Detected   = event.Detected
+	Changed    = event.Changed
+	Updated    = event.Updated
+	Failed     = event.Failed
+	Recovered  = event.Recovered
+	Verified   = event.Verified
+	RolledBack = event.RolledBack
+	Promoted   = event.Promoted
+	Stopped    = event.Stopped
+	BackedOff  = event.BackedOff
+)
+
+// Sink receives the events of an Engine; see Attach
+type Sink = event.Sink
+
+// Status is the state of the providers of an Engine, by name
+type Status = state.Snapshot
+
+// ProviderStatus is the state of a single provider
+type ProviderStatus = state.Provider
+
+// Store persists the Status between runs, such as state.File; see Persist
+type Store = state.Store
@@ -0,0 +1,118 @@
+package ddns_test
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/justenwalker/ddns"
+	"github.com/justenwalker/ddns/daemon"
+	"github.com/justenwalker/ddns/dynu"
+	"github.com/justenwalker/ddns/event"
+	"github.com/justenwalker/ddns/ipdetect"
+	"github.com/justenwalker/ddns/state"
+)
+
+// The version 1 API: these assignments stop compiling if an identifier is removed or changed incompatibly.
+// Additions belong here as well.
+
+const _ = ddns.APIVersion
+
+var (
+	_ func(ddns.Source, []ddns.Provider, ...ddns.Option) *ddns.Engine = ddns.New
+	_ func(ddns.Logger) ddns.Option                                   = ddns.Log
+	_ func(time.Duration) ddns.Option                                 = ddns.Interval
+	_ func(min, max time.Duration) ddns.Option                        = ddns.Backoff
+	_ func(ddns.Store) ddns.Option                                    = ddns.Persist
+	_ func(time.Duration) ddns.Option                                 = ddns.Watch
+	_ func(ddns.Sink) ddns.Option                                     = ddns.Attach
+	_ func(...daemon.Option) ddns.Option                              = ddns.Daemon
+	_ func(...ddns.Publisher) ddns.Updater                            = ddns.Accounts
+	_ func(...*dynu.Client) ddns.Updater                              = ddns.Dynu
+
+	_ func(*ddns.Engine) error                                          = (*ddns.Engine).Start
+	_ func(*ddns.Engine, context.Context) error                         = (*ddns.Engine).Stop
+	_ func(*ddns.Engine) bool                                           = (*ddns.Engine).Running
+	_ func(*ddns.Engine)                                                = (*ddns.Engine).Update
+	_ func(*ddns.Engine) *ddns.Status                                   = (*ddns.Engine).Status
+	_ func(*ddns.Engine, ...ddns.EventType) (<-chan ddns.Event, func()) = (*ddns.Engine).Subscribe
+	_ func(*ddns.Engine, func(ddns.Event), ...ddns.EventType) func()    = (*ddns.Engine).SubscribeFunc
+	_ func(ddns.UpdaterFunc, context.Context, []net.IP) error           = ddns.UpdaterFunc.UpdateIP
+	_ func(ddns.Logger, string, ...interface{})                         = ddns.Logger.Log
+	_ func(ddns.Updater, context.Context, []net.IP) error               = ddns.Updater.UpdateIP
+	_ func(ddns.Publisher, context.Context, []net.IP) (bool, error)     = ddns.Publisher.UpdateIPChangedContext
+	_ func(ddns.Source, context.Context) ([]net.IP, error)              = ddns.Source.Detect
+	_ func(ddns.Sink, context.Context, ddns.Event) error                = ddns.Sink.Handle
+	_ func(ddns.Store) (*ddns.Status, error)                            = ddns.Store.Load
+	_ func(ddns.Store, *ddns.Status) error                              = ddns.Store.Save
+
+	// interfaces gain no methods: types with only the methods above still implement them
+	_ ddns.Logger    = minimal{}
+	_ ddns.Updater   = minimal{}
+	_ ddns.Publisher = minimal{}
+	_ ddns.Source    = minimal{}
+	_ ddns.Sink      = minimal{}
+	_ ddns.Store     = minimal{}
+
+	_ ddns.Publisher = (*dynu.Client)(nil)
+	_ error          = ddns.ErrRunning
+	_ error          = ddns.ErrUnchanged
+	_ ddns.Option    = ddns.Option(func(*ddns.Engine) {})
+
+	// the aliases are the types of the packages they are declared in
+	_ ipdetect.Source      = ddns.Source(nil)
+	_ *daemon.Provider     = (*ddns.Provider)(nil)
+	_ *daemon.FamilyPolicy = (*ddns.FamilyPolicy)(nil)
+	_ daemon.Updater       = ddns.Updater(nil)
+	_ daemon.UpdaterFunc   = ddns.UpdaterFunc(nil)
+	_ *event.Event         = (*ddns.Event)(nil)
+	_ event.Type           = ddns.EventType(0)
+	_ event.Sink           = ddns.Sink(nil)
+	_ *state.Snapshot      = (*ddns.Status)(nil)
+	_ *state.Provider      = (*ddns.ProviderStatus)(nil)
+	_ state.Store          = ddns.Store(nil)
+	_ []ddns.EventType     = []ddns.EventType{ddns.Detected, ddns.Changed, ddns.Updated, ddns.Failed, ddns.Recovered, ddns.Verified, ddns.RolledBack, ddns.Promoted, ddns.Stopped, ddns.BackedOff}
+	_                      = ddns.Provider{
+		Name:          "",
+		Hostnames:     []string(nil),
+		Updater:       ddns.Updater(nil),
+		Debounce:      time.Duration(0),
+		Refresh:       time.Duration(0),
+		Backup:        (*ddns.Provider)(nil),
+		PromoteAfter:  0,
+		SplitFamilies: false,
+		IPv4:          ddns.FamilyPolicy{Debounce: time.Duration(0), Refresh: time.Duration(0)},
+		IPv6:          ddns.FamilyPolicy{},
+		Interval:      time.Duration(0),
+		Backoff:       time.Duration(0),
+		BackoffMax:    time.Duration(0),
+	}
+	_ = ddns.Event{
+		Type:      ddns.EventType(0),
+		Time:      time.Time{},
+		Provider:  "",
+		Hostnames: []string(nil),
+		OldIPs:    []net.IP(nil),
+		NewIPs:    []net.IP(nil),
+		Err:       error(nil),
+		Attempt:   0,
+		RetryAt:   time.Time{},
+		Duration:  time.Duration(0),
+	}
+	_ = ddns.Status{
+		Version:   0,
+		Providers: map[string]ddns.ProviderStatus(nil),
+		NextRun:   time.Time{},
+	}
+)
+
+// minimal implements the interfaces of the API with their methods alone
+type minimal struct{}
+
+func (minimal) Log(string, ...interface{})                                     {}
+func (minimal) UpdateIP(context.Context, []net.IP) error                       { return nil }
+func (minimal) UpdateIPChangedContext(context.Context, []net.IP) (bool, error) { return false, nil }
+func (minimal) Detect(context.Context) ([]net.IP, error)                       { return nil, nil }
+func (minimal) Handle(context.Context, ddns.Event) error                       { return nil }
+func (minimal) Load() (*ddns.Status, error)                                    { return nil, nil }
+func (minimal) Save(*ddns.Status) error                                        { return nil }
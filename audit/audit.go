@@ -0,0 +1,290 @@
+// Package audit keeps an append-only log of the address changes detected and the provider updates attempted,
+// so that outages can be correlated with the times the ISP assigned a new address. Unlike the history of the
+// state, which only keeps the last changes, the log is never rotated: Open only removes a last line cut short by
+// a crash.
+//
+// Each line of the file is a Record encoded as JSON. A Log is an event.Sink, so it records the events of a
+// daemon or an Engine when attached to them:
+//
+//	l, err := audit.Open("/var/lib/ddns/audit.jsonl")
+//	if err != nil {
+//		return err
+//	}
+//	defer l.Close()
+//	engine := ddns.New(src, providers, ddns.Attach(l))
+//
+// Read queries the log, such as for the failed updates of a provider over the last week.
+package audit // import "github.com/justenwalker/ddns/audit"
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/justenwalker/ddns/event"
+)
+
+// Record types
+const (
+	// Detected records addresses that differ from those detected before
+	Detected = "detected"
+	// Update records an attempt to publish addresses to a provider
+	Update = "update"
+)
+
+// Results of an update
+const (
+	Success = "success"
+	Failure = "failure"
+)
+
+// Record is an entry of the log
+type Record struct {
+	Time time.Time `json:"time"`
+	// Type is Detected or Update
+	Type string `json:"type"`
+	// Provider and Hostnames are those of an update
+	Provider  string   `json:"provider,omitempty"`
+	Hostnames []string `json:"hostnames,omitempty"`
+	// OldIPs are the addresses detected, or published to the provider, before
+	OldIPs []net.IP `json:"old_ips,omitempty"`
+	NewIPs []net.IP `json:"new_ips,omitempty"`
+	// Result is Success or Failure for an update, with the error in Error
+	Result string `json:"result,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Log appends records to a file
+type Log struct {
+	mu sync.Mutex
+	f  *os.File
+	// detected are the addresses of the last Detected record
+	detected []net.IP
+}
+
+// Open opens or creates the log at path, appending to it after removing a last line cut short by a crash
+func Open(path string) (*Log, error) {
+	var corrupt *CorruptError
+	last, err := Read(path, Query{Type: Detected, Limit: 1})
+	if err != nil && !errors.Is(err, os.ErrNotExist) && !errors.As(err, &corrupt) {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err := trimFragment(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	l := &Log{f: f}
+	if len(last) > 0 {
+		l.detected = last[0].NewIPs
+	}
+	return l, nil
+}
+
+// trimFragment truncates the file after its last newline, removing a line cut short by a crash so the next
+// record starts a line of its own
+func trimFragment(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+	buf := make([]byte, 4096)
+	end := size
+	for end > 0 {
+		start := max(end-int64(len(buf)), 0)
+		chunk := buf[:end-start]
+		if _, err := f.ReadAt(chunk, start); err != nil {
+			return err
+		}
+		if i := bytes.LastIndexByte(chunk, '\n'); i >= 0 {
+			end = start + int64(i) + 1
+			break
+		}
+		end = start
+	}
+	if end == size {
+		return nil
+	}
+	return f.Truncate(end)
+}
+
+// Append writes r to the log as a single line, setting its time to now if it has none
+func (l *Log) Append(r Record) error {
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	r.Time = r.Time.UTC()
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.f.Write(append(data, '\n'))
+	return err
+}
+
+// Handle records Detected events whose addresses differ from those detected before, and the Updated and Failed
+// events of the providers
+func (l *Log) Handle(ctx context.Context, ev event.Event) error {
+	r := Record{Time: ev.Time, Provider: ev.Provider, Hostnames: ev.Hostnames, OldIPs: ev.OldIPs, NewIPs: ev.NewIPs}
+	switch ev.Type {
+	case event.Detected:
+		l.mu.Lock()
+		old := l.detected
+		changed := !sameIPs(old, ev.NewIPs)
+		l.detected = ev.NewIPs
+		l.mu.Unlock()
+		if !changed {
+			return nil
+		}
+		r.Type, r.OldIPs = Detected, old
+	case event.Updated:
+		r.Type, r.Result = Update, Success
+	case event.Failed:
+		r.Type, r.Result = Update, Failure
+		if ev.Err != nil {
+			r.Error = ev.Err.Error()
+		}
+	default:
+		return nil
+	}
+	return l.Append(r)
+}
+
+// Close closes the file
+func (l *Log) Close() error {
+	return l.f.Close()
+}
+
+// Query selects records of the log. Zero fields match every record.
+type Query struct {
+	// Since and Until bound the time of the records, Until excluded
+	Since, Until time.Time
+	// Type is Detected or Update
+	Type string
+	// Provider only matches the updates of this provider
+	Provider string
+	// Failures only matches failed updates
+	Failures bool
+	// Limit keeps the most recent records
+	Limit int
+}
+
+// Match returns true if r is selected by q, regardless of its Limit
+func (q Query) Match(r Record) bool {
+	switch {
+	case !q.Since.IsZero() && r.Time.Before(q.Since):
+		return false
+	case !q.Until.IsZero() && !r.Time.Before(q.Until):
+		return false
+	case q.Type != "" && r.Type != q.Type:
+		return false
+	case q.Provider != "" && r.Provider != q.Provider:
+		return false
+	case q.Failures && r.Result != Failure:
+		return false
+	}
+	return true
+}
+
+// CorruptError reports the lines of a log that are not records, such as a line cut short by a crash that was
+// followed by more records. They are skipped.
+type CorruptError struct {
+	// Lines are the line numbers, from 1
+	Lines []int
+}
+
+func (e *CorruptError) Error() string {
+	if len(e.Lines) == 1 {
+		return fmt.Sprintf("line %d is corrupt and was skipped", e.Lines[0])
+	}
+	return fmt.Sprintf("%d corrupt lines were skipped, the first at line %d", len(e.Lines), e.Lines[0])
+}
+
+// Read returns the records of the log at path selected by q, in the order they were appended. If some lines
+// are corrupt, the records are returned with a *CorruptError.
+func Read(path string, q Query) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	rs, err := Decode(f, q)
+	if err != nil {
+		return rs, fmt.Errorf("audit: %s: %w", path, err)
+	}
+	return rs, nil
+}
+
+// Decode reads the records of a log from r selected by q, in the order they were appended.
+// A last line without a newline, such as one cut short by a crash, is ignored. Other lines that are not records
+// are skipped, and reported by a *CorruptError returned with the records.
+func Decode(r io.Reader, q Query) ([]Record, error) {
+	var rs []Record
+	var corrupt []int
+	br := bufio.NewReader(r)
+	for n := 1; ; n++ {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line = bytes.TrimSpace(line); len(line) == 0 {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			corrupt = append(corrupt, n)
+			continue
+		}
+		if !q.Match(rec) {
+			continue
+		}
+		rs = append(rs, rec)
+		if q.Limit > 0 && len(rs) > 2*q.Limit {
+			rs = append(rs[:0], rs[len(rs)-q.Limit:]...)
+		}
+	}
+	if q.Limit > 0 && len(rs) > q.Limit {
+		rs = rs[len(rs)-q.Limit:]
+	}
+	if len(corrupt) > 0 {
+		return rs, &CorruptError{Lines: corrupt}
+	}
+	return rs, nil
+}
+
+// sameIPs returns true if a and b hold the same addresses, in any order
+func sameIPs(a, b []net.IP) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, ip := range a {
+		found := false
+		for _, other := range b {
+			if ip.Equal(other) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
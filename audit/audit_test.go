@@ -0,0 +1,134 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/justenwalker/ddns/event"
+)
+
+func TestLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	first, second := []net.IP{net.ParseIP("203.0.113.1").To4()}, []net.IP{net.ParseIP("203.0.113.2").To4()}
+	for _, ev := range []event.Event{
+		{Type: event.Detected, Time: at, NewIPs: first},
+		{Type: event.Updated, Time: at, Provider: "home", NewIPs: first},
+		{Type: event.Detected, Time: at.Add(time.Minute), NewIPs: first},
+		{Type: event.Verified, Time: at.Add(time.Minute), Provider: "home"},
+	} {
+		if err := l.Handle(ctx, ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.Close()
+
+	// a restarted daemon only records the addresses that differ from the last detected ones
+	l, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ev := range []event.Event{
+		{Type: event.Detected, Time: at.Add(time.Hour), NewIPs: first},
+		{Type: event.Detected, Time: at.Add(2 * time.Hour), NewIPs: second},
+		{Type: event.Failed, Time: at.Add(2 * time.Hour), Provider: "home", OldIPs: first, NewIPs: second, Err: errors.New("badauth")},
+	} {
+		if err := l.Handle(ctx, ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.Close()
+	// a line cut short by a crash is ignored
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	f.WriteString(`{"time":"2026-01-02T`)
+	f.Close()
+
+	all, err := Read(path, Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 4 {
+		t.Fatalf("expected 4 records, got %+v", all)
+	}
+	if r := all[2]; r.Type != Detected || !r.OldIPs[0].Equal(first[0]) || !r.NewIPs[0].Equal(second[0]) || !r.Time.Equal(at.Add(2*time.Hour)) {
+		t.Errorf("unexpected detected change %+v", r)
+	}
+	if r := all[3]; r.Type != Update || r.Result != Failure || r.Error != "badauth" || r.Provider != "home" {
+		t.Errorf("unexpected failed update %+v", r)
+	}
+
+	for _, tc := range []struct {
+		q    Query
+		want int
+	}{
+		{Query{Type: Update}, 2},
+		{Query{Failures: true}, 1},
+		{Query{Since: at.Add(time.Hour)}, 2},
+		{Query{Until: at.Add(time.Hour)}, 2},
+		{Query{Provider: "home", Limit: 1}, 1},
+	} {
+		rs, err := Read(path, tc.q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rs) != tc.want {
+			t.Errorf("query %+v: got %d records, want %d", tc.q, len(rs), tc.want)
+		}
+	}
+	if rs, _ := Read(path, Query{Limit: 1}); len(rs) != 1 || rs[0].Result != Failure {
+		t.Errorf("expected the limit to keep the most recent record, got %+v", rs)
+	}
+}
+
+func TestLogAfterCrash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	data := `{"time":"2026-01-02T03:04:05Z","type":"detected","new_ips":["203.0.113.1"]}` + "\n" + `{"time":"2026-01-02T`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// the line cut short by the crash is removed, so the next record starts a line of its own
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Append(Record{Time: at, Type: Update, Provider: "home", Result: Success}); err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	rs, err := Read(path, Query{})
+	if err != nil || len(rs) != 2 || rs[1].Provider != "home" {
+		t.Fatalf("expected 2 records, got %+v: %v", rs, err)
+	}
+	l, err = Open(path)
+	if err != nil {
+		t.Fatalf("expected the log to open again: %v", err)
+	}
+	l.Close()
+
+	// a corrupt line, such as one left by an older version, is skipped and reported
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	f.WriteString(`{"time":"2026-01-02T{"time":"2026-01-02T03:04:05Z","type":"update"}` + "\n")
+	f.WriteString(`{"time":"2026-01-02T03:05:05Z","type":"update","provider":"home","result":"failure"}` + "\n")
+	f.Close()
+	rs, err = Read(path, Query{})
+	var corrupt *CorruptError
+	if !errors.As(err, &corrupt) || len(corrupt.Lines) != 1 || corrupt.Lines[0] != 3 || len(rs) != 3 {
+		t.Errorf("expected the records around the corrupt line 3, got %+v: %v", rs, err)
+	}
+	l, err = Open(path)
+	if err != nil {
+		t.Fatalf("expected a log with a corrupt line to open: %v", err)
+	}
+	l.Close()
+}
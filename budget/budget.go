@@ -0,0 +1,307 @@
+// Package budget caps how much traffic ddns sends over metered connections, such as an LTE backup WAN,
+// by limiting the number of requests and bytes per period and deferring traffic that can wait.
+package budget // import "github.com/justenwalker/ddns/budget"
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/justenwalker/ddns/power"
+)
+
+// sensorTTL is how long a metered state reported by the sensor is reused
+const sensorTTL = time.Minute
+
+// Logger for printing debug logs from this package
+type Logger interface {
+	Log(format string, v ...interface{})
+}
+
+// Class is the kind of traffic charged to a budget
+type Class int
+
+const (
+	// Essential traffic detects the address and updates providers. It may use the whole budget.
+	Essential Class = iota
+	// Deferrable traffic, such as verification, may only use the reserve fraction of the budget
+	// and otherwise waits until the budget resets
+	Deferrable
+)
+
+func (c Class) String() string {
+	switch c {
+	case Essential:
+		return "essential"
+	case Deferrable:
+		return "deferrable"
+	}
+	return fmt.Sprintf("Class(%d)", int(c))
+}
+
+// ExhaustedError is returned when traffic of a class is not allowed until the budget resets
+type ExhaustedError struct {
+	Class Class
+	Reset time.Time
+}
+
+func (e *ExhaustedError) Error() string {
+	return fmt.Sprintf("budget: %v traffic budget exhausted until %s", e.Class, e.Reset.Format(time.RFC3339))
+}
+
+// Usage is the traffic charged in the current period
+type Usage struct {
+	Calls int
+	Bytes int64
+	// Reset is when the period ends; it is zero before anything has been charged
+	Reset time.Time
+}
+
+// Option sets budget options
+type Option func(*Budget)
+
+// Log enables budget logging using the given Logger
+func Log(l Logger) Option {
+	return func(b *Budget) {
+		b.logger = l
+	}
+}
+
+// Calls limits the number of requests per period; zero, the default, is unlimited
+func Calls(n int) Option {
+	return func(b *Budget) {
+		b.calls = n
+	}
+}
+
+// Bytes limits the request and response body bytes per period; zero, the default, is unlimited
+func Bytes(n int64) Option {
+	return func(b *Budget) {
+		b.bytes = n
+	}
+}
+
+// Period sets how long a budget lasts before it resets; the default is 24 hours.
+// A period starts with the first request charged after the previous one ended.
+func Period(d time.Duration) Option {
+	return func(b *Budget) {
+		b.period = d
+	}
+}
+
+// Reserve sets the fraction of the budget deferrable traffic may use; the default is 0.5,
+// which keeps half of the budget for detecting the address and updating providers
+func Reserve(fraction float64) Option {
+	return func(b *Budget) {
+		b.reserve = fraction
+	}
+}
+
+// Metered only enforces the budget while sensor reports a metered network.
+// Traffic on unmetered networks is neither limited nor charged.
+// Without this option the budget is always enforced.
+func Metered(sensor power.Sensor) Option {
+	return func(b *Budget) {
+		b.sensor = sensor
+	}
+}
+
+// Budget limits traffic per period. It is safe for concurrent use.
+type Budget struct {
+	logger  Logger
+	calls   int
+	bytes   int64
+	period  time.Duration
+	reserve float64
+	sensor  power.Sensor
+	now     func() time.Time
+
+	mu        sync.Mutex
+	used      Usage
+	metered   bool
+	checkedAt time.Time
+	warned    map[Class]bool
+	deferred  map[string]func(ctx context.Context) error
+	timer     *time.Timer
+}
+
+// New constructs a budget
+func New(options ...Option) *Budget {
+	b := &Budget{
+		period:  24 * time.Hour,
+		reserve: 0.5,
+		now:     time.Now,
+	}
+	for _, opt := range options {
+		opt(b)
+	}
+	return b
+}
+
+func (b *Budget) logf(format string, v ...interface{}) {
+	if b.logger != nil {
+		b.logger.Log(format, v...)
+	}
+}
+
+// enforced returns whether the budget currently applies, querying the sensor at most once per sensorTTL
+func (b *Budget) enforced(ctx context.Context, now time.Time) bool {
+	if b.sensor == nil {
+		return true
+	}
+	b.mu.Lock()
+	if !b.checkedAt.IsZero() && now.Sub(b.checkedAt) < sensorTTL {
+		defer b.mu.Unlock()
+		return b.metered
+	}
+	b.mu.Unlock()
+	st, err := b.sensor.State(ctx)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err != nil {
+		// keep the last known state; before the first reading, err on the side of enforcing
+		b.logf("budget: reading network state: %v", err)
+		return b.metered || b.checkedAt.IsZero()
+	}
+	b.metered = st.Metered
+	b.checkedAt = now
+	return b.metered
+}
+
+// roll starts a new period if the current one has ended. It must be called with b.mu held.
+func (b *Budget) roll(now time.Time) {
+	if !b.used.Reset.IsZero() && now.Before(b.used.Reset) {
+		return
+	}
+	if !b.used.Reset.IsZero() {
+		b.logf("budget: period ended after %d calls and %d bytes", b.used.Calls, b.used.Bytes)
+	}
+	b.used = Usage{Reset: now.Add(b.period)}
+	b.warned = nil
+}
+
+// exhausted returns whether class has used its share of the budget. It must be called with b.mu held.
+func (b *Budget) exhausted(class Class) bool {
+	share := 1.0
+	if class == Deferrable {
+		share = b.reserve
+	}
+	if b.calls > 0 && float64(b.used.Calls) >= share*float64(b.calls) {
+		return true
+	}
+	return b.bytes > 0 && float64(b.used.Bytes) >= share*float64(b.bytes)
+}
+
+func (b *Budget) check(ctx context.Context, class Class) error {
+	now := b.now()
+	if !b.enforced(ctx, now) {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.roll(now)
+	if !b.exhausted(class) {
+		return nil
+	}
+	if !b.warned[class] {
+		if b.warned == nil {
+			b.warned = make(map[Class]bool)
+		}
+		b.warned[class] = true
+		b.logf("budget: %v traffic budget exhausted until %v", class, b.used.Reset)
+	}
+	return &ExhaustedError{Class: class, Reset: b.used.Reset}
+}
+
+// Check returns an *ExhaustedError if traffic of class is not allowed now, without charging anything
+func (b *Budget) Check(ctx context.Context, class Class) error {
+	return b.check(ctx, class)
+}
+
+// Allow charges one call to the budget, or returns an *ExhaustedError if traffic of class is not allowed now
+func (b *Budget) Allow(ctx context.Context, class Class) error {
+	if err := b.check(ctx, class); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.sensor == nil || b.metered {
+		b.roll(b.now())
+		b.used.Calls++
+	}
+	return nil
+}
+
+// Add charges n bytes to the budget. Nothing is charged while the budget is not enforced.
+func (b *Budget) Add(n int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.sensor == nil || b.metered {
+		b.roll(b.now())
+		b.used.Bytes += n
+	}
+}
+
+// Usage returns the traffic charged in the current period
+func (b *Budget) Usage() Usage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.used.Reset.IsZero() && !b.now().Before(b.used.Reset) {
+		return Usage{}
+	}
+	return b.used
+}
+
+// Defer runs fn now if deferrable traffic is allowed, and otherwise schedules it to run once the budget resets.
+// Only the latest fn deferred under each key is kept, so a stale verification is replaced by a newer one.
+// It returns the error of fn, or nil if fn was deferred; errors of deferred runs are logged.
+func (b *Budget) Defer(ctx context.Context, key string, fn func(ctx context.Context) error) error {
+	err := b.check(ctx, Deferrable)
+	if err == nil {
+		return fn(ctx)
+	}
+	reset := err.(*ExhaustedError).Reset
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.deferred == nil {
+		b.deferred = make(map[string]func(ctx context.Context) error)
+	}
+	b.deferred[key] = fn
+	b.logf("budget: deferring %s until %v", key, reset)
+	b.schedule(reset)
+	return nil
+}
+
+// schedule runs the deferred functions at t. It must be called with b.mu held.
+func (b *Budget) schedule(t time.Time) {
+	if b.timer != nil {
+		return
+	}
+	b.timer = time.AfterFunc(t.Sub(b.now()), b.flush)
+}
+
+// flush runs the deferred functions, deferring them again if the budget is still exhausted
+func (b *Budget) flush() {
+	b.mu.Lock()
+	b.timer = nil
+	deferred := b.deferred
+	b.deferred = nil
+	b.mu.Unlock()
+	for key, fn := range deferred {
+		if err := b.Defer(context.Background(), key, fn); err != nil {
+			b.logf("budget: deferred %s: %v", key, err)
+		}
+	}
+}
+
+// Stop discards the deferred functions that have not run yet
+func (b *Budget) Stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	b.deferred = nil
+}
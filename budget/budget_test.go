@@ -0,0 +1,148 @@
+package budget
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/justenwalker/ddns/power"
+)
+
+func TestAllow(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1000, 0)
+	b := New(Calls(4), Period(time.Hour))
+	b.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if err := b.Allow(ctx, Deferrable); err != nil {
+			t.Fatalf("deferrable call %d: %v", i, err)
+		}
+	}
+	var exhausted *ExhaustedError
+	if err := b.Allow(ctx, Deferrable); !errors.As(err, &exhausted) {
+		t.Fatalf("expected deferrable traffic to be limited to the reserve, got %v", err)
+	}
+	if exhausted.Reset != now.Add(time.Hour) {
+		t.Errorf("reset = %v", exhausted.Reset)
+	}
+	for i := 0; i < 2; i++ {
+		if err := b.Allow(ctx, Essential); err != nil {
+			t.Fatalf("essential call %d: %v", i, err)
+		}
+	}
+	if err := b.Allow(ctx, Essential); err == nil {
+		t.Fatal("expected essential traffic to be limited")
+	}
+	if u := b.Usage(); u.Calls != 4 {
+		t.Errorf("calls = %d, want 4", u.Calls)
+	}
+
+	now = now.Add(time.Hour)
+	if err := b.Allow(ctx, Essential); err != nil {
+		t.Fatalf("expected the budget to reset: %v", err)
+	}
+}
+
+func TestBytes(t *testing.T) {
+	ctx := context.Background()
+	b := New(Bytes(100))
+	b.Add(60)
+	if err := b.Check(ctx, Deferrable); err == nil {
+		t.Error("expected deferrable traffic to be limited")
+	}
+	if err := b.Check(ctx, Essential); err != nil {
+		t.Errorf("essential: %v", err)
+	}
+	b.Add(40)
+	if err := b.Check(ctx, Essential); err == nil {
+		t.Error("expected essential traffic to be limited")
+	}
+}
+
+func TestMetered(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1000, 0)
+	metered := false
+	b := New(Calls(1), Metered(power.SensorFunc(func(ctx context.Context) (power.State, error) {
+		return power.State{Metered: metered}, nil
+	})))
+	b.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if err := b.Allow(ctx, Essential); err != nil {
+			t.Fatalf("unmetered call %d: %v", i, err)
+		}
+	}
+	if u := b.Usage(); u.Calls != 0 {
+		t.Errorf("unmetered calls were charged: %d", u.Calls)
+	}
+	metered = true
+	now = now.Add(sensorTTL)
+	if err := b.Allow(ctx, Essential); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Allow(ctx, Essential); err == nil {
+		t.Fatal("expected metered traffic to be limited")
+	}
+}
+
+func TestTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "203.0.113.7")
+	}))
+	defer srv.Close()
+	b := New(Calls(1))
+	hc := &http.Client{Transport: b.Transport(nil, Essential)}
+
+	resp, err := hc.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if u := b.Usage(); u.Calls != 1 || u.Bytes != int64(len("203.0.113.7")) {
+		t.Errorf("usage = %+v", u)
+	}
+	var exhausted *ExhaustedError
+	if _, err := hc.Get(srv.URL); !errors.As(err, &exhausted) {
+		t.Errorf("expected the second request to be refused, got %v", err)
+	}
+}
+
+func TestDefer(t *testing.T) {
+	ctx := context.Background()
+	b := New(Calls(2), Period(50*time.Millisecond))
+	defer b.Stop()
+	b.Allow(ctx, Essential)
+
+	ran := make(chan string, 2)
+	for _, v := range []string{"stale", "latest"} {
+		v := v
+		err := b.Defer(ctx, "verify", func(ctx context.Context) error {
+			ran <- v
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case v := <-ran:
+		if v != "latest" {
+			t.Errorf("ran %q, want the latest deferred function", v)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("deferred function did not run after the budget reset")
+	}
+	select {
+	case v := <-ran:
+		t.Errorf("replaced function %q ran", v)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,54 @@
+package budget
+
+import (
+	"io"
+	"net/http"
+)
+
+// Transport returns a RoundTripper that charges every request made through rt to the budget as class.
+// Requests are refused with an *ExhaustedError once the class has used its share of the budget.
+// Request and response bodies are charged; headers and TLS overhead are not.
+func (b *Budget) Transport(rt http.RoundTripper, class Class) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return &transport{budget: b, rt: rt, class: class}
+}
+
+type transport struct {
+	budget *Budget
+	rt     http.RoundTripper
+	class  Class
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.budget.Allow(req.Context(), t.class); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	if req.ContentLength > 0 {
+		t.budget.Add(req.ContentLength)
+	}
+	resp, err := t.rt.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &countingBody{ReadCloser: resp.Body, budget: t.budget}
+	return resp, nil
+}
+
+// countingBody charges the bytes read from a response body
+type countingBody struct {
+	io.ReadCloser
+	budget *Budget
+}
+
+func (c *countingBody) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	if n > 0 {
+		c.budget.Add(int64(n))
+	}
+	return n, err
+}
@@ -0,0 +1,57 @@
+package cdn
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// BunnyPullZone replaces the host of a bunny.net pull zone's origin URL, keeping its scheme, port and path.
+// A pull zone has a single origin, so only changes of the family selected by IPv6 are applied.
+type BunnyPullZone struct {
+	AccessKey  string
+	PullZoneID int
+	IPv6       bool
+
+	// Endpoint defaults to https://api.bunny.net
+	Endpoint   string
+	HTTPClient HTTPRequester
+}
+
+// Replace points the origin URL at new
+func (z BunnyPullZone) Replace(ctx context.Context, old, new net.IP) error {
+	if isIPv6(new) != z.IPv6 {
+		return nil
+	}
+	endpoint := z.Endpoint
+	if endpoint == "" {
+		endpoint = "https://api.bunny.net"
+	}
+	zoneURL := strings.TrimSuffix(endpoint, "/") + "/pullzone/" + strconv.Itoa(z.PullZoneID)
+	header := http.Header{"Accesskey": {z.AccessKey}}
+	var zone struct {
+		OriginURL string `json:"OriginUrl"`
+	}
+	if err := (apiRequest{client: z.HTTPClient, method: http.MethodGet, url: zoneURL, header: header}).do(ctx, &zone); err != nil {
+		return err
+	}
+	origin, err := url.Parse(zone.OriginURL)
+	if err != nil || origin.Host == "" {
+		origin = &url.URL{Scheme: "http"}
+	}
+	host := new.String()
+	if port := origin.Port(); port != "" {
+		host = net.JoinHostPort(host, port)
+	} else if isIPv6(new) {
+		host = "[" + host + "]"
+	}
+	origin.Host = host
+	if origin.String() == zone.OriginURL {
+		return nil
+	}
+	body := map[string]string{"OriginUrl": origin.String()}
+	return apiRequest{client: z.HTTPClient, method: http.MethodPost, url: zoneURL, header: header, body: body}.do(ctx, nil)
+}
@@ -0,0 +1,93 @@
+// Package cdn updates the origin address configured at CDNs and reverse proxies when the origin's address changes,
+// for users fronting a dynamically addressed server with a CDN.
+package cdn // import "github.com/justenwalker/ddns/cdn"
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/justenwalker/ddns/event"
+	"github.com/justenwalker/ddns/internal/httpreq"
+)
+
+// HTTPRequester makes http requests and returns responses.
+// *http.Client implicitly implements HTTPRequester and can be provided wherever this interface is requested.
+type HTTPRequester = httpreq.Requester
+
+// Updater replaces the origin address
+type Updater = event.Replacer
+
+// Sink returns an event sink that applies Changed events for hostname to the updater; see event.ReplaceSink
+func Sink(u Updater, hostname string) event.Sink {
+	return event.ReplaceSink(u, hostname)
+}
+
+// apiRequest is a JSON request to a CDN API
+type apiRequest struct {
+	client HTTPRequester
+	method string
+	url    string
+	header http.Header
+	// body is encoded as JSON unless it is an io.Reader
+	body interface{}
+}
+
+func (r apiRequest) do(ctx context.Context, out interface{}) error {
+	var body io.Reader
+	contentType := "application/json"
+	switch b := r.body.(type) {
+	case nil:
+	case io.Reader:
+		body = b
+		contentType = "application/x-www-form-urlencoded"
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(r.method, r.url, body)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	for k, v := range r.header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	hc := r.client
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("cdn: %s %s: %s: %s", r.method, r.url, resp.Status, strings.TrimSpace(string(data)))
+	}
+	if out != nil && len(data) > 0 {
+		return json.Unmarshal(data, out)
+	}
+	return nil
+}
+
+func isIPv6(ip net.IP) bool {
+	return ip.To4() == nil
+}
@@ -0,0 +1,107 @@
+package cdn
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBunnyPullZone(t *testing.T) {
+	var updated string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("AccessKey") != "key" || r.URL.Path != "/pullzone/42" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodPost {
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			updated = body["OriginUrl"]
+			return
+		}
+		w.Write([]byte(`{"OriginUrl":"https://203.0.113.1:8443/site"}`))
+	}))
+	defer srv.Close()
+	z := BunnyPullZone{AccessKey: "key", PullZoneID: 42, Endpoint: srv.URL}
+	if err := z.Replace(context.Background(), net.ParseIP("203.0.113.1"), net.ParseIP("203.0.113.2")); err != nil {
+		t.Fatal(err)
+	}
+	if updated != "https://203.0.113.2:8443/site" {
+		t.Errorf("unexpected origin %q", updated)
+	}
+}
+
+func TestFastlyBackend(t *testing.T) {
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Fastly-Key") != "key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		r.ParseForm()
+		calls = append(calls, r.Method+" "+r.URL.Path+" "+r.PostForm.Get("address"))
+		switch r.URL.Path {
+		case "/service/svc/version/active":
+			w.Write([]byte(`{"number":3}`))
+		case "/service/svc/version/3/clone":
+			w.Write([]byte(`{"number":4}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+	f := FastlyBackend{APIKey: "key", ServiceID: "svc", Backend: "home", Endpoint: srv.URL}
+	if err := f.Replace(context.Background(), nil, net.ParseIP("203.0.113.2")); err != nil {
+		t.Fatal(err)
+	}
+	want := "GET /service/svc/version/active |PUT /service/svc/version/3/clone |" +
+		"PUT /service/svc/version/4/backend/home 203.0.113.2|PUT /service/svc/version/4/activate "
+	if got := strings.Join(calls, "|"); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestCloudflareOriginRule(t *testing.T) {
+	var patched []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/zones/zone/rulesets/phases/http_request_origin/entrypoint":
+			w.Write([]byte(`{"success":true,"result":{"id":"ruleset","rules":[` +
+				`{"id":"r1","description":"api","action":"route","action_parameters":{"host_header":"api.example.com"}},` +
+				`{"id":"r2","description":"home","action":"route","action_parameters":{"origin":{"host":"origin.example.com","port":8443}}}]}}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/zones/zone/dns_records":
+			if q := r.URL.Query(); q.Get("name") != "origin.example.com" || q.Get("type") != "AAAA" {
+				t.Errorf("unexpected query %s", r.URL.RawQuery)
+			}
+			w.Write([]byte(`{"success":true,"result":[{"id":"rec","content":"2001:db8::1"}]}`))
+		case r.Method == http.MethodPatch && r.URL.Path == "/zones/zone/dns_records/rec":
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			patched = append(patched, body["content"])
+			w.Write([]byte(`{"success":true,"result":{}}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	o := CloudflareOriginRule{APIToken: "token", ZoneID: "zone", Rule: "home", Endpoint: srv.URL}
+	if err := o.Replace(context.Background(), net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2")); err != nil {
+		t.Fatal(err)
+	}
+	if len(patched) != 1 || patched[0] != "2001:db8::2" {
+		t.Errorf("expected the record of the rule to be updated, got %v", patched)
+	}
+	o.Rule = "r1"
+	if err := o.Replace(context.Background(), nil, net.ParseIP("2001:db8::2")); err == nil || !strings.Contains(err.Error(), "does not override") {
+		t.Errorf("expected an error for a rule without an origin override, got %v", err)
+	}
+	o.Rule = "missing"
+	if err := o.Replace(context.Background(), nil, net.ParseIP("2001:db8::2")); err == nil {
+		t.Error("expected an error for a missing rule")
+	}
+}
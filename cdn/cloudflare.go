@@ -0,0 +1,92 @@
+package cdn
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/justenwalker/ddns/internal/cfapi"
+)
+
+// CloudflareOriginRule keeps the origin that a Cloudflare Origin Rule routes requests to at the new address.
+// Origin Rules override the origin with a hostname, which must be a proxied DNS record of the zone, rather than
+// with an address: the record named by the rule is found in the rule and its content is updated.
+// The A records are updated for IPv4 addresses and the AAAA records for IPv6 addresses.
+type CloudflareOriginRule struct {
+	APIToken string
+	ZoneID   string
+	// Rule is the ID or the description of the rule in the http_request_origin phase of the zone
+	Rule string
+
+	Endpoint   string
+	HTTPClient HTTPRequester
+}
+
+type originRule struct {
+	ID               string `json:"id"`
+	Description      string `json:"description"`
+	Action           string `json:"action"`
+	ActionParameters struct {
+		Origin *struct {
+			Host string `json:"host"`
+		} `json:"origin"`
+	} `json:"action_parameters"`
+}
+
+type dnsRecord struct {
+	ID      string `json:"id"`
+	Content string `json:"content"`
+}
+
+// Replace points the record the rule routes to at new
+func (o CloudflareOriginRule) Replace(ctx context.Context, old, new net.IP) error {
+	c := &cfapi.Client{Token: o.APIToken, Endpoint: o.Endpoint, HTTPClient: o.HTTPClient}
+	host, err := o.origin(ctx, c)
+	if err != nil {
+		return err
+	}
+	typ := "A"
+	if isIPv6(new) {
+		typ = "AAAA"
+	}
+	q := url.Values{}
+	q.Set("type", typ)
+	q.Set("name", host)
+	var records []dnsRecord
+	base := "/zones/" + o.ZoneID + "/dns_records"
+	if err := c.Do(ctx, http.MethodGet, base+"?"+q.Encode(), nil, &records); err != nil {
+		return err
+	}
+	for _, r := range records {
+		if r.Content == new.String() {
+			continue
+		}
+		if err := c.Do(ctx, http.MethodPatch, base+"/"+r.ID, map[string]string{"content": new.String()}, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// origin returns the hostname the rule overrides the origin with
+func (o CloudflareOriginRule) origin(ctx context.Context, c *cfapi.Client) (string, error) {
+	var ruleset struct {
+		Rules []originRule `json:"rules"`
+	}
+	if err := c.Do(ctx, http.MethodGet, "/zones/"+o.ZoneID+"/rulesets/phases/http_request_origin/entrypoint", nil, &ruleset); err != nil {
+		return "", err
+	}
+	for _, r := range ruleset.Rules {
+		if r.ID != o.Rule && r.Description != o.Rule {
+			continue
+		}
+		if r.Action != "route" || r.ActionParameters.Origin == nil || r.ActionParameters.Origin.Host == "" {
+			return "", fmt.Errorf("cdn: cloudflare: origin rule %q does not override the origin hostname", o.Rule)
+		}
+		return strings.TrimSuffix(r.ActionParameters.Origin.Host, "."), nil
+	}
+	return "", fmt.Errorf("cdn: cloudflare: no origin rule %q in zone %s", o.Rule, o.ZoneID)
+}
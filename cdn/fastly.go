@@ -0,0 +1,60 @@
+package cdn
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// FastlyBackend updates the address of a Fastly service backend.
+// Fastly versions are immutable once active, so the active version is cloned, the backend updated,
+// and the clone activated.
+// A backend has a single address, so only changes of the family selected by IPv6 are applied.
+type FastlyBackend struct {
+	APIKey    string
+	ServiceID string
+	Backend   string
+	IPv6      bool
+
+	// Endpoint defaults to https://api.fastly.com
+	Endpoint   string
+	HTTPClient HTTPRequester
+}
+
+type fastlyVersion struct {
+	Number int `json:"number"`
+}
+
+// Replace sets the backend address to new and activates the change
+func (f FastlyBackend) Replace(ctx context.Context, old, new net.IP) error {
+	if isIPv6(new) != f.IPv6 {
+		return nil
+	}
+	endpoint := f.Endpoint
+	if endpoint == "" {
+		endpoint = "https://api.fastly.com"
+	}
+	service := strings.TrimSuffix(endpoint, "/") + "/service/" + url.PathEscape(f.ServiceID)
+	header := http.Header{"Fastly-Key": {f.APIKey}}
+	req := func(method, u string, body interface{}) apiRequest {
+		return apiRequest{client: f.HTTPClient, method: method, url: u, header: header, body: body}
+	}
+
+	var active fastlyVersion
+	if err := req(http.MethodGet, service+"/version/active", nil).do(ctx, &active); err != nil {
+		return err
+	}
+	var clone fastlyVersion
+	if err := req(http.MethodPut, service+"/version/"+strconv.Itoa(active.Number)+"/clone", nil).do(ctx, &clone); err != nil {
+		return err
+	}
+	version := service + "/version/" + strconv.Itoa(clone.Number)
+	form := url.Values{"address": {new.String()}}
+	if err := req(http.MethodPut, version+"/backend/"+url.PathEscape(f.Backend), strings.NewReader(form.Encode())).do(ctx, nil); err != nil {
+		return err
+	}
+	return req(http.MethodPut, version+"/activate", nil).do(ctx, nil)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/justenwalker/ddns/internal/chaos"
+)
+
+// runChaos runs the hidden soak test mode, ddns daemon --chaos, which simulates days of faults against fake
+// backends and exits with 1 if the daemon broke an invariant
+func runChaos(args []string, stdout, stderr io.Writer) int {
+	var duration, interval time.Duration
+	var seed int64
+	var flap, detectFail, updateFail, outage, restart float64
+	var verbose bool
+	fs := flag.NewFlagSet("daemon --chaos", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.DurationVar(&duration, "duration", 7*24*time.Hour, "simulated time to run faults for, followed by 3 quiet hours")
+	fs.DurationVar(&interval, "interval", 5*time.Minute, "detection interval of the simulated daemon")
+	fs.Int64Var(&seed, "seed", time.Now().UnixNano(), "seed of the random faults, to replay a run")
+	fs.Float64Var(&flap, "flap-rate", 0.02, "probability that a detection finds a new address")
+	fs.Float64Var(&detectFail, "detect-fail-rate", 0.02, "probability that a detection fails")
+	fs.Float64Var(&updateFail, "update-fail-rate", 0.1, "probability that an update fails")
+	fs.Float64Var(&outage, "outage-rate", 0.002, "probability per step that a provider goes down for up to 6 hours")
+	fs.Float64Var(&restart, "restart-rate", 0.002, "probability per step that the daemon restarts from its saved state")
+	fs.BoolVar(&verbose, "v", false, "log daily progress and violations as they happen")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: ddns daemon --chaos [flags]")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Soak tests the daemon against fake backends on a simulated clock. Not for production use.")
+		fmt.Fprintln(stderr)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	opts := []chaos.Option{
+		chaos.Seed(seed),
+		chaos.Duration(duration),
+		chaos.Interval(interval),
+		chaos.Rates(flap, detectFail, updateFail, outage, restart),
+	}
+	if verbose {
+		opts = append(opts, chaos.Log(newLogger(stderr)))
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	r := chaos.New(opts...).Run(ctx)
+	fmt.Fprintf(stdout, "seed %d: %s\n", seed, r)
+	for _, v := range r.Violations {
+		fmt.Fprintf(stdout, "  %s\n", v)
+	}
+	if !r.OK() {
+		return exitFailure
+	}
+	return exitOK
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/justenwalker/ddns/config"
+	"github.com/justenwalker/ddns/logging/syslog"
+)
+
+func init() {
+	// added here rather than in the table, as it lists the other commands
+	commands = append(commands, command{"completion", "print a shell completion script for bash, zsh or fish", runCompletion})
+}
+
+func runCompletion(args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("completion", stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: ddns completion bash|zsh|fish")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Prints a script completing the commands and flags of ddns, the address sources and formats,")
+		fmt.Fprintln(stderr, "and the profiles of the -config file. Load it in the current shell with:")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "  source <(ddns completion bash)")
+		fmt.Fprintln(stderr, "  source <(ddns completion zsh)")
+		fmt.Fprintln(stderr, "  ddns completion fish | source")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "or save it where the shell loads completions from, such as /etc/bash_completion.d/ddns,")
+		fmt.Fprintln(stderr, "a directory of $fpath as _ddns, or ~/.config/fish/completions/ddns.fish.")
+	}
+	if len(args) == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	action := args[0]
+	if err := fs.Parse(args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	switch action {
+	case "bash":
+		writeBash(stdout, completionCommands())
+	case "zsh":
+		writeZsh(stdout, completionCommands())
+	case "fish":
+		writeFish(stdout, completionCommands())
+	case "profiles":
+		// called by the scripts to complete -profile
+		if fs.NArg() != 1 {
+			return exitUsage
+		}
+		c, err := config.Load(fs.Arg(0))
+		if err != nil {
+			fmt.Fprintf(stderr, "ddns: %v\n", err)
+			return exitFailure
+		}
+		for _, name := range c.ProfileNames() {
+			fmt.Fprintln(stdout, name)
+		}
+	default:
+		fmt.Fprintf(stderr, "ddns: unknown shell %q\n", action)
+		return exitUsage
+	}
+	return exitOK
+}
+
+// flagValues are the values completed for flags that take one of a few words
+var flagValues = map[string][]string{
+	"provider":        {"dynu"},
+	"source":          {"ipify", "stun", "hook", "interface"},
+	"log-format":      {"text", "json"},
+	"output":          {"text", "json"},
+	"syslog-facility": syslog.Facilities(),
+}
+
+// fileFlags take a path
+var fileFlags = []string{"config", "state", "o", "log-file", "event-log", "status-file", "pid-file", "password-file", "rules", "dashboard"}
+
+// completionCommand is a command as completed by the scripts
+type completionCommand struct {
+	name, summary string
+	actions       []string
+	flags         []completionFlag
+}
+
+type completionFlag struct {
+	name, usage string
+	takesValue  bool
+}
+
+// completionCommands lists the commands and their flags, found by asking each of them for its usage
+func completionCommands() []completionCommand {
+	defer func() { flagSetCreated = nil }()
+	var cmds []completionCommand
+	for _, c := range commands {
+		cc := completionCommand{name: c.name, summary: c.summary, actions: actionCommands[c.name]}
+		if c.name == "completion" {
+			cmds = append(cmds, cc)
+			continue
+		}
+		var sets []*flag.FlagSet
+		flagSetCreated = func(fs *flag.FlagSet) { sets = append(sets, fs) }
+		args := []string{"-h"}
+		if len(cc.actions) > 0 {
+			args = []string{cc.actions[0], "-h"}
+		}
+		c.run(args, io.Discard, io.Discard)
+		for _, fs := range sets {
+			fs.VisitAll(func(fl *flag.Flag) {
+				boolFlag, ok := fl.Value.(interface{ IsBoolFlag() bool })
+				cc.flags = append(cc.flags, completionFlag{
+					name:       fl.Name,
+					usage:      firstClause(fl.Usage),
+					takesValue: !ok || !boolFlag.IsBoolFlag(),
+				})
+			})
+		}
+		cmds = append(cmds, cc)
+	}
+	return cmds
+}
+
+// firstClause shortens the usage of a flag for the completion menus
+func firstClause(usage string) string {
+	usage, _, _ = strings.Cut(usage, "; ")
+	return usage
+}
+
+// valueFlags returns the flags of every command that take one of flagValues, sorted
+func valueFlags() []string {
+	names := make([]string, 0, len(flagValues))
+	for name := range flagValues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func flagNames(fls []completionFlag) string {
+	names := make([]string, len(fls))
+	for i, fl := range fls {
+		names[i] = "-" + fl.name
+	}
+	return strings.Join(names, " ")
+}
+
+func dashed(names []string) string {
+	out := make([]string, len(names))
+	for i, name := range names {
+		out[i] = "-" + name
+	}
+	return strings.Join(out, " | ")
+}
+
+func writeBash(w io.Writer, cmds []completionCommand) {
+	fmt.Fprintln(w, `# bash completion for ddns, generated by "ddns completion bash"`)
+	fmt.Fprintln(w, `_ddns() {
+	local cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]}
+	local i cmd pos words
+	for ((i = 1; i < COMP_CWORD; i++)); do
+		case ${COMP_WORDS[i]} in
+		-output | --output) ((i++)) ;;
+		-*) ;;
+		*)
+			cmd=${COMP_WORDS[i]} pos=$i
+			break
+			;;
+		esac
+	done
+	case $prev in`)
+	fmt.Fprintf(w, "\t%s)\n\t\tCOMPREPLY=($(compgen -f -- \"$cur\"))\n\t\treturn\n\t\t;;\n", dashed(fileFlags))
+	fmt.Fprintln(w, `	-profile)
+		for ((i = 1; i < COMP_CWORD - 1; i++)); do
+			if [[ ${COMP_WORDS[i]} == -config ]]; then
+				COMPREPLY=($(compgen -W "$(ddns completion profiles "${COMP_WORDS[i+1]}" 2>/dev/null)" -- "$cur"))
+			fi
+		done
+		return
+		;;`)
+	for _, name := range valueFlags() {
+		pattern := "-" + name
+		if name == "output" {
+			pattern += " | --output"
+		}
+		fmt.Fprintf(w, "\t%s)\n\t\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n\t\treturn\n\t\t;;\n", pattern, strings.Join(flagValues[name], " "))
+	}
+	fmt.Fprintln(w, "\tesac")
+	fmt.Fprintln(w, "\tcase $cmd in")
+	names := make([]string, len(cmds))
+	for i, c := range cmds {
+		names[i] = c.name
+	}
+	fmt.Fprintf(w, "\t\"\") words=%q ;;\n", strings.Join(names, " ")+" --dry-run --output")
+	for _, c := range cmds {
+		if len(c.actions) > 0 {
+			fmt.Fprintf(w, "\t%s)\n\t\tif ((COMP_CWORD == pos + 1)); then words=%q; else words=%q; fi\n\t\t;;\n",
+				c.name, strings.Join(c.actions, " "), flagNames(c.flags))
+			continue
+		}
+		fmt.Fprintf(w, "\t%s) words=%q ;;\n", c.name, flagNames(c.flags))
+	}
+	fmt.Fprintln(w, "\tesac")
+	fmt.Fprintln(w, `	COMPREPLY=($(compgen -W "$words" -- "$cur"))
+}
+complete -F _ddns ddns`)
+}
+
+// zshItem formats a completion with its description for _describe, single-quoted
+func zshItem(name, desc string) string {
+	return "'" + strings.ReplaceAll(name+":"+desc, "'", `'\''`) + "'"
+}
+
+func zshItems(fls []completionFlag) string {
+	items := make([]string, len(fls))
+	for i, fl := range fls {
+		items[i] = zshItem("-"+fl.name, fl.usage)
+	}
+	return strings.Join(items, " ")
+}
+
+func writeZsh(w io.Writer, cmds []completionCommand) {
+	fmt.Fprintln(w, `#compdef ddns
+# zsh completion for ddns, generated by "ddns completion zsh"
+_ddns() {
+	local i cmd pos
+	local -a items
+	for ((i = 2; i < CURRENT; i++)); do
+		case ${words[i]} in
+		-output | --output) ((i++)) ;;
+		-*) ;;
+		*)
+			cmd=${words[i]} pos=$i
+			break
+			;;
+		esac
+	done
+	case ${words[CURRENT-1]} in`)
+	fmt.Fprintf(w, "\t%s)\n\t\t_files\n\t\treturn\n\t\t;;\n", dashed(fileFlags))
+	fmt.Fprintln(w, `	-profile)
+		i=${words[(I)-config]}
+		((i)) && compadd -- ${(f)"$(ddns completion profiles ${words[i+1]} 2>/dev/null)"}
+		return
+		;;`)
+	for _, name := range valueFlags() {
+		pattern := "-" + name
+		if name == "output" {
+			pattern += " | --output"
+		}
+		fmt.Fprintf(w, "\t%s)\n\t\tcompadd -- %s\n\t\treturn\n\t\t;;\n", pattern, strings.Join(flagValues[name], " "))
+	}
+	fmt.Fprintln(w, "\tesac")
+	fmt.Fprintln(w, "\tcase $cmd in")
+	items := make([]string, 0, len(cmds)+2)
+	for _, c := range cmds {
+		items = append(items, zshItem(c.name, c.summary))
+	}
+	items = append(items, zshItem("--dry-run", "show what would be updated without sending anything"), zshItem("--output", "format of the result"))
+	fmt.Fprintf(w, "\t\"\") items=(%s) ;;\n", strings.Join(items, " "))
+	for _, c := range cmds {
+		if len(c.actions) > 0 {
+			fmt.Fprintf(w, "\t%s)\n\t\tif ((CURRENT == pos + 1)); then items=(%s); else items=(%s); fi\n\t\t;;\n",
+				c.name, strings.Join(c.actions, " "), zshItems(c.flags))
+			continue
+		}
+		fmt.Fprintf(w, "\t%s) items=(%s) ;;\n", c.name, zshItems(c.flags))
+	}
+	fmt.Fprintln(w, "\tesac")
+	fmt.Fprintln(w, `	_describe ddns items
+}
+if [[ $funcstack[1] == _ddns ]]; then
+	_ddns "$@"
+else
+	compdef _ddns ddns
+fi`)
+}
+
+// fishQuote single-quotes s for fish
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
+
+func writeFish(w io.Writer, cmds []completionCommand) {
+	fmt.Fprintln(w, `# fish completion for ddns, generated by "ddns completion fish"
+function __ddns_profiles
+	set -l args (commandline -opc)
+	set -l i (contains -i -- -config $args); or return
+	set i (math $i + 1)
+	test $i -le (count $args); and ddns completion profiles $args[$i] 2>/dev/null
+end
+complete -c ddns -f
+complete -c ddns -n __fish_use_subcommand -l dry-run -d 'show what would be updated without sending anything'
+complete -c ddns -n __fish_use_subcommand -l output -x -a 'text json' -d 'format of the result'`)
+	for _, c := range cmds {
+		fmt.Fprintf(w, "complete -c ddns -n __fish_use_subcommand -a %s -d %s\n", c.name, fishQuote(c.summary))
+	}
+	files := make(map[string]bool)
+	for _, name := range fileFlags {
+		files[name] = true
+	}
+	for _, c := range cmds {
+		seen := "__fish_seen_subcommand_from " + c.name
+		if len(c.actions) > 0 {
+			fmt.Fprintf(w, "complete -c ddns -n '%s; and not __fish_seen_subcommand_from %s' -a %s\n",
+				seen, strings.Join(c.actions, " "), fishQuote(strings.Join(c.actions, " ")))
+		}
+		for _, fl := range c.flags {
+			var opts string
+			switch {
+			case fl.name == "profile":
+				opts = " -x -a '(__ddns_profiles)'"
+			case flagValues[fl.name] != nil:
+				opts = " -x -a " + fishQuote(strings.Join(flagValues[fl.name], " "))
+			case files[fl.name]:
+				opts = " -r -F"
+			case fl.takesValue:
+				opts = " -x"
+			}
+			fmt.Fprintf(w, "complete -c ddns -n %s -o %s%s -d %s\n", fishQuote(seen), fl.name, opts, fishQuote(fl.usage))
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/justenwalker/ddns/config"
+)
+
+func runConfig(args []string, stdout, stderr io.Writer) int {
+	var path string
+	var credentials bool
+	var format string
+	fs := newFlagSet("config", stderr)
+	fs.StringVar(&path, "config", "", "YAML, TOML or JSON configuration file (required)")
+	fs.BoolVar(&credentials, "check-credentials", false, "also check the credentials and hostnames of every provider with a request that changes no record")
+	registerOutput(fs, &format)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: ddns config validate [flags]")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Checks the configuration file and builds its providers and address sources without updating anything,")
+		fmt.Fprintln(stderr, "reporting every problem found with its line.")
+		fmt.Fprintln(stderr)
+		fs.PrintDefaults()
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, outputUsage)
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, envUsage)
+	}
+	if len(args) == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	action := args[0]
+	if err := fs.Parse(args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if err := applyEnv(fs, os.Getenv); err != nil {
+		fmt.Fprintf(stderr, "ddns: %v\n", err)
+		return exitUsage
+	}
+	if action != "validate" {
+		fmt.Fprintf(stderr, "ddns: unknown config command %q\n", action)
+		return exitUsage
+	}
+	if path == "" || fs.NArg() != 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	if !checkOutput(format, stderr) {
+		return exitUsage
+	}
+	checks := []func(*config.Config) config.Problems{buildProblems}
+	if credentials {
+		checks = append(checks, credentialProblems)
+	}
+	c, problems, err := config.CheckFile(path, checks...)
+	if err != nil {
+		return fail(format, err, exitFailure, stdout, stderr)
+	}
+	if format == "json" {
+		v := validation{Path: path, OK: len(problems) == 0, Warnings: findings(nil), Problems: findings(problems)}
+		if c != nil {
+			v.Warnings = findings(c.Warnings)
+		}
+		writeJSON(stdout, v)
+		if len(problems) > 0 {
+			return exitFailure
+		}
+		return exitOK
+	}
+	if c != nil {
+		for _, w := range c.Warnings {
+			fmt.Fprintf(stdout, "%s: warning: %v\n", position(path, w), w.Err)
+		}
+	}
+	for _, p := range problems {
+		fmt.Fprintf(stdout, "%s: %v\n", position(path, p), p.Err)
+	}
+	if len(problems) > 0 {
+		fmt.Fprintf(stderr, "ddns: %d problem(s) in %s\n", len(problems), path)
+		return exitFailure
+	}
+	fmt.Fprintf(stdout, "%s: ok\n", path)
+	return exitOK
+}
+
+// validation is the JSON output of ddns config validate
+type validation struct {
+	Path     string    `json:"path"`
+	OK       bool      `json:"ok"`
+	Warnings []finding `json:"warnings"`
+	Problems []finding `json:"problems"`
+}
+
+// finding is a problem or warning of ddns config validate
+type finding struct {
+	Line    int    `json:"line,omitempty"`
+	Key     string `json:"key,omitempty"`
+	Message string `json:"message"`
+}
+
+func findings(ps config.Problems) []finding {
+	out := []finding{}
+	for _, p := range ps {
+		out = append(out, finding{Line: p.Line, Key: p.Key, Message: p.Err.Error()})
+	}
+	return out
+}
+
+// buildProblems builds the address sources and the updater of every provider account, as the update
+// and daemon commands would. Undefined providers are reported by config.Check.
+func buildProblems(c *config.Config) config.Problems {
+	var ps config.Problems
+	eachProfile(c, func(c *config.Config, key func(string) string) {
+		if _, err := configSource(c, nil); err != nil {
+			ps = append(ps, config.Problem{Key: key("sources"), Err: err})
+		}
+		for _, t := range c.Targets() {
+			for _, name := range []string{t.Provider, t.Policy.Backup} {
+				if account, ok := c.Providers[name]; !ok || account.Type == "" {
+					continue
+				}
+				if _, err := configUpdater(c, config.Target{Provider: name, Group: t.Group, Hostnames: t.Hostnames}, debugLogs{}); err != nil {
+					ps = append(ps, config.Problem{Key: key("providers." + name), Err: err})
+				}
+			}
+		}
+	})
+	return dedupe(ps)
+}
+
+// credentialProblems asks the provider of every target to accept its hostnames without changing their addresses
+func credentialProblems(c *config.Config) config.Problems {
+	var ps config.Problems
+	eachProfile(c, func(c *config.Config, key func(string) string) {
+		for _, t := range c.Targets() {
+			if account, ok := c.Providers[t.Provider]; !ok || account.Type != "dynu" {
+				continue
+			}
+			clients, err := configDynu(c, t, debugLogs{})
+			if err != nil {
+				continue // reported by buildProblems
+			}
+			for _, client := range clients {
+				if err := client.VerifyContext(context.Background()); err != nil {
+					ps = append(ps, config.Problem{
+						Key: key("providers." + t.Provider),
+						Err: fmt.Errorf("provider %q rejected group %q: %v", t.Provider, t.Group, err),
+					})
+				}
+			}
+		}
+	})
+	return ps
+}
+
+// eachProfile calls fn with the configuration and then each of its profiles, except empty ones, which are
+// reported by config.Check. key returns the key of a setting of the configuration passed to fn:
+// those of a profile are under "profiles.<name>", unless they are inherited from the top level.
+func eachProfile(c *config.Config, fn func(c *config.Config, key func(string) string)) {
+	fn(c, func(key string) string { return key })
+	for _, name := range c.ProfileNames() {
+		pc, err := c.Profile(name)
+		if err != nil {
+			continue
+		}
+		own := c.Profiles[name]
+		fn(pc, func(key string) string {
+			if provider, ok := strings.CutPrefix(key, "providers."); ok {
+				if _, defined := own.Providers[provider]; !defined {
+					return key
+				}
+			}
+			return "profiles." + name + "." + key
+		})
+	}
+}
+
+// position returns where the problem is in the file at path, as "path:line", or path if its line is unknown
+func position(path string, p config.Problem) string {
+	if p.Line > 0 {
+		return fmt.Sprintf("%s:%d", path, p.Line)
+	}
+	return path
+}
+
+func dedupe(ps config.Problems) config.Problems {
+	seen := make(map[string]bool)
+	var out config.Problems
+	for _, p := range ps {
+		if !seen[p.Err.Error()] {
+			seen[p.Err.Error()] = true
+			out = append(out, p)
+		}
+	}
+	return out
+}
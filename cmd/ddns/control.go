@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/justenwalker/ddns/control"
+)
+
+func runControl(args []string, stdout, stderr io.Writer) int {
+	var socket, format string
+	fs := newFlagSet("control", stderr)
+	fs.StringVar(&socket, "control-socket", "", "socket of the daemon, set by ddns daemon -control-socket (required)")
+	registerOutput(fs, &format)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: ddns control update [flags]")
+		fmt.Fprintln(stderr, "       ddns control reload [flags]")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Sends a command to the running daemon over its control socket:")
+		fmt.Fprintln(stderr, "  update  detect the address and republish it to every provider now, even if it is unchanged")
+		fmt.Fprintln(stderr, "  reload  read the -config file again and restart the daemon with it, unless it is invalid")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Use ddns status -control-socket to show the status of the daemon.")
+		fmt.Fprintln(stderr)
+		fs.PrintDefaults()
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, outputUsage)
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, envUsage)
+	}
+	if len(args) == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	action := args[0]
+	if err := fs.Parse(args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	if err := applyEnv(fs, os.Getenv); err != nil {
+		fmt.Fprintf(stderr, "ddns: %v\n", err)
+		return exitUsage
+	}
+	if !checkOutput(format, stderr) {
+		return exitUsage
+	}
+	if socket == "" {
+		fmt.Fprintln(stderr, "ddns: -control-socket (or DDNS_CONTROL_SOCKET) is required")
+		return exitUsage
+	}
+	if !checkControlSocket(stderr) {
+		return exitUsage
+	}
+	c := control.NewClient(socket)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var err error
+	switch action {
+	case "update":
+		err = c.Update(ctx)
+	case "reload":
+		err = c.Reload(ctx)
+	default:
+		fmt.Fprintf(stderr, "ddns: unknown control command %q\n", action)
+		return exitUsage
+	}
+	if err != nil {
+		return fail(format, err, exitFailure, stdout, stderr)
+	}
+	if format == "json" {
+		writeJSON(stdout, struct {
+			Command string `json:"command"`
+		}{action})
+		return exitOK
+	}
+	fmt.Fprintf(stdout, "Sent %s to the daemon\n", action)
+	return exitOK
+}
+
+// checkControlSocket returns true if the control socket is supported on this platform, and reports it otherwise
+func checkControlSocket(stderr io.Writer) bool {
+	if !control.Supported {
+		fmt.Fprintln(stderr, "ddns: -control-socket is only supported on unix platforms")
+	}
+	return control.Supported
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/justenwalker/ddns/config"
+	"github.com/justenwalker/ddns/dynu"
+)
+
+// credentialChecks is the JSON output of ddns check-credentials: the outcome for each provider account
+type credentialChecks struct {
+	Providers []credentialCheck `json:"providers"`
+}
+
+type credentialCheck struct {
+	// Name is the name of the provider account, prefixed with "profile:" if it is defined by a profile
+	Name string `json:"name"`
+	OK   bool   `json:"ok"`
+	// Skipped is true if no group uses the account, so its credentials could not be checked
+	Skipped bool     `json:"skipped,omitempty"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+func runCheckCredentials(args []string, stdout, stderr io.Writer) int {
+	var path, format string
+	var timeout time.Duration
+	fs := newFlagSet("check-credentials", stderr)
+	fs.StringVar(&path, "config", "", "YAML, TOML or JSON configuration file (required)")
+	fs.DurationVar(&timeout, "timeout", 30*time.Second, "time limit for checking the API key of each provider")
+	registerOutput(fs, &format)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: ddns check-credentials -config FILE [flags]")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Asks every provider account to accept the hostnames of its groups, and its api_key if it has one,")
+		fmt.Fprintln(stderr, "with requests that change no record, and reports which accounts pass, such as after rotating passwords.")
+		fmt.Fprintln(stderr, "Exits with 1 if any account fails.")
+		fmt.Fprintln(stderr)
+		fs.PrintDefaults()
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, outputUsage)
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, envUsage)
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if err := applyEnv(fs, os.Getenv); err != nil {
+		fmt.Fprintf(stderr, "ddns: %v\n", err)
+		return exitUsage
+	}
+	if path == "" || fs.NArg() != 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	if !checkOutput(format, stderr) {
+		return exitUsage
+	}
+	c, err := config.Load(path)
+	if err != nil {
+		return fail(format, err, exitUsage, stdout, stderr)
+	}
+	out := credentialChecks{Providers: checkCredentials(c, timeout)}
+	code := exitOK
+	for _, r := range out.Providers {
+		if !r.OK {
+			code = exitFailure
+		}
+	}
+	if format == "json" {
+		writeJSON(stdout, out)
+		return code
+	}
+	for _, r := range out.Providers {
+		switch {
+		case r.Skipped:
+			fmt.Fprintf(stdout, "%s: skipped, not used by any group\n", r.Name)
+		case r.OK:
+			fmt.Fprintf(stdout, "%s: ok\n", r.Name)
+		default:
+			fmt.Fprintf(stdout, "%s: failed\n", r.Name)
+			for _, e := range r.Errors {
+				fmt.Fprintf(stdout, "  %s\n", strings.ReplaceAll(e, "\n", "\n  "))
+			}
+		}
+	}
+	return code
+}
+
+// checkCredentials checks the accounts of the configuration and of its profiles, sorted by name
+func checkCredentials(c *config.Config, timeout time.Duration) []credentialCheck {
+	checks := make(map[string]*credentialCheck)
+	used := make(map[string]bool)
+	check := func(c *config.Config, prefix string, own map[string]config.Provider) {
+		name := func(provider string) string {
+			if _, ok := own[provider]; ok {
+				return prefix + provider
+			}
+			return provider
+		}
+		for _, t := range c.Targets() {
+			targets := []config.Target{t}
+			if b := t.Policy.Backup; b != "" {
+				bt := config.Target{Provider: b, Group: t.Group, Hostnames: t.Hostnames, Policy: t.Policy}
+				if len(t.Policy.BackupHostnames) > 0 {
+					bt.Hostnames = t.Policy.BackupHostnames
+				}
+				targets = append(targets, bt)
+			}
+			for _, t := range targets {
+				r := credentialResult(checks, name(t.Provider))
+				used[r.Name] = true
+				for _, err := range verifyTarget(c, t) {
+					r.Errors = append(r.Errors, err.Error())
+				}
+			}
+		}
+		// the accounts inherited by a profile are checked with the top level
+		for provider, account := range own {
+			r := credentialResult(checks, name(provider))
+			if err := verifyKey(account, timeout); err != nil {
+				r.Errors = append(r.Errors, fmt.Sprintf("api_key: %v", err))
+			}
+		}
+	}
+	check(c, "", c.Providers)
+	for _, name := range c.ProfileNames() {
+		pc, err := c.Profile(name)
+		if err != nil {
+			continue
+		}
+		check(pc, name+":", c.Profiles[name].Providers)
+	}
+	out := make([]credentialCheck, 0, len(checks))
+	for _, r := range checks {
+		r.Errors = dedupeStrings(r.Errors)
+		r.OK = len(r.Errors) == 0
+		r.Skipped = r.OK && !used[r.Name]
+		out = append(out, *r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func credentialResult(checks map[string]*credentialCheck, name string) *credentialCheck {
+	r, ok := checks[name]
+	if !ok {
+		r = &credentialCheck{Name: name}
+		checks[name] = r
+	}
+	return r
+}
+
+// verifyTarget asks the provider of t to accept its hostnames with each account they are updated with,
+// without changing their addresses
+func verifyTarget(c *config.Config, t config.Target) []error {
+	account := c.Providers[t.Provider]
+	if account.Type != "dynu" {
+		return []error{fmt.Errorf("unsupported type %q", account.Type)}
+	}
+	clients, err := configDynu(c, t, debugLogs{})
+	if err != nil {
+		return []error{err}
+	}
+	routes := account.Route(t.Hostnames)
+	var errs []error
+	for i, client := range clients {
+		if err := client.VerifyContext(context.Background()); err != nil {
+			errs = append(errs, fmt.Errorf("account %q rejected group %q: %v", routes[i].Provider.Username, t.Group, err))
+		}
+	}
+	return errs
+}
+
+// verifyKey lists the hosts of the account with its API key, if it has one
+func verifyKey(account config.Provider, timeout time.Duration) error {
+	key, err := account.Key()
+	if err != nil || key == "" || account.Type != "dynu" {
+		return err
+	}
+	opts := []dynu.Option{dynu.APIKey(key)}
+	if account.Endpoint != "" {
+		opts = append(opts, dynu.Endpoint(account.Endpoint))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	_, err = dynu.New(account.Username, "", opts...).ListHosts(ctx)
+	return err
+}
+
+func dedupeStrings(ss []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, s := range ss {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
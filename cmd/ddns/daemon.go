@@ -0,0 +1,701 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/justenwalker/ddns/budget"
+	"github.com/justenwalker/ddns/config"
+	"github.com/justenwalker/ddns/control"
+	"github.com/justenwalker/ddns/daemon"
+	"github.com/justenwalker/ddns/event"
+	"github.com/justenwalker/ddns/event/jsonlsink"
+	"github.com/justenwalker/ddns/health"
+	"github.com/justenwalker/ddns/internal/pidlock"
+	"github.com/justenwalker/ddns/metrics"
+	"github.com/justenwalker/ddns/netwatch"
+	"github.com/justenwalker/ddns/ping"
+	"github.com/justenwalker/ddns/power"
+	"github.com/justenwalker/ddns/service"
+	"github.com/justenwalker/ddns/startup"
+	"github.com/justenwalker/ddns/state"
+	"github.com/justenwalker/ddns/trace"
+	"github.com/justenwalker/ddns/verify"
+)
+
+func runDaemon(args []string, stdout, stderr io.Writer) int {
+	if len(args) > 0 && (args[0] == "-chaos" || args[0] == "--chaos") {
+		// hidden soak test mode, left out of the usage
+		return runChaos(args[1:], stdout, stderr)
+	}
+	for {
+		var reload bool
+		code := serveDaemon(args, stdout, stderr, &reload)
+		if !reload {
+			return code
+		}
+	}
+}
+
+// serveDaemon runs the daemons until they are stopped, setting reload if they were stopped by the reload command
+// of the control socket, to be started again with the configuration read again
+func serveDaemon(args []string, stdout, stderr io.Writer, reload *bool) int {
+	var f updateFlags
+	var flags daemonSchedule
+	var statePath string
+	var serviceName string
+	var budgetCalls int
+	var budgetBytes int64
+	var budgetPeriod time.Duration
+	var budgetAlways bool
+	var termux bool
+	var metricsAddr string
+	var statsdAddr, statsdPrefix string
+	var dogstatsd bool
+	var traceEndpoint string
+	var healthAddr string
+	var healthGrace time.Duration
+	var healthFailures int
+	var pingURL, pingFailURL string
+	var controlSocket string
+	var statusPath string
+	var eventLog string
+	var eventLogSize int64
+	var eventLogAge time.Duration
+	var eventLogKeep int
+	var shutdownTimeout time.Duration
+	var waitNetwork, waitTimeSync time.Duration
+	var force bool
+	var rollback verify.Policy
+	fs := newFlagSet("daemon", stderr)
+	f.register(fs)
+	f.registerDryRun(fs)
+	f.registerAuditLog(fs)
+	f.registerPIDFile(fs, "lock this file, holding the process ID, while running; exits if another daemon or update locks it")
+	fs.DurationVar(&flags.interval, "interval", 5*time.Minute, "how often to detect the address (overrides the configured schedule)")
+	fs.DurationVar(&flags.jitter, "jitter", 0, "delay each detection by a random duration of up to this long, so devices sharing a configuration do not poll at the same instant")
+	fs.StringVar(&flags.cron, "cron", "", `cron expressions to detect at instead of every -interval, separated by ";", such as "*/5 8-19 * * *; 0 20-23,0-7 * * *"`)
+	fs.StringVar(&flags.timezone, "timezone", "", "IANA time zone -cron is evaluated in (default local time)")
+	fs.DurationVar(&flags.backoffMin, "backoff", 30*time.Second, "delay before retrying after a failure; doubles with each consecutive failure")
+	fs.DurationVar(&flags.backoffMax, "backoff-max", 30*time.Minute, "maximum delay between retries")
+	fs.Float64Var(&flags.stretch, "power-stretch", 1, "multiply the interval by this factor while on battery or a metered network")
+	fs.BoolVar(&flags.watch, "watch", false, "detect the address as soon as local addresses or routes change, then polling every 30m unless an interval is set")
+	fs.DurationVar(&flags.settle, "watch-settle", 2*time.Second, "with -watch, detect once the changes have stopped for this long, so a burst of them causes a single update")
+	fs.StringVar(&serviceName, "service", "", "name of the service the daemon runs as; set by ddns service install")
+	fs.IntVar(&budgetCalls, "budget-calls", 0, "maximum requests per -budget-period on metered networks (default unlimited)")
+	fs.Int64Var(&budgetBytes, "budget-bytes", 0, "maximum request and response bytes per -budget-period on metered networks (default unlimited)")
+	fs.DurationVar(&budgetPeriod, "budget-period", 24*time.Hour, "how long a traffic budget lasts before it resets")
+	fs.BoolVar(&budgetAlways, "budget-always", false, "enforce the traffic budget on every network, not only metered ones")
+	fs.BoolVar(&termux, "termux", isTermux(), "Android mode: HTTP and STUN sources only, no -watch, -state in the home directory and suspend-aware waits (default true in Termux)")
+	fs.StringVar(&metricsAddr, "metrics", "", "serve Prometheus metrics at /metrics on this address, such as :9110")
+	fs.StringVar(&statsdAddr, "statsd", "", "send counters and timings of the detections and updates to the StatsD agent at this UDP address, such as localhost:8125")
+	fs.StringVar(&statsdPrefix, "statsd-prefix", "ddns", "prefix of the -statsd metric names")
+	fs.BoolVar(&dogstatsd, "dogstatsd", false, "with -statsd, tag the metrics with the provider and the result as DogStatsD agents such as Datadog's expect")
+	fs.StringVar(&healthAddr, "health", "", "serve /healthz and /readyz for Kubernetes probes on this address, such as :8080; it may be the -metrics address")
+	fs.DurationVar(&healthGrace, "health-grace", overdueAfter, "how late the daemon may be for its next step before /healthz fails")
+	fs.IntVar(&healthFailures, "health-max-failures", 1, "how many consecutive times a provider may fail before /readyz and -ping fail (0 ignores failures)")
+	fs.StringVar(&pingURL, "ping", "", "request this URL of a dead man's switch such as Healthchecks.io or Cronitor after every step, so it alerts if the daemon stops")
+	fs.StringVar(&pingFailURL, "ping-fail", "", "with -ping, post why the daemon is failing to this URL instead, while detection or a provider fails (default the -ping URL followed by /fail)")
+	fs.StringVar(&controlSocket, "control-socket", "", "serve the status of the daemon on this Unix socket, such as /run/ddns/ddns.sock, for ddns status, and accept the update and reload commands of ddns control; unix platforms only")
+	fs.StringVar(&statusPath, "status-file", "", "after every step, atomically replace this file with the status of the daemon in the JSON format of ddns status -output json, for dashboards and scripts")
+	fs.StringVar(&traceEndpoint, "trace", "", "export OpenTelemetry traces of the updates to the OTLP/HTTP collector at this URL, such as http://localhost:4318 (default $OTEL_EXPORTER_OTLP_TRACES_ENDPOINT or $OTEL_EXPORTER_OTLP_ENDPOINT)")
+	fs.StringVar(&eventLog, "event-log", "", "append every event to this file as JSON Lines, for log shippers such as Promtail or Filebeat")
+	fs.Int64Var(&eventLogSize, "event-log-max-size", 10<<20, "rotate the -event-log file before it grows beyond this many bytes (0 disables it)")
+	fs.DurationVar(&eventLogAge, "event-log-max-age", 0, "also rotate the -event-log file every period of this duration, such as 24h for each UTC day")
+	fs.IntVar(&eventLogKeep, "event-log-keep", 5, "number of rotated -event-log files to keep (0 keeps all of them)")
+	fs.DurationVar(&shutdownTimeout, "shutdown-timeout", 10*time.Second, "on SIGTERM, how long to let an update in progress finish and the events flush, ending with the final status of each provider; a second signal exits at once")
+	fs.DurationVar(&waitNetwork, "wait-network", 0, "before the first update, wait up to this long for a default route, such as 2m on routers that start services before the WAN is up")
+	fs.DurationVar(&waitTimeSync, "wait-time-sync", 0, "before the first update, wait up to this long for the clock to be synchronized by NTP, such as 2m on boards without a real-time clock")
+	fs.BoolVar(&force, "force", false, "republish every record at startup even if -state says it is up to date, such as after it was changed outside of ddns")
+	fs.BoolVar(&rollback.Rollback, "rollback", false, "restore the previous addresses of a provider when the new ones fail -verify-port, instead of failing the update")
+	fs.IntVar(&rollback.Threshold, "rollback-after", 1, "with -rollback, how many consecutive times the new addresses must fail -verify-port, checked at each step, before rolling back")
+	fs.DurationVar(&rollback.Blackout, "rollback-blackout", time.Hour, "with -rollback, how long to wait before publishing rolled back addresses again")
+	fs.StringVar(&statePath, "state", "", "file to persist published addresses in, so restarts do not update every record again")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: ddns daemon [flags]")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Detects the public address every -interval and publishes it to the provider when it changes.")
+		fmt.Fprintln(stderr, "Each profile of the -config file runs on its own schedule, unless -profile selects one.")
+		fmt.Fprintln(stderr, "The -wait-network and -wait-time-sync timeouts delay the first update during early boot;")
+		fmt.Fprintln(stderr, "once they elapse, the daemon starts anyway.")
+		fmt.Fprintln(stderr)
+		fs.PrintDefaults()
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, dryRunUsage)
+		fmt.Fprintln(stderr, "The daemon runs a single step from the -state file, which is left unchanged.")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, outputUsage)
+		fmt.Fprintln(stderr, "Without -dry-run, the daemon has no result and -output json logs as -log-format json does.")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, envUsage)
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if err := applyEnv(fs, os.Getenv); err != nil {
+		fmt.Fprintf(stderr, "ddns: %v\n", err)
+		return exitUsage
+	}
+	if !f.validate(stderr) {
+		return exitUsage
+	}
+	if f.output == "json" && !f.dryRun {
+		// a running daemon has no result: its output is the log
+		f.logFormat, f.output = "json", "text"
+	}
+	if f.source == "hook" {
+		fmt.Fprintln(stderr, "ddns: -source hook is only supported by the update command")
+		return exitUsage
+	}
+	if controlSocket != "" && !checkControlSocket(stderr) {
+		return exitUsage
+	}
+	if rollback.Rollback {
+		if len(f.ports) == 0 || f.canary != "" {
+			fmt.Fprintln(stderr, "ddns: -rollback requires -verify-port and cannot be used with -canary")
+			return exitUsage
+		}
+		// the daemon verifies the addresses once published, rather than the updater
+		f.rollback = true
+	}
+	if f.pidFile != "" && !f.dryRun {
+		// before opening the log file, which another instance may be rotating
+		lock, err := pidlock.Acquire(f.pidFile)
+		if err != nil {
+			fmt.Fprintf(stderr, "ddns: %v\n", err)
+			return exitFailure
+		}
+		defer lock.Release()
+	}
+	if err := f.openLogFile(fs); err != nil {
+		fmt.Fprintf(stderr, "ddns: %v\n", err)
+		return exitFailure
+	}
+	defer f.closeLogFile()
+	l := f.newLogger(stdout, stderr)
+	asService := false
+	if serviceName != "" {
+		var err error
+		if asService, err = service.IsService(); err != nil {
+			fmt.Fprintf(stderr, "ddns: %v\n", err)
+			return exitFailure
+		}
+	}
+	if asService && f.logOut == nil && f.sysOut == nil {
+		el, err := service.OpenEventLog(serviceName)
+		if err != nil {
+			fmt.Fprintf(stderr, "ddns: %v\n", err)
+			return exitFailure
+		}
+		defer el.Close()
+		l = el
+	}
+	debug := f.debugLogs(l)
+	plans, err := f.plans(nil, debug, stdout, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "ddns: %v\n", err)
+		return exitUsage
+	}
+	defer closePlans(plans)
+
+	// the configured schedule applies unless overridden on the command line
+	set := make(map[string]bool)
+	fs.Visit(func(fl *flag.Flag) { set[fl.Name] = true })
+	schedules := make([]daemonSchedule, len(plans))
+	for i, p := range plans {
+		schedules[i] = flags.configured(p.schedule, set)
+	}
+	// the budget is the same in every plan: profiles share that of the top level
+	if cb := plans[0].schedule.Budget; cb.Calls > 0 || cb.Bytes > 0 {
+		if !set["budget-calls"] {
+			budgetCalls = cb.Calls
+		}
+		if !set["budget-bytes"] {
+			budgetBytes = cb.Bytes
+		}
+		if d := time.Duration(cb.Period); d > 0 && !set["budget-period"] {
+			budgetPeriod = d
+		}
+		if cb.Always && !set["budget-always"] {
+			budgetAlways = true
+		}
+	}
+
+	if termux {
+		for _, p := range plans {
+			for _, t := range p.sourceTypes {
+				if !termuxSources[t] {
+					fmt.Fprintf(stderr, "ddns: %ssource %q is not supported in Termux mode; use ipify, stun or an http(s) URL\n", p.label(), t)
+					return exitUsage
+				}
+			}
+		}
+		for i := range schedules {
+			if schedules[i].watch {
+				l.Log("ddns: %signoring -watch in Termux mode: Android does not let apps monitor routes", plans[i].label())
+				schedules[i].watch = false
+			}
+		}
+		if statePath == "" {
+			if statePath, err = termuxStatePath(); err != nil {
+				fmt.Fprintf(stderr, "ddns: %v\n", err)
+				return exitFailure
+			}
+		}
+	}
+
+	if f.dryRun {
+		return dryRunDaemon(plans, statePath, force, f.timeout, f.output, debug.scheduler, stdout, stderr)
+	}
+
+	bus := event.NewBus(event.Log(l))
+	for _, p := range plans {
+		for _, s := range p.sinks {
+			bus.Attach(s)
+		}
+		if s := p.hookSink(); s != nil {
+			bus.Attach(s)
+		}
+		if s := p.aliasSink(l); s != nil {
+			bus.Attach(s)
+		}
+	}
+	if eventLog != "" {
+		sink, err := jsonlsink.Open(eventLog, jsonlsink.MaxSize(eventLogSize), jsonlsink.MaxAge(eventLogAge), jsonlsink.Keep(eventLogKeep))
+		if err != nil {
+			fmt.Fprintf(stderr, "ddns: %v\n", err)
+			return exitFailure
+		}
+		// closed after the bus has delivered the last events
+		defer sink.Close()
+		bus.Attach(sink)
+	}
+	// the metrics and health endpoints share a server if they have the same address
+	muxes := make(map[string]*http.ServeMux)
+	serveMux := func(addr string) *http.ServeMux {
+		if muxes[addr] == nil {
+			muxes[addr] = http.NewServeMux()
+		}
+		return muxes[addr]
+	}
+	if err := f.openAuditLog(); err != nil {
+		fmt.Fprintf(stderr, "ddns: %v\n", err)
+		return exitFailure
+	}
+	if f.audit != nil {
+		// closed after the bus has delivered the last events
+		defer f.closeAuditLog()
+		bus.Attach(f.audit)
+	}
+	if metricsAddr != "" {
+		pm := metrics.NewProviders()
+		bus.Attach(pm)
+		serveMux(metricsAddr).Handle("/metrics", metrics.Handler(pm))
+	}
+	if statsdAddr != "" {
+		sopts := []metrics.StatsDOption{metrics.StatsDPrefix(statsdPrefix)}
+		if dogstatsd {
+			sopts = append(sopts, metrics.DogStatsD())
+		}
+		sd, err := metrics.DialStatsD(statsdAddr, sopts...)
+		if err != nil {
+			fmt.Fprintf(stderr, "ddns: %v\n", err)
+			return exitFailure
+		}
+		// closed after the bus has delivered the last events
+		defer sd.Close()
+		bus.Attach(sd)
+	}
+	var monitor *health.Monitor
+	if healthAddr != "" {
+		monitor = health.New(health.Grace(healthGrace), health.MaxFailures(healthFailures))
+		monitor.Register(serveMux(healthAddr))
+	}
+	var pinger *ping.Pinger
+	if pingURL != "" {
+		for _, u := range []string{pingURL, pingFailURL} {
+			if pu, err := url.Parse(u); u != "" && (err != nil || (pu.Scheme != "http" && pu.Scheme != "https")) {
+				fmt.Fprintf(stderr, "ddns: -ping: %q is not an http or https URL\n", u)
+				return exitUsage
+			}
+		}
+		popts := []ping.Option{ping.Log(l), ping.MaxFailures(healthFailures)}
+		if pingFailURL != "" {
+			popts = append(popts, ping.FailURL(pingFailURL))
+		}
+		pinger = ping.New(pingURL, popts...)
+	}
+	// reloading is canceled by the reload command, which stops the daemons to start them again
+	reloading, requestReload := context.WithCancel(context.Background())
+	defer requestReload()
+	var ctl *control.Server
+	if controlSocket != "" {
+		// the control socket is unix-only, so the daemon never runs as a Windows service here, whose service
+		// control manager only starts it once per process
+		ctl = control.New(control.Log(l), control.Reload(func() error {
+			if f.config != "" {
+				if _, err := config.Load(f.config); err != nil {
+					return err
+				}
+			}
+			requestReload()
+			return nil
+		}))
+		srv, err := ctl.Listen(controlSocket)
+		if err != nil {
+			fmt.Fprintf(stderr, "ddns: %v\n", err)
+			return exitFailure
+		}
+		defer srv.Close()
+	}
+	var sf *statusFile
+	if statusPath != "" {
+		sf = newStatusFile(statusPath, l)
+	}
+	for addr, mux := range muxes {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			fmt.Fprintf(stderr, "ddns: %v\n", err)
+			return exitFailure
+		}
+		srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go srv.Serve(ln)
+		defer srv.Close()
+	}
+	var b *budget.Budget
+	if budgetCalls > 0 || budgetBytes > 0 {
+		bopts := []budget.Option{
+			budget.Log(l),
+			budget.Calls(budgetCalls),
+			budget.Bytes(budgetBytes),
+			budget.Period(budgetPeriod),
+		}
+		if !budgetAlways {
+			bopts = append(bopts, budget.Metered(power.System()))
+		}
+		b = budget.New(bopts...)
+		defer b.Stop()
+		// sources and providers use http.DefaultClient unless they are bound to an interface or address
+		prev := http.DefaultClient.Transport
+		http.DefaultClient.Transport = b.Transport(prev, budget.Essential)
+		defer func() { http.DefaultClient.Transport = prev }()
+		f.budget = b
+	}
+	tracer, err := newTracer(traceEndpoint, os.Getenv, l)
+	if err != nil {
+		fmt.Fprintf(stderr, "ddns: -trace: %v\n", err)
+		return exitUsage
+	}
+	if tracer != nil {
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			defer cancel()
+			tracer.Close(ctx)
+		}()
+		// outside the budget transport, so the requests it refuses are recorded
+		prev := http.DefaultClient.Transport
+		http.DefaultClient.Transport = trace.Transport(prev)
+		defer func() { http.DefaultClient.Transport = prev }()
+	}
+	var wakes []<-chan struct{}
+	for _, s := range schedules {
+		if s.watch && wakes == nil {
+			w, err := netwatch.New(netwatch.Log(debug.scheduler))
+			if err != nil {
+				fmt.Fprintf(stderr, "ddns: %v\n", err)
+				return exitFailure
+			}
+			defer w.Close()
+			wakes = wakeOn(w.Events(), len(schedules), s.settle)
+		}
+	}
+	// the daemons of several plans save through their own view of the state file
+	var shared *state.Shared
+	if statePath != "" && len(plans) > 1 {
+		shared = state.NewShared(state.File{Path: statePath})
+	}
+	daemons := make([]*daemon.Daemon, len(plans))
+	for i, p := range plans {
+		s := schedules[i]
+		providers := make([]daemon.Provider, len(p.providers))
+		for j, provider := range p.providers {
+			updater := provider.Updater
+			provider.Updater = daemon.UpdaterFunc(func(ctx context.Context, ips []net.IP) error {
+				ctx, cancel := context.WithTimeout(ctx, f.timeout)
+				defer cancel()
+				return updater.UpdateIP(ctx, ips)
+			})
+			providers[j] = provider
+		}
+		opts := []daemon.Option{
+			daemon.Log(l),
+			daemon.Interval(s.poll()),
+			daemon.Backoff(s.backoffMin, s.backoffMax),
+			daemon.Events(bus),
+			daemon.Grace(shutdownTimeout),
+		}
+		if s.cron != "" {
+			cs, err := config.Schedule{Cron: []string{s.cron}, Timezone: s.timezone}.CronSchedule()
+			if err != nil {
+				fmt.Fprintf(stderr, "ddns: %s-cron: %v\n", p.label(), err)
+				return exitUsage
+			}
+			opts = append(opts, daemon.Schedule(cs))
+		}
+		var store state.Store
+		switch {
+		case shared != nil:
+			store = shared.View(p.profile)
+		case statePath != "":
+			store = state.File{Path: statePath}
+		}
+		if monitor != nil {
+			store = monitor.Store(store)
+		}
+		if store != nil {
+			opts = append(opts, daemon.Persist(store))
+		}
+		if s.jitter > 0 {
+			opts = append(opts, daemon.Jitter(s.jitter))
+		}
+		if s.stretch > 1 {
+			opts = append(opts, daemon.PowerAware(power.System(), s.stretch))
+		}
+		if debug.scheduler != nil {
+			opts = append(opts, daemon.Debug(debug.scheduler))
+		}
+		if force {
+			opts = append(opts, daemon.Force())
+		}
+		if b != nil {
+			opts = append(opts, daemon.Budget(b))
+		}
+		if tracer != nil {
+			opts = append(opts, daemon.Trace(tracer))
+		}
+		if pinger != nil {
+			opts = append(opts, daemon.AfterStep(pinger.Step()))
+		}
+		if ctl != nil {
+			opts = append(opts, daemon.AfterStep(ctl.Step()), daemon.Trigger(ctl.Trigger()))
+		}
+		if sf != nil {
+			opts = append(opts, daemon.AfterStep(sf.Step()))
+		}
+		if termux {
+			opts = append(opts, daemon.WallClock(time.Minute))
+		}
+		if s.watch {
+			opts = append(opts, daemon.Wake(wakes[i]))
+		}
+		if rollback.Rollback {
+			opts = append(opts, daemon.Verify(f.verify, rollback))
+		}
+		source := p.source
+		if p.hooks != nil {
+			source = p.hooks.Source(source)
+		}
+		daemons[i] = daemon.New(source, providers, opts...)
+	}
+
+	// startup gates, in order: the clock is synchronized over the network
+	gates := []struct {
+		check   startup.Check
+		timeout time.Duration
+	}{
+		{startup.Network(), waitNetwork},
+		{startup.TimeSync(), waitTimeSync},
+	}
+	run := func(ctx context.Context) error {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		context.AfterFunc(reloading, cancel)
+		for _, g := range gates {
+			if g.timeout <= 0 {
+				continue
+			}
+			// a context done while waiting stops the daemons as soon as they run
+			if err := startup.Wait(ctx, g.check, g.timeout, startup.Log(l)); errors.Is(err, startup.ErrTimeout) {
+				l.Log("ddns: %v; starting anyway", err)
+			}
+		}
+		for i, p := range plans {
+			if s := schedules[i]; s.cron != "" {
+				l.Log("ddns: %supdating %d provider(s) at %q", p.label(), len(p.providers), s.cron)
+			} else {
+				l.Log("ddns: %supdating %d provider(s) every %v", p.label(), len(p.providers), s.poll())
+			}
+		}
+		// the shutdown timeout covers both the last step and flushing the events
+		stopped := make(chan time.Time, 1)
+		context.AfterFunc(ctx, func() { stopped <- time.Now() })
+		err := runAll(ctx, daemons)
+		deadline := time.Now().Add(shutdownTimeout)
+		select {
+		case at := <-stopped:
+			deadline = at.Add(shutdownTimeout)
+		default:
+		}
+		closeCtx, cancel := context.WithDeadline(context.Background(), deadline)
+		defer cancel()
+		bus.Close(closeCtx)
+		if err != nil && ctx.Err() == nil {
+			l.Log("ddns: %v", err)
+			return err
+		}
+		if reloading.Err() != nil {
+			*reload = true
+			l.Log("ddns: stopped, reloading")
+			return nil
+		}
+		l.Log("ddns: stopped")
+		return nil
+	}
+	if asService {
+		err = service.Run(serviceName, run)
+	} else {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		go func() {
+			// restore the default handling, so that a second signal exits at once
+			<-ctx.Done()
+			stop()
+		}()
+		err = run(ctx)
+	}
+	if err != nil {
+		return exitFailure
+	}
+	return exitOK
+}
+
+// daemonSchedule is the schedule of the daemon of one plan
+type daemonSchedule struct {
+	interval, jitter, backoffMin, backoffMax time.Duration
+	stretch                                  float64
+	watch                                    bool
+	cron, timezone                           string
+	// settle is how long the address changes must stop for before the daemon is woken
+	settle time.Duration
+	// intervalSet is true if the interval was set on the command line or in the configuration
+	intervalSet bool
+}
+
+// watchInterval is the interval of a daemon woken by address changes, when none is set: polling is then only
+// a safety net for the changes the host cannot see, such as those of an upstream router
+const watchInterval = 30 * time.Minute
+
+// poll returns the interval the daemon polls at
+func (s daemonSchedule) poll() time.Duration {
+	if s.watch && !s.intervalSet {
+		return watchInterval
+	}
+	return s.interval
+}
+
+// configured returns the schedule with the settings of c that were not set on the command line
+func (s daemonSchedule) configured(c config.Schedule, set map[string]bool) daemonSchedule {
+	if d := time.Duration(c.Interval); d > 0 && !set["interval"] {
+		s.interval = d
+	}
+	s.intervalSet = set["interval"] || c.Interval > 0
+	if d := time.Duration(c.Jitter); d > 0 && !set["jitter"] {
+		s.jitter = d
+	}
+	if d := time.Duration(c.Backoff); d > 0 && !set["backoff"] {
+		s.backoffMin = d
+	}
+	if d := time.Duration(c.BackoffMax); d > 0 && !set["backoff-max"] {
+		s.backoffMax = d
+	}
+	if c.PowerStretch > 0 && !set["power-stretch"] {
+		s.stretch = c.PowerStretch
+	}
+	if c.Watch && !set["watch"] {
+		s.watch = true
+	}
+	if d := time.Duration(c.WatchSettle); d > 0 && !set["watch-settle"] {
+		s.settle = d
+	}
+	if len(c.Cron) > 0 && !set["cron"] {
+		s.cron = strings.Join(c.Cron, ";")
+	}
+	if c.Timezone != "" && !set["timezone"] {
+		s.timezone = c.Timezone
+	}
+	return s
+}
+
+// runAll runs the daemons until ctx is done, or one of them fails, which stops the others.
+// It returns the error of the daemon that failed, or ctx.Err().
+func runAll(ctx context.Context, daemons []*daemon.Daemon) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errs := make(chan error, len(daemons))
+	for _, d := range daemons {
+		go func(d *daemon.Daemon) {
+			errs <- d.Run(ctx)
+		}(d)
+	}
+	var first error
+	for range daemons {
+		if err := <-errs; first == nil {
+			first = err
+			cancel()
+		}
+	}
+	return first
+}
+
+// wakeOn converts address change events into wake-ups for n daemons, once no event has been received for settle.
+// Bursts of events, such as an interface coming up with several addresses, collapse into a single wake-up.
+func wakeOn(events <-chan netwatch.Event, n int, settle time.Duration) []<-chan struct{} {
+	wakes := make([]chan struct{}, n)
+	out := make([]<-chan struct{}, n)
+	for i := range wakes {
+		wakes[i] = make(chan struct{}, 1)
+		out[i] = wakes[i]
+	}
+	wakeAll := func() {
+		for _, wake := range wakes {
+			select {
+			case wake <- struct{}{}:
+			default:
+			}
+		}
+	}
+	go func() {
+		var settled <-chan time.Time
+		for {
+			select {
+			case _, ok := <-events:
+				if !ok {
+					return
+				}
+				if settle <= 0 {
+					wakeAll()
+					continue
+				}
+				settled = time.After(settle)
+			case <-settled:
+				settled = nil
+				wakeAll()
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/justenwalker/ddns/daemon"
+	"github.com/justenwalker/ddns/state"
+)
+
+// dryRunUsage documents -dry-run, which every command that publishes addresses accepts
+const dryRunUsage = `With -dry-run, or "ddns --dry-run <command>", the address is detected but nothing is sent to the providers:
+the providers and hostnames that would be updated are shown instead.`
+
+// dryRunDaemon runs a single step of the daemon of each plan from the saved state, showing which providers it
+// would update and with which addresses, in the -output format. The state is not saved.
+func dryRunDaemon(plans []*plan, statePath string, force bool, timeout time.Duration, format string, l Logger, stdout, stderr io.Writer) int {
+	saved := state.New()
+	if statePath != "" {
+		var err error
+		if saved, err = (state.File{Path: statePath}).Load(); err != nil {
+			return fail(format, err, exitFailure, stdout, stderr)
+		}
+	}
+	text := stdout
+	if format == "json" {
+		text = io.Discard
+	}
+	code := exitOK
+	out := results{Results: []planResult{}}
+	for _, p := range plans {
+		var updated []string
+		providers := make([]daemon.Provider, len(p.providers))
+		for i, provider := range p.providers {
+			provider.Updater = dryRunUpdater(provider.Name, provider.Hostnames, &updated, text)
+			if b := provider.Backup; b != nil {
+				backup := *b
+				backup.Updater = dryRunUpdater(backup.Name, backup.Hostnames, &updated, text)
+				provider.Backup = &backup
+			}
+			providers[i] = provider
+		}
+		var opts []daemon.Option
+		if l != nil {
+			opts = append(opts, daemon.Log(l))
+		}
+		if force {
+			opts = append(opts, daemon.Force())
+		}
+		d := daemon.New(p.source, providers, opts...)
+		d.Restore(saved)
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		d.Step(ctx)
+		cancel()
+		s := d.Snapshot()
+		r := newPlanResult(p)
+		if s.DetectedAt.IsZero() {
+			fmt.Fprintf(stderr, "ddns: %sdetecting address failed\n", p.label())
+			r.Error = "detecting address failed"
+			out.Results = append(out.Results, r)
+			code = exitFailure
+			continue
+		}
+		r.Detected = s.Detected
+		fmt.Fprintf(text, "%sdetected %s\n", p.label(), joinIPs(s.Detected))
+		for _, provider := range providers {
+			candidates := []daemon.Provider{provider}
+			if provider.Backup != nil {
+				candidates = append(candidates, *provider.Backup)
+			}
+			for _, c := range candidates {
+				pr := providerResult{Name: c.Name, Hostnames: c.Hostnames, Status: "would-not-update"}
+				if contains(updated, c.Name) {
+					pr.Status, pr.IPs = "would-update", s.Detected
+				} else if c.Name == provider.Name {
+					fmt.Fprintf(text, "would not update %s (%s)\n", strings.Join(provider.Hostnames, ", "), provider.Name)
+				}
+				r.Providers = append(r.Providers, pr)
+			}
+		}
+		out.Results = append(out.Results, r)
+	}
+	if format == "json" {
+		if err := writeJSON(stdout, out); err != nil {
+			fmt.Fprintf(stderr, "ddns: %v\n", err)
+			return exitFailure
+		}
+	}
+	return code
+}
+
+// dryRunUpdater shows what would be published to the named provider instead of publishing it
+func dryRunUpdater(name string, hostnames []string, updated *[]string, stdout io.Writer) daemon.Updater {
+	return daemon.UpdaterFunc(func(ctx context.Context, ips []net.IP) error {
+		*updated = append(*updated, name)
+		fmt.Fprintf(stdout, "would update %s (%s) to %s\n", strings.Join(hostnames, ", "), name, joinIPs(ips))
+		return nil
+	})
+}
+
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// envPrefix is prepended to the environment variable of every flag
+const envPrefix = "DDNS_"
+
+// listFlag is implemented by repeatable flags.
+// Their environment variable is the plural of the flag name and holds a comma-separated list.
+type listFlag interface {
+	flag.Value
+	list()
+}
+
+func (s *stringList) list() {}
+func (s *intList) list()    {}
+
+// envName returns the environment variable for a flag, e.g. DDNS_BACKOFF_MAX for -backoff-max
+// and DDNS_HOSTNAMES for the repeatable -hostname
+func envName(f *flag.Flag) string {
+	name := strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+	if _, ok := f.Value.(listFlag); ok {
+		name += "S"
+	}
+	return envPrefix + name
+}
+
+// applyEnv sets the flags that were not given on the command line from their environment variables,
+// so the commands can be configured entirely from the environment in containers.
+// The variable with a _FILE suffix, such as DDNS_PASSWORD_FILE, names a file to read the value from instead,
+// for secrets mounted by Docker or Kubernetes.
+func applyEnv(fs *flag.FlagSet, getenv func(string) string) error {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if set[f.Name] || err != nil {
+			return
+		}
+		name := envName(f)
+		v := getenv(name)
+		if file := getenv(name + "_FILE"); file != "" {
+			if v != "" {
+				err = fmt.Errorf("%s and %s_FILE are both set", name, name)
+				return
+			}
+			data, e := os.ReadFile(file)
+			if e != nil {
+				err = fmt.Errorf("%s_FILE: %v", name, e)
+				return
+			}
+			v = strings.TrimRight(string(data), "\r\n")
+		}
+		if v == "" {
+			return
+		}
+		values := []string{v}
+		if _, ok := f.Value.(listFlag); ok {
+			values = strings.Split(v, ",")
+		}
+		for _, value := range values {
+			if value = strings.TrimSpace(value); value == "" {
+				continue
+			}
+			if e := fs.Set(f.Name, value); e != nil {
+				err = fmt.Errorf("invalid value %q for %s: %v", value, name, e)
+				return
+			}
+		}
+	})
+	return err
+}
+
+// envUsage describes how flags map to environment variables
+const envUsage = `Every flag can also be set with an environment variable, such as DDNS_BACKOFF_MAX for -backoff-max.
+Repeatable flags take a comma-separated list, such as DDNS_HOSTNAMES for -hostname.
+Append _FILE to read the value from a file instead, such as DDNS_PASSWORD_FILE for a Docker or Kubernetes secret.
+Flags given on the command line take precedence.
+Failures are explained in the language of DDNS_LANG or the locale (English or German).`
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestApplyEnv(t *testing.T) {
+	var f updateFlags
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	f.register(fs)
+	env := map[string]string{
+		"DDNS_USERNAME":     "user",
+		"DDNS_PROVIDER":     "other",
+		"DDNS_HOSTNAMES":    "a.example.com, b.example.com",
+		"DDNS_VERIFY_PORTS": "443,22",
+		"DDNS_IPV6":         "true",
+		"DDNS_TIMEOUT":      "5s",
+	}
+	if err := fs.Parse([]string{"-provider", "dynu"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyEnv(fs, func(k string) string { return env[k] }); err != nil {
+		t.Fatal(err)
+	}
+	if f.username != "user" || !f.ipv6 || f.timeout != 5*time.Second {
+		t.Errorf("environment not applied: %+v", f)
+	}
+	if f.provider != "dynu" {
+		t.Errorf("expected the command line to take precedence, got provider %q", f.provider)
+	}
+	if !reflect.DeepEqual([]string(f.hostnames), []string{"a.example.com", "b.example.com"}) {
+		t.Errorf("unexpected hostnames %v", f.hostnames)
+	}
+	if !reflect.DeepEqual([]int(f.ports), []int{443, 22}) {
+		t.Errorf("unexpected ports %v", f.ports)
+	}
+
+	env = map[string]string{"DDNS_TIMEOUT": "soon"}
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	f.register(fs)
+	fs.Parse(nil)
+	if err := applyEnv(fs, func(k string) string { return env[k] }); err == nil {
+		t.Error("expected an error for an invalid value")
+	}
+
+	secret := filepath.Join(t.TempDir(), "password")
+	os.WriteFile(secret, []byte("s3cret\n"), 0o600)
+	env = map[string]string{"DDNS_PASSWORD_FILE": secret}
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	f.register(fs)
+	fs.Parse(nil)
+	if err := applyEnv(fs, func(k string) string { return env[k] }); err != nil || f.password != "s3cret" {
+		t.Errorf("expected the password to be read from the file, got %q, %v", f.password, err)
+	}
+	env["DDNS_PASSWORD"] = "other"
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	f.register(fs)
+	fs.Parse(nil)
+	if err := applyEnv(fs, func(k string) string { return env[k] }); err == nil {
+		t.Error("expected an error when both the variable and its file are set")
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+
+	"github.com/justenwalker/ddns/dynu"
+)
+
+// explanation tells people what went wrong in plain language, and what to try next.
+// It is shown below the error itself, which stays as precise as the package that returned it.
+type explanation struct {
+	what, next string
+}
+
+// explanations are the messages by language, then by kind of failure: a dynu response code,
+// or one of the network failures returned by failureKinds
+var explanations = map[string]map[string]explanation{
+	"en": {
+		"badauth":     {"The provider rejected the username or password, or a hostname that does not belong to the account.", "Check the credentials of the provider account; ddns config validate -check-credentials tests them without changing any record."},
+		"nohost":      {"The provider does not know this hostname or username.", "Check the spelling of the hostname, and that it exists in the provider account."},
+		"notfqdn":     {"The hostname is not a fully qualified domain name.", "Use the complete name, such as home.example.com."},
+		"numhost":     {"Too many hostnames were sent in one request.", "Split the hostnames into groups of at most 20."},
+		"abuse":       {"The provider blocked updates from this account for sending too many.", "Wait before trying again, and make sure only one instance of ddns updates these hostnames."},
+		"911":         {"The provider is under maintenance.", "Nothing needs fixing here: try again in 10 minutes. The daemon waits and retries on its own."},
+		"servererror": {"The provider had a temporary problem.", "Nothing needs fixing here: try again in a few minutes. The daemon retries on its own."},
+		"!donator":    {"This feature is only available to paying members of the provider.", "Upgrade the provider account, or turn off the option that needs it."},
+		"unknown":     {"The provider did not understand the request.", "Check the endpoint and options of the provider account."},
+		"dns":         {"The name of a server could not be resolved.", "Check the network connection and the DNS settings of this machine."},
+		"timeout":     {"A server did not answer in time.", "Check the network connection, or raise -timeout on slow links."},
+		"refused":     {"A server refused the connection.", "Check the endpoint or URL, and that no firewall blocks the connection."},
+		"unreachable": {"The network or the server is unreachable.", "Check that this machine is online, and with IPv6 enabled, that it has IPv6 connectivity."},
+		"tls":         {"The certificate of a server could not be verified.", "Check that the clock of this machine is right and that CA certificates are installed (in Termux: pkg install ca-certificates)."},
+	},
+	"de": {
+		"badauth":     {"Der Anbieter hat den Benutzernamen oder das Passwort abgelehnt, oder einen Hostnamen, der nicht zum Konto gehört.", "Prüfen Sie die Zugangsdaten des Anbieterkontos; ddns config validate -check-credentials testet sie, ohne einen Eintrag zu ändern."},
+		"nohost":      {"Der Anbieter kennt diesen Hostnamen oder Benutzernamen nicht.", "Prüfen Sie die Schreibweise des Hostnamens und ob er im Anbieterkonto existiert."},
+		"notfqdn":     {"Der Hostname ist kein vollständig qualifizierter Domainname.", "Verwenden Sie den vollständigen Namen, etwa home.example.com."},
+		"numhost":     {"In einer Anfrage wurden zu viele Hostnamen gesendet.", "Teilen Sie die Hostnamen in Gruppen von höchstens 20 auf."},
+		"abuse":       {"Der Anbieter hat Aktualisierungen dieses Kontos wegen zu vieler Anfragen gesperrt.", "Warten Sie, bevor Sie es erneut versuchen, und stellen Sie sicher, dass nur eine Instanz von ddns diese Hostnamen aktualisiert."},
+		"911":         {"Der Anbieter wird gerade gewartet.", "Hier ist nichts zu beheben: Versuchen Sie es in 10 Minuten erneut. Der Daemon wartet und wiederholt es selbst."},
+		"servererror": {"Der Anbieter hatte ein vorübergehendes Problem.", "Hier ist nichts zu beheben: Versuchen Sie es in einigen Minuten erneut. Der Daemon wiederholt es selbst."},
+		"!donator":    {"Diese Funktion steht nur zahlenden Mitgliedern des Anbieters zur Verfügung.", "Erweitern Sie das Anbieterkonto oder schalten Sie die Option ab, die sie benötigt."},
+		"unknown":     {"Der Anbieter hat die Anfrage nicht verstanden.", "Prüfen Sie den Endpunkt und die Optionen des Anbieterkontos."},
+		"dns":         {"Der Name eines Servers konnte nicht aufgelöst werden.", "Prüfen Sie die Netzwerkverbindung und die DNS-Einstellungen dieses Rechners."},
+		"timeout":     {"Ein Server hat nicht rechtzeitig geantwortet.", "Prüfen Sie die Netzwerkverbindung oder erhöhen Sie -timeout bei langsamen Verbindungen."},
+		"refused":     {"Ein Server hat die Verbindung abgelehnt.", "Prüfen Sie den Endpunkt oder die URL und ob eine Firewall die Verbindung blockiert."},
+		"unreachable": {"Das Netzwerk oder der Server ist nicht erreichbar.", "Prüfen Sie, ob dieser Rechner online ist, und bei aktiviertem IPv6, ob er IPv6-Konnektivität hat."},
+		"tls":         {"Das Zertifikat eines Servers konnte nicht überprüft werden.", "Prüfen Sie, ob die Uhr dieses Rechners stimmt und CA-Zertifikate installiert sind (in Termux: pkg install ca-certificates)."},
+	},
+}
+
+// language returns the language of the explanations: that of DDNS_LANG, or of the POSIX locale variables in
+// their order of precedence, if there are explanations in it, and English otherwise
+func language(getenv func(string) string) string {
+	for _, name := range []string{"DDNS_LANG", "LC_ALL", "LC_MESSAGES", "LANG"} {
+		v := getenv(name)
+		if v == "" {
+			continue
+		}
+		// such as "de_DE.UTF-8" or "de"
+		lang := strings.ToLower(strings.FieldsFunc(v, func(r rune) bool { return r == '_' || r == '.' || r == '@' || r == '-' })[0])
+		if _, ok := explanations[lang]; ok {
+			return lang
+		}
+		return "en"
+	}
+	return "en"
+}
+
+// failureKinds returns the kinds of failure of err that have explanations, without duplicates
+func failureKinds(err error) []string {
+	var kinds []string
+	add := func(kind string) {
+		for _, k := range kinds {
+			if k == kind {
+				return
+			}
+		}
+		kinds = append(kinds, kind)
+	}
+	var responseErrs dynu.ResponseErrors
+	var responseErr dynu.Error
+	var dnsErr *net.DNSError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &responseErrs):
+		for _, e := range responseErrs {
+			add(responseKind(e.Code))
+		}
+	case errors.As(err, &responseErr):
+		add(responseKind(responseErr.Code))
+	case errors.As(err, &dnsErr) && !dnsErr.IsTimeout:
+		add("dns")
+	case errors.As(err, &authorityErr), errors.As(err, &hostnameErr), errors.As(err, &invalidErr):
+		add("tls")
+	case errors.Is(err, syscall.ECONNREFUSED):
+		add("refused")
+	case errors.Is(err, syscall.ENETUNREACH), errors.Is(err, syscall.EHOSTUNREACH):
+		add("unreachable")
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		add("timeout")
+	}
+	return kinds
+}
+
+// responseKind returns the kind of failure of a dynu response code; server errors share one explanation
+func responseKind(code dynu.ResponseCode) string {
+	if code == dynu.RespDNS {
+		return string(dynu.RespServerError)
+	}
+	return string(code)
+}
+
+// explain writes the explanations of err in the language of the environment to w, indented below the error
+func explain(w io.Writer, err error, getenv func(string) string) {
+	messages := explanations[language(getenv)]
+	for _, kind := range failureKinds(err) {
+		if e, ok := messages[kind]; ok {
+			fmt.Fprintf(w, "  %s\n  %s\n", e.what, e.next)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/justenwalker/ddns/dynu"
+)
+
+func TestExplain(t *testing.T) {
+	env := func(vars ...string) func(string) string {
+		return func(name string) string {
+			for i := 0; i < len(vars); i += 2 {
+				if vars[i] == name {
+					return vars[i+1]
+				}
+			}
+			return ""
+		}
+	}
+	for _, tc := range []struct {
+		env  func(string) string
+		lang string
+	}{
+		{env(), "en"},
+		{env("LANG", "de_DE.UTF-8"), "de"},
+		{env("LANG", "de_DE.UTF-8", "LC_ALL", "C"), "en"},
+		{env("LANG", "fr_FR.UTF-8"), "en"},
+		{env("DDNS_LANG", "de", "LC_ALL", "en_US.UTF-8"), "de"},
+	} {
+		if got := language(tc.env); got != tc.lang {
+			t.Errorf("expected language %q, got %q", tc.lang, got)
+		}
+	}
+
+	for _, tc := range []struct {
+		err   error
+		kinds []string
+	}{
+		{dynu.ResponseErrors{{Hostname: "a.example.com", Code: dynu.RespBadAuth}, {Hostname: "b.example.com", Code: dynu.RespBadAuth}}, []string{"badauth"}},
+		{fmt.Errorf("wrapped: %w", dynu.ResponseErrors{{Code: dynu.RespDNS}}), []string{"servererror"}},
+		{&net.DNSError{Err: "no such host", Name: "api.dynu.com", IsNotFound: true}, []string{"dns"}},
+		{&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, []string{"refused"}},
+		{fmt.Errorf("detect: %w", context.DeadlineExceeded), []string{"timeout"}},
+		{x509.UnknownAuthorityError{}, []string{"tls"}},
+		{fmt.Errorf("something else"), nil},
+	} {
+		if got := failureKinds(tc.err); fmt.Sprint(got) != fmt.Sprint(tc.kinds) {
+			t.Errorf("%v: expected kinds %v, got %v", tc.err, tc.kinds, got)
+		}
+	}
+
+	// every kind is explained in every language
+	for lang, messages := range explanations {
+		for kind := range explanations["en"] {
+			if _, ok := messages[kind]; !ok {
+				t.Errorf("%s: no explanation of %q", lang, kind)
+			}
+		}
+	}
+}
+
+func TestUpdateExplained(t *testing.T) {
+	t.Setenv("DDNS_LANG", "de")
+	detect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("203.0.113.7\n"))
+	}))
+	defer detect.Close()
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("badauth"))
+	}))
+	defer api.Close()
+	var stdout, stderr bytes.Buffer
+	run([]string{"update", "-username", "user", "-password", "pass", "-hostname", "foo.example.com",
+		"-source", detect.URL, "-endpoint", api.URL}, &stdout, &stderr)
+	want := "badauth\n  " + explanations["de"]["badauth"].what + "\n  " + explanations["de"]["badauth"].next + "\n"
+	if !strings.HasSuffix(stderr.String(), want) {
+		t.Errorf("expected the failure to be explained in German, got %q", stderr.String())
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/justenwalker/ddns/config"
+	"github.com/justenwalker/ddns/dynu"
+	"github.com/justenwalker/ddns/gc"
+)
+
+// collected is the JSON output of ddns gc: the outcome for each provider with an API key
+type collected struct {
+	Providers []collectedProvider `json:"providers"`
+}
+
+type collectedProvider struct {
+	Name    string     `json:"name"`
+	Claimed []string   `json:"claimed"`
+	Removed []gcRecord `json:"removed"`
+	Error   string     `json:"error,omitempty"`
+}
+
+type gcRecord struct {
+	Hostname string `json:"hostname"`
+	Type     string `json:"type"`
+	Value    string `json:"value,omitempty"`
+}
+
+func runGC(args []string, stdout, stderr io.Writer) int {
+	var path, owner, format string
+	var claim, dryRun bool
+	var timeout time.Duration
+	fs := newFlagSet("gc", stderr)
+	fs.StringVar(&path, "config", "", "YAML, TOML or JSON configuration file (required)")
+	fs.StringVar(&owner, "owner", "ddns", "name of this installation in the ownership markers, for accounts shared by several configurations")
+	fs.BoolVar(&claim, "claim", false, "mark the existing records of the configured hostnames as owned, so they are removed once they are no longer configured")
+	fs.BoolVar(&dryRun, "dry-run", false, "show what would be claimed and removed without changing anything")
+	fs.DurationVar(&timeout, "timeout", 2*time.Minute, "time limit for collecting the records of every provider")
+	registerOutput(fs, &format)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: ddns gc -config FILE [flags]")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Removes the A and AAAA records that ddns owns but whose hostname is no longer in the configuration,")
+		fmt.Fprintln(stderr, "at the provider accounts with an api_key, including the accounts of a dynu provider. A record is owned")
+		fmt.Fprintln(stderr, `once a TXT record at its name holds the marker of the -owner, such as "heritage=ddns,owner=ddns", as`)
+		fmt.Fprintln(stderr, "added by -claim. Other records are never removed.")
+		fmt.Fprintln(stderr)
+		fs.PrintDefaults()
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, outputUsage)
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, envUsage)
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if err := applyEnv(fs, os.Getenv); err != nil {
+		fmt.Fprintf(stderr, "ddns: %v\n", err)
+		return exitUsage
+	}
+	if path == "" || owner == "" || fs.NArg() != 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	if !checkOutput(format, stderr) {
+		return exitUsage
+	}
+	c, err := config.Load(path)
+	if err != nil {
+		return fail(format, err, exitUsage, stdout, stderr)
+	}
+	keep, providers, err := gcScope(c)
+	if err != nil {
+		return fail(format, err, exitUsage, stdout, stderr)
+	}
+	text := stdout
+	if format == "json" {
+		text = io.Discard
+	}
+	verb := map[bool][2]string{false: {"claimed", "removed"}, true: {"would claim", "would remove"}}[dryRun]
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	out := collected{Providers: []collectedProvider{}}
+	code := exitOK
+	for _, name := range sortedNames(providers) {
+		p := providers[name]
+		key, err := p.Key()
+		if err == nil && key == "" {
+			if strings.Contains(name, "/accounts.") {
+				fmt.Fprintf(stderr, "ddns: provider %q: no api_key, the records of its zones are not collected\n", name)
+			}
+			continue
+		}
+		r := collectedProvider{Name: name, Claimed: []string{}, Removed: []gcRecord{}}
+		if err == nil {
+			err = gcProvider(ctx, p, key, func(z gc.Zone) error {
+				plan, err := gc.Collect(ctx, z, owner, func(hostname string) bool { return keep[hostname] }, claim)
+				if err != nil {
+					return err
+				}
+				for _, rec := range plan.Add {
+					fmt.Fprintf(text, "%s %s (%s)\n", verb[0], rec.Name, name)
+					r.Claimed = append(r.Claimed, rec.Name)
+				}
+				for _, rec := range plan.Remove {
+					fmt.Fprintf(text, "%s %v (%s)\n", verb[1], rec, name)
+					r.Removed = append(r.Removed, gcRecord{Hostname: rec.Name, Type: rec.Type, Value: rec.Value})
+				}
+				if dryRun {
+					return nil
+				}
+				return plan.Apply(ctx, z)
+			})
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "ddns: provider %q: %v\n", name, err)
+			r.Error = err.Error()
+			code = exitFailure
+		}
+		out.Providers = append(out.Providers, r)
+	}
+	if len(out.Providers) == 0 {
+		return fail(format, fmt.Errorf("no provider has an api_key to manage its records with"), exitUsage, stdout, stderr)
+	}
+	if format == "json" {
+		writeJSON(stdout, out)
+	}
+	return code
+}
+
+// gcScope returns the hostnames configured at the top level or in any profile, which are kept, and the
+// provider accounts of the configuration and its profiles, by name: that of the provider for its own account,
+// and name/accounts.N for each of its Accounts
+func gcScope(c *config.Config) (map[string]bool, map[string]config.Provider, error) {
+	keep := make(map[string]bool)
+	providers := make(map[string]config.Provider)
+	add := func(c *config.Config) {
+		for _, h := range c.Hosts() {
+			keep[gc.Hostname(h.Hostname)] = true
+			for _, b := range h.Policy.BackupHostnames {
+				keep[gc.Hostname(b)] = true
+			}
+		}
+		for name, p := range c.Providers {
+			for i, account := range p.Split() {
+				key := name
+				if i > 0 {
+					key = fmt.Sprintf("%s/accounts.%d", name, i-1)
+				}
+				if _, ok := providers[key]; !ok {
+					providers[key] = account
+				}
+			}
+		}
+	}
+	add(c)
+	for _, name := range c.ProfileNames() {
+		pc, err := c.Profile(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		add(pc)
+	}
+	return keep, providers, nil
+}
+
+func sortedNames(providers map[string]config.Provider) []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// gcProvider calls collect with each zone of the provider account
+func gcProvider(ctx context.Context, p config.Provider, key string, collect func(gc.Zone) error) error {
+	switch p.Type {
+	case "dynu":
+		opts := []dynu.Option{dynu.APIKey(key)}
+		if p.Endpoint != "" {
+			opts = append(opts, dynu.Endpoint(p.Endpoint))
+		}
+		client := dynu.New(p.Username, "", opts...)
+		hosts, err := client.ListHosts(ctx)
+		if err != nil {
+			return err
+		}
+		for _, h := range hosts {
+			if err := collect(dynuZone{client: client, host: h}); err != nil {
+				return fmt.Errorf("%s: %w", h.Name, err)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported type %q", p.Type)
+}
+
+// dynuZone is the zone of a dynu host: the records of its subdomains
+type dynuZone struct {
+	client *dynu.Client
+	host   dynu.Host
+}
+
+func (z dynuZone) Records(ctx context.Context) ([]gc.Record, error) {
+	records, err := z.client.ListRecords(ctx, z.host.ID)
+	if err != nil {
+		return nil, err
+	}
+	var out []gc.Record
+	for _, r := range records {
+		// the records of the host itself are updated, but not removed, with the host
+		if r.NodeName == "" {
+			continue
+		}
+		rec := gc.Record{ID: strconv.FormatInt(r.ID, 10), Name: r.Hostname, Type: r.RecordType}
+		switch r.RecordType {
+		case "A":
+			rec.Value = r.IPv4Address
+		case "AAAA":
+			rec.Value = r.IPv6Address
+		case "TXT":
+			rec.Value = r.TextData
+		}
+		out = append(out, rec)
+	}
+	return out, nil
+}
+
+func (z dynuZone) Add(ctx context.Context, r gc.Record) error {
+	_, err := z.client.AddRecord(ctx, z.host.ID, dynu.Record{
+		NodeName:   strings.TrimSuffix(r.Name, "."+z.host.Name),
+		RecordType: r.Type,
+		State:      true,
+		TextData:   r.Value,
+		TTL:        300,
+	})
+	return err
+}
+
+func (z dynuZone) Delete(ctx context.Context, r gc.Record) error {
+	id, err := strconv.ParseInt(r.ID, 10, 64)
+	if err != nil {
+		return err
+	}
+	return z.client.DeleteRecord(ctx, z.host.ID, id)
+}
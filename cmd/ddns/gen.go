@@ -0,0 +1,135 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/justenwalker/ddns/daemon"
+	"github.com/justenwalker/ddns/metrics"
+)
+
+func runGen(args []string, stdout, stderr io.Writer) int {
+	var path, profile, rules, dashboard, title string
+	var m metrics.Monitoring
+	fs := newFlagSet("gen", stderr)
+	fs.StringVar(&path, "config", "", "YAML, TOML or JSON configuration file of the daemon (required)")
+	fs.StringVar(&profile, "profile", "", "only monitor the providers of this configuration profile")
+	fs.StringVar(&rules, "rules", "ddns.rules.yml", `file to write the Prometheus alerting rules to, or "-" for stdout, or "" to skip them`)
+	fs.StringVar(&dashboard, "dashboard", "ddns-dashboard.json", `file to write the Grafana dashboard to, or "-" for stdout, or "" to skip it`)
+	fs.StringVar(&title, "title", "Dynamic DNS", "title of the Grafana dashboard")
+	fs.StringVar(&m.Job, "job", "ddns", `Prometheus job scraping the -metrics of the daemon, or "" to match every job`)
+	fs.IntVar(&m.Failures, "failures", 3, "alert when a provider fails this many updates in a row")
+	fs.DurationVar(&m.For, "for", 5*time.Minute, "how long a condition lasts before its alert fires")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: ddns gen monitoring -config FILE [flags]")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Writes Prometheus alerting rules and a Grafana dashboard with a row for each provider of the")
+		fmt.Fprintln(stderr, "configuration, for the metrics served by ddns daemon -metrics. Providers with a refresh also")
+		fmt.Fprintln(stderr, "alert when they have not been updated for twice as long.")
+		fmt.Fprintln(stderr)
+		fs.PrintDefaults()
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, envUsage)
+	}
+	if len(args) == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	kind := args[0]
+	if err := fs.Parse(args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if err := applyEnv(fs, os.Getenv); err != nil {
+		fmt.Fprintf(stderr, "ddns: %v\n", err)
+		return exitUsage
+	}
+	if kind != "monitoring" {
+		fmt.Fprintf(stderr, "ddns: unknown gen command %q\n", kind)
+		return exitUsage
+	}
+	if path == "" || fs.NArg() != 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	if rules == "-" && dashboard == "-" {
+		fmt.Fprintln(stderr, `ddns: only one of -rules and -dashboard can be "-"`)
+		return exitUsage
+	}
+	plans, err := loadPlans(path, profile, debugLogs{}, stdout, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "ddns: %v\n", err)
+		return exitUsage
+	}
+	defer closePlans(plans)
+	var targets []metrics.Target
+	for _, p := range plans {
+		for _, provider := range p.providers {
+			targets = append(targets, monitored(provider)...)
+		}
+	}
+	outputs := []struct {
+		path  string
+		write func(w io.Writer) error
+	}{
+		{rules, func(w io.Writer) error { return metrics.WriteRules(w, m, targets) }},
+		{dashboard, func(w io.Writer) error { return metrics.WriteDashboard(w, title, m, targets) }},
+	}
+	for _, out := range outputs {
+		if err := writeOutput(out.path, stdout, out.write); err != nil {
+			fmt.Fprintf(stderr, "ddns: %v\n", err)
+			return exitFailure
+		}
+		if out.path != "" && out.path != "-" && rules != "-" && dashboard != "-" {
+			fmt.Fprintf(stdout, "Wrote %s\n", out.path)
+		}
+	}
+	return exitOK
+}
+
+// monitored returns the names a daemon provider and its backup have in the metrics, with their refresh period
+func monitored(p daemon.Provider) []metrics.Target {
+	if !p.SplitFamilies {
+		targets := []metrics.Target{{Provider: p.Name, Refresh: p.Refresh}}
+		if p.Backup != nil {
+			targets = append(targets, metrics.Target{Provider: p.Backup.Name})
+		}
+		return targets
+	}
+	var targets []metrics.Target
+	for _, family := range []string{"ipv4", "ipv6"} {
+		refresh := p.Refresh
+		if fp := map[string]daemon.FamilyPolicy{"ipv4": p.IPv4, "ipv6": p.IPv6}[family]; fp.Refresh > 0 {
+			refresh = fp.Refresh
+		}
+		targets = append(targets, metrics.Target{Provider: p.Name + "/" + family, Refresh: refresh})
+		if p.Backup != nil {
+			targets = append(targets, metrics.Target{Provider: p.Backup.Name + "/" + family})
+		}
+	}
+	return targets
+}
+
+// writeOutput calls write with the file at path, stdout if path is "-", or not at all if path is empty
+func writeOutput(path string, stdout io.Writer, write func(w io.Writer) error) error {
+	switch path {
+	case "":
+		return nil
+	case "-":
+		return write(stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
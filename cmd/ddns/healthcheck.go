@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/justenwalker/ddns/state"
+)
+
+func runHealthcheck(args []string, stdout, stderr io.Writer) int {
+	var path string
+	var grace time.Duration
+	var maxFailures int
+	var format string
+	fs := newFlagSet("healthcheck", stderr)
+	fs.StringVar(&path, "state", "", "state file of the daemon (required outside Termux)")
+	fs.DurationVar(&grace, "grace", overdueAfter, "how late the daemon may be for its next run before it is unhealthy")
+	fs.IntVar(&maxFailures, "max-failures", 0, "also unhealthy once a provider has failed this many consecutive times (default never)")
+	registerOutput(fs, &format)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: ddns healthcheck [flags]")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Exits with 0 if the daemon is healthy and 1 otherwise, for a container HEALTHCHECK or liveness probe.")
+		fmt.Fprintln(stderr, "The daemon is healthy while it keeps to the schedule saved in its -state file.")
+		fmt.Fprintln(stderr)
+		fs.PrintDefaults()
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, outputUsage)
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, envUsage)
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	if err := applyEnv(fs, os.Getenv); err != nil {
+		fmt.Fprintf(stderr, "ddns: %v\n", err)
+		return exitUsage
+	}
+	if !checkOutput(format, stderr) {
+		return exitUsage
+	}
+	if path == "" && isTermux() {
+		var err error
+		if path, err = termuxStatePath(); err != nil {
+			return fail(format, err, exitFailure, stdout, stderr)
+		}
+	}
+	if path == "" {
+		fmt.Fprintln(stderr, "ddns: -state (or DDNS_STATE) is required")
+		return exitUsage
+	}
+	s, err := state.File{Path: path}.Load()
+	if err != nil {
+		return fail(format, err, exitFailure, stdout, stderr)
+	}
+	err = healthy(s, time.Now(), grace, maxFailures)
+	if format == "json" {
+		h := healthReport{Healthy: err == nil}
+		if err != nil {
+			h.Reason = err.Error()
+		}
+		writeJSON(stdout, h)
+	} else if err != nil {
+		fmt.Fprintf(stdout, "unhealthy: %v\n", err)
+	} else {
+		fmt.Fprintln(stdout, "healthy")
+	}
+	if err != nil {
+		return exitFailure
+	}
+	return exitOK
+}
+
+// healthReport is the JSON output of ddns healthcheck
+type healthReport struct {
+	Healthy bool   `json:"healthy"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// healthy returns why the daemon that saved s is unhealthy at now, or nil
+func healthy(s *state.Snapshot, now time.Time, grace time.Duration, maxFailures int) error {
+	if s.NextRun.IsZero() {
+		return fmt.Errorf("the daemon has not saved its schedule yet")
+	}
+	if late := now.Sub(s.NextRun); late > grace {
+		return fmt.Errorf("the daemon is %v late for its run at %s", late.Round(time.Second), formatTime(s.NextRun))
+	}
+	if maxFailures <= 0 {
+		return nil
+	}
+	names := make([]string, 0, len(s.Providers))
+	for name := range s.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if p := s.Providers[name]; p.Failures >= maxFailures {
+			return fmt.Errorf("%s failed %d consecutive times: %s", name, p.Failures, p.LastError)
+		}
+	}
+	return nil
+}
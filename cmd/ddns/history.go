@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/justenwalker/ddns/audit"
+	"github.com/justenwalker/ddns/config"
+)
+
+// history is the JSON output of ddns history
+type history struct {
+	Records []audit.Record `json:"records"`
+}
+
+func runHistory(args []string, stdout, stderr io.Writer) int {
+	var path, since, until, typ, format string
+	var q audit.Query
+	fs := newFlagSet("history", stderr)
+	fs.StringVar(&path, "audit-log", "", "audit log written by ddns update or daemon -audit-log (required)")
+	fs.StringVar(&since, "since", "", "only show the records since this time, as RFC 3339, a date such as 2026-01-02, or a duration ago such as 7d or 12h")
+	fs.StringVar(&until, "until", "", "only show the records before this time, in the same formats as -since")
+	fs.StringVar(&typ, "type", "", `only show the records of this type: "detected" address changes or "update" attempts`)
+	fs.StringVar(&q.Provider, "provider", "", "only show the updates of this provider")
+	fs.BoolVar(&q.Failures, "failures", false, "only show the updates that failed")
+	fs.IntVar(&q.Limit, "limit", 50, "show at most this many of the most recent records (0 shows all of them)")
+	registerOutput(fs, &format)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: ddns history [flags]")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Shows the changes of the detected address and the update attempts recorded in the audit log,")
+		fmt.Fprintln(stderr, "oldest first, such as to find out when the ISP assigned a new address.")
+		fmt.Fprintln(stderr)
+		fs.PrintDefaults()
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, outputUsage)
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, envUsage)
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	if err := applyEnv(fs, os.Getenv); err != nil {
+		fmt.Fprintf(stderr, "ddns: %v\n", err)
+		return exitUsage
+	}
+	if !checkOutput(format, stderr) {
+		return exitUsage
+	}
+	if path == "" {
+		fmt.Fprintln(stderr, "ddns: -audit-log (or DDNS_AUDIT_LOG) is required")
+		return exitUsage
+	}
+	switch typ {
+	case "", audit.Detected, audit.Update:
+		q.Type = typ
+	default:
+		fmt.Fprintf(stderr, "ddns: unknown -type %q, want detected or update\n", typ)
+		return exitUsage
+	}
+	now := time.Now()
+	for _, b := range []struct {
+		name, value string
+		t           *time.Time
+	}{{"since", since, &q.Since}, {"until", until, &q.Until}} {
+		if b.value == "" {
+			continue
+		}
+		t, err := parseSince(b.value, now)
+		if err != nil {
+			fmt.Fprintf(stderr, "ddns: -%s: %v\n", b.name, err)
+			return exitUsage
+		}
+		*b.t = t
+	}
+	records, err := audit.Read(path, q)
+	var corrupt *audit.CorruptError
+	if errors.As(err, &corrupt) {
+		fmt.Fprintf(stderr, "ddns: %v\n", err)
+	} else if err != nil {
+		return fail(format, err, exitFailure, stdout, stderr)
+	}
+	if format == "json" {
+		if records == nil {
+			records = []audit.Record{}
+		}
+		if err := writeJSON(stdout, history{Records: records}); err != nil {
+			fmt.Fprintf(stderr, "ddns: %v\n", err)
+			return exitFailure
+		}
+		return exitOK
+	}
+	printHistory(stdout, records)
+	return exitOK
+}
+
+// parseSince parses an RFC 3339 time, a date in the local time zone, or a duration before now such as 7d
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t, nil
+	}
+	var d config.Duration
+	if err := d.UnmarshalText([]byte(s)); err != nil || d < 0 {
+		return time.Time{}, fmt.Errorf("%q is not a time, a date or a duration", s)
+	}
+	return now.Add(-time.Duration(d)), nil
+}
+
+func printHistory(w io.Writer, records []audit.Record) {
+	if len(records) == 0 {
+		fmt.Fprintln(w, "No records: is ddns running with -audit-log?")
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tEVENT\tADDRESSES\tRESULT")
+	for _, r := range records {
+		change := ipsOrNone(r.OldIPs) + " -> " + ipsOrNone(r.NewIPs)
+		what := "detected"
+		if r.Type == audit.Update {
+			what = "updated " + r.Provider
+			if len(r.Hostnames) > 0 {
+				what += " (" + strings.Join(r.Hostnames, ", ") + ")"
+			}
+			if len(r.OldIPs) == 0 {
+				change = ipsOrNone(r.NewIPs)
+			}
+		}
+		result := r.Result
+		if r.Error != "" {
+			// keep each record on a line of the table
+			result += ": " + strings.Join(strings.Fields(r.Error), " ")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", formatTime(r.Time), what, change, result)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/justenwalker/ddns/config"
+	"github.com/justenwalker/ddns/dynu"
+)
+
+// importGroup is the group of the hosts without a location at the provider
+const importGroup = "imported"
+
+// imported is the JSON output of ddns import-hosts: the hostnames by group, and the address families they use
+type imported struct {
+	Groups   map[string][]string `json:"groups"`
+	Families []string            `json:"families"`
+}
+
+func runImportHosts(args []string, stdout, stderr io.Writer) int {
+	var apiKey, endpoint, name, username, passwordFile, format string
+	var records bool
+	var timeout time.Duration
+	fs := newFlagSet("import-hosts", stderr)
+	fs.StringVar(&apiKey, "api-key", "", "key of the provider's REST API, under API Credentials in the dynu.com control panel (required); prefer DDNS_API_KEY")
+	fs.StringVar(&endpoint, "endpoint", "", "override the provider API endpoint")
+	fs.BoolVar(&records, "records", false, "also import the A and AAAA records of the subdomains of each host")
+	fs.StringVar(&name, "name", "", "name of the provider account in the configuration (default the provider type)")
+	fs.StringVar(&username, "username", "", "username of the provider account to write in the configuration")
+	fs.StringVar(&passwordFile, "password-file", "", "file holding the IP update password to write in the configuration")
+	fs.DurationVar(&timeout, "timeout", 30*time.Second, "time limit for listing the hosts")
+	registerOutput(fs, &format)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: ddns import-hosts dynu [flags]")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Lists the hosts of the provider account and prints a configuration file updating all of them,")
+		fmt.Fprintln(stderr, "with a group for each location of the hosts, and the others in a group named imported. It lists the one")
+		fmt.Fprintln(stderr, "account the -api-key belongs to: run it once for each account of a provider. Save it with:")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "  ddns import-hosts dynu -username USER -password-file /etc/ddns/password > ddns.yaml")
+		fmt.Fprintln(stderr)
+		fs.PrintDefaults()
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, outputUsage)
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, envUsage)
+	}
+	if len(args) == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	provider := args[0]
+	if err := fs.Parse(args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if err := applyEnv(fs, os.Getenv); err != nil {
+		fmt.Fprintf(stderr, "ddns: %v\n", err)
+		return exitUsage
+	}
+	if !contains(initProviders, provider) {
+		fmt.Fprintf(stderr, "ddns: unsupported provider %q\n", provider)
+		return exitUsage
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	if !checkOutput(format, stderr) {
+		return exitUsage
+	}
+	if apiKey == "" {
+		fmt.Fprintln(stderr, "ddns: -api-key (or DDNS_API_KEY) is required")
+		return exitUsage
+	}
+	if name == "" {
+		name = provider
+	}
+
+	opts := []dynu.Option{dynu.APIKey(apiKey)}
+	if endpoint != "" {
+		opts = append(opts, dynu.Endpoint(endpoint))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	out, err := importDynu(ctx, dynu.New(username, "", opts...), records)
+	if err != nil {
+		return fail(format, err, exitFailure, stdout, stderr)
+	}
+	if format == "json" {
+		writeJSON(stdout, out)
+		return exitOK
+	}
+	text := out.yaml(name, provider, username, passwordFile)
+	if _, err := config.Decode(strings.NewReader(text), config.YAML); err != nil {
+		return fail(format, err, exitFailure, stdout, stderr)
+	}
+	io.WriteString(stdout, text)
+	return exitOK
+}
+
+// importDynu lists the hosts of a dynu account, and with records the enabled A and AAAA records of their subdomains
+func importDynu(ctx context.Context, client *dynu.Client, records bool) (imported, error) {
+	out := imported{Groups: make(map[string][]string)}
+	hosts, err := client.ListHosts(ctx)
+	if err != nil {
+		return out, err
+	}
+	seen := make(map[string]bool)
+	add := func(group, hostname string) {
+		hostname = strings.ToLower(hostname)
+		if !seen[hostname] {
+			seen[hostname] = true
+			out.Groups[group] = append(out.Groups[group], hostname)
+		}
+	}
+	var ipv4, ipv6 bool
+	for _, h := range hosts {
+		group := h.Group
+		if group == "" {
+			group = importGroup
+		}
+		add(group, h.Name)
+		ipv4, ipv6 = ipv4 || h.IPv4, ipv6 || h.IPv6
+		if !records {
+			continue
+		}
+		rs, err := client.ListRecords(ctx, h.ID)
+		if err != nil {
+			return out, fmt.Errorf("%s: %w", h.Name, err)
+		}
+		for _, r := range rs {
+			if r.State && r.NodeName != "" && (r.RecordType == "A" || r.RecordType == "AAAA") {
+				add(group, r.Hostname)
+			}
+		}
+	}
+	for _, hostnames := range out.Groups {
+		sort.Strings(hostnames)
+	}
+	if ipv4 || !ipv6 {
+		out.Families = append(out.Families, "ipv4")
+	}
+	if ipv6 {
+		out.Families = append(out.Families, "ipv6")
+	}
+	return out, nil
+}
+
+// yaml returns the configuration file updating the imported hostnames with the named provider account
+func (im imported) yaml(name, provider, username, passwordFile string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# written by ddns import-hosts %s; see contrib/ddns.example.yaml in the ddns sources for every setting\n", provider)
+	fmt.Fprintf(&b, "version: %d\n\n", config.CurrentVersion)
+	fmt.Fprintln(&b, "providers:")
+	fmt.Fprintf(&b, "  %s:\n", quote(name))
+	fmt.Fprintf(&b, "    type: %s\n", provider)
+	if username != "" {
+		fmt.Fprintf(&b, "    username: %s\n", quote(username))
+	} else {
+		fmt.Fprintln(&b, "    # username: the username of the account")
+	}
+	if passwordFile != "" {
+		fmt.Fprintf(&b, "    password_file: %s\n", quote(passwordFile))
+	} else {
+		fmt.Fprintln(&b, "    # password_file: a file holding the IP update password")
+	}
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "groups:")
+	groups := make([]string, 0, len(im.Groups))
+	for group := range im.Groups {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	if len(groups) == 0 {
+		fmt.Fprintln(&b, "  # the account has no hosts")
+	}
+	for _, group := range groups {
+		hostnames := make([]string, len(im.Groups[group]))
+		for i, h := range im.Groups[group] {
+			hostnames[i] = quote(h)
+		}
+		fmt.Fprintf(&b, "  %s:\n", quote(group))
+		fmt.Fprintf(&b, "    providers: [%s]\n", quote(name))
+		fmt.Fprintf(&b, "    hostnames: [%s]\n", strings.Join(hostnames, ", "))
+	}
+	fmt.Fprintln(&b)
+	fmt.Fprintf(&b, "families: [%s]\n", strings.Join(im.Families, ", "))
+	return b.String()
+}
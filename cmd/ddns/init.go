@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/justenwalker/ddns/config"
+)
+
+// stdin is read by the commands that ask questions; replaced by tests
+var stdin io.Reader = os.Stdin
+
+// initProviders are the provider types ddns init can set up
+var initProviders = []string{"dynu"}
+
+func runInit(args []string, stdout, stderr io.Writer) int {
+	var path, endpoint string
+	var force, noVerify bool
+	fs := newFlagSet("init", stderr)
+	fs.StringVar(&path, "config", "ddns.yaml", "configuration file to write")
+	fs.BoolVar(&force, "force", false, "replace an existing -config file")
+	fs.BoolVar(&noVerify, "no-verify", false, "write the file without asking the provider to check the credentials and hostnames")
+	fs.StringVar(&endpoint, "endpoint", "", "override the provider API endpoint")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: ddns init [flags]")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Asks for the provider, its credentials and the hostnames to update, checks them with the provider")
+		fmt.Fprintln(stderr, "without changing any record, and writes a configuration file for ddns update and ddns daemon.")
+		fmt.Fprintln(stderr)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	if _, err := os.Stat(path); err == nil && !force {
+		fmt.Fprintf(stderr, "ddns: %s already exists; use -force to replace it\n", path)
+		return exitFailure
+	}
+
+	q := questions{in: bufio.NewScanner(stdin), out: stdout}
+	var a answers
+	a.provider = q.choose("Provider", initProviders)
+	a.username = q.ask("Username", "", nonEmpty)
+	a.password = q.ask("Password (shown as typed; or @FILE to read it from FILE when ddns runs)", "", nonEmpty)
+	a.hostnames = strings.FieldsFunc(q.ask("Hostnames, separated by spaces or commas", "", nonEmpty), func(r rune) bool {
+		return r == ' ' || r == ','
+	})
+	a.ipv6 = q.confirm("Also publish the IPv6 address?", false)
+	a.endpoint = endpoint
+	if q.err != nil {
+		fmt.Fprintf(stderr, "ddns: %v\n", q.err)
+		return exitFailure
+	}
+
+	text := a.yaml()
+	c, err := config.Decode(strings.NewReader(text), config.YAML)
+	if err != nil {
+		fmt.Fprintf(stderr, "ddns: %v\n", err)
+		return exitFailure
+	}
+	if !noVerify {
+		fmt.Fprintf(stdout, "Checking the credentials and hostnames with %s...\n", a.provider)
+		if problems := credentialProblems(c); len(problems) > 0 {
+			for _, p := range problems {
+				fmt.Fprintf(stdout, "  %v\n", p.Err)
+				explain(stdout, p.Err, os.Getenv)
+			}
+			if !q.confirm("Write the configuration anyway?", false) || q.err != nil {
+				return exitFailure
+			}
+		} else {
+			fmt.Fprintln(stdout, "  ok")
+		}
+	}
+	mode := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if force {
+		mode = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	// the file holds the password
+	f, err := os.OpenFile(path, mode, 0o600)
+	if err == nil {
+		_, err = io.WriteString(f, text)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "ddns: %v\n", err)
+		return exitFailure
+	}
+	fmt.Fprintf(stdout, "Wrote %s. Publish the address once with:\n\n  ddns update -config %s\n\nor keep it up to date with:\n\n  ddns daemon -config %s\n", path, path, path)
+	return exitOK
+}
+
+// answers to the questions of ddns init
+type answers struct {
+	provider, username, password, endpoint string
+	hostnames                              []string
+	ipv6                                   bool
+}
+
+// yaml returns the configuration file of the answers
+func (a answers) yaml() string {
+	var b strings.Builder
+	fmt.Fprintln(&b, "# written by ddns init; see contrib/ddns.example.yaml in the ddns sources for every setting")
+	fmt.Fprintf(&b, "version: %d\n\n", config.CurrentVersion)
+	fmt.Fprintln(&b, "providers:")
+	fmt.Fprintf(&b, "  %s:\n", a.provider)
+	fmt.Fprintf(&b, "    type: %s\n", a.provider)
+	fmt.Fprintf(&b, "    username: %s\n", quote(a.username))
+	if file, ok := strings.CutPrefix(a.password, "@"); ok {
+		fmt.Fprintf(&b, "    password_file: %s\n", quote(file))
+	} else {
+		fmt.Fprintf(&b, "    password: %s\n", quote(a.password))
+	}
+	if a.endpoint != "" {
+		fmt.Fprintf(&b, "    endpoint: %s\n", quote(a.endpoint))
+	}
+	hostnames := make([]string, len(a.hostnames))
+	for i, h := range a.hostnames {
+		hostnames[i] = quote(h)
+	}
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "groups:")
+	fmt.Fprintln(&b, "  home:")
+	fmt.Fprintf(&b, "    providers: [%s]\n", a.provider)
+	fmt.Fprintf(&b, "    hostnames: [%s]\n", strings.Join(hostnames, ", "))
+	fmt.Fprintln(&b)
+	if a.ipv6 {
+		fmt.Fprintln(&b, "families: [ipv4, ipv6]")
+	} else {
+		fmt.Fprintln(&b, "families: [ipv4]")
+	}
+	return b.String()
+}
+
+// quote returns s as a double-quoted YAML string
+func quote(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+// questions asks for answers on out and reads them from in, one per line.
+// After the input fails or ends, err is set and every question returns its default.
+type questions struct {
+	in  *bufio.Scanner
+	out io.Writer
+	err error
+}
+
+func nonEmpty(s string) error {
+	if s == "" {
+		return errors.New("an answer is required")
+	}
+	return nil
+}
+
+// ask asks a question until the answer, or def for an empty one, passes check
+func (q *questions) ask(prompt, def string, check func(string) error) string {
+	for q.err == nil {
+		if def != "" {
+			fmt.Fprintf(q.out, "%s [%s]: ", prompt, def)
+		} else {
+			fmt.Fprintf(q.out, "%s: ", prompt)
+		}
+		if !q.in.Scan() {
+			q.err = q.in.Err()
+			if q.err == nil {
+				q.err = errors.New("no answer: the input ended")
+			}
+			break
+		}
+		answer := strings.TrimSpace(q.in.Text())
+		if answer == "" {
+			answer = def
+		}
+		if err := check(answer); err != nil {
+			fmt.Fprintf(q.out, "  %v\n", err)
+			continue
+		}
+		return answer
+	}
+	return def
+}
+
+// choose asks for one of choices, the first being the default
+func (q *questions) choose(prompt string, choices []string) string {
+	return q.ask(fmt.Sprintf("%s (%s)", prompt, strings.Join(choices, ", ")), choices[0], func(s string) error {
+		if !contains(choices, s) {
+			return fmt.Errorf("choose one of %s", strings.Join(choices, ", "))
+		}
+		return nil
+	})
+}
+
+// confirm asks a yes or no question
+func (q *questions) confirm(prompt string, def bool) bool {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	answer := q.ask(prompt+" ("+hint+")", "", func(s string) error {
+		switch strings.ToLower(s) {
+		case "", "y", "yes", "n", "no":
+			return nil
+		}
+		return errors.New("answer yes or no")
+	})
+	switch strings.ToLower(answer) {
+	case "y", "yes":
+		return true
+	case "n", "no":
+		return false
+	}
+	return def
+}
@@ -0,0 +1,238 @@
+// Command ddns detects this host's public IP address and publishes it to a dynamic DNS provider.
+//
+// Usage:
+//
+//	ddns [--dry-run] [--output json] <command> [flags]
+//
+// Commands:
+//
+//	update            detect the address and update the provider once
+//	daemon            keep the provider updated as the address changes
+//	wait              wait until the records resolve to the detected address
+//	status            show the last detected address and update of each provider
+//	control           ask the running daemon to update now or reload its configuration
+//	state             export or import the daemon state
+//	history           show the address changes and update attempts of the audit log
+//	healthcheck       exit with 0 if the daemon is healthy, for container health checks
+//	config            validate a configuration file
+//	check-credentials check that every provider account accepts its credentials
+//	init              ask for a provider account and hostnames and write a configuration file
+//	import-hosts      print a configuration file for the existing hosts of a provider account
+//	gc                remove the records ddns owns whose hostname is no longer configured
+//	gen               generate Prometheus alerting rules and a Grafana dashboard for the daemon
+//	service           install or uninstall the daemon as a system service
+//	completion        print a shell completion script for bash, zsh or fish
+//
+// Run "ddns <command> -h" for the flags of a command. With --dry-run, update and daemon detect the address
+// and show what they would update without sending anything to the providers. With --output json, every command
+// prints its result as a JSON object for scripts and monitoring.
+// Every flag can also be set through an environment variable named after it, such as DDNS_HOSTNAMES,
+// or read from the file named by the variable with a _FILE suffix, such as DDNS_PASSWORD_FILE,
+// so ddns can be configured without a configuration file in containers.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/justenwalker/ddns/logging"
+	"github.com/justenwalker/ddns/logging/syslog"
+)
+
+// exit codes
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
+	// returned by ddns update -oneshot
+	exitNoChange  = 3
+	exitTemporary = 4
+	exitPermanent = 5
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string, stdout, stderr io.Writer) int
+}
+
+var commands = []command{
+	{"update", "detect the address and update the provider once", runUpdate},
+	{"daemon", "keep the provider updated as the address changes", runDaemon},
+	{"wait", "wait until the records resolve to the detected address", runWait},
+	{"status", "show the last detected address and update of each provider", runStatus},
+	{"control", "ask the running daemon to update now or reload its configuration", runControl},
+	{"state", "export or import the daemon state", runState},
+	{"history", "show the address changes and update attempts of the audit log", runHistory},
+	{"healthcheck", "exit with 0 if the daemon is healthy, for container health checks", runHealthcheck},
+	{"config", "validate a configuration file", runConfig},
+	{"check-credentials", "check that every provider account accepts its credentials", runCheckCredentials},
+	{"init", "ask for a provider account and hostnames and write a configuration file", runInit},
+	{"import-hosts", "print a configuration file for the existing hosts of a provider account", runImportHosts},
+	{"gc", "remove the records ddns owns whose hostname is no longer configured", runGC},
+	{"gen", "generate Prometheus alerting rules and a Grafana dashboard for the daemon", runGen},
+	{"service", "install or uninstall the daemon as a system service", runService},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return exitUsage
+	}
+	args = globalFlags(args)
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c.run(args[1:], stdout, stderr)
+		}
+	}
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		usage(stdout)
+		return exitOK
+	}
+	fmt.Fprintf(stderr, "ddns: unknown command %q\n", args[0])
+	usage(stderr)
+	return exitUsage
+}
+
+// actionCommands take one of these actions before their flags, such as "ddns config validate -config ddns.yaml"
+var actionCommands = map[string][]string{
+	"state":        {"export", "import"},
+	"control":      {"update", "reload"},
+	"config":       {"validate"},
+	"service":      {"install", "uninstall"},
+	"completion":   {"bash", "zsh", "fish"},
+	"import-hosts": initProviders,
+	"gen":          {"monitoring"},
+}
+
+// globalFlags moves the global forms of the -dry-run and -output flags of the commands, such as
+// "ddns --output json status", after the command and its action
+func globalFlags(args []string) []string {
+	var global []string
+loop:
+	for len(args) > 1 && strings.HasPrefix(args[0], "-") {
+		// -flag and --flag are equivalent
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(args[0][1:], "-"), "=")
+		switch {
+		case name == "dry-run" && !hasValue:
+			global, args = append(global, "-dry-run"), args[1:]
+		case name == "output" && hasValue:
+			global, args = append(global, "-output", value), args[1:]
+		case name == "output" && len(args) > 2:
+			global, args = append(global, "-output", args[1]), args[2:]
+		default:
+			break loop
+		}
+	}
+	if len(global) == 0 {
+		return args
+	}
+	n := 1
+	if len(actionCommands[args[0]]) > 0 && len(args) > 1 && !strings.HasPrefix(args[1], "-") {
+		n = 2
+	}
+	out := append([]string{}, args[:n]...)
+	out = append(out, global...)
+	return append(out, args[n:]...)
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: ddns [--dry-run] [--output json] <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	width := 0
+	for _, c := range commands {
+		width = max(width, len(c.name)+1)
+	}
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-*s%s\n", width, c.name, c.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "ddns <command> -h" for the flags of a command.`)
+}
+
+// flagSetCreated, when set, is called with the flag set of every command run, so ddns completion can list them
+var flagSetCreated func(fs *flag.FlagSet)
+
+// newFlagSet returns the flag set of a command, which reports errors and usage to stderr
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	if flagSetCreated != nil {
+		flagSetCreated(fs)
+	}
+	return fs
+}
+
+// Logger is the logging interface shared by the ddns packages
+type Logger interface {
+	Log(format string, v ...interface{})
+}
+
+// logger adapts a standard library logger to the Logger interfaces of the ddns packages
+type logger struct {
+	*log.Logger
+}
+
+func newLogger(w io.Writer) logger {
+	return logger{log.New(w, "", log.LstdFlags)}
+}
+
+func (l logger) Log(format string, v ...interface{}) {
+	l.Printf(format, v...)
+}
+
+// newJSONLogger returns a logger writing each message as a JSON object on its own line, with its level and fields
+func newJSONLogger(w io.Writer) *logging.Adapter {
+	return logging.Slog(slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug})))
+}
+
+// debugLogger returns l logging at the debug level, for the formats that have levels
+func debugLogger(l Logger) Logger {
+	switch l := l.(type) {
+	case *logging.Adapter:
+		return l.Level(slog.LevelDebug)
+	case *syslog.Logger:
+		return l.Debug()
+	}
+	return l
+}
+
+// stringList is a repeatable string flag
+type stringList []string
+
+func (s *stringList) String() string {
+	return fmt.Sprint(*s)
+}
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// intList is a repeatable integer flag
+type intList []int
+
+func (s *intList) String() string {
+	return fmt.Sprint(*s)
+}
+
+func (s *intList) Set(v string) error {
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return err
+	}
+	*s = append(*s, n)
+	return nil
+}
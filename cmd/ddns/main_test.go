@@ -0,0 +1,1523 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/justenwalker/ddns/config"
+	"github.com/justenwalker/ddns/control"
+	"github.com/justenwalker/ddns/event"
+	"github.com/justenwalker/ddns/internal/pidlock"
+	"github.com/justenwalker/ddns/netwatch"
+	"github.com/justenwalker/ddns/state"
+	"github.com/justenwalker/ddns/verify"
+)
+
+func TestUpdate(t *testing.T) {
+	detect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("203.0.113.7\n"))
+	}))
+	defer detect.Close()
+	var query string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		w.Write([]byte("good 203.0.113.7"))
+	}))
+	defer api.Close()
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"update",
+		"-username", "user", "-password", "pass",
+		"-hostname", "foo.example.com",
+		"-source", detect.URL,
+		"-endpoint", api.URL,
+	}, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(query, "hostname=foo.example.com") || !strings.Contains(query, "myip=203.0.113.7") {
+		t.Errorf("unexpected update query %q", query)
+	}
+	if stdout.String() != "updated foo.example.com to 203.0.113.7\n" {
+		t.Errorf("unexpected output %q", stdout.String())
+	}
+}
+
+func TestUpdateBootstrap(t *testing.T) {
+	detect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("203.0.113.7\n"))
+	}))
+	defer detect.Close()
+	var host string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+		w.Write([]byte("good 203.0.113.7"))
+	}))
+	defer api.Close()
+	u, _ := url.Parse(api.URL)
+
+	// the endpoint does not resolve: it is reached at its bootstrap address
+	endpoint := "http://api.ddns.invalid:" + u.Port()
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"update",
+		"-username", "user", "-password", "pass",
+		"-hostname", "foo.example.com",
+		"-source", detect.URL,
+		"-endpoint", endpoint,
+		"-bootstrap-ip", u.Hostname(),
+	}, &stdout, &stderr)
+	if code != exitOK || host != "api.ddns.invalid:"+u.Port() {
+		t.Fatalf("exit code %d, host %q: %s", code, host, stderr.String())
+	}
+	stderr.Reset()
+	if code := run([]string{"update", "-username", "user", "-password", "pass", "-bootstrap-doh", "https://dns.example/dns-query"}, &stdout, &stderr); code != exitUsage {
+		t.Errorf("expected a usage error for a DoH server that must be resolved, got %d: %s", code, stderr.String())
+	}
+}
+
+func TestUpdateAccounts(t *testing.T) {
+	detect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("203.0.113.7\n"))
+	}))
+	defer detect.Close()
+	requests := make(map[string]string)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Query().Get("hostname")] = r.URL.Query().Get("password")
+		w.Write([]byte(strings.Repeat("good 203.0.113.7\n", strings.Count(r.URL.Query().Get("hostname"), ",")+1)))
+	}))
+	defer api.Close()
+	path := filepath.Join(t.TempDir(), "ddns.yaml")
+	os.WriteFile(path, []byte(fmt.Sprintf(`
+providers:
+  home:
+    type: dynu
+    password: main
+    endpoint: %q
+    accounts:
+      - {zones: [example.org], password: org}
+      - {zones: [vpn.example.org], password: vpn}
+groups:
+  all: {providers: [home], hostnames: [www.example.com, example.org, www.example.org, office.vpn.example.org]}
+sources:
+  - {type: http, url: %q}
+`, api.URL, detect.URL)), 0o600)
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"update", "-config", path}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	want := map[string]string{
+		"www.example.com":             hashed("main"),
+		"example.org,www.example.org": hashed("org"),
+		"office.vpn.example.org":      hashed("vpn"),
+	}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("expected a request per account, got %v", requests)
+	}
+}
+
+func TestUpdateHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	detect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("203.0.113.7\n"))
+	}))
+	defer detect.Close()
+	response := "good 203.0.113.7"
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(response))
+	}))
+	defer api.Close()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ddns.yaml")
+	out := filepath.Join(dir, "hooks")
+	record := fmt.Sprintf(`[sh, -c, 'echo "$DDNS_HOOK_EVENT $DDNS_HOOK_PROVIDER $DDNS_HOOK_RESULT $DDNS_HOOK_NEW_IPS" >> %s']`, out)
+	os.WriteFile(path, []byte(fmt.Sprintf(`
+providers:
+  home: {type: dynu, username: user, password: pass, endpoint: %q}
+groups:
+  all: {providers: [home], hostnames: [foo.example.com]}
+sources:
+  - {type: http, url: %q}
+hooks:
+  pre_detect: %s
+  on_success: %s
+  on_failure: %s
+`, api.URL, detect.URL, record, record, record)), 0o600)
+	for _, response = range []string{"good 203.0.113.7", "nochg 203.0.113.7", "badauth"} {
+		var stdout, stderr bytes.Buffer
+		run([]string{"update", "-config", path}, &stdout, &stderr)
+	}
+	want := "pre-detect   \n" +
+		"success home/all updated 203.0.113.7\n" +
+		"pre-detect   \n" +
+		"success home/all unchanged 203.0.113.7\n" +
+		"pre-detect   \n" +
+		"failure home/all failed 203.0.113.7\n"
+	if data, _ := os.ReadFile(out); string(data) != want {
+		t.Errorf("got hooks\n%s\nwant\n%s", data, want)
+	}
+}
+
+func TestUpdateAliases(t *testing.T) {
+	detect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("203.0.113.7\n"))
+	}))
+	defer detect.Close()
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("good 203.0.113.7\ngood 203.0.113.7\n"))
+	}))
+	defer api.Close()
+	defer func(r verify.CNAMEResolver) { cnameResolver = r }(cnameResolver)
+	cnameResolver = cnames{"www.example.com": "www._dyn.example.com.", "office.example.com": "office.example.com."}
+	path := filepath.Join(t.TempDir(), "ddns.yaml")
+	os.WriteFile(path, []byte(fmt.Sprintf(`
+providers:
+  home: {type: dynu, username: user, password: pass, endpoint: %q}
+groups:
+  all:
+    providers: [home]
+    hostnames: [www._dyn.example.com, office._dyn.example.com]
+    aliases:
+      www.example.com: www._dyn.example.com
+      office.example.com: office._dyn.example.com
+sources:
+  - {type: http, url: %q}
+`, api.URL, detect.URL)), 0o600)
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"update", "-config", path}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("expected a broken chain not to fail the update, got exit code %d: %s", code, stderr.String())
+	}
+	want := "ddns: warning: CNAME chain of office.example.com is broken: it leads to office.example.com, want office._dyn.example.com\n"
+	if stderr.String() != want {
+		t.Errorf("got %q, want %q", stderr.String(), want)
+	}
+}
+
+// cnames maps hostnames to their canonical name
+type cnames map[string]string
+
+func (c cnames) LookupCNAME(ctx context.Context, host string) (string, error) {
+	if name, ok := c[host]; ok {
+		return name, nil
+	}
+	return "", &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func TestUpdateRollout(t *testing.T) {
+	detect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("203.0.113.7\n"))
+	}))
+	defer detect.Close()
+	var updates []string
+	served := make(map[string]bool)
+	api := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			updates = append(updates, name)
+			served[name+".example.net:53"] = true
+			w.Write([]byte("good 203.0.113.7"))
+		}))
+	}
+	primary, secondary, tertiary := api("primary"), api("secondary"), api("tertiary")
+	defer primary.Close()
+	defer secondary.Close()
+	defer tertiary.Close()
+	defer func(r func(string) verify.Resolver, poll time.Duration) { rolloutResolver, rolloutPoll = r, poll }(rolloutResolver, rolloutPoll)
+	lagging := ""
+	rolloutPoll = 10 * time.Millisecond
+	rolloutResolver = func(server string) verify.Resolver {
+		return resolverFunc(func(ctx context.Context, network, host string) ([]net.IP, error) {
+			if !served[server] || server == lagging {
+				return []net.IP{net.ParseIP("203.0.113.1")}, nil
+			}
+			return []net.IP{net.ParseIP("203.0.113.7")}, nil
+		})
+	}
+	path := filepath.Join(t.TempDir(), "ddns.yaml")
+	os.WriteFile(path, []byte(fmt.Sprintf(`
+providers:
+  primary: {type: dynu, username: user, password: pass, endpoint: %q, resolver: primary.example.net}
+  secondary: {type: dynu, username: user, password: pass, endpoint: %q, resolver: secondary.example.net:53}
+  tertiary: {type: dynu, username: user, password: pass, endpoint: %q}
+groups:
+  web:
+    providers: [primary, secondary, tertiary]
+    hostnames: [www.example.com]
+    rollout: ordered
+    rollout_timeout: 100ms
+sources:
+  - {type: http, url: %q}
+`, primary.URL, secondary.URL, tertiary.URL, detect.URL)), 0o600)
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"update", "-config", path}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	if want := []string{"primary", "secondary", "tertiary"}; !reflect.DeepEqual(updates, want) {
+		t.Errorf("got updates %v, want %v", updates, want)
+	}
+
+	updates, lagging = nil, "secondary.example.net:53"
+	stderr.Reset()
+	if code := run([]string{"update", "-config", path}, &stdout, &stderr); code == exitOK {
+		t.Fatal("expected a provider that does not serve the address to fail the rollout")
+	}
+	if want := []string{"primary", "secondary"}; !reflect.DeepEqual(updates, want) {
+		t.Errorf("got updates %v, want %v", updates, want)
+	}
+	if !strings.Contains(stderr.String(), "primary>secondary>tertiary/web") || !strings.Contains(stderr.String(), "rollout stopped at secondary") {
+		t.Errorf("unexpected output %q", stderr.String())
+	}
+}
+
+// resolverFunc adapts a function to the verify.Resolver interface
+type resolverFunc func(ctx context.Context, network, host string) ([]net.IP, error)
+
+func (f resolverFunc) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	return f(ctx, network, host)
+}
+
+func TestUpdateOneshot(t *testing.T) {
+	detect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("203.0.113.7\n"))
+	}))
+	defer detect.Close()
+	for _, tc := range []struct {
+		response string
+		oneshot  int
+		plain    int
+	}{
+		{"good 203.0.113.7", exitOK, exitOK},
+		{"nochg 203.0.113.7", exitNoChange, exitOK},
+		{"911", exitTemporary, exitFailure},
+		{"badauth", exitPermanent, exitFailure},
+	} {
+		api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(tc.response))
+		}))
+		args := []string{"update",
+			"-username", "user", "-password", "pass",
+			"-hostname", "foo.example.com",
+			"-source", detect.URL,
+			"-endpoint", api.URL,
+		}
+		var stdout, stderr bytes.Buffer
+		if code := run(append(args, "-oneshot"), &stdout, &stderr); code != tc.oneshot {
+			t.Errorf("%s: -oneshot exit code %d, want %d: %s", tc.response, code, tc.oneshot, stderr.String())
+		}
+		if code := run(args, &stdout, &stderr); code != tc.plain {
+			t.Errorf("%s: exit code %d, want %d: %s", tc.response, code, tc.plain, stderr.String())
+		}
+		api.Close()
+	}
+}
+
+func TestUpdatePIDFile(t *testing.T) {
+	detect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("203.0.113.7\n"))
+	}))
+	defer detect.Close()
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("good 203.0.113.7"))
+	}))
+	defer api.Close()
+	path := filepath.Join(t.TempDir(), "ddns.pid")
+	lock, err := pidlock.Acquire(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"update", "-oneshot",
+		"-username", "user", "-password", "pass",
+		"-hostname", "foo.example.com",
+		"-source", detect.URL,
+		"-endpoint", api.URL,
+		"-pid-file", path,
+		"-timeout", "50ms",
+	}
+	var stdout, stderr bytes.Buffer
+	if code := run(args, &stdout, &stderr); code != exitTemporary || !strings.Contains(stderr.String(), "locked by process") {
+		t.Errorf("expected a temporary failure while another instance holds the lock, got exit code %d: %s", code, stderr.String())
+	}
+	lock.Release()
+	stderr.Reset()
+	if code := run(args, &stdout, &stderr); code != exitOK {
+		t.Errorf("exit code %d: %s", code, stderr.String())
+	}
+}
+
+func TestUpdateHookNotTriggered(t *testing.T) {
+	t.Setenv("reason", "EXPIRE")
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"update", "-username", "user", "-password", "pass", "-source", "hook"}, &stdout, &stderr)
+	if code != exitOK || stdout.Len() != 0 {
+		t.Errorf("expected a silent no-op, got exit code %d: %s%s", code, stdout.String(), stderr.String())
+	}
+}
+
+func TestUnknownCommand(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"frobnicate"}, &stdout, &stderr); code != exitUsage {
+		t.Errorf("expected usage exit code, got %d", code)
+	}
+}
+
+func TestUpdateCanary(t *testing.T) {
+	detect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("203.0.113.7"))
+	}))
+	defer detect.Close()
+	var hostnames []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := r.URL.Query().Get("hostname")
+		hostnames = append(hostnames, h)
+		if h == "canary.example.com" {
+			w.Write([]byte("nohost"))
+			return
+		}
+		w.Write([]byte("good 203.0.113.7"))
+	}))
+	defer api.Close()
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"update",
+		"-username", "user", "-password", "pass",
+		"-hostname", "foo.example.com", "-canary", "canary.example.com",
+		"-source", detect.URL, "-endpoint", api.URL,
+	}, &stdout, &stderr)
+	if code != exitFailure {
+		t.Fatalf("expected the failed canary to fail the update, got exit code %d", code)
+	}
+	if len(hostnames) != 1 {
+		t.Errorf("expected only the canary to be updated, got %v", hostnames)
+	}
+}
+
+func TestUpdateConfig(t *testing.T) {
+	detect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ip":"203.0.113.7"}`))
+	}))
+	defer detect.Close()
+	var queries []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Query().Get("hostname"))
+		w.Write([]byte("good 203.0.113.7"))
+	}))
+	defer api.Close()
+
+	path := filepath.Join(t.TempDir(), "ddns.yaml")
+	cfg := "providers:\n" +
+		"  home: {type: dynu, username: user, password: pass, endpoint: " + api.URL + "}\n" +
+		"defaults: {providers: [home]}\n" +
+		"groups:\n" +
+		"  web: {hostnames: [example.com, www.example.com]}\n" +
+		"  vpn: {hostnames: [vpn.example.com]}\n" +
+		"sources:\n" +
+		"  - {type: http, url: " + detect.URL + ", json_path: ip}\n"
+	if err := os.WriteFile(path, []byte(cfg), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"update", "-config", path}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	if len(queries) != 2 || queries[0] != "vpn.example.com" || queries[1] != "example.com,www.example.com" {
+		t.Errorf("unexpected updates %v", queries)
+	}
+}
+
+func TestUpdateProfiles(t *testing.T) {
+	detect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ip":"203.0.113.7"}`))
+	}))
+	defer detect.Close()
+	var updates []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		account := "home"
+		if r.URL.Query().Get("password") != hashed("pass") {
+			account = "office"
+		}
+		updates = append(updates, account+" "+r.URL.Query().Get("hostname"))
+		w.Write([]byte("good 203.0.113.7"))
+	}))
+	defer api.Close()
+
+	path := filepath.Join(t.TempDir(), "ddns.yaml")
+	cfg := "providers:\n" +
+		"  home: {type: dynu, username: user, password: pass, endpoint: " + api.URL + "}\n" +
+		"defaults: {providers: [home]}\n" +
+		"sources:\n" +
+		"  - {type: http, url: " + detect.URL + ", json_path: ip}\n" +
+		"profiles:\n" +
+		"  home:\n" +
+		"    groups: {web: {hostnames: [example.com]}}\n" +
+		"  office:\n" +
+		"    providers:\n" +
+		"      home: {type: dynu, username: office, password: secret, endpoint: " + api.URL + "}\n" +
+		"    groups: {vpn: {hostnames: [vpn.example.net]}}\n"
+	if err := os.WriteFile(path, []byte(cfg), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"update", "-config", path, "-profile", "office"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	if len(updates) != 1 || updates[0] != "office vpn.example.net" {
+		t.Errorf("expected only the office profile to be updated, got %v", updates)
+	}
+	updates = nil
+	if code := run([]string{"update", "-config", path}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	if len(updates) != 2 || updates[0] != "home example.com" || updates[1] != "office vpn.example.net" {
+		t.Errorf("expected every profile to be updated, got %v", updates)
+	}
+	if code := run([]string{"update", "-config", path, "-profile", "lab"}, &stdout, &stderr); code != exitUsage {
+		t.Errorf("expected exit code %d for an unknown profile, got %d", exitUsage, code)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	detect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ip":"203.0.113.7"}`))
+	}))
+	defer detect.Close()
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s", r.URL)
+	}))
+	defer api.Close()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ddns.yaml")
+	cfg := "providers:\n" +
+		"  home: {type: dynu, username: user, password: pass, endpoint: " + api.URL + "}\n" +
+		"defaults: {providers: [home]}\n" +
+		"groups:\n" +
+		"  web: {hostnames: [example.com]}\n" +
+		"  vpn: {hostnames: [vpn.example.com]}\n" +
+		"sources:\n" +
+		"  - {type: http, url: " + detect.URL + ", json_path: ip}\n"
+	if err := os.WriteFile(path, []byte(cfg), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--dry-run", "update", "-config", path}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	want := "would update vpn.example.com (home/vpn) to 203.0.113.7\nwould update example.com (home/web) to 203.0.113.7\n"
+	if stdout.String() != want {
+		t.Errorf("got\n%swant\n%s", stdout.String(), want)
+	}
+
+	statePath := filepath.Join(dir, "state.json")
+	snapshot := `{"version":2,"providers":{"home/web":{"ips":["203.0.113.7"],"updated_at":"2026-01-02T03:04:05Z"}}}`
+	if err := os.WriteFile(statePath, []byte(snapshot), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	stdout.Reset()
+	if code := run([]string{"daemon", "-dry-run", "-config", path, "-state", statePath, "-termux=false"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	want = "would update vpn.example.com (home/vpn) to 203.0.113.7\ndetected 203.0.113.7\nwould not update example.com (home/web)\n"
+	if stdout.String() != want {
+		t.Errorf("got\n%swant\n%s", stdout.String(), want)
+	}
+	if data, _ := os.ReadFile(statePath); string(data) != snapshot {
+		t.Errorf("expected the state to be left unchanged, got %s", data)
+	}
+	stdout.Reset()
+	if code := run([]string{"daemon", "-dry-run", "-force", "-config", path, "-state", statePath, "-termux=false"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "would update example.com (home/web)") {
+		t.Errorf("expected -force to update the unchanged record, got %s", stdout.String())
+	}
+}
+
+func hashed(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestStateExportImport(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "old.json")
+	dst := filepath.Join(dir, "new.json")
+	snapshot := `{"version":1,"providers":{"dynu":{"ips":["203.0.113.1"],"failures":1}}}`
+	if err := os.WriteFile(src, []byte(snapshot), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"state", "export", "-state", src}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("export: exit code %d: %s", code, stderr.String())
+	}
+	export := filepath.Join(dir, "export.json")
+	os.WriteFile(export, stdout.Bytes(), 0o600)
+	if code := run([]string{"state", "import", "-state", dst, export}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("import: exit code %d: %s", code, stderr.String())
+	}
+	if code := run([]string{"state", "import", "-state", dst, export}, &stdout, &stderr); code != exitFailure {
+		t.Errorf("expected importing over existing state to fail without -force, got exit code %d", code)
+	}
+	data, _ := os.ReadFile(dst)
+	if !strings.Contains(string(data), `"203.0.113.1"`) {
+		t.Errorf("unexpected imported state %s", data)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	snapshot := `{"version":1,"detected":["203.0.113.2"],"detected_at":"2026-01-02T03:04:05Z","next_run":"2026-01-02T03:09:05Z",
+"providers":{"dynu":{"hostnames":["example.com"],"ips":["203.0.113.1"],"updated_at":"2026-01-01T00:00:00Z","failures":2,
+"last_error":"dynu: badauth","last_error_at":"2026-01-02T03:04:05Z"}}}`
+	if err := os.WriteFile(path, []byte(snapshot), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"status", "-state", path}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	for _, want := range []string{"203.0.113.2", "dynu (example.com)", "203.0.113.1", "2 consecutive failure(s)", "dynu: badauth", "overdue"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("expected %q in status:\n%s", want, stdout.String())
+		}
+	}
+
+	stdout.Reset()
+	if code := run([]string{"status", "-state", path, "-json"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	var st struct {
+		Overdue   bool
+		Providers []struct {
+			Name      string
+			LastError string `json:"last_error"`
+		}
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &st); err != nil {
+		t.Fatal(err)
+	}
+	if !st.Overdue || len(st.Providers) != 1 || st.Providers[0].Name != "dynu" || st.Providers[0].LastError != "dynu: badauth" {
+		t.Errorf("unexpected JSON status %s", stdout.String())
+	}
+}
+
+func TestControl(t *testing.T) {
+	if !control.Supported {
+		t.Skip("control socket not supported on this platform")
+	}
+	// t.TempDir can be too long for a Unix socket
+	dir, err := os.MkdirTemp("", "ddns")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "ctl.sock")
+	s := control.New()
+	step, trigger := s.Step(), s.Trigger()
+	srv, err := s.Listen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"status", "-control-socket", path}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "not finished its first step") {
+		t.Errorf("unexpected status before the first step:\n%s", stdout.String())
+	}
+	snap := state.New()
+	snap.Providers["dynu"] = state.Provider{Failures: 1, LastError: "dynu: badauth"}
+	step(context.Background(), snap)
+	stdout.Reset()
+	if code := run([]string{"status", "-control-socket", path}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	for _, want := range []string{fmt.Sprintf("process %d", os.Getpid()), "dynu: badauth"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("expected %q in status:\n%s", want, stdout.String())
+		}
+	}
+
+	if code := run([]string{"control", "update", "-control-socket", path}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	select {
+	case <-trigger:
+	default:
+		t.Error("expected the daemon to be triggered")
+	}
+	stderr.Reset()
+	if code := run([]string{"control", "reload", "-control-socket", path}, &stdout, &stderr); code != exitFailure || !strings.Contains(stderr.String(), "cannot be reloaded") {
+		t.Errorf("expected reload to fail without a reload function, got exit code %d: %s", code, stderr.String())
+	}
+}
+
+func TestStatusFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status.json")
+	sf := newStatusFile(path, nil)
+	home, office := sf.Step(), sf.Step()
+	now := time.Now().UTC().Truncate(time.Second)
+	snap := state.New()
+	snap.Detected, snap.DetectedAt, snap.NextRun = []net.IP{net.ParseIP("203.0.113.7")}, now, now.Add(5*time.Minute)
+	snap.Providers["home"] = state.Provider{IPs: snap.Detected, UpdatedAt: now}
+	home(context.Background(), snap)
+	snap = state.New()
+	snap.Providers["office"] = state.Provider{Failures: 2, LastError: "dynu: badauth", LastErrorAt: now}
+	office(context.Background(), snap)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var st status
+	if err := json.Unmarshal(data, &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.PID != os.Getpid() || st.Started == nil || !st.NextRun.Equal(now.Add(5*time.Minute)) || len(st.Detected) != 1 {
+		t.Errorf("unexpected status %s", data)
+	}
+	if len(st.Providers) != 2 || !st.Providers[0].UpdatedAt.Equal(now) || st.Providers[1].LastError != "dynu: badauth" {
+		t.Errorf("expected the providers of both daemons, got %s", data)
+	}
+	if fi, err := os.Stat(path); err != nil {
+		t.Error(err)
+	} else if runtime.GOOS != "windows" && fi.Mode().Perm() != 0o644 {
+		t.Errorf("expected a file readable by other users, got %v", fi.Mode())
+	}
+}
+
+func TestHistory(t *testing.T) {
+	detect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("203.0.113.7\n"))
+	}))
+	defer detect.Close()
+	reply := "good 203.0.113.7"
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(reply))
+	}))
+	defer api.Close()
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	update := []string{"update", "-username", "user", "-password", "pass", "-hostname", "foo.example.com",
+		"-source", detect.URL, "-endpoint", api.URL, "-audit-log", path}
+	var stdout, stderr bytes.Buffer
+	if code := run(update, &stdout, &stderr); code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	reply = "badauth"
+	run(update, &stdout, &stderr)
+
+	stdout.Reset()
+	if code := run([]string{"history", "-audit-log", path, "-output", "json"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	var h struct {
+		Records []struct {
+			Type, Provider, Result, Error string
+			NewIPs                        []string `json:"new_ips"`
+		}
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &h); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range h.Records {
+		got = append(got, strings.TrimSpace(fmt.Sprint(r.Type, " ", r.Provider, " ", r.NewIPs, " ", r.Result)))
+	}
+	want := []string{"detected  [203.0.113.7]", "update dynu [203.0.113.7] success", "update dynu [203.0.113.7] failure"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got records %q, want %q", got, want)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"history", "-audit-log", path, "-failures", "-since", "1h"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	if out := stdout.String(); strings.Count(out, "\n") != 2 || !strings.Contains(out, "updated dynu (foo.example.com)") || !strings.Contains(out, "failure: ") {
+		t.Errorf("expected the failed update, got\n%s", out)
+	}
+	if code := run([]string{"history", "-audit-log", path, "-since", "yesterday"}, &stdout, &stderr); code != exitUsage {
+		t.Errorf("expected an invalid -since to be a usage error, got %d", code)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("myip") != "no" {
+			t.Errorf("expected no address to be published, got %s", r.URL.RawQuery)
+		}
+		w.Write([]byte("badauth\n"))
+	}))
+	defer srv.Close()
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.yaml")
+	os.WriteFile(good, []byte(`
+providers:
+  home: {type: dynu, username: user, password: pass, endpoint: `+srv.URL+`}
+groups:
+  web: {providers: [home], hostnames: [example.com]}
+`), 0o600)
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"config", "validate", "-config", good}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("exit code %d: %s%s", code, stdout.String(), stderr.String())
+	}
+	stdout.Reset()
+	if code := run([]string{"config", "validate", "-config", good, "-check-credentials"}, &stdout, &stderr); code != exitFailure {
+		t.Fatalf("expected rejected credentials to fail, got exit code %d", code)
+	}
+	if !strings.Contains(stdout.String(), "good.yaml:3: ") || !strings.Contains(stdout.String(), "badauth") {
+		t.Errorf("unexpected output %s", stdout.String())
+	}
+
+	bad := filepath.Join(dir, "bad.yaml")
+	os.WriteFile(bad, []byte(`
+providers:
+  home: {type: carrier-pigeon}
+groups:
+  web: {providers: [home, away], hostnames: [example.com]}
+`), 0o600)
+	stdout.Reset()
+	if code := run([]string{"config", "validate", "-config", bad}, &stdout, &stderr); code != exitFailure {
+		t.Fatalf("expected exit code %d, got %d", exitFailure, code)
+	}
+	if lines := strings.Split(strings.TrimSpace(stdout.String()), "\n"); len(lines) != 2 ||
+		!strings.HasPrefix(lines[0], bad+":3: ") || !strings.HasPrefix(lines[1], bad+":5: ") {
+		t.Errorf("unexpected output %s", stdout.String())
+	}
+
+	v1 := filepath.Join(dir, "v1.yaml")
+	os.WriteFile(v1, []byte(`
+providers:
+  home: {type: dynu, username: user, password: pass}
+groups:
+  web: {providers: [home], hostnames: [example.com]}
+ipv6: true
+`), 0o600)
+	stdout.Reset()
+	if code := run([]string{"config", "validate", "-config", v1}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("expected a deprecated setting to only warn, got exit code %d: %s", code, stdout.String())
+	}
+	if !strings.Contains(stdout.String(), v1+":6: warning: ipv4 and ipv6 are deprecated") {
+		t.Errorf("unexpected output %s", stdout.String())
+	}
+
+	profiles := filepath.Join(dir, "profiles.yaml")
+	os.WriteFile(profiles, []byte(`
+providers:
+  home: {type: dynu, username: user, password: pass}
+profiles:
+  office:
+    groups:
+      vpn: {providers: [work], hostnames: [vpn.example.net]}
+`), 0o600)
+	stdout.Reset()
+	if code := run([]string{"config", "validate", "-config", profiles}, &stdout, &stderr); code != exitFailure {
+		t.Fatalf("expected exit code %d, got %d", exitFailure, code)
+	}
+	if want := profiles + `:7: profile "office": group "vpn": undefined provider "work"`; strings.TrimSpace(stdout.String()) != want {
+		t.Errorf("got %s, want %s", stdout.String(), want)
+	}
+}
+
+func TestHealthcheck(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	write := func(next time.Time, failures int) {
+		s := state.New()
+		s.NextRun = next
+		s.Providers["dynu"] = state.Provider{Failures: failures, LastError: "dynu: 911"}
+		if err := (state.File{Path: path}).Save(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"healthcheck", "-state", path}, &stdout, &stderr); code != exitFailure {
+		t.Errorf("expected a daemon without state to be unhealthy, got exit code %d", code)
+	}
+	write(time.Now().Add(time.Minute), 2)
+	if code := run([]string{"healthcheck", "-state", path}, &stdout, &stderr); code != exitOK {
+		t.Errorf("expected a daemon on schedule to be healthy, got exit code %d: %s", code, stdout.String())
+	}
+	if code := run([]string{"healthcheck", "-state", path, "-max-failures", "2"}, &stdout, &stderr); code != exitFailure {
+		t.Errorf("expected a failing provider to be unhealthy with -max-failures, got exit code %d", code)
+	}
+	write(time.Now().Add(-time.Hour), 0)
+	stdout.Reset()
+	if code := run([]string{"healthcheck", "-state", path}, &stdout, &stderr); code != exitFailure || !strings.Contains(stdout.String(), "late") {
+		t.Errorf("expected a late daemon to be unhealthy, got exit code %d: %s", code, stdout.String())
+	}
+}
+
+func TestJSONLogger(t *testing.T) {
+	var buf bytes.Buffer
+	newJSONLogger(&buf).Log("daemon: %s: published %v", "dynu", "203.0.113.1")
+	var line struct {
+		Level, Msg, Component string
+	}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatal(err)
+	}
+	if line.Level != "INFO" || line.Component != "daemon" || line.Msg != "dynu: published 203.0.113.1" {
+		t.Errorf("unexpected log line %s", buf.String())
+	}
+}
+
+func TestDaemonChaos(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"daemon", "--chaos", "-duration", "24h", "-seed", "1"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("exit code %d: %s%s", code, stdout.String(), stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "0 violations") {
+		t.Errorf("unexpected report %s", stdout.String())
+	}
+}
+
+func TestOutputJSON(t *testing.T) {
+	detect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("203.0.113.7\n"))
+	}))
+	defer detect.Close()
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("hostname") == "bad.example.com" {
+			w.Write([]byte("nohost"))
+			return
+		}
+		w.Write([]byte("good 203.0.113.7"))
+	}))
+	defer api.Close()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ddns.yaml")
+	os.WriteFile(path, []byte("version: 2\n"+
+		"providers:\n"+
+		"  home: {type: dynu, username: user, password: pass, endpoint: "+api.URL+"}\n"+
+		"sources:\n"+
+		"  - {type: http, url: "+detect.URL+"}\n"+
+		"groups:\n"+
+		"  good: {providers: [home], hostnames: [good.example.com]}\n"+
+		"  bad: {providers: [home], hostnames: [bad.example.com]}\n"), 0o600)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--output", "json", "update", "-config", path}, &stdout, &stderr); code != exitFailure {
+		t.Fatalf("expected exit code %d, got %d: %s", exitFailure, code, stderr.String())
+	}
+	var out results
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		t.Fatalf("%v: %s", err, stdout.String())
+	}
+	statuses := map[string]string{}
+	for _, r := range out.Results {
+		if len(r.Detected) != 1 || r.Detected[0].String() != "203.0.113.7" {
+			t.Errorf("unexpected detected addresses %v", r.Detected)
+		}
+		for _, p := range r.Providers {
+			statuses[p.Hostnames[0]] = p.Status
+			if p.Status == "failed" && !strings.Contains(p.Error, "nohost") {
+				t.Errorf("unexpected error %q", p.Error)
+			}
+		}
+	}
+	if statuses["good.example.com"] != "updated" || statuses["bad.example.com"] != "failed" {
+		t.Errorf("unexpected statuses %v in %s", statuses, stdout.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"--output=json", "config", "validate", "-config", path}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	var v validation
+	if err := json.Unmarshal(stdout.Bytes(), &v); err != nil || !v.OK || len(v.Problems) != 0 {
+		t.Errorf("unexpected validation %s (%v)", stdout.String(), err)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"healthcheck", "-output", "json", "-state", filepath.Join(dir, "missing.json")}, &stdout, &stderr); code != exitFailure {
+		t.Fatalf("expected exit code %d, got %d", exitFailure, code)
+	}
+	var h healthReport
+	if err := json.Unmarshal(stdout.Bytes(), &h); err != nil || h.Healthy || h.Reason == "" {
+		t.Errorf("unexpected health %s (%v)", stdout.String(), err)
+	}
+
+	if code := run([]string{"status", "-output", "yaml", "-state", path}, &stdout, &stderr); code != exitUsage {
+		t.Errorf("expected an unknown format to be a usage error, got exit code %d", code)
+	}
+}
+
+func TestLogFile(t *testing.T) {
+	detect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("203.0.113.7\n"))
+	}))
+	defer detect.Close()
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("good 203.0.113.7"))
+	}))
+	defer api.Close()
+	path := filepath.Join(t.TempDir(), "ddns.log")
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"update", "-v", "-log-file", path, "-log-format", "json",
+		"-username", "user", "-password", "pass", "-hostname", "foo.example.com",
+		"-source", detect.URL, "-endpoint", api.URL}, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"component":"ipdetect"`) || strings.Contains(stdout.String(), "component") {
+		t.Errorf("expected the logs in the file only, got file %s and stdout %s", data, stdout.String())
+	}
+
+	// the log file of the configuration is used unless -log-file is set
+	dir := t.TempDir()
+	configured := filepath.Join(dir, "configured.log")
+	configPath := filepath.Join(dir, "ddns.yaml")
+	os.WriteFile(configPath, []byte(fmt.Sprintf(`
+providers:
+  home: {type: dynu, username: user, password: pass, endpoint: %q}
+groups:
+  home: {providers: [home], hostnames: [foo.example.com]}
+sources:
+  - {type: http, url: %q}
+log:
+  file: %q
+  max_size: 0
+`, api.URL, detect.URL, configured)), 0o600)
+	stderr.Reset()
+	if code := run([]string{"update", "-v", "-config", configPath}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	if data, err := os.ReadFile(configured); err != nil || !strings.Contains(string(data), "ipdetect") || stderr.Len() > 0 {
+		t.Errorf("expected the logs in the configured file only, got %q (%v) and stderr %q", data, err, stderr.String())
+	}
+	overridden := filepath.Join(dir, "overridden.log")
+	if code := run([]string{"update", "-v", "-config", configPath, "-log-file", overridden}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	if _, err := os.Stat(overridden); err != nil {
+		t.Errorf("expected -log-file to override the configured log: %v", err)
+	}
+}
+
+func TestSyslog(t *testing.T) {
+	detect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("203.0.113.7\n"))
+	}))
+	defer detect.Close()
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("good 203.0.113.7"))
+	}))
+	defer api.Close()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"update", "-v", "-syslog", "udp://" + pc.LocalAddr().String(), "-syslog-facility", "local0",
+		"-username", "user", "-password", "pass", "-hostname", "foo.example.com",
+		"-source", detect.URL, "-endpoint", api.URL}, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	if stderr.Len() > 0 {
+		t.Errorf("expected the logs in syslog only, got stderr %q", stderr.String())
+	}
+	buf := make([]byte, 4096)
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// local0 at the debug level of -v
+	if msg := string(buf[:n]); !strings.HasPrefix(msg, "<135>1 ") {
+		t.Errorf("unexpected syslog message %q", msg)
+	}
+
+	for _, args := range [][]string{
+		{"-syslog", "logs.example.com"},
+		{"-syslog", "local", "-log-file", "ddns.log"},
+		{"-syslog", "local", "-syslog-facility", "local8"},
+	} {
+		stderr.Reset()
+		if code := run(append([]string{"update", "-username", "user", "-password", "pass"}, args...), &stdout, &stderr); code != exitUsage {
+			t.Errorf("%v: expected a usage error, got exit code %d: %s", args, code, stderr.String())
+		}
+	}
+}
+
+func TestDebugLogs(t *testing.T) {
+	detect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("203.0.113.7\n"))
+	}))
+	defer detect.Close()
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("good 203.0.113.7"))
+	}))
+	defer api.Close()
+	for _, tc := range []struct {
+		flags      []string
+		want, skip []string
+	}{
+		{[]string{"-v"}, []string{`"component":"ipdetect"`}, []string{`"component":"dynu"`}},
+		{[]string{"-vv"}, []string{`"component":"ipdetect"`, `password=REDACTED`, `"msg":"200 OK: \"good 203.0.113.7\""`, `"status_code":200`}, []string{"pass&"}},
+		{[]string{"-vv", "-debug", "provider"}, []string{`"level":"DEBUG","msg":"GET `}, []string{`"component":"ipdetect"`}},
+		{[]string{"-debug", "detection"}, []string{`"component":"ipdetect"`}, []string{`"component":"dynu"`}},
+	} {
+		var stdout, stderr bytes.Buffer
+		args := append([]string{"update", "-log-format", "json", "-username", "user", "-password", "pass",
+			"-hostname", "foo.example.com", "-source", detect.URL, "-endpoint", api.URL}, tc.flags...)
+		if code := run(args, &stdout, &stderr); code != exitOK {
+			t.Fatalf("%v: exit code %d: %s", tc.flags, code, stderr.String())
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(stdout.String(), want) {
+				t.Errorf("%v: expected %s in the logs:\n%s", tc.flags, want, stdout.String())
+			}
+		}
+		for _, skip := range tc.skip {
+			if strings.Contains(stdout.String(), skip) {
+				t.Errorf("%v: unexpected %s in the logs:\n%s", tc.flags, skip, stdout.String())
+			}
+		}
+	}
+	var stderr bytes.Buffer
+	if code := run([]string{"update", "-debug", "network", "-username", "user", "-password", "pass"}, io.Discard, &stderr); code != exitUsage {
+		t.Errorf("expected a usage error for an unknown component, got %d: %s", code, stderr.String())
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	for _, tc := range []struct {
+		shell string
+		want  []string
+	}{
+		{"bash", []string{"complete -F _ddns ddns", "export import", "-event-log-max-size", "ddns completion profiles"}},
+		{"zsh", []string{"compdef _ddns ddns", "'-oneshot:exit with a code describing the outcome, for cron jobs and monitoring (see below)'"}},
+		{"fish", []string{"-o profile -x -a '(__ddns_profiles)'", "-o source -x -a 'ipify stun hook interface'"}},
+	} {
+		var stdout, stderr bytes.Buffer
+		if code := run([]string{"completion", tc.shell}, &stdout, &stderr); code != exitOK {
+			t.Fatalf("%s: exit code %d: %s", tc.shell, code, stderr.String())
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(stdout.String(), want) {
+				t.Errorf("%s: expected %q in the script", tc.shell, want)
+			}
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "ddns.yaml")
+	os.WriteFile(path, []byte(`
+providers:
+  home: {type: dynu, username: user, password: pass}
+profiles:
+  office:
+    groups:
+      vpn: {providers: [home], hostnames: [vpn.example.net]}
+`), 0o600)
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"completion", "profiles", path}, &stdout, &stderr); code != exitOK || stdout.String() != "office\n" {
+		t.Errorf("unexpected profiles %q, exit code %d: %s", stdout.String(), code, stderr.String())
+	}
+}
+
+func TestInit(t *testing.T) {
+	var queries []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		if r.URL.Query().Get("hostname") == "typo.example.com" {
+			w.Write([]byte("nohost"))
+			return
+		}
+		w.Write([]byte("nochg"))
+	}))
+	defer api.Close()
+	defer func(r io.Reader) { stdin = r }(stdin)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ddns.yaml")
+
+	stdin = strings.NewReader("\nuser\npa\"ss\nhome.example.com, www.example.com\ny\n")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"init", "-config", path, "-endpoint", api.URL}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("exit code %d: %s%s", code, stdout.String(), stderr.String())
+	}
+	if len(queries) != 1 || !strings.Contains(queries[0], "myip=no") {
+		t.Errorf("expected a single verification that publishes nothing, got %q", queries)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && fi.Mode().Perm() != 0o600 {
+		t.Errorf("expected the file holding the password to be private, got %v", fi.Mode().Perm())
+	}
+	c, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := c.Providers["dynu"]; p.Username != "user" || p.Password != `pa"ss` || !c.EnableIPv6() || len(c.Hosts()) != 2 {
+		t.Errorf("unexpected configuration %+v", c)
+	}
+
+	// rejected hostnames are only written if confirmed
+	stdin = strings.NewReader("dynu\nuser\npass\ntypo.example.com\n\nn\n")
+	stdout.Reset()
+	if code := run([]string{"init", "-config", path, "-endpoint", api.URL, "-force"}, &stdout, &stderr); code != exitFailure {
+		t.Fatalf("expected exit code %d, got %d", exitFailure, code)
+	}
+	if !strings.Contains(stdout.String(), "nohost") {
+		t.Errorf("expected the rejection to be shown, got %s", stdout.String())
+	}
+	if c, err := config.Load(path); err != nil || len(c.Hosts()) != 2 {
+		t.Errorf("expected the existing file to be kept, got %v", err)
+	}
+	if code := run([]string{"init", "-config", path}, &stdout, &stderr); code != exitFailure {
+		t.Errorf("expected an existing file to be kept without -force, got exit code %d", code)
+	}
+}
+
+func TestImportHosts(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/dns":
+			w.Write([]byte(`{"statusCode":200,"domains":[
+				{"id":1,"name":"home.dynu.net","group":"home","ipv4":true},
+				{"id":2,"name":"Example.com","group":"","ipv4":true,"ipv6":true}]}`))
+		case "/v2/dns/1/record":
+			w.Write([]byte(`{"statusCode":200,"dnsRecords":[]}`))
+		case "/v2/dns/2/record":
+			w.Write([]byte(`{"statusCode":200,"dnsRecords":[
+				{"id":10,"hostname":"www.example.com","nodeName":"www","recordType":"A","state":true},
+				{"id":11,"hostname":"old.example.com","nodeName":"old","recordType":"A","state":false},
+				{"id":12,"hostname":"example.com","nodeName":"","recordType":"MX","state":true}]}`))
+		}
+	}))
+	defer api.Close()
+	t.Setenv("DDNS_API_KEY", "secret")
+	var stdout, stderr bytes.Buffer
+	args := []string{"import-hosts", "dynu", "-endpoint", api.URL, "-records", "-username", "user", "-password-file", "/run/secrets/dynu"}
+	if code := run(args, &stdout, &stderr); code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	c, err := config.Decode(&stdout, config.YAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, h := range c.Hosts() {
+		got = append(got, h.Group+": "+h.Hostname)
+	}
+	want := []string{"imported: example.com", "home: home.dynu.net", "imported: www.example.com"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got hosts %q, want %q", got, want)
+	}
+	if p := c.Providers["dynu"]; p.Username != "user" || p.PasswordFile != "/run/secrets/dynu" || !c.EnableIPv6() {
+		t.Errorf("unexpected configuration %+v", c)
+	}
+}
+
+func TestGC(t *testing.T) {
+	var changes []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("API-Key") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v2/dns":
+			w.Write([]byte(`{"statusCode":200,"domains":[{"id":2,"name":"example.com","ipv4":true}]}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/v2/dns/2/record":
+			w.Write([]byte(`{"statusCode":200,"dnsRecords":[
+				{"id":10,"hostname":"www.example.com","nodeName":"www","recordType":"A","ipv4Address":"203.0.113.7","state":true},
+				{"id":11,"hostname":"old.example.com","nodeName":"old","recordType":"A","ipv4Address":"203.0.113.8","state":true},
+				{"id":12,"hostname":"old.example.com","nodeName":"old","recordType":"TXT","textData":"heritage=ddns,owner=ddns","state":true},
+				{"id":13,"hostname":"mail.example.com","nodeName":"mail","recordType":"A","ipv4Address":"203.0.113.9","state":true},
+				{"id":14,"hostname":"example.com","nodeName":"","recordType":"A","ipv4Address":"203.0.113.7","state":true}]}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/v2/dns/2/record":
+			var rec struct{ NodeName, TextData string }
+			json.NewDecoder(r.Body).Decode(&rec)
+			changes = append(changes, "add "+rec.NodeName+" "+rec.TextData)
+			w.Write([]byte(`{"statusCode":200,"id":15}`))
+		case r.Method == http.MethodDelete:
+			changes = append(changes, "delete "+r.URL.Path)
+			w.Write([]byte(`{"statusCode":200}`))
+		}
+	}))
+	defer api.Close()
+
+	path := filepath.Join(t.TempDir(), "ddns.yaml")
+	cfg := "providers:\n" +
+		"  dynu: {type: dynu, username: user, password: pass, api_key: secret, endpoint: " + api.URL + "}\n" +
+		"  other: {type: dynu, username: user, password: pass}\n" +
+		"groups:\n" +
+		"  web: {providers: [dynu, other], hostnames: [example.com, www.example.com]}\n"
+	if err := os.WriteFile(path, []byte(cfg), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"gc", "-config", path, "-claim", "-dry-run"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	want := "would claim www.example.com (dynu)\n" +
+		"would remove old.example.com A 203.0.113.8 (dynu)\n" +
+		"would remove old.example.com TXT heritage=ddns,owner=ddns (dynu)\n"
+	if stdout.String() != want || len(changes) != 0 {
+		t.Errorf("got %q and changes %v, want %q", stdout.String(), changes, want)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"--output", "json", "gc", "-config", path, "-claim"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	var out collected
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Providers) != 1 || len(out.Providers[0].Claimed) != 1 || len(out.Providers[0].Removed) != 2 {
+		t.Errorf("unexpected output %+v", out)
+	}
+	wantChanges := []string{"add www heritage=ddns,owner=ddns", "delete /v2/dns/2/record/11", "delete /v2/dns/2/record/12"}
+	if !reflect.DeepEqual(changes, wantChanges) {
+		t.Errorf("got changes %q, want %q", changes, wantChanges)
+	}
+}
+
+func TestPlanNotifyDualStack(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ddns.yaml")
+	cfg := "families: [ipv4, ipv6]\n" +
+		"providers:\n" +
+		"  dynu: {type: dynu, username: user, password: pass}\n" +
+		"  spare: {type: dynu, username: user, password: pass}\n" +
+		"notifiers:\n" +
+		"  console: {type: stdout}\n" +
+		"groups:\n" +
+		"  web: {providers: [dynu], backup: spare, notify: [console], hostnames: [example.com]}\n"
+	if err := os.WriteFile(path, []byte(cfg), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	plans, err := loadPlans(path, "", debugLogs{}, &stdout, &stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closePlans(plans)
+	if len(plans) != 1 || len(plans[0].sinks) != 1 || !plans[0].providers[0].SplitFamilies {
+		t.Fatalf("expected a dual-stack plan with a notifier, got %+v", plans)
+	}
+	ctx := context.Background()
+	name := plans[0].providers[0].Name
+	for _, provider := range []string{name + "/ipv4", name + "/backup/ipv6", "other/web/ipv4"} {
+		ev := event.Event{Type: event.Changed, Provider: provider, Hostnames: []string{"example.com"},
+			NewIPs: []net.IP{net.ParseIP("203.0.113.7")}}
+		if err := plans[0].sinks[0].Handle(ctx, ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := strings.Count(stdout.String(), "example.com"); n != 2 {
+		t.Errorf("expected the events of each family and of the backup to be notified, got %q", stdout.String())
+	}
+}
+
+func TestGCScope(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ddns.yaml")
+	cfg := "providers:\n" +
+		"  dynu:\n" +
+		"    type: dynu\n" +
+		"    username: user\n" +
+		"    password: pass\n" +
+		"    api_key: secret\n" +
+		"    accounts: [{zones: [example.net], username: net, password: pass, api_key: netsecret}]\n" +
+		"groups:\n" +
+		"  web: {providers: [dynu], hostnames: [WWW.Example.com., example.net]}\n"
+	if err := os.WriteFile(path, []byte(cfg), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keep, providers, err := gcScope(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !keep["www.example.com"] {
+		t.Errorf("expected the hostname to be kept as gc.Collect names it, got %v", keep)
+	}
+	if len(providers) != 2 || providers["dynu"].APIKey != "secret" || len(providers["dynu"].Accounts) != 0 {
+		t.Errorf("expected the provider's own account and each of its accounts, got %+v", providers)
+	}
+	if a := providers["dynu/accounts.0"]; a.Username != "net" || a.APIKey != "netsecret" {
+		t.Errorf("expected the account with its own api_key, got %+v", a)
+	}
+}
+
+func TestCheckCredentials(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/dns" {
+			if r.Header.Get("API-Key") != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"statusCode":401,"type":"Authentication Exception","message":"Invalid API key"}`))
+				return
+			}
+			w.Write([]byte(`{"statusCode":200,"domains":[]}`))
+			return
+		}
+		if r.URL.Query().Get("myip") != "no" {
+			t.Errorf("expected no address to be published, got %s", r.URL.RawQuery)
+		}
+		if r.URL.Query().Get("password") != hashed("pass") {
+			w.Write([]byte("badauth\n"))
+			return
+		}
+		w.Write([]byte("nochg\n"))
+	}))
+	defer srv.Close()
+	path := filepath.Join(t.TempDir(), "ddns.yaml")
+	os.WriteFile(path, []byte(fmt.Sprintf(`
+providers:
+  home: {type: dynu, username: user, password: pass, api_key: secret, endpoint: %[1]q}
+  work: {type: dynu, username: other, password: old, endpoint: %[1]q}
+  spare: {type: dynu, username: spare, password: pass, api_key: stale, endpoint: %[1]q}
+  unused: {type: dynu, username: unused, password: pass, endpoint: %[1]q}
+groups:
+  web: {providers: [home], hostnames: [example.com]}
+  vpn: {providers: [work], hostnames: [vpn.example.com]}
+`, srv.URL)), 0o600)
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"check-credentials", "-config", path}, &stdout, &stderr); code != exitFailure {
+		t.Fatalf("expected the rejected accounts to fail, got exit code %d: %s", code, stderr.String())
+	}
+	want := "home: ok\n" +
+		"spare: failed\n" +
+		"  api_key: dynu: API returned 401: Authentication Exception: Invalid API key\n" +
+		"unused: skipped, not used by any group\n" +
+		"work: failed\n" +
+		"  account \"other\" rejected group \"vpn\": dynu: response return 1 error(s):\n  \t* [0] vpn.example.com: badauth\n"
+	if stdout.String() != want {
+		t.Errorf("got %q, want %q", stdout.String(), want)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"--output", "json", "check-credentials", "-config", path}, &stdout, &stderr); code != exitFailure {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	var out credentialChecks
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Providers) != 4 || !out.Providers[0].OK || out.Providers[3].OK || !out.Providers[2].Skipped {
+		t.Errorf("unexpected output %s", stdout.String())
+	}
+}
+
+func TestWakeOn(t *testing.T) {
+	events := make(chan netwatch.Event)
+	wakes := wakeOn(events, 2, 50*time.Millisecond)
+	for i := 0; i < 3; i++ {
+		events <- netwatch.Event{Kind: netwatch.AddrAdded, Interface: "eth0"}
+	}
+	select {
+	case <-wakes[0]:
+		t.Fatal("expected the wake-up to wait for the changes to settle")
+	default:
+	}
+	for _, wake := range wakes {
+		select {
+		case <-wake:
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected a wake-up once the changes settled")
+		}
+	}
+	select {
+	case <-wakes[0]:
+		t.Error("expected the burst to wake the daemon once")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(events)
+}
+
+func TestWatchInterval(t *testing.T) {
+	flags := daemonSchedule{interval: 5 * time.Minute, watch: true}
+	if got := flags.configured(config.Schedule{}, nil).poll(); got != watchInterval {
+		t.Errorf("expected polling to be a safety net with -watch, got %v", got)
+	}
+	if got := flags.configured(config.Schedule{}, map[string]bool{"interval": true}).poll(); got != 5*time.Minute {
+		t.Errorf("expected -interval to be kept, got %v", got)
+	}
+	if got := flags.configured(config.Schedule{Interval: config.Duration(time.Minute)}, nil).poll(); got != time.Minute {
+		t.Errorf("expected the configured interval to be kept, got %v", got)
+	}
+}
+
+func TestGenMonitoring(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ddns.yaml")
+	os.WriteFile(path, []byte(`
+providers:
+  home: {type: dynu, username: user, password: pass}
+  spare: {type: dynu, username: other, password: pass}
+groups:
+  web: {providers: [home], hostnames: [example.com], refresh: 12h, backup: spare}
+families: [ipv4, ipv6]
+`), 0o600)
+	rules, dashboard := filepath.Join(dir, "rules.yml"), filepath.Join(dir, "dashboard.json")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"gen", "monitoring", "-config", path, "-rules", rules, "-dashboard", dashboard}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	data, err := os.ReadFile(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`provider="home/web/ipv4"} > 86400`, `provider="home/web/ipv6"} > 86400`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected a staleness alert with %s in\n%s", want, data)
+		}
+	}
+	var d struct {
+		Panels []struct{ Type, Title string }
+	}
+	data, err = os.ReadFile(dashboard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &d); err != nil {
+		t.Fatal(err)
+	}
+	var rows []string
+	for _, p := range d.Panels {
+		if p.Type == "row" {
+			rows = append(rows, p.Title)
+		}
+	}
+	want := []string{"home/web/ipv4", "home/web/backup/ipv4", "home/web/ipv6", "home/web/backup/ipv6"}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("got rows %q, want %q", rows, want)
+	}
+}
+
+func TestDialMQTT(t *testing.T) {
+	for _, broker := range []string{"http://localhost:1883", "localhost:1883", "tcp://"} {
+		if _, err := dialMQTT(broker, ""); err == nil {
+			t.Errorf("%s: expected an error", broker)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+)
+
+// outputUsage documents -output, which every command accepts
+const outputUsage = `With -output json, or "ddns --output json <command>", the result is printed to stdout as a single JSON object
+instead of text, including errors as an "error" field. Invalid flags are only reported to stderr, with exit code 2.`
+
+// registerOutput defines -output
+func registerOutput(fs *flag.FlagSet, format *string) {
+	fs.StringVar(format, "output", "text", `format of the result: "text", or "json" for scripts and monitoring`)
+}
+
+// checkOutput reports an unknown -output format to stderr
+func checkOutput(format string, stderr io.Writer) bool {
+	if format != "text" && format != "json" {
+		fmt.Fprintf(stderr, "ddns: unknown -output %q\n", format)
+		return false
+	}
+	return true
+}
+
+// writeJSON prints the result of a command as indented JSON
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// fail reports err to stderr and, in the JSON format, as the result, returning code
+func fail(format string, err error, code int, stdout, stderr io.Writer) int {
+	fmt.Fprintf(stderr, "ddns: %v\n", err)
+	if format == "json" {
+		writeJSON(stdout, struct {
+			Error string `json:"error"`
+		}{err.Error()})
+	}
+	return code
+}
+
+// results is the JSON output of the update, wait and daemon -dry-run commands: one result per profile
+type results struct {
+	Results []planResult `json:"results"`
+}
+
+// planResult is the outcome of a plan: the detected addresses and the outcome for each provider
+type planResult struct {
+	Profile   string           `json:"profile,omitempty"`
+	Detected  []net.IP         `json:"detected,omitempty"`
+	Error     string           `json:"error,omitempty"`
+	Providers []providerResult `json:"providers"`
+}
+
+// providerResult is the outcome for the hostnames of a provider
+type providerResult struct {
+	Name      string   `json:"name"`
+	Hostnames []string `json:"hostnames"`
+	// Status is updated, unchanged, failed, resolved (by ddns wait), or would-update and would-not-update with -dry-run
+	Status string   `json:"status"`
+	IPs    []net.IP `json:"ips,omitempty"`
+	Error  string   `json:"error,omitempty"`
+	// Warnings are the broken CNAME chains of the aliases of the hostnames
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// newPlanResult returns the result of a plan with no provider outcome yet
+func newPlanResult(p *plan) planResult {
+	return planResult{Profile: p.profile, Providers: []providerResult{}}
+}
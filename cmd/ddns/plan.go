@@ -0,0 +1,561 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/justenwalker/ddns"
+	"github.com/justenwalker/ddns/config"
+	"github.com/justenwalker/ddns/daemon"
+	"github.com/justenwalker/ddns/dynu"
+	"github.com/justenwalker/ddns/event"
+	"github.com/justenwalker/ddns/event/mqttsink"
+	"github.com/justenwalker/ddns/hook"
+	"github.com/justenwalker/ddns/ipdetect"
+	"github.com/justenwalker/ddns/ipify"
+	"github.com/justenwalker/ddns/notify"
+	"github.com/justenwalker/ddns/verify"
+)
+
+// plan is what the update and daemon commands act on, built either from flags or from a configuration file
+type plan struct {
+	// profile is the name of the configuration profile, or empty
+	profile string
+	source  ipdetect.Source
+	// sourceTypes are the configured source types, such as "ipify" or "http"
+	sourceTypes []string
+	providers   []daemon.Provider
+	schedule    config.Schedule
+	sinks       []event.Sink
+	// hooks runs the configured hook commands, if any
+	hooks *hook.Runner
+	// aliases are the CNAMEs of the hostnames of each provider, by provider name
+	aliases map[string]map[string]string
+	closers []io.Closer
+}
+
+// Close releases what the plan opened, in reverse order, so that a notifier is flushed before its file is closed
+func (p *plan) Close() error {
+	for i := len(p.closers) - 1; i >= 0; i-- {
+		p.closers[i].Close()
+	}
+	return nil
+}
+
+// label prefixes messages about the plan with its profile, if any
+func (p *plan) label() string {
+	if p.profile == "" {
+		return ""
+	}
+	return fmt.Sprintf("profile %q: ", p.profile)
+}
+
+func closePlans(plans []*plan) {
+	for _, p := range plans {
+		p.Close()
+	}
+}
+
+// loadPlans builds the plans of the configuration file at path: that of the named profile, or without one,
+// that of the top level groups, unless there are none but profiles, followed by that of each profile.
+// The deprecated settings the file was migrated from are reported to stderr.
+func loadPlans(path, profile string, d debugLogs, stdout, stderr io.Writer) ([]*plan, error) {
+	c, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range c.Warnings {
+		fmt.Fprintf(stderr, "ddns: %s: warning: %v\n", position(path, w), w.Err)
+	}
+	names := c.ProfileNames()
+	if profile != "" {
+		names = []string{profile}
+	}
+	var plans []*plan
+	if profile == "" && (len(c.Groups) > 0 || len(names) == 0) {
+		p, err := newPlan(c, "", d, stdout, stderr)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, p)
+	}
+	for _, name := range names {
+		pc, err := c.Profile(name)
+		if err != nil {
+			closePlans(plans)
+			return nil, err
+		}
+		p, err := newPlan(pc, name, d, stdout, stderr)
+		if err != nil {
+			closePlans(plans)
+			return nil, fmt.Errorf("profile %q: %v", name, err)
+		}
+		plans = append(plans, p)
+	}
+	return plans, nil
+}
+
+// newPlan builds the plan of a configuration, or of one of its profiles.
+// Each provider account and group pair becomes a daemon provider named "account/group",
+// or "profile:account/group" in a profile, and its backup, if any, is named with "/backup" appended.
+// The accounts of a group with an ordered rollout become a single provider named "first>second/group".
+func newPlan(c *config.Config, profile string, d debugLogs, stdout, stderr io.Writer) (*plan, error) {
+	var err error
+	prefix := ""
+	if profile != "" {
+		prefix = profile + ":"
+	}
+	p := &plan{profile: profile, schedule: c.Schedule, sourceTypes: []string{"ipify"}}
+	if len(c.Sources) > 0 {
+		p.sourceTypes = nil
+		for _, s := range c.Sources {
+			p.sourceTypes = append(p.sourceTypes, s.Type)
+		}
+	}
+	if p.source, err = configSource(c, d.detection); err != nil {
+		return nil, err
+	}
+	if h := c.Hooks; !h.IsZero() {
+		opts := []hook.Option{hook.Log(d.scheduler)}
+		if h.Timeout > 0 {
+			opts = append(opts, hook.Timeout(time.Duration(h.Timeout)))
+		}
+		p.hooks = hook.New(h.PreDetect, h.OnSuccess, h.OnFailure, opts...)
+	}
+	notified := make(map[string]map[string]bool)
+	rolledOut := make(map[string]bool)
+	for _, t := range c.Targets() {
+		name := prefix + t.Provider + "/" + t.Group
+		var u daemon.Updater
+		if t.Policy.Rollout == "ordered" && len(t.Policy.Providers) > 1 {
+			// the providers of the group are updated together, by the target of the first of them
+			if rolledOut[t.Group] {
+				continue
+			}
+			rolledOut[t.Group] = true
+			name = prefix + strings.Join(t.Policy.Providers, ">") + "/" + t.Group
+			u, err = configRollout(c, t, d)
+		} else {
+			u, err = configUpdater(c, t, d)
+		}
+		if err != nil {
+			return nil, err
+		}
+		provider := daemon.Provider{
+			Name:          name,
+			Hostnames:     t.Hostnames,
+			Updater:       u,
+			Debounce:      time.Duration(t.Policy.Debounce),
+			Refresh:       time.Duration(t.Policy.Refresh),
+			PromoteAfter:  t.Policy.PromoteAfter,
+			SplitFamilies: c.EnableIPv4() && c.EnableIPv6(),
+			IPv4:          familyPolicy(t.Policy.IPv4),
+			IPv6:          familyPolicy(t.Policy.IPv6),
+			Interval:      time.Duration(t.Policy.Interval),
+			Backoff:       time.Duration(t.Policy.Backoff),
+			BackoffMax:    time.Duration(t.Policy.BackoffMax),
+		}
+		if t.Policy.Backup != "" {
+			bt := config.Target{Provider: t.Policy.Backup, Group: t.Group, Hostnames: t.Hostnames, Policy: t.Policy}
+			if len(t.Policy.BackupHostnames) > 0 {
+				bt.Hostnames = t.Policy.BackupHostnames
+			}
+			bu, err := configUpdater(c, bt, d)
+			if err != nil {
+				return nil, err
+			}
+			provider.Backup = &daemon.Provider{Name: name + "/backup", Hostnames: bt.Hostnames, Updater: bu}
+		}
+		p.providers = append(p.providers, provider)
+		if len(t.Aliases) > 0 {
+			if p.aliases == nil {
+				p.aliases = make(map[string]map[string]string)
+			}
+			p.aliases[name] = t.Aliases
+		}
+		for _, n := range t.Policy.Notify {
+			if notified[n] == nil {
+				notified[n] = make(map[string]bool)
+			}
+			notified[n][name] = true
+		}
+	}
+	for name, providers := range notified {
+		if cn := c.Notifiers[name]; cn.Type == "mqtt" {
+			sink, err := configMQTT(cn, p)
+			if err != nil {
+				p.Close()
+				return nil, fmt.Errorf("notifier %q: %v", name, err)
+			}
+			// the addresses are published from the Detected events, which belong to no provider
+			providers[""] = true
+			p.sinks = append(p.sinks, providerFilter(sink, providers))
+			continue
+		}
+		n, err := configNotifier(c.Notifiers[name], stdout, stderr, p)
+		if err != nil {
+			p.Close()
+			return nil, fmt.Errorf("notifier %q: %v", name, err)
+		}
+		n = shapeNotifier(c.Notifiers[name], n, p)
+		p.sinks = append(p.sinks, providerFilter(notify.Sink(n), providers))
+	}
+	return p, nil
+}
+
+func familyPolicy(f config.Family) daemon.FamilyPolicy {
+	return daemon.FamilyPolicy{Debounce: time.Duration(f.Debounce), Refresh: time.Duration(f.Refresh)}
+}
+
+func configSource(c *config.Config, l Logger) (ipdetect.Source, error) {
+	var sources []ipdetect.Source
+	for _, s := range c.Sources {
+		switch s.Type {
+		case "ipify":
+			opts := []ipify.Option{ipify.IPv4(c.EnableIPv4()), ipify.IPv6(c.EnableIPv6())}
+			if l != nil {
+				opts = append(opts, ipify.Log(l))
+			}
+			sources = append(sources, ipify.New(opts...))
+		case "http":
+			var opts []ipdetect.HTTPOption
+			if l != nil {
+				opts = append(opts, ipdetect.HTTPLog(l))
+			}
+			if s.JSONPath != "" {
+				opts = append(opts, ipdetect.JSONPath(s.JSONPath))
+			}
+			if s.Regexp != "" {
+				opts = append(opts, ipdetect.Regexp(regexp.MustCompile(s.Regexp)))
+			}
+			sources = append(sources, ipdetect.NewHTTP(s.URL, opts...))
+		case "stun":
+			sources = append(sources, stunSource(s.Server, c.EnableIPv4(), c.EnableIPv6(), l))
+		case "interface":
+			sources = append(sources, ipdetect.NewInterface(s.Interface,
+				ipdetect.InterfaceIPv4(c.EnableIPv4()), ipdetect.InterfaceIPv6(c.EnableIPv6())))
+		case "exec":
+			var opts []ipdetect.ExecOption
+			if l != nil {
+				opts = append(opts, ipdetect.ExecLog(l))
+			}
+			sources = append(sources, ipdetect.NewExec(s.Command, opts...))
+		}
+	}
+	var src ipdetect.Source
+	switch len(sources) {
+	case 0:
+		src = ipify.New(ipify.IPv4(c.EnableIPv4()), ipify.IPv6(c.EnableIPv6()))
+	case 1:
+		src = sources[0]
+	default:
+		src = ipdetect.Fallback(sources...)
+	}
+	return familySource{src: src, ipv4: c.EnableIPv4(), ipv6: c.EnableIPv6()}, nil
+}
+
+// stunSource queries server over each enabled address family, failing only if every family fails
+func stunSource(server string, ipv4, ipv6 bool, l Logger) ipdetect.Source {
+	if server == "" {
+		server = ipdetect.DefaultSTUNServer
+	}
+	var opts []ipdetect.STUNOption
+	if l != nil {
+		opts = append(opts, ipdetect.STUNLog(l))
+	}
+	var sources []ipdetect.Source
+	if ipv4 {
+		sources = append(sources, ipdetect.NewSTUN(server, append(opts, ipdetect.STUNNetwork("udp4"))...))
+	}
+	if ipv6 {
+		sources = append(sources, ipdetect.NewSTUN(server, append(opts, ipdetect.STUNNetwork("udp6"))...))
+	}
+	return ipdetect.SourceFunc(func(ctx context.Context) ([]net.IP, error) {
+		var ips []net.IP
+		var firstErr error
+		for _, src := range sources {
+			found, err := src.Detect(ctx)
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			ips = append(ips, found...)
+		}
+		if len(ips) == 0 {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("no address family is enabled")
+			}
+			return nil, firstErr
+		}
+		return ips, nil
+	})
+}
+
+func configUpdater(c *config.Config, t config.Target, d debugLogs) (daemon.Updater, error) {
+	account := c.Providers[t.Provider]
+	switch account.Type {
+	case "dynu":
+		clients, err := configDynu(c, t, d)
+		if err != nil {
+			return nil, err
+		}
+		accounts := make([]ddns.Publisher, len(clients))
+		for i, client := range clients {
+			accounts[i] = client
+		}
+		return ddns.Accounts(accounts...), nil
+	}
+	return nil, fmt.Errorf("provider %q: unsupported type %q", t.Provider, account.Type)
+}
+
+// rolloutPoll is how often an ordered rollout looks the hostnames up at the resolver of a provider
+var rolloutPoll = 5 * time.Second
+
+// rolloutResolver returns the resolver querying the name server of a provider; replaced by tests
+var rolloutResolver = func(server string) verify.Resolver {
+	return newResolver(server)
+}
+
+// configRollout returns the updater publishing to the providers of the policy of t one after another,
+// each once the previous one serves the addresses at its resolver
+func configRollout(c *config.Config, t config.Target, d debugLogs) (daemon.Updater, error) {
+	timeout := 2 * time.Minute
+	if t.Policy.RolloutTimeout > 0 {
+		timeout = time.Duration(t.Policy.RolloutTimeout)
+	}
+	r := verify.Rollout{Unchanged: daemon.ErrUnchanged}
+	for _, name := range t.Policy.Providers {
+		st := t
+		st.Provider = name
+		u, err := configUpdater(c, st, d)
+		if err != nil {
+			return nil, err
+		}
+		server := c.Providers[name].Resolver
+		if _, _, err := net.SplitHostPort(server); server != "" && err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+		resolver := rolloutResolver(server)
+		r.Steps = append(r.Steps, verify.RolloutStep{Name: name, Updater: u, Verify: func(ctx context.Context, ips []net.IP) error {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			return verify.WaitConverged(ctx, resolver, t.Hostnames, ips, rolloutPoll)
+		}})
+	}
+	return r, nil
+}
+
+// configDynu returns a client for each account the hostnames of t are updated with
+func configDynu(c *config.Config, t config.Target, d debugLogs) ([]*dynu.Client, error) {
+	var clients []*dynu.Client
+	for _, r := range c.Providers[t.Provider].Route(t.Hostnames) {
+		account := r.Provider
+		password, err := account.Secret()
+		if err != nil {
+			return nil, fmt.Errorf("provider %q: %v", t.Provider, err)
+		}
+		opts := []dynu.Option{
+			dynu.Hostnames(r.Hostnames),
+			dynu.IPv4(c.EnableIPv4()),
+			dynu.IPv6(c.EnableIPv6()),
+		}
+		if account.Endpoint != "" {
+			opts = append(opts, dynu.Endpoint(account.Endpoint))
+		}
+		if b := account.Bootstrap; len(b.Addresses) > 0 || b.DoH != "" {
+			opts = append(opts, dynu.Bootstrap(b.IPs(), b.DoH))
+		}
+		opts = append(opts, d.dynuOptions()...)
+		clients = append(clients, dynu.New(account.Username, password, opts...))
+	}
+	return clients, nil
+}
+
+// dynuOptions returns the options logging the responses of dynu, with -vv its requests, and with -dump-http
+// both of them in full
+func (d debugLogs) dynuOptions() []dynu.Option {
+	var opts []dynu.Option
+	if d.provider != nil {
+		opts = append(opts, dynu.Log(d.provider))
+	}
+	if d.trace != nil {
+		opts = append(opts, dynu.Debug(d.trace))
+	}
+	if d.dump != nil {
+		opts = append(opts, dynu.Dump(d.dump))
+	}
+	return opts
+}
+
+// shapeNotifier applies the deduplication, escalation and digest settings of the notifier, if any.
+// The pending digest is delivered when the plan is closed.
+func shapeNotifier(cn config.Notifier, n notify.Notifier, p *plan) notify.Notifier {
+	var opts []notify.ShapeOption
+	if cn.Dedup > 0 {
+		opts = append(opts, notify.Dedup(time.Duration(cn.Dedup)))
+	}
+	if cn.EscalateAfter > 0 {
+		opts = append(opts, notify.EscalateAfter(cn.EscalateAfter))
+	}
+	if cn.Digest > 0 {
+		opts = append(opts, notify.DigestEvery(time.Duration(cn.Digest)))
+	}
+	if len(opts) == 0 {
+		return n
+	}
+	s := notify.Shape(n, opts...)
+	p.closers = append(p.closers, s)
+	return s
+}
+
+func configNotifier(n config.Notifier, stdout, stderr io.Writer, p *plan) (notify.Notifier, error) {
+	var f notify.Formatter
+	if n.Template != "" {
+		tmpl, err := notify.ParseTemplate(n.Template)
+		if err != nil {
+			return nil, err
+		}
+		f = tmpl
+	}
+	switch n.Type {
+	case "stdout":
+		return notify.Writer(stdout, f), nil
+	case "stderr":
+		return notify.Writer(stderr, f), nil
+	case "file":
+		if n.Path == "" {
+			return nil, fmt.Errorf("path is required")
+		}
+		file, err := os.OpenFile(n.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		p.closers = append(p.closers, file)
+		return notify.Writer(file, f), nil
+	case "webhook", "slack", "discord", "telegram", "ntfy", "gotify", "pushover":
+		opts := []notify.WebhookOption{notify.WebhookFormat(f), notify.Headers(n.Headers)}
+		if n.Secret != "" {
+			opts = append(opts, notify.Secret(n.Secret))
+		}
+		if n.Retries != nil {
+			opts = append(opts, notify.Retries(*n.Retries))
+		}
+		switch n.Type {
+		case "slack":
+			return notify.Slack(n.URL, opts...), nil
+		case "discord":
+			return notify.Discord(n.URL, opts...), nil
+		case "telegram":
+			return notify.Telegram(n.Token, n.Chat, opts...), nil
+		case "ntfy":
+			return notify.Ntfy(n.URL, n.Topic, n.Token, opts...), nil
+		case "gotify":
+			return notify.Gotify(n.URL, n.Token, opts...), nil
+		case "pushover":
+			return notify.Pushover(n.Token, n.User, opts...), nil
+		}
+		return notify.Webhook(n.URL, opts...), nil
+	}
+	return nil, fmt.Errorf("unsupported type %q", n.Type)
+}
+
+// configMQTT connects to the broker of an "mqtt" notifier, which is closed with the plan
+func configMQTT(n config.Notifier, p *plan) (event.Sink, error) {
+	prefix := n.Topic
+	if prefix == "" {
+		prefix = "ddns"
+	}
+	client, err := dialMQTT(n.URL, mqttsink.AvailabilityTopic(prefix))
+	if err != nil {
+		return nil, err
+	}
+	p.closers = append(p.closers, client)
+	var opts []mqttsink.Option
+	if n.Discovery != "" {
+		node, err := os.Hostname()
+		if err != nil {
+			node = "ddns"
+		}
+		opts = append(opts, mqttsink.Discovery(n.Discovery, node))
+	}
+	return mqttsink.New(client, prefix, opts...), nil
+}
+
+// hookSink returns the sink running the hooks of the plan for the events of its providers, or nil without hooks
+func (p *plan) hookSink() event.Sink {
+	if p.hooks == nil {
+		return nil
+	}
+	return event.SinkFunc(func(ctx context.Context, ev event.Event) error {
+		for _, provider := range p.providers {
+			// backups and address families are named after the provider
+			if ev.Provider == provider.Name || strings.HasPrefix(ev.Provider, provider.Name+"/") {
+				return p.hooks.Handle(ctx, ev)
+			}
+		}
+		return nil
+	})
+}
+
+// cnameResolver looks up the CNAME chains of aliases; replaced by tests
+var cnameResolver verify.CNAMEResolver = net.DefaultResolver
+
+// brokenAliases returns the aliases of the named provider whose CNAME chain does not lead to their hostname
+func (p *plan) brokenAliases(ctx context.Context, provider string) []error {
+	var names []string
+	for alias := range p.aliases[provider] {
+		names = append(names, alias)
+	}
+	sort.Strings(names)
+	var errs []error
+	for _, alias := range names {
+		if err := verify.Chain(ctx, cnameResolver, alias, p.aliases[provider][alias]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// aliasSink returns the sink warning about the broken CNAME chains of the aliases of a provider after
+// it is updated, or nil without aliases
+func (p *plan) aliasSink(l Logger) event.Sink {
+	if len(p.aliases) == 0 {
+		return nil
+	}
+	return event.SinkFunc(func(ctx context.Context, ev event.Event) error {
+		if ev.Type != event.Updated {
+			return nil
+		}
+		// each address family is updated as its own provider
+		name := strings.TrimSuffix(strings.TrimSuffix(ev.Provider, "/ipv4"), "/ipv6")
+		for _, err := range p.brokenAliases(ctx, name) {
+			l.Log("ddns: %swarning: %v", p.label(), err)
+		}
+		return nil
+	})
+}
+
+// providerFilter passes on the events of the named providers only, with those of their backups and address
+// families, which are named after them
+func providerFilter(s event.Sink, providers map[string]bool) event.Sink {
+	return event.SinkFunc(func(ctx context.Context, ev event.Event) error {
+		if providers[ev.Provider] {
+			return s.Handle(ctx, ev)
+		}
+		for name := range providers {
+			if name != "" && strings.HasPrefix(ev.Provider, name+"/") {
+				return s.Handle(ctx, ev)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"runtime"
+
+	"github.com/justenwalker/ddns/service"
+)
+
+func runService(args []string, stdout, stderr io.Writer) int {
+	var name, format string
+	fs := newFlagSet("service", stderr)
+	fs.StringVar(&name, "name", "ddns", "service name")
+	registerOutput(fs, &format)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: ddns service install [-name name] [-- daemon flags]")
+		fmt.Fprintln(stderr, "       ddns service uninstall [-name name]")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Installs the daemon as an automatically started service: a Windows service that logs to the event log,")
+		fmt.Fprintln(stderr, "or a launchd daemon on macOS that logs to /Library/Logs/NAME.log and also starts on network changes.")
+		fmt.Fprintln(stderr, "With the -log-file daemon flag, both log to that file instead, rotating it by size and age, and with -syslog to syslog.")
+		fmt.Fprintln(stderr, "The daemon flags are passed to the service on every start; use absolute paths, such as:")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, `  ddns service install -- -config C:\ProgramData\ddns\ddns.yaml -state C:\ProgramData\ddns\state.json`)
+		fmt.Fprintln(stderr, `  sudo ddns service install -- -config /usr/local/etc/ddns.yaml -state /usr/local/var/ddns/state.json`)
+		fmt.Fprintln(stderr)
+		fs.PrintDefaults()
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, outputUsage)
+	}
+	if len(args) == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	action := args[0]
+	if err := fs.Parse(args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if !checkOutput(format, stderr) {
+		return exitUsage
+	}
+	var err error
+	switch action {
+	case "install":
+		daemonArgs := []string{"daemon", "-service", name}
+		if runtime.GOOS == "darwin" {
+			// launchd only starts the daemon on network changes while it is not running
+			daemonArgs = append(daemonArgs, "-watch")
+		}
+		err = service.Install(service.Config{
+			Name:        name,
+			DisplayName: "Dynamic DNS (" + name + ")",
+			Description: "Keeps dynamic DNS records up to date with this host's public IP address.",
+			Args:        append(daemonArgs, fs.Args()...),
+		})
+	case "uninstall":
+		if fs.NArg() != 0 {
+			fs.Usage()
+			return exitUsage
+		}
+		err = service.Uninstall(name)
+	default:
+		fmt.Fprintf(stderr, "ddns: unknown service command %q\n", action)
+		return exitUsage
+	}
+	if err != nil {
+		return fail(format, err, exitFailure, stdout, stderr)
+	}
+	if format == "json" {
+		writeJSON(stdout, struct {
+			Service string `json:"service"`
+			Action  string `json:"action"`
+		}{name, action})
+		return exitOK
+	}
+	fmt.Fprintf(stdout, "%sed service %s\n", action, name)
+	return exitOK
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/justenwalker/ddns/state"
+)
+
+func runState(args []string, stdout, stderr io.Writer) int {
+	var path, output, format string
+	var force bool
+	fs := newFlagSet("state", stderr)
+	fs.StringVar(&path, "state", "", "state file of the daemon (required)")
+	fs.StringVar(&output, "o", "", "write the export to this file instead of stdout")
+	fs.BoolVar(&force, "force", false, "import over existing state")
+	registerOutput(fs, &format)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: ddns state export [flags]")
+		fmt.Fprintln(stderr, "       ddns state import [flags] <file|->")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Exports or imports a portable snapshot of the last published addresses, retry cooldowns and history,")
+		fmt.Fprintln(stderr, "so the daemon can move to another machine without updating every record again.")
+		fmt.Fprintln(stderr)
+		fs.PrintDefaults()
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "An export to stdout is always JSON. With -output json, an export to a file and an import print the")
+		fmt.Fprintln(stderr, "path and the number of providers and history entries written, or an error, as a JSON object.")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, envUsage)
+	}
+	if len(args) == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	action := args[0]
+	if err := fs.Parse(args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if err := applyEnv(fs, os.Getenv); err != nil {
+		fmt.Fprintf(stderr, "ddns: %v\n", err)
+		return exitUsage
+	}
+	if !checkOutput(format, stderr) {
+		return exitUsage
+	}
+	if path == "" {
+		fmt.Fprintln(stderr, "ddns: -state (or DDNS_STATE) is required")
+		return exitUsage
+	}
+	store := state.File{Path: path}
+	switch action {
+	case "export":
+		if fs.NArg() != 0 {
+			fs.Usage()
+			return exitUsage
+		}
+		return exportState(store, output, format, stdout, stderr)
+	case "import":
+		if fs.NArg() != 1 {
+			fs.Usage()
+			return exitUsage
+		}
+		return importState(store, fs.Arg(0), force, format, stdout, stderr)
+	}
+	fmt.Fprintf(stderr, "ddns: unknown state command %q\n", action)
+	return exitUsage
+}
+
+func exportState(store state.File, output, format string, stdout, stderr io.Writer) int {
+	s, err := store.Load()
+	if err != nil {
+		return fail(format, err, exitFailure, stdout, stderr)
+	}
+	if output == "" {
+		// the export is the result
+		if err := s.Write(stdout); err != nil {
+			fmt.Fprintf(stderr, "ddns: %v\n", err)
+			return exitFailure
+		}
+		return exitOK
+	}
+	if err := (state.File{Path: output}).Save(s); err != nil {
+		return fail(format, err, exitFailure, stdout, stderr)
+	}
+	if format == "json" {
+		writeJSON(stdout, newStateResult(output, s))
+	}
+	return exitOK
+}
+
+func importState(store state.File, input string, force bool, format string, stdout, stderr io.Writer) int {
+	var r io.Reader = os.Stdin
+	if input != "-" {
+		f, err := os.Open(input)
+		if err != nil {
+			return fail(format, err, exitFailure, stdout, stderr)
+		}
+		defer f.Close()
+		r = f
+	}
+	s, err := state.Read(r)
+	if err != nil {
+		return fail(format, fmt.Errorf("%s: %v", input, err), exitFailure, stdout, stderr)
+	}
+	if !force {
+		existing, err := store.Load()
+		if err != nil {
+			return fail(format, err, exitFailure, stdout, stderr)
+		}
+		if len(existing.Providers) > 0 || len(existing.History) > 0 {
+			return fail(format, fmt.Errorf("%s already has state; use -force to replace it", store.Path), exitFailure, stdout, stderr)
+		}
+	}
+	if err := store.Save(s); err != nil {
+		return fail(format, err, exitFailure, stdout, stderr)
+	}
+	if format == "json" {
+		writeJSON(stdout, newStateResult(store.Path, s))
+	}
+	return exitOK
+}
+
+// stateResult is the JSON output of ddns state import, and of an export to a file
+type stateResult struct {
+	Path      string `json:"path"`
+	Providers int    `json:"providers"`
+	History   int    `json:"history"`
+}
+
+func newStateResult(path string, s *state.Snapshot) stateResult {
+	return stateResult{Path: path, Providers: len(s.Providers), History: len(s.History)}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/justenwalker/ddns/control"
+	"github.com/justenwalker/ddns/state"
+)
+
+// status is the JSON output of ddns status
+type status struct {
+	// PID and Started are those of the daemon answering on the -control-socket
+	PID        int              `json:"pid,omitempty"`
+	Started    *time.Time       `json:"started,omitempty"`
+	Detected   []net.IP         `json:"detected,omitempty"`
+	DetectedAt time.Time        `json:"detected_at,omitempty"`
+	NextRun    time.Time        `json:"next_run,omitempty"`
+	Overdue    bool             `json:"overdue,omitempty"`
+	Providers  []providerStatus `json:"providers"`
+}
+
+type providerStatus struct {
+	Name string `json:"name"`
+	state.Provider
+}
+
+// overdueAfter is how late the next run may be before the daemon is reported as not running
+const overdueAfter = time.Minute
+
+func runStatus(args []string, stdout, stderr io.Writer) int {
+	var path, socket string
+	var asJSON bool
+	var format string
+	fs := newFlagSet("status", stderr)
+	fs.StringVar(&path, "state", "", "state file of the daemon (required outside Termux, unless -control-socket is set)")
+	fs.StringVar(&socket, "control-socket", "", "ask the running daemon for its status on this socket, set by ddns daemon -control-socket, instead of reading the -state file")
+	fs.BoolVar(&asJSON, "json", false, "print the status as JSON; the same as -output json")
+	registerOutput(fs, &format)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: ddns status [flags]")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Shows the last detected address, the last successful update and last error of each provider,")
+		fmt.Fprintln(stderr, "and when the daemon runs next, as saved in the state file of ddns daemon -state, or as reported by")
+		fmt.Fprintln(stderr, "the daemon itself on its -control-socket.")
+		fmt.Fprintln(stderr)
+		fs.PrintDefaults()
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, outputUsage)
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, envUsage)
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	if err := applyEnv(fs, os.Getenv); err != nil {
+		fmt.Fprintf(stderr, "ddns: %v\n", err)
+		return exitUsage
+	}
+	if !checkOutput(format, stderr) {
+		return exitUsage
+	}
+	if asJSON {
+		format = "json"
+	}
+	if socket != "" {
+		if !checkControlSocket(stderr) {
+			return exitUsage
+		}
+		return daemonStatus(socket, format, stdout, stderr)
+	}
+	if path == "" && isTermux() {
+		var err error
+		if path, err = termuxStatePath(); err != nil {
+			return fail(format, err, exitFailure, stdout, stderr)
+		}
+	}
+	if path == "" {
+		fmt.Fprintln(stderr, "ddns: -state (or DDNS_STATE) is required")
+		return exitUsage
+	}
+	s, err := state.File{Path: path}.Load()
+	if err != nil {
+		return fail(format, err, exitFailure, stdout, stderr)
+	}
+	return printStatus(newStatus(s, time.Now()), format, stdout, stderr)
+}
+
+// daemonStatus prints the status reported by the daemon listening on socket
+func daemonStatus(socket, format string, stdout, stderr io.Writer) int {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ds, err := control.NewClient(socket).Status(ctx)
+	if err != nil {
+		return fail(format, err, exitFailure, stdout, stderr)
+	}
+	s := ds.State
+	if s == nil {
+		s = state.New()
+	}
+	st := newStatus(s, time.Now())
+	st.PID, st.Started = ds.PID, &ds.Started
+	return printStatus(st, format, stdout, stderr)
+}
+
+func printStatus(st status, format string, stdout, stderr io.Writer) int {
+	if format == "json" {
+		if err := writeJSON(stdout, st); err != nil {
+			fmt.Fprintf(stderr, "ddns: %v\n", err)
+			return exitFailure
+		}
+		return exitOK
+	}
+	st.print(stdout)
+	return exitOK
+}
+
+func newStatus(s *state.Snapshot, now time.Time) status {
+	st := status{
+		Detected:   s.Detected,
+		DetectedAt: s.DetectedAt,
+		NextRun:    s.NextRun,
+		Overdue:    !s.NextRun.IsZero() && now.Sub(s.NextRun) > overdueAfter,
+		Providers:  []providerStatus{},
+	}
+	for name, p := range s.Providers {
+		st.Providers = append(st.Providers, providerStatus{Name: name, Provider: p})
+	}
+	sort.Slice(st.Providers, func(i, j int) bool { return st.Providers[i].Name < st.Providers[j].Name })
+	return st
+}
+
+func (st status) print(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if st.Started != nil {
+		fmt.Fprintf(tw, "Daemon:\trunning since %s\tprocess %d\n", formatTime(*st.Started), st.PID)
+	}
+	if st.DetectedAt.IsZero() && len(st.Providers) == 0 {
+		tw.Flush()
+		if st.Started != nil {
+			fmt.Fprintln(w, "The daemon has not finished its first step yet.")
+		} else {
+			fmt.Fprintln(w, "No state saved yet: is the daemon running with -state?")
+		}
+		return
+	}
+	if !st.DetectedAt.IsZero() {
+		fmt.Fprintf(tw, "Detected:\t%s\tat %s\n", ipsOrNone(st.Detected), formatTime(st.DetectedAt))
+	}
+	if !st.NextRun.IsZero() {
+		next := formatTime(st.NextRun)
+		if st.Overdue {
+			next += " (overdue: the daemon is probably not running)"
+		}
+		fmt.Fprintf(tw, "Next run:\t%s\n", next)
+	}
+	tw.Flush()
+	for _, p := range st.Providers {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "%s", p.Name)
+		if len(p.Hostnames) > 0 {
+			fmt.Fprintf(w, " (%s)", strings.Join(p.Hostnames, ", "))
+		}
+		if p.Promoted {
+			fmt.Fprint(w, " [backup promoted]")
+		}
+		fmt.Fprintln(w)
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		if p.UpdatedAt.IsZero() {
+			fmt.Fprintf(tw, "  Published:\tnever\n")
+		} else {
+			fmt.Fprintf(tw, "  Published:\t%s\tat %s\n", ipsOrNone(p.IPs), formatTime(p.UpdatedAt))
+		}
+		if p.Failures > 0 {
+			fmt.Fprintf(tw, "  Failing:\t%d consecutive failure(s)\tretrying at %s\n", p.Failures, formatTime(p.RetryAt))
+		}
+		if p.LastError != "" {
+			fmt.Fprintf(tw, "  Last error:\t%s\tat %s\n", p.LastError, formatTime(p.LastErrorAt))
+		}
+		tw.Flush()
+	}
+}
+
+// ipsOrNone formats ips, or "none" if there are none
+func ipsOrNone(ips []net.IP) string {
+	if len(ips) == 0 {
+		return "none"
+	}
+	return joinIPs(ips)
+}
+
+func formatTime(t time.Time) string {
+	return t.Local().Format(time.RFC3339)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/justenwalker/ddns/control"
+	"github.com/justenwalker/ddns/internal/atomicfile"
+	"github.com/justenwalker/ddns/logging"
+	"github.com/justenwalker/ddns/state"
+)
+
+// statusFile writes the status of the daemons to the -status-file after each of their steps, in the JSON format of
+// ddns status -output json, so dashboards and scripts can read it without a listener
+type statusFile struct {
+	path   string
+	logger Logger
+	// daemons merges the snapshots of the daemons, as for the control socket
+	daemons *control.Server
+
+	// mu serializes the writes of the daemons
+	mu sync.Mutex
+}
+
+func newStatusFile(path string, l Logger) *statusFile {
+	return &statusFile{path: path, logger: l, daemons: control.New()}
+}
+
+// Step returns the function for a daemon to call after each of its steps, with daemon.AfterStep
+func (f *statusFile) Step() func(ctx context.Context, snap *state.Snapshot) {
+	step := f.daemons.Step()
+	return func(ctx context.Context, snap *state.Snapshot) {
+		step(ctx, snap)
+		if err := f.write(); err != nil {
+			logging.Print(f.logger, slog.LevelWarn, []slog.Attr{slog.String("error", err.Error())}, "ddns: status file: %v", err)
+		}
+	}
+}
+
+// write replaces the file with the current status, readable by other users
+func (f *statusFile) write() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ds := f.daemons.Status()
+	s := ds.State
+	if s == nil {
+		s = state.New()
+	}
+	st := newStatus(s, time.Now())
+	st.PID, st.Started = ds.PID, &ds.Started
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, st); err != nil {
+		return err
+	}
+	return atomicfile.Write(f.path, buf.Bytes(), 0o644)
+}
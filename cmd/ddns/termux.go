@@ -0,0 +1,29 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// termuxSources are the source types that work for an unprivileged Android app:
+// other sources need interface details or host tooling that Android hides
+var termuxSources = map[string]bool{"ipify": true, "http": true, "stun": true}
+
+// isTermux returns whether ddns is running inside Termux on Android
+func isTermux() bool {
+	return os.Getenv("TERMUX_VERSION") != ""
+}
+
+// termuxStatePath returns the default state file under the home directory, which is private to the Termux app,
+// creating its directory if necessary
+func termuxStatePath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(home, ".local", "state", "ddns")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "state.json"), nil
+}
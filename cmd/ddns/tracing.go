@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/justenwalker/ddns/trace"
+)
+
+// newTracer returns the tracer exporting to the OTLP endpoint, or to that of the standard OpenTelemetry
+// environment variables if it is empty, or nil if neither is set
+func newTracer(endpoint string, getenv func(string) string, l Logger) (*trace.Tracer, error) {
+	if endpoint == "" {
+		endpoint = getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	}
+	if base := getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); endpoint == "" && base != "" {
+		endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+	}
+	if endpoint == "" {
+		return nil, nil
+	}
+	var opts []trace.OTLPOption
+	if name := getenv("OTEL_SERVICE_NAME"); name != "" {
+		opts = append(opts, trace.Service(name))
+	}
+	if h := getenv("OTEL_EXPORTER_OTLP_HEADERS"); h != "" {
+		headers, err := otlpHeaders(h)
+		if err != nil {
+			return nil, fmt.Errorf("OTEL_EXPORTER_OTLP_HEADERS: %w", err)
+		}
+		opts = append(opts, trace.Headers(headers))
+	}
+	exp, err := trace.OTLP(endpoint, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return trace.New(exp, trace.Log(l)), nil
+}
+
+// otlpHeaders parses headers in the format of OTEL_EXPORTER_OTLP_HEADERS, such as "api-key=secret,x-team=ops",
+// with URL encoded values
+func otlpHeaders(s string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, kv := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(kv, "=")
+		if k = strings.TrimSpace(k); !ok || k == "" {
+			return nil, fmt.Errorf("%q is not a key=value pair", kv)
+		}
+		v, err := url.QueryUnescape(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", k, err)
+		}
+		headers[k] = v
+	}
+	return headers, nil
+}
@@ -0,0 +1,823 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/justenwalker/ddns"
+	"github.com/justenwalker/ddns/audit"
+	"github.com/justenwalker/ddns/budget"
+	"github.com/justenwalker/ddns/config"
+	"github.com/justenwalker/ddns/daemon"
+	"github.com/justenwalker/ddns/dynu"
+	"github.com/justenwalker/ddns/event"
+	"github.com/justenwalker/ddns/hook"
+	"github.com/justenwalker/ddns/internal/pidlock"
+	"github.com/justenwalker/ddns/internal/rotate"
+	"github.com/justenwalker/ddns/ipdetect"
+	"github.com/justenwalker/ddns/ipify"
+	"github.com/justenwalker/ddns/logging/syslog"
+	"github.com/justenwalker/ddns/verify"
+)
+
+type updateFlags struct {
+	config    string
+	profile   string
+	provider  string
+	hostnames stringList
+	location  string
+	username  string
+	password  string
+	endpoint  string
+	source    string
+	iface     string
+	ipv4      bool
+	ipv6      bool
+	timeout   time.Duration
+	verbosity verbosity
+	debug     string
+	dumpHTTP  bool
+	logFormat string
+	logFile   string
+	logSize   int64
+	logAge    time.Duration
+	logKeep   int
+	syslog    string
+	facility  string
+	output    string
+	dryRun    bool
+	pidFile   string
+	canary    string
+	canaryDNS string
+	canaryMax time.Duration
+	ports     intList
+	probeURL  string
+	bootIPs   stringList
+	bootDoH   string
+	auditPath string
+	// audit is the -audit-log, opened by openAuditLog
+	audit *audit.Log
+	// logOut is the -log-file, opened by openLogFile
+	logOut *rotate.File
+	// sysOut is the -syslog, opened by openLogFile
+	sysOut *syslog.Logger
+	// budget, when set by the daemon, defers verification on metered networks. It is read when updating,
+	// so it may be set after the plan is built.
+	budget *budget.Budget
+	// rollback is set by the daemon when it verifies the addresses itself, to roll them back
+	rollback bool
+}
+
+// register defines the flags shared by the update and daemon commands
+func (f *updateFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.config, "config", "", "YAML, TOML or JSON configuration file; replaces the provider, hostname and source flags")
+	fs.StringVar(&f.profile, "profile", "", "only act on this profile of the -config file (default the top level groups and every profile)")
+	fs.StringVar(&f.provider, "provider", "dynu", "DNS provider to update")
+	fs.Var(&f.hostnames, "hostname", "hostname to update; may be repeated")
+	fs.StringVar(&f.location, "location", "", "update every hostname in this location instead of -hostname")
+	fs.StringVar(&f.username, "username", "", "provider username")
+	fs.StringVar(&f.password, "password", "", "provider password; prefer DDNS_PASSWORD to keep it out of the process list")
+	fs.StringVar(&f.endpoint, "endpoint", "", "override the provider API endpoint")
+	fs.Var(&f.bootIPs, "bootstrap-ip", "connect to the provider API endpoint at this address instead of resolving it, for networks whose DNS is broken; may be repeated")
+	fs.StringVar(&f.bootDoH, "bootstrap-doh", "", "resolve the provider API endpoint with this DNS over HTTPS server first, such as https://1.1.1.1/dns-query")
+	fs.StringVar(&f.source, "source", "ipify", `address source: "ipify", "stun", "stun:HOST[:PORT]", "hook", "interface" or an http(s) URL`)
+	fs.StringVar(&f.iface, "interface", "", `interface to read addresses from with -source interface`)
+	fs.BoolVar(&f.ipv4, "ipv4", true, "publish the IPv4 address")
+	fs.BoolVar(&f.ipv6, "ipv6", false, "publish the IPv6 address")
+	fs.DurationVar(&f.timeout, "timeout", 30*time.Second, "time limit for detecting and publishing the address")
+	fs.Var(&f.verbosity, "v", "log what the address sources, providers and scheduler do; repeat, or use -vv, to also log the requests and responses of the providers")
+	fs.BoolFunc("vv", "same as -v -v", func(s string) error {
+		if on, err := strconv.ParseBool(s); err != nil || on {
+			f.verbosity = 2
+		}
+		return nil
+	})
+	fs.StringVar(&f.debug, "debug", "", `only log the components in this comma-separated list with -v or -vv, among "detection", "provider" and "scheduler"; implies -v`)
+	fs.BoolVar(&f.dumpHTTP, "dump-http", false, "log the requests and responses of the providers in full, with their headers and bodies, redacting the passwords, tokens and hashed credentials so the logs can be attached to support tickets; implies -vv")
+	fs.StringVar(&f.logFormat, "log-format", "text", `"text" logs to stderr; "json" logs one JSON object per line to stdout, for container log collectors`)
+	fs.StringVar(&f.logFile, "log-file", "", "write logs to this file instead of stderr or stdout, rotating it by size and age (overrides the configured log)")
+	fs.Int64Var(&f.logSize, "log-max-size", 10<<20, "rotate the -log-file before it grows beyond this many bytes (0 disables it)")
+	fs.DurationVar(&f.logAge, "log-max-age", 0, "also rotate the -log-file every period of this duration, such as 24h for each UTC day")
+	fs.IntVar(&f.logKeep, "log-keep", 5, "number of rotated -log-file files to keep (0 keeps all of them)")
+	fs.StringVar(&f.syslog, "syslog", "", `log to syslog instead, in the RFC 5424 format: "local", or a remote udp://host[:port] or tcp://host[:port] server (overrides the configured log)`)
+	fs.StringVar(&f.facility, "syslog-facility", "daemon", "syslog facility, such as local0")
+	registerOutput(fs, &f.output)
+	fs.StringVar(&f.canary, "canary", "", "update and verify this hostname before the other -hostname values")
+	fs.DurationVar(&f.canaryMax, "canary-wait", 0, "with -canary, wait up to this long for the canary to resolve to the new address before updating the other hostnames (default not looked up)")
+	fs.StringVar(&f.canaryDNS, "canary-resolver", "", "with -canary-wait, DNS server to look the canary up on as host:port, such as the provider's name server (default the system resolver)")
+	fs.Var(&f.ports, "verify-port", "TCP port that must be reachable on the new address; may be repeated")
+	fs.StringVar(&f.probeURL, "probe-url", "", "external probe URL for -verify-port, with {ip} and {port} placeholders (default dials directly)")
+}
+
+// registerDryRun defines -dry-run, for the commands that publish addresses
+func (f *updateFlags) registerDryRun(fs *flag.FlagSet) {
+	fs.BoolVar(&f.dryRun, "dry-run", false, "detect the address and show what would be updated, without sending anything to the providers")
+}
+
+// registerAuditLog defines -audit-log, for the commands that publish addresses
+func (f *updateFlags) registerAuditLog(fs *flag.FlagSet) {
+	fs.StringVar(&f.auditPath, "audit-log", "", "append every change of the detected address and every update attempt to this file, which is never rotated; see ddns history")
+}
+
+// openAuditLog opens the -audit-log, if any, unless this is a dry run
+func (f *updateFlags) openAuditLog() error {
+	if f.auditPath == "" || f.dryRun {
+		return nil
+	}
+	l, err := audit.Open(f.auditPath)
+	if err != nil {
+		return err
+	}
+	f.audit = l
+	return nil
+}
+
+// closeAuditLog closes the -audit-log, if it was opened
+func (f *updateFlags) closeAuditLog() {
+	if f.audit != nil {
+		f.audit.Close()
+	}
+}
+
+// recordAudit appends ev to the -audit-log, if any, reporting a failure to stderr
+func (f *updateFlags) recordAudit(ev event.Event, stderr io.Writer) {
+	if f.audit == nil {
+		return
+	}
+	if err := f.audit.Handle(context.Background(), ev); err != nil {
+		fmt.Fprintf(stderr, "ddns: audit log: %v\n", err)
+	}
+}
+
+// registerPIDFile defines -pid-file, for the commands that publish addresses
+func (f *updateFlags) registerPIDFile(fs *flag.FlagSet, usage string) {
+	fs.StringVar(&f.pidFile, "pid-file", "", usage)
+}
+
+// validate checks the parsed flags, reporting problems to stderr
+func (f *updateFlags) validate(stderr io.Writer) bool {
+	if f.logFormat != "text" && f.logFormat != "json" {
+		fmt.Fprintf(stderr, "ddns: unknown -log-format %q\n", f.logFormat)
+		return false
+	}
+	if !checkOutput(f.output, stderr) {
+		return false
+	}
+	if f.syslog != "" && f.logFile != "" {
+		fmt.Fprintln(stderr, "ddns: -syslog cannot be used with -log-file")
+		return false
+	}
+	if _, _, err := syslog.ParseAddress(f.syslog); f.syslog != "" && err != nil {
+		fmt.Fprintf(stderr, "ddns: -syslog: %v\n", err)
+		return false
+	}
+	if _, err := syslog.ParseFacility(f.facility); err != nil {
+		fmt.Fprintf(stderr, "ddns: -syslog-facility: %v\n", err)
+		return false
+	}
+	for _, c := range f.components() {
+		if !contains(debugComponents, c) {
+			fmt.Fprintf(stderr, "ddns: unknown -debug component %q\n", c)
+			return false
+		}
+	}
+	if f.config != "" {
+		return true
+	}
+	if f.profile != "" {
+		fmt.Fprintln(stderr, "ddns: -profile requires -config")
+		return false
+	}
+	if f.provider != "dynu" {
+		fmt.Fprintf(stderr, "ddns: unsupported provider %q\n", f.provider)
+		return false
+	}
+	if f.canary != "" && f.location != "" {
+		fmt.Fprintln(stderr, "ddns: -canary cannot be used with -location")
+		return false
+	}
+	for _, a := range f.bootIPs {
+		if net.ParseIP(a) == nil {
+			fmt.Fprintf(stderr, "ddns: invalid -bootstrap-ip %q\n", a)
+			return false
+		}
+	}
+	if u, err := url.Parse(f.bootDoH); f.bootDoH != "" && (err != nil || net.ParseIP(u.Hostname()) == nil) {
+		fmt.Fprintf(stderr, "ddns: -bootstrap-doh %q must be a URL with a literal address as its host\n", f.bootDoH)
+		return false
+	}
+	if f.username == "" || f.password == "" {
+		fmt.Fprintln(stderr, "ddns: -username and -password (or DDNS_USERNAME and DDNS_PASSWORD) are required")
+		return false
+	}
+	return true
+}
+
+// verbosity is the level of -v, which may be repeated, or set to a level such as DDNS_V=2
+type verbosity int
+
+func (v *verbosity) String() string {
+	if v == nil {
+		return "0"
+	}
+	return strconv.Itoa(int(*v))
+}
+
+func (v *verbosity) Set(s string) error {
+	if on, err := strconv.ParseBool(s); err == nil {
+		if !on {
+			*v = 0
+		} else {
+			*v++
+		}
+		return nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid verbosity %q", s)
+	}
+	*v = verbosity(n)
+	return nil
+}
+
+func (v *verbosity) IsBoolFlag() bool {
+	return true
+}
+
+// debugComponents are the components -debug selects
+var debugComponents = []string{"detection", "provider", "scheduler"}
+
+// debugLogs are the debug loggers of each component, nil for those that do not log
+type debugLogs struct {
+	detection, provider, scheduler Logger
+	// trace logs the requests and responses of the providers, with -vv
+	trace Logger
+	// dump logs the requests and responses of the providers in full, with -dump-http
+	dump Logger
+}
+
+// components returns the components of -debug
+func (f *updateFlags) components() []string {
+	var components []string
+	for _, c := range strings.Split(f.debug, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			components = append(components, c)
+		}
+	}
+	return components
+}
+
+// debugLogs returns the loggers of the components enabled by -v, -vv, -debug and -dump-http, which log to l at
+// the debug level
+func (f *updateFlags) debugLogs(l Logger) debugLogs {
+	level := int(f.verbosity)
+	components := f.components()
+	if len(components) == 0 {
+		components = debugComponents
+	} else if level == 0 {
+		level = 1
+	}
+	if f.dumpHTTP && level < 2 {
+		level = 2
+	}
+	var d debugLogs
+	if level == 0 {
+		return d
+	}
+	l = debugLogger(l)
+	for _, c := range components {
+		switch c {
+		case "detection":
+			d.detection = l
+		case "provider":
+			d.provider = l
+			switch {
+			case f.dumpHTTP:
+				d.dump = l
+			case level > 1:
+				d.trace = l
+			}
+		case "scheduler":
+			d.scheduler = l
+		}
+	}
+	return d
+}
+
+// openLogFile opens the -log-file or connects to the -syslog, or those of the -config file, if any; the flags
+// set in fs take precedence over the configuration. The caller closes it with closeLogFile.
+func (f *updateFlags) openLogFile(fs *flag.FlagSet) error {
+	if f.config != "" {
+		// a configuration that fails to load is reported when its plans are built
+		if c, err := config.Load(f.config); err == nil {
+			f.configuredLog(c.Log, fs)
+		}
+	}
+	if f.syslog != "" {
+		// checked by validate, or by the configuration
+		facility, err := syslog.ParseFacility(f.facility)
+		if err != nil {
+			return err
+		}
+		f.sysOut, err = syslog.Dial(f.syslog, syslog.Facility(facility))
+		return err
+	}
+	if f.logFile == "" {
+		return nil
+	}
+	var err error
+	f.logOut, err = rotate.Open(f.logFile, rotate.MaxSize(f.logSize), rotate.MaxAge(f.logAge), rotate.Keep(f.logKeep))
+	return err
+}
+
+// configuredLog applies the settings of l for the -log-* flags not set in fs
+func (f *updateFlags) configuredLog(l config.Log, fs *flag.FlagSet) {
+	set := make(map[string]bool)
+	fs.Visit(func(fl *flag.Flag) { set[fl.Name] = true })
+	// a destination set on the command line replaces the configured one
+	if l.File != "" && !set["log-file"] && !set["syslog"] {
+		f.logFile = l.File
+	}
+	if l.Syslog != "" && !set["syslog"] && !set["log-file"] {
+		f.syslog = l.Syslog
+	}
+	if l.Facility != "" && !set["syslog-facility"] {
+		f.facility = l.Facility
+	}
+	if l.MaxSize != nil && !set["log-max-size"] {
+		f.logSize = *l.MaxSize
+	}
+	if l.MaxAge > 0 && !set["log-max-age"] {
+		f.logAge = time.Duration(l.MaxAge)
+	}
+	if l.Keep != nil && !set["log-keep"] {
+		f.logKeep = *l.Keep
+	}
+}
+
+func (f *updateFlags) closeLogFile() {
+	if f.logOut != nil {
+		f.logOut.Close()
+	}
+	if f.sysOut != nil {
+		f.sysOut.Close()
+	}
+}
+
+// newLogger returns a logger in the -log-format. Logs go to the -syslog or the -log-file if it is open.
+// Otherwise, JSON logs go to stdout, unless it holds the -output json result.
+func (f *updateFlags) newLogger(stdout, stderr io.Writer) Logger {
+	if f.sysOut != nil {
+		return f.sysOut
+	}
+	if f.logOut != nil && f.logFormat == "json" {
+		return newJSONLogger(f.logOut)
+	}
+	if f.logOut != nil {
+		return newLogger(f.logOut)
+	}
+	if f.logFormat == "json" && f.output == "json" {
+		return newJSONLogger(stderr)
+	}
+	if f.logFormat == "json" {
+		return newJSONLogger(stdout)
+	}
+	return newLogger(stderr)
+}
+
+func runUpdate(args []string, stdout, stderr io.Writer) int {
+	var f updateFlags
+	var oneshot bool
+	fs := newFlagSet("update", stderr)
+	f.register(fs)
+	f.registerDryRun(fs)
+	f.registerAuditLog(fs)
+	fs.BoolVar(&oneshot, "oneshot", false, "exit with a code describing the outcome, for cron jobs and monitoring (see below)")
+	f.registerPIDFile(fs, "lock this file, holding the process ID, while updating; waits up to -timeout for another update or daemon locking it, such as one run by an earlier hook")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: ddns update [flags] [hook arguments]")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Detects the public address and publishes it to the provider.")
+		fmt.Fprintln(stderr, "With -source hook, the arguments and environment passed to a PPP or DHCP hook script are used.")
+		fmt.Fprintln(stderr)
+		fs.PrintDefaults()
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, oneshotUsage)
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, dryRunUsage)
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, outputUsage)
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, envUsage)
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if err := applyEnv(fs, os.Getenv); err != nil {
+		fmt.Fprintf(stderr, "ddns: %v\n", err)
+		return exitUsage
+	}
+	if !f.validate(stderr) {
+		return exitUsage
+	}
+	if err := f.openLogFile(fs); err != nil {
+		return fail(f.output, err, exitFailure, stdout, stderr)
+	}
+	defer f.closeLogFile()
+	d := f.debugLogs(f.newLogger(stdout, stderr))
+
+	plans, err := f.plans(fs.Args(), d, stdout, stderr)
+	if err != nil {
+		return fail(f.output, err, exitUsage, stdout, stderr)
+	}
+	defer closePlans(plans)
+	if err := f.openAuditLog(); err != nil {
+		return fail(f.output, err, exitFailure, stdout, stderr)
+	}
+	defer f.closeAuditLog()
+	if f.pidFile != "" && !f.dryRun {
+		ctx, cancel := context.WithTimeout(context.Background(), f.timeout)
+		lock, err := pidlock.Wait(ctx, f.pidFile, 100*time.Millisecond)
+		cancel()
+		if err != nil {
+			code := exitFailure
+			if oneshot {
+				code = exitTemporary
+			}
+			return fail(f.output, err, code, stdout, stderr)
+		}
+		defer lock.Release()
+	}
+	var o outcome
+	out := results{Results: []planResult{}}
+	for _, p := range plans {
+		out.Results = append(out.Results, f.update(p, &o, stdout, stderr))
+	}
+	if f.output == "json" {
+		if err := writeJSON(stdout, out); err != nil {
+			fmt.Fprintf(stderr, "ddns: %v\n", err)
+			return exitFailure
+		}
+	}
+	return o.code(oneshot)
+}
+
+// update detects the address of a plan and publishes it to each of its providers, adding the results to o.
+// The results are printed unless they are printed as JSON by the caller.
+func (f *updateFlags) update(p *plan, o *outcome, stdout, stderr io.Writer) planResult {
+	r := newPlanResult(p)
+	if hook, ok := p.source.(*ipdetect.Hook); ok && !hook.Triggered() {
+		// dhclient also runs its hooks on expiry and release; there is no new address to publish
+		return r
+	}
+	text := stdout
+	if f.output == "json" {
+		text = io.Discard
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), f.timeout)
+	defer cancel()
+	var err error
+	if p.hooks != nil && !f.dryRun {
+		err = p.hooks.PreDetect(ctx)
+	}
+	var ips []net.IP
+	if err == nil {
+		ips, err = p.source.Detect(ctx)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "ddns: %sdetecting address: %v\n", p.label(), err)
+		explain(stderr, err, os.Getenv)
+		// the address may be found on the next run
+		o.temporary++
+		r.Error = "detecting address: " + err.Error()
+		return r
+	}
+	r.Detected = ips
+	f.recordAudit(event.Event{Type: event.Detected, Time: time.Now(), NewIPs: ips}, stderr)
+	for _, provider := range p.providers {
+		pr := providerResult{Name: provider.Name, Hostnames: provider.Hostnames, IPs: ips}
+		if f.dryRun {
+			fmt.Fprintf(text, "would update %s (%s) to %s\n", strings.Join(provider.Hostnames, ", "), provider.Name, joinIPs(ips))
+			pr.Status = "would-update"
+			r.Providers = append(r.Providers, pr)
+			continue
+		}
+		err := provider.Updater.UpdateIP(ctx, ips)
+		o.add(err)
+		switch {
+		case errors.Is(err, daemon.ErrUnchanged):
+			fmt.Fprintf(text, "%s already resolves to %s\n", strings.Join(provider.Hostnames, ", "), joinIPs(ips))
+			pr.Status = "unchanged"
+		case err != nil:
+			fmt.Fprintf(stderr, "ddns: %s: update failed: %v\n", provider.Name, err)
+			explain(stderr, err, os.Getenv)
+			pr.Status, pr.IPs, pr.Error = "failed", nil, err.Error()
+		default:
+			fmt.Fprintf(text, "updated %s to %s\n", strings.Join(provider.Hostnames, ", "), joinIPs(ips))
+			pr.Status = "updated"
+		}
+		if pr.Status != "failed" {
+			for _, err := range p.brokenAliases(ctx, provider.Name) {
+				fmt.Fprintf(stderr, "ddns: %swarning: %v\n", p.label(), err)
+				pr.Warnings = append(pr.Warnings, err.Error())
+			}
+		}
+		r.Providers = append(r.Providers, pr)
+		f.recordAudit(updateEvent(provider, ips, err), stderr)
+		if p.hooks != nil {
+			f.runHooks(p.hooks, provider, ips, err, stderr)
+		}
+	}
+	return r
+}
+
+// runHooks runs the success or failure hook after updating provider, reporting its failure to stderr
+func (f *updateFlags) runHooks(h *hook.Runner, provider daemon.Provider, ips []net.IP, err error, stderr io.Writer) {
+	// the hooks have their own timeout
+	if err := h.Handle(context.Background(), updateEvent(provider, ips, err)); err != nil {
+		fmt.Fprintf(stderr, "ddns: %v\n", err)
+	}
+}
+
+// updateEvent returns the Updated or Failed event of publishing ips to provider, as the daemon publishes it
+func updateEvent(provider daemon.Provider, ips []net.IP, err error) event.Event {
+	ev := event.Event{Type: event.Updated, Time: time.Now(), Provider: provider.Name, Hostnames: provider.Hostnames, NewIPs: ips}
+	switch {
+	case errors.Is(err, daemon.ErrUnchanged):
+		ev.OldIPs = ips
+	case err != nil:
+		ev.Type, ev.Err = event.Failed, err
+	}
+	return ev
+}
+
+// oneshotUsage documents the exit codes of -oneshot
+const oneshotUsage = `With -oneshot, the exit code is:
+  0  at least one record was updated
+  3  no change: every record already had the address
+  4  temporary failure, such as a network or server error; try again later
+  5  permanent failure, such as bad credentials or an unknown hostname; fix the configuration
+  2  invalid flags or configuration
+Without -oneshot, the exit code is 0 on success (including no change) and 1 on any failure.`
+
+// outcome accumulates the results of updating each provider
+type outcome struct {
+	updated, unchanged   int
+	temporary, permanent int
+}
+
+func (o *outcome) add(err error) {
+	var netErr net.Error
+	var temp interface{ Temporary() bool }
+	switch {
+	case err == nil:
+		o.updated++
+	case errors.Is(err, daemon.ErrUnchanged):
+		o.unchanged++
+	case errors.As(err, &netErr):
+		o.temporary++
+	case errors.As(err, &temp) && !temp.Temporary():
+		// provider responses such as dynu's badauth and nohost
+		o.permanent++
+	default:
+		// network errors, timeouts and unclassified failures may succeed later
+		o.temporary++
+	}
+}
+
+// code returns the exit code; failures take precedence over success, and permanent over temporary failures
+func (o *outcome) code(oneshot bool) int {
+	failed := o.temporary+o.permanent > 0
+	switch {
+	case !oneshot && failed:
+		return exitFailure
+	case !oneshot:
+		return exitOK
+	case o.permanent > 0:
+		return exitPermanent
+	case o.temporary > 0:
+		return exitTemporary
+	case o.updated == 0 && o.unchanged > 0:
+		return exitNoChange
+	}
+	return exitOK
+}
+
+// plans builds the plans of the configuration file, selected by -profile, or the plan of the flags if there is none
+func (f *updateFlags) plans(args []string, d debugLogs, stdout, stderr io.Writer) ([]*plan, error) {
+	if f.config != "" {
+		return loadPlans(f.config, f.profile, d, stdout, stderr)
+	}
+	src, err := f.newSource(args, d.detection)
+	if err != nil {
+		return nil, err
+	}
+	hostnames := f.hostnames
+	if len(hostnames) == 0 {
+		hostnames = []string{f.target()}
+	}
+	return []*plan{{
+		source:      src,
+		sourceTypes: []string{f.sourceType()},
+		providers: []daemon.Provider{{
+			Name:          f.provider,
+			Hostnames:     hostnames,
+			Updater:       f.newUpdater(d),
+			SplitFamilies: f.ipv4 && f.ipv6,
+		}},
+	}}, nil
+}
+
+// newSource returns the configured address source, limited to the enabled address families
+func (f *updateFlags) newSource(args []string, l Logger) (ipdetect.Source, error) {
+	src, err := f.baseSource(args, l)
+	if err != nil {
+		return nil, err
+	}
+	if hook, ok := src.(*ipdetect.Hook); ok {
+		return hook, nil
+	}
+	return familySource{src: src, ipv4: f.ipv4, ipv6: f.ipv6}, nil
+}
+
+func (f *updateFlags) baseSource(args []string, l Logger) (ipdetect.Source, error) {
+	switch {
+	case f.source == "ipify":
+		opts := []ipify.Option{ipify.IPv4(f.ipv4), ipify.IPv6(f.ipv6)}
+		if l != nil {
+			opts = append(opts, ipify.Log(l))
+		}
+		return ipify.New(opts...), nil
+	case f.source == "stun", strings.HasPrefix(f.source, "stun:"):
+		return stunSource(strings.TrimPrefix(strings.TrimPrefix(f.source, "stun"), ":"), f.ipv4, f.ipv6, l), nil
+	case f.source == "hook":
+		return ipdetect.NewHook(args), nil
+	case f.source == "interface":
+		if f.iface == "" {
+			return nil, fmt.Errorf("-source interface requires -interface")
+		}
+		return ipdetect.NewInterface(f.iface, ipdetect.InterfaceIPv4(f.ipv4), ipdetect.InterfaceIPv6(f.ipv6)), nil
+	case strings.HasPrefix(f.source, "http://"), strings.HasPrefix(f.source, "https://"):
+		var opts []ipdetect.HTTPOption
+		if l != nil {
+			opts = append(opts, ipdetect.HTTPLog(l))
+		}
+		return ipdetect.NewHTTP(f.source, opts...), nil
+	}
+	return nil, fmt.Errorf("unknown source %q", f.source)
+}
+
+// sourceType returns the configuration source type of -source
+func (f *updateFlags) sourceType() string {
+	switch {
+	case strings.HasPrefix(f.source, "stun"):
+		return "stun"
+	case strings.HasPrefix(f.source, "http://"), strings.HasPrefix(f.source, "https://"):
+		return "http"
+	}
+	return f.source
+}
+
+func (f *updateFlags) dynuOptions(d debugLogs) []dynu.Option {
+	opts := []dynu.Option{dynu.IPv4(f.ipv4), dynu.IPv6(f.ipv6)}
+	opts = append(opts, d.dynuOptions()...)
+	if f.location != "" {
+		opts = append(opts, dynu.Location(f.location))
+	} else if len(f.hostnames) > 0 {
+		opts = append(opts, dynu.Hostnames(f.hostnames))
+	}
+	if f.endpoint != "" {
+		opts = append(opts, dynu.Endpoint(f.endpoint))
+	}
+	if len(f.bootIPs) > 0 || f.bootDoH != "" {
+		ips := make([]net.IP, len(f.bootIPs))
+		for i, a := range f.bootIPs {
+			ips[i] = net.ParseIP(a)
+		}
+		opts = append(opts, dynu.Bootstrap(ips, f.bootDoH))
+	}
+	return opts
+}
+
+// newUpdater returns an updater that publishes addresses to the configured provider,
+// through the canary and reachability verification when they are enabled
+func (f *updateFlags) newUpdater(d debugLogs) daemon.Updater {
+	client := dynu.New(f.username, f.password, f.dynuOptions(d)...)
+	return daemon.UpdaterFunc(func(ctx context.Context, ips []net.IP) error {
+		ips = filterFamilies(ips, f.ipv4, f.ipv6)
+		if len(ips) == 0 {
+			return fmt.Errorf("no address of the enabled families was detected")
+		}
+		if f.canary != "" {
+			return f.newCanary(client).UpdateHostnames(ctx, f.hostnames, ips)
+		}
+		updateErr := ddns.Accounts(client).UpdateIP(ctx, ips)
+		if updateErr != nil && !errors.Is(updateErr, daemon.ErrUnchanged) {
+			return updateErr
+		}
+		if len(f.ports) > 0 && !f.rollback {
+			if err := f.verify(ctx, ips); err != nil {
+				return err
+			}
+		}
+		return updateErr
+	})
+}
+
+// verify checks that the -verify-port ports are reachable on ips, deferring the check on metered networks
+func (f *updateFlags) verify(ctx context.Context, ips []net.IP) error {
+	check := func(ctx context.Context) error {
+		return f.checker().Check(ctx, ips).Err()
+	}
+	if f.budget != nil {
+		return f.budget.Defer(ctx, "verification", check)
+	}
+	return check(ctx)
+}
+
+func (f *updateFlags) checker() *verify.Checker {
+	c := &verify.Checker{Prober: verify.Dial{}, Ports: f.ports, Timeout: 10 * time.Second}
+	if f.probeURL != "" {
+		probe := verify.ProbeURL{URL: f.probeURL}
+		if f.budget != nil {
+			probe.HTTPClient = &http.Client{Transport: f.budget.Transport(nil, budget.Deferrable)}
+		}
+		c.Prober = probe
+	}
+	return c
+}
+
+func (f *updateFlags) newCanary(client *dynu.Client) verify.Canary {
+	c := verify.Canary{
+		Hostname: f.canary,
+		Updater: verify.HostnameUpdaterFunc(func(ctx context.Context, hostnames []string, ips []net.IP) error {
+			return client.UpdateHostnamesContext(ctx, hostnames, ips)
+		}),
+	}
+	if f.canaryMax > 0 {
+		c.Resolver, c.Wait = newResolver(f.canaryDNS), f.canaryMax
+	}
+	if len(f.ports) > 0 {
+		c.Checker = f.checker()
+	}
+	return c
+}
+
+func (f *updateFlags) target() string {
+	switch {
+	case f.location != "":
+		return "location " + f.location
+	case len(f.hostnames) > 0:
+		return strings.Join(f.hostnames, ", ")
+	}
+	return f.username
+}
+
+// familySource drops addresses of disabled families from another source
+type familySource struct {
+	src        ipdetect.Source
+	ipv4, ipv6 bool
+}
+
+func (s familySource) Detect(ctx context.Context) ([]net.IP, error) {
+	ips, err := s.src.Detect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ips = filterFamilies(ips, s.ipv4, s.ipv6)
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no address of the enabled families was detected")
+	}
+	return ips, nil
+}
+
+func filterFamilies(ips []net.IP, ipv4, ipv6 bool) []net.IP {
+	var out []net.IP
+	for _, ip := range ips {
+		if ip.To4() != nil && ipv4 || ip.To4() == nil && ipv6 {
+			out = append(out, ip)
+		}
+	}
+	return out
+}
+
+func joinIPs(ips []net.IP) string {
+	ss := make([]string, len(ips))
+	for i, ip := range ips {
+		ss[i] = ip.String()
+	}
+	return strings.Join(ss, ", ")
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"github.com/justenwalker/ddns/verify"
+)
+
+func runWait(args []string, stdout, stderr io.Writer) int {
+	var f updateFlags
+	var maxWait, poll time.Duration
+	var server string
+	fs := newFlagSet("wait", stderr)
+	f.register(fs)
+	fs.DurationVar(&maxWait, "max-wait", 10*time.Minute, "give up if the records have not converged after this long")
+	fs.DurationVar(&poll, "poll", 10*time.Second, "how often to look the records up")
+	fs.StringVar(&server, "resolver", "", "DNS server to query as host:port, such as the provider's name server (default the system resolver)")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: ddns wait [flags]")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Detects the public address and waits until every hostname resolves to it, exiting 0 once they do")
+		fmt.Fprintln(stderr, "and 1 after -max-wait. Order services that need the records, such as ACME issuance, after it:")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "  ExecStartPre=/usr/local/bin/ddns wait -config /etc/ddns.yaml")
+		fmt.Fprintln(stderr)
+		fs.PrintDefaults()
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, outputUsage)
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, envUsage)
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if err := applyEnv(fs, os.Getenv); err != nil {
+		fmt.Fprintf(stderr, "ddns: %v\n", err)
+		return exitUsage
+	}
+	if !checkOutput(f.output, stderr) {
+		return exitUsage
+	}
+	if f.config == "" && len(f.hostnames) == 0 {
+		fmt.Fprintln(stderr, "ddns: -hostname or -config is required")
+		return exitUsage
+	}
+	if err := f.openLogFile(fs); err != nil {
+		return fail(f.output, err, exitFailure, stdout, stderr)
+	}
+	defer f.closeLogFile()
+	plans, err := f.plans(nil, f.debugLogs(f.newLogger(stdout, stderr)), stdout, stderr)
+	if err != nil {
+		return fail(f.output, err, exitUsage, stdout, stderr)
+	}
+	defer closePlans(plans)
+
+	ctx, cancel := context.WithTimeout(context.Background(), maxWait)
+	defer cancel()
+	out := results{Results: []planResult{}}
+	code := exitOK
+	for _, p := range plans {
+		r, ok := f.wait(ctx, p, newResolver(server), poll, stdout, stderr)
+		out.Results = append(out.Results, r)
+		if !ok {
+			code = exitFailure
+			break
+		}
+	}
+	if f.output == "json" {
+		if err := writeJSON(stdout, out); err != nil {
+			fmt.Fprintf(stderr, "ddns: %v\n", err)
+			return exitFailure
+		}
+	}
+	return code
+}
+
+// wait detects the address of a plan and waits until its hostnames resolve to it, returning false if they
+// did not. The result is printed unless it is printed as JSON by the caller.
+func (f *updateFlags) wait(ctx context.Context, p *plan, resolver *net.Resolver, poll time.Duration, stdout, stderr io.Writer) (planResult, bool) {
+	r := newPlanResult(p)
+	var hostnames []string
+	for _, provider := range p.providers {
+		hostnames = append(hostnames, provider.Hostnames...)
+	}
+	detectCtx, cancelDetect := context.WithTimeout(ctx, f.timeout)
+	ips, err := p.source.Detect(detectCtx)
+	cancelDetect()
+	if err != nil {
+		fmt.Fprintf(stderr, "ddns: %sdetecting address: %v\n", p.label(), err)
+		explain(stderr, err, os.Getenv)
+		r.Error = "detecting address: " + err.Error()
+		return r, false
+	}
+	r.Detected = ips
+	err = verify.WaitConverged(ctx, resolver, hostnames, ips, poll)
+	if err != nil {
+		fmt.Fprintf(stderr, "ddns: %s%v\n", p.label(), err)
+		r.Error = err.Error()
+	} else if f.output != "json" {
+		fmt.Fprintf(stdout, "%s%d hostname(s) resolve to %s\n", p.label(), len(hostnames), joinIPs(ips))
+	}
+	for _, provider := range p.providers {
+		pr := providerResult{Name: provider.Name, Hostnames: provider.Hostnames, Status: "resolved", IPs: ips}
+		if err != nil && f.output == "json" {
+			// look the hostnames of each provider up once more to tell which ones are still pending
+			checkCtx, cancelCheck := context.WithTimeout(context.Background(), f.timeout)
+			if perr := verify.Converged(checkCtx, resolver, provider.Hostnames, ips); perr != nil {
+				pr.Status, pr.IPs, pr.Error = "pending", nil, perr.Error()
+			}
+			cancelCheck()
+		}
+		r.Providers = append(r.Providers, pr)
+	}
+	return r, err == nil
+}
+
+// newResolver returns a resolver that queries server, or the system resolver if server is empty.
+// Querying the provider's name server directly avoids waiting for cached answers to expire.
+func newResolver(server string) *net.Resolver {
+	if server == "" {
+		return net.DefaultResolver
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, server)
+		},
+	}
+}
@@ -0,0 +1,294 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// CheckFile reads the configuration file at path like Load, but instead of stopping at the first error it
+// returns every problem found, with the line of the setting concerned where it can be found.
+// The checks are run on the decoded configuration, even if it has problems, to report those that depend on
+// more than the file, such as rejected credentials; set the Key of their problems to locate them.
+// The configuration is nil if the file could not be decoded; otherwise it is migrated, and its Warnings located.
+// The error is only set if the file cannot be read.
+func CheckFile(path string, checks ...func(*Config) Problems) (*Config, Problems, error) {
+	format, err := FormatOf(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	c, ps := check(data, format, checks)
+	return c, ps, nil
+}
+
+func check(data []byte, format Format, checks []func(*Config) Problems) (*Config, Problems) {
+	var c Config
+	var ps Problems
+	var lines map[string]int
+	switch format {
+	case YAML:
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&c); err != nil && err != io.EOF {
+			ps = yamlProblems(err)
+		}
+		lines = yamlLines(data)
+	case TOML:
+		md, err := toml.NewDecoder(bytes.NewReader(data)).Decode(&c)
+		lines = tomlLines(data)
+		if err != nil {
+			var perr toml.ParseError
+			if errors.As(err, &perr) {
+				return nil, Problems{{Line: perr.Position.Line, Err: errors.New(perr.Message)}}
+			}
+			return nil, Problems{{Err: err}}
+		}
+		for _, k := range md.Undecoded() {
+			ps = append(ps, Problem{Key: k.String(), Err: fmt.Errorf("unknown key %s", k)})
+		}
+	case JSON:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		lines = jsonLines(data)
+		if err := dec.Decode(&c); err != nil {
+			offset := dec.InputOffset()
+			var serr *json.SyntaxError
+			var terr *json.UnmarshalTypeError
+			if errors.As(err, &serr) {
+				offset = serr.Offset
+			} else if errors.As(err, &terr) {
+				offset = terr.Offset
+			}
+			p := Problem{Line: bytes.Count(data[:offset], []byte("\n")) + 1, Err: err}
+			if m := jsonUnknown.FindStringSubmatch(err.Error()); m != nil {
+				// the error does not tell where the field is: take the first key of that name
+				p.Line = 0
+				for key, line := range lines {
+					if (key == m[1] || strings.HasSuffix(key, "."+m[1])) && (p.Line == 0 || line < p.Line) {
+						p.Line = line
+					}
+				}
+			}
+			ps = Problems{p}
+		}
+	default:
+		return nil, Problems{{Err: fmt.Errorf("unknown format %q", format)}}
+	}
+	if len(ps) > 0 {
+		locate(ps, lines)
+		return nil, ps
+	}
+	if err := c.Migrate(); err != nil {
+		ps = Problems{{Key: "version", Err: errors.New(strings.TrimPrefix(err.Error(), "config: "))}}
+		locate(ps, lines)
+		return nil, ps
+	}
+	locate(c.Warnings, lines)
+	ps = append(c.resolveSecrets(), c.Check()...)
+	for _, check := range checks {
+		ps = append(ps, check(&c)...)
+	}
+	locate(ps, lines)
+	return &c, ps
+}
+
+// lines returns the line of every key of data, by dotted path
+func lines(data []byte, format Format) map[string]int {
+	switch format {
+	case YAML:
+		return yamlLines(data)
+	case TOML:
+		return tomlLines(data)
+	case JSON:
+		return jsonLines(data)
+	}
+	return nil
+}
+
+// locate sets the line of each problem to that of its key, or of the closest enclosing key
+func locate(ps Problems, lines map[string]int) {
+	for i := range ps {
+		if ps[i].Line > 0 {
+			continue
+		}
+		for key := ps[i].Key; key != ""; {
+			if line, ok := lines[key]; ok {
+				ps[i].Line = line
+				break
+			}
+			n := strings.LastIndexByte(key, '.')
+			if n < 0 {
+				break
+			}
+			key = key[:n]
+		}
+	}
+	sort.SliceStable(ps, func(i, j int) bool { return ps[i].Line < ps[j].Line })
+}
+
+var (
+	yamlLine    = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+	jsonUnknown = regexp.MustCompile(`^json: unknown field "(.*)"$`)
+)
+
+// yamlProblems splits a YAML decoding error into its problems, which each start with their line
+func yamlProblems(err error) Problems {
+	msgs := []string{err.Error()}
+	var terr *yaml.TypeError
+	if errors.As(err, &terr) {
+		msgs = terr.Errors
+	}
+	var ps Problems
+	for _, msg := range msgs {
+		p := Problem{Err: errors.New(msg)}
+		if m := yamlLine.FindStringSubmatch(msg); m != nil {
+			p.Line, _ = strconv.Atoi(m[1])
+			p.Err = errors.New(m[2])
+		}
+		ps = append(ps, p)
+	}
+	return ps
+}
+
+// yamlLines returns the line of every key, by dotted path
+func yamlLines(data []byte) map[string]int {
+	lines := make(map[string]int)
+	var doc yaml.Node
+	if yaml.Unmarshal(data, &doc) != nil {
+		return lines
+	}
+	var walk func(n *yaml.Node, path string)
+	walk = func(n *yaml.Node, path string) {
+		switch n.Kind {
+		case yaml.DocumentNode:
+			for _, c := range n.Content {
+				walk(c, path)
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				key := join(path, n.Content[i].Value)
+				lines[key] = n.Content[i].Line
+				walk(n.Content[i+1], key)
+			}
+		case yaml.SequenceNode:
+			for i, c := range n.Content {
+				key := join(path, strconv.Itoa(i))
+				lines[key] = c.Line
+				walk(c, key)
+			}
+		}
+	}
+	walk(&doc, "")
+	return lines
+}
+
+// jsonLines returns the line of every key, by dotted path
+func jsonLines(data []byte) map[string]int {
+	lines := make(map[string]int)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	line := func() int {
+		return bytes.Count(data[:dec.InputOffset()], []byte("\n")) + 1
+	}
+	var value func(path string) error
+	value = func(path string) error {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'):
+			for dec.More() {
+				k, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				key := join(path, fmt.Sprint(k))
+				lines[key] = line()
+				if err := value(key); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+		case json.Delim('['):
+			for i := 0; dec.More(); i++ {
+				key := join(path, strconv.Itoa(i))
+				lines[key] = line()
+				if err := value(key); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+		}
+		return err
+	}
+	value("")
+	return lines
+}
+
+var (
+	tomlTable = regexp.MustCompile(`^\[\s*([^\[\]]+?)\s*\]$`)
+	tomlArray = regexp.MustCompile(`^\[\[\s*([^\[\]]+?)\s*\]\]$`)
+	tomlKey   = regexp.MustCompile(`^([A-Za-z0-9_."-]+?)\s*=`)
+)
+
+// tomlLines returns the line of every table and key, by dotted path.
+// It only understands the table headers and keys on their own lines that configuration files use in practice.
+func tomlLines(data []byte) map[string]int {
+	lines := make(map[string]int)
+	arrays := make(map[string]int)
+	table := ""
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		text := strings.TrimSpace(sc.Text())
+		if m := tomlArray.FindStringSubmatch(text); m != nil {
+			name := unquote(m[1])
+			table = join(name, strconv.Itoa(arrays[name]))
+			if arrays[name] == 0 {
+				lines[name] = n
+			}
+			arrays[name]++
+			lines[table] = n
+			continue
+		}
+		if m := tomlTable.FindStringSubmatch(text); m != nil {
+			table = unquote(m[1])
+			lines[table] = n
+			continue
+		}
+		if m := tomlKey.FindStringSubmatch(text); m != nil {
+			lines[join(table, unquote(m[1]))] = n
+		}
+	}
+	return lines
+}
+
+// unquote removes the quotes and spaces around the parts of a dotted TOML key
+func unquote(key string) string {
+	parts := strings.Split(key, ".")
+	for i, p := range parts {
+		parts[i] = strings.Trim(strings.TrimSpace(p), `"`)
+	}
+	return strings.Join(parts, ".")
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
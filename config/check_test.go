@@ -0,0 +1,73 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/justenwalker/ddns/config"
+)
+
+func checkFile(t *testing.T, name, text string) (*config.Config, config.Problems) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(text), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c, ps, err := config.CheckFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, ps
+}
+
+func TestCheckFile(t *testing.T) {
+	_, ps := checkFile(t, "ddns.yaml", `
+providers:
+  home:
+    type: dynu
+groups:
+  web:
+    providers: [home, away]
+    notify: [pager]
+    hostnames: [example.com]
+sources:
+  - type: ipify
+  - type: carrier-pigeon
+schedule:
+  cron: ["*/5 25 * * *"]
+`)
+	want := []struct {
+		line int
+		text string
+	}{
+		{7, `undefined provider "away"`},
+		{8, `undefined notifier "pager"`},
+		{12, `unknown type "carrier-pigeon"`},
+		{14, "schedule:"},
+	}
+	if len(ps) != len(want) {
+		t.Fatalf("expected %d problems, got %v", len(want), ps)
+	}
+	for i, w := range want {
+		if ps[i].Line != w.line || !strings.Contains(ps[i].Err.Error(), w.text) {
+			t.Errorf("problem %d: got %v, want line %d: %s", i, ps[i], w.line, w.text)
+		}
+	}
+}
+
+func TestCheckFileUnknownKeys(t *testing.T) {
+	c, ps := checkFile(t, "ddns.yaml", yamlConfig+"intreval: 5m\nschedule2: {}\n")
+	if c != nil || len(ps) != 2 || ps[0].Line != 21 || ps[1].Line != 22 {
+		t.Errorf("yaml: expected both unknown keys with their lines, got %v", ps)
+	}
+	_, ps = checkFile(t, "ddns.toml", tomlConfig+"\n[groups.vpn]\nhostname = [\"vpn.example.com\"]\n")
+	if len(ps) != 1 || ps[0].Line != 27 || !strings.Contains(ps[0].Error(), "groups.vpn.hostname") {
+		t.Errorf("toml: expected the unknown key with its line, got %v", ps)
+	}
+	_, ps = checkFile(t, "ddns.json", "{\n  \"groups\": {},\n  \"extra\": 1\n}\n")
+	if len(ps) != 1 || ps[0].Line != 3 {
+		t.Errorf("json: expected the unknown key with its line, got %v", ps)
+	}
+}
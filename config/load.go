@@ -0,0 +1,108 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Format of a configuration file
+type Format string
+
+// Configuration file formats
+const (
+	YAML = Format("yaml")
+	TOML = Format("toml")
+	JSON = Format("json")
+)
+
+// FormatOf returns the format implied by the file extension of path
+func FormatOf(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return YAML, nil
+	case ".toml":
+		return TOML, nil
+	case ".json":
+		return JSON, nil
+	}
+	return "", fmt.Errorf("config: %s: unknown file extension, expected .yaml, .yml, .toml or .json", path)
+}
+
+// Load reads, migrates and validates the configuration file at path.
+// The format is chosen by the file extension. The Warnings are located in the file.
+func Load(path string) (*Config, error) {
+	format, err := FormatOf(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := Decode(bytes.NewReader(data), format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(c.Warnings) > 0 {
+		locate(c.Warnings, lines(data, format))
+	}
+	return c, nil
+}
+
+// Decode reads, migrates and validates a configuration.
+// Unknown keys are an error, so that a misspelled setting is not silently ignored.
+func Decode(r io.Reader, format Format) (*Config, error) {
+	var c Config
+	switch format {
+	case YAML:
+		dec := yaml.NewDecoder(r)
+		dec.KnownFields(true)
+		if err := dec.Decode(&c); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("config: %v", err)
+		}
+	case TOML:
+		md, err := toml.NewDecoder(r).Decode(&c)
+		if err != nil {
+			return nil, fmt.Errorf("config: %v", err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			keys := make([]string, len(undecoded))
+			for i, k := range undecoded {
+				keys[i] = k.String()
+			}
+			sort.Strings(keys)
+			return nil, fmt.Errorf("config: unknown keys: %s", strings.Join(keys, ", "))
+		}
+	case JSON:
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&c); err != nil {
+			return nil, fmt.Errorf("config: %v", err)
+		}
+	default:
+		return nil, fmt.Errorf("config: unknown format %q", format)
+	}
+	if err := c.Migrate(); err != nil {
+		return nil, err
+	}
+	if ps := c.resolveSecrets(); len(ps) > 0 {
+		return nil, fmt.Errorf("config: %v", ps[0].Err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
@@ -0,0 +1,146 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/justenwalker/ddns/config"
+)
+
+const yamlConfig = `
+providers:
+  home:
+    type: dynu
+    username: user
+    password: pass
+defaults:
+  providers: [home]
+groups:
+  web:
+    debounce: 1m
+    hostnames: [example.com, www.example.com]
+sources:
+  - type: http
+    url: https://ip.example.com
+    json_path: ip
+  - type: ipify
+schedule:
+  interval: 10m
+  backoff_max: 1h
+`
+
+const tomlConfig = `
+[providers.home]
+type = "dynu"
+username = "user"
+password = "pass"
+
+[defaults]
+providers = ["home"]
+
+[groups.web]
+debounce = "1m"
+hostnames = ["example.com", "www.example.com"]
+
+[[sources]]
+type = "http"
+url = "https://ip.example.com"
+json_path = "ip"
+
+[[sources]]
+type = "ipify"
+
+[schedule]
+interval = "10m"
+backoff_max = "1h"
+`
+
+func TestDecode(t *testing.T) {
+	for _, tc := range []struct {
+		format config.Format
+		text   string
+	}{{config.YAML, yamlConfig}, {config.TOML, tomlConfig}} {
+		c, err := config.Decode(strings.NewReader(tc.text), tc.format)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.format, err)
+		}
+		hosts := c.Hosts()
+		if len(hosts) != 2 || hosts[0].Policy.Debounce != config.Duration(time.Minute) || hosts[0].Policy.Providers[0] != "home" {
+			t.Errorf("%s: unexpected hosts %+v", tc.format, hosts)
+		}
+		if len(c.Sources) != 2 || c.Sources[0].JSONPath != "ip" {
+			t.Errorf("%s: unexpected sources %+v", tc.format, c.Sources)
+		}
+		if c.Schedule.Interval != config.Duration(10*time.Minute) || c.Schedule.BackoffMax != config.Duration(time.Hour) {
+			t.Errorf("%s: unexpected schedule %+v", tc.format, c.Schedule)
+		}
+		if !c.EnableIPv4() || c.EnableIPv6() {
+			t.Errorf("%s: unexpected address families", tc.format)
+		}
+	}
+}
+
+func TestDecodeUnknownKeys(t *testing.T) {
+	if _, err := config.Decode(strings.NewReader(yamlConfig+"intreval: 5m\n"), config.YAML); err == nil {
+		t.Error("yaml: expected an error for an unknown key")
+	}
+	_, err := config.Decode(strings.NewReader(tomlConfig+"\n[groups.vpn]\nhostname = [\"vpn.example.com\"]\n"), config.TOML)
+	if err == nil || !strings.Contains(err.Error(), "groups.vpn.hostname") {
+		t.Errorf("toml: expected an error naming the unknown key, got %v", err)
+	}
+	if _, err := config.Decode(strings.NewReader(`{"groups":{},"extra":1}`), config.JSON); err == nil {
+		t.Error("json: expected an error for an unknown key")
+	}
+}
+
+func TestDecodeSecrets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(path, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DDNS_TEST_USER", "envuser")
+	text := strings.NewReplacer(
+		"username: user", "username: ${env:DDNS_TEST_USER}",
+		"password: pass", "password: ${file:"+filepath.ToSlash(path)+"}\n    api_key: $${literal}",
+	).Replace(yamlConfig)
+	c, err := config.Decode(strings.NewReader(text), config.YAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := c.Providers["home"]; p.Username != "envuser" || p.Password != "s3cret" || p.APIKey != "${literal}" {
+		t.Errorf("unexpected credentials %+v", p)
+	}
+	text = strings.Replace(yamlConfig, "password: pass", "password: ${env:DDNS_TEST_UNSET}", 1)
+	if _, err := config.Decode(strings.NewReader(text), config.YAML); err == nil || !strings.Contains(err.Error(), "DDNS_TEST_UNSET is not set") {
+		t.Errorf("expected an error for an unset variable, got %v", err)
+	}
+	text = strings.Replace(yamlConfig, "password: pass", "password: ${vault:dynu}", 1)
+	if _, err := config.Decode(strings.NewReader(text), config.YAML); err == nil || !strings.Contains(err.Error(), `unknown secret store "vault"`) {
+		t.Errorf("expected an error for an unknown store, got %v", err)
+	}
+}
+
+func TestDecodeProfiles(t *testing.T) {
+	for _, tc := range []struct {
+		format config.Format
+		text   string
+	}{
+		{config.YAML, yamlConfig + "profiles:\n  office:\n    families: [ipv4, ipv6]\n    groups:\n      vpn:\n        hostnames: [vpn.example.com]\n"},
+		{config.TOML, tomlConfig + "\n[profiles.office]\nfamilies = [\"ipv4\", \"ipv6\"]\n\n[profiles.office.groups.vpn]\nhostnames = [\"vpn.example.com\"]\n"},
+	} {
+		c, err := config.Decode(strings.NewReader(tc.text), tc.format)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.format, err)
+		}
+		p, err := c.Profile("office")
+		if err != nil {
+			t.Fatalf("%s: %v", tc.format, err)
+		}
+		if hosts := p.Hosts(); len(hosts) != 1 || hosts[0].Policy.Providers[0] != "home" || !p.EnableIPv6() {
+			t.Errorf("%s: unexpected profile %+v", tc.format, p)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CurrentVersion is the version of the configuration format written by this release.
+// Version 2 replaced ipv4 and ipv6 with families.
+const CurrentVersion = 2
+
+// migrations upgrade a configuration, or one of its profiles, from the version they are indexed by to the next.
+// They return a warning for each deprecated setting they changed, keyed under prefix.
+var migrations = map[int]func(c *Config, prefix string) Problems{
+	1: migrateV1,
+}
+
+// Migrate upgrades a configuration written for an earlier version of the format, and its profiles, to
+// CurrentVersion, adding a warning to Warnings for each deprecated setting that should be changed in the file.
+// It returns an error if the configuration was written for a later version.
+// Load and Decode migrate the configurations they return.
+func (c *Config) Migrate() error {
+	version := c.Version
+	if version == 0 {
+		version = 1
+	}
+	if version > CurrentVersion {
+		return fmt.Errorf("config: version %d was written for a newer release of ddns, which reads up to version %d", version, CurrentVersion)
+	}
+	for _, name := range c.ProfileNames() {
+		if p := c.Profiles[name]; p != nil && p.Version != 0 && p.Version != c.Version {
+			return fmt.Errorf("config: profile %q: the version can only be set at the top level", name)
+		}
+	}
+	for ; version < CurrentVersion; version++ {
+		migrate := migrations[version]
+		c.Warnings = append(c.Warnings, migrate(c, "")...)
+		for _, name := range c.ProfileNames() {
+			if p := c.Profiles[name]; p != nil {
+				c.Warnings = append(c.Warnings, migrate(p, "profiles."+name+".")...)
+			}
+		}
+	}
+	c.Version = CurrentVersion
+	for _, p := range c.Profiles {
+		if p != nil {
+			p.Version = CurrentVersion
+		}
+	}
+	return nil
+}
+
+// migrateV1 replaces ipv4 and ipv6 with families
+func migrateV1(c *Config, prefix string) Problems {
+	if c.IPv4 == nil && c.IPv6 == nil {
+		return nil
+	}
+	var families []string
+	if c.EnableIPv4() {
+		families = append(families, "ipv4")
+	}
+	if c.EnableIPv6() {
+		families = append(families, "ipv6")
+	}
+	if len(c.Families) > 0 || len(families) == 0 {
+		// reported by Check
+		return nil
+	}
+	key := prefix + deprecatedFamilyKey(c)
+	c.Families, c.IPv4, c.IPv6 = families, nil, nil
+	return Problems{{
+		Key: key,
+		Err: fmt.Errorf("ipv4 and ipv6 are deprecated: replace them with families: [%s] and set version: %d", strings.Join(families, ", "), CurrentVersion),
+	}}
+}
+
+// deprecatedFamilyKey returns the key of the first deprecated address family setting of c
+func deprecatedFamilyKey(c *Config) string {
+	if c.IPv4 != nil {
+		return "ipv4"
+	}
+	return "ipv6"
+}
@@ -0,0 +1,62 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/justenwalker/ddns/config"
+)
+
+func TestMigrate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ddns.yaml")
+	v1 := yamlConfig + "ipv6: true\nprofiles:\n  office:\n    ipv4: false\n    ipv6: true\n"
+	if err := os.WriteFile(path, []byte(v1), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Version != config.CurrentVersion || !reflect.DeepEqual(c.Families, []string{"ipv4", "ipv6"}) || c.IPv6 != nil {
+		t.Errorf("unexpected migrated configuration: version %d, families %v", c.Version, c.Families)
+	}
+	office, err := c.Profile("office")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if office.EnableIPv4() || !office.EnableIPv6() {
+		t.Errorf("unexpected families of the profile %v", office.Families)
+	}
+	var got []string
+	for _, w := range c.Warnings {
+		got = append(got, w.Key+": "+w.Err.Error())
+		if w.Line == 0 {
+			t.Errorf("expected the warning %q to be located", w.Err)
+		}
+	}
+	want := []string{
+		"ipv6: ipv4 and ipv6 are deprecated: replace them with families: [ipv4, ipv6] and set version: 2",
+		"profiles.office.ipv4: ipv4 and ipv6 are deprecated: replace them with families: [ipv6] and set version: 2",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got warnings\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	for _, tc := range []struct {
+		text string
+		err  string
+	}{
+		{yamlConfig + "version: 3\n", "newer release"},
+		{yamlConfig + "version: 2\nipv6: true\n", "replaced by families"},
+		{yamlConfig + "families: [ipv5]\n", "unknown address family"},
+		{yamlConfig + "ipv4: false\n", "no address family"},
+		{yamlConfig + "profiles:\n  office:\n    version: 2\n", "top level"},
+	} {
+		if _, err := config.Decode(strings.NewReader(tc.text), config.YAML); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("expected an error containing %q, got %v", tc.err, err)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/justenwalker/ddns/internal/keyring"
+)
+
+// secretRef matches a value that is entirely a reference to a secret, such as ${env:DYNU_PASSWORD}
+var secretRef = regexp.MustCompile(`^\$\{([a-z]+):(.*)\}$`)
+
+// keyringLookup reads a secret of the keyring of the operating system; replaced by tests
+var keyringLookup = keyring.Lookup
+
+// resolveSecret returns value, or the secret it references:
+//
+//   - ${file:PATH} is the content of the file, without its trailing newline
+//   - ${env:NAME} is the value of the environment variable, which must be set
+//   - ${keyring:NAME} is the secret stored under service "ddns" and NAME in the keyring of the operating system
+//
+// A value starting with "$${" is taken literally, without its first "$".
+func resolveSecret(value string) (string, error) {
+	if strings.HasPrefix(value, "$${") {
+		return value[1:], nil
+	}
+	m := secretRef.FindStringSubmatch(value)
+	if m == nil {
+		return value, nil
+	}
+	scheme, ref := m[1], m[2]
+	switch scheme {
+	case "file":
+		data, err := os.ReadFile(ref)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	case "env":
+		v, ok := os.LookupEnv(ref)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", ref)
+		}
+		return v, nil
+	case "keyring":
+		return keyringLookup(ref)
+	}
+	return "", fmt.Errorf("unknown secret store %q, want file, env or keyring", scheme)
+}
+
+// resolveSecrets replaces the references to secrets in the credentials of the providers and the webhooks,
+// tokens and keys of the notifiers, and of those of the profiles, by the secrets, returning a problem for each that cannot be read
+func (c *Config) resolveSecrets() Problems {
+	var ps Problems
+	providers := func(key, label string, m map[string]Provider) {
+		for _, name := range sortedKeys(m) {
+			p := m[name]
+			resolve := func(field string, value *string) {
+				v, err := resolveSecret(*value)
+				if err != nil {
+					ps = append(ps, Problem{Key: key + name + "." + field, Err: fmt.Errorf("%sprovider %q: %s: %v", label, name, field, err)})
+					return
+				}
+				*value = v
+			}
+			resolve("username", &p.Username)
+			resolve("password", &p.Password)
+			resolve("api_key", &p.APIKey)
+			p.Accounts = append([]Account(nil), p.Accounts...)
+			for i := range p.Accounts {
+				resolve(fmt.Sprintf("accounts.%d.username", i), &p.Accounts[i].Username)
+				resolve(fmt.Sprintf("accounts.%d.password", i), &p.Accounts[i].Password)
+				resolve(fmt.Sprintf("accounts.%d.api_key", i), &p.Accounts[i].APIKey)
+			}
+			m[name] = p
+		}
+	}
+	notifiers := func(key, label string, m map[string]Notifier) {
+		for _, name := range sortedKeys(m) {
+			n := m[name]
+			for _, f := range []struct {
+				field string
+				value *string
+			}{{"url", &n.URL}, {"secret", &n.Secret}, {"token", &n.Token}, {"user", &n.User}} {
+				v, err := resolveSecret(*f.value)
+				if err != nil {
+					ps = append(ps, Problem{Key: key + name + "." + f.field, Err: fmt.Errorf("%snotifier %q: %s: %v", label, name, f.field, err)})
+					continue
+				}
+				*f.value = v
+			}
+			m[name] = n
+		}
+	}
+	providers("providers.", "", c.Providers)
+	notifiers("notifiers.", "", c.Notifiers)
+	for _, name := range c.ProfileNames() {
+		if p := c.Profiles[name]; p != nil {
+			providers("profiles."+name+".providers.", fmt.Sprintf("profile %q: ", name), p.Providers)
+			notifiers("profiles."+name+".notifiers.", fmt.Sprintf("profile %q: ", name), p.Notifiers)
+		}
+	}
+	return ps
+}
@@ -0,0 +1,85 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Client sends requests to the control socket of a daemon
+type Client struct {
+	path string
+	hc   *http.Client
+}
+
+// NewClient returns a client of the daemon listening on the Unix socket at path
+func NewClient(path string) *Client {
+	return &Client{
+		path: path,
+		hc: &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", path)
+			},
+		}},
+	}
+}
+
+// Status returns the status of the daemon
+func (c *Client) Status(ctx context.Context) (*Status, error) {
+	var st Status
+	if err := c.do(ctx, http.MethodGet, "/status", &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// Update asks the daemon to detect the addresses and republish them to every provider now
+func (c *Client) Update(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/update", nil)
+}
+
+// Reload asks the daemon to re-read its configuration and restart
+func (c *Client) Reload(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/reload", nil)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, out interface{}) error {
+	// the host is ignored by the transport, which always dials the socket
+	req, err := http.NewRequestWithContext(ctx, method, "http://ddns"+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		var op *net.OpError
+		if errors.As(err, &op) && op.Op == "dial" {
+			return fmt.Errorf("control: cannot connect to the daemon at %s: %w", c.path, op.Err)
+		}
+		return fmt.Errorf("control: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return fmt.Errorf("control: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		var e errorResponse
+		if json.Unmarshal(data, &e) != nil || e.Error == "" {
+			e.Error = strings.TrimSpace(resp.Status)
+		}
+		return fmt.Errorf("control: %s", e.Error)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("control: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+// Package control lets local clients, such as ddns status, query a running daemon and send it commands over a
+// Unix domain socket. It is only supported on unix platforms: Windows has Unix sockets, but without per-user access
+// control, so Listen returns ErrNotSupported there. The API is HTTP with JSON responses:
+//
+//	GET  /status   the Status of the daemon, with the state of every provider
+//	POST /update   detect the addresses and republish them to every provider now
+//	POST /reload   re-read the configuration and restart the daemon
+//
+// Errors are reported as {"error":"..."} with a 4xx or 5xx status. The socket is only accessible to the user
+// running the daemon and, where the peer credentials of a connection are known, connections of other users
+// than root are rejected.
+package control // import "github.com/justenwalker/ddns/control"
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/justenwalker/ddns/state"
+)
+
+// Logger for printing the commands received
+type Logger interface {
+	Log(format string, v ...interface{})
+}
+
+// Option sets server options
+type Option func(*Server)
+
+// Reload handles the reload command with f, which returns an error if the daemon cannot be reloaded, such as
+// when its configuration is invalid. Without it, the command fails.
+func Reload(f func() error) Option {
+	return func(s *Server) {
+		s.reload = f
+	}
+}
+
+// Log logs the commands received to l
+func Log(l Logger) Option {
+	return func(s *Server) {
+		s.logger = l
+	}
+}
+
+// Status is the response of /status
+type Status struct {
+	PID     int       `json:"pid"`
+	Started time.Time `json:"started"`
+	// State merges the last snapshots saved by the daemons; it is nil until one of them finished its first step
+	State *state.Snapshot `json:"state"`
+}
+
+// Server answers the requests of the clients
+type Server struct {
+	reload  func() error
+	logger  Logger
+	started time.Time
+
+	mu        sync.Mutex
+	snapshots []*state.Snapshot
+	triggers  []chan struct{}
+}
+
+// New constructs a server for no daemons; each daemon is added with Step and Trigger
+func New(options ...Option) *Server {
+	s := &Server{started: time.Now()}
+	for _, opt := range options {
+		opt(s)
+	}
+	return s
+}
+
+// Step returns the function for a daemon to call after each of its steps, with daemon.AfterStep,
+// so that its state is part of the Status
+func (s *Server) Step() func(ctx context.Context, snap *state.Snapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshots = append(s.snapshots, nil)
+	i := len(s.snapshots) - 1
+	return func(ctx context.Context, snap *state.Snapshot) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.snapshots[i] = snap
+	}
+}
+
+// Trigger returns the channel that receives a value when a client asks to update now, for daemon.Trigger
+func (s *Server) Trigger() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ch := make(chan struct{}, 1)
+	s.triggers = append(s.triggers, ch)
+	return ch
+}
+
+// Status returns the status of the daemons
+func (s *Server) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Status{PID: os.Getpid(), Started: s.started, State: merge(s.snapshots)}
+}
+
+// merge combines the snapshots of several daemons, whose providers have distinct names
+func merge(snapshots []*state.Snapshot) *state.Snapshot {
+	var out *state.Snapshot
+	for _, snap := range snapshots {
+		if snap == nil {
+			continue
+		}
+		if out == nil {
+			out = state.New()
+		}
+		for name, p := range snap.Providers {
+			out.Providers[name] = p
+		}
+		if snap.DetectedAt.After(out.DetectedAt) {
+			out.Detected, out.DetectedAt = snap.Detected, snap.DetectedAt
+		}
+		if snap.DetectionFailures > out.DetectionFailures {
+			out.DetectionFailures, out.DetectionError = snap.DetectionFailures, snap.DetectionError
+		}
+		if out.NextRun.IsZero() || (!snap.NextRun.IsZero() && snap.NextRun.Before(out.NextRun)) {
+			out.NextRun = snap.NextRun
+		}
+		out.History = append(out.History, snap.History...)
+	}
+	if out != nil {
+		sort.SliceStable(out.History, func(i, j int) bool { return out.History[i].Time.Before(out.History[j].Time) })
+	}
+	return out
+}
+
+// ServeHTTP answers the requests of the API
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/status":
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, errors.New("use GET"))
+			return
+		}
+		writeJSON(w, http.StatusOK, s.Status())
+	case "/update":
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, errors.New("use POST"))
+			return
+		}
+		s.logf("control: updating now")
+		s.mu.Lock()
+		for _, ch := range s.triggers {
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	case "/reload":
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, errors.New("use POST"))
+			return
+		}
+		if s.reload == nil {
+			writeError(w, http.StatusNotImplemented, errors.New("the daemon cannot be reloaded"))
+			return
+		}
+		s.logf("control: reloading")
+		if err := s.reload(); err != nil {
+			writeError(w, http.StatusConflict, err)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("no such command %q", r.URL.Path))
+	}
+}
+
+func (s *Server) logf(format string, v ...interface{}) {
+	if s.logger != nil {
+		s.logger.Log(format, v...)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, errorResponse{Error: err.Error()})
+}
+
+// ErrNotSupported is returned by Listen on platforms where the socket cannot be restricted to the user, as
+// reported by Supported
+var ErrNotSupported = errors.New("control: not supported on this platform")
+
+// Listen serves the API on the Unix socket at path until the returned server is closed. A socket left behind by
+// a daemon that is no longer running is replaced.
+func (s *Server) Listen(path string) (*http.Server, error) {
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("control: another daemon is listening on %s", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("control: %w", err)
+		}
+	}
+	ln, err := s.listen(path)
+	if err != nil {
+		return nil, fmt.Errorf("control: %w", err)
+	}
+	srv := &http.Server{Handler: s, ReadHeaderTimeout: 10 * time.Second}
+	go srv.Serve(ln)
+	return srv, nil
+}
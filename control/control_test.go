@@ -0,0 +1,106 @@
+package control_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/justenwalker/ddns/control"
+	"github.com/justenwalker/ddns/state"
+)
+
+// socketPath returns a path short enough for a Unix socket, which t.TempDir may not be
+func socketPath(t *testing.T) string {
+	dir, err := os.MkdirTemp("", "ddns")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, "ctl.sock")
+}
+
+func TestServer(t *testing.T) {
+	if !control.Supported {
+		t.Skip("control socket not supported on this platform")
+	}
+	path := socketPath(t)
+	reloads := 0
+	reloadErr := error(nil)
+	s := control.New(control.Reload(func() error {
+		reloads++
+		return reloadErr
+	}))
+	home, office := s.Step(), s.Step()
+	trigger := s.Trigger()
+	srv, err := s.Listen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	if fi, err := os.Stat(path); err != nil || (runtime.GOOS != "windows" && fi.Mode().Perm() != 0o600) {
+		t.Errorf("expected a socket only the user can access, got %v, %v", fi, err)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("expected only the socket in its directory, got %v", entries)
+	}
+	if _, err := s.Listen(path); err == nil || !strings.Contains(err.Error(), "another daemon") {
+		t.Errorf("expected an error listening on the socket of a running daemon, got %v", err)
+	}
+
+	ctx := context.Background()
+	c := control.NewClient(path)
+	st, err := c.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.PID != os.Getpid() || st.State != nil {
+		t.Errorf("unexpected status before the first step: %+v", st)
+	}
+
+	t0 := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	hs := state.New()
+	hs.Detected, hs.DetectedAt, hs.NextRun = []net.IP{net.ParseIP("203.0.113.1")}, t0, t0.Add(5*time.Minute)
+	hs.Providers["dynu/home"] = state.Provider{IPs: hs.Detected, UpdatedAt: t0}
+	home(ctx, hs)
+	ws := state.New()
+	ws.Detected, ws.DetectedAt, ws.NextRun = []net.IP{net.ParseIP("203.0.113.2")}, t0.Add(time.Minute), t0.Add(time.Hour)
+	ws.Providers["office:dynu/office"] = state.Provider{Failures: 2, LastError: "badauth"}
+	office(ctx, ws)
+	if st, err = c.Status(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := st.State; got == nil || len(got.Providers) != 2 || !got.Detected[0].Equal(net.ParseIP("203.0.113.2")) ||
+		!got.NextRun.Equal(t0.Add(5*time.Minute)) || got.Providers["office:dynu/office"].LastError != "badauth" {
+		t.Errorf("unexpected merged state %+v", got)
+	}
+
+	if err := c.Update(ctx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-trigger:
+	default:
+		t.Error("expected the daemon to be triggered")
+	}
+
+	if err := c.Reload(ctx); err != nil || reloads != 1 {
+		t.Errorf("reload: %v after %d reloads", err, reloads)
+	}
+	reloadErr = errors.New("config.yaml: unknown key")
+	if err := c.Reload(ctx); err == nil || !strings.Contains(err.Error(), "unknown key") {
+		t.Errorf("expected the reload error, got %v", err)
+	}
+}
+
+func TestClientNotRunning(t *testing.T) {
+	path := socketPath(t)
+	if _, err := control.NewClient(path).Status(context.Background()); err == nil || !strings.Contains(err.Error(), "cannot connect") {
+		t.Errorf("expected an error without a daemon, got %v", err)
+	}
+}
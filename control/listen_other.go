@@ -0,0 +1,13 @@
+//go:build !unix
+
+package control
+
+import "net"
+
+// Supported is false on this platform: a Unix socket on Windows has no per-user access control
+const Supported = false
+
+// listen is not supported on this platform
+func (s *Server) listen(path string) (net.Listener, error) {
+	return nil, ErrNotSupported
+}
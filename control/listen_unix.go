@@ -0,0 +1,70 @@
+//go:build unix
+
+package control
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+)
+
+// Supported is true on this platform
+const Supported = true
+
+// listen binds the socket in a directory only the user can access, where its mode is set before it is moved to
+// path, so that other users cannot connect to it in between
+func (s *Server) listen(path string) (net.Listener, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".ddns")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	tmp := filepath.Join(dir, "s")
+	ln, err := net.ListenUnix("unix", &net.UnixAddr{Name: tmp, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	// the socket is removed from path on close, rather than from tmp
+	ln.SetUnlinkOnClose(false)
+	if err := os.Chmod(tmp, 0o600); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return &peerListener{UnixListener: ln, path: path, server: s}, nil
+}
+
+// peerListener rejects the connections of other users than that of the daemon and root
+type peerListener struct {
+	*net.UnixListener
+	path   string
+	server *Server
+}
+
+func (l *peerListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.AcceptUnix()
+		if err != nil {
+			return nil, err
+		}
+		uid, err := peerUID(conn)
+		if err == nil && (uid < 0 || uid == 0 || uid == os.Geteuid()) {
+			return conn, nil
+		}
+		if err != nil {
+			l.server.logf("control: rejected a connection: %v", err)
+		} else {
+			l.server.logf("control: rejected a connection of user %d", uid)
+		}
+		conn.Close()
+	}
+}
+
+func (l *peerListener) Close() error {
+	err := l.UnixListener.Close()
+	os.Remove(l.path)
+	return err
+}
@@ -0,0 +1,11 @@
+//go:build unix && !linux && !darwin && !freebsd
+
+package control
+
+import "net"
+
+// peerUID returns -1: the peer credentials are unknown, so connections are only restricted by the mode of the
+// socket
+func peerUID(conn *net.UnixConn) (int, error) {
+	return -1, nil
+}
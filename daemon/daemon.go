@@ -0,0 +1,1016 @@
+// Package daemon runs the detect and update loop: it periodically detects the host's addresses and
+// updates every provider whose published addresses differ, backing off providers that fail.
+package daemon // import "github.com/justenwalker/ddns/daemon"
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/justenwalker/ddns/budget"
+	"github.com/justenwalker/ddns/event"
+	"github.com/justenwalker/ddns/ipdetect"
+	"github.com/justenwalker/ddns/logging"
+	"github.com/justenwalker/ddns/power"
+	"github.com/justenwalker/ddns/schedule"
+	"github.com/justenwalker/ddns/state"
+	"github.com/justenwalker/ddns/trace"
+	"github.com/justenwalker/ddns/verify"
+)
+
+// Logger for printing debug logs from this package
+type Logger interface {
+	Log(format string, v ...interface{})
+}
+
+// ErrUnchanged may be returned by an Updater when the provider already had the addresses.
+// The daemon treats it as a successful update.
+var ErrUnchanged = errors.New("daemon: addresses unchanged")
+
+// Updater publishes addresses to a provider
+type Updater interface {
+	UpdateIP(ctx context.Context, ips []net.IP) error
+}
+
+// UpdaterFunc adapts a function to the Updater interface
+type UpdaterFunc func(ctx context.Context, ips []net.IP) error
+
+// UpdateIP calls f(ctx, ips)
+func (f UpdaterFunc) UpdateIP(ctx context.Context, ips []net.IP) error {
+	return f(ctx, ips)
+}
+
+// Publisher publishes events; *event.Bus implements it
+type Publisher interface {
+	Publish(ev event.Event)
+}
+
+// Provider is a named set of records updated together
+type Provider struct {
+	Name      string
+	Hostnames []string
+	Updater   Updater
+	// Debounce is how long a newly detected address must stay unchanged before it is published,
+	// so that a flapping connection does not cause a burst of updates
+	Debounce time.Duration
+	// Refresh republishes the addresses once they have been published this long, even if they are unchanged,
+	// for providers that expire records that are not updated regularly. Zero never refreshes.
+	Refresh time.Duration
+	// Backup is promoted once the provider has failed PromoteAfter consecutive times: it is kept updated
+	// alongside the provider until the provider recovers. It is typically another DNS host serving the same
+	// name, or a secondary hostname. The Backup of a Backup is ignored.
+	Backup *Provider
+	// PromoteAfter is the number of consecutive failures before Backup is promoted; the default is 3
+	PromoteAfter int
+	// SplitFamilies updates the IPv4 and IPv6 addresses independently, as providers named Name+"/ipv4"
+	// and Name+"/ipv6" with their own debounce, backoff and backup, so that a failing family neither blocks
+	// nor rolls back the other. The Updater is called with the addresses of one family at a time.
+	SplitFamilies bool
+	// IPv4 and IPv6 override Debounce and Refresh for one address family when SplitFamilies is set,
+	// e.g. to debounce IPv6 prefixes that rotate daily without delaying a stable IPv4 address
+	IPv4, IPv6 FamilyPolicy
+	// Interval overrides how often the provider is checked for new addresses, such as hourly for a static
+	// office address and every minute for a flapping LTE link. The daemon then detects the addresses as often
+	// as its most frequent provider needs, and checks those without an Interval every Interval of the daemon,
+	// or at every step of its Schedule. Wake-ups and Force check every provider at once. Zero uses the daemon's.
+	Interval time.Duration
+	// Backoff and BackoffMax override the delay before retrying after a failure, and its maximum, for this
+	// provider. Zero uses the daemon's.
+	Backoff, BackoffMax time.Duration
+}
+
+// FamilyPolicy overrides the Debounce and Refresh of a provider for one address family.
+// Zero fields keep the provider's setting.
+type FamilyPolicy struct {
+	Debounce time.Duration
+	Refresh  time.Duration
+}
+
+// Option sets daemon options
+type Option func(*Daemon)
+
+// Log enables daemon logging using the given Logger
+func Log(l Logger) Option {
+	return func(d *Daemon) {
+		d.logger = l
+	}
+}
+
+// Debug logs the decisions of the scheduler, such as when the next step is due, using the given Logger
+func Debug(l Logger) Option {
+	return func(d *Daemon) {
+		d.debug = l
+	}
+}
+
+// Interval sets how often addresses are detected; the default is 5 minutes
+func Interval(interval time.Duration) Option {
+	return func(d *Daemon) {
+		d.interval = interval
+	}
+}
+
+// Jitter delays each step by a random duration of up to max after the interval or scheduled time, so that
+// devices sharing a configuration do not query the detection services and providers at the same instant.
+// Retries after failures are not delayed.
+func Jitter(max time.Duration) Option {
+	return func(d *Daemon) {
+		d.jitter = max
+	}
+}
+
+// Schedule detects addresses at the times of s instead of every Interval; PowerAware does not stretch it.
+// Retries after failures and wake-ups are not affected.
+func Schedule(s schedule.Schedule) Option {
+	return func(d *Daemon) {
+		d.schedule = s
+	}
+}
+
+// Backoff sets the delay before retrying after a failure. It doubles with each consecutive failure up to max.
+// The default is 30 seconds up to 30 minutes.
+func Backoff(min, max time.Duration) Option {
+	return func(d *Daemon) {
+		d.minBackoff = min
+		d.maxBackoff = max
+	}
+}
+
+// Events publishes the daemon's Detected, Changed, Updated, Failed, BackedOff and Recovered events to p
+func Events(p Publisher) Option {
+	return func(d *Daemon) {
+		d.events = p
+	}
+}
+
+// Persist restores the published addresses, backoff and history from store when the daemon starts,
+// and saves them after every update
+func Persist(store state.Store) Option {
+	return func(d *Daemon) {
+		d.store = store
+	}
+}
+
+// Force republishes the addresses of every provider at the first step, even if they are already published,
+// such as after the records were changed outside of ddns. Providers backing off still wait for their retry.
+func Force() Option {
+	return func(d *Daemon) {
+		for _, p := range d.all() {
+			p.forced = true
+		}
+	}
+}
+
+// PowerAware multiplies the interval by stretch while sensor reports the host is on battery or a metered network.
+// Retries after failures are not stretched.
+func PowerAware(sensor power.Sensor, stretch float64) Option {
+	return func(d *Daemon) {
+		d.power = sensor
+		d.stretch = stretch
+	}
+}
+
+// Budget skips detection while b has no essential traffic left, instead of failing and backing off,
+// and resumes when it resets. Requests are charged to b by its Transport, not by the daemon.
+func Budget(b *budget.Budget) Option {
+	return func(d *Daemon) {
+		d.budget = b
+	}
+}
+
+// Wake steps the daemon as soon as a value is received on ch instead of waiting for the interval,
+// so that event-driven detection such as a netwatch.Watcher can replace frequent polling
+func Wake(ch <-chan struct{}) Option {
+	return func(d *Daemon) {
+		d.wake = ch
+	}
+}
+
+// Trigger steps the daemon as soon as a value is received on ch, like Wake, and republishes the addresses of
+// every provider as Force does, without waiting for the retries of those backing off, such as when asked to
+// update now over a control socket
+func Trigger(ch <-chan struct{}) Option {
+	return func(d *Daemon) {
+		d.trigger = ch
+	}
+}
+
+// WallClock re-checks the wall clock every check while waiting for the next step.
+// Timers run on a monotonic clock that stops while the system is suspended or an Android phone dozes,
+// so without this a 5 minute wait can last for hours after the device wakes up.
+func WallClock(check time.Duration) Option {
+	return func(d *Daemon) {
+		d.wallCheck = check
+	}
+}
+
+// Grace lets a step in progress when Run's context is canceled finish for up to grace, so that stopping the daemon,
+// e.g. on SIGTERM in a container, does not abort an update halfway. By default the step is aborted immediately.
+func Grace(grace time.Duration) Option {
+	return func(d *Daemon) {
+		d.grace = grace
+	}
+}
+
+// Trace records each step that detects the addresses with a span of t, with the detection and the update of
+// each provider as its children. The context of the step is passed to the source and the updaters, so that the
+// requests they make through a trace.Transport are recorded as well.
+func Trace(t *trace.Tracer) Option {
+	return func(d *Daemon) {
+		d.tracer = t
+	}
+}
+
+// AfterStep calls f with the state of the daemon at the end of each step of Run, once it has been saved, such as
+// to report that the daemon is still running. The context is that of the step. The functions of several AfterStep
+// options are called in order.
+func AfterStep(f func(ctx context.Context, s *state.Snapshot)) Option {
+	return func(d *Daemon) {
+		prev := d.afterStep
+		if prev == nil {
+			d.afterStep = f
+			return
+		}
+		d.afterStep = func(ctx context.Context, s *state.Snapshot) {
+			prev(ctx, s)
+			f(ctx, s)
+		}
+	}
+}
+
+// Verify checks the addresses after each update of a provider with check, publishing a Verified event with its
+// result, and checks them again at each step until they pass. A verify.Guard with the given policy then restores
+// the previous addresses when they fail: the daemon publishes the rolled back addresses again only once the
+// blackout of the policy is over.
+func Verify(check func(ctx context.Context, ips []net.IP) error, policy verify.Policy) Option {
+	return func(d *Daemon) {
+		d.verify = check
+		d.rollback = policy
+	}
+}
+
+// Clock replaces time.Now for simulations that call Step with a simulated time, such as ddns daemon --chaos.
+// Run still waits in real time.
+func Clock(now func() time.Time) Option {
+	return func(d *Daemon) {
+		d.now = now
+	}
+}
+
+// Daemon runs the update loop
+type Daemon struct {
+	logger     Logger
+	debug      Logger
+	grace      time.Duration
+	power      power.Sensor
+	stretch    float64
+	wake       <-chan struct{}
+	trigger    <-chan struct{}
+	wallCheck  time.Duration
+	budget     *budget.Budget
+	events     Publisher
+	tracer     *trace.Tracer
+	verify     func(ctx context.Context, ips []net.IP) error
+	rollback   verify.Policy
+	store      state.Store
+	afterStep  func(ctx context.Context, s *state.Snapshot)
+	history    []state.Entry
+	interval   time.Duration
+	jitter     time.Duration
+	random     func(n int64) int64
+	schedule   schedule.Schedule
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	source     ipdetect.Source
+	providers  []*providerState
+	// paced is set if a provider has its own Interval, so providers are checked at their own pace
+	paced bool
+	// woken is set when the daemon is woken, so every provider is checked at the next step
+	woken      bool
+	detect     backoff
+	detectErr  string
+	detected   []net.IP
+	detectedAt time.Time
+	nextRun    time.Time
+	now        func() time.Time
+}
+
+type providerState struct {
+	Provider
+	// family is "ipv4" or "ipv6" if the provider only publishes addresses of that family
+	family    string
+	published []net.IP
+	updatedAt time.Time
+	backoff   backoff
+	// pending is the address set waiting out the debounce period since pendingSince
+	pending      []net.IP
+	pendingSince time.Time
+	// checkedAt is when the provider was last checked for new addresses, if it is paced
+	checkedAt time.Time
+	backup    *providerState
+	promoted  bool
+	lastErr   string
+	lastErrAt time.Time
+	// forced publishes the addresses at the next update even if they are unchanged
+	forced bool
+	// guard rolls the provider back when the addresses fail the check of the Verify option. verified are the
+	// addresses it restores, those published before the unverified ones.
+	guard      *verify.Guard
+	verified   []net.IP
+	unverified bool
+}
+
+func (p *providerState) promoteAfter() int {
+	if p.PromoteAfter > 0 {
+		return p.PromoteAfter
+	}
+	return 3
+}
+
+// settled returns true once ips have been detected unchanged for the debounce period.
+// Otherwise it returns the time at which they will have settled.
+func (p *providerState) settled(now time.Time, ips []net.IP) (bool, time.Time) {
+	if p.Debounce <= 0 || p.published == nil {
+		return true, time.Time{}
+	}
+	if !sameIPs(p.pending, ips) {
+		p.pending = ips
+		p.pendingSince = now
+	}
+	at := p.pendingSince.Add(p.Debounce)
+	return !now.Before(at), at
+}
+
+// backoff tracks consecutive failures and when the next attempt is allowed
+type backoff struct {
+	failures int
+	next     time.Time
+}
+
+func (b *backoff) fail(now time.Time, min, max time.Duration) {
+	b.failures++
+	delay := min
+	for i := 1; i < b.failures && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	b.next = now.Add(delay)
+}
+
+// New constructs a daemon that detects addresses with src and publishes them to providers
+func New(src ipdetect.Source, providers []Provider, options ...Option) *Daemon {
+	d := &Daemon{
+		source:     src,
+		interval:   5 * time.Minute,
+		minBackoff: 30 * time.Second,
+		maxBackoff: 30 * time.Minute,
+		random:     rand.Int63n,
+		now:        time.Now,
+	}
+	for _, p := range providers {
+		if p.Interval > 0 {
+			d.paced = true
+		}
+		if !p.SplitFamilies {
+			d.providers = append(d.providers, newProviderState(p, ""))
+			continue
+		}
+		for _, family := range []string{"ipv4", "ipv6"} {
+			d.providers = append(d.providers, newProviderState(p, family))
+		}
+	}
+	for _, opt := range options {
+		opt(d)
+	}
+	if d.verify != nil {
+		for _, p := range d.all() {
+			d.guard(p)
+		}
+	}
+	return d
+}
+
+// guard sets the guard of p, which updates the addresses the daemon holds as published when it rolls p back
+func (d *Daemon) guard(p *providerState) {
+	p.guard = &verify.Guard{
+		Provider: p.Name,
+		Updater: verify.UpdaterFunc(func(ctx context.Context, ips []net.IP) error {
+			if err := p.Updater.UpdateIP(ctx, ips); err != nil && !errors.Is(err, ErrUnchanged) {
+				return err
+			}
+			return nil
+		}),
+		Policy: d.rollback,
+		Publisher: publisherFunc(func(ev event.Event) {
+			if ev.Type == event.RolledBack {
+				p.published, p.updatedAt, p.unverified = ev.NewIPs, d.now(), false
+				d.logAttrs(slog.LevelWarn, providerAttrs(p, slog.Any("ips", ipStrings(ev.NewIPs)), slog.String("error", ev.Err.Error())),
+					"daemon: %s: rolled back to %v: %v", p.Name, ev.NewIPs, ev.Err)
+				d.record(state.Changed, ev)
+			}
+			d.publish(ev)
+		}),
+	}
+}
+
+// publisherFunc adapts a function to the Publisher interface
+type publisherFunc func(ev event.Event)
+
+func (f publisherFunc) Publish(ev event.Event) {
+	f(ev)
+}
+
+// newProviderState returns the state of p, limited to the given address family if it is not empty
+func newProviderState(p Provider, family string) *providerState {
+	ps := &providerState{Provider: p, family: family}
+	if family != "" {
+		ps.Name += "/" + family
+		fp := p.IPv4
+		if family == "ipv6" {
+			fp = p.IPv6
+		}
+		if fp.Debounce > 0 {
+			ps.Debounce = fp.Debounce
+		}
+		if fp.Refresh > 0 {
+			ps.Refresh = fp.Refresh
+		}
+	}
+	if p.Backup != nil {
+		backup := *p.Backup
+		backup.Backup = nil
+		// the backup is retried as the provider it stands in for
+		if backup.Backoff <= 0 {
+			backup.Backoff = p.Backoff
+		}
+		if backup.BackoffMax <= 0 {
+			backup.BackoffMax = p.BackoffMax
+		}
+		ps.backup = &providerState{Provider: backup, family: family}
+		if family != "" {
+			ps.backup.Name += "/" + family
+		}
+	}
+	return ps
+}
+
+func (d *Daemon) logf(format string, v ...interface{}) {
+	if d.logger != nil {
+		d.logger.Log(format, v...)
+	}
+}
+
+// logAttrs logs the message with its level and fields; see logging.Print
+func (d *Daemon) logAttrs(level slog.Level, attrs []slog.Attr, format string, v ...interface{}) {
+	if d.logger != nil {
+		logging.Print(d.logger, level, attrs, format, v...)
+	}
+}
+
+// providerAttrs returns the fields identifying p in the logs
+func providerAttrs(p *providerState, attrs ...slog.Attr) []slog.Attr {
+	return append([]slog.Attr{slog.String("provider", p.Name), slog.Any("hostnames", p.Hostnames)}, attrs...)
+}
+
+func (d *Daemon) debugf(format string, v ...interface{}) {
+	if d.debug != nil {
+		d.debug.Log(format, v...)
+	}
+}
+
+func (d *Daemon) publish(ev event.Event) {
+	if d.events != nil {
+		ev.Time = d.now()
+		d.events.Publish(ev)
+	}
+}
+
+// Run loops until ctx is done, then publishes a Stopped event for each provider and returns ctx.Err().
+// It returns an error without looping if the persisted state cannot be loaded.
+func (d *Daemon) Run(ctx context.Context) error {
+	if d.store != nil {
+		s, err := d.store.Load()
+		if err != nil {
+			return err
+		}
+		d.Restore(s)
+	}
+	for {
+		stepCtx, cancel := d.stepContext(ctx)
+		wait := d.Step(stepCtx)
+		d.nextRun = d.now().Add(wait)
+		d.debugf("daemon: next step in %v, at %v", wait, d.nextRun.Format(time.RFC3339))
+		d.save()
+		if d.afterStep != nil {
+			d.afterStep(stepCtx, d.Snapshot())
+		}
+		cancel()
+		if err := d.sleep(ctx, wait); err != nil {
+			d.stopped()
+			return err
+		}
+	}
+}
+
+// stopped publishes the final status of each provider, and of its backup while promoted
+func (d *Daemon) stopped() {
+	for _, p := range d.providers {
+		d.publishStatus(p)
+		if p.promoted {
+			d.publishStatus(p.backup)
+		}
+	}
+}
+
+func (d *Daemon) publishStatus(p *providerState) {
+	ev := event.Event{Type: event.Stopped, Provider: p.Name, Hostnames: p.Hostnames, NewIPs: p.published}
+	if p.backoff.failures > 0 && p.lastErr != "" {
+		ev.Err = errors.New(p.lastErr)
+	}
+	d.publish(ev)
+}
+
+// stepContext returns the context of a step, which outlives ctx by the grace period
+func (d *Daemon) stepContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if d.grace <= 0 {
+		return ctx, func() {}
+	}
+	stepCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, func() {
+		d.logf("daemon: stopping; letting the current step finish for up to %v", d.grace)
+		time.AfterFunc(d.grace, cancel)
+	})
+	return stepCtx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// sleep waits for wait to pass, or until the daemon is woken or ctx is done
+func (d *Daemon) sleep(ctx context.Context, wait time.Duration) error {
+	// Round(0) strips the monotonic reading, so time.Until measures the wall clock
+	deadline := time.Now().Round(0).Add(wait)
+	for {
+		timeout := wait
+		if d.wallCheck > 0 && timeout > d.wallCheck {
+			timeout = d.wallCheck
+		}
+		t := time.NewTimer(timeout)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-d.wake:
+			t.Stop()
+			d.logf("daemon: woken early")
+			// a change bypasses any detection backoff, and the interval of every provider
+			d.detect, d.detectErr = backoff{}, ""
+			d.woken = true
+			return nil
+		case <-d.trigger:
+			t.Stop()
+			d.logf("daemon: triggered, republishing the addresses of every provider")
+			d.detect, d.detectErr = backoff{}, ""
+			d.woken = true
+			for _, p := range d.all() {
+				p.forced = true
+				p.backoff.next = time.Time{}
+			}
+			return nil
+		case <-t.C:
+		}
+		if d.wallCheck <= 0 {
+			return nil
+		}
+		if wait = time.Until(deadline); wait <= 0 {
+			if -wait > d.wallCheck {
+				d.logf("daemon: step is %v late, the system was probably suspended", -wait)
+			}
+			return nil
+		}
+	}
+}
+
+// Step detects the addresses once, updates the providers that need it, and returns how long to wait
+// before the next step. The wait is shortened when a failed provider is due for a retry.
+func (d *Daemon) Step(ctx context.Context) time.Duration {
+	now := d.now()
+	stretch := d.currentStretch(ctx)
+	jitter := d.randomJitter()
+	next := d.nextStep(now, stretch, jitter)
+	if now.Before(d.detect.next) {
+		return d.detect.next.Sub(now)
+	}
+	if d.budget != nil {
+		var exhausted *budget.ExhaustedError
+		if err := d.budget.Check(ctx, budget.Essential); errors.As(err, &exhausted) {
+			d.logAttrs(slog.LevelWarn, []slog.Attr{slog.String("error", err.Error())}, "daemon: skipping detection: %v", err)
+			if exhausted.Reset.Before(next) {
+				return next.Sub(now)
+			}
+			return exhausted.Reset.Sub(now)
+		}
+	}
+	ctx, span := d.tracer.Start(ctx, "ddns.step")
+	defer span.End()
+	start := time.Now()
+	ips, err := d.detectIPs(ctx)
+	took := time.Since(start)
+	if err != nil {
+		span.Fail(err)
+		d.detect.fail(now, d.minBackoff, d.maxBackoff)
+		d.detectErr = err.Error()
+		d.logAttrs(slog.LevelWarn, []slog.Attr{
+			slog.Int("attempt", d.detect.failures),
+			slog.Time("retry_at", d.detect.next),
+			slog.String("error", err.Error()),
+		}, "daemon: detection failed (attempt %d), retrying at %v: %v", d.detect.failures, d.detect.next, err)
+		d.publish(event.Event{Type: event.BackedOff, Err: err, Attempt: d.detect.failures, RetryAt: d.detect.next})
+		return d.detect.next.Sub(now)
+	}
+	d.detect, d.detectErr = backoff{}, ""
+	ips = sortIPs(ips)
+	d.detected, d.detectedAt = ips, now
+	d.publish(event.Event{Type: event.Detected, NewIPs: ips, Duration: took})
+
+	woken := d.woken
+	d.woken = false
+	for _, p := range d.providers {
+		if d.paced {
+			ok, at := d.due(now, p, woken, stretch)
+			if !at.IsZero() && at.Add(jitter).Before(next) {
+				next = at.Add(jitter)
+			}
+			if !ok {
+				d.debugf("daemon: %s: not due for a check until %v", p.Name, at)
+				continue
+			}
+		}
+		next = d.stepProvider(ctx, now, next, p, ips)
+		if p.promoted {
+			next = d.stepProvider(ctx, now, next, p.backup, ips)
+		}
+	}
+	return next.Sub(now)
+}
+
+// detectIPs detects the addresses with the source, in a span of the step
+func (d *Daemon) detectIPs(ctx context.Context) ([]net.IP, error) {
+	ctx, span := trace.Start(ctx, "ddns.detect")
+	defer span.End()
+	ips, err := d.source.Detect(ctx)
+	span.Fail(err)
+	span.SetAttributes(trace.IPs("ddns.ips", ips))
+	return ips, err
+}
+
+// due returns true if p is to be checked for new addresses now, and when it is due for its next check, or the
+// zero time if it follows the Schedule. Providers retrying, debouncing or forced are always due, since they wait
+// on their own.
+func (d *Daemon) due(now time.Time, p *providerState, woken bool, stretch float64) (bool, time.Time) {
+	interval := p.Interval
+	if interval <= 0 {
+		if d.schedule != nil {
+			return true, time.Time{}
+		}
+		interval = d.interval
+	}
+	interval = time.Duration(float64(interval) * stretch)
+	if next := p.checkedAt.Add(interval); !p.checkedAt.IsZero() && now.Before(next) &&
+		!woken && !p.forced && p.backoff.failures == 0 && p.pending == nil {
+		return false, next
+	}
+	p.checkedAt = now
+	return true, now.Add(interval)
+}
+
+// nextStep returns when to step next if nothing needs a retry sooner
+func (d *Daemon) nextStep(now time.Time, stretch float64, jitter time.Duration) time.Time {
+	if d.schedule != nil {
+		if next := d.schedule.Next(now); !next.IsZero() {
+			return next.Add(jitter)
+		}
+	}
+	return now.Add(time.Duration(float64(d.interval)*stretch) + jitter)
+}
+
+// randomJitter returns the delay added to the next step, up to the jitter
+func (d *Daemon) randomJitter() time.Duration {
+	if d.jitter <= 0 {
+		return 0
+	}
+	return time.Duration(d.random(int64(d.jitter)))
+}
+
+// currentStretch returns what the intervals are multiplied by: the stretch if the host is power or cost
+// constrained, or 1
+func (d *Daemon) currentStretch(ctx context.Context) float64 {
+	if d.power == nil || d.stretch <= 1 {
+		return 1
+	}
+	st, err := d.power.State(ctx)
+	if err != nil {
+		d.logAttrs(slog.LevelWarn, []slog.Attr{slog.String("error", err.Error())}, "daemon: reading power state: %v", err)
+		return 1
+	}
+	if !st.Constrained() {
+		return 1
+	}
+	return d.stretch
+}
+
+// stepProvider updates p if its published addresses differ from ips, or it is forced, and it is not backing off
+// or debouncing.
+// It returns next, brought forward if p needs to be revisited sooner.
+func (d *Daemon) stepProvider(ctx context.Context, now, next time.Time, p *providerState, ips []net.IP) time.Time {
+	if p.family != "" {
+		if ips = familyIPs(ips, p.family); len(ips) == 0 {
+			// keep the published addresses while the family is not detected
+			return next
+		}
+	}
+	switch {
+	case p.forced:
+		p.pending = nil
+	case p.backoff.failures == 0 && sameIPs(p.published, ips):
+		p.pending = nil
+		if p.unverified {
+			d.verifyUpdate(ctx, p, ips)
+			return next
+		}
+		if p.Refresh <= 0 {
+			d.debugf("daemon: %s: %v already published", p.Name, ips)
+			return next
+		}
+		due := p.updatedAt.Add(p.Refresh)
+		if now.Before(due) {
+			if due.Before(next) {
+				next = due
+			}
+			return next
+		}
+		d.logAttrs(slog.LevelInfo, providerAttrs(p, slog.Time("published_at", p.updatedAt)),
+			"daemon: %s: refreshing addresses published at %v", p.Name, p.updatedAt)
+	default:
+		if ok, at := p.settled(now, ips); !ok {
+			d.debugf("daemon: %s: waiting for %v to settle until %v", p.Name, ips, at)
+			if at.Before(next) {
+				next = at
+			}
+			return next
+		}
+	}
+	if p.guard != nil && !sameIPs(p.published, ips) && p.guard.Blocked(ips) {
+		d.debugf("daemon: %s: %v were rolled back, waiting out the blackout", p.Name, ips)
+		return next
+	}
+	if now.Before(p.backoff.next) {
+		d.debugf("daemon: %s: backing off until %v", p.Name, p.backoff.next)
+		if p.backoff.next.Before(next) {
+			next = p.backoff.next
+		}
+		return next
+	}
+	d.update(ctx, p, ips)
+	if p.backoff.failures > 0 && p.backoff.next.Before(next) {
+		next = p.backoff.next
+	}
+	return next
+}
+
+func (d *Daemon) update(ctx context.Context, p *providerState, ips []net.IP) {
+	p.forced = false
+	ev := event.Event{Provider: p.Name, Hostnames: p.Hostnames, OldIPs: p.published, NewIPs: ips}
+	ctx, span := trace.Start(ctx, "ddns.update",
+		trace.String("ddns.provider", p.Name),
+		trace.Strings("ddns.hostnames", p.Hostnames),
+		trace.IPs("ddns.old_ips", p.published),
+		trace.IPs("ddns.new_ips", ips),
+	)
+	defer span.End()
+	start := time.Now()
+	err := p.Updater.UpdateIP(ctx, ips)
+	took := time.Since(start)
+	ev.Duration = took
+	if errors.Is(err, ErrUnchanged) {
+		span.SetAttributes(trace.Bool("ddns.unchanged", true))
+	}
+	if err != nil && !errors.Is(err, ErrUnchanged) {
+		span.Fail(err)
+		min, max := d.minBackoff, d.maxBackoff
+		if p.Backoff > 0 {
+			min = p.Backoff
+		}
+		if p.BackoffMax > 0 {
+			max = p.BackoffMax
+		}
+		if max < min {
+			max = min
+		}
+		p.backoff.fail(d.now(), min, max)
+		p.lastErr, p.lastErrAt = err.Error(), d.now()
+		d.logAttrs(slog.LevelWarn, providerAttrs(p,
+			slog.Int("attempt", p.backoff.failures),
+			slog.Time("retry_at", p.backoff.next),
+			slog.Duration("duration", took),
+			slog.String("error", err.Error()),
+		), "daemon: %s: update failed (attempt %d), retrying at %v: %v", p.Name, p.backoff.failures, p.backoff.next, err)
+		ev.Type, ev.Err = event.Failed, err
+		d.publish(ev)
+		d.record(state.Failed, ev)
+		d.publish(event.Event{
+			Type:      event.BackedOff,
+			Provider:  p.Name,
+			Hostnames: p.Hostnames,
+			Err:       err,
+			Attempt:   p.backoff.failures,
+			RetryAt:   p.backoff.next,
+		})
+		if p.backup != nil && !p.promoted && p.backoff.failures >= p.promoteAfter() {
+			p.promoted = true
+			d.logAttrs(slog.LevelWarn, providerAttrs(p, slog.String("backup", p.backup.Name), slog.Int("failures", p.backoff.failures)),
+				"daemon: %s: promoting backup %s after %d failures", p.Name, p.backup.Name, p.backoff.failures)
+			d.publish(event.Event{
+				Type:      event.Promoted,
+				Provider:  p.backup.Name,
+				Hostnames: p.backup.Hostnames,
+				NewIPs:    ips,
+				Err:       err,
+			})
+		}
+		return
+	}
+	if p.promoted {
+		p.promoted = false
+		d.logAttrs(slog.LevelInfo, providerAttrs(p, slog.String("backup", p.backup.Name)),
+			"daemon: %s: recovered, demoting backup %s", p.Name, p.backup.Name)
+	}
+	recovered := p.backoff.failures > 0
+	changed := !sameIPs(p.published, ips)
+	if !p.unverified {
+		p.verified = p.published
+	}
+	p.backoff = backoff{}
+	p.published = ips
+	p.updatedAt = d.now()
+	p.pending = nil
+	d.logAttrs(slog.LevelInfo, providerAttrs(p, slog.Any("ips", ipStrings(ips)), slog.Duration("duration", took)),
+		"daemon: %s: published %v", p.Name, ips)
+	if changed {
+		ev.Type = event.Changed
+		d.publish(ev)
+	}
+	switch {
+	case changed:
+		d.record(state.Changed, ev)
+	case recovered:
+		d.record(state.Recovered, ev)
+	}
+	ev.Type = event.Updated
+	d.publish(ev)
+	if recovered {
+		ev.Type = event.Recovered
+		d.publish(ev)
+	}
+	if d.verify != nil {
+		d.verifyUpdate(ctx, p, ips)
+	}
+}
+
+// verifyUpdate checks the addresses published to p and hands the result to its guard
+func (d *Daemon) verifyUpdate(ctx context.Context, p *providerState, ips []net.IP) {
+	err := d.verify(ctx, ips)
+	p.unverified = err != nil
+	ev := event.Event{Type: event.Verified, Provider: p.Name, Hostnames: p.Hostnames, NewIPs: ips, Err: err}
+	if !sameIPs(p.verified, ips) {
+		// there is nothing to roll back to after refreshing verified addresses
+		ev.OldIPs = p.verified
+	}
+	if err != nil {
+		d.logAttrs(slog.LevelWarn, providerAttrs(p, slog.Any("ips", ipStrings(ips)), slog.String("error", err.Error())),
+			"daemon: %s: verification of %v failed: %v", p.Name, ips, err)
+	}
+	d.publish(ev)
+	ev.Time = d.now()
+	p.guard.Handle(ctx, ev)
+}
+
+// record adds the outcome of an update to the history and saves the state
+func (d *Daemon) record(typ string, ev event.Event) {
+	e := state.Entry{Time: d.now(), Type: typ, Provider: ev.Provider, OldIPs: ev.OldIPs, NewIPs: ev.NewIPs}
+	if ev.Err != nil {
+		e.Error = ev.Err.Error()
+	}
+	d.history = append(d.history, e)
+	if n := len(d.history) - state.DefaultHistorySize; n > 0 {
+		d.history = append([]state.Entry(nil), d.history[n:]...)
+	}
+	d.save()
+}
+
+// save writes the state to the store, if any
+func (d *Daemon) save() {
+	if d.store == nil {
+		return
+	}
+	if err := d.store.Save(d.Snapshot()); err != nil {
+		d.logAttrs(slog.LevelError, []slog.Attr{slog.String("error", err.Error())}, "daemon: saving state: %v", err)
+	}
+}
+
+// Snapshot returns the current state of the daemon
+func (d *Daemon) Snapshot() *state.Snapshot {
+	s := state.New()
+	s.Detected, s.DetectedAt, s.NextRun = d.detected, d.detectedAt, d.nextRun
+	s.DetectionFailures, s.DetectionError = d.detect.failures, d.detectErr
+	for _, p := range d.all() {
+		s.Providers[p.Name] = state.Provider{
+			Hostnames:   p.Hostnames,
+			IPs:         p.published,
+			UpdatedAt:   p.updatedAt,
+			Failures:    p.backoff.failures,
+			RetryAt:     p.backoff.next,
+			Promoted:    p.promoted,
+			LastError:   p.lastErr,
+			LastErrorAt: p.lastErrAt,
+		}
+	}
+	s.History = append(s.History, d.history...)
+	return s
+}
+
+// Restore replaces the state of the daemon's providers with those in s.
+// Providers missing from s are left unchanged, and providers in s that the daemon does not have are ignored.
+func (d *Daemon) Restore(s *state.Snapshot) {
+	for _, p := range d.all() {
+		ps, ok := s.Providers[p.Name]
+		if !ok {
+			continue
+		}
+		p.published = sortIPs(ps.IPs)
+		p.updatedAt = ps.UpdatedAt
+		p.backoff = backoff{failures: ps.Failures, next: ps.RetryAt}
+		p.promoted = ps.Promoted && p.backup != nil
+		p.lastErr, p.lastErrAt = ps.LastError, ps.LastErrorAt
+	}
+	d.history = append([]state.Entry(nil), s.History...)
+}
+
+// all returns the providers and their backups
+func (d *Daemon) all() []*providerState {
+	var all []*providerState
+	for _, p := range d.providers {
+		all = append(all, p)
+		if p.backup != nil {
+			all = append(all, p.backup)
+		}
+	}
+	return all
+}
+
+// familyIPs returns the addresses of ips in family, "ipv4" or "ipv6"
+func familyIPs(ips []net.IP, family string) []net.IP {
+	var out []net.IP
+	for _, ip := range ips {
+		if (ip.To4() != nil) == (family == "ipv4") {
+			out = append(out, ip)
+		}
+	}
+	return out
+}
+
+// ipStrings returns the addresses as strings, for the logs
+func ipStrings(ips []net.IP) []string {
+	s := make([]string, len(ips))
+	for i, ip := range ips {
+		s[i] = ip.String()
+	}
+	return s
+}
+
+// sortIPs returns a sorted copy of ips so that the order reported by a source does not count as a change
+func sortIPs(ips []net.IP) []net.IP {
+	out := append([]net.IP(nil), ips...)
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].String() < out[j].String()
+	})
+	return out
+}
+
+func sameIPs(a, b []net.IP) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,665 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/justenwalker/ddns/budget"
+	"github.com/justenwalker/ddns/event"
+	"github.com/justenwalker/ddns/ipdetect"
+	"github.com/justenwalker/ddns/power"
+	"github.com/justenwalker/ddns/schedule"
+	"github.com/justenwalker/ddns/state"
+	"github.com/justenwalker/ddns/trace"
+	"github.com/justenwalker/ddns/verify"
+)
+
+type recorder []event.Type
+
+func (r *recorder) Publish(ev event.Event) {
+	*r = append(*r, ev.Type)
+}
+
+func TestStep(t *testing.T) {
+	ctx := context.Background()
+	ip := net.ParseIP("203.0.113.1").To4()
+	src := ipdetect.SourceFunc(func(ctx context.Context) ([]net.IP, error) {
+		return []net.IP{ip}, nil
+	})
+	var updates int
+	var fail bool
+	p := Provider{Name: "dynu", Updater: UpdaterFunc(func(ctx context.Context, ips []net.IP) error {
+		updates++
+		if fail {
+			return errors.New("servererror")
+		}
+		return nil
+	})}
+	var events recorder
+	now := time.Unix(1000, 0)
+	d := New(src, []Provider{p}, Interval(time.Minute), Backoff(10*time.Second, time.Minute), Events(&events))
+	d.now = func() time.Time { return now }
+
+	if wait := d.Step(ctx); wait != time.Minute || updates != 1 {
+		t.Fatalf("first step: wait %v, %d updates", wait, updates)
+	}
+	d.Step(ctx)
+	if updates != 1 {
+		t.Fatalf("expected no update while the address is unchanged, got %d", updates)
+	}
+
+	ip = net.ParseIP("203.0.113.2").To4()
+	fail = true
+	if wait := d.Step(ctx); wait != 10*time.Second {
+		t.Errorf("expected the retry after the minimum backoff, got %v", wait)
+	}
+	now = now.Add(5 * time.Second)
+	d.Step(ctx)
+	if updates != 2 {
+		t.Errorf("expected no retry during backoff, got %d updates", updates)
+	}
+	now = now.Add(5 * time.Second)
+	d.Step(ctx)
+	if wait := d.providers[0].backoff.next.Sub(now); updates != 3 || wait != 20*time.Second {
+		t.Errorf("expected a retry with doubled backoff, got %d updates and %v", updates, wait)
+	}
+	fail = false
+	now = now.Add(20 * time.Second)
+	d.Step(ctx)
+
+	want := []event.Type{
+		event.Detected, event.Changed, event.Updated,
+		event.Detected,
+		event.Detected, event.Failed, event.BackedOff,
+		event.Detected,
+		event.Detected, event.Failed, event.BackedOff,
+		event.Detected, event.Changed, event.Updated, event.Recovered,
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got events %v, want %v", events, want)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Fatalf("got events %v, want %v", events, want)
+		}
+	}
+}
+
+func TestStepDebounce(t *testing.T) {
+	ctx := context.Background()
+	ip := net.ParseIP("203.0.113.1").To4()
+	src := ipdetect.SourceFunc(func(ctx context.Context) ([]net.IP, error) {
+		return []net.IP{ip}, nil
+	})
+	var published []net.IP
+	p := Provider{Name: "dynu", Debounce: time.Minute, Updater: UpdaterFunc(func(ctx context.Context, ips []net.IP) error {
+		published = ips
+		return nil
+	})}
+	now := time.Unix(1000, 0)
+	d := New(src, []Provider{p}, Interval(5*time.Minute))
+	d.now = func() time.Time { return now }
+
+	d.Step(ctx)
+	if published == nil {
+		t.Fatal("expected the first address to be published immediately")
+	}
+	ip = net.ParseIP("203.0.113.2").To4()
+	if wait := d.Step(ctx); wait != time.Minute {
+		t.Errorf("expected to wait for the debounce period, got %v", wait)
+	}
+	now = now.Add(30 * time.Second)
+	ip = net.ParseIP("203.0.113.3").To4()
+	d.Step(ctx)
+	now = now.Add(45 * time.Second)
+	d.Step(ctx)
+	if !published[0].Equal(net.ParseIP("203.0.113.1")) {
+		t.Fatalf("expected the flapping address not to be published, got %v", published)
+	}
+	now = now.Add(15 * time.Second)
+	d.Step(ctx)
+	if !published[0].Equal(ip) {
+		t.Errorf("expected %v to be published once settled, got %v", ip, published)
+	}
+}
+
+type memoryStore struct {
+	snapshot *state.Snapshot
+}
+
+func (m *memoryStore) Load() (*state.Snapshot, error) {
+	if m.snapshot == nil {
+		return state.New(), nil
+	}
+	return m.snapshot, nil
+}
+
+func (m *memoryStore) Save(s *state.Snapshot) error {
+	m.snapshot = s
+	return nil
+}
+
+func TestPersist(t *testing.T) {
+	ctx := context.Background()
+	ip := net.ParseIP("203.0.113.1").To4()
+	src := ipdetect.SourceFunc(func(ctx context.Context) ([]net.IP, error) {
+		return []net.IP{ip}, nil
+	})
+	var updates int
+	p := Provider{Name: "dynu", Updater: UpdaterFunc(func(ctx context.Context, ips []net.IP) error {
+		updates++
+		return nil
+	})}
+	store := &memoryStore{}
+	d := New(src, []Provider{p}, Persist(store))
+	d.Step(ctx)
+	if store.snapshot == nil || !store.snapshot.Providers["dynu"].IPs[0].Equal(ip) || len(store.snapshot.History) != 1 {
+		t.Fatalf("expected the update to be saved, got %+v", store.snapshot)
+	}
+	if typ := store.snapshot.History[0].Type; typ != state.Changed {
+		t.Errorf("expected a %q history entry, got %q", state.Changed, typ)
+	}
+	if len(store.snapshot.Detected) != 1 || store.snapshot.DetectedAt.IsZero() {
+		t.Errorf("expected the detected address to be saved, got %v at %v", store.snapshot.Detected, store.snapshot.DetectedAt)
+	}
+
+	restarted := New(src, []Provider{p}, Persist(store))
+	restarted.Restore(store.snapshot)
+	restarted.Step(ctx)
+	if updates != 1 {
+		t.Errorf("expected no update after restoring the state, got %d", updates)
+	}
+
+	p.Debounce = time.Hour
+	forced := New(src, []Provider{p}, Persist(store), Force())
+	forced.Restore(store.snapshot)
+	forced.Step(ctx)
+	forced.Step(ctx)
+	if updates != 2 {
+		t.Errorf("expected a single forced update of the unchanged address, got %d updates", updates-1)
+	}
+}
+
+func TestSnapshotLastError(t *testing.T) {
+	ctx := context.Background()
+	src := ipdetect.SourceFunc(func(ctx context.Context) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("203.0.113.1")}, nil
+	})
+	fail := true
+	p := Provider{Name: "dynu", Hostnames: []string{"example.com"}, Updater: UpdaterFunc(func(ctx context.Context, ips []net.IP) error {
+		if fail {
+			return errors.New("badauth")
+		}
+		return nil
+	})}
+	now := time.Now()
+	d := New(src, []Provider{p}, Backoff(time.Second, time.Second))
+	d.now = func() time.Time { return now }
+	d.Step(ctx)
+	fail = false
+	now = now.Add(time.Minute)
+	d.Step(ctx)
+	ps := d.Snapshot().Providers["dynu"]
+	if ps.Failures != 0 || ps.LastError != "badauth" || ps.LastErrorAt.IsZero() || ps.Hostnames[0] != "example.com" {
+		t.Errorf("expected the last error to be kept after recovering, got %+v", ps)
+	}
+}
+
+func TestGrace(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	src := ipdetect.SourceFunc(func(ctx context.Context) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("203.0.113.1")}, nil
+	})
+	updating := make(chan struct{})
+	var updateErr error
+	p := Provider{Name: "dynu", Updater: UpdaterFunc(func(uctx context.Context, ips []net.IP) error {
+		close(updating)
+		<-ctx.Done()
+		select {
+		case <-uctx.Done():
+			updateErr = uctx.Err()
+		case <-time.After(20 * time.Millisecond):
+		}
+		return updateErr
+	})}
+	store := &memoryStore{}
+	var events recorder
+	d := New(src, []Provider{p}, Grace(time.Second), Persist(store), Events(&events))
+	go func() {
+		<-updating
+		cancel()
+	}()
+	if err := d.Run(ctx); err != context.Canceled {
+		t.Fatalf("expected Run to stop with the context, got %v", err)
+	}
+	if updateErr != nil || len(store.snapshot.Providers["dynu"].IPs) != 1 {
+		t.Errorf("expected the update to finish within the grace period, got %v", updateErr)
+	}
+	if n := len(events); n == 0 || events[n-1] != event.Stopped {
+		t.Errorf("expected a final Stopped event, got %v", events)
+	}
+}
+
+func TestPromoteBackup(t *testing.T) {
+	ctx := context.Background()
+	ip := net.ParseIP("203.0.113.1").To4()
+	src := ipdetect.SourceFunc(func(ctx context.Context) ([]net.IP, error) {
+		return []net.IP{ip}, nil
+	})
+	primaryUp := false
+	var backupUpdates int
+	p := Provider{
+		Name: "primary",
+		Updater: UpdaterFunc(func(ctx context.Context, ips []net.IP) error {
+			if !primaryUp {
+				return errors.New("servererror")
+			}
+			return nil
+		}),
+		PromoteAfter: 2,
+		Backup: &Provider{Name: "backup", Updater: UpdaterFunc(func(ctx context.Context, ips []net.IP) error {
+			backupUpdates++
+			return nil
+		})},
+	}
+	var events recorder
+	now := time.Unix(1000, 0)
+	d := New(src, []Provider{p}, Backoff(time.Second, time.Second), Events(&events))
+	d.now = func() time.Time { return now }
+
+	d.Step(ctx)
+	if backupUpdates != 0 {
+		t.Fatal("backup promoted before PromoteAfter failures")
+	}
+	now = now.Add(time.Second)
+	d.Step(ctx)
+	if backupUpdates != 1 {
+		t.Fatalf("expected the backup to be promoted and updated, got %d updates", backupUpdates)
+	}
+	if events[len(events)-3] != event.Promoted {
+		t.Errorf("expected a Promoted event, got %v", events)
+	}
+	if s := d.Snapshot(); !s.Providers["primary"].Promoted || !s.Providers["backup"].IPs[0].Equal(ip) {
+		t.Errorf("unexpected snapshot %+v", s.Providers)
+	}
+
+	primaryUp = true
+	now = now.Add(time.Second)
+	ip = net.ParseIP("203.0.113.2").To4()
+	d.Step(ctx)
+	if backupUpdates != 1 || d.providers[0].promoted {
+		t.Errorf("expected the backup to be demoted once the primary recovered, got %d updates", backupUpdates)
+	}
+}
+
+func TestPowerAware(t *testing.T) {
+	src := ipdetect.SourceFunc(func(ctx context.Context) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("203.0.113.1")}, nil
+	})
+	battery := false
+	sensor := power.SensorFunc(func(ctx context.Context) (power.State, error) {
+		return power.State{OnBattery: battery}, nil
+	})
+	now := time.Unix(1000, 0)
+	d := New(src, nil, Interval(time.Minute), PowerAware(sensor, 4))
+	d.now = func() time.Time { return now }
+	if wait := d.Step(context.Background()); wait != time.Minute {
+		t.Errorf("expected the normal interval on AC, got %v", wait)
+	}
+	battery = true
+	if wait := d.Step(context.Background()); wait != 4*time.Minute {
+		t.Errorf("expected a stretched interval on battery, got %v", wait)
+	}
+}
+
+func TestJitter(t *testing.T) {
+	src := ipdetect.SourceFunc(func(ctx context.Context) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("203.0.113.1")}, nil
+	})
+	now := time.Unix(1000, 0)
+	d := New(src, nil, Interval(time.Minute), Jitter(30*time.Second))
+	d.now = func() time.Time { return now }
+	d.random = func(n int64) int64 {
+		if n != int64(30*time.Second) {
+			t.Errorf("expected a jitter of up to 30s, got %v", time.Duration(n))
+		}
+		return int64(12 * time.Second)
+	}
+	if wait := d.Step(context.Background()); wait != time.Minute+12*time.Second {
+		t.Errorf("expected the interval plus the jitter, got %v", wait)
+	}
+}
+
+func TestProviderInterval(t *testing.T) {
+	ctx := context.Background()
+	ip := net.ParseIP("203.0.113.1").To4()
+	src := ipdetect.SourceFunc(func(ctx context.Context) ([]net.IP, error) {
+		return []net.IP{ip}, nil
+	})
+	updates := make(map[string]int)
+	var fail bool
+	updater := func(name string) Updater {
+		return UpdaterFunc(func(ctx context.Context, ips []net.IP) error {
+			updates[name]++
+			if fail && name == "lte" {
+				return errors.New("servererror")
+			}
+			return nil
+		})
+	}
+	now := time.Unix(1000, 0)
+	d := New(src, []Provider{
+		{Name: "lte", Updater: updater("lte"), Interval: time.Minute, Backoff: 10 * time.Second},
+		{Name: "office", Updater: updater("office"), Interval: time.Hour},
+		{Name: "home", Updater: updater("home")},
+	}, Interval(5*time.Minute))
+	d.now = func() time.Time { return now }
+
+	if wait := d.Step(ctx); wait != time.Minute || len(updates) != 3 {
+		t.Fatalf("first step: wait %v, updates %v", wait, updates)
+	}
+	ip = net.ParseIP("203.0.113.2").To4()
+	now = now.Add(time.Minute)
+	d.Step(ctx)
+	if updates["lte"] != 2 || updates["office"] != 1 || updates["home"] != 1 {
+		t.Errorf("expected only lte to be checked after a minute, got %v", updates)
+	}
+	now = now.Add(4 * time.Minute)
+	d.Step(ctx)
+	if updates["home"] != 2 || updates["office"] != 1 {
+		t.Errorf("expected home to be checked at the interval of the daemon, got %v", updates)
+	}
+
+	ip = net.ParseIP("203.0.113.3").To4()
+	fail = true
+	now = now.Add(time.Minute)
+	if wait := d.Step(ctx); wait != 10*time.Second {
+		t.Errorf("expected lte to retry after its own backoff, got %v", wait)
+	}
+	d.woken = true
+	d.Step(ctx)
+	if updates["office"] != 2 {
+		t.Errorf("expected a wake-up to check every provider, got %v", updates)
+	}
+}
+
+func TestWake(t *testing.T) {
+	detections := make(chan struct{}, 10)
+	src := ipdetect.SourceFunc(func(ctx context.Context) ([]net.IP, error) {
+		detections <- struct{}{}
+		return []net.IP{net.ParseIP("203.0.113.1")}, nil
+	})
+	wake := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d := New(src, nil, Interval(time.Hour), Wake(wake))
+	go d.Run(ctx)
+	<-detections
+	wake <- struct{}{}
+	select {
+	case <-detections:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a detection after waking")
+	}
+}
+
+func TestTrigger(t *testing.T) {
+	src := ipdetect.SourceFunc(func(ctx context.Context) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("203.0.113.1")}, nil
+	})
+	updates := make(chan struct{}, 10)
+	p := Provider{Name: "dynu", Updater: UpdaterFunc(func(ctx context.Context, ips []net.IP) error {
+		updates <- struct{}{}
+		return nil
+	})}
+	trigger := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d := New(src, []Provider{p}, Interval(time.Hour), Trigger(trigger))
+	go d.Run(ctx)
+	<-updates
+	trigger <- struct{}{}
+	select {
+	case <-updates:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the unchanged address to be republished after a trigger")
+	}
+}
+
+func TestBudget(t *testing.T) {
+	detections := 0
+	src := ipdetect.SourceFunc(func(ctx context.Context) ([]net.IP, error) {
+		detections++
+		return []net.IP{net.ParseIP("203.0.113.1")}, nil
+	})
+	b := budget.New(budget.Calls(1))
+	b.Allow(context.Background(), budget.Essential)
+	d := New(src, nil, Interval(time.Minute), Budget(b))
+	if wait := d.Step(context.Background()); wait <= time.Minute {
+		t.Errorf("expected to wait for the budget to reset, got %v", wait)
+	}
+	if detections != 0 {
+		t.Errorf("expected no detection while the budget is exhausted, got %d", detections)
+	}
+}
+
+func TestWallClock(t *testing.T) {
+	detections := make(chan struct{}, 10)
+	src := ipdetect.SourceFunc(func(ctx context.Context) ([]net.IP, error) {
+		detections <- struct{}{}
+		return []net.IP{net.ParseIP("203.0.113.1")}, nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d := New(src, nil, Interval(50*time.Millisecond), WallClock(10*time.Millisecond))
+	go d.Run(ctx)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-detections:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected detection %d", i+1)
+		}
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	src := ipdetect.SourceFunc(func(ctx context.Context) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("203.0.113.1")}, nil
+	})
+	s, err := schedule.Parse("0 * * * *", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, time.March, 15, 19, 45, 0, 0, time.UTC)
+	d := New(src, nil, Interval(time.Minute), Schedule(s))
+	d.now = func() time.Time { return now }
+	if wait := d.Step(context.Background()); wait != 15*time.Minute {
+		t.Errorf("expected to wait until the top of the hour, got %v", wait)
+	}
+}
+
+func TestSplitFamilies(t *testing.T) {
+	src := ipdetect.SourceFunc(func(ctx context.Context) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("203.0.113.1").To4(), net.ParseIP("2001:db8::1")}, nil
+	})
+	var calls [][]net.IP
+	updater := UpdaterFunc(func(ctx context.Context, ips []net.IP) error {
+		calls = append(calls, ips)
+		if ips[0].To4() == nil {
+			return errors.New("AAAA update failed")
+		}
+		return nil
+	})
+	d := New(src, []Provider{{Name: "home", Updater: updater, SplitFamilies: true}})
+	d.Step(context.Background())
+	if len(calls) != 2 || len(calls[0]) != 1 || len(calls[1]) != 1 {
+		t.Fatalf("expected one update per family, got %v", calls)
+	}
+	s := d.Snapshot()
+	if v4 := s.Providers["home/ipv4"]; len(v4.IPs) != 1 || v4.Failures != 0 {
+		t.Errorf("expected IPv4 to be published despite the IPv6 failure: %+v", v4)
+	}
+	if v6 := s.Providers["home/ipv6"]; len(v6.IPs) != 0 || v6.Failures != 1 {
+		t.Errorf("expected IPv6 to be backing off: %+v", v6)
+	}
+}
+
+func TestRefreshAndFamilyPolicy(t *testing.T) {
+	v6 := net.ParseIP("2001:db8::1")
+	src := ipdetect.SourceFunc(func(ctx context.Context) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("203.0.113.1").To4(), v6}, nil
+	})
+	updates := map[bool]int{}
+	updater := UpdaterFunc(func(ctx context.Context, ips []net.IP) error {
+		updates[ips[0].To4() != nil]++
+		return nil
+	})
+	now := time.Unix(1000, 0)
+	d := New(src, []Provider{{
+		Name:          "home",
+		Updater:       updater,
+		SplitFamilies: true,
+		Refresh:       time.Hour,
+		IPv6:          FamilyPolicy{Debounce: 10 * time.Minute},
+	}}, Interval(time.Minute))
+	d.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	d.Step(ctx)
+	now = now.Add(30 * time.Minute)
+	v6 = net.ParseIP("2001:db8::2")
+	d.Step(ctx)
+	if updates[true] != 1 || updates[false] != 1 {
+		t.Fatalf("expected the new IPv6 prefix to be debounced, got %v", updates)
+	}
+	now = now.Add(10 * time.Minute)
+	d.Step(ctx)
+	if updates[false] != 2 {
+		t.Errorf("expected the IPv6 prefix to be published after its debounce, got %v", updates)
+	}
+	now = now.Add(21 * time.Minute)
+	if wait := d.Step(ctx); wait != time.Minute {
+		t.Errorf("unexpected wait %v", wait)
+	}
+	if updates[true] != 2 {
+		t.Errorf("expected the unchanged IPv4 address to be refreshed after an hour, got %v", updates)
+	}
+}
+
+func TestTrace(t *testing.T) {
+	src := ipdetect.SourceFunc(func(ctx context.Context) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("203.0.113.1").To4()}, nil
+	})
+	var parent trace.SpanID
+	p := Provider{Name: "home", Updater: UpdaterFunc(func(ctx context.Context, ips []net.IP) error {
+		parent = trace.FromContext(ctx).SpanID()
+		return errors.New("badauth")
+	})}
+	var spans []trace.SpanData
+	tracer := trace.New(trace.ExporterFunc(func(ctx context.Context, s []trace.SpanData) error {
+		spans = append(spans, s...)
+		return nil
+	}))
+	d := New(src, []Provider{p}, Trace(tracer))
+	d.Step(context.Background())
+	if err := tracer.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, s := range spans {
+		names = append(names, s.Name)
+	}
+	if len(spans) != 3 || names[0] != "ddns.detect" || names[1] != "ddns.update" || names[2] != "ddns.step" {
+		t.Fatalf("expected the detection and update spans of the step, got %v", names)
+	}
+	step, update := spans[2], spans[1]
+	if spans[0].Parent != step.SpanID || update.Parent != step.SpanID || update.TraceID != step.TraceID {
+		t.Errorf("expected the spans to be children of the step, got %+v", spans)
+	}
+	if parent != update.SpanID || update.Err == nil {
+		t.Errorf("expected the updater to run in the failed update span, got %+v", update)
+	}
+}
+
+func TestAfterStep(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	src := ipdetect.SourceFunc(func(ctx context.Context) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("203.0.113.1").To4()}, nil
+	})
+	p := Provider{Name: "home", Updater: UpdaterFunc(func(ctx context.Context, ips []net.IP) error { return nil })}
+	var steps []*state.Snapshot
+	d := New(src, []Provider{p}, AfterStep(func(stepCtx context.Context, s *state.Snapshot) {
+		if stepCtx.Err() != nil {
+			t.Error("expected the context of the step to be live")
+		}
+		steps = append(steps, s)
+		cancel()
+	}))
+	if err := d.Run(ctx); err != context.Canceled {
+		t.Fatal(err)
+	}
+	if len(steps) != 1 || steps[0].NextRun.IsZero() || len(steps[0].Providers["home"].IPs) != 1 {
+		t.Errorf("expected the state after the step, got %+v", steps)
+	}
+}
+
+func TestVerifyRollback(t *testing.T) {
+	ctx := context.Background()
+	good, bad := net.ParseIP("203.0.113.1").To4(), net.ParseIP("203.0.113.2").To4()
+	ip := good
+	src := ipdetect.SourceFunc(func(ctx context.Context) ([]net.IP, error) {
+		return []net.IP{ip}, nil
+	})
+	var published []string
+	p := Provider{Name: "dynu", Updater: UpdaterFunc(func(ctx context.Context, ips []net.IP) error {
+		published = append(published, ips[0].String())
+		return nil
+	})}
+	var checks int
+	check := func(ctx context.Context, ips []net.IP) error {
+		checks++
+		if ips[0].Equal(bad) {
+			return errors.New("port 443 unreachable")
+		}
+		return nil
+	}
+	var events recorder
+	d := New(src, []Provider{p}, Events(&events), Verify(check, verify.Policy{Rollback: true, Threshold: 2, Blackout: time.Hour}))
+
+	d.Step(ctx)
+	ip = bad
+	d.Step(ctx)
+	if checks != 2 || len(published) != 2 {
+		t.Fatalf("expected the new address to be published and checked, got %d checks of %v", checks, published)
+	}
+	d.Step(ctx)
+	if want := []string{good.String(), bad.String(), good.String()}; strings.Join(published, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected a rollback after the second failed check, got %v", published)
+	}
+	if !sameIPs(d.providers[0].published, []net.IP{good}) {
+		t.Errorf("expected the daemon to hold %v as published, got %v", good, d.providers[0].published)
+	}
+	d.Step(ctx)
+	if len(published) != 3 || checks != 3 {
+		t.Errorf("expected the rolled back address not to be published during the blackout, got %v", published)
+	}
+
+	want := []event.Type{
+		event.Detected, event.Changed, event.Updated, event.Verified,
+		event.Detected, event.Changed, event.Updated, event.Verified,
+		event.Detected, event.Verified, event.RolledBack,
+		event.Detected,
+	}
+	if len(events) != len(want) {
+		t.Fatalf("expected events %v, got %v", want, events)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("event %d: expected %v, got %v", i, want[i], events[i])
+		}
+	}
+}
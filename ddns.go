@@ -0,0 +1,410 @@
+// Package ddns embeds the dynamic DNS updater in other Go programs, such as NAS software or router firmware,
+// without running the ddns command. An Engine detects the public addresses with a Source and keeps the records
+// of its providers up to date in the background, as ddns daemon does:
+//
+//	engine := ddns.New(ipify.New(), []ddns.Provider{{
+//		Name:      "home",
+//		Hostnames: []string{"home.example.com"},
+//		Updater:   ddns.Accounts(dynu.New(username, password, dynu.Hostnames("home.example.com"))),
+//	}}, ddns.Interval(10*time.Minute), ddns.Persist(state.File{Path: "ddns.state"}))
+//	events, cancel := engine.Subscribe(ddns.Changed, ddns.Failed)
+//	defer cancel()
+//	stop := engine.SubscribeFunc(func(ev ddns.Event) {
+//		log.Printf("%s: retrying at %v after %d failures: %v", ev.Provider, ev.RetryAt, ev.Attempt, ev.Err)
+//	}, ddns.BackedOff)
+//	defer stop()
+//	if err := engine.Start(); err != nil {
+//		return err
+//	}
+//	defer engine.Stop(context.Background())
+//
+// # Compatibility
+//
+// The exported identifiers of this package are its version 1 API, APIVersion. They are not removed or changed
+// incompatibly until a new major version of the module: functions and methods keep their signatures, Options
+// are added rather than changed, structs only gain fields, and interfaces gain no methods, new capabilities
+// being interfaces of their own that implementations may also satisfy. Identifiers that are replaced are
+// marked Deprecated and keep working for the rest of version 1.
+//
+// The types aliased from other packages, such as Provider and Status, are part of the version 1 API under the
+// same rules, although they are declared in the daemon, event, ipdetect and state packages. The other
+// identifiers of those packages, and the other packages, including the provider clients such as dynu, are not:
+// they may change in minor releases, what they replace being kept as a Deprecated shim for at least one minor
+// release, such as the dynu calls without a context. Packages under internal are not part of any API.
+package ddns // import "github.com/justenwalker/ddns"
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/justenwalker/ddns/daemon"
+	"github.com/justenwalker/ddns/dynu"
+	"github.com/justenwalker/ddns/event"
+	"github.com/justenwalker/ddns/netwatch"
+	"github.com/justenwalker/ddns/state"
+)
+
+// Logger for printing debug logs from this package
+type Logger interface {
+	Log(format string, v ...interface{})
+}
+
+// ErrRunning is returned by Start when the engine is already running
+var ErrRunning = errors.New("ddns: engine is already running")
+
+// Option sets engine options
+type Option func(*Engine)
+
+// Log enables engine, daemon and event bus logging using the given Logger, such as logging.Slog(slog.Default())
+// for leveled records with the provider, hostnames and duration of each update as fields
+func Log(l Logger) Option {
+	return func(e *Engine) {
+		e.logger = l
+	}
+}
+
+// Interval sets how often addresses are detected; the default is 5 minutes
+func Interval(interval time.Duration) Option {
+	return Daemon(daemon.Interval(interval))
+}
+
+// Backoff sets the delay before retrying after a failure. It doubles with each consecutive failure up to max.
+// The default is 30 seconds up to 30 minutes.
+func Backoff(min, max time.Duration) Option {
+	return Daemon(daemon.Backoff(min, max))
+}
+
+// Persist restores the published addresses from store when the engine starts, and saves them after every update,
+// so that restarting the application does not update every record again
+func Persist(store Store) Option {
+	return func(e *Engine) {
+		e.store = store
+	}
+}
+
+// Watch detects the addresses as soon as the local addresses or routes change, once they have stopped changing
+// for settle. Start fails with netwatch.ErrNotSupported on platforms that cannot watch them.
+func Watch(settle time.Duration) Option {
+	return func(e *Engine) {
+		e.watch = true
+		e.settle = settle
+	}
+}
+
+// Attach delivers the events of the engine to s, such as a notify.Sink or a metrics.Providers
+func Attach(s Sink) Option {
+	return func(e *Engine) {
+		e.sinks = append(e.sinks, s)
+	}
+}
+
+// Daemon sets options of the update loop that the engine has none for, such as daemon.Schedule or daemon.Jitter.
+// The engine sets daemon.Log, daemon.Events, daemon.Persist and daemon.Wake itself.
+func Daemon(options ...daemon.Option) Option {
+	return func(e *Engine) {
+		e.options = append(e.options, options...)
+	}
+}
+
+// Engine runs the detect and update loop of a set of providers in the background
+type Engine struct {
+	source    Source
+	providers []Provider
+	logger    Logger
+	store     Store
+	watch     bool
+	settle    time.Duration
+	sinks     []Sink
+	options   []daemon.Option
+	wake      chan struct{}
+
+	mu      sync.Mutex
+	running bool
+	stop    context.CancelFunc
+	done    chan struct{}
+	err     error
+	bus     *event.Bus
+	watcher *netwatch.Watcher
+	status  *Status
+	subs    map[*subscription]bool
+}
+
+type subscription struct {
+	types map[EventType]bool
+	ch    chan Event
+}
+
+// New constructs an engine that detects addresses with src and publishes them to providers.
+// It does nothing until it is started.
+func New(src Source, providers []Provider, options ...Option) *Engine {
+	e := &Engine{
+		source:    src,
+		providers: providers,
+		wake:      make(chan struct{}, 1),
+		status:    state.New(),
+		subs:      make(map[*subscription]bool),
+	}
+	for _, opt := range options {
+		opt(e)
+	}
+	return e
+}
+
+func (e *Engine) logf(format string, v ...interface{}) {
+	if e.logger != nil {
+		e.logger.Log(format, v...)
+	}
+}
+
+// Start restores the persisted state, if any, and starts updating the providers in the background until Stop.
+// A stopped engine can be started again.
+func (e *Engine) Start() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.running {
+		return ErrRunning
+	}
+	var opts []daemon.Option
+	var busOpts []event.Option
+	if e.logger != nil {
+		opts = append(opts, daemon.Log(e.logger))
+		busOpts = append(busOpts, event.Log(e.logger))
+	}
+	var watcher *netwatch.Watcher
+	if e.watch {
+		var wopts []netwatch.Option
+		if e.logger != nil {
+			wopts = append(wopts, netwatch.Log(e.logger))
+		}
+		var err error
+		if watcher, err = netwatch.New(wopts...); err != nil {
+			return err
+		}
+		go e.settled(watcher.Events())
+	}
+	bus := event.NewBus(busOpts...)
+	for _, s := range e.sinks {
+		bus.Attach(s)
+	}
+	bus.Attach(event.SinkFunc(e.deliver))
+	opts = append(opts, e.options...)
+	opts = append(opts, daemon.Events(bus), daemon.Persist(recorder{e}), daemon.Wake(e.wake))
+	d := daemon.New(e.source, e.providers, opts...)
+	s, err := recorder{e}.Load()
+	if err != nil {
+		if watcher != nil {
+			watcher.Close()
+		}
+		bus.Close(context.Background())
+		return err
+	}
+	// the daemon restores the state again when it runs; this lists the providers before their first update
+	d.Restore(s)
+	e.status = d.Snapshot()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e.running, e.stop, e.done, e.err = true, cancel, make(chan struct{}), nil
+	e.bus, e.watcher = bus, watcher
+	go func(done chan struct{}) {
+		defer close(done)
+		if err := d.Run(ctx); ctx.Err() == nil {
+			e.logf("ddns: engine stopped: %v", err)
+			e.mu.Lock()
+			e.err = err
+			e.mu.Unlock()
+		}
+	}(e.done)
+	return nil
+}
+
+// Stop stops updating the providers, letting an update in progress finish, and delivers the remaining events,
+// ending with a Stopped event for each provider, until ctx is done.
+// It returns the error that stopped the engine on its own, if any.
+func (e *Engine) Stop(ctx context.Context) error {
+	e.mu.Lock()
+	if !e.running {
+		e.mu.Unlock()
+		return nil
+	}
+	e.running = false
+	stop, done, bus, watcher := e.stop, e.done, e.bus, e.watcher
+	e.mu.Unlock()
+	stop()
+	if watcher != nil {
+		watcher.Close()
+	}
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if err := bus.Close(ctx); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.err
+}
+
+// Running returns true between Start and Stop
+func (e *Engine) Running() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.running
+}
+
+// Update detects the addresses and updates the providers that need it now, instead of at the next interval,
+// such as when the application knows the WAN connection was re-established. Providers backing off still wait
+// for their retry.
+func (e *Engine) Update() {
+	select {
+	case e.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Status returns the state of the providers as of the last update: their published addresses, failures and
+// history, and when the addresses will next be detected. It must not be modified.
+func (e *Engine) Status() *Status {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.status
+}
+
+// Subscribe returns a channel receiving the events of the given types, or of every type if none is given,
+// until cancel is called, which closes it. Events are dropped while the channel is full, so slow subscribers
+// do not hold up the updates.
+func (e *Engine) Subscribe(types ...EventType) (events <-chan Event, cancel func()) {
+	s := &subscription{ch: make(chan Event, 64)}
+	if len(types) > 0 {
+		s.types = make(map[EventType]bool)
+		for _, typ := range types {
+			s.types[typ] = true
+		}
+	}
+	e.mu.Lock()
+	e.subs[s] = true
+	e.mu.Unlock()
+	var once sync.Once
+	return s.ch, func() {
+		once.Do(func() {
+			e.mu.Lock()
+			defer e.mu.Unlock()
+			delete(e.subs, s)
+			close(s.ch)
+		})
+	}
+}
+
+// SubscribeFunc calls f with the events of the given types, or of every type if none is given, until cancel is
+// called. The events are delivered in order from a goroutine of the subscription, and dropped as with Subscribe
+// while f is slow to return.
+func (e *Engine) SubscribeFunc(f func(Event), types ...EventType) (cancel func()) {
+	events, cancel := e.Subscribe(types...)
+	go func() {
+		for ev := range events {
+			f(ev)
+		}
+	}()
+	return cancel
+}
+
+// deliver sends ev to the subscriptions to its type
+func (e *Engine) deliver(ctx context.Context, ev Event) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for s := range e.subs {
+		if s.types != nil && !s.types[ev.Type] {
+			continue
+		}
+		select {
+		case s.ch <- ev:
+		default:
+			e.logf("ddns: subscriber not keeping up, dropping %v event", ev.Type)
+		}
+	}
+	return nil
+}
+
+// settled wakes the daemon once no address change has been received for the settle period
+func (e *Engine) settled(changes <-chan netwatch.Event) {
+	var settled <-chan time.Time
+	for {
+		select {
+		case _, ok := <-changes:
+			if !ok {
+				return
+			}
+			if e.settle <= 0 {
+				e.Update()
+				continue
+			}
+			settled = time.After(e.settle)
+		case <-settled:
+			settled = nil
+			e.Update()
+		}
+	}
+}
+
+// recorder keeps the snapshots the daemon saves as the status of the engine, and saves them to its store, if any
+type recorder struct {
+	e *Engine
+}
+
+func (r recorder) Load() (*Status, error) {
+	if r.e.store == nil {
+		return state.New(), nil
+	}
+	return r.e.store.Load()
+}
+
+func (r recorder) Save(s *Status) error {
+	r.e.mu.Lock()
+	r.e.status = s
+	r.e.mu.Unlock()
+	if r.e.store == nil {
+		return nil
+	}
+	return r.e.store.Save(s)
+}
+
+// Accounts returns the updater publishing addresses to every account, such as the dynu clients of several
+// accounts. It returns ErrUnchanged if every account already had them, or the errors of those that failed.
+func Accounts(accounts ...Publisher) Updater {
+	return UpdaterFunc(func(ctx context.Context, ips []net.IP) error {
+		var errs []error
+		unchanged := true
+		for _, account := range accounts {
+			changed, err := account.UpdateIPChangedContext(ctx, ips)
+			switch {
+			case err != nil:
+				errs = append(errs, err)
+			case changed:
+				unchanged = false
+			}
+		}
+		if len(errs) > 0 {
+			return errors.Join(errs...)
+		}
+		if unchanged {
+			return ErrUnchanged
+		}
+		return nil
+	})
+}
+
+// Dynu returns the updater publishing addresses with every client, such as one per dynu account.
+// It returns ErrUnchanged if dynu already had them for every client, or the errors of those that failed.
+//
+// Deprecated: use Accounts, which takes the clients of any provider.
+func Dynu(clients ...*dynu.Client) Updater {
+	accounts := make([]Publisher, len(clients))
+	for i, client := range clients {
+		accounts[i] = client
+	}
+	return Accounts(accounts...)
+}
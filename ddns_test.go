@@ -0,0 +1,112 @@
+package ddns_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/justenwalker/ddns"
+	"github.com/justenwalker/ddns/ipdetect"
+)
+
+func TestEngine(t *testing.T) {
+	var mu sync.Mutex
+	ip := net.ParseIP("203.0.113.1").To4()
+	src := ipdetect.SourceFunc(func(ctx context.Context) ([]net.IP, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return []net.IP{ip}, nil
+	})
+	engine := ddns.New(src, []ddns.Provider{{
+		Name:      "home",
+		Hostnames: []string{"home.example.com"},
+		Updater: ddns.UpdaterFunc(func(ctx context.Context, ips []net.IP) error {
+			return nil
+		}),
+	}}, ddns.Interval(time.Hour))
+	changed, cancel := engine.Subscribe(ddns.Changed)
+	defer cancel()
+	all, cancelAll := engine.Subscribe()
+	defer cancelAll()
+	next := func() ddns.Event {
+		t.Helper()
+		select {
+		case ev := <-changed:
+			return ev
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a change")
+		}
+		return ddns.Event{}
+	}
+
+	if err := engine.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Start(); err != ddns.ErrRunning {
+		t.Errorf("expected starting twice to fail with ErrRunning, got %v", err)
+	}
+	if ev := next(); ev.Provider != "home" || !ev.NewIPs[0].Equal(ip) {
+		t.Errorf("unexpected first change: %+v", ev)
+	}
+
+	mu.Lock()
+	ip = net.ParseIP("203.0.113.2").To4()
+	mu.Unlock()
+	engine.Update()
+	if ev := next(); !ev.NewIPs[0].Equal(ip) {
+		t.Errorf("expected Update to publish the new address, got %v", ev.NewIPs)
+	}
+	// the status is saved after the events of the update are published
+	deadline := time.Now().Add(5 * time.Second)
+	for p := engine.Status().Providers["home"]; len(p.IPs) == 0 || !p.IPs[0].Equal(ip); p = engine.Status().Providers["home"] {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the status to have the new address, got %+v", p)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := engine.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if engine.Running() {
+		t.Error("expected the engine to be stopped")
+	}
+	var last ddns.Event
+	for len(all) > 0 {
+		last = <-all
+	}
+	if last.Type != ddns.Stopped || last.Provider != "home" {
+		t.Errorf("expected the last event to be the final status of the provider, got %+v", last)
+	}
+}
+
+func TestSubscribeFunc(t *testing.T) {
+	src := ipdetect.SourceFunc(func(ctx context.Context) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("203.0.113.1").To4()}, nil
+	})
+	engine := ddns.New(src, []ddns.Provider{{
+		Name: "home",
+		Updater: ddns.UpdaterFunc(func(ctx context.Context, ips []net.IP) error {
+			return errors.New("badauth")
+		}),
+	}}, ddns.Interval(time.Hour))
+	backedOff := make(chan ddns.Event, 1)
+	cancel := engine.SubscribeFunc(func(ev ddns.Event) { backedOff <- ev }, ddns.BackedOff)
+	defer cancel()
+	start := time.Now()
+	if err := engine.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer engine.Stop(context.Background())
+	select {
+	case ev := <-backedOff:
+		if ev.Provider != "home" || ev.Attempt != 1 || ev.Err == nil || ev.RetryAt.Before(start.Add(30*time.Second)) {
+			t.Errorf("unexpected event %+v", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the provider to back off")
+	}
+}
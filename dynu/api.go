@@ -0,0 +1,70 @@
+package dynu
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/justenwalker/ddns/dynu/dynuapi"
+)
+
+// restPath is the prefix of the REST API, which unlike the IP Update API authenticates with an API key
+const restPath = "/v2"
+
+// APIKey sets the key of the REST API used to list and change hosts and records, found in the control panel
+// of dynu.com. Package dynuapi covers the rest of the REST API, such as updating records and their TTLs.
+func APIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// Host is a dynamic host of the account
+type Host = dynuapi.Domain
+
+// Record is a DNS record of a host, such as the A record of a subdomain
+type Record = dynuapi.Record
+
+// APIError is an error response of the REST API
+type APIError = dynuapi.APIError
+
+// ListHosts returns the dynamic hosts of the account of the APIKey
+func (c *Client) ListHosts(ctx context.Context) ([]Host, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	return c.rest().Domains(ctx)
+}
+
+// ListRecords returns the DNS records of the host with the given ID, as returned by ListHosts
+func (c *Client) ListRecords(ctx context.Context, hostID int64) ([]Record, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	return c.rest().Records(ctx, hostID)
+}
+
+// AddRecord adds a record to the host with the given ID and returns it, with its ID
+func (c *Client) AddRecord(ctx context.Context, hostID int64, r Record) (Record, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	return c.rest().CreateRecord(ctx, hostID, r)
+}
+
+// DeleteRecord removes a record of the host with the given ID
+func (c *Client) DeleteRecord(ctx context.Context, hostID, recordID int64) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	return c.rest().DeleteRecord(ctx, hostID, recordID)
+}
+
+// rest returns the client of the REST API at the endpoint of c, authenticated with its APIKey
+func (c *Client) rest() *dynuapi.Client {
+	endpoint := c.endpoint + restPath
+	if u, err := url.Parse(c.endpoint); err == nil {
+		u.Path, u.RawQuery = restPath, ""
+		endpoint = u.String()
+	}
+	opts := []dynuapi.Option{dynuapi.Endpoint(endpoint), dynuapi.HTTPClient(c.httpClient), dynuapi.APIKey(c.apiKey)}
+	if c.debug != nil {
+		opts = append(opts, dynuapi.Debug(c.debug))
+	}
+	return dynuapi.New(opts...)
+}
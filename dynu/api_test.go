@@ -0,0 +1,68 @@
+package dynu_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/justenwalker/ddns/dynu"
+)
+
+func TestListHosts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("API-Key") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"statusCode":401,"type":"Authentication Exception","message":"Invalid API key."}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/v2/dns":
+			w.Write([]byte(`{"statusCode":200,"domains":[
+				{"id":1,"name":"home.dynu.net","group":"home","ipv4Address":"203.0.113.7","ipv4":true,"ipv6":false,"ttl":90},
+				{"id":2,"name":"example.com","group":"","ipv6Address":"2001:db8::7","ipv4":true,"ipv6":true,"ttl":300}]}`))
+		case "/v2/dns/2/record":
+			w.Write([]byte(`{"statusCode":200,"dnsRecords":[
+				{"id":10,"hostname":"www.example.com","nodeName":"www","recordType":"A","state":true,"ipv4Address":"203.0.113.7"},
+				{"id":11,"hostname":"example.com","nodeName":"","recordType":"MX","state":true}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+	client := dynu.New("", "", dynu.Endpoint(srv.URL), dynu.APIKey("secret"))
+	hosts, err := client.ListHosts(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 2 || hosts[0].Name != "home.dynu.net" || hosts[0].Group != "home" || !hosts[1].IPv6 {
+		t.Errorf("unexpected hosts %+v", hosts)
+	}
+	records, err := client.ListRecords(ctx, hosts[1].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Hostname != "www.example.com" || records[0].RecordType != "A" {
+		t.Errorf("unexpected records %+v", records)
+	}
+
+	_, err = dynu.New("", "", dynu.Endpoint(srv.URL), dynu.APIKey("wrong")).ListHosts(ctx)
+	var apiErr *dynu.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 401 || apiErr.Message != "Invalid API key." {
+		t.Errorf("expected the API error, got %v", err)
+	}
+}
+
+func TestListHostsTimeout(t *testing.T) {
+	var bounded bool
+	client := dynu.New("", "", dynu.APIKey("secret"), dynu.Timeout(time.Minute), dynu.HTTPClient(requesterFunc(func(req *http.Request) (*http.Response, error) {
+		_, bounded = req.Context().Deadline()
+		return nil, errors.New("offline")
+	})))
+	if _, err := client.ListHosts(context.Background()); err == nil || !bounded {
+		t.Errorf("expected the call to be bounded by the Timeout, got %v", err)
+	}
+}
@@ -2,13 +2,22 @@ package dynu // import "github.com/justenwalker/ddns/dynu"
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"io/ioutil"
+	"log/slog"
 	"net"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
+
+	"github.com/justenwalker/ddns/internal/bootstrap"
+	"github.com/justenwalker/ddns/internal/httpdump"
+	"github.com/justenwalker/ddns/internal/httpreq"
+	"github.com/justenwalker/ddns/internal/netbind"
+	"github.com/justenwalker/ddns/logging"
 )
 
 const apiEndpoint = "https://api.dynu.com"
@@ -19,11 +28,9 @@ type Logger interface {
 	Log(format string, v ...interface{})
 }
 
-// HTTPRequester makes http requests and returns responses
-// *http.Client implicitly implements Requester and can be provided whever this interface is requested.
-type HTTPRequester interface {
-	Do(req *http.Request) (*http.Response, error)
-}
+// HTTPRequester makes http requests and returns responses.
+// *http.Client implicitly implements HTTPRequester and can be provided wherever this interface is requested.
+type HTTPRequester = httpreq.Requester
 
 // Option sets client options
 type Option func(*Client)
@@ -31,6 +38,10 @@ type Option func(*Client)
 // Client for communicating with the IP Update API at dynu.com
 type Client struct {
 	logger     Logger
+	debug      Logger
+	dump       Logger
+	policy     Policy
+	strict     bool
 	ipv6       bool
 	ipv4       bool
 	httpClient HTTPRequester
@@ -39,6 +50,11 @@ type Client struct {
 	password   string
 	location   string
 	hostnames  []string
+	apiKey     string
+	timeout    time.Duration
+	bootstrap  *bootstrap.Resolver
+	// bootstrapIPs are the addresses of the endpoint host, set by Bootstrap
+	bootstrapIPs []net.IP
 }
 
 // Log enables client logging using the given Logger
@@ -48,6 +64,31 @@ func Log(l Logger) Option {
 	}
 }
 
+// Debug logs each request, with the password redacted, and its response using the given Logger
+func Debug(l Logger) Option {
+	return func(c *Client) {
+		c.debug = l
+	}
+}
+
+// Dump logs each request and its response in full, with their headers and bodies, using the given Logger.
+// The password, its hash, the API key and the parameters and headers named like credentials are redacted,
+// so that the logs can be shared.
+func Dump(l Logger) Option {
+	return func(c *Client) {
+		c.dump = l
+	}
+}
+
+// Strict enables/disables strict response parsing.
+// When enabled, unexpected response lines or a mismatch between the number of hostnames and response codes
+// are returned as a ParseError instead of being logged.
+func Strict(enabled bool) Option {
+	return func(c *Client) {
+		c.strict = enabled
+	}
+}
+
 // IPv6 enables/disables setting the IPv6 address
 func IPv6(enabled bool) Option {
 	return func(c *Client) {
@@ -88,6 +129,14 @@ func Endpoint(endpoint string) Option {
 	}
 }
 
+// Timeout bounds each call whose context has no deadline, such as those of the deprecated methods without a
+// context. By default, and with 0, the calls are only bounded by the HTTP client.
+func Timeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = d
+	}
+}
+
 // HTTPClient sets a custom HTTP client to use for all of the API calls
 // the default uses http.DefaultClient
 func HTTPClient(hc HTTPRequester) Option {
@@ -96,6 +145,35 @@ func HTTPClient(hc HTTPRequester) Option {
 	}
 }
 
+// BindInterface sends API requests from the named interface, for multi-homed hosts
+// where the default route is not the connection being published.
+// It replaces the client set by HTTPClient.
+func BindInterface(name string) Option {
+	return func(c *Client) {
+		c.httpClient = netbind.Client(netbind.Binding{Interface: name})
+	}
+}
+
+// BindAddress sends API requests from the given local source address.
+// It replaces the client set by HTTPClient.
+func BindAddress(ip net.IP) Option {
+	return func(c *Client) {
+		c.httpClient = netbind.Client(netbind.Binding{Address: ip})
+	}
+}
+
+// Bootstrap connects to the API endpoint at the given addresses, or if there are none, those returned by the
+// DNS over HTTPS server at doh, before falling back to the system resolver. It lets ddns fix records when the
+// local DNS is broken or resolves the endpoint to a stale address, such as on its first run; doh should have a
+// literal address as its host, such as https://1.1.1.1/dns-query. The TLS certificate of the endpoint is still
+// verified. It replaces the client set by HTTPClient, BindInterface or BindAddress.
+func Bootstrap(ips []net.IP, doh string) Option {
+	return func(c *Client) {
+		c.bootstrap = &bootstrap.Resolver{DoH: doh}
+		c.bootstrapIPs = ips
+	}
+}
+
 // New constructs a dnyu.com API client
 func New(username string, password string, options ...Option) *Client {
 	client := &Client{
@@ -109,16 +187,46 @@ func New(username string, password string, options ...Option) *Client {
 	for _, opt := range options {
 		opt(client)
 	}
+	if r := client.bootstrap; r != nil {
+		// an invalid endpoint is reported by the requests
+		if u, err := url.Parse(client.endpoint); err == nil && len(client.bootstrapIPs) > 0 {
+			r.Hosts = map[string][]net.IP{strings.ToLower(u.Hostname()): client.bootstrapIPs}
+		}
+		client.httpClient = r.HTTPClient()
+	}
+	if client.dump != nil {
+		client.httpClient = &httpdump.Client{
+			Requester: client.httpClient,
+			Logger:    client.dump,
+			Name:      "dynu",
+			Secrets:   []string{client.password, hashPassword(client.password), client.apiKey},
+		}
+	}
 	return client
 }
 
+// withTimeout returns ctx bounded by the Timeout, unless it has a deadline of its own
+func (c *Client) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || c.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, c.timeout)
+}
+
 func hashPassword(password string) string {
 	bs := sha256.Sum256([]byte(password))
 	return hex.EncodeToString(bs[:])
 }
 
 // DoUpdateIP executes the UpdateIP request and returns the response
+//
+// Deprecated: use DoUpdateIPContext, which can be canceled.
 func (c *Client) DoUpdateIP(ips []net.IP) (*Response, error) {
+	return c.DoUpdateIPContext(context.Background(), ips)
+}
+
+// DoUpdateIPContext executes the UpdateIPContext request and returns the response
+func (c *Client) DoUpdateIPContext(ctx context.Context, ips []net.IP) (*Response, error) {
 	// URL Format:
 	// https://api.dynu.com/nic/update?hostname=[HOSTNAME]&myip=[IP ADDRESS]&myipv6=[IPv6 ADDRESS]&password=[PASSWORD or MD5(PASSWORD) or SHA256(PASSWORD)]
 	// https://api.dynu.com/nic/update?username=[USERNAME]&myip=[IP ADDRESS]&myipv6=[IPv6 ADDRESS]&password=[PASSWORD or MD5(PASSWORD) or SHA256(PASSWORD)]
@@ -151,10 +259,20 @@ func (c *Client) DoUpdateIP(ips []net.IP) (*Response, error) {
 	}
 	uri.Path = updatePath
 	uri.RawQuery = q.Encode()
-	req, err := http.NewRequest(http.MethodGet, uri.String(), nil)
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri.String(), nil)
 	if err != nil {
 		return nil, err
 	}
+	if c.debug != nil {
+		q.Set("password", "REDACTED")
+		redacted := *uri
+		redacted.RawQuery = q.Encode()
+		logging.Print(c.debug, slog.LevelDebug, []slog.Attr{slog.String("method", http.MethodGet), slog.String("url", redacted.String())},
+			"dynu: GET %s", redacted.String())
+	}
+	start := time.Now()
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, err
@@ -164,18 +282,91 @@ func (c *Client) DoUpdateIP(ips []net.IP) (*Response, error) {
 	if err != nil {
 		return nil, err
 	}
-	rs, err := ReadResponse(bytes.NewBuffer(body))
+	if c.debug != nil {
+		logging.Print(c.debug, slog.LevelDebug, responseAttrs(resp, start), "dynu: %s: %q", resp.Status, body)
+	}
+	rr := ResponseReader{
+		Strict:    c.strict,
+		Hostnames: c.hostnames,
+		Logger:    c.logger,
+	}
+	rs, err := rr.Read(bytes.NewBuffer(body))
 	if err != nil {
 		return nil, err
 	}
 	return rs, nil
 }
 
+// responseAttrs returns the fields of a response in the debug logs
+func responseAttrs(resp *http.Response, start time.Time) []slog.Attr {
+	return []slog.Attr{slog.Int("status_code", resp.StatusCode), slog.Duration("duration", time.Since(start))}
+}
+
 // UpdateIP updates the ip address of the dnyu address
+//
+// Deprecated: use UpdateIPContext, which can be canceled.
 func (c *Client) UpdateIP(ips []net.IP) error {
-	rs, err := c.DoUpdateIP(ips)
+	return c.UpdateIPContext(context.Background(), ips)
+}
+
+// UpdateIPContext updates the ip address of the dnyu address
+func (c *Client) UpdateIPContext(ctx context.Context, ips []net.IP) error {
+	rs, err := c.DoUpdateIPContext(ctx, ips)
+	if err != nil {
+		return err
+	}
+	return rs.toError(c.policy)
+}
+
+// UpdateIPChanged updates the ip address like UpdateIP and also reports whether the server changed any record
+//
+// Deprecated: use UpdateIPChangedContext, which can be canceled.
+func (c *Client) UpdateIPChanged(ips []net.IP) (bool, error) {
+	return c.UpdateIPChangedContext(context.Background(), ips)
+}
+
+// UpdateIPChangedContext updates the ip address like UpdateIPContext and also reports whether the server changed
+// any record, as opposed to answering nochg for every hostname
+func (c *Client) UpdateIPChangedContext(ctx context.Context, ips []net.IP) (bool, error) {
+	rs, err := c.DoUpdateIPContext(ctx, ips)
+	if err != nil {
+		return false, err
+	}
+	if err := rs.toError(c.policy); err != nil {
+		return false, err
+	}
+	return !rs.Unchanged(), nil
+}
+
+// Verify checks the credentials and hostnames without changing anything at the provider
+//
+// Deprecated: use VerifyContext, which can be canceled.
+func (c *Client) Verify() error {
+	return c.VerifyContext(context.Background())
+}
+
+// VerifyContext checks the credentials and hostnames with an update request that publishes no address,
+// so nothing changes at the provider
+func (c *Client) VerifyContext(ctx context.Context) error {
+	rs, err := c.DoUpdateIPContext(ctx, nil)
 	if err != nil {
 		return err
 	}
-	return rs.ToError()
+	return rs.toError(c.policy)
+}
+
+// UpdateHostnames updates the given hostnames instead of those configured with the Hostnames or Location options
+//
+// Deprecated: use UpdateHostnamesContext, which can be canceled.
+func (c *Client) UpdateHostnames(hostnames []string, ips []net.IP) error {
+	return c.UpdateHostnamesContext(context.Background(), hostnames, ips)
+}
+
+// UpdateHostnamesContext updates the given hostnames instead of those configured with the Hostnames or Location
+// options
+func (c *Client) UpdateHostnamesContext(ctx context.Context, hostnames []string, ips []net.IP) error {
+	cc := *c
+	cc.hostnames = hostnames
+	cc.location = ""
+	return cc.UpdateIPContext(ctx, ips)
 }
@@ -1,10 +1,17 @@
 package dynu_test
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/http/httputil"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/justenwalker/ddns/dynu"
 )
@@ -17,7 +24,12 @@ type testRequester struct {
 func (r testRequester) Do(req *http.Request) (*http.Response, error) {
 	reqbody, _ := httputil.DumpRequest(req, false)
 	r.t.Log("<< REQUEST\n", string(reqbody))
-	return r.resp, nil
+	resp := *r.resp
+	if resp.Body == nil {
+		// answer like the server when the test does not care about the response
+		resp.Body = ioutil.NopCloser(strings.NewReader("good " + req.URL.Query().Get("myip")))
+	}
+	return &resp, nil
 }
 
 func TestUpdateIP(t *testing.T) {
@@ -32,3 +44,254 @@ func TestUpdateIP(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestDoUpdateIPContextAccepted(t *testing.T) {
+	client := dynu.New("foo", "bar",
+		dynu.HTTPClient(testRequester{t: t, resp: &http.Response{
+			Body: ioutil.NopCloser(strings.NewReader("nochg 14.14.22.149 2001:db8::1")),
+		}}),
+		dynu.Hostnames([]string{"dionysus.myddns.rocks"}),
+	)
+	rs, err := client.DoUpdateIPContext(context.Background(), []net.IP{net.IPv4(14, 14, 22, 149), net.ParseIP("2001:db8::1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rs.AcceptedIPv4.Equal(net.IPv4(14, 14, 22, 149)) || !rs.AcceptedIPv6.Equal(net.ParseIP("2001:db8::1")) {
+		t.Errorf("accepted = %v %v", rs.AcceptedIPv4, rs.AcceptedIPv6)
+	}
+}
+
+func TestReadResponseAccepted(t *testing.T) {
+	rs, err := dynu.ReadResponse(strings.NewReader("nochg 14.14.22.149 2001:db8::1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rs.AcceptedIPv4.Equal(net.IPv4(14, 14, 22, 149)) {
+		t.Errorf("AcceptedIPv4 = %v", rs.AcceptedIPv4)
+	}
+	if !rs.AcceptedIPv6.Equal(net.ParseIP("2001:db8::1")) {
+		t.Errorf("AcceptedIPv6 = %v", rs.AcceptedIPv6)
+	}
+	rs, err = dynu.ReadResponse(strings.NewReader("badauth"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rs.AcceptedIPv4 != nil || rs.AcceptedIPv6 != nil {
+		t.Errorf("expected no accepted addresses, got %v %v", rs.AcceptedIPv4, rs.AcceptedIPv6)
+	}
+}
+
+func TestResponseReaderStrict(t *testing.T) {
+	hosts := []string{"a.example.com", "b.example.com"}
+	tests := []struct {
+		name   string
+		body   string
+		strict bool
+		err    bool
+	}{
+		{name: "match", body: "good 1.2.3.4\r\nnohost\r\n", strict: true},
+		{name: "count mismatch", body: "good 1.2.3.4\ngood 1.2.3.4\ngood 1.2.3.4", strict: true, err: true},
+		{name: "unexpected line", body: "good 1.2.3.4\n<html>", strict: true, err: true},
+		{name: "empty", body: "", strict: true, err: true},
+		{name: "lenient count mismatch", body: "good 1.2.3.4\ngood 1.2.3.4\ngood 1.2.3.4"},
+		{name: "lenient unexpected line", body: "good 1.2.3.4\n<html>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := dynu.ResponseReader{Strict: tt.strict, Hostnames: hosts}
+			_, err := rr.Read(strings.NewReader(tt.body))
+			if tt.err {
+				if _, ok := err.(dynu.ParseError); !ok {
+					t.Fatalf("expected ParseError, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestResponseResults(t *testing.T) {
+	rr := dynu.ResponseReader{Hostnames: []string{"a.example.com", "b.example.com"}}
+	rs, err := rr.Read(strings.NewReader("good 1.2.3.4\nnohost"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs.Results) != 2 || rs.Results[1].Hostname != "b.example.com" || rs.Results[1].Code != dynu.RespNohost {
+		t.Fatalf("unexpected results: %+v", rs.Results)
+	}
+	errs, ok := rs.ToError().(dynu.ResponseErrors)
+	if !ok || len(errs) != 1 || errs[0].Hostname != "b.example.com" {
+		t.Fatalf("unexpected errors: %v", rs.ToError())
+	}
+}
+
+func TestResponseResultsBatch(t *testing.T) {
+	rr := dynu.ResponseReader{Hostnames: []string{"a.example.com", "b.example.com"}}
+	rs, err := rr.Read(strings.NewReader("nohost"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs, ok := rs.ToError().(dynu.ResponseErrors)
+	if !ok || len(errs) != 2 || errs[0].Hostname != "a.example.com" || errs[1].Hostname != "b.example.com" {
+		t.Fatalf("expected an error for each hostname of the batch, got %v", rs.ToError())
+	}
+}
+
+func TestResponseMarshal(t *testing.T) {
+	rr := dynu.ResponseReader{Hostnames: []string{"a.example.com", "b.example.com"}}
+	rs, err := rr.Read(strings.NewReader("good 1.2.3.4\nnohost"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err := json.Marshal(rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"results":[{"hostname":"a.example.com","code":"good","detail":"1.2.3.4","error":false},{"hostname":"b.example.com","code":"nohost","error":true}],"accepted_ipv4":"1.2.3.4","error":true}`
+	if string(bs) != want {
+		t.Errorf("got  %s\nwant %s", bs, want)
+	}
+	if s := rs.String(); s != "a.example.com: good 1.2.3.4\nb.example.com: nohost" {
+		t.Errorf("unexpected string: %q", s)
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	newClient := func(options ...dynu.Option) *dynu.Client {
+		options = append(options,
+			dynu.HTTPClient(testRequester{t: t, resp: &http.Response{
+				Body: ioutil.NopCloser(strings.NewReader("nohost")),
+			}}),
+			dynu.Hostnames([]string{"dionysus.myddns.rocks"}),
+		)
+		return dynu.New("foo", "bar", options...)
+	}
+	ips := []net.IP{net.IPv4(14, 14, 22, 149)}
+
+	err := newClient().UpdateIP(ips)
+	errs, ok := err.(dynu.ResponseErrors)
+	if !ok {
+		t.Fatalf("expected ResponseErrors, got %v", err)
+	}
+	if b := errs.Behavior(); !b.Fatal || b.Retryable {
+		t.Errorf("default policy: unexpected behavior %+v", b)
+	}
+
+	err = newClient(dynu.RetryPolicy(dynu.Policy{
+		dynu.RespNohost: {Retryable: true, Cooldown: time.Minute},
+	})).UpdateIP(ips)
+	errs, ok = err.(dynu.ResponseErrors)
+	if !ok {
+		t.Fatalf("expected ResponseErrors, got %v", err)
+	}
+	if b := errs.Behavior(); b.Fatal || !b.Retryable || b.Cooldown != time.Minute {
+		t.Errorf("custom policy: unexpected behavior %+v", b)
+	}
+	if !errs[0].Temporary() {
+		t.Error("expected nohost to be temporary under the custom policy")
+	}
+	// Error stays comparable, so that callers can test for a specific error with ==
+	var target error = errs[0]
+	if target != error(errs[0]) {
+		t.Error("expected the error to equal itself")
+	}
+}
+
+func TestUpdateIPChanged(t *testing.T) {
+	for body, want := range map[string]bool{
+		"good 14.14.22.149":  true,
+		"nochg 14.14.22.149": false,
+		"nochg\ngood":        true,
+	} {
+		client := dynu.New("foo", "bar",
+			dynu.Hostnames([]string{"a.example.com", "b.example.com"}),
+			dynu.HTTPClient(testRequester{t: t, resp: &http.Response{
+				Body: ioutil.NopCloser(strings.NewReader(body)),
+			}}))
+		changed, err := client.UpdateIPChanged([]net.IP{net.IPv4(14, 14, 22, 149)})
+		if err != nil {
+			t.Errorf("%q: %v", body, err)
+		} else if changed != want {
+			t.Errorf("%q: changed = %v, want %v", body, changed, want)
+		}
+	}
+}
+
+type requesterFunc func(req *http.Request) (*http.Response, error)
+
+func (f requesterFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestUpdateIPContext(t *testing.T) {
+	client := dynu.New("foo", "bar", dynu.HTTPClient(requesterFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, req.Context().Err()
+	})))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := client.UpdateIPContext(ctx, []net.IP{net.IPv4(14, 14, 22, 149)}); err != context.Canceled {
+		t.Errorf("expected the request to be canceled with its context, got %v", err)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	var deadline time.Time
+	client := dynu.New("foo", "bar", dynu.Timeout(time.Minute), dynu.HTTPClient(requesterFunc(func(req *http.Request) (*http.Response, error) {
+		deadline, _ = req.Context().Deadline()
+		return nil, errors.New("offline")
+	})))
+	start := time.Now()
+	client.UpdateIP([]net.IP{net.IPv4(14, 14, 22, 149)})
+	if deadline.Before(start.Add(time.Minute)) || deadline.After(time.Now().Add(time.Minute)) {
+		t.Errorf("expected the call without a deadline to time out after a minute, got a deadline of %v", deadline)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	want, _ := ctx.Deadline()
+	client.UpdateIPContext(ctx, []net.IP{net.IPv4(14, 14, 22, 149)})
+	if !deadline.Equal(want) {
+		t.Errorf("expected the deadline of the context to be kept, got %v", deadline)
+	}
+
+	var bounded bool
+	client = dynu.New("foo", "bar", dynu.HTTPClient(requesterFunc(func(req *http.Request) (*http.Response, error) {
+		_, bounded = req.Context().Deadline()
+		return nil, errors.New("offline")
+	})))
+	client.UpdateIP([]net.IP{net.IPv4(14, 14, 22, 149)})
+	if bounded {
+		t.Error("expected no deadline without the Timeout option")
+	}
+}
+
+type logs []string
+
+func (l *logs) Log(format string, v ...interface{}) {
+	*l = append(*l, fmt.Sprintf(format, v...))
+}
+
+func TestDump(t *testing.T) {
+	var l logs
+	client := dynu.New("foo", "s3cret!",
+		dynu.HTTPClient(testRequester{t: t, resp: &http.Response{
+			Status: "200 OK", StatusCode: http.StatusOK, ProtoMajor: 1, ProtoMinor: 1,
+			Body: ioutil.NopCloser(strings.NewReader("good 14.14.22.149")),
+		}}),
+		dynu.Hostnames([]string{"dionysus.myddns.rocks"}),
+		dynu.Dump(&l),
+	)
+	if err := client.UpdateIP([]net.IP{net.IPv4(14, 14, 22, 149)}); err != nil {
+		t.Fatal(err)
+	}
+	dumps := strings.Join(l, "\n")
+	if !strings.Contains(dumps, "password=REDACTED") || !strings.Contains(dumps, "good 14.14.22.149") {
+		t.Errorf("unexpected dumps:\n%s", dumps)
+	}
+	// with the SHA-256 of the password
+	if strings.Contains(dumps, "s3cret") || strings.Contains(dumps, "5cb7b35eb7ae9bbd505baa5cde3fb64c1e9a5e8862072013989c0a557ab9eaa2") {
+		t.Errorf("the password is not redacted in\n%s", dumps)
+	}
+}
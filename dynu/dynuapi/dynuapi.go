@@ -0,0 +1,452 @@
+// Package dynuapi is a client for the REST API of dynu.com, at api.dynu.com/v2. Unlike the IP Update API of
+// package dynu, it manages the domains of the account and their DNS records of any type, with their TTLs:
+//
+//	client := dynuapi.New(dynuapi.APIKey(key))
+//	root, err := client.Root(ctx, "www.example.com")
+//	...
+//	_, err = client.CreateRecord(ctx, root.ID, dynuapi.Record{NodeName: root.Node, RecordType: "TXT", TextData: "hello", TTL: 300, State: true})
+//
+// It authenticates with the API key of the control panel, or with the client ID and secret of an OAuth2
+// application.
+package dynuapi // import "github.com/justenwalker/ddns/dynu/dynuapi"
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/justenwalker/ddns/internal/httpreq"
+	"github.com/justenwalker/ddns/logging"
+)
+
+// DefaultEndpoint is the base URL of the REST API
+const DefaultEndpoint = "https://api.dynu.com/v2"
+
+// Logger for printing debug logs from this package
+type Logger interface {
+	Log(format string, v ...interface{})
+}
+
+// HTTPRequester makes http requests and returns responses.
+// *http.Client implicitly implements HTTPRequester and can be provided wherever this interface is requested.
+type HTTPRequester = httpreq.Requester
+
+// Option sets client options
+type Option func(*Client)
+
+// APIKey authenticates with the API key found in the control panel of dynu.com
+func APIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// OAuth2 authenticates with access tokens of the OAuth2 application with the given client ID and secret,
+// found in the control panel of dynu.com. The tokens are renewed before they expire.
+func OAuth2(clientID, secret string) Option {
+	return func(c *Client) {
+		c.clientID, c.secret = clientID, secret
+	}
+}
+
+// Endpoint sets the base URL of the REST API
+// The default should normally be fine
+func Endpoint(endpoint string) Option {
+	return func(c *Client) {
+		c.endpoint = strings.TrimSuffix(endpoint, "/")
+	}
+}
+
+// HTTPClient sets a custom HTTP client to use for all of the API calls
+// the default uses http.DefaultClient
+func HTTPClient(hc HTTPRequester) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// Debug logs each request and its response using the given Logger
+func Debug(l Logger) Option {
+	return func(c *Client) {
+		c.debug = l
+	}
+}
+
+// Client for the REST API of dynu.com
+type Client struct {
+	endpoint   string
+	httpClient HTTPRequester
+	debug      Logger
+	apiKey     string
+	clientID   string
+	secret     string
+
+	// mu guards the OAuth2 access token
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// New constructs a client of the REST API; it needs the APIKey or OAuth2 option
+func New(options ...Option) *Client {
+	c := &Client{
+		endpoint:   DefaultEndpoint,
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range options {
+		opt(c)
+	}
+	return c
+}
+
+// Domain is a domain or dynamic host of the account, such as example.dynu.net or a domain hosted by dynu.com
+type Domain struct {
+	ID          int64  `json:"id,omitempty"`
+	Name        string `json:"name"`
+	UnicodeName string `json:"unicodeName,omitempty"`
+	State       string `json:"state,omitempty"`
+	// Group is the group, or location, of the domain; the IP Update API updates the domains of a group together
+	Group       string `json:"group"`
+	IPv4Address string `json:"ipv4Address"`
+	IPv6Address string `json:"ipv6Address"`
+	// IPv4 and IPv6 are true if the domain publishes an address of the family
+	IPv4 bool `json:"ipv4"`
+	IPv6 bool `json:"ipv6"`
+	TTL  int  `json:"ttl"`
+	// IPv4WildcardAlias and IPv6WildcardAlias are true if the subdomains without records of their own resolve to
+	// the addresses of the domain
+	IPv4WildcardAlias bool       `json:"ipv4WildcardAlias"`
+	IPv6WildcardAlias bool       `json:"ipv6WildcardAlias"`
+	UpdatedOn         *time.Time `json:"updatedOn,omitempty"`
+}
+
+// Root is the domain of a hostname and the node of the hostname in it, as returned by Root
+type Root struct {
+	// ID is that of the domain
+	ID         int64  `json:"id"`
+	DomainName string `json:"domainName"`
+	Hostname   string `json:"hostname"`
+	// Node is the name of the hostname in the domain, such as www for www.example.com, or empty for the domain
+	Node string `json:"node"`
+}
+
+// Record is a DNS record of a domain. The fields set depend on its RecordType: IPv4Address for A,
+// IPv6Address for AAAA, Host for CNAME, MX, NS and SRV, TextData for TXT, Priority for MX and SRV,
+// Weight and Port for SRV, and Flags, Tag and Value for CAA.
+type Record struct {
+	ID       int64 `json:"id,omitempty"`
+	DomainID int64 `json:"domainId,omitempty"`
+	// NodeName is the name of the record in the domain, such as www for www.example.com, or empty for the domain
+	NodeName   string `json:"nodeName"`
+	Hostname   string `json:"hostname,omitempty"`
+	RecordType string `json:"recordType"`
+	TTL        int    `json:"ttl"`
+	// State is false if the record is disabled
+	State bool `json:"state"`
+	// Content is the record data in zone file format, as returned by the API
+	Content     string     `json:"content,omitempty"`
+	IPv4Address string     `json:"ipv4Address,omitempty"`
+	IPv6Address string     `json:"ipv6Address,omitempty"`
+	Host        string     `json:"host,omitempty"`
+	TextData    string     `json:"textData,omitempty"`
+	Priority    int        `json:"priority,omitempty"`
+	Weight      int        `json:"weight,omitempty"`
+	Port        int        `json:"port,omitempty"`
+	Flags       int        `json:"flags,omitempty"`
+	Tag         string     `json:"tag,omitempty"`
+	Value       string     `json:"value,omitempty"`
+	UpdatedOn   *time.Time `json:"updatedOn,omitempty"`
+}
+
+// APIError is an error response of the REST API
+type APIError struct {
+	StatusCode int    `json:"statusCode"`
+	Type       string `json:"type"`
+	Message    string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	if e.Type == "" {
+		return fmt.Sprintf("dynu: API returned %d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("dynu: API returned %d: %s: %s", e.StatusCode, e.Type, e.Message)
+}
+
+// Group is a group of domains, whose addresses the IP Update API updates together with its location parameter
+type Group struct {
+	Name    string
+	Domains []Domain
+}
+
+// Domains returns the domains of the account
+func (c *Client) Domains(ctx context.Context) ([]Domain, error) {
+	var out struct {
+		Domains []Domain `json:"domains"`
+	}
+	if err := c.call(ctx, http.MethodGet, "/dns", nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Domains, nil
+}
+
+// Domain returns the domain with the given ID
+func (c *Client) Domain(ctx context.Context, id int64) (Domain, error) {
+	var out Domain
+	err := c.call(ctx, http.MethodGet, domainPath(id), nil, &out)
+	return out, err
+}
+
+// UpdateDomain changes the settings of the domain with the ID of d, such as its TTL or group
+func (c *Client) UpdateDomain(ctx context.Context, d Domain) error {
+	return c.call(ctx, http.MethodPost, domainPath(d.ID), d, nil)
+}
+
+// Root returns the domain of hostname, such as example.com for www.example.com, and the node of hostname in it
+func (c *Client) Root(ctx context.Context, hostname string) (Root, error) {
+	var out Root
+	err := c.call(ctx, http.MethodGet, "/dns/getroot/"+url.PathEscape(hostname), nil, &out)
+	return out, err
+}
+
+// Records returns the DNS records of the domain with the given ID
+func (c *Client) Records(ctx context.Context, domainID int64) ([]Record, error) {
+	var out struct {
+		Records []Record `json:"dnsRecords"`
+	}
+	if err := c.call(ctx, http.MethodGet, recordsPath(domainID), nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Records, nil
+}
+
+// Record returns the DNS record with the given ID of the domain with the given ID
+func (c *Client) Record(ctx context.Context, domainID, recordID int64) (Record, error) {
+	var out Record
+	err := c.call(ctx, http.MethodGet, recordPath(domainID, recordID), nil, &out)
+	return out, err
+}
+
+// CreateRecord adds r to the domain with the given ID and returns it, with its ID
+func (c *Client) CreateRecord(ctx context.Context, domainID int64, r Record) (Record, error) {
+	var out Record
+	err := c.call(ctx, http.MethodPost, recordsPath(domainID), r, &out)
+	return out, err
+}
+
+// UpdateRecord replaces the record with the ID of r in the domain with the given ID and returns it
+func (c *Client) UpdateRecord(ctx context.Context, domainID int64, r Record) (Record, error) {
+	var out Record
+	err := c.call(ctx, http.MethodPost, recordPath(domainID, r.ID), r, &out)
+	return out, err
+}
+
+// DeleteRecord removes the record with the given ID of the domain with the given ID
+func (c *Client) DeleteRecord(ctx context.Context, domainID, recordID int64) error {
+	return c.call(ctx, http.MethodDelete, recordPath(domainID, recordID), nil, nil)
+}
+
+// Groups returns the groups of the domains of the account, sorted by name; the domains without a group are not
+// returned
+func (c *Client) Groups(ctx context.Context) ([]Group, error) {
+	domains, err := c.Domains(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]*Group)
+	var groups []*Group
+	for _, d := range domains {
+		if d.Group == "" {
+			continue
+		}
+		g, ok := byName[d.Group]
+		if !ok {
+			g = &Group{Name: d.Group}
+			byName[d.Group] = g
+			groups = append(groups, g)
+		}
+		g.Domains = append(g.Domains, d)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+	out := make([]Group, len(groups))
+	for i, g := range groups {
+		out[i] = *g
+	}
+	return out, nil
+}
+
+// SetGroup moves the domain with the given ID to group, creating it if needed; an empty group removes the
+// domain from its group
+func (c *Client) SetGroup(ctx context.Context, domainID int64, group string) error {
+	d, err := c.Domain(ctx, domainID)
+	if err != nil {
+		return err
+	}
+	d.Group = group
+	return c.UpdateDomain(ctx, d)
+}
+
+func domainPath(id int64) string {
+	return "/dns/" + strconv.FormatInt(id, 10)
+}
+
+func recordsPath(domainID int64) string {
+	return domainPath(domainID) + "/record"
+}
+
+func recordPath(domainID, recordID int64) string {
+	return recordsPath(domainID) + "/" + strconv.FormatInt(recordID, 10)
+}
+
+// call sends a request to path, with in encoded as JSON if it is not nil, and decodes the response into out if it
+// is not nil
+func (c *Client) call(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	switch {
+	case c.apiKey != "":
+		req.Header.Set("API-Key", c.apiKey)
+	case c.clientID != "":
+		token, err := c.accessToken(ctx)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	default:
+		return errors.New("dynu: an API key or OAuth2 client is required")
+	}
+	data, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("dynu: invalid API response: %v", err)
+	}
+	return nil
+}
+
+// do sends req and returns the body of its response, or the error it reports
+func (c *Client) do(req *http.Request) ([]byte, error) {
+	req.Header.Set("Accept", "application/json")
+	if c.debug != nil {
+		logging.Print(c.debug, slog.LevelDebug, []slog.Attr{slog.String("method", req.Method), slog.String("url", req.URL.String())},
+			"dynu: %s %s", req.Method, req.URL)
+	}
+	start := time.Now()
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if c.debug != nil {
+		logging.Print(c.debug, slog.LevelDebug, []slog.Attr{slog.Int("status_code", resp.StatusCode), slog.Duration("duration", time.Since(start))},
+			"dynu: %s: %q", resp.Status, redactToken(data))
+	}
+	// errors are reported in the body, with a statusCode that matches that of the response
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+	if err := json.Unmarshal(data, apiErr); err != nil {
+		if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+			// left to the caller, such as an empty body
+			return data, nil
+		}
+		// such as the HTML page of a proxy or of an outage
+		return nil, &APIError{StatusCode: resp.StatusCode, Message: errorBody(resp.StatusCode, data)}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 || apiErr.StatusCode > 299 {
+		if apiErr.Message == "" {
+			apiErr.Message = http.StatusText(apiErr.StatusCode)
+		}
+		return nil, apiErr
+	}
+	return data, nil
+}
+
+// maxErrorBody is how much of a body that is not JSON an APIError holds
+const maxErrorBody = 256
+
+// errorBody returns the status text followed by the start of the body, for errors whose body is not JSON
+func errorBody(statusCode int, data []byte) string {
+	msg := http.StatusText(statusCode)
+	body := strings.TrimSpace(string(data))
+	if len(body) > maxErrorBody {
+		body = body[:maxErrorBody] + "..."
+	}
+	if body == "" {
+		return msg
+	}
+	return msg + ": " + body
+}
+
+// tokenMargin is how long before it expires an access token is renewed
+const tokenMargin = time.Minute
+
+// accessToken returns the OAuth2 access token, requesting a new one if it expires soon
+func (c *Client) accessToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && time.Until(c.expires) > tokenMargin {
+		return c.token, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint+"/oauth2/token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(c.clientID, c.secret)
+	data, err := c.do(req)
+	if err != nil {
+		return "", err
+	}
+	var out struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil || out.AccessToken == "" {
+		return "", fmt.Errorf("dynu: invalid OAuth2 token response")
+	}
+	c.token, c.expires = out.AccessToken, time.Now().Add(time.Duration(out.ExpiresIn)*time.Second)
+	return c.token, nil
+}
+
+// redactToken hides the access token of an OAuth2 token response in the debug logs
+func redactToken(data []byte) []byte {
+	var out map[string]interface{}
+	if json.Unmarshal(data, &out) != nil {
+		return data
+	}
+	if _, ok := out["access_token"]; !ok {
+		return data
+	}
+	out["access_token"] = "REDACTED"
+	redacted, _ := json.Marshal(out)
+	return redacted
+}
@@ -0,0 +1,215 @@
+package dynuapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/justenwalker/ddns/dynu/dynuapi"
+)
+
+// fakeAPI serves the domains and records of one account, authenticating with an API key or OAuth2 tokens
+type fakeAPI struct {
+	domains map[int64]*dynuapi.Domain
+	records map[int64][]dynuapi.Record
+	tokens  int
+}
+
+func newFakeAPI() *fakeAPI {
+	return &fakeAPI{
+		domains: map[int64]*dynuapi.Domain{
+			1: {ID: 1, Name: "home.dynu.net", Group: "home", IPv4Address: "203.0.113.7", IPv4: true, TTL: 90},
+			2: {ID: 2, Name: "example.com", IPv4: true, IPv6: true, TTL: 300},
+			3: {ID: 3, Name: "cabin.dynu.net", Group: "home", IPv4: true, TTL: 90},
+		},
+		records: map[int64][]dynuapi.Record{
+			2: {{ID: 10, DomainID: 2, NodeName: "www", Hostname: "www.example.com", RecordType: "A", TTL: 300, State: true, IPv4Address: "203.0.113.7"}},
+		},
+	}
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/v2/oauth2/token" {
+		if id, secret, ok := r.BasicAuth(); !ok || id != "client" || secret != "secret" {
+			f.fail(w, http.StatusUnauthorized, "Invalid client credentials.")
+			return
+		}
+		f.tokens++
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": fmt.Sprintf("token%d", f.tokens), "token_type": "bearer", "expires_in": 28800})
+		return
+	}
+	if r.Header.Get("API-Key") != "key" && r.Header.Get("Authorization") != fmt.Sprintf("Bearer token%d", f.tokens) {
+		f.fail(w, http.StatusUnauthorized, "Invalid API key.")
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v2/dns"), "/")
+	var id, recordID int64
+	if len(parts) > 1 {
+		fmt.Sscan(parts[1], &id)
+	}
+	if len(parts) > 3 {
+		fmt.Sscan(parts[3], &recordID)
+	}
+	switch {
+	case r.URL.Path == "/v2/dns" && r.Method == http.MethodGet:
+		var domains []*dynuapi.Domain
+		for i := int64(1); i <= 3; i++ {
+			domains = append(domains, f.domains[i])
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"statusCode": 200, "domains": domains})
+	case len(parts) == 3 && parts[1] == "getroot":
+		json.NewEncoder(w).Encode(dynuapi.Root{ID: 2, DomainName: "example.com", Hostname: parts[2], Node: strings.TrimSuffix(parts[2], ".example.com")})
+	case len(parts) == 2 && f.domains[id] != nil && r.Method == http.MethodGet:
+		json.NewEncoder(w).Encode(f.domains[id])
+	case len(parts) == 2 && f.domains[id] != nil && r.Method == http.MethodPost:
+		var d dynuapi.Domain
+		json.NewDecoder(r.Body).Decode(&d)
+		f.domains[id] = &d
+		json.NewEncoder(w).Encode(map[string]interface{}{"statusCode": 200})
+	case len(parts) == 3 && r.Method == http.MethodGet:
+		json.NewEncoder(w).Encode(map[string]interface{}{"statusCode": 200, "dnsRecords": f.records[id]})
+	case len(parts) == 3 && r.Method == http.MethodPost:
+		var rec dynuapi.Record
+		json.NewDecoder(r.Body).Decode(&rec)
+		if rec.RecordType == "" {
+			f.fail(w, http.StatusBadRequest, "Record type is required.")
+			return
+		}
+		rec.ID, rec.DomainID = int64(100+len(f.records[id])), id
+		f.records[id] = append(f.records[id], rec)
+		json.NewEncoder(w).Encode(rec)
+	case len(parts) == 4:
+		for i, rec := range f.records[id] {
+			if rec.ID != recordID {
+				continue
+			}
+			switch r.Method {
+			case http.MethodGet:
+				json.NewEncoder(w).Encode(rec)
+			case http.MethodPost:
+				json.NewDecoder(r.Body).Decode(&rec)
+				f.records[id][i] = rec
+				json.NewEncoder(w).Encode(rec)
+			case http.MethodDelete:
+				f.records[id] = append(f.records[id][:i], f.records[id][i+1:]...)
+				json.NewEncoder(w).Encode(map[string]interface{}{"statusCode": 200})
+			}
+			return
+		}
+		f.fail(w, http.StatusNotFound, "Record not found.")
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeAPI) fail(w http.ResponseWriter, code int, msg string) {
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{"statusCode": code, "type": "Exception", "message": msg})
+}
+
+func TestRecords(t *testing.T) {
+	api := newFakeAPI()
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+	ctx := context.Background()
+	client := dynuapi.New(dynuapi.Endpoint(srv.URL+"/v2"), dynuapi.APIKey("key"))
+
+	root, err := client.Root(ctx, "www.example.com")
+	if err != nil || root.ID != 2 || root.Node != "www" {
+		t.Fatalf("unexpected root %+v: %v", root, err)
+	}
+	records, err := client.Records(ctx, root.ID)
+	if err != nil || len(records) != 1 || records[0].IPv4Address != "203.0.113.7" {
+		t.Fatalf("unexpected records %+v: %v", records, err)
+	}
+	txt, err := client.CreateRecord(ctx, root.ID, dynuapi.Record{NodeName: "_acme", RecordType: "TXT", TextData: "token", TTL: 60, State: true})
+	if err != nil || txt.ID == 0 || txt.DomainID != 2 {
+		t.Fatalf("unexpected created record %+v: %v", txt, err)
+	}
+	rec := records[0]
+	rec.TTL, rec.IPv4Address = 60, "203.0.113.8"
+	if _, err := client.UpdateRecord(ctx, root.ID, rec); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := client.Record(ctx, root.ID, rec.ID); err != nil || got.TTL != 60 || got.IPv4Address != "203.0.113.8" {
+		t.Errorf("expected the record to be updated, got %+v: %v", got, err)
+	}
+	if err := client.DeleteRecord(ctx, root.ID, txt.ID); err != nil {
+		t.Fatal(err)
+	}
+	if records, _ := client.Records(ctx, root.ID); len(records) != 1 {
+		t.Errorf("expected the record to be deleted, got %+v", records)
+	}
+
+	_, err = client.CreateRecord(ctx, root.ID, dynuapi.Record{NodeName: "bad"})
+	var apiErr *dynuapi.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || apiErr.Message != "Record type is required." {
+		t.Errorf("expected the API error, got %v", err)
+	}
+	if err := dynuapi.New(dynuapi.Endpoint(srv.URL+"/v2")).DeleteRecord(ctx, 2, 10); err == nil {
+		t.Error("expected an error without credentials")
+	}
+}
+
+func TestGroups(t *testing.T) {
+	api := newFakeAPI()
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+	ctx := context.Background()
+	client := dynuapi.New(dynuapi.Endpoint(srv.URL+"/v2"), dynuapi.APIKey("key"))
+
+	if err := client.SetGroup(ctx, 2, "office"); err != nil {
+		t.Fatal(err)
+	}
+	if d := api.domains[2]; d.Group != "office" || d.TTL != 300 || !d.IPv6 {
+		t.Errorf("expected only the group of the domain to change, got %+v", d)
+	}
+	groups, err := client.Groups(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 2 || groups[0].Name != "home" || len(groups[0].Domains) != 2 || groups[1].Name != "office" || groups[1].Domains[0].Name != "example.com" {
+		t.Errorf("unexpected groups %+v", groups)
+	}
+}
+
+func TestOAuth2(t *testing.T) {
+	api := newFakeAPI()
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+	ctx := context.Background()
+	client := dynuapi.New(dynuapi.Endpoint(srv.URL+"/v2"), dynuapi.OAuth2("client", "secret"))
+	for i := 0; i < 2; i++ {
+		if domains, err := client.Domains(ctx); err != nil || len(domains) != 3 {
+			t.Fatalf("unexpected domains %+v: %v", domains, err)
+		}
+	}
+	if api.tokens != 1 {
+		t.Errorf("expected the access token to be reused, got %d tokens", api.tokens)
+	}
+
+	_, err := dynuapi.New(dynuapi.Endpoint(srv.URL+"/v2"), dynuapi.OAuth2("client", "wrong")).Domains(ctx)
+	var apiErr *dynuapi.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected the API error, got %v", err)
+	}
+}
+
+func TestErrorNotJSON(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte("<html>upstream unavailable</html>\n"))
+	}))
+	defer srv.Close()
+	_, err := dynuapi.New(dynuapi.Endpoint(srv.URL+"/v2"), dynuapi.APIKey("key")).Domains(context.Background())
+	var apiErr *dynuapi.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadGateway ||
+		apiErr.Message != "Bad Gateway: <html>upstream unavailable</html>" {
+		t.Errorf("expected the status and the body, got %v", err)
+	}
+}
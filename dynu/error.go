@@ -5,17 +5,27 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"strings"
 )
 
 // Error encapsualtes response code errors and their mapping to the request in a multi-request response
 type Error struct {
-	Request int
-	Code    ResponseCode
-	Detail  string
+	Request  int
+	Hostname string
+	Code     ResponseCode
+	Detail   string
+
+	// behavior is resolved from the client's Policy when the error is built; the zero Error uses DefaultPolicy.
+	// It is a plain value so that Error stays comparable.
+	behavior Behavior
+	resolved bool
 }
 
 func (e Error) Error() string {
+	if e.Hostname != "" {
+		return fmt.Sprintf("%s: %s", e.Hostname, Error{Code: e.Code, Detail: e.Detail}.Error())
+	}
 	if e.Code != "" {
 		if e.Detail != "" {
 			return fmt.Sprintf("%s: %s", e.Code, e.Detail)
@@ -27,16 +37,17 @@ func (e Error) Error() string {
 
 // Temporary returns true if the error is temporary and may succeed after a retry
 func (e Error) Temporary() bool {
-	switch e.Code {
-	case RespDNS, Resp911, RespServerError:
-		return true
-	}
-	return false
+	return e.Behavior().Retryable
 }
 
 // ResponseErrors implements the error interface for multi-request responses
 type ResponseErrors []Error
 
+// Temporary returns true if any of the requests may succeed after a retry
+func (rs ResponseErrors) Temporary() bool {
+	return rs.Behavior().Retryable
+}
+
 // ResponseErrors returns the response error string
 func (rs ResponseErrors) Error() string {
 	buf := &bytes.Buffer{}
@@ -54,18 +65,60 @@ type ResponseCode string
 type Response struct {
 	Codes  []ResponseCode
 	Detail []string
+
+	// AcceptedIPv4 is the IPv4 address reported back by the server in a good/nochg response, if any
+	AcceptedIPv4 net.IP
+	// AcceptedIPv6 is the IPv6 address reported back by the server in a good/nochg response, if any
+	AcceptedIPv6 net.IP
+
+	// Results maps each response code to the hostname it belongs to
+	Results []UpdateResult
+}
+
+// UpdateResult is the outcome of the update for a single hostname
+type UpdateResult struct {
+	// Hostname is empty when the update was made by username or location
+	Hostname string
+	Code     ResponseCode
+	Detail   string
+}
+
+// Unchanged returns true if every request was answered with nochg: the server already had the addresses
+func (rs Response) Unchanged() bool {
+	for _, r := range rs.Codes {
+		if r != RespNoChange {
+			return false
+		}
+	}
+	return len(rs.Codes) > 0
 }
 
-// ToError returns the response errors, or nil if there were no errors
+// ToError returns the response errors, or nil if there were no errors.
+// The errors are classified using DefaultPolicy.
 func (rs Response) ToError() error {
+	return rs.toError(nil)
+}
+
+func (rs Response) toError(p Policy) error {
 	var errs ResponseErrors
 	for i, r := range rs.Codes {
 		if r.IsError() {
-			errs = append(errs, Error{
-				Request: i,
-				Code:    r,
-				Detail:  rs.Detail[i],
-			})
+			e := Error{
+				Request:  i,
+				Code:     r,
+				Detail:   rs.Detail[i],
+				behavior: p.Lookup(r),
+				resolved: true,
+			}
+			hostnames := rs.hostnames(i)
+			if len(hostnames) == 0 {
+				errs = append(errs, e)
+				continue
+			}
+			for _, h := range hostnames {
+				e.Hostname = h
+				errs = append(errs, e)
+			}
 		}
 	}
 	if len(errs) == 0 {
@@ -74,27 +127,162 @@ func (rs Response) ToError() error {
 	return errs
 }
 
-// ReadResponse reads the response code from the http response body
+// hostnames returns the hostnames the code at index i answers: all of them when a single code answers a batch
+func (rs Response) hostnames(i int) []string {
+	if len(rs.Codes) == 1 && len(rs.Results) > 1 {
+		hostnames := make([]string, 0, len(rs.Results))
+		for _, r := range rs.Results {
+			hostnames = append(hostnames, r.Hostname)
+		}
+		return hostnames
+	}
+	if i < len(rs.Results) && rs.Results[i].Hostname != "" {
+		return []string{rs.Results[i].Hostname}
+	}
+	return nil
+}
+
+// ReadResponse reads the response code from the http response body.
+// It parses leniently; use ResponseReader to enable strict parsing or hostname mapping.
 func ReadResponse(r io.Reader) (*Response, error) {
+	return ResponseReader{}.Read(r)
+}
+
+// ParseError is returned by strict response parsing when the response does not match the request
+type ParseError struct {
+	// Line is the 1-based line of the response body, or 0 if the error is not specific to one line
+	Line   int
+	Text   string
+	Reason string
+}
+
+func (e ParseError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("dynu: response line %d %q: %s", e.Line, e.Text, e.Reason)
+	}
+	return fmt.Sprintf("dynu: %s", e.Reason)
+}
+
+// ResponseReader parses the IP Update API response body.
+//
+// In lenient mode (the default) unexpected lines and mismatched code counts are logged and parsing continues.
+// In strict mode they are returned as a ParseError so that mis-indexed responses cannot mask failures.
+type ResponseReader struct {
+	Strict bool
+	// Hostnames requested, in order. Each response line is mapped onto the hostname at the same position.
+	// When empty, a single response line is expected.
+	Hostnames []string
+	Logger    Logger
+}
+
+func (rr ResponseReader) logf(format string, v ...interface{}) {
+	if rr.Logger != nil {
+		rr.Logger.Log(format, v...)
+	}
+}
+
+// Read parses the response from r
+func (rr ResponseReader) Read(r io.Reader) (*Response, error) {
 	body, err := ioutil.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
 	var response Response
-	codes := strings.Split(string(body), "\n\r")
-	for _, code := range codes {
-		sp := strings.SplitN(code, " ", 2)
-		rc := ResponseCode(strings.TrimSpace(strings.ToLower(sp[0])))
+	for i, line := range strings.Split(string(body), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		sp := strings.SplitN(line, " ", 2)
+		rc := ResponseCode(strings.ToLower(sp[0]))
 		detail := ""
 		if len(sp) > 1 {
-			detail = sp[1]
+			detail = strings.TrimSpace(sp[1])
+		}
+		if !rc.Known() {
+			if rr.Strict {
+				return nil, ParseError{Line: i + 1, Text: line, Reason: "unexpected response code"}
+			}
+			rr.logf("dynu: response line %d %q: unexpected response code", i+1, line)
 		}
 		response.Codes = append(response.Codes, rc)
 		response.Detail = append(response.Detail, detail)
+		if !rc.IsError() {
+			response.parseAccepted(detail)
+		}
+	}
+	if len(response.Codes) == 0 {
+		if rr.Strict {
+			return nil, ParseError{Reason: "empty response"}
+		}
+		rr.logf("dynu: empty response")
+		response.Codes = []ResponseCode{""}
+		response.Detail = []string{""}
+	}
+	if err := response.mapResults(rr.Hostnames); err != nil {
+		if rr.Strict {
+			return nil, err
+		}
+		rr.logf("%v", err)
 	}
 	return &response, nil
 }
 
+// mapResults assigns each response code to the hostname it answers.
+// It returns a ParseError if the number of codes does not match the number of hostnames.
+// A single code for several hostnames is applied to all of them, since the server may answer a batch with one line.
+func (rs *Response) mapResults(hostnames []string) error {
+	expected := len(hostnames)
+	if expected == 0 {
+		expected = 1
+	}
+	rs.Results = nil
+	if len(hostnames) > 1 && len(rs.Codes) == 1 {
+		for _, h := range hostnames {
+			rs.Results = append(rs.Results, UpdateResult{Hostname: h, Code: rs.Codes[0], Detail: rs.Detail[0]})
+		}
+		return nil
+	}
+	for i, rc := range rs.Codes {
+		res := UpdateResult{Code: rc, Detail: rs.Detail[i]}
+		if i < len(hostnames) {
+			res.Hostname = hostnames[i]
+		}
+		rs.Results = append(rs.Results, res)
+	}
+	if len(rs.Codes) != expected {
+		return ParseError{Reason: fmt.Sprintf("expected %d response code(s), got %d", expected, len(rs.Codes))}
+	}
+	return nil
+}
+
+// parseAccepted records the first IPv4 and IPv6 address found in the detail text of a successful response
+func (rs *Response) parseAccepted(detail string) {
+	for _, field := range strings.Fields(detail) {
+		ip := net.ParseIP(field)
+		if ip == nil {
+			continue
+		}
+		if ipv4 := ip.To4(); ipv4 != nil {
+			if rs.AcceptedIPv4 == nil {
+				rs.AcceptedIPv4 = ipv4
+			}
+		} else if rs.AcceptedIPv6 == nil {
+			rs.AcceptedIPv6 = ip
+		}
+	}
+}
+
+// Known returns true if the response code is one documented by the IP Update API
+func (rc ResponseCode) Known() bool {
+	switch rc {
+	case RespUnknown, RespGood, RespBadAuth, RespServerError, RespNoChange, RespNotFQDN,
+		RespNumHost, RespAbuse, RespNohost, Resp911, RespDNS, RespNotDonator:
+		return true
+	}
+	return false
+}
+
 // IsError returns true if the response code is an error
 func (rc ResponseCode) IsError() bool {
 	switch rc {
@@ -0,0 +1,92 @@
+package dynu
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// String returns the response code as sent by the server
+func (rc ResponseCode) String() string {
+	return string(rc)
+}
+
+// MarshalJSON encodes the response code as a JSON string
+func (rc ResponseCode) MarshalJSON() ([]byte, error) {
+	return json.Marshal(string(rc))
+}
+
+// String formats the result as "hostname: code detail"
+func (r UpdateResult) String() string {
+	s := string(r.Code)
+	if r.Detail != "" {
+		s += " " + r.Detail
+	}
+	if r.Hostname != "" {
+		s = r.Hostname + ": " + s
+	}
+	return s
+}
+
+type jsonUpdateResult struct {
+	Hostname string       `json:"hostname,omitempty"`
+	Code     ResponseCode `json:"code"`
+	Detail   string       `json:"detail,omitempty"`
+	Error    bool         `json:"error"`
+}
+
+// MarshalJSON encodes the result as a JSON object with hostname, code, detail and error fields
+func (r UpdateResult) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonUpdateResult{
+		Hostname: r.Hostname,
+		Code:     r.Code,
+		Detail:   r.Detail,
+		Error:    r.Code.IsError(),
+	})
+}
+
+// String formats each result on its own line
+func (rs Response) String() string {
+	results := rs.results()
+	lines := make([]string, len(results))
+	for i, r := range results {
+		lines[i] = r.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+type jsonResponse struct {
+	Results      []UpdateResult `json:"results"`
+	AcceptedIPv4 string         `json:"accepted_ipv4,omitempty"`
+	AcceptedIPv6 string         `json:"accepted_ipv6,omitempty"`
+	Error        bool           `json:"error"`
+}
+
+// MarshalJSON encodes the response as a JSON object with the per-hostname results and accepted addresses
+func (rs Response) MarshalJSON() ([]byte, error) {
+	jr := jsonResponse{
+		Results: rs.results(),
+		Error:   rs.ToError() != nil,
+	}
+	if jr.Results == nil {
+		jr.Results = []UpdateResult{}
+	}
+	if rs.AcceptedIPv4 != nil {
+		jr.AcceptedIPv4 = rs.AcceptedIPv4.String()
+	}
+	if rs.AcceptedIPv6 != nil {
+		jr.AcceptedIPv6 = rs.AcceptedIPv6.String()
+	}
+	return json.Marshal(jr)
+}
+
+// results returns the mapped results, or unmapped results built from the raw codes
+func (rs Response) results() []UpdateResult {
+	if len(rs.Results) > 0 {
+		return rs.Results
+	}
+	var results []UpdateResult
+	for i, rc := range rs.Codes {
+		results = append(results, UpdateResult{Code: rc, Detail: rs.Detail[i]})
+	}
+	return results
+}
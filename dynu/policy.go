@@ -0,0 +1,84 @@
+package dynu
+
+import "time"
+
+// Behavior describes how a caller should react to a response code
+type Behavior struct {
+	// Retryable is true if the same request may succeed when sent again
+	Retryable bool
+	// Fatal is true if the request will keep failing until the configuration is fixed
+	Fatal bool
+	// Cooldown is the minimum time to wait before sending another request
+	Cooldown time.Duration
+}
+
+// Policy maps response codes to the behavior callers should apply when they are returned.
+// Codes missing from a Policy fall back to DefaultPolicy.
+type Policy map[ResponseCode]Behavior
+
+// DefaultPolicy returns the behavior recommended by the IP Update API documentation
+func DefaultPolicy() Policy {
+	fatal := Behavior{Fatal: true}
+	return Policy{
+		RespGood:        {},
+		RespNoChange:    {},
+		RespServerError: {Retryable: true, Cooldown: time.Minute},
+		RespDNS:         {Retryable: true, Cooldown: time.Minute},
+		Resp911:         {Retryable: true, Cooldown: 10 * time.Minute},
+		RespAbuse:       {Fatal: true, Cooldown: time.Hour},
+		RespUnknown:     fatal,
+		RespBadAuth:     fatal,
+		RespNotFQDN:     fatal,
+		RespNumHost:     fatal,
+		RespNohost:      fatal,
+		RespNotDonator:  fatal,
+	}
+}
+
+// Lookup returns the behavior for the response code.
+// Codes that are in neither p nor DefaultPolicy are treated as fatal.
+func (p Policy) Lookup(rc ResponseCode) Behavior {
+	if b, ok := p[rc]; ok {
+		return b
+	}
+	if b, ok := DefaultPolicy()[rc]; ok {
+		return b
+	}
+	return Behavior{Fatal: true}
+}
+
+// RetryPolicy sets the policy used to classify response codes in errors returned by the client.
+// The default uses DefaultPolicy.
+func RetryPolicy(p Policy) Option {
+	return func(c *Client) {
+		c.policy = p
+	}
+}
+
+// Behavior returns the behavior for the error's response code
+func (e Error) Behavior() Behavior {
+	if e.resolved {
+		return e.behavior
+	}
+	return DefaultPolicy().Lookup(e.Code)
+}
+
+// Behavior combines the behavior of all errors in a multi-request response.
+// The combined error is retryable if any request is retryable, fatal only if every request is fatal,
+// and uses the longest cooldown.
+func (rs ResponseErrors) Behavior() Behavior {
+	var b Behavior
+	if len(rs) == 0 {
+		return b
+	}
+	b.Fatal = true
+	for _, e := range rs {
+		eb := e.Behavior()
+		b.Retryable = b.Retryable || eb.Retryable
+		b.Fatal = b.Fatal && eb.Fatal
+		if eb.Cooldown > b.Cooldown {
+			b.Cooldown = eb.Cooldown
+		}
+	}
+	return b
+}
@@ -0,0 +1,52 @@
+// Command fakedyndns runs the fake DynDNS2 provider of the end-to-end tests.
+//
+// Usage:
+//
+//	fakedyndns [-listen :8080] [-domain example.test] [-password secret] [-zone-file path] [-ip address]
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/justenwalker/ddns/e2e/fakedyndns"
+)
+
+type logger struct{}
+
+func (logger) Log(format string, v ...interface{}) {
+	log.Printf(format, v...)
+}
+
+func main() {
+	listen := flag.String("listen", ":8080", "address to serve HTTP on")
+	domain := flag.String("domain", "example.test", "domain whose hostnames may be updated")
+	password := flag.String("password", "secret", "password updates must present")
+	zoneFile := flag.String("zone-file", "", "zone file to write the records to, for an authoritative DNS server")
+	ttl := flag.Duration("ttl", 5*time.Second, "TTL of the records in the zone file")
+	ip := flag.String("ip", "", "initial address returned by GET /ip")
+	flag.Parse()
+
+	opts := []fakedyndns.Option{
+		fakedyndns.Log(logger{}),
+		fakedyndns.ZoneFile(*zoneFile),
+		fakedyndns.TTL(*ttl),
+	}
+	if *ip != "" {
+		addr := net.ParseIP(*ip)
+		if addr == nil {
+			log.Fatalf("fakedyndns: invalid -ip %q", *ip)
+		}
+		opts = append(opts, fakedyndns.PublicIP(addr))
+	}
+	s := fakedyndns.New(*domain, *password, opts...)
+	if err := s.WriteZone(); err != nil {
+		log.Fatalf("fakedyndns: %v", err)
+	}
+	log.Printf("fakedyndns: serving %s on %s", *domain, *listen)
+	srv := &http.Server{Addr: *listen, Handler: s, ReadHeaderTimeout: 10 * time.Second}
+	log.Fatal(srv.ListenAndServe())
+}
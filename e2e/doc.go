@@ -0,0 +1,12 @@
+// Package e2e runs end-to-end scenarios against a simulated provider.
+//
+// docker-compose.yml starts a fake DynDNS2 provider (see package fakedyndns), CoreDNS serving the zone the
+// provider writes, and the daemon configured through its environment. The tests drive the provider's
+// public address and failures over HTTP and check that the records propagate through CoreDNS.
+// They need Docker with the compose plugin and are excluded from go test ./... by the e2e build tag:
+//
+//	go test -tags e2e ./e2e/
+//
+// Set DDNS_E2E_KEEP=1 to leave the environment running after the tests, e.g. to inspect it with
+// docker compose logs, and DDNS_E2E_NOUP=1 to reuse an environment that is already running.
+package e2e // import "github.com/justenwalker/ddns/e2e"
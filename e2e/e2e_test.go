@@ -0,0 +1,159 @@
+//go:build e2e
+
+package e2e_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/justenwalker/ddns/e2e/fakedyndns"
+	"github.com/justenwalker/ddns/verify"
+)
+
+// addresses published by docker-compose.yml
+const (
+	providerURL = "http://127.0.0.1:18080"
+	dnsServer   = "127.0.0.1:10053"
+)
+
+// hostnames the daemon updates
+var hostnames = []string{"example.test", "www.example.test"}
+
+func TestMain(m *testing.M) {
+	if os.Getenv("DDNS_E2E_NOUP") == "" {
+		if err := compose("up", "-d", "--build", "--wait"); err != nil {
+			fmt.Fprintf(os.Stderr, "e2e: starting the environment: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	code := m.Run()
+	if code != 0 {
+		compose("logs", "--no-color")
+	}
+	if os.Getenv("DDNS_E2E_KEEP") == "" && os.Getenv("DDNS_E2E_NOUP") == "" {
+		compose("down", "-v")
+	}
+	os.Exit(code)
+}
+
+func compose(args ...string) error {
+	cmd := exec.Command("docker", append([]string{"compose", "-f", "docker-compose.yml"}, args...)...)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// resolver queries CoreDNS directly
+func resolver() *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, dnsServer)
+		},
+	}
+}
+
+func waitConverged(t *testing.T, ip string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := verify.WaitConverged(ctx, resolver(), hostnames, []net.IP{net.ParseIP(ip)}, 500*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func post(t *testing.T, method, path, body string) {
+	t.Helper()
+	req, err := http.NewRequest(method, providerURL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("%s %s: %s", method, path, resp.Status)
+	}
+}
+
+type records struct {
+	Records  []fakedyndns.Record
+	Requests int
+}
+
+func getRecords(t *testing.T) records {
+	t.Helper()
+	resp, err := http.Get(providerURL + "/records")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var r records
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// The tests share the environment and run in order, each starting from the address the previous one published.
+
+func TestInitialPublish(t *testing.T) {
+	waitConverged(t, "203.0.113.10")
+}
+
+func TestAddressChange(t *testing.T) {
+	post(t, http.MethodPut, "/ip", "203.0.113.20")
+	waitConverged(t, "203.0.113.20")
+}
+
+func TestProviderFailure(t *testing.T) {
+	before := getRecords(t).Requests
+	post(t, http.MethodPost, "/fail?code=911&count=2", "")
+	post(t, http.MethodPut, "/ip", "203.0.113.30")
+	waitConverged(t, "203.0.113.30")
+	if n := getRecords(t).Requests - before; n < 3 {
+		t.Errorf("expected the daemon to retry after 2 failures, got %d requests", n)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	var out bytes.Buffer
+	cmd := exec.Command("docker", "compose", "-f", "docker-compose.yml", "exec", "-T", "ddns", "ddns", "status", "-json")
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	var st struct {
+		Detected  []net.IP
+		Providers []struct {
+			Name      string
+			IPs       []net.IP
+			LastError string `json:"last_error"`
+		}
+	}
+	if err := json.Unmarshal(out.Bytes(), &st); err != nil {
+		t.Fatalf("%v: %s", err, out.String())
+	}
+	if len(st.Detected) != 1 || st.Detected[0].String() != "203.0.113.30" {
+		t.Errorf("unexpected detected address %v", st.Detected)
+	}
+	if len(st.Providers) != 1 || len(st.Providers[0].IPs) != 1 || st.Providers[0].IPs[0].String() != "203.0.113.30" {
+		t.Fatalf("unexpected providers %s", out.String())
+	}
+	if st.Providers[0].LastError == "" {
+		t.Errorf("expected the failure of TestProviderFailure to be reported, got %s", out.String())
+	}
+}
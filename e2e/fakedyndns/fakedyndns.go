@@ -0,0 +1,380 @@
+// Package fakedyndns is a DynDNS2 server for end-to-end tests.
+//
+// It accepts updates in the dialect of the dynu client, keeps the records in memory and writes them to a
+// zone file that an authoritative DNS server such as CoreDNS can serve, so that tests can check propagation
+// as well as the updates themselves. It also plays the part of the address source: GET /ip returns the
+// "public" address that tests change with PUT /ip.
+//
+// Routes:
+//
+//	GET  /nic/update   DynDNS2 update with hostname, myip, myipv6 and password parameters
+//	GET  /ip           the current public address, for ddns -source
+//	PUT  /ip           set the public address from the request body
+//	GET  /records      the records and the number of update requests, as JSON
+//	POST /fail         answer the next count updates (default 1) with code (default 911)
+package fakedyndns // import "github.com/justenwalker/ddns/e2e/fakedyndns"
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/justenwalker/ddns/internal/atomicfile"
+)
+
+// Logger is a logging interface
+type Logger interface {
+	Log(format string, v ...interface{})
+}
+
+// Option sets server options
+type Option func(*Server)
+
+// Log enables logging using the given Logger
+func Log(l Logger) Option {
+	return func(s *Server) {
+		s.logger = l
+	}
+}
+
+// ZoneFile writes the zone to path, in RFC 1035 master file format, every time a record changes
+func ZoneFile(path string) Option {
+	return func(s *Server) {
+		s.zoneFile = path
+	}
+}
+
+// TTL sets the TTL of the records in the zone file; the default is 5 seconds
+func TTL(ttl time.Duration) Option {
+	return func(s *Server) {
+		s.ttl = ttl
+	}
+}
+
+// PublicIP sets the initial address returned by GET /ip
+func PublicIP(ip net.IP) Option {
+	return func(s *Server) {
+		s.ip = ip
+	}
+}
+
+// Record is the state of a hostname
+type Record struct {
+	Hostname string `json:"hostname"`
+	IPv4     net.IP `json:"ipv4,omitempty"`
+	IPv6     net.IP `json:"ipv6,omitempty"`
+	// Updates is the number of updates that changed the record
+	Updates int `json:"updates"`
+}
+
+// Server is a fake DynDNS2 provider for the hostnames of a single domain
+type Server struct {
+	domain   string
+	password string
+	logger   Logger
+	zoneFile string
+	ttl      time.Duration
+	mux      *http.ServeMux
+
+	mu       sync.Mutex
+	ip       net.IP
+	records  map[string]*Record
+	requests int
+	serial   uint32
+	fail     []string
+}
+
+// New returns a server for the hostnames of domain that accepts password, in plain text or as an MD5 or SHA-256 hex digest
+func New(domain, password string, options ...Option) *Server {
+	s := &Server{
+		domain:   strings.ToLower(strings.TrimSuffix(domain, ".")),
+		password: password,
+		ttl:      5 * time.Second,
+		records:  make(map[string]*Record),
+		serial:   uint32(time.Now().Unix()),
+	}
+	for _, opt := range options {
+		opt(s)
+	}
+	s.mux = http.NewServeMux()
+	s.mux.HandleFunc("/nic/update", s.handleUpdate)
+	s.mux.HandleFunc("/ip", s.handleIP)
+	s.mux.HandleFunc("/records", s.handleRecords)
+	s.mux.HandleFunc("/fail", s.handleFail)
+	return s
+}
+
+func (s *Server) logf(format string, v ...interface{}) {
+	if s.logger != nil {
+		s.logger.Log(format, v...)
+	}
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// SetIP sets the address returned by GET /ip
+func (s *Server) SetIP(ip net.IP) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ip = ip
+}
+
+// FailNext answers the next count updates with code instead of applying them
+func (s *Server) FailNext(code string, count int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < count; i++ {
+		s.fail = append(s.fail, code)
+	}
+}
+
+// Records returns the records, sorted by hostname
+func (s *Server) Records() []Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records := make([]Record, 0, len(s.records))
+	for _, r := range s.records {
+		records = append(records, *r)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Hostname < records[j].Hostname })
+	return records
+}
+
+// Requests returns the number of update requests received, including failed ones
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// LookupIP returns the addresses of host as the zone would serve them, so tests without a DNS server
+// can use the Server as a verify.Resolver. network is "ip", "ip4" or "ip6".
+func (s *Server) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.records[strings.ToLower(strings.TrimSuffix(host, "."))]
+	var ips []net.IP
+	if ok && r.IPv4 != nil && network != "ip6" {
+		ips = append(ips, r.IPv4)
+	}
+	if ok && r.IPv6 != nil && network != "ip4" {
+		ips = append(ips, r.IPv6)
+	}
+	if len(ips) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return ips, nil
+}
+
+func (s *Server) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	if len(s.fail) > 0 {
+		code := s.fail[0]
+		s.fail = s.fail[1:]
+		s.logf("fakedyndns: failing update with %s", code)
+		fmt.Fprintln(w, code)
+		return
+	}
+	if !s.authorized(q.Get("password")) {
+		fmt.Fprintln(w, "badauth")
+		return
+	}
+	hostnames := strings.Split(q.Get("hostname"), ",")
+	if q.Get("hostname") == "" {
+		// updating by username and location is not supported
+		fmt.Fprintln(w, "nohost")
+		return
+	}
+	ipv4, ipv6, ok := parseAddresses(q.Get("myip"), q.Get("myipv6"))
+	if !ok {
+		fmt.Fprintln(w, "911")
+		return
+	}
+	changed := false
+	for _, host := range hostnames {
+		host = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(host), "."))
+		if !s.inDomain(host) {
+			fmt.Fprintln(w, "nohost")
+			continue
+		}
+		rec, ok := s.records[host]
+		if !ok {
+			rec = &Record{Hostname: host}
+			s.records[host] = rec
+		}
+		code := "nochg"
+		if ipv4 != nil && !ipv4.Equal(rec.IPv4) {
+			rec.IPv4, code = ipv4, "good"
+		}
+		if ipv6 != nil && !ipv6.Equal(rec.IPv6) {
+			rec.IPv6, code = ipv6, "good"
+		}
+		if code == "good" {
+			rec.Updates++
+			changed = true
+			s.logf("fakedyndns: %s is now %v %v", host, rec.IPv4, rec.IPv6)
+		}
+		fmt.Fprintln(w, strings.TrimSpace(code+" "+joinIPs(rec.IPv4, rec.IPv6)))
+	}
+	if changed {
+		if err := s.writeZone(); err != nil {
+			s.logf("fakedyndns: writing zone: %v", err)
+		}
+	}
+}
+
+func (s *Server) handleIP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		s.mu.Lock()
+		ip := s.ip
+		s.mu.Unlock()
+		if ip == nil {
+			http.Error(w, "no public address set", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, ip)
+	case http.MethodPut, http.MethodPost:
+		body, err := io.ReadAll(io.LimitReader(r.Body, 64))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ip := net.ParseIP(strings.TrimSpace(string(body)))
+		if ip == nil {
+			http.Error(w, "invalid address", http.StatusBadRequest)
+			return
+		}
+		s.SetIP(ip)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleRecords(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Records  []Record `json:"records"`
+		Requests int      `json:"requests"`
+	}{s.Records(), s.Requests()})
+}
+
+func (s *Server) handleFail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		code = "911"
+	}
+	count := 1
+	if c := r.URL.Query().Get("count"); c != "" {
+		n, err := strconv.Atoi(c)
+		if err != nil || n < 1 {
+			http.Error(w, "invalid count", http.StatusBadRequest)
+			return
+		}
+		count = n
+	}
+	s.FailNext(code, count)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authorized accepts the password in plain text or as an MD5 or SHA-256 hex digest, like dynu
+func (s *Server) authorized(password string) bool {
+	md5sum := md5.Sum([]byte(s.password))
+	sha := sha256.Sum256([]byte(s.password))
+	switch strings.ToLower(password) {
+	case s.password, hex.EncodeToString(md5sum[:]), hex.EncodeToString(sha[:]):
+		return true
+	}
+	return false
+}
+
+func (s *Server) inDomain(host string) bool {
+	return host == s.domain || strings.HasSuffix(host, "."+s.domain)
+}
+
+// parseAddresses parses the myip and myipv6 parameters, where "no" or an empty value leaves the family unchanged
+func parseAddresses(myip, myipv6 string) (ipv4, ipv6 net.IP, ok bool) {
+	if myip != "" && myip != "no" {
+		if ipv4 = net.ParseIP(myip).To4(); ipv4 == nil {
+			return nil, nil, false
+		}
+	}
+	if myipv6 != "" && myipv6 != "no" {
+		if ipv6 = net.ParseIP(myipv6); ipv6 == nil || ipv6.To4() != nil {
+			return nil, nil, false
+		}
+	}
+	return ipv4, ipv6, true
+}
+
+func joinIPs(ips ...net.IP) string {
+	var ss []string
+	for _, ip := range ips {
+		if ip != nil {
+			ss = append(ss, ip.String())
+		}
+	}
+	return strings.Join(ss, " ")
+}
+
+// writeZone writes the zone file with a new serial, so that the DNS server reloads it. s.mu must be held.
+func (s *Server) writeZone() error {
+	if s.zoneFile == "" {
+		return nil
+	}
+	s.serial++
+	ttl := int(s.ttl / time.Second)
+	var b strings.Builder
+	fmt.Fprintf(&b, "$ORIGIN %s.\n$TTL %d\n", s.domain, ttl)
+	fmt.Fprintf(&b, "@ IN SOA ns.%s. hostmaster.%s. %d 60 60 3600 %d\n", s.domain, s.domain, s.serial, ttl)
+	fmt.Fprintf(&b, "@ IN NS ns.%s.\n", s.domain)
+	fmt.Fprintf(&b, "ns IN A 127.0.0.1\n")
+	hosts := make([]string, 0, len(s.records))
+	for host := range s.records {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		rec := s.records[host]
+		name := "@"
+		if host != s.domain {
+			name = strings.TrimSuffix(host, "."+s.domain)
+		}
+		if rec.IPv4 != nil {
+			fmt.Fprintf(&b, "%s IN A %s\n", name, rec.IPv4)
+		}
+		if rec.IPv6 != nil {
+			fmt.Fprintf(&b, "%s IN AAAA %s\n", name, rec.IPv6)
+		}
+	}
+	return atomicfile.Write(s.zoneFile, []byte(b.String()), 0644)
+}
+
+// WriteZone writes the zone file, e.g. at startup so that the DNS server has a zone to serve before the first update
+func (s *Server) WriteZone() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writeZone()
+}
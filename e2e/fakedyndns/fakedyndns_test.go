@@ -0,0 +1,61 @@
+package fakedyndns_test
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/justenwalker/ddns/dynu"
+	"github.com/justenwalker/ddns/e2e/fakedyndns"
+	"github.com/justenwalker/ddns/verify"
+)
+
+func TestServer(t *testing.T) {
+	zone := filepath.Join(t.TempDir(), "db.example.test")
+	s := fakedyndns.New("example.test", "secret", fakedyndns.ZoneFile(zone))
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	hostnames := []string{"example.test", "www.example.test"}
+	client := dynu.New("e2e", "secret", dynu.Endpoint(srv.URL), dynu.Hostnames(hostnames), dynu.IPv6(true), dynu.Strict(true))
+	ips := []net.IP{net.ParseIP("203.0.113.1"), net.ParseIP("2001:db8::1")}
+
+	if changed, err := client.UpdateIPChanged(ips); err != nil || !changed {
+		t.Fatalf("expected the first update to change the records, got %v, %v", changed, err)
+	}
+	if changed, err := client.UpdateIPChanged(ips); err != nil || changed {
+		t.Fatalf("expected nochg for the same addresses, got %v, %v", changed, err)
+	}
+	if err := verify.Converged(context.Background(), s, hostnames, ips); err != nil {
+		t.Error(err)
+	}
+	data, err := os.ReadFile(zone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"@ IN A 203.0.113.1", "www IN AAAA 2001:db8::1", "$ORIGIN example.test."} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected %q in zone file:\n%s", want, data)
+		}
+	}
+
+	s.FailNext("911", 1)
+	if err := client.UpdateIP(ips); err == nil {
+		t.Error("expected the injected failure")
+	}
+	if err := dynu.New("e2e", "wrong", dynu.Endpoint(srv.URL), dynu.Hostnames(hostnames)).UpdateIP(ips); err == nil {
+		t.Error("expected badauth for the wrong password")
+	}
+	if err := dynu.New("e2e", "secret", dynu.Endpoint(srv.URL), dynu.Hostnames([]string{"example.com"})).UpdateIP(ips); err == nil {
+		t.Error("expected nohost outside the domain")
+	}
+	if n := s.Requests(); n != 5 {
+		t.Errorf("expected 5 requests, got %d", n)
+	}
+	if r := s.Records(); len(r) != 2 || r[0].Updates != 1 {
+		t.Errorf("unexpected records %+v", r)
+	}
+}
@@ -0,0 +1,140 @@
+package event
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Logger for printing debug logs from this package
+type Logger interface {
+	Log(format string, v ...interface{})
+}
+
+// Option sets bus options
+type Option func(*Bus)
+
+// Log enables bus logging using the given Logger. Sink errors and dropped events are logged.
+func Log(l Logger) Option {
+	return func(b *Bus) {
+		b.logger = l
+	}
+}
+
+// QueueSize sets the number of events queued per sink before new events are dropped; the default is 64
+func QueueSize(n int) Option {
+	return func(b *Bus) {
+		b.queueSize = n
+	}
+}
+
+// Timeout bounds how long a sink may take to handle a single event; the default is 30 seconds
+func Timeout(d time.Duration) Option {
+	return func(b *Bus) {
+		b.timeout = d
+	}
+}
+
+// Bus delivers published events to every attached sink.
+// Each sink has its own queue and goroutine, so a slow sink neither blocks the publisher nor other sinks,
+// and each sink receives events in the order they were published.
+type Bus struct {
+	logger    Logger
+	queueSize int
+	timeout   time.Duration
+
+	mu     sync.RWMutex
+	queues []*queue
+	closed bool
+	wg     sync.WaitGroup
+}
+
+type queue struct {
+	sink Sink
+	ch   chan Event
+}
+
+// NewBus constructs an event bus
+func NewBus(options ...Option) *Bus {
+	b := &Bus{
+		queueSize: 64,
+		timeout:   30 * time.Second,
+	}
+	for _, opt := range options {
+		opt(b)
+	}
+	return b
+}
+
+func (b *Bus) logf(format string, v ...interface{}) {
+	if b.logger != nil {
+		b.logger.Log(format, v...)
+	}
+}
+
+// Attach adds a sink to the bus. It receives events published after it was attached.
+func (b *Bus) Attach(s Sink) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	q := &queue{sink: s, ch: make(chan Event, b.queueSize)}
+	b.queues = append(b.queues, q)
+	b.wg.Add(1)
+	go b.run(q)
+}
+
+func (b *Bus) run(q *queue) {
+	defer b.wg.Done()
+	for ev := range q.ch {
+		ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
+		if err := q.sink.Handle(ctx, ev); err != nil {
+			b.logf("event: sink failed to handle %v event: %v", ev.Type, err)
+		}
+		cancel()
+	}
+}
+
+// Publish queues the event for every sink without blocking.
+// Events are dropped for sinks whose queue is full.
+func (b *Bus) Publish(ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return
+	}
+	for _, q := range b.queues {
+		select {
+		case q.ch <- ev:
+		default:
+			b.logf("event: sink queue full, dropping %v event", ev.Type)
+		}
+	}
+}
+
+// Close stops accepting events and waits until the queued events have been handled or ctx is done
+func (b *Bus) Close(ctx context.Context) error {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		for _, q := range b.queues {
+			close(q.ch)
+		}
+	}
+	b.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
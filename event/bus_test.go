@@ -0,0 +1,42 @@
+package event_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/justenwalker/ddns/event"
+)
+
+func TestBus(t *testing.T) {
+	var mu sync.Mutex
+	var all, failures []event.Type
+	bus := event.NewBus()
+	bus.Attach(event.SinkFunc(func(ctx context.Context, ev event.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		all = append(all, ev.Type)
+		return nil
+	}))
+	bus.Attach(event.Filter(event.SinkFunc(func(ctx context.Context, ev event.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		failures = append(failures, ev.Type)
+		return nil
+	}), event.Failed))
+
+	for _, typ := range []event.Type{event.Detected, event.Changed, event.Failed, event.Recovered} {
+		bus.Publish(event.Event{Type: typ})
+	}
+	if err := bus.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	bus.Publish(event.Event{Type: event.Updated})
+
+	if len(all) != 4 || all[0] != event.Detected || all[3] != event.Recovered {
+		t.Errorf("unexpected events: %v", all)
+	}
+	if len(failures) != 1 || failures[0] != event.Failed {
+		t.Errorf("unexpected filtered events: %v", failures)
+	}
+}
@@ -0,0 +1,185 @@
+// Package event distributes update pipeline events to pluggable sinks.
+// Integrations such as notifiers subscribe to the bus instead of being called from the update pipeline directly.
+package event // import "github.com/justenwalker/ddns/event"
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// Type of event
+type Type int
+
+const (
+	// Detected is published every time addresses are detected, whether or not they changed
+	Detected Type = iota + 1
+	// Changed is published when the detected addresses differ from the last published ones
+	Changed
+	// Updated is published when a provider successfully updates its records
+	Updated
+	// Failed is published when a provider update fails
+	Failed
+	// Recovered is published when a provider update succeeds after failing
+	Recovered
+	// Verified is published after checking that a published address is reachable.
+	// Err is set when the check failed.
+	Verified
+	// RolledBack is published when an unreachable address was replaced by the previous one.
+	// OldIPs holds the rejected addresses, NewIPs the restored ones and Err the verification failure.
+	RolledBack
+	// Promoted is published when a backup provider is promoted because its primary keeps failing.
+	// Provider and Hostnames are those of the backup and Err is the primary's last error.
+	Promoted
+	// Stopped is published for each provider when the daemon stops, as its final status: NewIPs holds the
+	// addresses it last published and Err its last error, if it was failing
+	Stopped
+	// BackedOff is published when a provider update, or the detection if Provider is empty, fails and is retried
+	// later: Attempt is the number of consecutive failures and RetryAt when the next attempt is due
+	BackedOff
+)
+
+func (t Type) String() string {
+	switch t {
+	case Detected:
+		return "detected"
+	case Changed:
+		return "changed"
+	case Updated:
+		return "updated"
+	case Failed:
+		return "failed"
+	case Recovered:
+		return "recovered"
+	case Verified:
+		return "verified"
+	case RolledBack:
+		return "rolledback"
+	case Promoted:
+		return "promoted"
+	case Stopped:
+		return "stopped"
+	case BackedOff:
+		return "backedoff"
+	}
+	return "unknown"
+}
+
+// Event describes something that happened in the update pipeline
+type Event struct {
+	Type      Type
+	Time      time.Time
+	Provider  string
+	Hostnames []string
+	OldIPs    []net.IP
+	NewIPs    []net.IP
+	Err       error
+	// Attempt and RetryAt are set on BackedOff events
+	Attempt int
+	RetryAt time.Time
+	// Duration is how long the detection took on Detected events, and the update on Changed, Updated, Failed and
+	// Recovered ones
+	Duration time.Duration
+}
+
+// Sink receives events from a Bus
+type Sink interface {
+	Handle(ctx context.Context, ev Event) error
+}
+
+// SinkFunc adapts a function to the Sink interface
+type SinkFunc func(ctx context.Context, ev Event) error
+
+// Handle calls f(ctx, ev)
+func (f SinkFunc) Handle(ctx context.Context, ev Event) error {
+	return f(ctx, ev)
+}
+
+// Filter returns a sink that only receives events of the given types
+func Filter(s Sink, types ...Type) Sink {
+	return SinkFunc(func(ctx context.Context, ev Event) error {
+		for _, t := range types {
+			if ev.Type == t {
+				return s.Handle(ctx, ev)
+			}
+		}
+		return nil
+	})
+}
+
+// HasHostname returns true if the event concerns hostname. Every event matches an empty hostname.
+func (ev Event) HasHostname(hostname string) bool {
+	if hostname == "" {
+		return true
+	}
+	for _, h := range ev.Hostnames {
+		if h == hostname {
+			return true
+		}
+	}
+	return false
+}
+
+// Replacement is an address change within one address family.
+// Old is nil if there was no previous address of that family.
+type Replacement struct {
+	Old net.IP
+	New net.IP
+}
+
+// Replacements pairs old and new addresses by family, IPv4 first.
+// Families without a new address, or whose address did not change, are omitted.
+func Replacements(oldIPs, newIPs []net.IP) []Replacement {
+	var rs []Replacement
+	for _, v6 := range []bool{false, true} {
+		newIP := pickFamily(newIPs, v6)
+		if newIP == nil {
+			continue
+		}
+		oldIP := pickFamily(oldIPs, v6)
+		if oldIP.Equal(newIP) {
+			continue
+		}
+		rs = append(rs, Replacement{Old: oldIP, New: newIP})
+	}
+	return rs
+}
+
+// Replacer replaces an address in an external system, such as a firewall, a CDN or a service registry.
+// old is nil when no previous address is known. old and new are always of the same family;
+// replacers ignore families they do not manage.
+type Replacer interface {
+	Replace(ctx context.Context, old, new net.IP) error
+}
+
+// ReplaceSink returns a sink that applies Changed events for hostname to the replacer, one address family at
+// a time. Events for every hostname are applied if hostname is empty.
+func ReplaceSink(r Replacer, hostname string) Sink {
+	return SinkFunc(func(ctx context.Context, ev Event) error {
+		if ev.Type != Changed || !ev.HasHostname(hostname) {
+			return nil
+		}
+		for _, rep := range Replacements(ev.OldIPs, ev.NewIPs) {
+			if err := r.Replace(ctx, rep.Old, rep.New); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// PickIP returns the first address of the requested family, or nil
+func PickIP(ips []net.IP, v6 bool) net.IP {
+	return pickFamily(ips, v6)
+}
+
+func pickFamily(ips []net.IP, v6 bool) net.IP {
+	for _, ip := range ips {
+		if ipv4 := ip.To4(); ipv4 != nil && !v6 {
+			return ipv4
+		} else if ipv4 == nil && v6 {
+			return ip
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package event
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+)
+
+// MarshalText encodes the event type as its name
+func (t Type) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText decodes an event type name
+func (t *Type) UnmarshalText(text []byte) error {
+	for _, typ := range []Type{Detected, Changed, Updated, Failed, Recovered, Verified, RolledBack, Promoted, Stopped, BackedOff} {
+		if typ.String() == string(text) {
+			*t = typ
+			return nil
+		}
+	}
+	return fmt.Errorf("event: unknown event type %q", text)
+}
+
+type jsonEvent struct {
+	Type      Type     `json:"type"`
+	Time      string   `json:"time"`
+	Provider  string   `json:"provider,omitempty"`
+	Hostnames []string `json:"hostnames,omitempty"`
+	OldIPs    []string `json:"old_ips,omitempty"`
+	NewIPs    []string `json:"new_ips,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	Attempt   int      `json:"attempt,omitempty"`
+	RetryAt   string   `json:"retry_at,omitempty"`
+	Duration  float64  `json:"duration,omitempty"`
+}
+
+// MarshalJSON encodes the event as a JSON object with a stable schema:
+//
+//	{"type":"changed","time":"2006-01-02T15:04:05Z","provider":"dynu","hostnames":["..."],
+//	 "old_ips":["..."],"new_ips":["..."],"error":"...","attempt":1,"retry_at":"2006-01-02T15:05:05Z",
+//	 "duration":0.25}
+//
+// Empty fields are omitted, the times are formatted as RFC 3339 in UTC and the duration is in seconds.
+func (ev Event) MarshalJSON() ([]byte, error) {
+	je := jsonEvent{
+		Type:      ev.Type,
+		Time:      ev.Time.UTC().Format(time.RFC3339Nano),
+		Provider:  ev.Provider,
+		Hostnames: ev.Hostnames,
+		OldIPs:    ipStrings(ev.OldIPs),
+		NewIPs:    ipStrings(ev.NewIPs),
+		Attempt:   ev.Attempt,
+		Duration:  ev.Duration.Seconds(),
+	}
+	if ev.Err != nil {
+		je.Error = ev.Err.Error()
+	}
+	if !ev.RetryAt.IsZero() {
+		je.RetryAt = ev.RetryAt.UTC().Format(time.RFC3339Nano)
+	}
+	return json.Marshal(je)
+}
+
+func ipStrings(ips []net.IP) []string {
+	if len(ips) == 0 {
+		return nil
+	}
+	out := make([]string, len(ips))
+	for i, ip := range ips {
+		out[i] = ip.String()
+	}
+	return out
+}
@@ -0,0 +1,99 @@
+// Package jsonlsink appends update events to a JSON Lines file, for log shippers such as Promtail or Filebeat.
+//
+// Each line is one event, encoded as a JSON object with these fields:
+//
+//	type       string    detected, changed, updated, failed, backedoff, recovered, verified, rolledback, promoted
+//	                     or stopped
+//	time       string    when the event happened, RFC 3339 in UTC with up to nanosecond precision
+//	provider   string    name of the provider, omitted for detected and changed
+//	hostnames  []string  hostnames of the provider
+//	old_ips    []string  addresses before the event
+//	new_ips    []string  addresses after the event
+//	error      string    why an update or verification failed
+//	attempt    int       consecutive failures of a backedoff provider, or of the detection if provider is omitted
+//	retry_at   string    when a backedoff provider or detection is retried, RFC 3339 in UTC
+//
+// Empty fields are omitted. Fields may be added in later releases, but existing ones keep their name and meaning.
+//
+// The file is rotated once it would grow beyond MaxSize, and when an event falls in a different MaxAge period
+// than the last write, such as another UTC day with a MaxAge of 24 hours. Rotated files are renamed to
+// "<path>.<UTC time>", such as events.jsonl.20260102T030405.000Z, and only the newest Keep are kept.
+package jsonlsink // import "github.com/justenwalker/ddns/event/jsonlsink"
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/justenwalker/ddns/event"
+	"github.com/justenwalker/ddns/internal/rotate"
+)
+
+// Option sets sink options
+type Option func(*Sink)
+
+// MaxSize rotates the file before it grows beyond n bytes; the default is 10 MiB and 0 disables it
+func MaxSize(n int64) Option {
+	return func(s *Sink) {
+		s.options = append(s.options, rotate.MaxSize(n))
+	}
+}
+
+// MaxAge rotates the file when an event falls in another period of d than the last write; 0, the default,
+// disables it
+func MaxAge(d time.Duration) Option {
+	return func(s *Sink) {
+		s.options = append(s.options, rotate.MaxAge(d))
+	}
+}
+
+// Keep sets how many rotated files are kept; the default is 5 and 0 keeps all of them
+func Keep(n int) Option {
+	return func(s *Sink) {
+		s.options = append(s.options, rotate.Keep(n))
+	}
+}
+
+// Sink appends each event to a file as a line of JSON
+type Sink struct {
+	options []rotate.Option
+	f       *rotate.File
+}
+
+// Open opens or creates the file at path, appending to it
+func Open(path string, options ...Option) (*Sink, error) {
+	s := &Sink{}
+	for _, opt := range options {
+		opt(s)
+	}
+	f, err := rotate.Open(path, s.options...)
+	if err != nil {
+		return nil, err
+	}
+	s.f = f
+	return s, nil
+}
+
+// Handle appends the event to the file, rotating it first if needed
+func (s *Sink) Handle(ctx context.Context, ev event.Event) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	at := ev.Time
+	if at.IsZero() {
+		at = time.Now()
+	}
+	_, err = s.f.WriteTime(append(data, '\n'), at)
+	return err
+}
+
+// Rotated returns the paths of the rotated files, oldest first
+func (s *Sink) Rotated() ([]string, error) {
+	return s.f.Rotated()
+}
+
+// Close closes the file
+func (s *Sink) Close() error {
+	return s.f.Close()
+}
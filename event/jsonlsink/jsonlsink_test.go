@@ -0,0 +1,86 @@
+package jsonlsink_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/justenwalker/ddns/event"
+	"github.com/justenwalker/ddns/event/jsonlsink"
+)
+
+func TestSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	s, err := jsonlsink.Open(path, jsonlsink.MaxSize(400), jsonlsink.MaxAge(24*time.Hour), jsonlsink.Keep(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	day := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	ev := event.Event{
+		Type:      event.Updated,
+		Time:      day,
+		Provider:  "dynu",
+		Hostnames: []string{"home.example.com"},
+		NewIPs:    []net.IP{net.ParseIP("203.0.113.7")},
+	}
+	for i := 0; i < 3; i++ {
+		if err := s.Handle(context.Background(), ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if rotated, _ := s.Rotated(); len(rotated) != 0 {
+		t.Fatalf("expected no rotation yet, got %v", rotated)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := bufio.NewScanner(f)
+	lines := 0
+	for sc.Scan() {
+		var got map[string]interface{}
+		if err := json.Unmarshal(sc.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got["type"] != "updated" || got["time"] != "2026-01-02T03:04:05Z" || got["provider"] != "dynu" {
+			t.Errorf("unexpected line %s", sc.Text())
+		}
+		lines++
+	}
+	f.Close()
+	if lines != 3 {
+		t.Errorf("expected 3 lines, got %d", lines)
+	}
+
+	// the fourth line exceeds the size
+	if err := s.Handle(context.Background(), ev); err != nil {
+		t.Fatal(err)
+	}
+	// the next day
+	ev.Time = day.Add(24 * time.Hour)
+	if err := s.Handle(context.Background(), ev); err != nil {
+		t.Fatal(err)
+	}
+	rotated, _ := s.Rotated()
+	if len(rotated) != 2 || filepath.Base(rotated[0]) != "events.jsonl.20260102T030405.000Z" {
+		t.Fatalf("unexpected rotated files %v", rotated)
+	}
+	// the third rotation removes the oldest file
+	ev.Time = ev.Time.Add(24 * time.Hour)
+	if err := s.Handle(context.Background(), ev); err != nil {
+		t.Fatal(err)
+	}
+	rotated, _ = s.Rotated()
+	if len(rotated) != 2 || filepath.Base(rotated[0]) != "events.jsonl.20260103T030405.000Z" {
+		t.Errorf("unexpected rotated files %v", rotated)
+	}
+	if data, _ := os.ReadFile(path); len(data) == 0 {
+		t.Error("expected the last event in the current file")
+	}
+}
@@ -0,0 +1,45 @@
+// Package kafkasink publishes update events to a Kafka topic.
+// It is a module of its own, so that the ddns module does not depend on the Kafka client.
+package kafkasink // import "github.com/justenwalker/ddns/event/kafkasink"
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/justenwalker/ddns/event"
+	"github.com/segmentio/kafka-go"
+)
+
+// Writer writes messages to Kafka.
+// *kafka.Writer implicitly implements Writer and can be provided wherever this interface is requested.
+// The topic is configured on the writer.
+type Writer interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// Sink writes each event as a JSON message.
+// Messages are keyed by provider so that events of one provider stay ordered within a partition,
+// and carry the event type in the "type" header.
+type Sink struct {
+	w Writer
+}
+
+// New constructs a sink writing to w
+func New(w Writer) *Sink {
+	return &Sink{w: w}
+}
+
+// Handle writes the event
+func (s *Sink) Handle(ctx context.Context, ev event.Event) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	return s.w.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(ev.Provider),
+		Value: data,
+		Headers: []kafka.Header{
+			{Key: "type", Value: []byte(ev.Type.String())},
+		},
+	})
+}
@@ -0,0 +1,49 @@
+package kafkasink_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/justenwalker/ddns/event"
+	"github.com/justenwalker/ddns/event/kafkasink"
+)
+
+type writer []kafka.Message
+
+func (w *writer) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	*w = append(*w, msgs...)
+	return nil
+}
+
+func TestSink(t *testing.T) {
+	var w writer
+	s := kafkasink.New(&w)
+	err := s.Handle(context.Background(), event.Event{
+		Type:      event.Changed,
+		Time:      time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Provider:  "dynu",
+		Hostnames: []string{"a.example.com"},
+		NewIPs:    []net.IP{net.ParseIP("203.0.113.7")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(w) != 1 {
+		t.Fatalf("expected one message, got %d", len(w))
+	}
+	m := w[0]
+	if string(m.Key) != "dynu" {
+		t.Errorf("key = %q, want the provider", m.Key)
+	}
+	if len(m.Headers) != 1 || m.Headers[0].Key != "type" || string(m.Headers[0].Value) != "changed" {
+		t.Errorf("unexpected headers %v", m.Headers)
+	}
+	want := `{"type":"changed","time":"2020-01-02T03:04:05Z","provider":"dynu","hostnames":["a.example.com"],"new_ips":["203.0.113.7"]}`
+	if string(m.Value) != want {
+		t.Errorf("got  %s\nwant %s", m.Value, want)
+	}
+}
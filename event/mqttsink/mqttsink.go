@@ -0,0 +1,222 @@
+// Package mqttsink publishes the public addresses and the status of the providers to an MQTT broker, for home
+// automation systems such as Home Assistant, Node-RED or openHAB. Under a prefix such as "ddns", it publishes:
+//
+//	ddns/ipv4                 the detected IPv4 address, retained
+//	ddns/ipv6                 the detected IPv6 address, retained
+//	ddns/<provider>/state     the status of a provider as JSON, retained:
+//	                          {"status":"ok","ips":["203.0.113.7"],"time":"...","error":"..."}
+//	ddns/event/<type>         every event as JSON, such as ddns/event/changed
+//	ddns/status               "online" while the client is connected, and "offline" once it is not,
+//	                          when the Publisher sets it as its last will
+//
+// With Discovery, Home Assistant finds sensors for the addresses and a problem sensor for each provider.
+package mqttsink // import "github.com/justenwalker/ddns/event/mqttsink"
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/justenwalker/ddns/event"
+)
+
+// Schemes are the URL schemes of the brokers, for plain TCP, TLS and WebSockets
+var Schemes = map[string]bool{"tcp": true, "mqtt": true, "ssl": true, "tls": true, "mqtts": true, "ws": true, "wss": true}
+
+// Publisher publishes messages to MQTT topics, such as a client of the Eclipse Paho library that sets the
+// availability topic as its last will
+type Publisher interface {
+	Publish(ctx context.Context, topic string, retained bool, payload []byte) error
+}
+
+// Option sets sink options
+type Option func(*Sink)
+
+// Discovery publishes Home Assistant discovery payloads under prefix, usually "homeassistant", for a device
+// identified by node, such as the hostname, so that several daemons sharing a broker have their own sensors
+func Discovery(prefix, node string) Option {
+	return func(s *Sink) {
+		s.discovery = prefix
+		s.node = objectID(node)
+	}
+}
+
+// Sink publishes the events of a daemon
+type Sink struct {
+	pub       Publisher
+	prefix    string
+	discovery string
+	node      string
+
+	mu        sync.Mutex
+	announced map[string]bool
+}
+
+// New constructs a sink publishing to topics under prefix
+func New(pub Publisher, prefix string, options ...Option) *Sink {
+	s := &Sink{pub: pub, prefix: strings.TrimSuffix(prefix, "/"), announced: make(map[string]bool)}
+	for _, opt := range options {
+		opt(s)
+	}
+	return s
+}
+
+// Topic returns the topic named name under the prefix of the sink, such as "ddns/ipv4"
+func (s *Sink) Topic(name string) string {
+	if s.prefix == "" {
+		return name
+	}
+	return s.prefix + "/" + name
+}
+
+// AvailabilityTopic is the topic of a client publishing under prefix, which it sets to "online" once connected
+// and as its last will to "offline"
+func AvailabilityTopic(prefix string) string {
+	return New(nil, prefix).Topic("status")
+}
+
+// State is the payload of the state topic of a provider
+type State struct {
+	// Status is "ok", or "failed" while the updates fail
+	Status string    `json:"status"`
+	IPs    []string  `json:"ips,omitempty"`
+	Time   time.Time `json:"time"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// Handle publishes the event, and the addresses or the state of the provider it changes
+func (s *Sink) Handle(ctx context.Context, ev event.Event) error {
+	if err := s.announce(ctx, ev.Provider); err != nil {
+		return err
+	}
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	if err := s.pub.Publish(ctx, s.Topic("event/"+ev.Type.String()), false, data); err != nil {
+		return err
+	}
+	switch ev.Type {
+	case event.Detected:
+		for _, family := range []struct {
+			name string
+			v6   bool
+		}{{"ipv4", false}, {"ipv6", true}} {
+			if ip := event.PickIP(ev.NewIPs, family.v6); ip != nil {
+				if err := s.pub.Publish(ctx, s.Topic(family.name), true, []byte(ip.String())); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	case event.Updated:
+		return s.publishState(ctx, ev.Provider, State{Status: "ok", IPs: ipStrings(ev.NewIPs), Time: ev.Time})
+	case event.Failed:
+		st := State{Status: "failed", Time: ev.Time}
+		if ev.Err != nil {
+			st.Error = ev.Err.Error()
+		}
+		return s.publishState(ctx, ev.Provider, st)
+	}
+	return nil
+}
+
+func (s *Sink) publishState(ctx context.Context, provider string, st State) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	return s.pub.Publish(ctx, s.Topic(provider+"/state"), true, data)
+}
+
+// announce publishes the discovery payloads of the address sensors, or of the problem sensor of provider,
+// the first time they are needed
+func (s *Sink) announce(ctx context.Context, provider string) error {
+	if s.discovery == "" {
+		return nil
+	}
+	s.mu.Lock()
+	done := s.announced[provider]
+	s.announced[provider] = true
+	s.mu.Unlock()
+	if done {
+		return nil
+	}
+	device := map[string]interface{}{
+		"identifiers":  []string{"ddns_" + s.node},
+		"name":         "ddns " + s.node,
+		"manufacturer": "ddns",
+	}
+	var payloads []discoveryPayload
+	if provider == "" {
+		for _, family := range []string{"ipv4", "ipv6"} {
+			payloads = append(payloads, discoveryPayload{
+				topic: s.discovery + "/sensor/" + s.node + "/" + family + "/config",
+				config: map[string]interface{}{
+					"name":               "Public " + strings.ToUpper(family[:2]) + family[2:],
+					"unique_id":          s.node + "_" + family,
+					"state_topic":        s.Topic(family),
+					"availability_topic": s.Topic("status"),
+					"icon":               "mdi:ip-network",
+					"device":             device,
+				},
+			})
+		}
+	} else {
+		id := objectID(provider)
+		payloads = append(payloads, discoveryPayload{
+			topic: s.discovery + "/binary_sensor/" + s.node + "/" + id + "/config",
+			config: map[string]interface{}{
+				"name":                  provider + " update problem",
+				"unique_id":             s.node + "_" + id,
+				"state_topic":           s.Topic(provider + "/state"),
+				"value_template":        "{{ 'ON' if value_json.status == 'failed' else 'OFF' }}",
+				"json_attributes_topic": s.Topic(provider + "/state"),
+				"device_class":          "problem",
+				"availability_topic":    s.Topic("status"),
+				"device":                device,
+			},
+		})
+	}
+	for _, p := range payloads {
+		data, err := json.Marshal(p.config)
+		if err != nil {
+			return err
+		}
+		if err := s.pub.Publish(ctx, p.topic, true, data); err != nil {
+			// announce again with the next event
+			s.mu.Lock()
+			delete(s.announced, provider)
+			s.mu.Unlock()
+			return err
+		}
+	}
+	return nil
+}
+
+type discoveryPayload struct {
+	topic  string
+	config map[string]interface{}
+}
+
+var invalidID = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// objectID replaces the characters Home Assistant does not accept in IDs, such as the slash of "home/ipv4"
+func objectID(s string) string {
+	return invalidID.ReplaceAllString(s, "_")
+}
+
+func ipStrings(ips []net.IP) []string {
+	if len(ips) == 0 {
+		return nil
+	}
+	ss := make([]string, len(ips))
+	for i, ip := range ips {
+		ss[i] = ip.String()
+	}
+	return ss
+}
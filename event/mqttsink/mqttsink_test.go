@@ -0,0 +1,82 @@
+package mqttsink_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/justenwalker/ddns/event"
+	"github.com/justenwalker/ddns/event/mqttsink"
+)
+
+type message struct {
+	payload  string
+	retained bool
+}
+
+type publisher map[string]message
+
+func (p publisher) Publish(ctx context.Context, topic string, retained bool, payload []byte) error {
+	p[topic] = message{string(payload), retained}
+	return nil
+}
+
+func TestSink(t *testing.T) {
+	pub := publisher{}
+	s := mqttsink.New(pub, "ddns/")
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	events := []event.Event{
+		{Type: event.Detected, Time: now, NewIPs: []net.IP{net.ParseIP("203.0.113.7"), net.ParseIP("2001:db8::1")}},
+		{Type: event.Updated, Time: now, Provider: "dynu", NewIPs: []net.IP{net.ParseIP("203.0.113.7")}},
+		{Type: event.Failed, Time: now, Provider: "cloudflare", Err: errors.New("unauthorized")},
+	}
+	for _, ev := range events {
+		if err := s.Handle(context.Background(), ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for topic, want := range map[string]message{
+		"ddns/ipv4":             {"203.0.113.7", true},
+		"ddns/ipv6":             {"2001:db8::1", true},
+		"ddns/dynu/state":       {`{"status":"ok","ips":["203.0.113.7"],"time":"2020-01-02T03:04:05Z"}`, true},
+		"ddns/cloudflare/state": {`{"status":"failed","time":"2020-01-02T03:04:05Z","error":"unauthorized"}`, true},
+		"ddns/event/updated":    {`{"type":"updated","time":"2020-01-02T03:04:05Z","provider":"dynu","new_ips":["203.0.113.7"]}`, false},
+	} {
+		if got := pub[topic]; got != want {
+			t.Errorf("%s: got %+v, want %+v", topic, got, want)
+		}
+	}
+	if _, ok := pub["homeassistant/sensor/host/ipv4/config"]; ok {
+		t.Error("discovery payloads published without Discovery")
+	}
+}
+
+func TestDiscovery(t *testing.T) {
+	pub := publisher{}
+	s := mqttsink.New(pub, "ddns", mqttsink.Discovery("homeassistant", "my.host"))
+	for _, ev := range []event.Event{
+		{Type: event.Detected, NewIPs: []net.IP{net.ParseIP("203.0.113.7")}},
+		{Type: event.Failed, Provider: "home/ipv4"},
+	} {
+		if err := s.Handle(context.Background(), ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, topic := range []string{
+		"homeassistant/sensor/my_host/ipv4/config",
+		"homeassistant/sensor/my_host/ipv6/config",
+		"homeassistant/binary_sensor/my_host/home_ipv4/config",
+	} {
+		if m, ok := pub[topic]; !ok || !m.retained {
+			t.Errorf("%s: got %+v, want a retained payload", topic, m)
+		}
+	}
+}
+
+func TestAvailabilityTopic(t *testing.T) {
+	if got := mqttsink.AvailabilityTopic("home/ddns"); got != "home/ddns/status" {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,44 @@
+// Package natssink publishes update events to NATS subjects
+package natssink // import "github.com/justenwalker/ddns/event/natssink"
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/justenwalker/ddns/event"
+)
+
+// Publisher publishes messages to a subject.
+// *nats.Conn implicitly implements Publisher and can be provided wherever this interface is requested.
+type Publisher interface {
+	Publish(subject string, data []byte) error
+}
+
+// Sink publishes each event as JSON to the subject "<prefix>.<type>", e.g. "ddns.changed",
+// so subscribers can select events with "ddns.changed" or all of them with "ddns.>".
+type Sink struct {
+	pub    Publisher
+	prefix string
+}
+
+// New constructs a sink publishing to subjects under prefix
+func New(pub Publisher, prefix string) *Sink {
+	return &Sink{pub: pub, prefix: prefix}
+}
+
+// Subject returns the subject an event is published to
+func (s *Sink) Subject(ev event.Event) string {
+	if s.prefix == "" {
+		return ev.Type.String()
+	}
+	return s.prefix + "." + ev.Type.String()
+}
+
+// Handle publishes the event
+func (s *Sink) Handle(ctx context.Context, ev event.Event) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	return s.pub.Publish(s.Subject(ev), data)
+}
@@ -0,0 +1,37 @@
+package natssink_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/justenwalker/ddns/event"
+	"github.com/justenwalker/ddns/event/natssink"
+)
+
+type publisher map[string]string
+
+func (p publisher) Publish(subject string, data []byte) error {
+	p[subject] = string(data)
+	return nil
+}
+
+func TestSink(t *testing.T) {
+	pub := publisher{}
+	s := natssink.New(pub, "ddns")
+	err := s.Handle(context.Background(), event.Event{
+		Type:      event.Changed,
+		Time:      time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Provider:  "dynu",
+		Hostnames: []string{"a.example.com"},
+		NewIPs:    []net.IP{net.ParseIP("203.0.113.7")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"type":"changed","time":"2020-01-02T03:04:05Z","provider":"dynu","hostnames":["a.example.com"],"new_ips":["203.0.113.7"]}`
+	if got := pub["ddns.changed"]; got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
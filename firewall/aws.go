@@ -0,0 +1,165 @@
+package firewall
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/justenwalker/ddns/internal/awsv4"
+	"github.com/justenwalker/ddns/internal/httpreq"
+)
+
+// HTTPRequester makes http requests and returns responses.
+// *http.Client implicitly implements HTTPRequester and can be provided wherever this interface is requested.
+type HTTPRequester = httpreq.Requester
+
+// AWSSecurityGroup replaces the ingress rule of an EC2 security group that allows the address
+type AWSSecurityGroup struct {
+	Region  string
+	GroupID string
+	// Protocol is tcp, udp, icmp or -1 for all traffic
+	Protocol    string
+	FromPort    int
+	ToPort      int
+	Description string
+
+	// Credentials; when AccessKeyID is empty they are read from the AWS_* environment variables
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
+	// Endpoint defaults to https://ec2.<region>.amazonaws.com
+	Endpoint   string
+	HTTPClient HTTPRequester
+}
+
+// Replace authorizes ingress from new and revokes it from old
+func (g AWSSecurityGroup) Replace(ctx context.Context, old, new net.IP) error {
+	if err := g.call(ctx, "AuthorizeSecurityGroupIngress", new); err != nil && !hasCode(err, "InvalidPermission.Duplicate") {
+		return err
+	}
+	if old != nil {
+		if err := g.call(ctx, "RevokeSecurityGroupIngress", old); err != nil && !hasCode(err, "InvalidPermission.NotFound") {
+			return err
+		}
+	}
+	return nil
+}
+
+// AWSError is an error returned by the EC2 API
+type AWSError struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func (e AWSError) Error() string {
+	return fmt.Sprintf("firewall: aws: %s: %s", e.Code, e.Message)
+}
+
+func hasCode(err error, code string) bool {
+	e, ok := err.(AWSError)
+	return ok && e.Code == code
+}
+
+func (g AWSSecurityGroup) call(ctx context.Context, action string, ip net.IP) error {
+	form := url.Values{}
+	form.Set("Action", action)
+	form.Set("Version", "2016-11-15")
+	form.Set("GroupId", g.GroupID)
+	protocol := g.Protocol
+	if protocol == "" {
+		protocol = "tcp"
+	}
+	form.Set("IpPermissions.1.IpProtocol", protocol)
+	form.Set("IpPermissions.1.FromPort", strconv.Itoa(g.FromPort))
+	form.Set("IpPermissions.1.ToPort", strconv.Itoa(g.ToPort))
+	rangeKey := "IpPermissions.1.IpRanges.1."
+	cidr := ip.String() + "/32"
+	cidrKey := "CidrIp"
+	if isIPv6(ip) {
+		rangeKey = "IpPermissions.1.Ipv6Ranges.1."
+		cidr = ip.String() + "/128"
+		cidrKey = "CidrIpv6"
+	}
+	form.Set(rangeKey+cidrKey, cidr)
+	if g.Description != "" && action == "AuthorizeSecurityGroupIngress" {
+		form.Set(rangeKey+"Description", g.Description)
+	}
+	body := []byte(form.Encode())
+
+	endpoint := g.Endpoint
+	if endpoint == "" {
+		endpoint = "https://ec2." + g.Region + ".amazonaws.com/"
+	}
+	creds := awsv4.Credentials{AccessKeyID: g.AccessKeyID, SecretAccessKey: g.SecretAccessKey, SessionToken: g.SessionToken}
+	if creds.AccessKeyID == "" {
+		var err error
+		if creds, err = awsv4.EnvCredentials(); err != nil {
+			return err
+		}
+	}
+	signer := &awsv4.Signer{Credentials: creds, Region: g.Region, Service: "ec2"}
+	corrected, err := g.send(ctx, signer, action, endpoint, body)
+	if corrected && timeRejected(err) {
+		// signed with a skewed clock, which the response corrected
+		_, err = g.send(ctx, signer, action, endpoint, body)
+	}
+	return err
+}
+
+// send signs and sends a request, returning true if the response corrected the clock of the signer
+func (g AWSSecurityGroup) send(ctx context.Context, signer *awsv4.Signer, action, endpoint string, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	if err := signer.Sign(req, body); err != nil {
+		return false, err
+	}
+	hc := g.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	corrected := signer.Observe(resp)
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return corrected, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return corrected, nil
+	}
+	var errResp struct {
+		Errors []AWSError `xml:"Errors>Error"`
+	}
+	if err := xml.Unmarshal(data, &errResp); err != nil || len(errResp.Errors) == 0 {
+		return corrected, fmt.Errorf("firewall: aws: %s returned %s", action, resp.Status)
+	}
+	return corrected, errResp.Errors[0]
+}
+
+// timeRejected returns true if AWS rejected a request because of the time it was signed at
+func timeRejected(err error) bool {
+	e, ok := err.(AWSError)
+	if !ok {
+		return false
+	}
+	switch e.Code {
+	case "RequestExpired", "RequestTimeTooSkewed":
+		return true
+	}
+	return strings.Contains(e.Message, "Signature expired") || strings.Contains(e.Message, "Signature not yet current")
+}
@@ -0,0 +1,92 @@
+package firewall_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/justenwalker/ddns/firewall"
+	"github.com/justenwalker/ddns/internal/awsv4"
+)
+
+func TestAWSClockSkew(t *testing.T) {
+	defer func(c *awsv4.Clock) { awsv4.DefaultClock = c }(awsv4.DefaultClock)
+	awsv4.DefaultClock = &awsv4.Clock{}
+	server := time.Now().Add(2 * time.Hour).UTC()
+	var dates []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dates = append(dates, r.Header.Get("X-Amz-Date"))
+		w.Header().Set("Date", server.Format(http.TimeFormat))
+		if len(dates) == 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`<Response><Errors><Error><Code>RequestExpired</Code><Message>Request has expired.</Message></Error></Errors></Response>`))
+		}
+	}))
+	defer srv.Close()
+	g := firewall.AWSSecurityGroup{
+		Region: "us-east-1", GroupID: "sg-1", FromPort: 22, ToPort: 22,
+		AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret",
+		Endpoint: srv.URL,
+	}
+	if err := g.Replace(context.Background(), nil, net.ParseIP("203.0.113.7")); err != nil {
+		t.Fatal(err)
+	}
+	if len(dates) != 2 {
+		t.Fatalf("expected a request rejected for its time to be signed again, got %d request(s)", len(dates))
+	}
+	if want := server.Format("20060102T15"); dates[1][:11] != want {
+		t.Errorf("expected the retry to be signed at the server time %s, got %s", want, dates[1])
+	}
+}
+
+func TestAWSSecurityGroup(t *testing.T) {
+	var forms []url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			t.Error("expected a signed request")
+		}
+		body, _ := io.ReadAll(r.Body)
+		form, _ := url.ParseQuery(string(body))
+		forms = append(forms, form)
+		if form.Get("Action") == "RevokeSecurityGroupIngress" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`<Response><Errors><Error><Code>InvalidPermission.NotFound</Code><Message>not found</Message></Error></Errors></Response>`))
+		}
+	}))
+	defer srv.Close()
+	g := firewall.AWSSecurityGroup{
+		Region: "us-east-1", GroupID: "sg-1", FromPort: 22, ToPort: 22, Description: "home",
+		AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret",
+		Endpoint: srv.URL,
+	}
+	if err := g.Replace(context.Background(), net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2")); err != nil {
+		t.Fatalf("expected a rule already revoked to be accepted, got %v", err)
+	}
+	if len(forms) != 2 {
+		t.Fatalf("expected two requests, got %d", len(forms))
+	}
+	add, revoke := forms[0], forms[1]
+	if add.Get("Action") != "AuthorizeSecurityGroupIngress" || add.Get("GroupId") != "sg-1" ||
+		add.Get("IpPermissions.1.IpProtocol") != "tcp" || add.Get("IpPermissions.1.Ipv6Ranges.1.CidrIpv6") != "2001:db8::2/128" ||
+		add.Get("IpPermissions.1.Ipv6Ranges.1.Description") != "home" {
+		t.Errorf("unexpected authorize request %v", add)
+	}
+	if revoke.Get("Action") != "RevokeSecurityGroupIngress" || revoke.Get("IpPermissions.1.Ipv6Ranges.1.CidrIpv6") != "2001:db8::1/128" {
+		t.Errorf("unexpected revoke request %v", revoke)
+	}
+
+	g.GroupID = "sg-missing"
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`<Response><Errors><Error><Code>InvalidGroup.NotFound</Code><Message>no group</Message></Error></Errors></Response>`))
+	})
+	err := g.Replace(context.Background(), nil, net.ParseIP("203.0.113.7"))
+	if e, ok := err.(firewall.AWSError); !ok || e.Code != "InvalidGroup.NotFound" {
+		t.Errorf("expected the AWS error, got %v", err)
+	}
+}
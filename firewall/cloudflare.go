@@ -0,0 +1,94 @@
+package firewall
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/justenwalker/ddns/internal/cfapi"
+)
+
+// cloudflareDuplicateRule is the error code returned when an identical access rule already exists
+const cloudflareDuplicateRule = 10009
+
+// CloudflareAccessRule replaces a Cloudflare IP Access rule for the address.
+// Rules are scoped to the zone if ZoneID is set, or to the account otherwise.
+type CloudflareAccessRule struct {
+	APIToken  string
+	ZoneID    string
+	AccountID string
+	// Mode defaults to whitelist
+	Mode string
+	// Notes defaults to "ddns"
+	Notes string
+
+	Endpoint   string
+	HTTPClient HTTPRequester
+}
+
+type accessRule struct {
+	ID            string `json:"id,omitempty"`
+	Mode          string `json:"mode"`
+	Notes         string `json:"notes,omitempty"`
+	Configuration struct {
+		Target string `json:"target"`
+		Value  string `json:"value"`
+	} `json:"configuration"`
+}
+
+// Replace creates the rule for new and deletes the rules for old that have the same Mode and Notes,
+// leaving the rules created by other means in place
+func (r CloudflareAccessRule) Replace(ctx context.Context, old, new net.IP) error {
+	c := &cfapi.Client{Token: r.APIToken, Endpoint: r.Endpoint, HTTPClient: r.HTTPClient}
+	base := r.basePath()
+	rule := accessRule{Mode: r.Mode, Notes: r.Notes}
+	if rule.Mode == "" {
+		rule.Mode = "whitelist"
+	}
+	if rule.Notes == "" {
+		rule.Notes = "ddns"
+	}
+	rule.Configuration.Target = target(new)
+	rule.Configuration.Value = new.String()
+	if err := c.Do(ctx, http.MethodPost, base, rule, nil); err != nil {
+		if errs, ok := err.(cfapi.Errors); !ok || !errs.Has(cloudflareDuplicateRule) {
+			return err
+		}
+	}
+	if old == nil {
+		return nil
+	}
+	q := url.Values{}
+	q.Set("configuration.target", target(old))
+	q.Set("configuration.value", old.String())
+	q.Set("mode", rule.Mode)
+	var existing []accessRule
+	if err := c.Do(ctx, http.MethodGet, base+"?"+q.Encode(), nil, &existing); err != nil {
+		return err
+	}
+	for _, e := range existing {
+		// only the rules ddns created are removed; the notes filter of the API matches substrings
+		if e.Mode != rule.Mode || e.Notes != rule.Notes {
+			continue
+		}
+		if err := c.Do(ctx, http.MethodDelete, base+"/"+e.ID, nil, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r CloudflareAccessRule) basePath() string {
+	if r.ZoneID != "" {
+		return "/zones/" + r.ZoneID + "/firewall/access_rules/rules"
+	}
+	return "/accounts/" + r.AccountID + "/firewall/access_rules/rules"
+}
+
+func target(ip net.IP) string {
+	if isIPv6(ip) {
+		return "ip6"
+	}
+	return "ip"
+}
@@ -0,0 +1,72 @@
+package firewall_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/justenwalker/ddns/firewall"
+)
+
+func TestCloudflareAccessRule(t *testing.T) {
+	rules := `[
+		{"id":"ddns","mode":"whitelist","notes":"ddns","configuration":{"target":"ip","value":"203.0.113.1"}},
+		{"id":"manual","mode":"whitelist","notes":"office","configuration":{"target":"ip","value":"203.0.113.1"}},
+		{"id":"block","mode":"block","notes":"ddns","configuration":{"target":"ip","value":"203.0.113.1"}},
+		{"id":"suffix","mode":"whitelist","notes":"ddns backup","configuration":{"target":"ip","value":"203.0.113.1"}}
+	]`
+	var created map[string]interface{}
+	var deleted []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("unexpected authorization %q", r.Header.Get("Authorization"))
+		}
+		const base = "/zones/zone/firewall/access_rules/rules"
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == base:
+			json.NewDecoder(r.Body).Decode(&created)
+			w.Write([]byte(`{"success":true,"result":{"id":"new"}}`))
+		case r.Method == http.MethodGet && r.URL.Path == base:
+			if q := r.URL.Query(); q.Get("configuration.value") != "203.0.113.1" || q.Get("mode") != "whitelist" {
+				t.Errorf("unexpected query %s", r.URL.RawQuery)
+			}
+			w.Write([]byte(`{"success":true,"result":` + rules + `}`))
+		case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, base+"/"):
+			deleted = append(deleted, strings.TrimPrefix(r.URL.Path, base+"/"))
+			w.Write([]byte(`{"success":true,"result":{}}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	rule := firewall.CloudflareAccessRule{APIToken: "token", ZoneID: "zone", Endpoint: srv.URL}
+	if err := rule.Replace(context.Background(), net.ParseIP("203.0.113.1"), net.ParseIP("203.0.113.2")); err != nil {
+		t.Fatal(err)
+	}
+	if created["mode"] != "whitelist" || created["notes"] != "ddns" {
+		t.Errorf("unexpected rule created: %v", created)
+	}
+	if len(deleted) != 1 || deleted[0] != "ddns" {
+		t.Errorf("expected only the rule created by ddns to be deleted, got %v", deleted)
+	}
+}
+
+func TestCloudflareAccessRuleDuplicate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"success":false,"errors":[{"code":10009,"message":"firewallaccessrules.api.duplicate_of_existing"}]}`))
+	}))
+	defer srv.Close()
+	rule := firewall.CloudflareAccessRule{APIToken: "token", AccountID: "account", Endpoint: srv.URL}
+	if err := rule.Replace(context.Background(), nil, net.ParseIP("2001:db8::1")); err != nil {
+		t.Errorf("expected an existing rule to be accepted, got %v", err)
+	}
+}
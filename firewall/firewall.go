@@ -0,0 +1,87 @@
+// Package firewall keeps firewall objects that reference the published address in sync when it changes,
+// so access control lists follow the DNS record.
+package firewall // import "github.com/justenwalker/ddns/firewall"
+
+import (
+	"context"
+	"net"
+	"os/exec"
+	"strings"
+
+	"github.com/justenwalker/ddns/event"
+)
+
+// Updater replaces an address in a firewall object.
+// When no previous address is known, new is only added.
+type Updater = event.Replacer
+
+// Runner runs a command; it is used by updaters that shell out to nft or iptables
+type Runner func(ctx context.Context, name string, args ...string) error
+
+// ExecRunner runs the command with os/exec, including its output in the error on failure
+func ExecRunner(ctx context.Context, name string, args ...string) error {
+	out, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return &CommandError{Command: append([]string{name}, args...), Output: msg, Err: err}
+		}
+		return &CommandError{Command: append([]string{name}, args...), Err: err}
+	}
+	return nil
+}
+
+// CommandError is returned by ExecRunner when a command fails
+type CommandError struct {
+	Command []string
+	Output  string
+	Err     error
+}
+
+func (e *CommandError) Error() string {
+	msg := "firewall: " + strings.Join(e.Command, " ") + ": " + e.Err.Error()
+	if e.Output != "" {
+		msg += ": " + e.Output
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+type multi []Updater
+
+// Multi applies the replacement to each updater in order, stopping at the first error
+func Multi(updaters ...Updater) Updater {
+	return multi(updaters)
+}
+
+func (m multi) Replace(ctx context.Context, old, new net.IP) error {
+	for _, u := range m {
+		if err := u.Replace(ctx, old, new); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sink returns an event sink that applies Changed events to the updater, one address family at a time
+func Sink(u Updater) event.Sink {
+	return event.ReplaceSink(u, "")
+}
+
+// Apply replaces the old addresses with the new ones, pairing them by family.
+// Families without a new address are left untouched.
+func Apply(ctx context.Context, u Updater, oldIPs, newIPs []net.IP) error {
+	for _, r := range event.Replacements(oldIPs, newIPs) {
+		if err := u.Replace(ctx, r.Old, r.New); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isIPv6(ip net.IP) bool {
+	return ip.To4() == nil
+}
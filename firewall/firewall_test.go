@@ -0,0 +1,45 @@
+package firewall_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/justenwalker/ddns/event"
+	"github.com/justenwalker/ddns/firewall"
+)
+
+type recorder struct {
+	cmds []string
+}
+
+func (r *recorder) run(ctx context.Context, name string, args ...string) error {
+	r.cmds = append(r.cmds, name+" "+strings.Join(args, " "))
+	return nil
+}
+
+func TestSink(t *testing.T) {
+	rec := &recorder{}
+	nft := firewall.NFTSet{Table: "filter", Set: "home", Runner: rec.run}
+	ipt := firewall.IPTables{Chain: "INPUT", Rule: []string{"-s", firewall.AddressPlaceholder, "-j", "ACCEPT"}, Runner: rec.run}
+	sink := firewall.Sink(firewall.Multi(nft, ipt))
+	err := sink.Handle(context.Background(), event.Event{
+		Type:   event.Changed,
+		OldIPs: []net.IP{net.ParseIP("203.0.113.1"), net.ParseIP("2001:db8::1")},
+		NewIPs: []net.IP{net.ParseIP("203.0.113.2"), net.ParseIP("2001:db8::1")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"nft add element inet filter home { 203.0.113.2 }",
+		"nft delete element inet filter home { 203.0.113.1 }",
+		"iptables -t filter -I INPUT -s 203.0.113.2 -j ACCEPT",
+		"iptables -t filter -D INPUT -s 203.0.113.1 -j ACCEPT",
+	}
+	if fmt.Sprint(rec.cmds) != fmt.Sprint(want) {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(rec.cmds, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,53 @@
+package firewall
+
+import (
+	"context"
+	"net"
+	"strings"
+)
+
+// AddressPlaceholder is replaced by the address in IPTables rule specifications
+const AddressPlaceholder = "{ip}"
+
+// IPTables replaces a rule referencing the address, e.g. Rule: []string{"-s", "{ip}", "-p", "tcp", "--dport", "22", "-j", "ACCEPT"}.
+// iptables is used for IPv4 addresses and ip6tables for IPv6 addresses.
+type IPTables struct {
+	// Table defaults to filter
+	Table string
+	Chain string
+	// Rule is the rule specification; AddressPlaceholder is replaced by the address
+	Rule []string
+	// Runner runs iptables; ExecRunner is used if nil
+	Runner Runner
+}
+
+// Replace inserts the rule for new at the top of the chain and deletes the rule for old
+func (t IPTables) Replace(ctx context.Context, old, new net.IP) error {
+	run := t.Runner
+	if run == nil {
+		run = ExecRunner
+	}
+	cmd := "iptables"
+	if isIPv6(new) {
+		cmd = "ip6tables"
+	}
+	table := t.Table
+	if table == "" {
+		table = "filter"
+	}
+	if err := run(ctx, cmd, append([]string{"-t", table, "-I", t.Chain}, t.rule(new)...)...); err != nil {
+		return err
+	}
+	if old != nil {
+		return run(ctx, cmd, append([]string{"-t", table, "-D", t.Chain}, t.rule(old)...)...)
+	}
+	return nil
+}
+
+func (t IPTables) rule(ip net.IP) []string {
+	out := make([]string, len(t.Rule))
+	for i, arg := range t.Rule {
+		out[i] = strings.Replace(arg, AddressPlaceholder, ip.String(), -1)
+	}
+	return out
+}
@@ -0,0 +1,40 @@
+package firewall
+
+import (
+	"context"
+	"net"
+)
+
+// NFTSet replaces the address in a named nftables set, e.g. a set referenced by an "ip saddr @home accept" rule.
+// The set holds addresses of a single family, selected by IPv6.
+type NFTSet struct {
+	// Family of the table: ip, ip6, inet, ...
+	Family string
+	Table  string
+	Set    string
+	IPv6   bool
+	// Runner runs nft; ExecRunner is used if nil
+	Runner Runner
+}
+
+// Replace adds new to the set and removes old from it
+func (s NFTSet) Replace(ctx context.Context, old, new net.IP) error {
+	if isIPv6(new) != s.IPv6 {
+		return nil
+	}
+	run := s.Runner
+	if run == nil {
+		run = ExecRunner
+	}
+	family := s.Family
+	if family == "" {
+		family = "inet"
+	}
+	if err := run(ctx, "nft", "add", "element", family, s.Table, s.Set, "{ "+new.String()+" }"); err != nil {
+		return err
+	}
+	if old != nil {
+		return run(ctx, "nft", "delete", "element", family, s.Table, s.Set, "{ "+old.String()+" }")
+	}
+	return nil
+}
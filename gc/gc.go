@@ -0,0 +1,117 @@
+// Package gc removes the records that ddns owns but that are no longer configured, so that zones stay tidy as
+// hosts are decommissioned.
+//
+// Ownership is recorded by a TXT record at the name of each owned record, holding a marker such as
+// "heritage=ddns,owner=home". Records without the marker of the owner, such as those created by hand or
+// by another installation of ddns with its own owner, are never removed.
+package gc // import "github.com/justenwalker/ddns/gc"
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Record is a record of a zone
+type Record struct {
+	// ID identifies the record at the provider
+	ID string
+	// Name is the hostname of the record, such as www.example.com
+	Name string
+	// Type is the record type, such as A, AAAA or TXT
+	Type string
+	// Value is the address or text of the record
+	Value string
+}
+
+func (r Record) String() string {
+	return fmt.Sprintf("%s %s %s", r.Name, r.Type, r.Value)
+}
+
+// Zone is a zone at a provider that can manage its records
+type Zone interface {
+	Records(ctx context.Context) ([]Record, error)
+	Add(ctx context.Context, r Record) error
+	Delete(ctx context.Context, r Record) error
+}
+
+// Marker returns the text of the TXT records marking the records of owner
+func Marker(owner string) string {
+	return "heritage=ddns,owner=" + owner
+}
+
+// Hostname returns name as Collect compares it and passes it to keep: lowercase, without a trailing dot
+func Hostname(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+// Plan is the changes Collect found for a zone
+type Plan struct {
+	// Add are the markers claiming the records of kept hostnames
+	Add []Record
+	// Remove are the address records of the owned hostnames that are not kept, each followed by its marker
+	Remove []Record
+}
+
+// Collect returns the changes to z: the A and AAAA records of the hostnames marked as those of owner that keep
+// rejects are removed, with their marker. With claim, the kept hostnames that have A or AAAA records but no
+// marker of owner are marked.
+func Collect(ctx context.Context, z Zone, owner string, keep func(hostname string) bool, claim bool) (Plan, error) {
+	var p Plan
+	records, err := z.Records(ctx)
+	if err != nil {
+		return p, err
+	}
+	marker := Marker(owner)
+	markers := make(map[string]Record)
+	addresses := make(map[string][]Record)
+	for _, r := range records {
+		name := Hostname(r.Name)
+		switch {
+		case r.Type == "TXT" && strings.Trim(r.Value, `"`) == marker:
+			markers[name] = r
+		case r.Type == "A" || r.Type == "AAAA":
+			addresses[name] = append(addresses[name], r)
+		}
+	}
+	for _, name := range sortedKeys(markers) {
+		if !keep(name) {
+			p.Remove = append(p.Remove, addresses[name]...)
+			p.Remove = append(p.Remove, markers[name])
+		}
+	}
+	if claim {
+		for _, name := range sortedKeys(addresses) {
+			if _, ok := markers[name]; !ok && keep(name) {
+				p.Add = append(p.Add, Record{Name: name, Type: "TXT", Value: marker})
+			}
+		}
+	}
+	return p, nil
+}
+
+// Apply makes the changes of the plan, stopping at the first error. Each marker is removed after the records
+// it marks, so that a failed collection is resumed by the next one.
+func (p Plan) Apply(ctx context.Context, z Zone) error {
+	for _, r := range p.Add {
+		if err := z.Add(ctx, r); err != nil {
+			return fmt.Errorf("gc: adding %v: %w", r, err)
+		}
+	}
+	for _, r := range p.Remove {
+		if err := z.Delete(ctx, r); err != nil {
+			return fmt.Errorf("gc: removing %v: %w", r, err)
+		}
+	}
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,87 @@
+package gc_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/justenwalker/ddns/gc"
+)
+
+// zone is a Zone in memory, failing to delete the record with ID fail
+type zone struct {
+	records []gc.Record
+	fail    string
+}
+
+func (z *zone) Records(ctx context.Context) ([]gc.Record, error) {
+	return z.records, nil
+}
+
+func (z *zone) Add(ctx context.Context, r gc.Record) error {
+	z.records = append(z.records, r)
+	return nil
+}
+
+func (z *zone) Delete(ctx context.Context, r gc.Record) error {
+	if r.ID == z.fail {
+		return errors.New("server error")
+	}
+	for i, o := range z.records {
+		if o.ID == r.ID {
+			z.records = append(z.records[:i], z.records[i+1:]...)
+			return nil
+		}
+	}
+	return errors.New("no such record")
+}
+
+func TestCollect(t *testing.T) {
+	ctx := context.Background()
+	marker := gc.Marker("home")
+	z := &zone{records: []gc.Record{
+		{ID: "1", Name: "www.example.com", Type: "A", Value: "203.0.113.7"},
+		{ID: "2", Name: "www.example.com", Type: "TXT", Value: marker},
+		{ID: "3", Name: "old.example.com", Type: "A", Value: "203.0.113.7"},
+		{ID: "4", Name: "old.example.com", Type: "AAAA", Value: "2001:db8::7"},
+		{ID: "5", Name: "old.example.com.", Type: "TXT", Value: `"` + marker + `"`},
+		{ID: "6", Name: "manual.example.com", Type: "A", Value: "203.0.113.8"},
+		{ID: "7", Name: "office.example.com", Type: "A", Value: "203.0.113.9"},
+		{ID: "8", Name: "office.example.com", Type: "TXT", Value: gc.Marker("office")},
+		{ID: "9", Name: "new.example.com", Type: "A", Value: "203.0.113.7"},
+	}}
+	keep := func(hostname string) bool {
+		return hostname == "www.example.com" || hostname == "new.example.com"
+	}
+	p, err := gc.Collect(ctx, z, "home", keep, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var removed []string
+	for _, r := range p.Remove {
+		removed = append(removed, r.ID)
+	}
+	if want := []string{"3", "4", "5"}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("removing %v, want %v", removed, want)
+	}
+	if want := []gc.Record{{Name: "new.example.com", Type: "TXT", Value: marker}}; !reflect.DeepEqual(p.Add, want) {
+		t.Errorf("adding %v, want %v", p.Add, want)
+	}
+	if err := p.Apply(ctx, z); err != nil {
+		t.Fatal(err)
+	}
+	if len(z.records) != 7 {
+		t.Errorf("unexpected records after collection: %v", z.records)
+	}
+
+	z.records = append(z.records, gc.Record{ID: "10", Name: "gone.example.com", Type: "A"}, gc.Record{ID: "11", Name: "gone.example.com", Type: "TXT", Value: marker})
+	z.fail = "10"
+	p, _ = gc.Collect(ctx, z, "home", keep, false)
+	if err := p.Apply(ctx, z); err == nil || len(p.Add) != 0 {
+		t.Fatalf("expected the removal to fail, without claiming records, got %v %v", err, p.Add)
+	}
+	if p, _ := gc.Collect(ctx, z, "home", keep, false); len(p.Remove) != 2 {
+		t.Errorf("expected the marker to be kept after a failed removal, to try again, got %v", p.Remove)
+	}
+}
@@ -0,0 +1,173 @@
+// Package health serves the liveness and readiness of running daemons over HTTP, for Kubernetes probes and
+// uptime monitors. A Monitor keeps the state each daemon saves after every step:
+//
+//   - /healthz answers 200 while every daemon keeps to its schedule, and 503 once one is late for its next step,
+//     such as when it is stuck. Daemons that have not finished their first step are live.
+//   - /readyz answers 200 once every daemon has finished a step in which the addresses were detected and no
+//     provider failed, and 503 otherwise.
+//
+// The body is "ok", or the reason the daemons are not live or ready.
+package health // import "github.com/justenwalker/ddns/health"
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/justenwalker/ddns/state"
+)
+
+// Option sets monitor options
+type Option func(*Monitor)
+
+// Grace sets how late a daemon may be for its next step before it is not live; the default is one minute
+func Grace(grace time.Duration) Option {
+	return func(m *Monitor) {
+		m.grace = grace
+	}
+}
+
+// MaxFailures sets how many consecutive times a provider may fail before the daemons are not ready;
+// the default is 1, so that any failing provider makes them not ready
+func MaxFailures(n int) Option {
+	return func(m *Monitor) {
+		m.maxFailures = n
+	}
+}
+
+// Clock replaces time.Now
+func Clock(now func() time.Time) Option {
+	return func(m *Monitor) {
+		m.now = now
+	}
+}
+
+// Monitor keeps the last state saved by each daemon
+type Monitor struct {
+	grace       time.Duration
+	maxFailures int
+	now         func() time.Time
+
+	mu        sync.Mutex
+	snapshots []*state.Snapshot
+}
+
+// New constructs a monitor of no daemons; each daemon is added with Store
+func New(options ...Option) *Monitor {
+	m := &Monitor{
+		grace:       time.Minute,
+		maxFailures: 1,
+		now:         time.Now,
+	}
+	for _, opt := range options {
+		opt(m)
+	}
+	return m
+}
+
+// Store returns the store for a daemon to persist its state with, such as with daemon.Persist. It records the
+// snapshots the daemon saves, and saves them to store too, unless it is nil.
+func (m *Monitor) Store(store state.Store) state.Store {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.snapshots = append(m.snapshots, nil)
+	return recorder{m: m, i: len(m.snapshots) - 1, store: store}
+}
+
+// Live returns why a daemon is not live, or nil
+func (m *Monitor) Live() error {
+	now := m.now()
+	for _, s := range m.saved() {
+		if s == nil || s.NextRun.IsZero() {
+			continue
+		}
+		if late := now.Sub(s.NextRun); late > m.grace {
+			return fmt.Errorf("the daemon is %v late for its step at %s", late.Round(time.Second), s.NextRun.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// Ready returns why a daemon is not ready, or nil
+func (m *Monitor) Ready() error {
+	for _, s := range m.saved() {
+		if s == nil || s.NextRun.IsZero() {
+			return fmt.Errorf("the daemon has not finished its first step")
+		}
+		if s.DetectionFailures > 0 {
+			return fmt.Errorf("detection failed %d consecutive times: %s", s.DetectionFailures, s.DetectionError)
+		}
+		names := make([]string, 0, len(s.Providers))
+		for name := range s.Providers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if p := s.Providers[name]; m.maxFailures > 0 && p.Failures >= m.maxFailures {
+				return fmt.Errorf("%s failed %d consecutive times: %s", name, p.Failures, p.LastError)
+			}
+		}
+	}
+	return nil
+}
+
+// ServeHTTP answers the /healthz and /readyz requests, by the last element of their path
+func (m *Monitor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var err error
+	switch path.Base(r.URL.Path) {
+	case "healthz":
+		err = m.Live()
+	case "readyz":
+		err = m.Ready()
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, err)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// Register serves the /healthz and /readyz endpoints of m on mux
+func (m *Monitor) Register(mux *http.ServeMux) {
+	mux.Handle("/healthz", m)
+	mux.Handle("/readyz", m)
+}
+
+func (m *Monitor) saved() []*state.Snapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*state.Snapshot(nil), m.snapshots...)
+}
+
+// recorder is the store of the daemon at index i of the monitor
+type recorder struct {
+	m     *Monitor
+	i     int
+	store state.Store
+}
+
+func (r recorder) Load() (*state.Snapshot, error) {
+	if r.store == nil {
+		return state.New(), nil
+	}
+	return r.store.Load()
+}
+
+func (r recorder) Save(s *state.Snapshot) error {
+	r.m.mu.Lock()
+	r.m.snapshots[r.i] = s
+	r.m.mu.Unlock()
+	if r.store == nil {
+		return nil
+	}
+	return r.store.Save(s)
+}
@@ -0,0 +1,58 @@
+package health
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/justenwalker/ddns/state"
+)
+
+func TestMonitor(t *testing.T) {
+	now := time.Unix(1000, 0)
+	m := New(Clock(func() time.Time { return now }))
+	first, second := m.Store(nil), m.Store(nil)
+	get := func(path string) (int, string) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code, strings.TrimSpace(rec.Body.String())
+	}
+
+	if code, body := get("/healthz"); code != http.StatusOK || body != "ok" {
+		t.Errorf("expected daemons that are starting to be live, got %d %q", code, body)
+	}
+	if code, _ := get("/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("expected daemons that are starting not to be ready, got %d", code)
+	}
+
+	s := state.New()
+	s.NextRun = now.Add(time.Minute)
+	s.Providers["home"] = state.Provider{Failures: 1, LastError: "badauth"}
+	first.Save(s)
+	ok := state.New()
+	ok.NextRun = now.Add(time.Minute)
+	second.Save(ok)
+	if code, body := get("/readyz"); code != http.StatusServiceUnavailable || !strings.Contains(body, "home failed 1 consecutive times: badauth") {
+		t.Errorf("expected a failing provider not to be ready, got %d %q", code, body)
+	}
+	s.Providers["home"] = state.Provider{}
+	first.Save(s)
+	if code, body := get("/readyz"); code != http.StatusOK {
+		t.Errorf("expected the daemons to be ready, got %d %q", code, body)
+	}
+	second.Save(&state.Snapshot{NextRun: ok.NextRun, DetectionFailures: 2, DetectionError: "timeout"})
+	if code, body := get("/readyz"); code != http.StatusServiceUnavailable || !strings.Contains(body, "timeout") {
+		t.Errorf("expected a failing detection not to be ready, got %d %q", code, body)
+	}
+
+	now = now.Add(3 * time.Minute)
+	if code, body := get("/healthz"); code != http.StatusServiceUnavailable || !strings.Contains(body, "2m0s late") {
+		t.Errorf("expected a late daemon not to be live, got %d %q", code, body)
+	}
+	if code, _ := get("/metrics"); code != http.StatusNotFound {
+		t.Errorf("expected other paths to be not found, got %d", code)
+	}
+}
@@ -0,0 +1,173 @@
+// Package hook runs user commands around updates, such as to restart a VPN before detecting the address,
+// reload a firewall after it changed, or send a custom alert when an update fails.
+//
+// The commands inherit the environment of ddns, with these variables added:
+//
+//	DDNS_HOOK_EVENT      pre-detect, success or failure
+//	DDNS_HOOK_PROVIDER   name of the provider, for success and failure
+//	DDNS_HOOK_HOSTNAMES  hostnames of the provider, separated by commas
+//	DDNS_HOOK_OLD_IPS    addresses published before the update, separated by commas
+//	DDNS_HOOK_NEW_IPS    addresses of the update, separated by commas
+//	DDNS_HOOK_RESULT     updated, unchanged or failed
+//	DDNS_HOOK_ERROR      why the update failed
+package hook // import "github.com/justenwalker/ddns/hook"
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/justenwalker/ddns/event"
+	"github.com/justenwalker/ddns/ipdetect"
+)
+
+// Logger for printing debug logs from this package
+type Logger interface {
+	Log(format string, v ...interface{})
+}
+
+// Option sets runner options
+type Option func(*Runner)
+
+// Log enables logging the commands that are run using the given Logger
+func Log(l Logger) Option {
+	return func(r *Runner) {
+		r.logger = l
+	}
+}
+
+// Timeout limits how long each command may run; the default is 30 seconds
+func Timeout(d time.Duration) Option {
+	return func(r *Runner) {
+		r.timeout = d
+	}
+}
+
+// Runner runs the hook commands. Each command is the program to run followed by its arguments; it is not run
+// through a shell. Empty commands are skipped.
+type Runner struct {
+	logger    Logger
+	timeout   time.Duration
+	preDetect []string
+	onSuccess []string
+	onFailure []string
+}
+
+// New returns a runner of preDetect before each detection, onSuccess after each successful update and onFailure
+// after each failed one
+func New(preDetect, onSuccess, onFailure []string, options ...Option) *Runner {
+	r := &Runner{
+		timeout:   30 * time.Second,
+		preDetect: preDetect,
+		onSuccess: onSuccess,
+		onFailure: onFailure,
+	}
+	for _, opt := range options {
+		opt(r)
+	}
+	return r
+}
+
+func (r *Runner) logf(format string, v ...interface{}) {
+	if r.logger != nil {
+		r.logger.Log(format, v...)
+	}
+}
+
+// PreDetect runs the pre-detect command
+func (r *Runner) PreDetect(ctx context.Context) error {
+	return r.run(ctx, r.preDetect, []string{"DDNS_HOOK_EVENT=pre-detect"})
+}
+
+// Source returns src running the pre-detect command before each detection. Detection fails if the command fails.
+func (r *Runner) Source(src ipdetect.Source) ipdetect.Source {
+	return ipdetect.SourceFunc(func(ctx context.Context) ([]net.IP, error) {
+		if err := r.PreDetect(ctx); err != nil {
+			return nil, err
+		}
+		return src.Detect(ctx)
+	})
+}
+
+// Handle runs the success command for Updated events and the failure command for Failed events, so that a
+// Runner is an event.Sink. The result is unchanged when the event has the same OldIPs and NewIPs.
+func (r *Runner) Handle(ctx context.Context, ev event.Event) error {
+	switch ev.Type {
+	case event.Updated:
+		result := "updated"
+		if sameIPs(ev.OldIPs, ev.NewIPs) {
+			result = "unchanged"
+		}
+		return r.run(ctx, r.onSuccess, eventEnv("success", result, ev))
+	case event.Failed:
+		return r.run(ctx, r.onFailure, eventEnv("failure", "failed", ev))
+	}
+	return nil
+}
+
+func eventEnv(name, result string, ev event.Event) []string {
+	env := []string{
+		"DDNS_HOOK_EVENT=" + name,
+		"DDNS_HOOK_PROVIDER=" + ev.Provider,
+		"DDNS_HOOK_HOSTNAMES=" + strings.Join(ev.Hostnames, ","),
+		"DDNS_HOOK_OLD_IPS=" + joinIPs(ev.OldIPs),
+		"DDNS_HOOK_NEW_IPS=" + joinIPs(ev.NewIPs),
+		"DDNS_HOOK_RESULT=" + result,
+	}
+	if ev.Err != nil {
+		env = append(env, "DDNS_HOOK_ERROR="+ev.Err.Error())
+	}
+	return env
+}
+
+// run runs command with env added to the environment, returning its output in the error if it fails
+func (r *Runner) run(ctx context.Context, command []string, env []string) error {
+	if len(command) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Env = append(os.Environ(), env...)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	r.logf("hook: running %s (%s)", command[0], env[0])
+	err := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %v", r.timeout)
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(out.String()); msg != "" {
+			return fmt.Errorf("hook: %s: %v: %s", command[0], err, msg)
+		}
+		return fmt.Errorf("hook: %s: %v", command[0], err)
+	}
+	return nil
+}
+
+func joinIPs(ips []net.IP) string {
+	s := make([]string, len(ips))
+	for i, ip := range ips {
+		s[i] = ip.String()
+	}
+	return strings.Join(s, ",")
+}
+
+func sameIPs(a, b []net.IP) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,82 @@
+package hook
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/justenwalker/ddns/event"
+	"github.com/justenwalker/ddns/ipdetect"
+)
+
+func TestHandle(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	out := filepath.Join(t.TempDir(), "env")
+	record := []string{"sh", "-c", `env | grep ^DDNS_HOOK_ | sort > "$0"`, out}
+	r := New(nil, record, record)
+	ips := []net.IP{net.ParseIP("203.0.113.7")}
+	for _, tc := range []struct {
+		ev   event.Event
+		want string
+	}{
+		{
+			event.Event{Type: event.Updated, Provider: "home/web", Hostnames: []string{"a.example.com", "b.example.com"}, OldIPs: []net.IP{net.ParseIP("203.0.113.1")}, NewIPs: ips},
+			"DDNS_HOOK_EVENT=success\nDDNS_HOOK_HOSTNAMES=a.example.com,b.example.com\nDDNS_HOOK_NEW_IPS=203.0.113.7\nDDNS_HOOK_OLD_IPS=203.0.113.1\nDDNS_HOOK_PROVIDER=home/web\nDDNS_HOOK_RESULT=updated\n",
+		},
+		{
+			event.Event{Type: event.Updated, Provider: "home/web", OldIPs: ips, NewIPs: ips},
+			"DDNS_HOOK_EVENT=success\nDDNS_HOOK_HOSTNAMES=\nDDNS_HOOK_NEW_IPS=203.0.113.7\nDDNS_HOOK_OLD_IPS=203.0.113.7\nDDNS_HOOK_PROVIDER=home/web\nDDNS_HOOK_RESULT=unchanged\n",
+		},
+		{
+			event.Event{Type: event.Failed, Provider: "home/web", NewIPs: ips, Err: errors.New("badauth")},
+			"DDNS_HOOK_ERROR=badauth\nDDNS_HOOK_EVENT=failure\nDDNS_HOOK_HOSTNAMES=\nDDNS_HOOK_NEW_IPS=203.0.113.7\nDDNS_HOOK_OLD_IPS=\nDDNS_HOOK_PROVIDER=home/web\nDDNS_HOOK_RESULT=failed\n",
+		},
+	} {
+		os.Remove(out)
+		if err := r.Handle(context.Background(), tc.ev); err != nil {
+			t.Fatal(err)
+		}
+		if data, _ := os.ReadFile(out); string(data) != tc.want {
+			t.Errorf("%v: got environment\n%s\nwant\n%s", tc.ev.Type, data, tc.want)
+		}
+	}
+	os.Remove(out)
+	if err := r.Handle(context.Background(), event.Event{Type: event.Detected}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(out); err == nil {
+		t.Error("expected no hook for a Detected event")
+	}
+}
+
+func TestSource(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	var detected bool
+	src := ipdetect.SourceFunc(func(ctx context.Context) ([]net.IP, error) {
+		detected = true
+		return []net.IP{net.ParseIP("203.0.113.7")}, nil
+	})
+	r := New([]string{"sh", "-c", "echo vpn is down; exit 1"}, nil, nil)
+	_, err := r.Source(src).Detect(context.Background())
+	if err == nil || !strings.HasSuffix(err.Error(), "exit status 1: vpn is down") || detected {
+		t.Errorf("expected detection to fail with the output of the hook, got %v", err)
+	}
+	r = New([]string{"sleep", "5"}, nil, nil, Timeout(10*time.Millisecond))
+	if _, err := r.Source(src).Detect(context.Background()); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected the hook to time out, got %v", err)
+	}
+	r = New([]string{"true"}, nil, nil)
+	if ips, err := r.Source(src).Detect(context.Background()); err != nil || len(ips) != 1 {
+		t.Errorf("expected detection after the hook, got %v %v", ips, err)
+	}
+}
@@ -0,0 +1,105 @@
+package hostsync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/justenwalker/ddns/internal/httpreq"
+)
+
+// HTTPRequester makes http requests and returns responses.
+// *http.Client implicitly implements HTTPRequester and can be provided wherever this interface is requested.
+type HTTPRequester = httpreq.Requester
+
+// ConsulService updates the address of a service registered with the local Consul agent.
+// A service has a single address, so only changes of the family selected by IPv6 are applied.
+type ConsulService struct {
+	// Address of the Consul agent; defaults to http://127.0.0.1:8500
+	Address   string
+	Token     string
+	ServiceID string
+	IPv6      bool
+
+	HTTPClient HTTPRequester
+}
+
+// consulReadOnly are the fields of the agent service definition that describe the registration rather than
+// the service, and are not accepted when registering it
+var consulReadOnly = []string{"ContentHash", "Datacenter", "PeerName", "LocallyRegisteredAsSidecar"}
+
+// Replace re-registers the service with the new address.
+// The definition is copied as returned by the agent, so that fields such as Kind, Proxy and Connect are kept;
+// the health checks of the service are kept by the agent, as the registration does not replace them.
+func (c ConsulService) Replace(ctx context.Context, old, new net.IP) error {
+	if (new.To4() == nil) != c.IPv6 {
+		return nil
+	}
+	var svc map[string]json.RawMessage
+	if err := c.do(ctx, http.MethodGet, "/v1/agent/service/"+url.PathEscape(c.ServiceID), nil, &svc); err != nil {
+		return err
+	}
+	if name, ok := svc["Service"]; ok {
+		svc["Name"] = name
+		delete(svc, "Service")
+	}
+	for _, field := range consulReadOnly {
+		delete(svc, field)
+	}
+	address, err := json.Marshal(new.String())
+	if err != nil {
+		return err
+	}
+	svc["Address"] = address
+	return c.do(ctx, http.MethodPut, "/v1/agent/service/register", svc, nil)
+}
+
+func (c ConsulService) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	addr := c.Address
+	if addr == "" {
+		addr = "http://127.0.0.1:8500"
+	}
+	req, err := http.NewRequest(method, strings.TrimSuffix(addr, "/")+path, body)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	if c.Token != "" {
+		req.Header.Set("X-Consul-Token", c.Token)
+	}
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("hostsync: consul %s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(data)))
+	}
+	if out != nil {
+		return json.Unmarshal(data, out)
+	}
+	return nil
+}
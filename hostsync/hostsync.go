@@ -0,0 +1,21 @@
+// Package hostsync keeps tooling that refers to a host by address consistent when the host's address changes:
+// SSH known_hosts and config files, and Consul service registrations.
+package hostsync // import "github.com/justenwalker/ddns/hostsync"
+
+import (
+	"github.com/justenwalker/ddns/event"
+	"github.com/justenwalker/ddns/internal/atomicfile"
+)
+
+// Updater replaces the address of a host
+type Updater = event.Replacer
+
+// Sink returns an event sink that applies Changed events for hostname to the updater; see event.ReplaceSink
+func Sink(u Updater, hostname string) event.Sink {
+	return event.ReplaceSink(u, hostname)
+}
+
+// writeFileAtomic replaces the file at path with data, keeping its permissions
+func writeFileAtomic(path string, data []byte) error {
+	return atomicfile.Write(path, data, 0600)
+}
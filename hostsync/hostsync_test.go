@@ -0,0 +1,100 @@
+package hostsync
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var (
+	oldIP = net.ParseIP("203.0.113.1").To4()
+	newIP = net.ParseIP("203.0.113.2").To4()
+)
+
+func TestKnownHosts(t *testing.T) {
+	hashedOld := hashHost("203.0.113.1")
+	hashedName := hashHost("home.example.com")
+	in := "home.example.com,203.0.113.1 ssh-ed25519 AAAAkey1\n" +
+		"[203.0.113.1]:2222 ssh-ed25519 AAAAkey2\n" +
+		hashedOld + " ssh-ed25519 AAAAkey3\n" +
+		hashedName + " ssh-ed25519 AAAAkey4\n" +
+		"# comment 203.0.113.1\n" +
+		"other.example.com ssh-ed25519 AAAAkey5\n"
+	out, changed := KnownHosts{Hostname: "home.example.com"}.rewrite([]byte(in), oldIP, newIP)
+	if !changed {
+		t.Fatal("expected the file to change")
+	}
+	lines := strings.Split(string(out), "\n")
+	if lines[0] != "home.example.com,203.0.113.2 ssh-ed25519 AAAAkey1" {
+		t.Errorf("plain entry: %s", lines[0])
+	}
+	if lines[1] != "[203.0.113.2]:2222 ssh-ed25519 AAAAkey2" {
+		t.Errorf("port entry: %s", lines[1])
+	}
+	if p := strings.Fields(lines[2])[0]; !matchPattern(p, "203.0.113.2") || matchPattern(p, "203.0.113.1") {
+		t.Errorf("hashed entry: %s", lines[2])
+	}
+	if ps := strings.Split(strings.Fields(lines[3])[0], ","); len(ps) != 2 || !matchPattern(ps[1], "203.0.113.2") {
+		t.Errorf("hashed hostname entry: %s", lines[3])
+	}
+	if lines[4] != "# comment 203.0.113.1" || lines[5] != "other.example.com ssh-ed25519 AAAAkey5" {
+		t.Errorf("unrelated lines changed: %q", lines[4:])
+	}
+}
+
+func TestSSHConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	in := "Host home\n  HostName 203.0.113.1\n  User me\n\nHost other\n  HostName 198.51.100.1\n"
+	if err := ioutil.WriteFile(path, []byte(in), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := (SSHConfig{Path: path, Alias: "home"}).Replace(context.Background(), nil, newIP); err != nil {
+		t.Fatal(err)
+	}
+	out, _ := ioutil.ReadFile(path)
+	want := "Host home\n  HostName 203.0.113.2\n  User me\n\nHost other\n  HostName 198.51.100.1\n"
+	if string(out) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestConsulService(t *testing.T) {
+	var registered map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/agent/service/web":
+			w.Write([]byte(`{"Kind":"connect-proxy","ID":"web","Service":"web","Tags":["home"],"Address":"203.0.113.1","Port":443,` +
+				`"Proxy":{"DestinationServiceName":"api"},"Connect":{"Native":false},"ContentHash":"abc","Datacenter":"dc1"}`))
+		case r.Method == http.MethodPut && r.URL.Path == "/v1/agent/service/register":
+			if r.URL.Query().Has("replace-existing-checks") {
+				t.Error("expected the checks of the service to be kept")
+			}
+			json.NewDecoder(r.Body).Decode(&registered)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	c := ConsulService{Address: srv.URL, ServiceID: "web"}
+	if err := c.Replace(context.Background(), oldIP, newIP); err != nil {
+		t.Fatal(err)
+	}
+	if registered["Name"] != "web" || registered["Address"] != "203.0.113.2" || registered["Port"] != float64(443) {
+		t.Errorf("unexpected registration: %v", registered)
+	}
+	if registered["Kind"] != "connect-proxy" || registered["Proxy"] == nil || registered["Connect"] == nil {
+		t.Errorf("expected the proxy and connect settings to be kept: %v", registered)
+	}
+	if _, ok := registered["Service"]; ok {
+		t.Errorf("expected Service to be sent as Name: %v", registered)
+	}
+	if _, ok := registered["ContentHash"]; ok {
+		t.Errorf("expected the read-only fields to be left out: %v", registered)
+	}
+}
@@ -0,0 +1,140 @@
+package hostsync
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"io/ioutil"
+	"net"
+	"strings"
+)
+
+// KnownHosts updates the addresses in an OpenSSH known_hosts file.
+// Patterns for the old address are replaced with the new address, and entries for Hostname gain the new address
+// so that CheckHostIP keeps working. Hashed entries (HashKnownHosts) are supported.
+type KnownHosts struct {
+	Path     string
+	Hostname string
+}
+
+// Replace rewrites the known_hosts file
+func (k KnownHosts) Replace(ctx context.Context, old, new net.IP) error {
+	data, err := ioutil.ReadFile(k.Path)
+	if err != nil {
+		return err
+	}
+	out, changed := k.rewrite(data, old, new)
+	if !changed {
+		return nil
+	}
+	return writeFileAtomic(k.Path, out)
+}
+
+func (k KnownHosts) rewrite(data []byte, old, new net.IP) ([]byte, bool) {
+	lines := strings.SplitAfter(string(data), "\n")
+	changed := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		fields := strings.Fields(trimmed)
+		hostIdx := 0
+		if strings.HasPrefix(fields[0], "@") {
+			hostIdx = 1
+		}
+		if len(fields) <= hostIdx {
+			continue
+		}
+		patterns := strings.Split(fields[hostIdx], ",")
+		lineChanged := false
+		matchesHost, hashed, hasNew := false, false, false
+		for j, p := range patterns {
+			if old != nil && matchPattern(p, old.String()) {
+				patterns[j] = replacePattern(p, new.String())
+				lineChanged = true
+			}
+			if k.Hostname != "" && matchPattern(p, k.Hostname) {
+				matchesHost = true
+				hashed = strings.HasPrefix(p, "|1|")
+			}
+			if matchPattern(patterns[j], new.String()) {
+				hasNew = true
+			}
+		}
+		if matchesHost && !hasNew {
+			if hashed {
+				patterns = append(patterns, hashHost(new.String()))
+			} else {
+				patterns = append(patterns, new.String())
+			}
+			lineChanged = true
+		}
+		if !lineChanged {
+			continue
+		}
+		fields[hostIdx] = strings.Join(patterns, ",")
+		nl := ""
+		if strings.HasSuffix(line, "\n") {
+			nl = "\n"
+		}
+		lines[i] = strings.Join(fields, " ") + nl
+		changed = true
+	}
+	return []byte(strings.Join(lines, "")), changed
+}
+
+// matchPattern returns true if the known_hosts pattern refers to host, either in plain text,
+// with a port ([host]:port), or hashed
+func matchPattern(pattern, host string) bool {
+	if strings.HasPrefix(pattern, "|1|") {
+		parts := strings.Split(pattern[3:], "|")
+		if len(parts) != 2 {
+			return false
+		}
+		salt, err := base64.StdEncoding.DecodeString(parts[0])
+		if err != nil {
+			return false
+		}
+		hash, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return false
+		}
+		return hmac.Equal(hash, hostHash(salt, host))
+	}
+	if pattern == host {
+		return true
+	}
+	return strings.HasPrefix(pattern, "["+host+"]:")
+}
+
+// replacePattern returns a pattern of the same form as pattern (plain, with a port, or hashed) for host
+func replacePattern(pattern, host string) string {
+	switch {
+	case strings.HasPrefix(pattern, "|1|"):
+		return hashHost(host)
+	case strings.HasPrefix(pattern, "["):
+		return "[" + host + "]" + pattern[strings.LastIndex(pattern, "]")+1:]
+	}
+	return host
+}
+
+func hostHash(salt []byte, host string) []byte {
+	h := hmac.New(sha1.New, salt)
+	h.Write([]byte(host))
+	return h.Sum(nil)
+}
+
+func hashHost(host string) string {
+	salt := make([]byte, sha1.Size)
+	rand.Read(salt)
+	var b bytes.Buffer
+	b.WriteString("|1|")
+	b.WriteString(base64.StdEncoding.EncodeToString(salt))
+	b.WriteString("|")
+	b.WriteString(base64.StdEncoding.EncodeToString(hostHash(salt, host)))
+	return b.String()
+}
@@ -0,0 +1,70 @@
+package hostsync
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"regexp"
+	"strings"
+)
+
+var (
+	hostLine     = regexp.MustCompile(`(?i)^\s*(?:host|match)\b\s*=?\s*(.*)$`)
+	hostnameLine = regexp.MustCompile(`(?i)^(\s*hostname\b\s*=?\s*)(\S+)(.*)$`)
+)
+
+// SSHConfig updates HostName directives in an OpenSSH client configuration file.
+//
+// If Alias is set, only the HostName in "Host" blocks listing Alias is changed (and set to the new address even
+// if no old address is known); otherwise every HostName equal to the old address is changed.
+type SSHConfig struct {
+	Path  string
+	Alias string
+}
+
+// Replace rewrites the config file
+func (c SSHConfig) Replace(ctx context.Context, old, new net.IP) error {
+	data, err := ioutil.ReadFile(c.Path)
+	if err != nil {
+		return err
+	}
+	out, changed := c.rewrite(data, old, new)
+	if !changed {
+		return nil
+	}
+	return writeFileAtomic(c.Path, out)
+}
+
+func (c SSHConfig) rewrite(data []byte, old, new net.IP) ([]byte, bool) {
+	lines := strings.SplitAfter(string(data), "\n")
+	inAlias := false
+	changed := false
+	for i, line := range lines {
+		if m := hostLine.FindStringSubmatch(strings.TrimRight(line, "\r\n")); m != nil {
+			inAlias = false
+			for _, p := range strings.Fields(m[1]) {
+				if c.Alias != "" && p == c.Alias {
+					inAlias = true
+				}
+			}
+			continue
+		}
+		m := hostnameLine.FindStringSubmatch(strings.TrimRight(line, "\r\n"))
+		if m == nil {
+			continue
+		}
+		current := net.ParseIP(m[2])
+		var replace bool
+		if c.Alias != "" {
+			replace = inAlias && (old == nil || current == nil || current.Equal(old))
+		} else {
+			replace = old != nil && current != nil && current.Equal(old)
+		}
+		if !replace || m[2] == new.String() {
+			continue
+		}
+		lines[i] = m[1] + new.String() + m[3] + line[len(strings.TrimRight(line, "\r\n")):]
+		changed = true
+	}
+	return []byte(strings.Join(lines, "")), changed
+}
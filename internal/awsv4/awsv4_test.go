@@ -0,0 +1,61 @@
+package awsv4
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// Example from the AWS Signature Version 4 documentation
+func TestSign(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	s := &Signer{
+		Credentials: Credentials{
+			AccessKeyID:     "AKIDEXAMPLE",
+			SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		},
+		Region:  "us-east-1",
+		Service: "iam",
+		Now: func() time.Time {
+			return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+		},
+	}
+	if err := s.Sign(req, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-date, " +
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestClock(t *testing.T) {
+	local := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := &Clock{Local: func() time.Time { return local }}
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set("Date", "Fri, 02 Jan 2026 03:04:05 GMT")
+	if !c.Observe(resp) {
+		t.Fatal("expected the clock to be corrected")
+	}
+	if got, want := c.Now(), time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("got corrected time %v, want %v", got, want)
+	}
+	// within MaxSkew of the corrected time
+	local = local.Add(10 * time.Second)
+	if c.Observe(resp) {
+		t.Error("expected a small difference to be ignored")
+	}
+	if c.Observe(&http.Response{Header: http.Header{}}) {
+		t.Error("expected a response without a date to be ignored")
+	}
+	s := &Signer{Clock: c}
+	if s.now().Year() != 2026 {
+		t.Errorf("expected the signer to use the corrected clock, got %v", s.now())
+	}
+}
@@ -0,0 +1,64 @@
+package awsv4
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// MaxSkew is how far the signing time may drift from the time of the servers before Clock corrects it.
+// AWS rejects signatures more than 5 minutes off; the Date header only has a resolution of a second.
+const MaxSkew = 30 * time.Second
+
+// Clock is the local clock, corrected by the skew observed in the Date header of server responses.
+// Single-board computers without a real-time clock often run with the wrong time after boot,
+// until NTP catches up, and every signature they make until then would be rejected.
+type Clock struct {
+	// Local returns the local time; time.Now is used if nil
+	Local func() time.Time
+
+	mu   sync.Mutex
+	skew time.Duration
+}
+
+// DefaultClock is shared by signers without a Clock of their own: the skew is that of the local clock,
+// whichever server reports it
+var DefaultClock = &Clock{}
+
+func (c *Clock) local() time.Time {
+	if c.Local != nil {
+		return c.Local()
+	}
+	return time.Now()
+}
+
+// Now returns the corrected time
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.local().Add(c.skew)
+}
+
+// Skew returns the current correction: how far the servers are ahead of the local clock
+func (c *Clock) Skew() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.skew
+}
+
+// Observe compares the Date header of resp with the corrected time, and corrects the clock if they are more
+// than MaxSkew apart. It returns true if it did, so that a request rejected for its time can be signed again.
+func (c *Clock) Observe(resp *http.Response) bool {
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	skew := date.Sub(c.local())
+	if d := skew - c.skew; d > -MaxSkew && d < MaxSkew {
+		return false
+	}
+	c.skew = skew.Round(time.Second)
+	return true
+}
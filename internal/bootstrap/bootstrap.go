@@ -0,0 +1,225 @@
+// Package bootstrap resolves the API endpoints of providers without relying on the local DNS resolver alone, so
+// that ddns can fix the records of a network whose DNS is broken, or resolves the provider through the stale
+// records ddns is about to update, such as on its first run.
+package bootstrap // import "github.com/justenwalker/ddns/internal/bootstrap"
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Resolver looks hostnames up in Hosts, then with the DNS over HTTPS server at DoH, and then with the system
+// resolver, stopping at the first that returns addresses
+type Resolver struct {
+	// Hosts maps hostnames to their literal addresses
+	Hosts map[string][]net.IP
+	// DoH is the URL of an RFC 8484 DNS over HTTPS server, such as https://1.1.1.1/dns-query. Its host should be a
+	// literal address, as it is resolved by the system resolver otherwise.
+	DoH string
+	// Client sends the DoH queries; the default is http.DefaultClient
+	Client *http.Client
+}
+
+// LookupIP returns the addresses of host; a literal address is returned as is
+func (r *Resolver) LookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	name := strings.ToLower(strings.TrimSuffix(host, "."))
+	if ips := r.Hosts[name]; len(ips) > 0 {
+		return ips, nil
+	}
+	var dohErr error
+	if r.DoH != "" {
+		ips, err := r.lookupDoH(ctx, name)
+		if len(ips) > 0 {
+			return ips, nil
+		}
+		dohErr = err
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		if dohErr != nil {
+			return nil, fmt.Errorf("bootstrap: %v; %v", dohErr, err)
+		}
+		return nil, err
+	}
+	ips := make([]net.IP, len(addrs))
+	for i, a := range addrs {
+		ips[i] = a.IP
+	}
+	return ips, nil
+}
+
+// DialContext connects to addr at the addresses returned by LookupIP, in order
+func (r *Resolver) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := r.LookupIP(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	d := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	var lastErr error
+	for _, ip := range ips {
+		conn, err := d.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// HTTPClient returns an HTTP client connecting through the resolver. The TLS certificates are still verified
+// against the hostnames of the requests.
+func (r *Resolver) HTTPClient() *http.Client {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = r.DialContext
+	return &http.Client{Transport: t}
+}
+
+// DNS record types
+const (
+	typeA    = 1
+	typeAAAA = 28
+)
+
+// lookupDoH queries the A and AAAA records of name
+func (r *Resolver) lookupDoH(ctx context.Context, name string) ([]net.IP, error) {
+	var ips []net.IP
+	var errs []error
+	for _, qtype := range []uint16{typeA, typeAAAA} {
+		found, err := r.queryDoH(ctx, name, qtype)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		ips = append(ips, found...)
+	}
+	if len(ips) == 0 && len(errs) == 0 {
+		errs = append(errs, fmt.Errorf("no addresses for %s", name))
+	}
+	if len(errs) > 0 {
+		return ips, fmt.Errorf("DoH lookup of %s: %w", name, errs[0])
+	}
+	return ips, nil
+}
+
+func (r *Resolver) queryDoH(ctx context.Context, name string, qtype uint16) ([]net.IP, error) {
+	query, err := encodeQuery(name, qtype)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.DoH, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", r.DoH, resp.Status)
+	}
+	// a DNS message over HTTPS is at most 64 KiB
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	if err != nil {
+		return nil, err
+	}
+	return parseAnswers(body, qtype)
+}
+
+// encodeQuery returns a recursive query for the records of name, with the ID of 0 that RFC 8484 recommends for
+// caching
+func encodeQuery(name string, qtype uint16) ([]byte, error) {
+	msg := []byte{0, 0, 1, 0, 0, 1, 0, 0, 0, 0, 0, 0}
+	for _, label := range strings.Split(name, ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return nil, fmt.Errorf("invalid hostname %q", name)
+		}
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0)
+	msg = binary.BigEndian.AppendUint16(msg, qtype)
+	return binary.BigEndian.AppendUint16(msg, 1), nil
+}
+
+var errMalformed = errors.New("malformed DNS response")
+
+// parseAnswers returns the addresses of the answer records of type qtype in msg. The records of a CNAME chain
+// all answer the query, so their names are not checked.
+func parseAnswers(msg []byte, qtype uint16) ([]net.IP, error) {
+	if len(msg) < 12 {
+		return nil, errMalformed
+	}
+	if rcode := msg[3] & 0x0f; rcode != 0 {
+		if rcode == 3 {
+			return nil, errors.New("no such host")
+		}
+		return nil, fmt.Errorf("DNS response code %d", rcode)
+	}
+	qdcount := binary.BigEndian.Uint16(msg[4:])
+	ancount := binary.BigEndian.Uint16(msg[6:])
+	off := 12
+	var err error
+	for i := 0; i < int(qdcount); i++ {
+		if off, err = skipName(msg, off); err != nil {
+			return nil, err
+		}
+		off += 4
+	}
+	var ips []net.IP
+	for i := 0; i < int(ancount); i++ {
+		if off, err = skipName(msg, off); err != nil {
+			return nil, err
+		}
+		if off+10 > len(msg) {
+			return nil, errMalformed
+		}
+		rtype := binary.BigEndian.Uint16(msg[off:])
+		rdlength := int(binary.BigEndian.Uint16(msg[off+8:]))
+		off += 10
+		if off+rdlength > len(msg) {
+			return nil, errMalformed
+		}
+		if rtype == qtype && (rtype == typeA && rdlength == 4 || rtype == typeAAAA && rdlength == 16) {
+			ips = append(ips, net.IP(append([]byte(nil), msg[off:off+rdlength]...)))
+		}
+		off += rdlength
+	}
+	return ips, nil
+}
+
+// skipName returns the offset following the name at off
+func skipName(msg []byte, off int) (int, error) {
+	for off < len(msg) {
+		n := int(msg[off])
+		switch {
+		case n == 0:
+			return off + 1, nil
+		case n&0xc0 == 0xc0:
+			// a compression pointer ends the name
+			return off + 2, nil
+		}
+		off += n + 1
+	}
+	return 0, errMalformed
+}
@@ -0,0 +1,96 @@
+package bootstrap
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+// dohServer answers A queries for api.example.test through a CNAME, and nothing else
+func dohServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query, _ := io.ReadAll(r.Body)
+		if r.Header.Get("Content-Type") != "application/dns-message" {
+			t.Errorf("unexpected content type %q", r.Header.Get("Content-Type"))
+		}
+		want, _ := encodeQuery("api.example.test", typeA)
+		resp := append([]byte(nil), query...)
+		resp[2] |= 0x80 // response
+		if string(query) != string(want) {
+			w.Write(resp)
+			return
+		}
+		resp[7] = 2 // answers
+		// api.example.test CNAME lb.example.test, using a pointer to the question name
+		resp = append(resp, 0xc0, 12, 0, 5, 0, 1, 0, 0, 0, 60)
+		target := []byte{2, 'l', 'b', 0xc0, 16}
+		resp = binary.BigEndian.AppendUint16(resp, uint16(len(target)))
+		resp = append(resp, target...)
+		resp = append(resp, 2, 'l', 'b', 0xc0, 16, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4, 192, 0, 2, 10)
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(resp)
+	}))
+}
+
+func TestLookupIP(t *testing.T) {
+	doh := dohServer(t)
+	defer doh.Close()
+	r := &Resolver{
+		Hosts: map[string][]net.IP{"static.example.test": {net.ParseIP("192.0.2.1")}},
+		DoH:   doh.URL,
+	}
+	for host, want := range map[string]string{
+		"static.example.test": "192.0.2.1",
+		"API.example.test.":   "192.0.2.10",
+		"203.0.113.7":         "203.0.113.7",
+	} {
+		ips, err := r.LookupIP(context.Background(), host)
+		if err != nil || len(ips) != 1 || ips[0].String() != want {
+			t.Errorf("%s: expected %s, got %v (%v)", host, want, ips, err)
+		}
+	}
+	if _, err := r.LookupIP(context.Background(), "missing.invalid"); err == nil {
+		t.Error("expected an error for a name neither DoH nor the system resolver knows")
+	}
+}
+
+func TestHTTPClient(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	}))
+	defer api.Close()
+	u, _ := url.Parse(api.URL)
+	r := &Resolver{Hosts: map[string][]net.IP{"api.example.test": {net.ParseIP(u.Hostname())}}}
+	resp, err := r.HTTPClient().Get("http://api.example.test:" + u.Port() + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "api.example.test:"+u.Port() {
+		t.Errorf("expected the request to keep its host, got %q", body)
+	}
+}
+
+func TestParseAnswers(t *testing.T) {
+	query, _ := encodeQuery("example.test", typeAAAA)
+	if want := append([]byte{0, 0, 1, 0, 0, 1, 0, 0, 0, 0, 0, 0, 7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 4, 't', 'e', 's', 't', 0}, 0, 28, 0, 1); !reflect.DeepEqual(query, want) {
+		t.Errorf("unexpected query %v", query)
+	}
+	nx := append([]byte(nil), query...)
+	nx[3] = 3
+	if _, err := parseAnswers(nx, typeAAAA); err == nil || err.Error() != "no such host" {
+		t.Errorf("expected no such host, got %v", err)
+	}
+	truncated := append(append([]byte(nil), query...), 0xc0)
+	truncated[7] = 1
+	if _, err := parseAnswers(truncated, typeAAAA); err != errMalformed {
+		t.Errorf("expected a malformed response, got %v", err)
+	}
+}
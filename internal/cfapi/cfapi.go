@@ -0,0 +1,111 @@
+// Package cfapi is a minimal client for the Cloudflare v4 REST API
+package cfapi // import "github.com/justenwalker/ddns/internal/cfapi"
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/justenwalker/ddns/internal/httpreq"
+)
+
+// DefaultEndpoint is the base URL of the Cloudflare v4 API
+const DefaultEndpoint = "https://api.cloudflare.com/client/v4"
+
+// HTTPRequester makes http requests and returns responses
+type HTTPRequester = httpreq.Requester
+
+// Client calls the API with a bearer token
+type Client struct {
+	Token      string
+	Endpoint   string
+	HTTPClient HTTPRequester
+}
+
+// Error is a Cloudflare API error
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Errors is returned when the API reports failure
+type Errors []Error
+
+func (es Errors) Error() string {
+	msgs := make([]string, len(es))
+	for i, e := range es {
+		msgs[i] = fmt.Sprintf("%d: %s", e.Code, e.Message)
+	}
+	return "cloudflare: " + strings.Join(msgs, "; ")
+}
+
+// Has returns true if any error has the given code
+func (es Errors) Has(code int) bool {
+	for _, e := range es {
+		if e.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+type envelope struct {
+	Success bool            `json:"success"`
+	Errors  Errors          `json:"errors"`
+	Result  json.RawMessage `json:"result"`
+}
+
+// Do sends a request with in encoded as JSON (if not nil) and decodes the result into out (if not nil)
+func (c *Client) Do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+	endpoint := c.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+	req, err := http.NewRequest(method, strings.TrimSuffix(endpoint, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return fmt.Errorf("cloudflare: %s %s: %s: %v", method, path, resp.Status, err)
+	}
+	if !env.Success {
+		if len(env.Errors) == 0 {
+			return fmt.Errorf("cloudflare: %s %s: %s", method, path, resp.Status)
+		}
+		return env.Errors
+	}
+	if out != nil && len(env.Result) > 0 {
+		return json.Unmarshal(env.Result, out)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package cfapi
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDo(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("unexpected authorization %q", r.Header.Get("Authorization"))
+		}
+		switch r.URL.Path {
+		case "/zones":
+			if body, _ := io.ReadAll(r.Body); string(body) != `{"name":"example.com"}` || r.Header.Get("Content-Type") != "application/json" {
+				t.Errorf("unexpected body %s", body)
+			}
+			w.Write([]byte(`{"success":true,"errors":[],"result":{"id":"zone"}}`))
+		case "/fail":
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"success":false,"errors":[{"code":9109,"message":"Invalid access token"}]}`))
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte(`<html>bad gateway</html>`))
+		}
+	}))
+	defer srv.Close()
+	c := &Client{Token: "token", Endpoint: srv.URL + "/"}
+	ctx := context.Background()
+
+	var zone struct {
+		ID string `json:"id"`
+	}
+	if err := c.Do(ctx, http.MethodPost, "/zones", map[string]string{"name": "example.com"}, &zone); err != nil {
+		t.Fatal(err)
+	}
+	if zone.ID != "zone" {
+		t.Errorf("expected the result to be decoded, got %+v", zone)
+	}
+
+	err := c.Do(ctx, http.MethodGet, "/fail", nil, nil)
+	if errs, ok := err.(Errors); !ok || !errs.Has(9109) || errs.Has(10009) {
+		t.Errorf("expected the API errors, got %v", err)
+	}
+	if err := c.Do(ctx, http.MethodGet, "/html", nil, nil); err == nil {
+		t.Error("expected an error for a response that is not JSON")
+	}
+}
@@ -0,0 +1,386 @@
+// Package chaos soak tests the daemon: it steps a daemon on a simulated clock against a fake address source
+// and fake providers that randomly flap, fail and go down for hours, restarts it from its saved state, and checks
+// after every step that its state, backoff and events stay consistent with what the providers actually hold.
+// Days of operation run in seconds.
+package chaos // import "github.com/justenwalker/ddns/internal/chaos"
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/justenwalker/ddns/daemon"
+	"github.com/justenwalker/ddns/event"
+	"github.com/justenwalker/ddns/ipdetect"
+	"github.com/justenwalker/ddns/state"
+)
+
+// Logger is a logging interface
+type Logger interface {
+	Log(format string, v ...interface{})
+}
+
+// Option sets simulation options
+type Option func(*Sim)
+
+// Log reports progress every simulated day, and every violation, using the given Logger
+func Log(l Logger) Option {
+	return func(s *Sim) {
+		s.logger = l
+	}
+}
+
+// Seed sets the seed of the random faults, so a failing run can be replayed; the default is 1
+func Seed(seed int64) Option {
+	return func(s *Sim) {
+		s.rand = rand.New(rand.NewSource(seed))
+	}
+}
+
+// Duration sets how long to simulate; the default is a week
+func Duration(d time.Duration) Option {
+	return func(s *Sim) {
+		s.duration = d
+	}
+}
+
+// Interval sets the detection interval of the daemon; the default is 5 minutes
+func Interval(d time.Duration) Option {
+	return func(s *Sim) {
+		s.interval = d
+	}
+}
+
+// Rates sets the probabilities of the faults: per detection, that the address flaps and that detection fails;
+// per update, that it fails; and per step, that a provider goes down for hours and that the daemon restarts
+func Rates(flap, detectFail, updateFail, outage, restart float64) Option {
+	return func(s *Sim) {
+		s.flapRate = flap
+		s.detectFailRate = detectFail
+		s.updateFailRate = updateFail
+		s.outageRate = outage
+		s.restartRate = restart
+	}
+}
+
+// Report summarizes a simulation
+type Report struct {
+	Simulated  time.Duration
+	Steps      int
+	Flaps      int
+	Updates    int
+	Failures   int
+	Outages    int
+	Restarts   int
+	Promotions int
+	// Violations describes the first broken invariants, up to maxViolations
+	Violations []string
+	// ViolationCount is the number of broken invariants, including those not described
+	ViolationCount int
+}
+
+// OK returns true if no invariant was broken
+func (r *Report) OK() bool {
+	return r.ViolationCount == 0
+}
+
+func (r *Report) String() string {
+	return fmt.Sprintf("simulated %v in %d steps: %d flaps, %d updates, %d failures, %d outages, %d restarts, %d promotions, %d violations",
+		r.Simulated, r.Steps, r.Flaps, r.Updates, r.Failures, r.Outages, r.Restarts, r.Promotions, r.ViolationCount)
+}
+
+const (
+	maxViolations = 100
+	minBackoff    = 30 * time.Second
+	maxBackoff    = 30 * time.Minute
+	promoteAfter  = 3
+	refresh       = 12 * time.Hour
+	// quiet is how long the simulation runs without faults at the end, after which every provider must have converged
+	quiet = 3 * time.Hour
+)
+
+// Sim is a soak test simulation
+type Sim struct {
+	logger         Logger
+	rand           *rand.Rand
+	duration       time.Duration
+	interval       time.Duration
+	flapRate       float64
+	detectFailRate float64
+	updateFailRate float64
+	outageRate     float64
+	restartRate    float64
+
+	now       time.Time
+	faults    bool
+	ips       []net.IP
+	revert    []net.IP
+	backends  map[string]*backend
+	providers []daemon.Provider
+	store     memoryStore
+	daemon    *daemon.Daemon
+	report    Report
+}
+
+// backend is a fake provider and what the daemon is expected to respect about it
+type backend struct {
+	name      string
+	ips       []net.IP
+	down      time.Time
+	failures  int
+	lastOK    time.Time
+	retryAt   time.Time
+	failing   bool
+	recovered bool
+}
+
+// New returns a simulation
+func New(options ...Option) *Sim {
+	s := &Sim{
+		rand:           rand.New(rand.NewSource(1)),
+		duration:       7 * 24 * time.Hour,
+		interval:       5 * time.Minute,
+		flapRate:       0.02,
+		detectFailRate: 0.02,
+		updateFailRate: 0.1,
+		outageRate:     0.002,
+		restartRate:    0.002,
+		now:            time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		ips:            []net.IP{net.IPv4(203, 0, 113, 1).To4()},
+		backends:       make(map[string]*backend),
+	}
+	for _, opt := range options {
+		opt(s)
+	}
+	backup := &daemon.Provider{Name: "backup", Updater: s.updater("backup")}
+	s.providers = []daemon.Provider{
+		{Name: "primary", Updater: s.updater("primary"), Refresh: refresh, Backup: backup, PromoteAfter: promoteAfter},
+		{Name: "debounced", Updater: s.updater("debounced"), Debounce: 10 * time.Minute},
+	}
+	return s
+}
+
+func (s *Sim) logf(format string, v ...interface{}) {
+	if s.logger != nil {
+		s.logger.Log(format, v...)
+	}
+}
+
+func (s *Sim) violation(format string, v ...interface{}) {
+	msg := fmt.Sprintf("%s: ", s.now.Format(time.RFC3339)) + fmt.Sprintf(format, v...)
+	s.report.ViolationCount++
+	if len(s.report.Violations) < maxViolations {
+		s.report.Violations = append(s.report.Violations, msg)
+	}
+	s.logf("chaos: violation: %s", msg)
+}
+
+func (s *Sim) start() {
+	s.daemon = daemon.New(s.source(), s.providers,
+		daemon.Clock(func() time.Time { return s.now }),
+		daemon.Interval(s.interval),
+		daemon.Backoff(minBackoff, maxBackoff),
+		daemon.Events(publisher{s}),
+		daemon.Persist(&s.store),
+	)
+	if s.store.snapshot != nil {
+		s.daemon.Restore(s.store.snapshot)
+	}
+}
+
+// Run runs the simulation, followed by a quiet period without faults, and reports the outcome
+func (s *Sim) Run(ctx context.Context) *Report {
+	s.start()
+	begin := s.now
+	end := begin.Add(s.duration)
+	day := begin.Add(24 * time.Hour)
+	s.faults = true
+	for s.now.Before(end.Add(quiet)) && ctx.Err() == nil {
+		if s.faults && !s.now.Before(end) {
+			s.faults = false
+			s.revert = nil
+			for _, b := range s.backends {
+				b.down = time.Time{}
+			}
+		}
+		if s.faults && s.rand.Float64() < s.restartRate {
+			s.report.Restarts++
+			s.start()
+		}
+		if s.faults && s.rand.Float64() < s.outageRate {
+			names := []string{"primary", "backup", "debounced"}
+			b := s.backend(names[s.rand.Intn(len(names))])
+			b.down = s.now.Add(10*time.Minute + time.Duration(s.rand.Int63n(int64(6*time.Hour))))
+			s.report.Outages++
+		}
+		wait := s.daemon.Step(ctx)
+		s.report.Steps++
+		// saved after every step, like daemon.Run
+		s.store.Save(s.daemon.Snapshot())
+		s.check(wait)
+		if wait <= 0 {
+			wait = time.Second
+		}
+		s.now = s.now.Add(wait)
+		if !s.now.Before(day) {
+			s.logf("chaos: day %d: %s", int(s.now.Sub(begin)/(24*time.Hour)), &s.report)
+			day = day.Add(24 * time.Hour)
+		}
+	}
+	for _, name := range []string{"primary", "debounced"} {
+		if b := s.backend(name); !sameIPs(b.ips, s.ips) {
+			s.violation("%s holds %v after the quiet period, want %v", name, b.ips, s.ips)
+		}
+	}
+	s.report.Simulated = s.now.Sub(begin)
+	return &s.report
+}
+
+func (s *Sim) backend(name string) *backend {
+	b, ok := s.backends[name]
+	if !ok {
+		b = &backend{name: name}
+		s.backends[name] = b
+	}
+	return b
+}
+
+// source returns the fake address source, which flaps and fails at random
+func (s *Sim) source() ipdetect.Source {
+	return ipdetect.SourceFunc(func(ctx context.Context) ([]net.IP, error) {
+		if s.revert != nil {
+			s.ips, s.revert = s.revert, nil
+		} else if s.faults && s.rand.Float64() < s.flapRate {
+			old := s.ips
+			s.ips = []net.IP{net.IPv4(203, 0, 113, byte(1+s.rand.Intn(254))).To4()}
+			s.report.Flaps++
+			if s.rand.Intn(2) == 0 {
+				// a flap that reverts at the next detection
+				s.revert = old
+			}
+		}
+		if s.faults && s.rand.Float64() < s.detectFailRate {
+			return nil, fmt.Errorf("chaos: detection failed")
+		}
+		return s.ips, nil
+	})
+}
+
+// updater returns the fake provider name, which fails at random and during outages
+func (s *Sim) updater(name string) daemon.Updater {
+	return daemon.UpdaterFunc(func(ctx context.Context, ips []net.IP) error {
+		b := s.backend(name)
+		if s.now.Before(b.retryAt) {
+			s.violation("%s retried at %v, before its backoff ended at %v", name, s.now, b.retryAt)
+		}
+		if b.failures == 0 && !b.lastOK.IsZero() && sameIPs(b.ips, ips) && s.now.Sub(b.lastOK) < refresh {
+			s.violation("%s was sent %v again %v after it last accepted it", name, ips, s.now.Sub(b.lastOK))
+		}
+		if s.now.Before(b.down) || (s.faults && s.rand.Float64() < s.updateFailRate) {
+			b.failures++
+			s.report.Failures++
+			return fmt.Errorf("chaos: %s is unavailable", name)
+		}
+		b.failures = 0
+		b.ips = ips
+		b.lastOK = s.now
+		s.report.Updates++
+		return nil
+	})
+}
+
+// check verifies the saved state after a step
+func (s *Sim) check(wait time.Duration) {
+	if limit := maxDuration(s.interval, maxBackoff); wait <= 0 || wait > limit {
+		s.violation("step returned a wait of %v, want between 0 and %v", wait, limit)
+	}
+	snap := s.daemon.Snapshot()
+	for name, p := range snap.Providers {
+		b := s.backend(name)
+		if p.Failures != b.failures {
+			s.violation("%s: state has %d failures, the provider saw %d", name, p.Failures, b.failures)
+		}
+		b.retryAt = time.Time{}
+		if p.Failures > 0 {
+			b.retryAt = p.RetryAt
+			if d := p.RetryAt.Sub(s.now); d > maxBackoff {
+				s.violation("%s: retry in %v exceeds the maximum backoff", name, d)
+			}
+			continue
+		}
+		if p.IPs != nil && !sameIPs(p.IPs, b.ips) {
+			s.violation("%s: state says %v is published, the provider holds %v", name, p.IPs, b.ips)
+		}
+	}
+}
+
+// publisher checks the events of the daemon as they are published
+type publisher struct {
+	s *Sim
+}
+
+func (p publisher) Publish(ev event.Event) {
+	s := p.s
+	if ev.Provider == "" {
+		return
+	}
+	b := s.backend(ev.Provider)
+	switch ev.Type {
+	case event.Failed:
+		b.failing = true
+	case event.Changed:
+		if !sameIPs(ev.NewIPs, b.ips) {
+			s.violation("%s: changed to %v, the provider holds %v", ev.Provider, ev.NewIPs, b.ips)
+		}
+	case event.Updated:
+		b.recovered = b.failing
+		b.failing = false
+	case event.Recovered:
+		if !b.recovered {
+			s.violation("%s: recovered without a failure", ev.Provider)
+		}
+	case event.Promoted:
+		s.report.Promotions++
+		if primary := s.backend("primary"); primary.failures < promoteAfter {
+			s.violation("%s: promoted after %d failures of primary, want %d", ev.Provider, primary.failures, promoteAfter)
+		}
+	}
+}
+
+// memoryStore keeps the saved state across simulated restarts
+type memoryStore struct {
+	snapshot *state.Snapshot
+}
+
+func (m *memoryStore) Load() (*state.Snapshot, error) {
+	if m.snapshot == nil {
+		return state.New(), nil
+	}
+	return m.snapshot, nil
+}
+
+func (m *memoryStore) Save(s *state.Snapshot) error {
+	m.snapshot = s
+	return nil
+}
+
+func sameIPs(a, b []net.IP) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
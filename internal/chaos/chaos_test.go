@@ -0,0 +1,25 @@
+package chaos_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/justenwalker/ddns/internal/chaos"
+)
+
+func TestSoak(t *testing.T) {
+	for seed := int64(1); seed <= 5; seed++ {
+		r := chaos.New(
+			chaos.Seed(seed),
+			chaos.Duration(3*24*time.Hour),
+			chaos.Rates(0.05, 0.05, 0.2, 0.01, 0.01),
+		).Run(context.Background())
+		if !r.OK() {
+			t.Errorf("seed %d: %s\n%v", seed, r, r.Violations)
+		}
+		if r.Flaps == 0 || r.Failures == 0 || r.Outages == 0 || r.Restarts == 0 {
+			t.Errorf("seed %d: expected every kind of fault, got %s", seed, r)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Package httpdump logs HTTP requests and their responses in full, with their credentials redacted, so that the
+// logs can be attached to support tickets
+package httpdump // import "github.com/justenwalker/ddns/internal/httpdump"
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/justenwalker/ddns/internal/httpreq"
+	"github.com/justenwalker/ddns/logging"
+)
+
+// Redacted replaces the credentials in the dumps
+const Redacted = "REDACTED"
+
+// Logger for printing the dumps
+type Logger interface {
+	Log(format string, v ...interface{})
+}
+
+// HTTPRequester makes http requests and returns responses
+type HTTPRequester = httpreq.Requester
+
+// Client makes requests with a Requester, logging each of them and its response
+type Client struct {
+	Requester HTTPRequester
+	Logger    Logger
+	// Name prefixes the logs, such as "dynu"
+	Name string
+	// Secrets are redacted wherever they appear, such as in the bodies; the query parameters and headers whose
+	// names look like those of credentials, such as password or Authorization, are always redacted
+	Secrets []string
+}
+
+// Do dumps req, sends it with the Requester, and dumps the response
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	if dump, err := c.dumpRequest(req); err != nil {
+		c.log(nil, "%s: cannot dump the request: %v", c.Name, err)
+	} else {
+		c.log([]slog.Attr{slog.String("method", req.Method)}, "%s: request:\n%s", c.Name, dump)
+	}
+	start := time.Now()
+	resp, err := c.Requester.Do(req)
+	if err != nil {
+		c.log([]slog.Attr{slog.Duration("duration", time.Since(start))}, "%s: request failed: %s", c.Name, c.redact(err.Error()))
+		return nil, err
+	}
+	attrs := []slog.Attr{slog.Int("status_code", resp.StatusCode), slog.Duration("duration", time.Since(start))}
+	if dump, err := c.dumpResponse(resp); err != nil {
+		c.log(attrs, "%s: cannot dump the response: %v", c.Name, err)
+	} else {
+		c.log(attrs, "%s: response:\n%s", c.Name, dump)
+	}
+	return resp, nil
+}
+
+func (c *Client) log(attrs []slog.Attr, format string, v ...interface{}) {
+	logging.Print(c.Logger, slog.LevelDebug, attrs, format, v...)
+}
+
+// dumpRequest dumps a copy of req with its credentials redacted, leaving its body to be sent
+func (c *Client) dumpRequest(req *http.Request) (string, error) {
+	r := req.Clone(req.Context())
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return "", err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	u := *req.URL
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), Redacted)
+		}
+	}
+	u.RawQuery = redactQuery(u.RawQuery)
+	r.URL = &u
+	r.Header = redactHeader(req.Header)
+	dump, err := httputil.DumpRequestOut(r, true)
+	if err != nil {
+		return "", err
+	}
+	return c.redact(string(dump)), nil
+}
+
+// dumpResponse dumps resp with its credentials redacted, leaving its body to be read
+func (c *Client) dumpResponse(resp *http.Response) (string, error) {
+	r := *resp
+	r.Header = redactHeader(resp.Header)
+	dump, err := httputil.DumpResponse(&r, true)
+	// the body is read into memory by DumpResponse, and replaced by a copy
+	resp.Body = r.Body
+	if err != nil {
+		return "", err
+	}
+	return c.redact(string(dump)), nil
+}
+
+// redact replaces the secrets, and their URL encoding, in s
+func (c *Client) redact(s string) string {
+	for _, secret := range c.Secrets {
+		if secret == "" {
+			continue
+		}
+		s = strings.ReplaceAll(s, secret, Redacted)
+		s = strings.ReplaceAll(s, url.QueryEscape(secret), Redacted)
+	}
+	return s
+}
+
+// sensitive are the substrings of the names of the query parameters and headers that hold credentials
+var sensitive = []string{"pass", "pwd", "token", "secret", "key", "auth", "sig", "cookie", "credential", "session"}
+
+// Sensitive returns true if a query parameter or header called name is likely to hold a credential
+func Sensitive(name string) bool {
+	name = strings.ToLower(name)
+	for _, s := range sensitive {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactHeader(h http.Header) http.Header {
+	redacted := h.Clone()
+	for name, values := range redacted {
+		if Sensitive(name) {
+			for i := range values {
+				values[i] = Redacted
+			}
+		}
+	}
+	return redacted
+}
+
+// redactQuery redacts the values of the sensitive parameters of query, keeping their order
+func redactQuery(query string) string {
+	if query == "" {
+		return ""
+	}
+	params := strings.Split(query, "&")
+	for i, p := range params {
+		raw, _, ok := strings.Cut(p, "=")
+		name := raw
+		if unescaped, err := url.QueryUnescape(raw); err == nil {
+			name = unescaped
+		}
+		if ok && Sensitive(name) {
+			params[i] = raw + "=" + Redacted
+		}
+	}
+	return strings.Join(params, "&")
+}
@@ -0,0 +1,89 @@
+package httpdump
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type logs []string
+
+func (l *logs) Log(format string, v ...interface{}) {
+	*l = append(*l, fmt.Sprintf(format, v...))
+}
+
+type requesterFunc func(req *http.Request) (*http.Response, error)
+
+func (f requesterFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestClient(t *testing.T) {
+	var l logs
+	c := &Client{
+		Requester: requesterFunc(func(req *http.Request) (*http.Response, error) {
+			body, _ := io.ReadAll(req.Body)
+			if string(body) != `{"pw":"hunter2"}` {
+				t.Errorf("the request body was not left to send: %q", body)
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Status:     "200 OK",
+				ProtoMajor: 1,
+				ProtoMinor: 1,
+				Header:     http.Header{"Set-Cookie": {"session=abc"}, "Content-Type": {"text/plain"}},
+				Body:       io.NopCloser(strings.NewReader("good hunter2")),
+			}, nil
+		}),
+		Logger:  &l,
+		Name:    "test",
+		Secrets: []string{"hunter2", ""},
+	}
+	req, _ := http.NewRequest(http.MethodPost, "https://user:pw@api.example.com/nic/update?hostname=a.example.com&password=0123abcd&myip=203.0.113.7", strings.NewReader(`{"pw":"hunter2"}`))
+	req.Header.Set("API-Key", "k3y")
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(resp.Body); string(body) != "good hunter2" {
+		t.Errorf("the response body was not left to read: %q", body)
+	}
+	dumps := strings.Join(l, "\n")
+	for _, want := range []string{
+		"test: request:\nPOST /nic/update?hostname=a.example.com&password=REDACTED&myip=203.0.113.7 HTTP/1.1",
+		"Api-Key: REDACTED",
+		"Accept: application/json",
+		`{"pw":"REDACTED"}`,
+		"test: response:\nHTTP/1.1 200 OK",
+		"Set-Cookie: REDACTED",
+		"good REDACTED",
+	} {
+		if !strings.Contains(dumps, want) {
+			t.Errorf("missing %q in\n%s", want, dumps)
+		}
+	}
+	for _, secret := range []string{"hunter2", "0123abcd", "k3y", "abc"} {
+		if strings.Contains(dumps, secret) {
+			t.Errorf("%q is not redacted in\n%s", secret, dumps)
+		}
+	}
+}
+
+func TestSensitive(t *testing.T) {
+	for name, want := range map[string]bool{
+		"password":      true,
+		"Authorization": true,
+		"X-Gotify-Key":  true,
+		"access_token":  true,
+		"hostname":      false,
+		"myipv6":        false,
+		"Content-Type":  false,
+	} {
+		if got := Sensitive(name); got != want {
+			t.Errorf("Sensitive(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+// Package httpreq defines the interface through which the packages of ddns make HTTP requests,
+// so that tests and embedders can replace the HTTP client.
+package httpreq // import "github.com/justenwalker/ddns/internal/httpreq"
+
+import "net/http"
+
+// Requester makes http requests and returns responses.
+// *http.Client implicitly implements Requester and can be provided wherever this interface is requested.
+type Requester interface {
+	Do(req *http.Request) (*http.Response, error)
+}
@@ -0,0 +1,20 @@
+// Package keyring looks up the secrets ddns keeps in the keyring of the operating system: the login keychain
+// on macOS, the Credential Manager on Windows, and the Secret Service, such as GNOME Keyring or KWallet,
+// through secret-tool elsewhere.
+package keyring // import "github.com/justenwalker/ddns/internal/keyring"
+
+import "errors"
+
+// Service is the service the secrets of ddns are stored under
+const Service = "ddns"
+
+// ErrNotFound is returned by Lookup when the keyring has no secret of that name
+var ErrNotFound = errors.New("keyring: secret not found")
+
+// Lookup returns the secret stored under name, with Service, in the keyring of the current user
+func Lookup(name string) (string, error) {
+	if name == "" {
+		return "", errors.New("keyring: empty secret name")
+	}
+	return lookup(name)
+}
@@ -0,0 +1,22 @@
+package keyring
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// lookup reads a generic password of the login keychain, stored with
+// security add-generic-password -s ddns -a NAME -w
+func lookup(name string) (string, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", Service, "-a", name, "-w").Output()
+	var exit *exec.ExitError
+	switch {
+	case errors.As(err, &exit) && exit.ExitCode() == 44:
+		return "", ErrNotFound
+	case err != nil:
+		return "", fmt.Errorf("keyring: security: %v", err)
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
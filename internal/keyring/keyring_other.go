@@ -0,0 +1,24 @@
+//go:build !darwin && !windows
+
+package keyring
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+)
+
+// lookup reads a secret of the Secret Service, stored with
+// secret-tool store --label=ddns service ddns account NAME
+func lookup(name string) (string, error) {
+	out, err := exec.Command("secret-tool", "lookup", "service", Service, "account", name).Output()
+	var exit *exec.ExitError
+	switch {
+	case errors.As(err, &exit) && len(out) == 0 && len(exit.Stderr) == 0:
+		// secret-tool fails without a message when nothing matches
+		return "", ErrNotFound
+	case err != nil:
+		return "", fmt.Errorf("keyring: secret-tool: %v", err)
+	}
+	return string(out), nil
+}
@@ -0,0 +1,139 @@
+// Package netbind builds HTTP clients whose outbound connections originate from a specific interface or source address.
+// This is needed on multi-homed hosts where the default route is not the connection being published.
+package netbind // import "github.com/justenwalker/ddns/internal/netbind"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Binding selects where outbound connections originate from.
+// If both are set, Address must belong to Interface.
+type Binding struct {
+	// Interface is the name of the interface to send traffic from
+	Interface string
+	// Address is the local source address to send traffic from
+	Address net.IP
+}
+
+// Client returns an HTTP client whose connections are bound according to b
+func Client(b Binding) *http.Client {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	b.Apply(t)
+	return &http.Client{Transport: t}
+}
+
+// IsZero returns true if the binding does not restrict connections
+func (b Binding) IsZero() bool {
+	return b.Interface == "" && b.Address == nil
+}
+
+// Apply makes the transport dial connections according to b. A zero binding leaves the transport unchanged.
+func (b Binding) Apply(t *http.Transport) {
+	if !b.IsZero() {
+		t.DialContext = b.DialContext
+	}
+}
+
+// DialContext connects to addr from the bound interface or address.
+// The remote host is resolved first, and only remote addresses of a family the binding has a local address for are tried.
+func (b Binding) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	locals, err := b.localAddrs()
+	if err != nil {
+		return nil, err
+	}
+	remotes, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	var lastErr error
+	for _, remote := range remotes {
+		local := matchFamily(locals, remote.IP)
+		if local == nil {
+			continue
+		}
+		d := &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			LocalAddr: &net.TCPAddr{IP: local},
+			Control:   bindToDevice(b.Interface),
+		}
+		conn, err := d.DialContext(ctx, network, net.JoinHostPort(remote.IP.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("netbind: %s has no address of the same family as %s", b, host)
+	}
+	return nil, lastErr
+}
+
+func (b Binding) String() string {
+	switch {
+	case b.Interface != "" && b.Address != nil:
+		return fmt.Sprintf("%s (%s)", b.Interface, b.Address)
+	case b.Interface != "":
+		return b.Interface
+	}
+	return b.Address.String()
+}
+
+// localAddrs returns the candidate source addresses of the binding
+func (b Binding) localAddrs() ([]net.IP, error) {
+	if b.Interface == "" {
+		if b.Address == nil {
+			return nil, errors.New("netbind: no interface or address to bind to")
+		}
+		return []net.IP{b.Address}, nil
+	}
+	ifi, err := net.InterfaceByName(b.Interface)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	var ips []net.IP
+	for _, a := range addrs {
+		ipnet, ok := a.(*net.IPNet)
+		if !ok || ipnet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		if b.Address != nil {
+			if ipnet.IP.Equal(b.Address) {
+				return []net.IP{b.Address}, nil
+			}
+			continue
+		}
+		ips = append(ips, ipnet.IP)
+	}
+	if len(ips) == 0 {
+		if b.Address != nil {
+			return nil, fmt.Errorf("netbind: %s is not an address of %s", b.Address, b.Interface)
+		}
+		return nil, fmt.Errorf("netbind: %s has no usable addresses", b.Interface)
+	}
+	return ips, nil
+}
+
+// matchFamily returns the first local address of the same family as remote
+func matchFamily(locals []net.IP, remote net.IP) net.IP {
+	remoteIs4 := remote.To4() != nil
+	for _, ip := range locals {
+		if (ip.To4() != nil) == remoteIs4 {
+			return ip
+		}
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+package netbind
+
+import "syscall"
+
+// bindToDevice additionally pins the socket to the interface with SO_BINDTODEVICE,
+// so traffic leaves through it even without source-based policy routing.
+// Older kernels require CAP_NET_RAW for this; without it, binding falls back to the source address alone.
+func bindToDevice(iface string) func(network, address string, c syscall.RawConn) error {
+	if iface == "" {
+		return nil
+	}
+	return func(network, address string, c syscall.RawConn) error {
+		return c.Control(func(fd uintptr) {
+			syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, iface)
+		})
+	}
+}
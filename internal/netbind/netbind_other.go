@@ -0,0 +1,10 @@
+//go:build !linux
+
+package netbind
+
+import "syscall"
+
+// bindToDevice is not available on this platform; binding relies on the source address alone
+func bindToDevice(iface string) func(network, address string, c syscall.RawConn) error {
+	return nil
+}
@@ -0,0 +1,32 @@
+package netbind
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientBindAddress(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, _ := net.SplitHostPort(r.RemoteAddr)
+		fmt.Fprint(w, host)
+	}))
+	defer srv.Close()
+
+	resp, err := Client(Binding{Address: net.ParseIP("127.0.0.1")}).Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if string(body) != "127.0.0.1" {
+		t.Errorf("expected the connection to originate from 127.0.0.1, got %s", body)
+	}
+
+	if _, err := Client(Binding{Address: net.ParseIP("::1")}).Get(srv.URL); err == nil {
+		t.Error("expected an error binding an IPv6 address to an IPv4 destination")
+	}
+}
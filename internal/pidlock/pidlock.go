@@ -0,0 +1,102 @@
+// Package pidlock keeps a single instance of ddns running at a time, by holding an exclusive lock on a file
+// that records the process ID of its holder, so that overlapping updates do not trip the abuse detection
+// of providers. The lock is released by the operating system if the process dies.
+package pidlock // import "github.com/justenwalker/ddns/internal/pidlock"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errBusy is returned by lockFile when another process holds the lock
+var errBusy = errors.New("busy")
+
+// LockedError is returned when another process holds the lock
+type LockedError struct {
+	Path string
+	// PID of the holder, or 0 if it did not record it yet
+	PID int
+}
+
+func (e *LockedError) Error() string {
+	if e.PID == 0 {
+		return fmt.Sprintf("pidlock: %s is locked by another process", e.Path)
+	}
+	return fmt.Sprintf("pidlock: %s is locked by process %d", e.Path, e.PID)
+}
+
+// Lock is a held lock
+type Lock struct {
+	f *os.File
+}
+
+// Acquire locks the file at path, creating it if needed, and writes the process ID to it.
+// It returns a *LockedError without waiting if another process holds the lock.
+func Acquire(path string) (*Lock, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("pidlock: %v", err)
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		if errors.Is(err, errBusy) {
+			return nil, &LockedError{Path: path, PID: readPID(path)}
+		}
+		return nil, fmt.Errorf("pidlock: %s: %v", path, err)
+	}
+	if err := writePID(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("pidlock: %s: %v", path, err)
+	}
+	return &Lock{f: f}, nil
+}
+
+// Wait acquires the lock, trying again every poll while another process holds it, until ctx is done.
+// It then returns the *LockedError of the last attempt.
+func Wait(ctx context.Context, path string, poll time.Duration) (*Lock, error) {
+	for {
+		l, err := Acquire(path)
+		var locked *LockedError
+		if !errors.As(err, &locked) {
+			return l, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(poll):
+		}
+	}
+}
+
+// Release empties the file and releases the lock. The file is kept, as removing it could let two
+// processes lock different files at the same path.
+func (l *Lock) Release() error {
+	l.f.Truncate(0)
+	return l.f.Close()
+}
+
+func writePID(f *os.File) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	return err
+}
+
+// readPID returns the process ID recorded at path, or 0
+func readPID(path string) int {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	data, _ := io.ReadAll(io.LimitReader(f, 32))
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pid
+}
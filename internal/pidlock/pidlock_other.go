@@ -0,0 +1,10 @@
+//go:build !unix && !windows
+
+package pidlock
+
+import "os"
+
+// lockFile only records the process ID, as the platform has no advisory locks
+func lockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,47 @@
+package pidlock
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestAcquire(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ddns.pid")
+	l, err := Acquire(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != strconv.Itoa(os.Getpid())+"\n" {
+		t.Errorf("expected the process ID in the file, got %q", data)
+	}
+	_, err = Acquire(path)
+	var locked *LockedError
+	if !errors.As(err, &locked) || locked.PID != os.Getpid() {
+		t.Errorf("expected the lock to be held by this process, got %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := Wait(ctx, path, 10*time.Millisecond); !errors.As(err, &locked) {
+		t.Errorf("expected waiting to time out, got %v", err)
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		l.Release()
+	}()
+	next, err := Wait(context.Background(), path, 5*time.Millisecond)
+	if err != nil {
+		t.Fatalf("expected the lock once released, got %v", err)
+	}
+	if err := next.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(path); err != nil || len(data) != 0 {
+		t.Errorf("expected an empty file after release, got %q %v", data, err)
+	}
+}
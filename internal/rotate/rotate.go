@@ -0,0 +1,182 @@
+// Package rotate appends to files that are rotated by size and age, keeping a bounded number of rotated files,
+// so devices without logrotate do not fill their storage
+package rotate // import "github.com/justenwalker/ddns/internal/rotate"
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotatedFormat is the suffix of rotated files; it sorts in time order
+const rotatedFormat = "20060102T150405.000Z"
+
+// Option sets file options
+type Option func(*File)
+
+// MaxSize rotates the file before it grows beyond n bytes; the default is 10 MiB and 0 disables it
+func MaxSize(n int64) Option {
+	return func(f *File) {
+		f.maxSize = n
+	}
+}
+
+// MaxAge rotates the file when a write falls in another period of d than the last one, such as another
+// UTC day for 24 hours; 0, the default, disables it
+func MaxAge(d time.Duration) Option {
+	return func(f *File) {
+		f.maxAge = d
+	}
+}
+
+// Keep sets how many rotated files are kept; the default is 5 and 0 keeps all of them
+func Keep(n int) Option {
+	return func(f *File) {
+		f.keep = n
+	}
+}
+
+// File appends to the file at a path, renaming it to "<path>.<UTC time>", such as
+// ddns.log.20260102T030405.000Z, when it is rotated. Each write goes to a single file.
+type File struct {
+	path    string
+	maxSize int64
+	maxAge  time.Duration
+	keep    int
+
+	mu      sync.Mutex
+	f       *os.File
+	size    int64
+	written time.Time
+}
+
+// Open opens or creates the file at path, appending to it
+func Open(path string, options ...Option) (*File, error) {
+	f := &File{
+		path:    path,
+		maxSize: 10 << 20,
+		keep:    5,
+	}
+	for _, opt := range options {
+		opt(f)
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *File) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("rotate: %v", err)
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("rotate: %v", err)
+	}
+	f.f, f.size, f.written = file, fi.Size(), time.Time{}
+	if f.size > 0 {
+		f.written = fi.ModTime()
+	}
+	return nil
+}
+
+// Write appends p to the file at the current time
+func (f *File) Write(p []byte) (int, error) {
+	return f.WriteTime(p, time.Now())
+}
+
+// WriteTime appends p to the file, rotating it first if p would make it too large, or at falls in another
+// MaxAge period than the last write
+func (f *File) WriteTime(p []byte, at time.Time) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.f == nil {
+		return 0, fmt.Errorf("rotate: %s is closed", f.path)
+	}
+	if f.due(at, int64(len(p))) {
+		if err := f.rotate(at); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.f.Write(p)
+	f.size += int64(n)
+	f.written = at
+	if err != nil {
+		return n, fmt.Errorf("rotate: %v", err)
+	}
+	return n, nil
+}
+
+// due returns true if the file must be rotated before writing n bytes at the given time
+func (f *File) due(at time.Time, n int64) bool {
+	if f.size == 0 {
+		return false
+	}
+	if f.maxSize > 0 && f.size+n > f.maxSize {
+		return true
+	}
+	return f.maxAge > 0 && !f.written.IsZero() && !at.UTC().Truncate(f.maxAge).Equal(f.written.UTC().Truncate(f.maxAge))
+}
+
+// rotate renames the current file, reopens path and removes the oldest rotated files beyond keep
+func (f *File) rotate(at time.Time) error {
+	if err := f.f.Close(); err != nil {
+		return fmt.Errorf("rotate: %v", err)
+	}
+	f.f = nil
+	if err := os.Rename(f.path, f.path+"."+at.UTC().Format(rotatedFormat)); err != nil {
+		return fmt.Errorf("rotate: %v", err)
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	if f.keep <= 0 {
+		return nil
+	}
+	rotated, err := f.Rotated()
+	if err != nil {
+		return err
+	}
+	for len(rotated) > f.keep {
+		if err := os.Remove(rotated[0]); err != nil {
+			return fmt.Errorf("rotate: %v", err)
+		}
+		rotated = rotated[1:]
+	}
+	return nil
+}
+
+// Rotated returns the paths of the rotated files, oldest first
+func (f *File) Rotated() ([]string, error) {
+	matches, err := filepath.Glob(f.path + ".*")
+	if err != nil {
+		return nil, fmt.Errorf("rotate: %v", err)
+	}
+	var rotated []string
+	for _, m := range matches {
+		if _, err := time.Parse(rotatedFormat, strings.TrimPrefix(m, f.path+".")); err == nil {
+			rotated = append(rotated, m)
+		}
+	}
+	sort.Strings(rotated)
+	return rotated, nil
+}
+
+// Close closes the file
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.f == nil {
+		return nil
+	}
+	err := f.f.Close()
+	f.f = nil
+	return err
+}
@@ -0,0 +1,55 @@
+package rotate_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/justenwalker/ddns/internal/rotate"
+)
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ddns.log")
+	if err := os.WriteFile(path, []byte("existing\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	// the age of an existing file is that of its last write
+	if err := os.Chtimes(path, at, at); err != nil {
+		t.Fatal(err)
+	}
+	f, err := rotate.Open(path, rotate.MaxSize(20), rotate.MaxAge(time.Hour), rotate.Keep(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	write := func(line string, at time.Time) {
+		t.Helper()
+		if _, err := f.WriteTime([]byte(line+"\n"), at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// appended to the existing file, then rotated by size
+	write("first", at)
+	write("second line", at)
+	if data, _ := os.ReadFile(path); string(data) != "second line\n" {
+		t.Errorf("unexpected file after rotating by size: %q", data)
+	}
+	// rotated by age, replacing the older rotated file
+	write("third", at.Add(time.Hour))
+	rotated, err := f.Rotated()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rotated) != 1 || !strings.HasSuffix(rotated[0], ".20260102T040405.000Z") {
+		t.Fatalf("unexpected rotated files %v", rotated)
+	}
+	if data, _ := os.ReadFile(rotated[0]); string(data) != "second line\n" {
+		t.Errorf("unexpected rotated file %q", data)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "third\n" {
+		t.Errorf("unexpected file after rotating by age: %q", data)
+	}
+}
@@ -0,0 +1,63 @@
+package ipdetect
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// Cache memoizes the addresses detected by a source for a TTL, so that several providers updating in sequence
+// share a single lookup. Errors are not cached.
+//
+// Concurrent calls to Detect while a lookup is in flight wait for its result.
+type Cache struct {
+	src Source
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	ips     []net.IP
+	expires time.Time
+}
+
+// NewCache wraps src in a cache that keeps detected addresses for ttl
+func NewCache(src Source, ttl time.Duration) *Cache {
+	return &Cache{
+		src: src,
+		ttl: ttl,
+		now: time.Now,
+	}
+}
+
+// Detect returns the cached addresses, or queries the source if they have expired
+func (c *Cache) Detect(ctx context.Context) ([]net.IP, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ips != nil && c.now().Before(c.expires) {
+		return copyIPs(c.ips), nil
+	}
+	ips, err := c.src.Detect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.ips = copyIPs(ips)
+	c.expires = c.now().Add(c.ttl)
+	return ips, nil
+}
+
+// Invalidate discards the cached addresses so the next Detect queries the source
+func (c *Cache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ips = nil
+	c.expires = time.Time{}
+}
+
+func copyIPs(ips []net.IP) []net.IP {
+	out := make([]net.IP, len(ips))
+	for i, ip := range ips {
+		out[i] = append(net.IP(nil), ip...)
+	}
+	return out
+}
@@ -0,0 +1,43 @@
+package ipdetect
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	calls := 0
+	src := SourceFunc(func(ctx context.Context) ([]net.IP, error) {
+		calls++
+		return []net.IP{net.IPv4(203, 0, 113, byte(calls)).To4()}, nil
+	})
+	now := time.Unix(1000, 0)
+	c := NewCache(src, time.Minute)
+	c.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		ips, err := c.Detect(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ips[0].Equal(net.IPv4(203, 0, 113, 1)) {
+			t.Fatalf("unexpected cached address %v", ips)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("expected one lookup, got %d", calls)
+	}
+	now = now.Add(time.Minute)
+	c.Detect(ctx)
+	if calls != 2 {
+		t.Fatalf("expected a lookup after the TTL, got %d", calls)
+	}
+	c.Invalidate()
+	c.Detect(ctx)
+	if calls != 3 {
+		t.Fatalf("expected a lookup after invalidation, got %d", calls)
+	}
+}
@@ -0,0 +1,123 @@
+package ipdetect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+)
+
+// unhealthyScore is the score below which Consensus leaves a source out when enough healthier sources remain
+const unhealthyScore = 0.5
+
+// Fallback returns a Source that queries sources one at a time and returns the first successful result.
+// Sources implementing Scorer are tried in order of their score, so a flaky source drops behind reliable ones;
+// otherwise the given order is kept.
+func Fallback(sources ...Source) Source {
+	return fallback(sources)
+}
+
+type fallback []Source
+
+func (f fallback) Detect(ctx context.Context) ([]net.IP, error) {
+	if len(f) == 0 {
+		return nil, errors.New("ipdetect: no sources")
+	}
+	var errs []error
+	for _, src := range byScore(f) {
+		ips, err := src.Detect(ctx)
+		if err == nil {
+			return ips, nil
+		}
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, fmt.Errorf("ipdetect: all sources failed: %w", errors.Join(errs...))
+}
+
+// Consensus returns a Source that queries sources concurrently and returns the addresses reported by at least
+// quorum of them. Sources implementing Scorer with a score below 0.5 are left out while enough healthier
+// sources remain to reach the quorum.
+func Consensus(quorum int, sources ...Source) Source {
+	if quorum < 1 {
+		quorum = 1
+	}
+	return consensus{quorum: quorum, sources: sources}
+}
+
+type consensus struct {
+	quorum  int
+	sources []Source
+}
+
+func (c consensus) Detect(ctx context.Context) ([]net.IP, error) {
+	participants := byScore(c.sources)
+	healthy := 0
+	for _, src := range participants {
+		if score(src) >= unhealthyScore {
+			healthy++
+		}
+	}
+	if healthy >= c.quorum {
+		participants = participants[:healthy]
+	}
+	results := make([][]net.IP, len(participants))
+	errs := make([]error, len(participants))
+	var wg sync.WaitGroup
+	for i, src := range participants {
+		wg.Add(1)
+		go func(i int, src Source) {
+			defer wg.Done()
+			results[i], errs[i] = src.Detect(ctx)
+		}(i, src)
+	}
+	wg.Wait()
+
+	var ips []net.IP
+	votes := make(map[string]int)
+	for _, res := range results {
+		seen := make(map[string]bool)
+		for _, ip := range res {
+			ip = normalize(ip)
+			if key := ip.String(); !seen[key] {
+				seen[key] = true
+				votes[key]++
+				ips = appendUnique(ips, ip)
+			}
+		}
+	}
+	var agreed []net.IP
+	for _, ip := range ips {
+		if votes[ip.String()] >= c.quorum {
+			agreed = append(agreed, ip)
+		}
+	}
+	if len(agreed) == 0 {
+		err := fmt.Errorf("ipdetect: no address reported by %d of %d sources", c.quorum, len(participants))
+		if joined := errors.Join(errs...); joined != nil {
+			err = fmt.Errorf("%w: %w", err, joined)
+		}
+		return nil, err
+	}
+	return agreed, nil
+}
+
+// byScore returns a copy of sources sorted by descending score; sources without a score count as healthy
+func byScore(sources []Source) []Source {
+	sorted := append([]Source(nil), sources...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return score(sorted[i]) > score(sorted[j])
+	})
+	return sorted
+}
+
+func score(src Source) float64 {
+	if s, ok := src.(Scorer); ok {
+		return s.Score()
+	}
+	return 1
+}
@@ -0,0 +1,150 @@
+package ipdetect
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// ExecOption sets exec source options
+type ExecOption func(*Exec)
+
+// Exec is a Source that runs a command and parses its standard output.
+//
+// The output may be one or more addresses separated by whitespace, or JSON:
+// a string, an array of strings, or an object whose string (or array of string) values are addresses,
+// such as {"ipv4":"203.0.113.7","ipv6":"2001:db8::7"}.
+type Exec struct {
+	logger  Logger
+	command []string
+	env     []string
+	dir     string
+}
+
+// ExecLog enables logging using the given Logger
+func ExecLog(l Logger) ExecOption {
+	return func(e *Exec) {
+		e.logger = l
+	}
+}
+
+// ExecEnv sets additional environment variables ("KEY=value") for the command.
+// The command inherits the environment of the current process as well.
+func ExecEnv(env []string) ExecOption {
+	return func(e *Exec) {
+		e.env = env
+	}
+}
+
+// ExecDir sets the working directory of the command
+func ExecDir(dir string) ExecOption {
+	return func(e *Exec) {
+		e.dir = dir
+	}
+}
+
+// NewExec constructs a source that runs command[0] with the remaining elements as arguments
+func NewExec(command []string, options ...ExecOption) *Exec {
+	e := &Exec{
+		command: command,
+	}
+	for _, opt := range options {
+		opt(e)
+	}
+	return e
+}
+
+func (e *Exec) logf(format string, v ...interface{}) {
+	if e.logger != nil {
+		e.logger.Log(format, v...)
+	}
+}
+
+// Detect runs the command and parses the addresses from its output
+func (e *Exec) Detect(ctx context.Context) ([]net.IP, error) {
+	if len(e.command) == 0 {
+		return nil, errors.New("ipdetect: exec: no command")
+	}
+	cmd := exec.CommandContext(ctx, e.command[0], e.command[1:]...)
+	if len(e.env) > 0 {
+		cmd.Env = append(os.Environ(), e.env...)
+	}
+	cmd.Dir = e.dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg != "" {
+			return nil, fmt.Errorf("ipdetect: exec %s: %v: %s", e.command[0], err, msg)
+		}
+		return nil, fmt.Errorf("ipdetect: exec %s: %v", e.command[0], err)
+	}
+	ips, err := parseOutput(stdout.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("ipdetect: exec %s: %v", e.command[0], err)
+	}
+	e.logf("ipdetect: exec %s detected %v", e.command[0], ips)
+	return ips, nil
+}
+
+// parseOutput parses whitespace separated or JSON encoded addresses
+func parseOutput(out []byte) ([]net.IP, error) {
+	out = bytes.TrimSpace(out)
+	var candidates []string
+	if len(out) > 0 && (out[0] == '{' || out[0] == '[' || out[0] == '"') {
+		var v interface{}
+		if err := json.Unmarshal(out, &v); err != nil {
+			return nil, err
+		}
+		candidates = collectStrings(v)
+	} else {
+		candidates = strings.Fields(string(out))
+	}
+	var ips []net.IP
+	for _, c := range candidates {
+		ip := net.ParseIP(strings.TrimSpace(c))
+		if ip == nil {
+			return nil, fmt.Errorf("%q is not an IP address", c)
+		}
+		ips = appendUnique(ips, normalize(ip))
+	}
+	if len(ips) == 0 {
+		return nil, errors.New("no IP address in output")
+	}
+	return ips, nil
+}
+
+// collectStrings returns the string values of a decoded JSON string, array or object.
+// Object values are returned in key order so the result is deterministic.
+func collectStrings(v interface{}) []string {
+	switch val := v.(type) {
+	case string:
+		return []string{val}
+	case []interface{}:
+		var out []string
+		for _, item := range val {
+			out = append(out, collectStrings(item)...)
+		}
+		return out
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var out []string
+		for _, k := range keys {
+			out = append(out, collectStrings(val[k])...)
+		}
+		return out
+	}
+	return nil
+}
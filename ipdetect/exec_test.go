@@ -0,0 +1,43 @@
+package ipdetect
+
+import (
+	"net"
+	"testing"
+)
+
+func TestParseOutput(t *testing.T) {
+	tests := []struct {
+		out  string
+		want []string
+		err  bool
+	}{
+		{out: "203.0.113.7\n", want: []string{"203.0.113.7"}},
+		{out: "203.0.113.7 2001:db8::7\n", want: []string{"203.0.113.7", "2001:db8::7"}},
+		{out: `["203.0.113.7","2001:db8::7"]`, want: []string{"203.0.113.7", "2001:db8::7"}},
+		{out: `{"ipv6":"2001:db8::7","ipv4":"203.0.113.7"}`, want: []string{"203.0.113.7", "2001:db8::7"}},
+		{out: "", err: true},
+		{out: "not-an-ip", err: true},
+	}
+	for _, tt := range tests {
+		ips, err := parseOutput([]byte(tt.out))
+		if tt.err {
+			if err == nil {
+				t.Errorf("%q: expected an error", tt.out)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.out, err)
+			continue
+		}
+		if len(ips) != len(tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.out, ips, tt.want)
+			continue
+		}
+		for i := range ips {
+			if !ips[i].Equal(net.ParseIP(tt.want[i])) {
+				t.Errorf("%q: got %v, want %v", tt.out, ips, tt.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package ipdetect
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// healthWeight is the weight of the most recent result in the exponentially weighted score and latency
+const healthWeight = 0.2
+
+// Scorer is implemented by sources that report a health score between 0 (always failing) and 1 (always succeeding).
+// Fallback and Consensus use it to de-prioritize flaky sources.
+type Scorer interface {
+	Score() float64
+}
+
+// SourceStats is a snapshot of the health of a tracked source
+type SourceStats struct {
+	Name      string
+	Successes uint64
+	Failures  uint64
+	// Score is an exponentially weighted success rate. New sources start at 1.
+	Score float64
+	// Latency is an exponentially weighted average of the time taken by Detect
+	Latency     time.Duration
+	LastError   error
+	LastSuccess time.Time
+}
+
+// Health tracks the success rate and latency of a set of sources
+type Health struct {
+	mu      sync.Mutex
+	sources []*Tracked
+	now     func() time.Time
+}
+
+// NewHealth creates an empty health registry
+func NewHealth() *Health {
+	return &Health{now: time.Now}
+}
+
+// Track wraps src so that its results are recorded under name
+func (h *Health) Track(name string, src Source) *Tracked {
+	t := &Tracked{
+		src:    src,
+		health: h,
+		stats:  SourceStats{Name: name, Score: 1},
+	}
+	h.mu.Lock()
+	h.sources = append(h.sources, t)
+	h.mu.Unlock()
+	return t
+}
+
+// Stats returns a snapshot of every tracked source, in the order they were tracked
+func (h *Health) Stats() []SourceStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make([]SourceStats, len(h.sources))
+	for i, t := range h.sources {
+		out[i] = t.stats
+	}
+	return out
+}
+
+// Tracked is a Source whose results are recorded in a Health registry
+type Tracked struct {
+	src    Source
+	health *Health
+	stats  SourceStats
+}
+
+// Detect queries the source and records the outcome.
+// Calls aborted by ctx are not counted against the source.
+func (t *Tracked) Detect(ctx context.Context) ([]net.IP, error) {
+	start := t.health.now()
+	ips, err := t.src.Detect(ctx)
+	if err != nil && ctx.Err() != nil {
+		return nil, err
+	}
+	end := t.health.now()
+	t.health.mu.Lock()
+	defer t.health.mu.Unlock()
+	s := &t.stats
+	result := 1.0
+	if err != nil {
+		s.Failures++
+		s.LastError = err
+		result = 0
+	} else {
+		s.Successes++
+		s.LastSuccess = end
+	}
+	s.Score += healthWeight * (result - s.Score)
+	if latency := end.Sub(start); s.Successes+s.Failures == 1 {
+		s.Latency = latency
+	} else {
+		s.Latency += time.Duration(healthWeight * float64(latency-s.Latency))
+	}
+	return ips, err
+}
+
+// Stats returns a snapshot of the source's health
+func (t *Tracked) Stats() SourceStats {
+	t.health.mu.Lock()
+	defer t.health.mu.Unlock()
+	return t.stats
+}
+
+// Score returns the source's current health score
+func (t *Tracked) Score() float64 {
+	return t.Stats().Score
+}
@@ -0,0 +1,86 @@
+package ipdetect
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func staticSource(ip string) Source {
+	return SourceFunc(func(ctx context.Context) ([]net.IP, error) {
+		return []net.IP{net.ParseIP(ip)}, nil
+	})
+}
+
+func failingSource(calls *int) Source {
+	return SourceFunc(func(ctx context.Context) ([]net.IP, error) {
+		*calls++
+		return nil, errors.New("unreachable")
+	})
+}
+
+func TestHealth(t *testing.T) {
+	ctx := context.Background()
+	h := NewHealth()
+	now := time.Unix(1000, 0)
+	h.now = func() time.Time {
+		now = now.Add(100 * time.Millisecond)
+		return now
+	}
+	var calls int
+	good := h.Track("good", staticSource("203.0.113.1"))
+	bad := h.Track("bad", failingSource(&calls))
+	for i := 0; i < 4; i++ {
+		good.Detect(ctx)
+		bad.Detect(ctx)
+	}
+	stats := h.Stats()
+	if stats[0].Name != "good" || stats[0].Successes != 4 || stats[0].Score != 1 {
+		t.Errorf("unexpected stats for good source: %+v", stats[0])
+	}
+	if stats[0].Latency != 100*time.Millisecond {
+		t.Errorf("unexpected latency %v", stats[0].Latency)
+	}
+	if stats[1].Failures != 4 || stats[1].LastError == nil || stats[1].Score >= unhealthyScore {
+		t.Errorf("unexpected stats for bad source: %+v", stats[1])
+	}
+}
+
+func TestFallbackPrefersHealthySources(t *testing.T) {
+	ctx := context.Background()
+	h := NewHealth()
+	var calls int
+	bad := h.Track("bad", failingSource(&calls))
+	good := h.Track("good", staticSource("203.0.113.1"))
+	src := Fallback(bad, good)
+	for i := 0; i < 3; i++ {
+		ips, err := src.Detect(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ips[0].Equal(net.ParseIP("203.0.113.1")) {
+			t.Fatalf("unexpected address %v", ips)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected the failing source to be tried once before being de-prioritized, got %d calls", calls)
+	}
+}
+
+func TestConsensus(t *testing.T) {
+	ctx := context.Background()
+	var calls int
+	src := Consensus(2, staticSource("203.0.113.1"), staticSource("203.0.113.9"), staticSource("203.0.113.1"), failingSource(&calls))
+	ips, err := src.Detect(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ips) != 1 || !ips[0].Equal(net.ParseIP("203.0.113.1")) {
+		t.Errorf("unexpected consensus %v", ips)
+	}
+	if _, err := Consensus(3, staticSource("203.0.113.1"), staticSource("203.0.113.9")).Detect(ctx); err == nil {
+		t.Error("expected an error without a quorum")
+	}
+}
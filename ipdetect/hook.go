@@ -0,0 +1,89 @@
+package ipdetect
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"strings"
+)
+
+// Hook is a Source that reads the address handed out by the ISP from the environment and arguments
+// passed to PPP and DHCP client hook scripts, so updates can run exactly when a new lease is obtained.
+//
+// The following conventions are recognized:
+//
+//	pppd ip-up:           IPLOCAL, or the 4th argument (interface tty speed local-ip remote-ip ipparam)
+//	dhclient exit hooks:  new_ip_address and new_ip6_address
+//	udhcpc scripts:       ip
+type Hook struct {
+	args   []string
+	getenv func(string) string
+}
+
+// NewHook constructs a hook source from the arguments passed to the hook script (excluding the program name)
+func NewHook(args []string) *Hook {
+	return &Hook{
+		args:   args,
+		getenv: os.Getenv,
+	}
+}
+
+// Interface returns the name of the interface the hook was invoked for, if known
+func (h *Hook) Interface() string {
+	for _, key := range []string{"IFNAME", "interface"} {
+		if v := h.getenv(key); v != "" {
+			return v
+		}
+	}
+	if len(h.args) > 0 && h.getenv("IPLOCAL") != "" {
+		return h.args[0]
+	}
+	return ""
+}
+
+// Triggered reports whether the hook was invoked for an event that hands out an address.
+// dhclient and udhcpc also call their hooks on expiry, release and failure; updating DNS then would be wrong.
+// An unknown or missing event is not a trigger.
+func (h *Hook) Triggered() bool {
+	reason := strings.ToUpper(h.getenv("reason"))
+	if reason == "" {
+		if h.pppd() {
+			// pppd only runs ip-up once the link has an address
+			return true
+		}
+		if len(h.args) > 0 {
+			// udhcpc passes the event as its first argument, without setting ip on deconfig and leasefail
+			reason = strings.ToUpper(h.args[0])
+		}
+	}
+	switch reason {
+	case "BOUND", "RENEW", "REBIND", "REBOOT", "BOUND6", "RENEW6", "REBIND6", "REBOOT6":
+		return true
+	}
+	return false
+}
+
+// pppd reports whether the hook was invoked as a pppd ip-up script
+func (h *Hook) pppd() bool {
+	return h.getenv("IPLOCAL") != "" || (len(h.args) >= 4 && net.ParseIP(h.args[3]) != nil)
+}
+
+// Detect returns the addresses found in the hook environment
+func (h *Hook) Detect(ctx context.Context) ([]net.IP, error) {
+	var ips []net.IP
+	for _, key := range []string{"IPLOCAL", "new_ip_address", "new_ip6_address", "ip"} {
+		if ip := net.ParseIP(h.getenv(key)); ip != nil {
+			ips = appendUnique(ips, normalize(ip))
+		}
+	}
+	if len(ips) == 0 && len(h.args) >= 4 {
+		if ip := net.ParseIP(h.args[3]); ip != nil {
+			ips = append(ips, normalize(ip))
+		}
+	}
+	if len(ips) == 0 {
+		return nil, errors.New("ipdetect: hook: no address found in the hook environment or arguments")
+	}
+	return ips, nil
+}
@@ -0,0 +1,96 @@
+package ipdetect
+
+import (
+	"context"
+	"net"
+	"testing"
+)
+
+func TestHook(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		env       map[string]string
+		want      []net.IP
+		iface     string
+		triggered bool
+	}{
+		{
+			name:      "pppd",
+			args:      []string{"ppp0", "/dev/ttyUSB0", "115200", "203.0.113.7", "198.51.100.1", ""},
+			env:       map[string]string{"IFNAME": "ppp0", "IPLOCAL": "203.0.113.7"},
+			want:      []net.IP{net.ParseIP("203.0.113.7")},
+			iface:     "ppp0",
+			triggered: true,
+		},
+		{
+			name:      "pppd arguments only",
+			args:      []string{"ppp0", "/dev/ttyUSB0", "115200", "203.0.113.7", "198.51.100.1", ""},
+			env:       map[string]string{},
+			want:      []net.IP{net.ParseIP("203.0.113.7")},
+			triggered: true,
+		},
+		{
+			name:      "dhclient",
+			env:       map[string]string{"reason": "BOUND", "interface": "eth0", "new_ip_address": "203.0.113.7"},
+			want:      []net.IP{net.ParseIP("203.0.113.7")},
+			iface:     "eth0",
+			triggered: true,
+		},
+		{
+			name:  "dhclient expire",
+			env:   map[string]string{"reason": "EXPIRE", "interface": "eth0", "new_ip_address": "203.0.113.7"},
+			want:  []net.IP{net.ParseIP("203.0.113.7")},
+			iface: "eth0",
+		},
+		{
+			name:      "udhcpc",
+			args:      []string{"renew"},
+			env:       map[string]string{"interface": "eth0", "ip": "203.0.113.7"},
+			want:      []net.IP{net.ParseIP("203.0.113.7")},
+			iface:     "eth0",
+			triggered: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHook(tt.args)
+			h.getenv = func(key string) string { return tt.env[key] }
+			ips, err := h.Detect(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ips) != len(tt.want) || !ips[0].Equal(tt.want[0]) {
+				t.Errorf("got %v, want %v", ips, tt.want)
+			}
+			if h.Interface() != tt.iface {
+				t.Errorf("interface: got %q, want %q", h.Interface(), tt.iface)
+			}
+			if h.Triggered() != tt.triggered {
+				t.Errorf("triggered: got %v, want %v", h.Triggered(), tt.triggered)
+			}
+		})
+	}
+}
+
+func TestHookNotTriggered(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+	}{
+		{name: "udhcpc deconfig", args: []string{"deconfig"}, env: map[string]string{"interface": "eth0"}},
+		{name: "udhcpc leasefail", args: []string{"leasefail"}, env: map[string]string{"interface": "eth0"}},
+		{name: "unknown event", args: []string{"something"}, env: map[string]string{"ip": "203.0.113.7"}},
+		{name: "no event", env: map[string]string{"ip": "203.0.113.7"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHook(tt.args)
+			h.getenv = func(key string) string { return tt.env[key] }
+			if h.Triggered() {
+				t.Error("expected the hook not to be triggered")
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package ipdetect
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/justenwalker/ddns/internal/netbind"
+)
+
+// maxBodySize limits how much of a response body is read when extracting addresses
+const maxBodySize = 64 * 1024
+
+// HTTPOption sets HTTP source options
+type HTTPOption func(*HTTP)
+
+// HTTP is a Source that fetches a URL and extracts addresses from the response body.
+//
+// By default the whole body, trimmed of whitespace, must be a single address.
+// Use Regexp or JSONPath to extract addresses from router status pages or JSON APIs.
+type HTTP struct {
+	logger     Logger
+	httpClient HTTPRequester
+	binding    netbind.Binding
+	tlsConfig  *tls.Config
+	header     http.Header
+	limiter    *Limiter
+	url        string
+	regexp     *regexp.Regexp
+	jsonPath   []string
+}
+
+// HTTPLog enables logging using the given Logger
+func HTTPLog(l Logger) HTTPOption {
+	return func(h *HTTP) {
+		h.logger = l
+	}
+}
+
+// HTTPClient sets a custom HTTP client for the source.
+// the default uses http.DefaultClient.
+// BindInterface, BindAddress and TLSConfig are ignored when a custom client is set.
+func HTTPClient(hc HTTPRequester) HTTPOption {
+	return func(h *HTTP) {
+		h.httpClient = hc
+	}
+}
+
+// BindInterface sends requests from the named interface, so the detected address is the one of that connection
+func BindInterface(name string) HTTPOption {
+	return func(h *HTTP) {
+		h.binding.Interface = name
+	}
+}
+
+// BindAddress sends requests from the given local source address
+func BindAddress(ip net.IP) HTTPOption {
+	return func(h *HTTP) {
+		h.binding.Address = ip
+	}
+}
+
+// TLSConfig sets the TLS configuration used to connect to the URL,
+// e.g. to trust an internal CA or present a client certificate
+func TLSConfig(cfg *tls.Config) HTTPOption {
+	return func(h *HTTP) {
+		h.tlsConfig = cfg
+	}
+}
+
+// Header adds a static header to every request
+func Header(key, value string) HTTPOption {
+	return func(h *HTTP) {
+		h.header.Add(key, value)
+	}
+}
+
+// BasicAuth authenticates requests with HTTP basic authentication.
+// Clears the BearerToken option when used.
+func BasicAuth(username, password string) HTTPOption {
+	return func(h *HTTP) {
+		req := http.Request{Header: make(http.Header)}
+		req.SetBasicAuth(username, password)
+		h.header.Set("Authorization", req.Header.Get("Authorization"))
+	}
+}
+
+// BearerToken authenticates requests with a bearer token.
+// Clears the BasicAuth option when used.
+func BearerToken(token string) HTTPOption {
+	return func(h *HTTP) {
+		h.header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// Regexp extracts every match of re from the body.
+// If re has a capture group, the first group is used instead of the whole match.
+// Clears the JSONPath option when used.
+func Regexp(re *regexp.Regexp) HTTPOption {
+	return func(h *HTTP) {
+		h.regexp = re
+		h.jsonPath = nil
+	}
+}
+
+// JSONPath extracts the address from a dot-separated path into a JSON body, such as "ip" or "data.addresses.0".
+// The value at the path may be a string or an array of strings.
+// Clears the Regexp option when used.
+func JSONPath(path string) HTTPOption {
+	return func(h *HTTP) {
+		h.jsonPath = strings.Split(path, ".")
+		h.regexp = nil
+	}
+}
+
+// NewHTTP constructs an HTTP source for the given URL
+func NewHTTP(url string, options ...HTTPOption) *HTTP {
+	h := &HTTP{
+		url:    url,
+		header: make(http.Header),
+	}
+	for _, opt := range options {
+		opt(h)
+	}
+	if h.httpClient == nil {
+		h.httpClient = http.DefaultClient
+		if !h.binding.IsZero() || h.tlsConfig != nil {
+			t := http.DefaultTransport.(*http.Transport).Clone()
+			h.binding.Apply(t)
+			if h.tlsConfig != nil {
+				t.TLSClientConfig = h.tlsConfig
+			}
+			h.httpClient = &http.Client{Transport: t}
+		}
+	}
+	return h
+}
+
+func (h *HTTP) logf(format string, v ...interface{}) {
+	if h.logger != nil {
+		h.logger.Log(format, v...)
+	}
+}
+
+// Detect fetches the URL and extracts the addresses from the response
+func (h *HTTP) Detect(ctx context.Context) ([]net.IP, error) {
+	if h.limiter != nil {
+		if err := h.limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequest(http.MethodGet, h.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	for k, v := range h.header {
+		req.Header[k] = v
+	}
+	resp, err := h.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("ipdetect: %s returned %s", h.url, resp.Status)
+	}
+	ips, err := h.extract(body)
+	if err != nil {
+		return nil, fmt.Errorf("ipdetect: %s: %v", h.url, err)
+	}
+	h.logf("ipdetect: %s detected %v", h.url, ips)
+	return ips, nil
+}
+
+func (h *HTTP) extract(body []byte) ([]net.IP, error) {
+	var candidates []string
+	switch {
+	case h.regexp != nil:
+		for _, m := range h.regexp.FindAllStringSubmatch(string(body), -1) {
+			if len(m) > 1 {
+				candidates = append(candidates, m[1])
+			} else {
+				candidates = append(candidates, m[0])
+			}
+		}
+	case h.jsonPath != nil:
+		var err error
+		candidates, err = extractJSON(body, h.jsonPath)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		candidates = []string{string(body)}
+	}
+	var ips []net.IP
+	for _, c := range candidates {
+		ip := net.ParseIP(strings.TrimSpace(c))
+		if ip == nil {
+			h.logf("ipdetect: %s: ignoring %q: not an IP address", h.url, c)
+			continue
+		}
+		ips = appendUnique(ips, normalize(ip))
+	}
+	if len(ips) == 0 {
+		return nil, errors.New("no IP address found in response")
+	}
+	return ips, nil
+}
+
+func extractJSON(body []byte, path []string) ([]string, error) {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return nil, err
+	}
+	for i, key := range path {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			child, ok := node[key]
+			if !ok {
+				return nil, fmt.Errorf("json path %q: key not found", strings.Join(path[:i+1], "."))
+			}
+			v = child
+		case []interface{}:
+			idx, err := strconv.Atoi(key)
+			if err != nil || idx < 0 || idx >= len(node) {
+				return nil, fmt.Errorf("json path %q: invalid array index", strings.Join(path[:i+1], "."))
+			}
+			v = node[idx]
+		default:
+			return nil, fmt.Errorf("json path %q: not an object or array", strings.Join(path[:i], "."))
+		}
+	}
+	switch val := v.(type) {
+	case string:
+		return []string{val}, nil
+	case []interface{}:
+		var out []string
+		for _, item := range val {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("json path %q: value is not a string or array of strings", strings.Join(path, "."))
+}
@@ -0,0 +1,114 @@
+package ipdetect_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/justenwalker/ddns/ipdetect"
+)
+
+func serve(body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, body)
+	}))
+}
+
+func TestHTTPDetect(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		options []ipdetect.HTTPOption
+		want    []net.IP
+	}{
+		{
+			name: "plain",
+			body: "203.0.113.7\n",
+			want: []net.IP{net.ParseIP("203.0.113.7")},
+		},
+		{
+			name:    "regexp",
+			body:    "<td>WAN IP</td><td>203.0.113.7</td><td>WAN IPv6</td><td>2001:db8::7</td>",
+			options: []ipdetect.HTTPOption{ipdetect.Regexp(regexp.MustCompile(`WAN IP(?:v6)?</td><td>([^<]+)<`))},
+			want:    []net.IP{net.ParseIP("203.0.113.7"), net.ParseIP("2001:db8::7")},
+		},
+		{
+			name:    "json",
+			body:    `{"ip":"203.0.113.7"}`,
+			options: []ipdetect.HTTPOption{ipdetect.JSONPath("ip")},
+			want:    []net.IP{net.ParseIP("203.0.113.7")},
+		},
+		{
+			name:    "json nested array",
+			body:    `{"data":{"addresses":["2001:db8::7","203.0.113.7"]}}`,
+			options: []ipdetect.HTTPOption{ipdetect.JSONPath("data.addresses")},
+			want:    []net.IP{net.ParseIP("2001:db8::7"), net.ParseIP("203.0.113.7")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := serve(tt.body)
+			defer srv.Close()
+			ips, err := ipdetect.NewHTTP(srv.URL, tt.options...).Detect(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ips) != len(tt.want) {
+				t.Fatalf("got %v, want %v", ips, tt.want)
+			}
+			for i := range ips {
+				if !ips[i].Equal(tt.want[i]) {
+					t.Fatalf("got %v, want %v", ips, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestHTTPDetectNoAddress(t *testing.T) {
+	srv := serve(`{"ip":"unknown"}`)
+	defer srv.Close()
+	if _, err := ipdetect.NewHTTP(srv.URL, ipdetect.JSONPath("ip")).Detect(context.Background()); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestHTTPDetectAuthenticated(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "admin" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("X-Router") != "home" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, "203.0.113.7")
+	}))
+	defer srv.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+
+	src := ipdetect.NewHTTP(srv.URL,
+		ipdetect.TLSConfig(&tls.Config{RootCAs: pool}),
+		ipdetect.BasicAuth("admin", "secret"),
+		ipdetect.Header("X-Router", "home"),
+	)
+	ips, err := src.Detect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ips) != 1 || !ips[0].Equal(net.ParseIP("203.0.113.7")) {
+		t.Errorf("unexpected addresses %v", ips)
+	}
+
+	if _, err := ipdetect.NewHTTP(srv.URL, ipdetect.TLSConfig(&tls.Config{RootCAs: pool})).Detect(context.Background()); err == nil {
+		t.Error("expected an unauthenticated request to fail")
+	}
+}
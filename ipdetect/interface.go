@@ -0,0 +1,99 @@
+package ipdetect
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// InterfaceOption sets interface source options
+type InterfaceOption func(*Interface)
+
+// IPv6Selection sets how the IPv6 address is chosen among those on the interface; the default is PreferStable
+func IPv6Selection(sel IPv6Selector) InterfaceOption {
+	return func(i *Interface) {
+		i.selector = sel
+	}
+}
+
+// InterfaceIPv4 enables/disables returning the IPv4 address of the interface; the default is enabled
+func InterfaceIPv4(enabled bool) InterfaceOption {
+	return func(i *Interface) {
+		i.ipv4 = enabled
+	}
+}
+
+// InterfaceIPv6 enables/disables returning the IPv6 address of the interface; the default is enabled
+func InterfaceIPv6(enabled bool) InterfaceOption {
+	return func(i *Interface) {
+		i.ipv6 = enabled
+	}
+}
+
+// Interface is a Source that reads the addresses assigned to a local interface.
+// It is the usual way to detect IPv6 addresses, which are not translated by the router.
+// At most one global IPv4 address and one IPv6 address (chosen by the IPv6Selector) are returned.
+//
+// Temporary addresses are told apart from stable ones on Linux only. Elsewhere, only EUI-64 addresses are
+// considered, as they are the only ones known not to be temporary.
+type Interface struct {
+	name     string
+	ipv4     bool
+	ipv6     bool
+	selector IPv6Selector
+}
+
+// NewInterface constructs a source for the named interface
+func NewInterface(name string, options ...InterfaceOption) *Interface {
+	i := &Interface{
+		name: name,
+		ipv4: true,
+		ipv6: true,
+	}
+	for _, opt := range options {
+		opt(i)
+	}
+	return i
+}
+
+// Detect returns the addresses of the interface
+func (i *Interface) Detect(ctx context.Context) ([]net.IP, error) {
+	ifi, err := net.InterfaceByName(i.name)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	flags, known := ipv6Flags(i.name)
+	var ips []net.IP
+	var v6 []AddrInfo
+	for _, a := range addrs {
+		ipnet, ok := a.(*net.IPNet)
+		if !ok || !ipnet.IP.IsGlobalUnicast() {
+			continue
+		}
+		if ipv4 := ipnet.IP.To4(); ipv4 != nil {
+			if i.ipv4 && len(ips) == 0 {
+				ips = append(ips, ipv4)
+			}
+			continue
+		}
+		info := flags[ipnet.IP.String()]
+		info.IP = ipnet.IP
+		v6 = append(v6, info)
+	}
+	if !known {
+		v6 = onlyEUI64(v6)
+	}
+	if i.ipv6 {
+		if ip := i.selector.Select(v6); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("ipdetect: interface %s has no usable addresses", i.name)
+	}
+	return ips, nil
+}
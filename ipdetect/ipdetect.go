@@ -0,0 +1,50 @@
+// Package ipdetect discovers the IP addresses that should be published for this host
+package ipdetect // import "github.com/justenwalker/ddns/ipdetect"
+
+import (
+	"context"
+	"net"
+
+	"github.com/justenwalker/ddns/internal/httpreq"
+)
+
+// Source detects IP addresses
+type Source interface {
+	// Detect returns the detected addresses. IPv4 addresses are returned in their 4-byte form.
+	Detect(ctx context.Context) ([]net.IP, error)
+}
+
+// SourceFunc adapts a function to the Source interface
+type SourceFunc func(ctx context.Context) ([]net.IP, error)
+
+// Detect calls f(ctx)
+func (f SourceFunc) Detect(ctx context.Context) ([]net.IP, error) {
+	return f(ctx)
+}
+
+// Logger for printing debug logs from this package
+type Logger interface {
+	Log(format string, v ...interface{})
+}
+
+// HTTPRequester makes http requests and returns responses.
+// *http.Client implicitly implements HTTPRequester and can be provided wherever this interface is requested.
+type HTTPRequester = httpreq.Requester
+
+// normalize converts IPv4 addresses to their 4-byte form
+func normalize(ip net.IP) net.IP {
+	if ipv4 := ip.To4(); ipv4 != nil {
+		return ipv4
+	}
+	return ip
+}
+
+// appendUnique appends ip to ips if it is not already present
+func appendUnique(ips []net.IP, ip net.IP) []net.IP {
+	for _, existing := range ips {
+		if existing.Equal(ip) {
+			return ips
+		}
+	}
+	return append(ips, ip)
+}
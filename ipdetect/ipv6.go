@@ -0,0 +1,118 @@
+package ipdetect
+
+import (
+	"bytes"
+	"net"
+)
+
+// IPv6Strategy selects which of several IPv6 addresses on an interface is published
+type IPv6Strategy int
+
+const (
+	// PreferStable publishes a stable address: static, EUI-64 or stable-privacy ("secured") addresses,
+	// preferring EUI-64 addresses only when no other stable address exists
+	PreferStable IPv6Strategy = iota
+	// PreferEUI64 publishes an EUI-64 (MAC-derived) address if one exists, falling back to other stable addresses
+	PreferEUI64
+)
+
+func (s IPv6Strategy) String() string {
+	switch s {
+	case PreferStable:
+		return "stable"
+	case PreferEUI64:
+		return "eui64"
+	}
+	return "unknown"
+}
+
+// AddrInfo is a local IPv6 address with the properties used to select it
+type AddrInfo struct {
+	IP net.IP
+	// Temporary is true for RFC 4941 privacy addresses, which are never published
+	Temporary bool
+	// Deprecated is true once the address' preferred lifetime has expired
+	Deprecated bool
+}
+
+// EUI64 returns true if the interface identifier is derived from a MAC address (contains ff:fe in the middle)
+func (a AddrInfo) EUI64() bool {
+	ip := a.IP.To16()
+	return ip != nil && ip[11] == 0xff && ip[12] == 0xfe
+}
+
+// IPv6Selector chooses the IPv6 address to publish
+type IPv6Selector struct {
+	Strategy IPv6Strategy
+	// InterfaceID pins the published interface identifier (the low 64 bits), given as an address such as ::1234:5678.
+	// The address with that identifier is published if present; otherwise the identifier is combined with the
+	// prefix of the selected address, which allows publishing a host behind this one on the same prefix.
+	InterfaceID net.IP
+}
+
+// Select returns the address to publish, or nil if there is no suitable address.
+// Temporary, deprecated, link-local, unique-local and IPv4 addresses are never selected.
+func (s IPv6Selector) Select(addrs []AddrInfo) net.IP {
+	var candidates []AddrInfo
+	for _, a := range addrs {
+		if a.IP.To4() != nil || a.IP.To16() == nil || a.Temporary || a.Deprecated {
+			continue
+		}
+		if !a.IP.IsGlobalUnicast() || isULA(a.IP) {
+			continue
+		}
+		candidates = append(candidates, a)
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	if iid := s.InterfaceID.To16(); iid != nil {
+		for _, a := range candidates {
+			if bytes.Equal(a.IP.To16()[8:], iid[8:]) {
+				return a.IP
+			}
+		}
+	}
+	best := candidates[0]
+	for _, a := range candidates[1:] {
+		if s.better(a, best) {
+			best = a
+		}
+	}
+	if iid := s.InterfaceID.To16(); iid != nil {
+		ip := make(net.IP, net.IPv6len)
+		copy(ip, best.IP.To16()[:8])
+		copy(ip[8:], iid[8:])
+		return ip
+	}
+	return best.IP
+}
+
+// better returns true if a should be preferred over b
+func (s IPv6Selector) better(a, b AddrInfo) bool {
+	if a.EUI64() == b.EUI64() {
+		return false
+	}
+	if s.Strategy == PreferEUI64 {
+		return a.EUI64()
+	}
+	return !a.EUI64()
+}
+
+// onlyEUI64 returns the EUI-64 addresses, for platforms where the flags of the addresses are unknown:
+// a temporary address could otherwise be published as a stable one
+func onlyEUI64(addrs []AddrInfo) []AddrInfo {
+	var eui64 []AddrInfo
+	for _, a := range addrs {
+		if a.EUI64() {
+			eui64 = append(eui64, a)
+		}
+	}
+	return eui64
+}
+
+// isULA returns true for unique local addresses (fc00::/7), which are not reachable from the internet
+func isULA(ip net.IP) bool {
+	ip = ip.To16()
+	return ip != nil && ip[0]&0xfe == 0xfc
+}
@@ -0,0 +1,53 @@
+package ipdetect
+
+import (
+	"bufio"
+	"encoding/hex"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Address flags from linux/if_addr.h. /proc/net/if_inet6 only reports the low 8 bits.
+const (
+	ifaFTemporary  = 0x01
+	ifaFDeprecated = 0x20
+)
+
+// ipv6Flags reads the flags of the interface's IPv6 addresses from /proc/net/if_inet6, keyed by address.
+// It returns false if the flags cannot be read.
+func ipv6Flags(iface string) (map[string]AddrInfo, bool) {
+	f, err := os.Open("/proc/net/if_inet6")
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+	return parseIfInet6(bufio.NewScanner(f), iface), true
+}
+
+// parseIfInet6 parses lines of the form "20010db8000000000000000000000001 02 40 00 80 eth0"
+func parseIfInet6(s *bufio.Scanner, iface string) map[string]AddrInfo {
+	infos := make(map[string]AddrInfo)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) != 6 || fields[5] != iface {
+			continue
+		}
+		b, err := hex.DecodeString(fields[0])
+		if err != nil || len(b) != net.IPv6len {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[4], 16, 8)
+		if err != nil {
+			continue
+		}
+		ip := net.IP(b)
+		infos[ip.String()] = AddrInfo{
+			IP:         ip,
+			Temporary:  flags&ifaFTemporary != 0,
+			Deprecated: flags&ifaFDeprecated != 0,
+		}
+	}
+	return infos
+}
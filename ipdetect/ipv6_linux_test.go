@@ -0,0 +1,20 @@
+package ipdetect
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestParseIfInet6(t *testing.T) {
+	proc := "20010db8000000000000000000000001 02 40 00 01 eth0\n" +
+		"20010db8000000000000000000000002 02 40 00 80 eth0\n" +
+		"fe800000000000000000000000000001 03 40 20 80 wlan0\n"
+	infos := parseIfInet6(bufio.NewScanner(strings.NewReader(proc)), "eth0")
+	if len(infos) != 2 {
+		t.Fatalf("expected 2 addresses, got %v", infos)
+	}
+	if !infos["2001:db8::1"].Temporary || infos["2001:db8::2"].Temporary {
+		t.Errorf("unexpected flags: %+v", infos)
+	}
+}
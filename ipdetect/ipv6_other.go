@@ -0,0 +1,8 @@
+//go:build !linux
+
+package ipdetect
+
+// ipv6Flags is not available on this platform, so temporary addresses cannot be told apart from stable ones
+func ipv6Flags(iface string) (map[string]AddrInfo, bool) {
+	return nil, false
+}
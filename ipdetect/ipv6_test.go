@@ -0,0 +1,51 @@
+package ipdetect
+
+import (
+	"net"
+	"testing"
+)
+
+func TestIPv6Selector(t *testing.T) {
+	addrs := []AddrInfo{
+		{IP: net.ParseIP("fe80::1")},
+		{IP: net.ParseIP("fd00::1")},
+		{IP: net.ParseIP("2001:db8::aaaa:bbbb:cccc:dddd"), Temporary: true},
+		{IP: net.ParseIP("2001:db8::0211:22ff:fe33:4455")},
+		{IP: net.ParseIP("2001:db8::1234:5678:9abc:def0")},
+		{IP: net.ParseIP("2001:db8::9999"), Deprecated: true},
+	}
+	tests := []struct {
+		name string
+		sel  IPv6Selector
+		want string
+	}{
+		{name: "stable", sel: IPv6Selector{}, want: "2001:db8::1234:5678:9abc:def0"},
+		{name: "eui64", sel: IPv6Selector{Strategy: PreferEUI64}, want: "2001:db8::211:22ff:fe33:4455"},
+		{name: "pinned present", sel: IPv6Selector{InterfaceID: net.ParseIP("::211:22ff:fe33:4455")}, want: "2001:db8::211:22ff:fe33:4455"},
+		{name: "pinned synthesized", sel: IPv6Selector{InterfaceID: net.ParseIP("::10")}, want: "2001:db8::10"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.sel.Select(addrs); !got.Equal(net.ParseIP(tt.want)) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if got := (IPv6Selector{}).Select(addrs[:3]); got != nil {
+		t.Errorf("expected no address to be selected from temporary and local addresses, got %v", got)
+	}
+}
+
+func TestOnlyEUI64(t *testing.T) {
+	// without flags, a temporary address looks like any other
+	addrs := []AddrInfo{
+		{IP: net.ParseIP("2001:db8::aaaa:bbbb:cccc:dddd")},
+		{IP: net.ParseIP("2001:db8::0211:22ff:fe33:4455")},
+	}
+	if got := (IPv6Selector{}).Select(onlyEUI64(addrs)); !got.Equal(net.ParseIP("2001:db8::211:22ff:fe33:4455")) {
+		t.Errorf("expected the EUI-64 address, got %v", got)
+	}
+	if got := (IPv6Selector{}).Select(onlyEUI64(addrs[:1])); got != nil {
+		t.Errorf("expected no address without an EUI-64 one, got %v", got)
+	}
+}
@@ -0,0 +1,127 @@
+package ipdetect
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned when a request is not allowed before the context deadline
+var ErrRateLimited = errors.New("ipdetect: rate limited")
+
+// Limiter is a token bucket that limits how often a source may be queried.
+// It allows bursts of up to burst requests, then one request per interval.
+// A burst of 1 enforces a strict minimum interval between requests.
+//
+// A Limiter is safe for concurrent use and may be shared by several sources that query the same service.
+type Limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	burst    int
+	tokens   float64
+	last     time.Time
+	now      func() time.Time
+}
+
+// NewLimiter creates a limiter allowing one request per interval with the given burst
+func NewLimiter(interval time.Duration, burst int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{
+		interval: interval,
+		burst:    burst,
+		tokens:   float64(burst),
+		now:      time.Now,
+	}
+}
+
+// refill adds the tokens accumulated since the last call. It must be called with l.mu held.
+func (l *Limiter) refill(now time.Time) {
+	if !l.last.IsZero() && l.interval > 0 {
+		l.tokens += float64(now.Sub(l.last)) / float64(l.interval)
+	} else if l.interval <= 0 {
+		l.tokens = float64(l.burst)
+	}
+	if l.tokens > float64(l.burst) {
+		l.tokens = float64(l.burst)
+	}
+	l.last = now
+}
+
+// reserve takes a token and returns how long the caller must wait before using it
+func (l *Limiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill(l.now())
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens * float64(l.interval))
+}
+
+// cancel returns a token taken by reserve
+func (l *Limiter) cancel() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens++
+}
+
+// Allow reports whether a request may be made now, consuming a token if so
+func (l *Limiter) Allow() bool {
+	if l.reserve() > 0 {
+		l.cancel()
+		return false
+	}
+	return true
+}
+
+// Wait blocks until a request is allowed.
+// It returns ErrRateLimited without waiting if the request would not be allowed before the context deadline.
+func (l *Limiter) Wait(ctx context.Context) error {
+	delay := l.reserve()
+	if delay == 0 {
+		return nil
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		l.cancel()
+		return ErrRateLimited
+	}
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		l.cancel()
+		return ctx.Err()
+	}
+}
+
+// RateLimit limits how often the HTTP source sends requests.
+// The limiter may be shared with other sources querying the same service.
+func RateLimit(l *Limiter) HTTPOption {
+	return func(h *HTTP) {
+		h.limiter = l
+	}
+}
+
+type limited struct {
+	src     Source
+	limiter *Limiter
+}
+
+// Limit wraps src so that it is queried no more often than the limiter allows
+func Limit(src Source, l *Limiter) Source {
+	return limited{src: src, limiter: l}
+}
+
+func (s limited) Detect(ctx context.Context) ([]net.IP, error) {
+	if err := s.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return s.src.Detect(ctx)
+}
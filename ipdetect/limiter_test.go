@@ -0,0 +1,38 @@
+package ipdetect
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Unix(1000, 0)
+	l := NewLimiter(time.Minute, 2)
+	l.now = func() time.Time { return now }
+
+	if !l.Allow() || !l.Allow() {
+		t.Fatal("expected the burst to be allowed")
+	}
+	if l.Allow() {
+		t.Fatal("expected the third request to be limited")
+	}
+	now = now.Add(30 * time.Second)
+	if l.Allow() {
+		t.Fatal("expected a request before the interval to be limited")
+	}
+	now = now.Add(30 * time.Second)
+	if !l.Allow() {
+		t.Fatal("expected a request after the interval to be allowed")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := l.Wait(ctx); err != ErrRateLimited {
+		t.Fatalf("expected ErrRateLimited, got %v", err)
+	}
+	now = now.Add(time.Minute)
+	if err := l.Wait(ctx); err != nil {
+		t.Fatalf("expected the request to be allowed, got %v", err)
+	}
+}
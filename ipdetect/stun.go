@@ -0,0 +1,209 @@
+package ipdetect
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// STUN message constants from RFC 5389
+const (
+	stunBindingRequest  = 0x0001
+	stunBindingSuccess  = 0x0101
+	stunMagicCookie     = 0x2112A442
+	stunHeaderSize      = 20
+	stunMappedAddress   = 0x0001
+	stunXORMappedAddr   = 0x0020
+	stunDefaultPort     = "3478"
+	stunInitialInterval = 500 * time.Millisecond
+)
+
+// DefaultSTUNServer is a public STUN server
+const DefaultSTUNServer = "stun.l.google.com:19302"
+
+// STUNOption sets STUN source options
+type STUNOption func(*STUN)
+
+// STUN is a Source that asks a STUN server (RFC 5389) which address the host's UDP traffic comes from.
+// It needs no privileges and exchanges under a hundred bytes per query, which suits phones and metered links.
+type STUN struct {
+	logger  Logger
+	server  string
+	network string
+	timeout time.Duration
+}
+
+// STUNLog enables logging using the given Logger
+func STUNLog(l Logger) STUNOption {
+	return func(s *STUN) {
+		s.logger = l
+	}
+}
+
+// STUNNetwork sets the network used to reach the server, "udp4" or "udp6"; the default is "udp4".
+// The detected address is of the same family.
+func STUNNetwork(network string) STUNOption {
+	return func(s *STUN) {
+		s.network = network
+	}
+}
+
+// STUNTimeout bounds a query including retransmissions; the default is 5 seconds
+func STUNTimeout(d time.Duration) STUNOption {
+	return func(s *STUN) {
+		s.timeout = d
+	}
+}
+
+// NewSTUN constructs a STUN source for the server at "host:port". The port defaults to 3478.
+func NewSTUN(server string, options ...STUNOption) *STUN {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, stunDefaultPort)
+	}
+	s := &STUN{
+		server:  server,
+		network: "udp4",
+		timeout: 5 * time.Second,
+	}
+	for _, opt := range options {
+		opt(s)
+	}
+	return s
+}
+
+func (s *STUN) logf(format string, v ...interface{}) {
+	if s.logger != nil {
+		s.logger.Log(format, v...)
+	}
+}
+
+// Detect sends a binding request, retransmitting it with a doubling interval until a response arrives
+func (s *STUN) Detect(ctx context.Context) ([]net.IP, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, s.network, s.server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	req := make([]byte, stunHeaderSize)
+	binary.BigEndian.PutUint16(req[0:], stunBindingRequest)
+	binary.BigEndian.PutUint32(req[4:], stunMagicCookie)
+	if _, err := rand.Read(req[8:stunHeaderSize]); err != nil {
+		return nil, err
+	}
+	deadline, _ := ctx.Deadline()
+	buf := make([]byte, 1500)
+	for interval := stunInitialInterval; ; interval *= 2 {
+		if _, err := conn.Write(req); err != nil {
+			return nil, err
+		}
+		wait := time.Now().Add(interval)
+		if wait.After(deadline) {
+			wait = deadline
+		}
+		conn.SetReadDeadline(wait)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				var ne net.Error
+				if !errors.As(err, &ne) || !ne.Timeout() {
+					return nil, err
+				}
+				break
+			}
+			ip, err := parseSTUN(buf[:n], req[8:stunHeaderSize])
+			if err != nil {
+				s.logf("ipdetect: stun %s: ignoring response: %v", s.server, err)
+				continue
+			}
+			s.logf("ipdetect: stun %s detected %v", s.server, ip)
+			return []net.IP{ip}, nil
+		}
+		if !time.Now().Before(deadline) {
+			return nil, fmt.Errorf("ipdetect: stun %s: no response: %w", s.server, ctx.Err())
+		}
+	}
+}
+
+// parseSTUN returns the mapped address of a binding success response for the transaction txn,
+// preferring XOR-MAPPED-ADDRESS over the legacy MAPPED-ADDRESS
+func parseSTUN(msg, txn []byte) (net.IP, error) {
+	if len(msg) < stunHeaderSize {
+		return nil, errors.New("message too short")
+	}
+	if t := binary.BigEndian.Uint16(msg[0:]); t != stunBindingSuccess {
+		return nil, fmt.Errorf("unexpected message type %#04x", t)
+	}
+	if binary.BigEndian.Uint32(msg[4:]) != stunMagicCookie || string(msg[8:stunHeaderSize]) != string(txn) {
+		return nil, errors.New("transaction mismatch")
+	}
+	length := int(binary.BigEndian.Uint16(msg[2:]))
+	if stunHeaderSize+length > len(msg) {
+		return nil, errors.New("truncated message")
+	}
+	var mapped net.IP
+	attrs := msg[stunHeaderSize : stunHeaderSize+length]
+	for len(attrs) >= 4 {
+		typ := binary.BigEndian.Uint16(attrs[0:])
+		size := int(binary.BigEndian.Uint16(attrs[2:]))
+		if 4+size > len(attrs) {
+			return nil, errors.New("truncated attribute")
+		}
+		value := attrs[4 : 4+size]
+		switch typ {
+		case stunXORMappedAddr:
+			ip, err := stunAddress(value, msg[4:stunHeaderSize])
+			if err != nil {
+				return nil, err
+			}
+			return ip, nil
+		case stunMappedAddress:
+			if ip, err := stunAddress(value, nil); err == nil {
+				mapped = ip
+			}
+		}
+		// attributes are padded to a multiple of 4 bytes
+		next := 4 + (size+3)&^3
+		if next > len(attrs) {
+			break
+		}
+		attrs = attrs[next:]
+	}
+	if mapped == nil {
+		return nil, errors.New("no mapped address in response")
+	}
+	return mapped, nil
+}
+
+// stunAddress decodes an address attribute, XORed with key (the magic cookie and transaction ID) if it is set
+func stunAddress(value, key []byte) (net.IP, error) {
+	if len(value) < 4 {
+		return nil, errors.New("address attribute too short")
+	}
+	var size int
+	switch value[1] {
+	case 0x01:
+		size = net.IPv4len
+	case 0x02:
+		size = net.IPv6len
+	default:
+		return nil, fmt.Errorf("unknown address family %#02x", value[1])
+	}
+	if len(value) < 4+size {
+		return nil, errors.New("address attribute too short")
+	}
+	ip := make(net.IP, size)
+	copy(ip, value[4:4+size])
+	for i := range key {
+		if i < size {
+			ip[i] ^= key[i]
+		}
+	}
+	return normalize(ip), nil
+}
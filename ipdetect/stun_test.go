@@ -0,0 +1,80 @@
+package ipdetect
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+// stunResponse builds a binding success response for the request req carrying addr as XOR-MAPPED-ADDRESS
+func stunResponse(req []byte, addr net.IP) []byte {
+	ip := addr.To4()
+	family := byte(0x01)
+	if ip == nil {
+		ip = addr.To16()
+		family = 0x02
+	}
+	attr := make([]byte, 8+len(ip))
+	binary.BigEndian.PutUint16(attr[0:], stunXORMappedAddr)
+	binary.BigEndian.PutUint16(attr[2:], uint16(4+len(ip)))
+	attr[5] = family
+	for i := range ip {
+		attr[8+i] = ip[i] ^ req[4+i]
+	}
+	msg := make([]byte, stunHeaderSize, stunHeaderSize+len(attr))
+	copy(msg, req[:stunHeaderSize])
+	binary.BigEndian.PutUint16(msg[0:], stunBindingSuccess)
+	binary.BigEndian.PutUint16(msg[2:], uint16(len(attr)))
+	return append(msg, attr...)
+}
+
+func TestParseSTUN(t *testing.T) {
+	req := make([]byte, stunHeaderSize)
+	binary.BigEndian.PutUint32(req[4:], stunMagicCookie)
+	copy(req[8:], "transaction!")
+	for _, want := range []string{"203.0.113.7", "2001:db8::7"} {
+		ip, err := parseSTUN(stunResponse(req, net.ParseIP(want)), req[8:])
+		if err != nil {
+			t.Fatalf("%s: %v", want, err)
+		}
+		if !ip.Equal(net.ParseIP(want)) {
+			t.Errorf("got %v, want %s", ip, want)
+		}
+	}
+	other := append([]byte(nil), req...)
+	copy(other[8:], "someoneelse!")
+	if _, err := parseSTUN(stunResponse(other, net.ParseIP("203.0.113.7")), req[8:]); err == nil {
+		t.Error("expected a response to another transaction to be rejected")
+	}
+}
+
+func TestSTUNDetect(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	go func() {
+		buf := make([]byte, 1500)
+		for dropped := false; ; dropped = true {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			// drop the first request to exercise retransmission
+			if !dropped || n < stunHeaderSize {
+				continue
+			}
+			conn.WriteTo(stunResponse(buf[:n], net.ParseIP("203.0.113.7")), addr)
+		}
+	}()
+	ips, err := NewSTUN(conn.LocalAddr().String(), STUNTimeout(5*time.Second)).Detect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ips) != 1 || !ips[0].Equal(net.ParseIP("203.0.113.7")) || len(ips[0]) != net.IPv4len {
+		t.Errorf("got %v", ips)
+	}
+}
@@ -1,4 +1,105 @@
+// Package ipify detects the public IP address using the ipify.org API
 package ipify // import "github.com/justenwalker/ddns/ipify"
 
+import (
+	"context"
+	"net"
+
+	"github.com/justenwalker/ddns/ipdetect"
+)
+
+const (
+	ipv4Endpoint = "https://api.ipify.org"
+	ipv6Endpoint = "https://api6.ipify.org"
+)
+
+// Logger for printing debug logs from this package
+type Logger interface {
+	Log(format string, v ...interface{})
+}
+
+// Option sets IPify options
+type Option func(*IPify)
+
+// IPify is an ipdetect.Source that asks api.ipify.org (IPv4) and api6.ipify.org (IPv6) for the public address
 type IPify struct {
+	logger     Logger
+	httpClient ipdetect.HTTPRequester
+	ipv4       bool
+	ipv6       bool
+	v4, v6     ipdetect.Source
+}
+
+// Log enables logging using the given Logger
+func Log(l Logger) Option {
+	return func(c *IPify) {
+		c.logger = l
+	}
+}
+
+// IPv4 enables/disables detecting the IPv4 address; the default is enabled
+func IPv4(enabled bool) Option {
+	return func(c *IPify) {
+		c.ipv4 = enabled
+	}
+}
+
+// IPv6 enables/disables detecting the IPv6 address; the default is disabled
+func IPv6(enabled bool) Option {
+	return func(c *IPify) {
+		c.ipv6 = enabled
+	}
+}
+
+// HTTPClient sets a custom HTTP client
+// the default uses http.DefaultClient
+func HTTPClient(hc ipdetect.HTTPRequester) Option {
+	return func(c *IPify) {
+		c.httpClient = hc
+	}
+}
+
+// New constructs an ipify source
+func New(options ...Option) *IPify {
+	c := &IPify{ipv4: true}
+	for _, opt := range options {
+		opt(c)
+	}
+	var opts []ipdetect.HTTPOption
+	if c.logger != nil {
+		opts = append(opts, ipdetect.HTTPLog(c.logger))
+	}
+	if c.httpClient != nil {
+		opts = append(opts, ipdetect.HTTPClient(c.httpClient))
+	}
+	c.v4 = ipdetect.NewHTTP(ipv4Endpoint, opts...)
+	c.v6 = ipdetect.NewHTTP(ipv6Endpoint, opts...)
+	return c
+}
+
+// Detect returns the public addresses of the enabled families.
+// An error is returned only if no enabled family could be detected, since many hosts lack IPv6 connectivity.
+func (c *IPify) Detect(ctx context.Context) ([]net.IP, error) {
+	var ips []net.IP
+	var firstErr error
+	for _, f := range []struct {
+		enabled bool
+		src     ipdetect.Source
+	}{{c.ipv4, c.v4}, {c.ipv6, c.v6}} {
+		if !f.enabled {
+			continue
+		}
+		found, err := f.src.Detect(ctx)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		ips = append(ips, found...)
+	}
+	if len(ips) == 0 {
+		return nil, firstErr
+	}
+	return ips, nil
 }
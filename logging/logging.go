@@ -0,0 +1,84 @@
+// Package logging adapts log/slog to the Logger interfaces of the ddns packages, and lets them log leveled
+// records with fields such as the provider, hostnames, status code and duration of an update.
+//
+// Every ddns package takes a Logger with a printf-style Log method. Loggers that also implement Structured, such
+// as the Adapter returned by Slog, receive the level and fields of the messages logged with Print as well;
+// other loggers receive the same message as before. Packages logradapter, zapadapter and zerologadapter adapt
+// logr, zap and zerolog loggers the same way; each is a module of its own, so that the ddns module does not depend
+// on those loggers.
+package logging // import "github.com/justenwalker/ddns/logging"
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+)
+
+// Logger is the logging interface of the ddns packages
+type Logger interface {
+	Log(format string, v ...interface{})
+}
+
+// Structured is implemented by loggers that take the level and fields of a message along with its text
+type Structured interface {
+	Logger
+	LogAttrs(level slog.Level, msg string, attrs ...slog.Attr)
+}
+
+// Print logs the formatted message to l with level and attrs if l is Structured, or with its Log method otherwise.
+// It does nothing if l is nil.
+func Print(l Logger, level slog.Level, attrs []slog.Attr, format string, v ...interface{}) {
+	switch l := l.(type) {
+	case nil:
+	case Structured:
+		l.LogAttrs(level, fmt.Sprintf(format, v...), attrs...)
+	default:
+		l.Log(format, v...)
+	}
+}
+
+// Adapter logs the messages of the ddns packages to a slog.Logger. The package prefix of a message, such as
+// "daemon: ", becomes its component attribute.
+type Adapter struct {
+	logger *slog.Logger
+	level  slog.Level
+}
+
+// Slog returns an Adapter logging to l, at the info level unless a message has its own
+func Slog(l *slog.Logger) *Adapter {
+	return &Adapter{logger: l, level: slog.LevelInfo}
+}
+
+// Level returns a copy of a logging the messages without a level of their own at level,
+// such as for the debug loggers of the ddns packages
+func (a *Adapter) Level(level slog.Level) *Adapter {
+	c := *a
+	c.level = level
+	return &c
+}
+
+// Log logs the formatted message at the level of the adapter
+func (a *Adapter) Log(format string, v ...interface{}) {
+	a.LogAttrs(a.level, fmt.Sprintf(format, v...))
+}
+
+// LogAttrs logs msg at level with attrs
+func (a *Adapter) LogAttrs(level slog.Level, msg string, attrs ...slog.Attr) {
+	if component, rest := Component(msg); component != "" {
+		msg = rest
+		attrs = append([]slog.Attr{slog.String("component", component)}, attrs...)
+	}
+	a.logger.LogAttrs(context.Background(), level, msg, attrs...)
+}
+
+var componentPrefix = regexp.MustCompile(`^([a-z0-9]+): `)
+
+// Component splits the package prefix of a message, such as "daemon" in "daemon: stopping", from the rest of
+// the message. The component is empty if the message has no prefix.
+func Component(msg string) (component, rest string) {
+	if m := componentPrefix.FindStringSubmatch(msg); m != nil {
+		return m[1], msg[len(m[0]):]
+	}
+	return "", msg
+}
@@ -0,0 +1,51 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"testing"
+)
+
+type printf []string
+
+func (p *printf) Log(format string, v ...interface{}) {
+	*p = append(*p, fmt.Sprintf(format, v...))
+}
+
+func TestPrint(t *testing.T) {
+	var plain printf
+	Print(&plain, slog.LevelWarn, []slog.Attr{slog.String("provider", "home")}, "daemon: %s: update failed", "home")
+	if len(plain) != 1 || plain[0] != "daemon: home: update failed" {
+		t.Errorf("expected the message alone for a printf logger, got %q", plain)
+	}
+	Print(nil, slog.LevelInfo, nil, "ignored")
+
+	var buf bytes.Buffer
+	a := Slog(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	Print(a, slog.LevelWarn, []slog.Attr{slog.String("provider", "home"), slog.Int("attempt", 2)}, "daemon: %s: update failed", "home")
+	a.Level(slog.LevelDebug).Log("ipdetect: %d addresses", 2)
+	a.Log("no component")
+	var records []map[string]interface{}
+	for dec := json.NewDecoder(&buf); dec.More(); {
+		var r map[string]interface{}
+		if err := dec.Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, r)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %v", records)
+	}
+	if r := records[0]; r["level"] != "WARN" || r["msg"] != "home: update failed" || r["component"] != "daemon" ||
+		r["provider"] != "home" || r["attempt"] != float64(2) {
+		t.Errorf("unexpected structured record %v", r)
+	}
+	if r := records[1]; r["level"] != "DEBUG" || r["component"] != "ipdetect" || r["msg"] != "2 addresses" {
+		t.Errorf("unexpected debug record %v", r)
+	}
+	if r := records[2]; r["level"] != "INFO" || r["component"] != nil {
+		t.Errorf("unexpected record without a component %v", r)
+	}
+}
@@ -0,0 +1,10 @@
+module github.com/justenwalker/ddns/logging/logradapter
+
+go 1.23.0
+
+require (
+	github.com/go-logr/logr v1.4.4
+	github.com/justenwalker/ddns v0.0.0-00010101000000-000000000000
+)
+
+replace github.com/justenwalker/ddns => ../..
@@ -0,0 +1,70 @@
+// Package logradapter logs the messages of the ddns packages to a logr.Logger, for controllers and operators
+// that standardize on logr:
+//
+//	engine := ddns.New(src, providers, ddns.Log(logradapter.New(ctrl.Log.WithName("ddns"))))
+//
+// The package prefix of a message, such as "daemon: ", becomes the name of its logger. Messages logged at the
+// error level are logged with Logger.Error, debug messages at verbosity 1, and the others at verbosity 0.
+package logradapter // import "github.com/justenwalker/ddns/logging/logradapter"
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/go-logr/logr"
+
+	"github.com/justenwalker/ddns/logging"
+)
+
+// Adapter is a logging.Structured logger writing to a logr.Logger
+type Adapter struct {
+	logger logr.Logger
+	level  slog.Level
+}
+
+var _ logging.Structured = (*Adapter)(nil)
+
+// New returns an Adapter logging to l, at verbosity 0 unless a message has a level of its own
+func New(l logr.Logger) *Adapter {
+	return &Adapter{logger: l, level: slog.LevelInfo}
+}
+
+// Debug returns a copy of a logging the messages without a level of their own at verbosity 1,
+// such as for the debug loggers of the ddns packages
+func (a *Adapter) Debug() *Adapter {
+	c := *a
+	c.level = slog.LevelDebug
+	return &c
+}
+
+// Log logs the formatted message at the level of the adapter
+func (a *Adapter) Log(format string, v ...interface{}) {
+	a.LogAttrs(a.level, fmt.Sprintf(format, v...))
+}
+
+// LogAttrs logs msg at level with attrs as key and value pairs. The "error" attribute of an error message is
+// passed to Logger.Error as its error.
+func (a *Adapter) LogAttrs(level slog.Level, msg string, attrs ...slog.Attr) {
+	l := a.logger
+	if component, rest := logging.Component(msg); component != "" {
+		l, msg = l.WithName(component), rest
+	}
+	var err error
+	kvs := make([]interface{}, 0, 2*len(attrs))
+	for _, attr := range attrs {
+		if attr.Key == "error" && level >= slog.LevelError {
+			err = errors.New(attr.Value.String())
+			continue
+		}
+		kvs = append(kvs, attr.Key, attr.Value.Resolve().Any())
+	}
+	switch {
+	case level >= slog.LevelError:
+		l.Error(err, msg, kvs...)
+	case level < slog.LevelInfo:
+		l.V(1).Info(msg, kvs...)
+	default:
+		l.Info(msg, kvs...)
+	}
+}
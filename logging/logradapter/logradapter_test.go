@@ -0,0 +1,34 @@
+package logradapter
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/go-logr/logr/funcr"
+
+	"github.com/justenwalker/ddns/logging"
+)
+
+func TestAdapter(t *testing.T) {
+	var lines []string
+	l := funcr.NewJSON(func(obj string) { lines = append(lines, obj) }, funcr.Options{Verbosity: 1})
+	a := New(l)
+	logging.Print(a, slog.LevelWarn, []slog.Attr{slog.String("provider", "home"), slog.Int("attempt", 2)}, "daemon: %s: update failed", "home")
+	logging.Print(a, slog.LevelError, []slog.Attr{slog.String("error", "disk full")}, "daemon: saving state: %v", "disk full")
+	a.Debug().Log("dynu: GET %s", "https://api.dynu.com/nic/update")
+	a.Log("no component")
+	want := []string{
+		`{"logger":"daemon","level":0,"msg":"home: update failed","provider":"home","attempt":2}`,
+		`{"logger":"daemon","msg":"saving state: disk full","error":"disk full"}`,
+		`{"logger":"dynu","level":1,"msg":"GET https://api.dynu.com/nic/update"}`,
+		`{"logger":"","level":0,"msg":"no component"}`,
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got %q, want %q", lines, want)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d: got %s, want %s", i, lines[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,360 @@
+// Package syslog logs the messages of the ddns packages to a local or remote syslog daemon in the RFC 5424
+// format, for routers and appliances where syslog is the only way to collect logs:
+//
+//	l, err := syslog.Dial("udp://logs.example.com", syslog.Facility(syslog.Local0))
+//
+// The package prefix of a message, such as "daemon: ", becomes its MSGID, and its fields are appended to it
+// as key=value pairs.
+package syslog // import "github.com/justenwalker/ddns/logging/syslog"
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/justenwalker/ddns/logging"
+)
+
+// Local is the address of the local syslog daemon
+const Local = "local"
+
+// DefaultPort is the port of remote servers without one
+const DefaultPort = "514"
+
+// localPaths are the sockets of the local syslog daemons of Linux, macOS and the BSDs
+var localPaths = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// Priority is a syslog facility
+type Priority int
+
+// The facilities of RFC 5424
+const (
+	Kern Priority = iota << 3
+	User
+	Mail
+	Daemon
+	Auth
+	Syslog
+	LPR
+	News
+	UUCP
+	Cron
+	AuthPriv
+	FTP
+	_
+	_
+	_
+	_
+	Local0
+	Local1
+	Local2
+	Local3
+	Local4
+	Local5
+	Local6
+	Local7
+)
+
+var facilities = map[string]Priority{
+	"kern": Kern, "user": User, "mail": Mail, "daemon": Daemon, "auth": Auth, "syslog": Syslog,
+	"lpr": LPR, "news": News, "uucp": UUCP, "cron": Cron, "authpriv": AuthPriv, "ftp": FTP,
+	"local0": Local0, "local1": Local1, "local2": Local2, "local3": Local3,
+	"local4": Local4, "local5": Local5, "local6": Local6, "local7": Local7,
+}
+
+// Facilities returns the names of the facilities, sorted
+func Facilities() []string {
+	names := make([]string, 0, len(facilities))
+	for name := range facilities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseFacility returns the facility called name, such as "daemon" or "local0"
+func ParseFacility(name string) (Priority, error) {
+	f, ok := facilities[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("syslog: unknown facility %q", name)
+	}
+	return f, nil
+}
+
+// ParseAddress checks that addr is Local or a udp://host[:port] or tcp://host[:port] URL, returning the network
+// and address to dial; both are empty for Local
+func ParseAddress(addr string) (network, address string, err error) {
+	if addr == Local {
+		return "", "", nil
+	}
+	u, err := url.Parse(addr)
+	if err != nil || (u.Scheme != "udp" && u.Scheme != "tcp") || u.Hostname() == "" || (u.Path != "" && u.Path != "/") {
+		return "", "", fmt.Errorf("syslog: address %q must be %q or a udp://host[:port] or tcp://host[:port] URL", addr, Local)
+	}
+	port := u.Port()
+	if port == "" {
+		port = DefaultPort
+	}
+	return u.Scheme, net.JoinHostPort(u.Hostname(), port), nil
+}
+
+// Option sets logger options
+type Option func(*Logger)
+
+// Facility sets the facility of the messages; the default is Daemon
+func Facility(f Priority) Option {
+	return func(l *Logger) {
+		l.facility = f
+	}
+}
+
+// AppName sets the APP-NAME of the messages; the default is "ddns"
+func AppName(name string) Option {
+	return func(l *Logger) {
+		l.app = name
+	}
+}
+
+// Logger is a logging.Structured logger writing to a syslog daemon
+type Logger struct {
+	facility Priority
+	app      string
+	hostname string
+	level    slog.Level
+	// conn is shared by the copies returned by Debug
+	conn *conn
+}
+
+var _ logging.Structured = (*Logger)(nil)
+
+// Dial connects to the syslog daemon at addr, which is Local or a udp://host[:port] or tcp://host[:port] URL.
+// A connection that fails is reopened on the next message, waiting longer after each failure to reopen it.
+func Dial(addr string, options ...Option) (*Logger, error) {
+	network, address, err := ParseAddress(addr)
+	if err != nil {
+		return nil, err
+	}
+	l := &Logger{
+		facility: Daemon,
+		app:      "ddns",
+		level:    slog.LevelInfo,
+		conn:     &conn{network: network, address: address},
+	}
+	for _, opt := range options {
+		opt(l)
+	}
+	if l.hostname, err = os.Hostname(); err != nil || l.hostname == "" {
+		l.hostname = "-"
+	}
+	if err := l.conn.connect(); err != nil {
+		return nil, err
+	}
+	l.conn.start()
+	return l, nil
+}
+
+// Debug returns a copy of l logging the messages without a level of their own at the debug level,
+// such as for the debug loggers of the ddns packages
+func (l *Logger) Debug() *Logger {
+	c := *l
+	c.level = slog.LevelDebug
+	return &c
+}
+
+// Log logs the formatted message at the level of the logger
+func (l *Logger) Log(format string, v ...interface{}) {
+	l.LogAttrs(l.level, fmt.Sprintf(format, v...))
+}
+
+// LogAttrs logs msg at level with attrs without waiting for it to be sent. Messages that cannot be sent are
+// dropped, since there is nowhere to report them.
+func (l *Logger) LogAttrs(level slog.Level, msg string, attrs ...slog.Attr) {
+	l.conn.write(l.format(time.Now(), level, msg, attrs))
+}
+
+// format returns the RFC 5424 message
+func (l *Logger) format(t time.Time, level slog.Level, msg string, attrs []slog.Attr) string {
+	msgID := "-"
+	if component, rest := logging.Component(msg); component != "" {
+		msgID, msg = component, rest
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %d %s - %s", int(l.facility)|severity(level),
+		t.Format("2006-01-02T15:04:05.000000Z07:00"), l.hostname, l.app, os.Getpid(), msgID, msg)
+	for _, attr := range attrs {
+		b.WriteString(" ")
+		b.WriteString(attr.Key)
+		b.WriteString("=")
+		b.WriteString(value(attr.Value.Resolve().String()))
+	}
+	return b.String()
+}
+
+// value quotes s if it is empty or has spaces or quotes
+func value(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// severity returns the syslog severity of level
+func severity(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError:
+		return 3
+	case level >= slog.LevelWarn:
+		return 4
+	case level >= slog.LevelInfo:
+		return 6
+	default:
+		return 7
+	}
+}
+
+// Close sends the messages logged so far, waiting a few seconds at most, and closes the connection to the
+// syslog daemon
+func (l *Logger) Close() error {
+	return l.conn.close()
+}
+
+const (
+	// queueSize is how many messages wait to be sent; newer ones are dropped
+	queueSize = 256
+	// writeTimeout is how long a message may take to be sent, and how long Close waits for the queue to be sent
+	writeTimeout = 5 * time.Second
+	// minRetry and maxRetry bound the delay before reconnecting, which doubles with each failure
+	minRetry = time.Second
+	maxRetry = time.Minute
+)
+
+// conn is the connection to the syslog daemon; network is empty for the local one. Messages are sent in the
+// background so that a slow or unreachable daemon never blocks the caller: they are dropped while the queue is
+// full or while waiting to reconnect.
+type conn struct {
+	network string
+	address string
+
+	mu     sync.Mutex
+	queue  chan string
+	closed bool
+	done   chan struct{}
+
+	// c, stream, retry and wait are only used by run once it is started
+	c net.Conn
+	// stream is set for the local daemons listening on stream sockets, which separate messages with newlines
+	stream bool
+	// retry is when to reconnect after the connection failed, and wait how long the next failure delays it
+	retry time.Time
+	wait  time.Duration
+}
+
+// connect opens the connection
+func (c *conn) connect() error {
+	if c.network != "" {
+		nc, err := net.DialTimeout(c.network, c.address, writeTimeout)
+		if err != nil {
+			return fmt.Errorf("syslog: %w", err)
+		}
+		c.c = nc
+		return nil
+	}
+	for _, path := range localPaths {
+		for _, network := range []string{"unixgram", "unix"} {
+			if nc, err := net.Dial(network, path); err == nil {
+				c.c, c.stream = nc, network == "unix"
+				return nil
+			}
+		}
+	}
+	return errors.New("syslog: cannot connect to the local syslog daemon")
+}
+
+// start sends the queued messages in the background until the connection is closed
+func (c *conn) start() {
+	c.queue = make(chan string, queueSize)
+	c.done = make(chan struct{})
+	go c.run()
+}
+
+// write queues msg, or drops it if the queue is full
+func (c *conn) write(msg string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	select {
+	case c.queue <- msg:
+	default:
+	}
+}
+
+func (c *conn) run() {
+	defer close(c.done)
+	for msg := range c.queue {
+		c.deliver(msg)
+	}
+	if c.c != nil {
+		c.c.Close()
+	}
+}
+
+// deliver sends msg in a datagram of its own, or framed over streams, reconnecting once if it fails unless
+// reconnecting is backing off
+func (c *conn) deliver(msg string) {
+	if c.c != nil && c.send(msg) == nil {
+		return
+	}
+	if c.c != nil {
+		c.c.Close()
+		c.c = nil
+	}
+	now := time.Now()
+	if now.Before(c.retry) {
+		return
+	}
+	if err := c.connect(); err != nil {
+		c.wait = min(max(2*c.wait, minRetry), maxRetry)
+		c.retry = now.Add(c.wait)
+		return
+	}
+	c.wait = 0
+	c.send(msg)
+}
+
+// send frames msg with its length over TCP, as in RFC 6587, and with a newline over local stream sockets
+func (c *conn) send(msg string) error {
+	switch {
+	case c.network == "tcp":
+		msg = strconv.Itoa(len(msg)) + " " + msg
+	case c.stream:
+		msg += "\n"
+	}
+	c.c.SetWriteDeadline(time.Now().Add(writeTimeout))
+	_, err := c.c.Write([]byte(msg))
+	return err
+}
+
+// close sends the queued messages, waiting up to writeTimeout, and closes the connection
+func (c *conn) close() error {
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.queue)
+	}
+	c.mu.Unlock()
+	select {
+	case <-c.done:
+	case <-time.After(writeTimeout):
+	}
+	return nil
+}
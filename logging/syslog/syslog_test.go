@@ -0,0 +1,193 @@
+package syslog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/justenwalker/ddns/logging"
+)
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		addr, network, address string
+		err                    bool
+	}{
+		{addr: "local"},
+		{addr: "udp://logs.example.com", network: "udp", address: "logs.example.com:514"},
+		{addr: "tcp://192.0.2.1:1514", network: "tcp", address: "192.0.2.1:1514"},
+		{addr: "udp://[2001:db8::1]", network: "udp", address: "[2001:db8::1]:514"},
+		{addr: "logs.example.com:514", err: true},
+		{addr: "https://logs.example.com", err: true},
+		{addr: "udp://", err: true},
+	}
+	for _, tt := range tests {
+		network, address, err := ParseAddress(tt.addr)
+		if (err != nil) != tt.err || network != tt.network || address != tt.address {
+			t.Errorf("ParseAddress(%q) = %q, %q, %v", tt.addr, network, address, err)
+		}
+	}
+	if f, err := ParseFacility("LOCAL3"); err != nil || f != 19<<3 {
+		t.Errorf("ParseFacility(LOCAL3) = %d, %v", f, err)
+	}
+	if _, err := ParseFacility("local8"); err == nil {
+		t.Error("expected an unknown facility to fail")
+	}
+}
+
+// message matches the messages of the tests, whose timestamps and hostnames vary
+var message = regexp.MustCompile(`^<(\d+)>1 \d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{6}(Z|[+-]\d\d:\d\d) \S+ (\S+) (\d+) (\S+) - (.*)$`)
+
+func check(t *testing.T, msg, pri, app, msgID, text string) {
+	t.Helper()
+	m := message.FindStringSubmatch(msg)
+	if m == nil {
+		t.Fatalf("malformed message %q", msg)
+	}
+	if m[1] != pri || m[3] != app || m[4] != fmt.Sprint(os.Getpid()) || m[5] != msgID || m[6] != text {
+		t.Errorf("unexpected message %q", msg)
+	}
+}
+
+func TestUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	l, err := Dial("udp://"+pc.LocalAddr().String(), Facility(Local0), AppName("dyndns"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	logging.Print(l, slog.LevelWarn, []slog.Attr{slog.String("provider", "home"), slog.Duration("duration", time.Second),
+		slog.String("error", "dynu: badauth")}, "daemon: %s: update failed", "home")
+	l.Debug().Log("dynu: GET %s", "https://api.dynu.com/nic/update")
+	l.Log("no component")
+
+	buf := make([]byte, 2048)
+	var msgs []string
+	for i := 0; i < 3; i++ {
+		pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, string(buf[:n]))
+	}
+	check(t, msgs[0], "132", "dyndns", "daemon", `home: update failed provider=home duration=1s error="dynu: badauth"`)
+	check(t, msgs[1], "135", "dyndns", "dynu", "GET https://api.dynu.com/nic/update")
+	check(t, msgs[2], "134", "dyndns", "-", "no component")
+}
+
+func TestTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	l, err := Dial("tcp://" + ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	c, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	l.Log("daemon: line one\nline two")
+	l.Log("daemon: stopping")
+
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(c)
+	for _, want := range []string{"line one\nline two", "stopping"} {
+		var n int
+		if _, err := fmt.Fscanf(r, "%d ", &n); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			t.Fatal(err)
+		}
+		check(t, strings.Replace(string(buf), "\n", " ", 1), "30", "ddns", "daemon", strings.Replace(want, "\n", " ", 1))
+	}
+}
+
+func TestLocal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no datagram Unix sockets")
+	}
+	// t.TempDir can be too long for a Unix socket
+	dir, err := os.MkdirTemp("", "syslog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "log")
+	pc, err := net.ListenPacket("unixgram", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	defer func(paths []string) { localPaths = paths }(localPaths)
+	localPaths = []string{filepath.Join(dir, "missing"), path}
+
+	l, err := Dial(Local, Facility(Auth))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	l.LogAttrs(slog.LevelError, "daemon: detection failed")
+	buf := make([]byte, 2048)
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(t, string(buf[:n]), "35", "ddns", "daemon", "detection failed")
+}
+
+func TestSlowDaemon(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	l, err := Dial("tcp://" + ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// the daemon never reads: once the socket buffers are full, messages are dropped rather than blocking
+	msg := strings.Repeat("x", 64<<10)
+	start := time.Now()
+	for i := 0; i < 2048; i++ {
+		l.Log("daemon: %s", msg)
+	}
+	if took := time.Since(start); took > 2*time.Second {
+		t.Errorf("expected logging not to wait for the daemon, took %v", took)
+	}
+	// once the daemon is gone, the queue is dropped while waiting to reconnect
+	c.Close()
+	ln.Close()
+	start = time.Now()
+	l.Close()
+	if took := time.Since(start); took > time.Second {
+		t.Errorf("expected the queue to be dropped once the daemon is gone, closing took %v", took)
+	}
+}
@@ -0,0 +1,12 @@
+module github.com/justenwalker/ddns/logging/zapadapter
+
+go 1.23.0
+
+require (
+	github.com/justenwalker/ddns v0.0.0-00010101000000-000000000000
+	go.uber.org/zap v1.27.0
+)
+
+require go.uber.org/multierr v1.10.0 // indirect
+
+replace github.com/justenwalker/ddns => ../..
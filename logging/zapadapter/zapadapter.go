@@ -0,0 +1,95 @@
+// Package zapadapter logs the messages of the ddns packages to a zap.Logger, such as the debug logs of the
+// dynu client:
+//
+//	client := dynu.New(username, password, dynu.Debug(zapadapter.New(logger).Debug()))
+//
+// The package prefix of a message, such as "dynu: ", becomes the name of its logger.
+package zapadapter // import "github.com/justenwalker/ddns/logging/zapadapter"
+
+import (
+	"fmt"
+	"log/slog"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/justenwalker/ddns/logging"
+)
+
+// Adapter is a logging.Structured logger writing to a zap.Logger
+type Adapter struct {
+	logger *zap.Logger
+	level  slog.Level
+}
+
+var _ logging.Structured = (*Adapter)(nil)
+
+// New returns an Adapter logging to l, at the info level unless a message has a level of its own
+func New(l *zap.Logger) *Adapter {
+	return &Adapter{logger: l, level: slog.LevelInfo}
+}
+
+// Debug returns a copy of a logging the messages without a level of their own at the debug level,
+// such as for the debug loggers of the ddns packages
+func (a *Adapter) Debug() *Adapter {
+	c := *a
+	c.level = slog.LevelDebug
+	return &c
+}
+
+// Log logs the formatted message at the level of the adapter
+func (a *Adapter) Log(format string, v ...interface{}) {
+	a.LogAttrs(a.level, fmt.Sprintf(format, v...))
+}
+
+// LogAttrs logs msg at level with attrs as fields
+func (a *Adapter) LogAttrs(level slog.Level, msg string, attrs ...slog.Attr) {
+	l := a.logger
+	if component, rest := logging.Component(msg); component != "" {
+		l, msg = l.Named(component), rest
+	}
+	ce := l.Check(zapLevel(level), msg)
+	if ce == nil {
+		return
+	}
+	fields := make([]zap.Field, len(attrs))
+	for i, attr := range attrs {
+		fields[i] = field(attr)
+	}
+	ce.Write(fields...)
+}
+
+func zapLevel(level slog.Level) zapcore.Level {
+	switch {
+	case level >= slog.LevelError:
+		return zapcore.ErrorLevel
+	case level >= slog.LevelWarn:
+		return zapcore.WarnLevel
+	case level >= slog.LevelInfo:
+		return zapcore.InfoLevel
+	default:
+		return zapcore.DebugLevel
+	}
+}
+
+func field(attr slog.Attr) zap.Field {
+	v := attr.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return zap.String(attr.Key, v.String())
+	case slog.KindInt64:
+		return zap.Int64(attr.Key, v.Int64())
+	case slog.KindUint64:
+		return zap.Uint64(attr.Key, v.Uint64())
+	case slog.KindFloat64:
+		return zap.Float64(attr.Key, v.Float64())
+	case slog.KindBool:
+		return zap.Bool(attr.Key, v.Bool())
+	case slog.KindDuration:
+		return zap.Duration(attr.Key, v.Duration())
+	case slog.KindTime:
+		return zap.Time(attr.Key, v.Time())
+	default:
+		return zap.Any(attr.Key, v.Any())
+	}
+}
@@ -0,0 +1,36 @@
+package zapadapter
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/justenwalker/ddns/logging"
+)
+
+func TestAdapter(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	a := New(zap.New(core))
+	logging.Print(a, slog.LevelWarn, []slog.Attr{slog.String("provider", "home"), slog.Duration("duration", time.Second)}, "daemon: %s: update failed", "home")
+	a.Debug().Log("dynu: GET %s", "https://api.dynu.com/nic/update")
+	a.Log("no component")
+
+	entries := logs.AllUntimed()
+	if len(entries) != 2 {
+		t.Fatalf("expected the debug message to be filtered out, got %+v", entries)
+	}
+	e := entries[0]
+	if e.Level != zapcore.WarnLevel || e.LoggerName != "daemon" || e.Message != "home: update failed" {
+		t.Errorf("unexpected entry %+v", e.Entry)
+	}
+	if fields := e.ContextMap(); fields["provider"] != "home" || fields["duration"] != time.Second {
+		t.Errorf("unexpected fields %v", fields)
+	}
+	if e := entries[1]; e.Level != zapcore.InfoLevel || e.LoggerName != "" || e.Message != "no component" {
+		t.Errorf("unexpected entry %+v", e.Entry)
+	}
+}
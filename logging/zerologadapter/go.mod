@@ -0,0 +1,16 @@
+module github.com/justenwalker/ddns/logging/zerologadapter
+
+go 1.23.0
+
+require (
+	github.com/justenwalker/ddns v0.0.0-00010101000000-000000000000
+	github.com/rs/zerolog v1.34.0
+)
+
+require (
+	github.com/mattn/go-colorable v0.1.13 // indirect
+	github.com/mattn/go-isatty v0.0.19 // indirect
+	golang.org/x/sys v0.31.0 // indirect
+)
+
+replace github.com/justenwalker/ddns => ../..
@@ -0,0 +1,92 @@
+// Package zerologadapter logs the messages of the ddns packages to a zerolog.Logger, such as the debug logs of
+// the dynu client:
+//
+//	client := dynu.New(username, password, dynu.Debug(zerologadapter.New(log.Logger).Debug()))
+//
+// The package prefix of a message, such as "dynu: ", becomes its component field.
+package zerologadapter // import "github.com/justenwalker/ddns/logging/zerologadapter"
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/rs/zerolog"
+
+	"github.com/justenwalker/ddns/logging"
+)
+
+// Adapter is a logging.Structured logger writing to a zerolog.Logger
+type Adapter struct {
+	logger zerolog.Logger
+	level  slog.Level
+}
+
+var _ logging.Structured = (*Adapter)(nil)
+
+// New returns an Adapter logging to l, at the info level unless a message has a level of its own
+func New(l zerolog.Logger) *Adapter {
+	return &Adapter{logger: l, level: slog.LevelInfo}
+}
+
+// Debug returns a copy of a logging the messages without a level of their own at the debug level,
+// such as for the debug loggers of the ddns packages
+func (a *Adapter) Debug() *Adapter {
+	c := *a
+	c.level = slog.LevelDebug
+	return &c
+}
+
+// Log logs the formatted message at the level of the adapter
+func (a *Adapter) Log(format string, v ...interface{}) {
+	a.LogAttrs(a.level, fmt.Sprintf(format, v...))
+}
+
+// LogAttrs logs msg at level with attrs as fields
+func (a *Adapter) LogAttrs(level slog.Level, msg string, attrs ...slog.Attr) {
+	ev := a.logger.WithLevel(zerologLevel(level))
+	if ev == nil {
+		return
+	}
+	if component, rest := logging.Component(msg); component != "" {
+		ev, msg = ev.Str("component", component), rest
+	}
+	for _, attr := range attrs {
+		ev = field(ev, attr)
+	}
+	ev.Msg(msg)
+}
+
+func zerologLevel(level slog.Level) zerolog.Level {
+	switch {
+	case level >= slog.LevelError:
+		return zerolog.ErrorLevel
+	case level >= slog.LevelWarn:
+		return zerolog.WarnLevel
+	case level >= slog.LevelInfo:
+		return zerolog.InfoLevel
+	default:
+		return zerolog.DebugLevel
+	}
+}
+
+func field(ev *zerolog.Event, attr slog.Attr) *zerolog.Event {
+	v := attr.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return ev.Str(attr.Key, v.String())
+	case slog.KindInt64:
+		return ev.Int64(attr.Key, v.Int64())
+	case slog.KindUint64:
+		return ev.Uint64(attr.Key, v.Uint64())
+	case slog.KindFloat64:
+		return ev.Float64(attr.Key, v.Float64())
+	case slog.KindBool:
+		return ev.Bool(attr.Key, v.Bool())
+	case slog.KindDuration:
+		return ev.Dur(attr.Key, v.Duration())
+	case slog.KindTime:
+		return ev.Time(attr.Key, v.Time())
+	default:
+		return ev.Interface(attr.Key, v.Any())
+	}
+}
@@ -0,0 +1,28 @@
+package zerologadapter
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/justenwalker/ddns/logging"
+)
+
+func TestAdapter(t *testing.T) {
+	var buf bytes.Buffer
+	a := New(zerolog.New(&buf).Level(zerolog.InfoLevel))
+	logging.Print(a, slog.LevelWarn, []slog.Attr{slog.String("provider", "home"), slog.Int("attempt", 2)}, "daemon: %s: update failed", "home")
+	a.Debug().Log("dynu: GET %s", "https://api.dynu.com/nic/update")
+	a.Log("no component")
+
+	want := []string{
+		`{"level":"warn","component":"daemon","provider":"home","attempt":2,"message":"home: update failed"}`,
+		`{"level":"info","message":"no component"}`,
+	}
+	if got := strings.Split(strings.TrimSpace(buf.String()), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
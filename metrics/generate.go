@@ -0,0 +1,229 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Target is a provider, as named in the metrics, that the generated rules and dashboard monitor
+type Target struct {
+	Provider string
+	// Refresh is how often the provider republishes unchanged addresses. A provider that has not succeeded for
+	// twice as long is stale; without Refresh it only updates when the address changes, so it never is.
+	Refresh time.Duration
+}
+
+// Monitoring sets what the generated rules and dashboard select and alert on
+type Monitoring struct {
+	// Job is the Prometheus job scraping ddns; the metrics of every job are selected if it is empty
+	Job string
+	// Failures is the number of consecutive failed updates of a provider that raises an alert; the default is 3
+	Failures int
+	// For is how long a condition lasts before its alert fires; the default is 5 minutes
+	For time.Duration
+}
+
+func (m Monitoring) failures() int {
+	if m.Failures > 0 {
+		return m.Failures
+	}
+	return 3
+}
+
+func (m Monitoring) pending() string {
+	if m.For > 0 {
+		return promDuration(m.For)
+	}
+	return "5m"
+}
+
+// selector returns the PromQL label selector of the metrics of the job, and of provider if it is not empty
+func (m Monitoring) selector(provider string) string {
+	var matchers []string
+	if m.Job != "" {
+		matchers = append(matchers, "job="+strconv.Quote(m.Job))
+	}
+	if provider != "" {
+		matchers = append(matchers, "provider="+strconv.Quote(provider))
+	}
+	if len(matchers) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(matchers, ",") + "}"
+}
+
+type ruleFile struct {
+	Groups []ruleGroup `yaml:"groups"`
+}
+
+type ruleGroup struct {
+	Name  string `yaml:"name"`
+	Rules []rule `yaml:"rules"`
+}
+
+type rule struct {
+	Alert       string            `yaml:"alert"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// WriteRules writes a Prometheus rule file alerting when ddns is down, when a provider keeps failing, and when
+// a target with a Refresh has not succeeded for twice as long
+func WriteRules(w io.Writer, m Monitoring, targets []Target) error {
+	g := ruleGroup{Name: "ddns"}
+	if m.Job != "" {
+		g.Rules = append(g.Rules, rule{
+			Alert:       "DDNSDown",
+			Expr:        fmt.Sprintf("up{job=%q} == 0", m.Job),
+			For:         m.pending(),
+			Labels:      map[string]string{"severity": "warning"},
+			Annotations: map[string]string{"summary": "ddns on {{ $labels.instance }} cannot be scraped"},
+		})
+	}
+	g.Rules = append(g.Rules, rule{
+		Alert:  "DDNSProviderFailing",
+		Expr:   fmt.Sprintf("%s%s >= %d", ProviderConsecutiveFailures, m.selector(""), m.failures()),
+		For:    m.pending(),
+		Labels: map[string]string{"severity": "critical"},
+		Annotations: map[string]string{
+			"summary": "ddns provider {{ $labels.provider }} failed {{ $value }} updates in a row",
+		},
+	})
+	for _, t := range targets {
+		if t.Refresh <= 0 {
+			continue
+		}
+		g.Rules = append(g.Rules, rule{
+			Alert:  "DDNSProviderStale",
+			Expr:   fmt.Sprintf("time() - %s%s > %d", ProviderLastSuccess, m.selector(t.Provider), int64(2*t.Refresh/time.Second)),
+			For:    m.pending(),
+			Labels: map[string]string{"severity": "warning"},
+			Annotations: map[string]string{
+				"summary": fmt.Sprintf("ddns provider %s has not been refreshed for over %v", t.Provider, 2*t.Refresh),
+			},
+		})
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(ruleFile{Groups: []ruleGroup{g}}); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+type panel struct {
+	ID          int            `json:"id"`
+	Type        string         `json:"type"`
+	Title       string         `json:"title"`
+	GridPos     gridPos        `json:"gridPos"`
+	Datasource  *datasource    `json:"datasource,omitempty"`
+	Targets     []query        `json:"targets,omitempty"`
+	FieldConfig map[string]any `json:"fieldConfig,omitempty"`
+	Collapsed   *bool          `json:"collapsed,omitempty"`
+}
+
+type gridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type datasource struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+type query struct {
+	RefID        string `json:"refId"`
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat,omitempty"`
+}
+
+// WriteDashboard writes a Grafana dashboard with a row of panels for each target: its consecutive failures,
+// the time since its last success, and its updates by result
+func WriteDashboard(w io.Writer, title string, m Monitoring, targets []Target) error {
+	ds := &datasource{Type: "prometheus", UID: "${datasource}"}
+	thresholds := func(unit string, red float64) map[string]any {
+		return map[string]any{"defaults": map[string]any{
+			"unit": unit,
+			"thresholds": map[string]any{"mode": "absolute", "steps": []map[string]any{
+				{"color": "green", "value": nil},
+				{"color": "red", "value": red},
+			}},
+		}}
+	}
+	var panels []panel
+	id, y := 1, 0
+	next := func(p panel) {
+		p.ID = id
+		id++
+		panels = append(panels, p)
+	}
+	for _, t := range targets {
+		sel := m.selector(t.Provider)
+		collapsed := false
+		next(panel{Type: "row", Title: t.Provider, GridPos: gridPos{H: 1, W: 24, Y: y}, Collapsed: &collapsed})
+		stale := float64(24 * time.Hour / time.Second)
+		if t.Refresh > 0 {
+			stale = float64(2 * t.Refresh / time.Second)
+		}
+		next(panel{
+			Type: "stat", Title: "Consecutive failures", GridPos: gridPos{H: 6, W: 6, Y: y + 1}, Datasource: ds,
+			Targets:     []query{{RefID: "A", Expr: ProviderConsecutiveFailures + sel}},
+			FieldConfig: thresholds("none", float64(m.failures())),
+		})
+		next(panel{
+			Type: "stat", Title: "Since last success", GridPos: gridPos{H: 6, W: 6, X: 6, Y: y + 1}, Datasource: ds,
+			Targets:     []query{{RefID: "A", Expr: "time() - " + ProviderLastSuccess + sel}},
+			FieldConfig: thresholds("s", stale),
+		})
+		next(panel{
+			Type: "timeseries", Title: "Updates", GridPos: gridPos{H: 6, W: 12, X: 12, Y: y + 1}, Datasource: ds,
+			Targets: []query{{
+				RefID:        "A",
+				Expr:         fmt.Sprintf("sum by (result) (increase(%s%s[$__rate_interval]))", ProviderUpdates, sel),
+				LegendFormat: "{{result}}",
+			}},
+		})
+		y += 7
+	}
+	dashboard := map[string]any{
+		"title":         title,
+		"uid":           "ddns",
+		"tags":          []string{"ddns"},
+		"schemaVersion": 39,
+		"editable":      true,
+		"refresh":       "1m",
+		"time":          map[string]string{"from": "now-24h", "to": "now"},
+		"templating": map[string]any{"list": []map[string]any{{
+			"name":  "datasource",
+			"label": "Data source",
+			"type":  "datasource",
+			"query": "prometheus",
+		}}},
+		"panels": panels,
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(dashboard)
+}
+
+// promDuration formats d as a Prometheus duration, such as 5m or 2h
+func promDuration(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+	return fmt.Sprintf("%ds", (d+time.Second-1)/time.Second)
+}
@@ -0,0 +1,71 @@
+package metrics_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/justenwalker/ddns/metrics"
+	"gopkg.in/yaml.v3"
+)
+
+func TestWriteRules(t *testing.T) {
+	var buf bytes.Buffer
+	targets := []metrics.Target{{Provider: "home/web", Refresh: 12 * time.Hour}, {Provider: "work/vpn"}}
+	if err := metrics.WriteRules(&buf, metrics.Monitoring{Job: "ddns"}, targets); err != nil {
+		t.Fatal(err)
+	}
+	var rules struct {
+		Groups []struct {
+			Rules []struct {
+				Alert, Expr, For string
+			}
+		}
+	}
+	if err := yaml.Unmarshal(buf.Bytes(), &rules); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range rules.Groups[0].Rules {
+		got = append(got, r.Alert+": "+r.Expr+" for "+r.For)
+	}
+	want := []string{
+		`DDNSDown: up{job="ddns"} == 0 for 5m`,
+		`DDNSProviderFailing: ddns_provider_consecutive_failures{job="ddns"} >= 3 for 5m`,
+		`DDNSProviderStale: time() - ddns_provider_last_success_timestamp_seconds{job="ddns",provider="home/web"} > 86400 for 5m`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got rules\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestWriteDashboard(t *testing.T) {
+	var buf bytes.Buffer
+	targets := []metrics.Target{{Provider: "home/web"}, {Provider: "work/vpn"}}
+	if err := metrics.WriteDashboard(&buf, "ddns", metrics.Monitoring{}, targets); err != nil {
+		t.Fatal(err)
+	}
+	var dashboard struct {
+		Panels []struct {
+			ID      int
+			Type    string
+			Title   string
+			Targets []struct{ Expr string }
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &dashboard); err != nil {
+		t.Fatal(err)
+	}
+	if len(dashboard.Panels) != 8 {
+		t.Fatalf("expected a row of 3 panels for each provider, got %d panels", len(dashboard.Panels))
+	}
+	row, updates := dashboard.Panels[4], dashboard.Panels[7]
+	if row.Type != "row" || row.Title != "work/vpn" || row.ID != 5 {
+		t.Errorf("unexpected row %+v", row)
+	}
+	if want := `sum by (result) (increase(ddns_provider_updates_total{provider="work/vpn"}[$__rate_interval]))`; updates.Targets[0].Expr != want {
+		t.Errorf("got %q, want %q", updates.Targets[0].Expr, want)
+	}
+}
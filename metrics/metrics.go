@@ -0,0 +1,106 @@
+// Package metrics exposes ddns telemetry in the Prometheus text exposition format, or sends it to StatsD
+package metrics // import "github.com/justenwalker/ddns/metrics"
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Type is the Prometheus metric type
+type Type string
+
+// Metric types
+const (
+	Counter = Type("counter")
+	Gauge   = Type("gauge")
+)
+
+// Sample is a single labelled value of a metric
+type Sample struct {
+	Labels map[string]string
+	Value  float64
+}
+
+// Metric is a named family of samples
+type Metric struct {
+	Name    string
+	Help    string
+	Type    Type
+	Samples []Sample
+}
+
+// Collector produces metrics each time they are scraped
+type Collector interface {
+	Collect() []Metric
+}
+
+// CollectorFunc adapts a function to the Collector interface
+type CollectorFunc func() []Metric
+
+// Collect calls f()
+func (f CollectorFunc) Collect() []Metric {
+	return f()
+}
+
+// Handler serves the metrics of collectors in the Prometheus text format
+func Handler(collectors ...Collector) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		var ms []Metric
+		for _, c := range collectors {
+			ms = append(ms, c.Collect()...)
+		}
+		WriteText(w, ms)
+	})
+}
+
+// WriteText writes metrics in the Prometheus text format
+func WriteText(w io.Writer, ms []Metric) error {
+	for _, m := range ms {
+		if m.Help != "" {
+			if _, err := fmt.Fprintf(w, "# HELP %s %s\n", m.Name, escape(m.Help, false)); err != nil {
+				return err
+			}
+		}
+		if m.Type != "" {
+			if _, err := fmt.Fprintf(w, "# TYPE %s %s\n", m.Name, m.Type); err != nil {
+				return err
+			}
+		}
+		for _, s := range m.Samples {
+			if _, err := fmt.Fprintf(w, "%s%s %s\n", m.Name, labels(s.Labels), strconv.FormatFloat(s.Value, 'g', -1, 64)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func labels(l map[string]string) string {
+	if len(l) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(l))
+	for k := range l {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + `="` + escape(l[k], true) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escape(s string, quote bool) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	if quote {
+		s = strings.ReplaceAll(s, `"`, `\"`)
+	}
+	return s
+}
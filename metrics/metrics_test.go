@@ -0,0 +1,55 @@
+package metrics_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/justenwalker/ddns/event"
+	"github.com/justenwalker/ddns/metrics"
+)
+
+func TestWriteText(t *testing.T) {
+	var buf bytes.Buffer
+	err := metrics.WriteText(&buf, []metrics.Metric{{
+		Name: "ddns_updates_total",
+		Help: "Updates sent.",
+		Type: metrics.Counter,
+		Samples: []metrics.Sample{
+			{Labels: map[string]string{"provider": "dynu", "host": `a"b`}, Value: 3},
+			{Value: 0.5},
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# HELP ddns_updates_total Updates sent.\n" +
+		"# TYPE ddns_updates_total counter\n" +
+		"ddns_updates_total{host=\"a\\\"b\",provider=\"dynu\"} 3\n" +
+		"ddns_updates_total 0.5\n"
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestProviders(t *testing.T) {
+	p := metrics.NewProviders()
+	ctx := context.Background()
+	p.Handle(ctx, event.Event{Type: event.Updated, Provider: "home/ipv4", Time: time.Unix(1700000000, 0)})
+	p.Handle(ctx, event.Event{Type: event.Failed, Provider: "home/ipv6"})
+	p.Handle(ctx, event.Event{Type: event.Failed, Provider: "home/ipv6"})
+	var buf bytes.Buffer
+	metrics.WriteText(&buf, p.Collect())
+	for _, want := range []string{
+		`ddns_provider_updates_total{provider="home/ipv4",result="success"} 1`,
+		`ddns_provider_updates_total{provider="home/ipv6",result="failure"} 2`,
+		`ddns_provider_consecutive_failures{provider="home/ipv6"} 2`,
+		`ddns_provider_last_success_timestamp_seconds{provider="home/ipv4"} 1.7e+09`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("missing %s in\n%s", want, buf.String())
+		}
+	}
+}
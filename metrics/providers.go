@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/justenwalker/ddns/event"
+)
+
+// Names of the provider metrics, as used by the generated alerting rules and dashboard
+const (
+	ProviderUpdates             = "ddns_provider_updates_total"
+	ProviderConsecutiveFailures = "ddns_provider_consecutive_failures"
+	ProviderLastSuccess         = "ddns_provider_last_success_timestamp_seconds"
+)
+
+// Providers counts the outcomes of updates from the daemon's events.
+// Attach it to the event bus as a sink and register it as a collector.
+// Providers that split address families are reported separately, such as "home/ipv4" and "home/ipv6".
+type Providers struct {
+	mu        sync.Mutex
+	providers map[string]*providerStats
+}
+
+type providerStats struct {
+	successes   int
+	failures    int
+	consecutive int
+	lastSuccess float64
+}
+
+// NewProviders returns an empty provider collector
+func NewProviders() *Providers {
+	return &Providers{providers: make(map[string]*providerStats)}
+}
+
+// Handle records Updated and Failed events
+func (p *Providers) Handle(ctx context.Context, ev event.Event) error {
+	if ev.Type != event.Updated && ev.Type != event.Failed {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := p.providers[ev.Provider]
+	if s == nil {
+		s = &providerStats{}
+		p.providers[ev.Provider] = s
+	}
+	if ev.Type == event.Failed {
+		s.failures++
+		s.consecutive++
+		return nil
+	}
+	s.successes++
+	s.consecutive = 0
+	s.lastSuccess = float64(ev.Time.UnixNano()) / 1e9
+	return nil
+}
+
+// Collect returns the update counts, consecutive failures and last success time of each provider
+func (p *Providers) Collect() []Metric {
+	updates := Metric{Name: ProviderUpdates, Help: "Updates attempted for each provider.", Type: Counter}
+	failing := Metric{Name: ProviderConsecutiveFailures, Help: "Failed updates since the last success of each provider.", Type: Gauge}
+	last := Metric{Name: ProviderLastSuccess, Help: "Time of the last successful update of each provider.", Type: Gauge}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	names := make([]string, 0, len(p.providers))
+	for name := range p.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s := p.providers[name]
+		updates.Samples = append(updates.Samples,
+			Sample{Labels: map[string]string{"provider": name, "result": "success"}, Value: float64(s.successes)},
+			Sample{Labels: map[string]string{"provider": name, "result": "failure"}, Value: float64(s.failures)},
+		)
+		failing.Samples = append(failing.Samples, Sample{Labels: map[string]string{"provider": name}, Value: float64(s.consecutive)})
+		if s.lastSuccess > 0 {
+			last.Samples = append(last.Samples, Sample{Labels: map[string]string{"provider": name}, Value: s.lastSuccess})
+		}
+	}
+	return []Metric{updates, failing, last}
+}
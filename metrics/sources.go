@@ -0,0 +1,23 @@
+package metrics
+
+import (
+	"github.com/justenwalker/ddns/ipdetect"
+)
+
+// SourceHealth collects the per-source detection counts, health scores and latencies tracked by h
+func SourceHealth(h *ipdetect.Health) Collector {
+	return CollectorFunc(func() []Metric {
+		detections := Metric{Name: "ddns_source_detections_total", Help: "Detections attempted by each IP source.", Type: Counter}
+		score := Metric{Name: "ddns_source_health_score", Help: "Weighted success rate of each IP source.", Type: Gauge}
+		latency := Metric{Name: "ddns_source_latency_seconds", Help: "Weighted average detection latency of each IP source.", Type: Gauge}
+		for _, s := range h.Stats() {
+			detections.Samples = append(detections.Samples,
+				Sample{Labels: map[string]string{"source": s.Name, "result": "success"}, Value: float64(s.Successes)},
+				Sample{Labels: map[string]string{"source": s.Name, "result": "failure"}, Value: float64(s.Failures)},
+			)
+			score.Samples = append(score.Samples, Sample{Labels: map[string]string{"source": s.Name}, Value: s.Score})
+			latency.Samples = append(latency.Samples, Sample{Labels: map[string]string{"source": s.Name}, Value: s.Latency.Seconds()})
+		}
+		return []Metric{detections, score, latency}
+	})
+}
@@ -0,0 +1,146 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/justenwalker/ddns/event"
+)
+
+// StatsDOption sets StatsD options
+type StatsDOption func(*StatsD)
+
+// StatsDPrefix sets the prefix of the metric names; the default is "ddns."
+func StatsDPrefix(p string) StatsDOption {
+	return func(s *StatsD) {
+		if p != "" && !strings.HasSuffix(p, ".") {
+			p += "."
+		}
+		s.prefix = p
+	}
+}
+
+// DogStatsD tags the metrics with the provider and the result, in the DogStatsD format of Datadog agents,
+// instead of adding them to the metric names. The tags, such as "env:home", are added to every metric.
+func DogStatsD(tags ...string) StatsDOption {
+	return func(s *StatsD) {
+		s.dogstatsd = true
+		s.tags = tags
+	}
+}
+
+// StatsD sends counters and timings of the daemon's events to a StatsD agent over UDP.
+// Attach it to the event bus as a sink. It sends:
+//
+//	ddns.detections.<result>            detections that succeeded or failed
+//	ddns.detection                      how long each successful detection took, in milliseconds
+//	ddns.updates.<provider>.<result>    updates of each provider that succeeded or failed
+//	ddns.update.<provider>.<result>     how long each update took, in milliseconds
+//	ddns.changes.<provider>             updates that published new addresses
+//
+// With DogStatsD, the provider and the result are tags of the ddns.detections, ddns.updates, ddns.update and
+// ddns.changes metrics instead.
+type StatsD struct {
+	conn      net.Conn
+	prefix    string
+	dogstatsd bool
+	tags      []string
+}
+
+// DialStatsD returns a sink sending metrics to the agent at addr, such as localhost:8125
+func DialStatsD(addr string, options ...StatsDOption) (*StatsD, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("statsd: %w", err)
+	}
+	s := &StatsD{conn: conn, prefix: "ddns."}
+	for _, opt := range options {
+		opt(s)
+	}
+	return s, nil
+}
+
+// Handle sends the metrics of Detected, Updated, Failed and Changed events, and counts the failed detections
+// from their BackedOff events
+func (s *StatsD) Handle(ctx context.Context, ev event.Event) error {
+	switch ev.Type {
+	case event.Detected:
+		if err := s.send("detections", "c", 1, "", "success"); err != nil {
+			return err
+		}
+		return s.send("detection", "ms", milliseconds(ev.Duration), "", "")
+	case event.BackedOff:
+		if ev.Provider != "" {
+			return nil
+		}
+		return s.send("detections", "c", 1, "", "failure")
+	case event.Updated, event.Failed:
+		result := "success"
+		if ev.Type == event.Failed {
+			result = "failure"
+		}
+		if err := s.send("updates", "c", 1, ev.Provider, result); err != nil {
+			return err
+		}
+		return s.send("update", "ms", milliseconds(ev.Duration), ev.Provider, result)
+	case event.Changed:
+		return s.send("changes", "c", 1, ev.Provider, "")
+	}
+	return nil
+}
+
+// send writes a metric of type typ in a datagram of its own, with the provider and result if they are set
+func (s *StatsD) send(name, typ string, value float64, provider, result string) error {
+	var b strings.Builder
+	b.WriteString(s.prefix)
+	b.WriteString(name)
+	var tags []string
+	if s.dogstatsd {
+		tags = append(tags, s.tags...)
+		if provider != "" {
+			tags = append(tags, "provider:"+tagValue(provider))
+		}
+		if result != "" {
+			tags = append(tags, "result:"+result)
+		}
+	} else {
+		for _, part := range []string{provider, result} {
+			if part != "" {
+				b.WriteString(".")
+				b.WriteString(nameComponent(part))
+			}
+		}
+	}
+	b.WriteString(":")
+	b.WriteString(strconv.FormatFloat(value, 'f', -1, 64))
+	b.WriteString("|")
+	b.WriteString(typ)
+	if len(tags) > 0 {
+		b.WriteString("|#")
+		b.WriteString(strings.Join(tags, ","))
+	}
+	if _, err := s.conn.Write([]byte(b.String())); err != nil {
+		return fmt.Errorf("statsd: %w", err)
+	}
+	return nil
+}
+
+// Close closes the connection to the agent
+func (s *StatsD) Close() error {
+	return s.conn.Close()
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// nameComponent replaces the characters that separate the components of metric names or their values,
+// such as the slash of "home/ipv4" that Graphite would turn into a directory
+var nameComponent = strings.NewReplacer(".", "_", "/", "_", ":", "_", "|", "_", "@", "_", "#", "_", " ", "_").Replace
+
+// tagValue replaces the characters that separate tags
+var tagValue = strings.NewReplacer(",", "_", "|", "_", "#", "_", " ", "_").Replace
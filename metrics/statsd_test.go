@@ -0,0 +1,78 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/justenwalker/ddns/event"
+	"github.com/justenwalker/ddns/metrics"
+)
+
+func TestStatsD(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		options []metrics.StatsDOption
+		want    []string
+	}{
+		{"statsd", nil, []string{
+			"ddns.detections.success:1|c",
+			"ddns.detection:12.5|ms",
+			"ddns.changes.home_ipv4:1|c",
+			"ddns.updates.home_ipv4.success:1|c",
+			"ddns.update.home_ipv4.success:250|ms",
+			"ddns.updates.home_ipv4.failure:1|c",
+			"ddns.update.home_ipv4.failure:3|ms",
+			"ddns.detections.failure:1|c",
+		}},
+		{"dogstatsd", []metrics.StatsDOption{metrics.StatsDPrefix("dyn"), metrics.DogStatsD("env:home")}, []string{
+			"dyn.detections:1|c|#env:home,result:success",
+			"dyn.detection:12.5|ms|#env:home",
+			"dyn.changes:1|c|#env:home,provider:home/ipv4",
+			"dyn.updates:1|c|#env:home,provider:home/ipv4,result:success",
+			"dyn.update:250|ms|#env:home,provider:home/ipv4,result:success",
+			"dyn.updates:1|c|#env:home,provider:home/ipv4,result:failure",
+			"dyn.update:3|ms|#env:home,provider:home/ipv4,result:failure",
+			"dyn.detections:1|c|#env:home,result:failure",
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			agent, err := net.ListenPacket("udp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer agent.Close()
+			s, err := metrics.DialStatsD(agent.LocalAddr().String(), tc.options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			for _, ev := range []event.Event{
+				{Type: event.Detected, Duration: 12500 * time.Microsecond},
+				{Type: event.Changed, Provider: "home/ipv4"},
+				{Type: event.Updated, Provider: "home/ipv4", Duration: 250 * time.Millisecond},
+				{Type: event.Failed, Provider: "home/ipv4", Duration: 3 * time.Millisecond, Err: errors.New("timeout")},
+				{Type: event.BackedOff, Provider: "home/ipv4", Attempt: 1},
+				{Type: event.BackedOff, Attempt: 1},
+				{Type: event.Verified, Provider: "home/ipv4"},
+			} {
+				if err := s.Handle(context.Background(), ev); err != nil {
+					t.Fatal(err)
+				}
+			}
+			agent.SetReadDeadline(time.Now().Add(5 * time.Second))
+			buf := make([]byte, 1500)
+			for _, want := range tc.want {
+				n, _, err := agent.ReadFrom(buf)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := string(buf[:n]); got != want {
+					t.Errorf("got %q, want %q", got, want)
+				}
+			}
+		})
+	}
+}
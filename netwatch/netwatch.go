@@ -0,0 +1,123 @@
+// Package netwatch notifies when the addresses or default routes of the local host change.
+// It is used to trigger DNS updates as soon as the WAN address changes instead of waiting for the next poll.
+package netwatch // import "github.com/justenwalker/ddns/netwatch"
+
+import (
+	"errors"
+	"net"
+)
+
+// ErrNotSupported is returned by New on platforms without an address change watcher
+var ErrNotSupported = errors.New("netwatch: address change notifications are not supported on this platform")
+
+// Logger for printing debug logs from this package
+type Logger interface {
+	Log(format string, v ...interface{})
+}
+
+// EventKind describes what changed
+type EventKind int
+
+const (
+	// AddrAdded is sent when an address is added to an interface
+	AddrAdded EventKind = iota + 1
+	// AddrRemoved is sent when an address is removed from an interface
+	AddrRemoved
+	// RouteChanged is sent when a default route is added or removed
+	RouteChanged
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case AddrAdded:
+		return "addr-added"
+	case AddrRemoved:
+		return "addr-removed"
+	case RouteChanged:
+		return "route-changed"
+	}
+	return "unknown"
+}
+
+// Event is a single change notification
+type Event struct {
+	Kind      EventKind
+	Index     int
+	Interface string
+	// Addr is the address that was added or removed. It is nil for RouteChanged events.
+	Addr net.IP
+}
+
+// Option sets watcher options
+type Option func(*Watcher)
+
+// Log enables watcher logging using the given Logger
+func Log(l Logger) Option {
+	return func(w *Watcher) {
+		w.logger = l
+	}
+}
+
+// Interface restricts events to the named interface (typically the WAN interface).
+// Events for every interface are delivered when this is not set.
+func Interface(name string) Option {
+	return func(w *Watcher) {
+		w.iface = name
+	}
+}
+
+// BufferSize sets the number of events buffered before new events are dropped.
+// Dropping is harmless for callers that treat any event as "something changed"; the default is 16.
+func BufferSize(n int) Option {
+	return func(w *Watcher) {
+		w.bufSize = n
+	}
+}
+
+func (w *Watcher) logf(format string, v ...interface{}) {
+	if w.logger != nil {
+		w.logger.Log(format, v...)
+	}
+}
+
+// Events returns the channel on which change events are delivered. It is closed when the watcher stops.
+func (w *Watcher) Events() <-chan Event {
+	return w.events
+}
+
+func (w *Watcher) applyOptions(options []Option) {
+	w.bufSize = 16
+	for _, opt := range options {
+		opt(w)
+	}
+	w.events = make(chan Event, w.bufSize)
+}
+
+// match returns true if the event passes the interface filter
+func (w *Watcher) match(ev Event) bool {
+	return w.iface == "" || ev.Interface == w.iface
+}
+
+// send delivers the event without blocking, dropping it if the buffer is full
+func (w *Watcher) send(ev Event) {
+	if !w.match(ev) {
+		return
+	}
+	select {
+	case w.events <- ev:
+	default:
+		w.logf("netwatch: event buffer full, dropping %v event for %s", ev.Kind, ev.Interface)
+	}
+}
+
+// interfaceName returns the name of the interface with the given index, or "" if it no longer exists
+func interfaceName(index int) string {
+	if index == 0 {
+		return ""
+	}
+	ifi, err := net.InterfaceByIndex(index)
+	if err != nil {
+		return ""
+	}
+	return ifi.Name
+}
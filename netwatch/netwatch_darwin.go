@@ -0,0 +1,121 @@
+package netwatch
+
+import (
+	"net"
+	"os"
+	"syscall"
+)
+
+// Watcher reads address and route changes from a PF_ROUTE socket.
+// These are the kernel notifications SystemConfiguration's network change events are built on;
+// reading them directly avoids a cgo dependency.
+type Watcher struct {
+	logger  Logger
+	iface   string
+	bufSize int
+	events  chan Event
+	file    *os.File
+}
+
+// New opens a routing socket and starts delivering events
+func New(options ...Option) (*Watcher, error) {
+	w := &Watcher{}
+	w.applyOptions(options)
+	fd, err := syscall.Socket(syscall.AF_ROUTE, syscall.SOCK_RAW, syscall.AF_UNSPEC)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	syscall.CloseOnExec(fd)
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("setnonblock", err)
+	}
+	// Wrapping the socket in an *os.File registers it with the runtime poller, so Close unblocks the reader
+	w.file = os.NewFile(uintptr(fd), "route")
+	go w.run()
+	return w, nil
+}
+
+// Close stops the watcher and closes the Events channel
+func (w *Watcher) Close() error {
+	return w.file.Close()
+}
+
+func (w *Watcher) run() {
+	defer close(w.events)
+	rc, err := w.file.SyscallConn()
+	if err != nil {
+		w.logf("netwatch: %v", err)
+		return
+	}
+	buf := make([]byte, os.Getpagesize())
+	for {
+		var n int
+		var rerr error
+		err := rc.Read(func(fd uintptr) bool {
+			n, rerr = syscall.Read(int(fd), buf)
+			return rerr != syscall.EAGAIN
+		})
+		if err != nil {
+			// The socket was closed
+			return
+		}
+		if rerr != nil {
+			w.logf("netwatch: read: %v", rerr)
+			continue
+		}
+		msgs, err := syscall.ParseRoutingMessage(buf[:n])
+		if err != nil {
+			w.logf("netwatch: parse routing message: %v", err)
+			continue
+		}
+		for _, m := range msgs {
+			if ev, ok := parseRoutingMessage(m); ok {
+				w.send(ev)
+			}
+		}
+	}
+}
+
+func parseRoutingMessage(m syscall.RoutingMessage) (Event, bool) {
+	switch msg := m.(type) {
+	case *syscall.InterfaceAddrMessage:
+		ev := Event{Kind: AddrAdded, Index: int(msg.Header.Index)}
+		if msg.Header.Type == syscall.RTM_DELADDR {
+			ev.Kind = AddrRemoved
+		}
+		if sas, err := syscall.ParseRoutingSockaddr(msg); err == nil && len(sas) > syscall.RTAX_IFA {
+			ev.Addr = sockaddrIP(sas[syscall.RTAX_IFA])
+		}
+		ev.Interface = interfaceName(ev.Index)
+		return ev, true
+	case *syscall.RouteMessage:
+		switch msg.Header.Type {
+		case syscall.RTM_ADD, syscall.RTM_DELETE, syscall.RTM_CHANGE:
+		default:
+			return Event{}, false
+		}
+		sas, err := syscall.ParseRoutingSockaddr(msg)
+		if err != nil || len(sas) <= syscall.RTAX_DST {
+			return Event{}, false
+		}
+		// Only default routes decide which address is published
+		if dst := sockaddrIP(sas[syscall.RTAX_DST]); dst == nil || !dst.IsUnspecified() {
+			return Event{}, false
+		}
+		ev := Event{Kind: RouteChanged, Index: int(msg.Header.Index)}
+		ev.Interface = interfaceName(ev.Index)
+		return ev, true
+	}
+	return Event{}, false
+}
+
+func sockaddrIP(sa syscall.Sockaddr) net.IP {
+	switch a := sa.(type) {
+	case *syscall.SockaddrInet4:
+		return net.IP(append([]byte(nil), a.Addr[:]...))
+	case *syscall.SockaddrInet6:
+		return net.IP(append([]byte(nil), a.Addr[:]...))
+	}
+	return nil
+}
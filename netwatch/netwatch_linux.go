@@ -0,0 +1,175 @@
+package netwatch
+
+import (
+	"net"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// rtnetlink multicast groups from linux/rtnetlink.h
+const (
+	rtmgrpIPv4Ifaddr = 0x10
+	rtmgrpIPv4Route  = 0x40
+	rtmgrpIPv6Ifaddr = 0x100
+	rtmgrpIPv6Route  = 0x400
+)
+
+// Watcher subscribes to rtnetlink address and route notifications
+type Watcher struct {
+	logger  Logger
+	iface   string
+	bufSize int
+	events  chan Event
+	file    *os.File
+}
+
+// New opens an rtnetlink socket and starts delivering events
+func New(options ...Option) (*Watcher, error) {
+	w := &Watcher{}
+	w.applyOptions(options)
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	sa := &syscall.SockaddrNetlink{
+		Family: syscall.AF_NETLINK,
+		Groups: rtmgrpIPv4Ifaddr | rtmgrpIPv6Ifaddr | rtmgrpIPv4Route | rtmgrpIPv6Route,
+	}
+	if err := syscall.Bind(fd, sa); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("bind", err)
+	}
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("setnonblock", err)
+	}
+	// Wrapping the socket in an *os.File registers it with the runtime poller, so Close unblocks the reader
+	w.file = os.NewFile(uintptr(fd), "rtnetlink")
+	go w.run()
+	return w, nil
+}
+
+// Close stops the watcher and closes the Events channel
+func (w *Watcher) Close() error {
+	return w.file.Close()
+}
+
+func (w *Watcher) run() {
+	defer close(w.events)
+	rc, err := w.file.SyscallConn()
+	if err != nil {
+		w.logf("netwatch: %v", err)
+		return
+	}
+	buf := make([]byte, os.Getpagesize())
+	for {
+		var n int
+		var rerr error
+		err := rc.Read(func(fd uintptr) bool {
+			n, _, rerr = syscall.Recvfrom(int(fd), buf, 0)
+			return rerr != syscall.EAGAIN
+		})
+		if err != nil {
+			// The socket was closed
+			return
+		}
+		if rerr != nil {
+			w.logf("netwatch: recvfrom: %v", rerr)
+			continue
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			w.logf("netwatch: parse netlink message: %v", err)
+			continue
+		}
+		for _, ev := range parseMessages(msgs) {
+			w.send(ev)
+		}
+	}
+}
+
+// parseMessages converts rtnetlink messages into events, ignoring anything that is not an address or default route change
+func parseMessages(msgs []syscall.NetlinkMessage) []Event {
+	var events []Event
+	for _, m := range msgs {
+		switch m.Header.Type {
+		case syscall.RTM_NEWADDR, syscall.RTM_DELADDR:
+			if ev, ok := parseAddr(m); ok {
+				events = append(events, ev)
+			}
+		case syscall.RTM_NEWROUTE, syscall.RTM_DELROUTE:
+			if ev, ok := parseRoute(m); ok {
+				events = append(events, ev)
+			}
+		}
+	}
+	return events
+}
+
+func parseAddr(m syscall.NetlinkMessage) (Event, bool) {
+	if len(m.Data) < syscall.SizeofIfAddrmsg {
+		return Event{}, false
+	}
+	ifa := (*syscall.IfAddrmsg)(unsafe.Pointer(&m.Data[0]))
+	ev := Event{
+		Kind:  AddrAdded,
+		Index: int(ifa.Index),
+	}
+	if m.Header.Type == syscall.RTM_DELADDR {
+		ev.Kind = AddrRemoved
+	}
+	attrs, err := syscall.ParseNetlinkRouteAttr(&m)
+	if err != nil {
+		return Event{}, false
+	}
+	for _, a := range attrs {
+		switch a.Attr.Type {
+		case syscall.IFA_LOCAL:
+			// IFA_LOCAL is the local address on point-to-point links, where IFA_ADDRESS is the peer
+			ev.Addr = net.IP(append([]byte(nil), a.Value...))
+		case syscall.IFA_ADDRESS:
+			if ev.Addr == nil {
+				ev.Addr = net.IP(append([]byte(nil), a.Value...))
+			}
+		case syscall.IFA_LABEL:
+			ev.Interface = cstring(a.Value)
+		}
+	}
+	if ev.Interface == "" {
+		ev.Interface = interfaceName(ev.Index)
+	}
+	return ev, true
+}
+
+func parseRoute(m syscall.NetlinkMessage) (Event, bool) {
+	if len(m.Data) < syscall.SizeofRtMsg {
+		return Event{}, false
+	}
+	rt := (*syscall.RtMsg)(unsafe.Pointer(&m.Data[0]))
+	if rt.Dst_len != 0 || rt.Table != syscall.RT_TABLE_MAIN {
+		// Only default routes in the main table decide which address is published
+		return Event{}, false
+	}
+	ev := Event{Kind: RouteChanged}
+	attrs, err := syscall.ParseNetlinkRouteAttr(&m)
+	if err != nil {
+		return Event{}, false
+	}
+	for _, a := range attrs {
+		if a.Attr.Type == syscall.RTA_OIF && len(a.Value) >= 4 {
+			ev.Index = int(*(*uint32)(unsafe.Pointer(&a.Value[0])))
+			ev.Interface = interfaceName(ev.Index)
+		}
+	}
+	return ev, true
+}
+
+func cstring(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
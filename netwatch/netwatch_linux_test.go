@@ -0,0 +1,45 @@
+package netwatch
+
+import (
+	"net"
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+func rtattr(typ uint16, value []byte) []byte {
+	l := syscall.SizeofRtAttr + len(value)
+	b := make([]byte, (l+syscall.NLMSG_ALIGNTO-1)&^(syscall.NLMSG_ALIGNTO-1))
+	*(*uint16)(unsafe.Pointer(&b[0])) = uint16(l)
+	*(*uint16)(unsafe.Pointer(&b[2])) = typ
+	copy(b[syscall.SizeofRtAttr:], value)
+	return b
+}
+
+func TestParseMessages(t *testing.T) {
+	ifa := syscall.IfAddrmsg{Family: syscall.AF_INET, Prefixlen: 24}
+	data := append([]byte(nil), (*[syscall.SizeofIfAddrmsg]byte)(unsafe.Pointer(&ifa))[:]...)
+	data = append(data, rtattr(syscall.IFA_ADDRESS, []byte{203, 0, 113, 7})...)
+	data = append(data, rtattr(syscall.IFA_LABEL, []byte("wan0\x00"))...)
+
+	rt := syscall.RtMsg{Family: syscall.AF_INET, Table: syscall.RT_TABLE_MAIN}
+	route := append([]byte(nil), (*[syscall.SizeofRtMsg]byte)(unsafe.Pointer(&rt))[:]...)
+	rt.Dst_len = 24
+	subnet := append([]byte(nil), (*[syscall.SizeofRtMsg]byte)(unsafe.Pointer(&rt))[:]...)
+
+	events := parseMessages([]syscall.NetlinkMessage{
+		{Header: syscall.NlMsghdr{Type: syscall.RTM_DELADDR}, Data: data},
+		{Header: syscall.NlMsghdr{Type: syscall.RTM_NEWROUTE}, Data: route},
+		{Header: syscall.NlMsghdr{Type: syscall.RTM_NEWROUTE}, Data: subnet},
+		{Header: syscall.NlMsghdr{Type: syscall.RTM_NEWLINK}},
+	})
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d: %+v", len(events), events)
+	}
+	if ev := events[0]; ev.Kind != AddrRemoved || ev.Interface != "wan0" || !ev.Addr.Equal(net.IPv4(203, 0, 113, 7)) {
+		t.Errorf("unexpected address event: %+v", ev)
+	}
+	if ev := events[1]; ev.Kind != RouteChanged {
+		t.Errorf("unexpected route event: %+v", ev)
+	}
+}
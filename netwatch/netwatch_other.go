@@ -0,0 +1,22 @@
+//go:build !linux && !windows && !darwin
+// +build !linux,!windows,!darwin
+
+package netwatch
+
+// Watcher is not implemented on this platform
+type Watcher struct {
+	logger  Logger
+	iface   string
+	bufSize int
+	events  chan Event
+}
+
+// New returns ErrNotSupported on this platform
+func New(options ...Option) (*Watcher, error) {
+	return nil, ErrNotSupported
+}
+
+// Close is a no-op on this platform
+func (w *Watcher) Close() error {
+	return nil
+}
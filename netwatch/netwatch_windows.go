@@ -0,0 +1,159 @@
+package netwatch
+
+import (
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	iphlpapi                         = syscall.NewLazyDLL("iphlpapi.dll")
+	procNotifyIPInterfaceChange      = iphlpapi.NewProc("NotifyIpInterfaceChange")
+	procNotifyUnicastIPAddressChange = iphlpapi.NewProc("NotifyUnicastIpAddressChange")
+	procCancelMibChangeNotify2       = iphlpapi.NewProc("CancelMibChangeNotify2")
+)
+
+// MIB_NOTIFICATION_TYPE values
+const (
+	mibParameterNotification = 0
+	mibAddInstance           = 1
+	mibDeleteInstance        = 2
+)
+
+const afUnspec = 0
+
+// Callbacks are created once because the runtime limits how many may exist.
+// They find their watcher through the caller context, which is an index into watchers.
+var (
+	interfaceChangeCallback = syscall.NewCallback(interfaceChanged)
+	addressChangeCallback   = syscall.NewCallback(addressChanged)
+
+	watchersMu sync.Mutex
+	watchers   = make(map[uintptr]*Watcher)
+	nextID     uintptr
+)
+
+// Watcher subscribes to IP Helper interface and unicast address change notifications
+type Watcher struct {
+	logger  Logger
+	iface   string
+	bufSize int
+	events  chan Event
+
+	id         uintptr
+	ifHandle   uintptr
+	addrHandle uintptr
+	closeOnce  sync.Once
+}
+
+// New registers for NotifyIpInterfaceChange and NotifyUnicastIpAddressChange notifications
+func New(options ...Option) (*Watcher, error) {
+	w := &Watcher{}
+	w.applyOptions(options)
+	watchersMu.Lock()
+	nextID++
+	w.id = nextID
+	watchers[w.id] = w
+	watchersMu.Unlock()
+
+	r, _, _ := procNotifyIPInterfaceChange.Call(afUnspec, interfaceChangeCallback, w.id, 0, uintptr(unsafe.Pointer(&w.ifHandle)))
+	if r != 0 {
+		w.unregister()
+		return nil, os.NewSyscallError("NotifyIpInterfaceChange", syscall.Errno(r))
+	}
+	r, _, _ = procNotifyUnicastIPAddressChange.Call(afUnspec, addressChangeCallback, w.id, 0, uintptr(unsafe.Pointer(&w.addrHandle)))
+	if r != 0 {
+		procCancelMibChangeNotify2.Call(w.ifHandle)
+		w.unregister()
+		return nil, os.NewSyscallError("NotifyUnicastIpAddressChange", syscall.Errno(r))
+	}
+	return w, nil
+}
+
+func (w *Watcher) unregister() {
+	watchersMu.Lock()
+	delete(watchers, w.id)
+	watchersMu.Unlock()
+}
+
+// Close cancels the notifications and closes the Events channel
+func (w *Watcher) Close() error {
+	var err error
+	w.closeOnce.Do(func() {
+		// CancelMibChangeNotify2 waits for running callbacks to return, so no event is sent after the channel is closed
+		for _, h := range []uintptr{w.ifHandle, w.addrHandle} {
+			if r, _, _ := procCancelMibChangeNotify2.Call(h); r != 0 && err == nil {
+				err = os.NewSyscallError("CancelMibChangeNotify2", syscall.Errno(r))
+			}
+		}
+		w.unregister()
+		close(w.events)
+	})
+	return err
+}
+
+func lookupWatcher(id uintptr) *Watcher {
+	watchersMu.Lock()
+	defer watchersMu.Unlock()
+	return watchers[id]
+}
+
+// mibIPInterfaceRow is the leading part of MIB_IPINTERFACE_ROW
+type mibIPInterfaceRow struct {
+	Family         uint16
+	_              [6]byte
+	InterfaceLuid  uint64
+	InterfaceIndex uint32
+}
+
+// mibUnicastIPAddressRow is the leading part of MIB_UNICASTIPADDRESS_ROW
+type mibUnicastIPAddressRow struct {
+	Address        [28]byte // SOCKADDR_INET
+	_              [4]byte
+	InterfaceLuid  uint64
+	InterfaceIndex uint32
+}
+
+func interfaceChanged(callerContext uintptr, r *mibIPInterfaceRow, notificationType uintptr) uintptr {
+	w := lookupWatcher(callerContext)
+	if w == nil || r == nil {
+		return 0
+	}
+	ev := Event{Kind: RouteChanged, Index: int(r.InterfaceIndex)}
+	ev.Interface = interfaceName(ev.Index)
+	w.send(ev)
+	return 0
+}
+
+func addressChanged(callerContext uintptr, r *mibUnicastIPAddressRow, notificationType uintptr) uintptr {
+	w := lookupWatcher(callerContext)
+	if w == nil || r == nil {
+		return 0
+	}
+	ev := Event{Index: int(r.InterfaceIndex)}
+	switch notificationType {
+	case mibAddInstance, mibParameterNotification:
+		ev.Kind = AddrAdded
+	case mibDeleteInstance:
+		ev.Kind = AddrRemoved
+	default:
+		return 0
+	}
+	ev.Addr = sockaddrInetIP(r.Address)
+	ev.Interface = interfaceName(ev.Index)
+	w.send(ev)
+	return 0
+}
+
+// sockaddrInetIP extracts the address from a SOCKADDR_INET
+func sockaddrInetIP(sa [28]byte) net.IP {
+	switch *(*uint16)(unsafe.Pointer(&sa[0])) {
+	case syscall.AF_INET:
+		return net.IP(append([]byte(nil), sa[4:8]...))
+	case syscall.AF_INET6:
+		return net.IP(append([]byte(nil), sa[8:24]...))
+	}
+	return nil
+}
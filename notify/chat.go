@@ -0,0 +1,66 @@
+package notify
+
+import (
+	"strings"
+)
+
+// Slack returns a Notifier that posts each notification as a message to a Slack incoming webhook.
+// The url is a secret: anyone who has it can post to the channel.
+func Slack(url string, options ...WebhookOption) Notifier {
+	return newWebhook("slack", url, func(n Notification, f Formatter) (interface{}, error) {
+		msg, err := Message(f, n)
+		if err != nil {
+			return nil, err
+		}
+		return struct {
+			Text string `json:"text"`
+		}{msg}, nil
+	}, options)
+}
+
+// discordLimit is the longest message Discord accepts
+const discordLimit = 2000
+
+// Discord returns a Notifier that posts each notification as a message to a Discord webhook.
+// Messages longer than Discord allows are truncated.
+func Discord(url string, options ...WebhookOption) Notifier {
+	return newWebhook("discord", url, func(n Notification, f Formatter) (interface{}, error) {
+		msg, err := Message(f, n)
+		if err != nil {
+			return nil, err
+		}
+		return struct {
+			Content string `json:"content"`
+		}{truncate(msg, discordLimit)}, nil
+	}, options)
+}
+
+// telegramLimit is the longest message the Telegram Bot API accepts
+const telegramLimit = 4096
+
+// TelegramAPI is the Telegram Bot API endpoint
+const TelegramAPI = "https://api.telegram.org"
+
+// Telegram returns a Notifier that sends each notification as a message from the bot with the token to a chat,
+// by its ID or the @username of a channel. Messages longer than Telegram allows are truncated.
+func Telegram(token, chat string, options ...WebhookOption) Notifier {
+	return newWebhook("telegram", TelegramAPI+"/bot"+token+"/sendMessage", func(n Notification, f Formatter) (interface{}, error) {
+		msg, err := Message(f, n)
+		if err != nil {
+			return nil, err
+		}
+		return struct {
+			ChatID string `json:"chat_id"`
+			Text   string `json:"text"`
+		}{chat, truncate(msg, telegramLimit)}, nil
+	}, options)
+}
+
+// truncate shortens s to at most n characters, ending it with an ellipsis if it was longer
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return strings.TrimRight(string(r[:n-1]), " \n") + "…"
+}
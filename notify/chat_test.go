@@ -0,0 +1,59 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestChat(t *testing.T) {
+	var path string
+	var body map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		b, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(b, &body); err != nil {
+			t.Error(err)
+		}
+		if body["chat_id"] == "missing" {
+			http.Error(w, `{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`, http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+	n := Notification{Kind: Failed, Hostnames: []string{"home.example.com"}, Err: io.ErrUnexpectedEOF}
+
+	if err := Slack(srv.URL+"/services/T0/B0/X").Notify(context.Background(), n); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/services/T0/B0/X" || body["text"] != n.String() {
+		t.Errorf("unexpected slack request to %s: %v", path, body)
+	}
+
+	n.Err = errors.New(strings.Repeat("x", 3000))
+	if err := Discord(srv.URL).Notify(context.Background(), n); err != nil {
+		t.Fatal(err)
+	}
+	if c := body["content"]; utf8.RuneCountInString(c) != discordLimit || !strings.HasSuffix(c, "…") {
+		t.Errorf("expected the discord message to be truncated to %d characters, got %d", discordLimit, len(c))
+	}
+
+	tg := Telegram("123:token", "42").(*webhook)
+	tg.url = srv.URL + strings.TrimPrefix(tg.url, TelegramAPI)
+	if err := tg.Notify(context.Background(), n); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/bot123:token/sendMessage" || body["chat_id"] != "42" || !strings.HasPrefix(body["text"], "home.example.com: update failed") {
+		t.Errorf("unexpected telegram request to %s: %v", path, body)
+	}
+	tg = Telegram("123:token", "missing").(*webhook)
+	tg.url = srv.URL + strings.TrimPrefix(tg.url, TelegramAPI)
+	if err := tg.Notify(context.Background(), n); err == nil || !strings.Contains(err.Error(), "chat not found") {
+		t.Errorf("expected the error to have the description of the response, got %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+// Package notify delivers notifications about IP address changes and update failures
+package notify // import "github.com/justenwalker/ddns/notify"
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// Kind of notification
+type Kind int
+
+const (
+	// Changed is sent when the published IP address changes
+	Changed Kind = iota + 1
+	// Failed is sent when an update fails
+	Failed
+	// Recovered is sent when updates succeed again after failing
+	Recovered
+	// Digest summarizes the changes collected over a period
+	Digest
+	// Unreachable is sent when a published address fails reachability verification
+	Unreachable
+	// RolledBack is sent when an unreachable address was replaced by the previous one
+	RolledBack
+	// Promoted is sent when a backup provider takes over from a failing primary
+	Promoted
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Changed:
+		return "changed"
+	case Failed:
+		return "failed"
+	case Recovered:
+		return "recovered"
+	case Digest:
+		return "digest"
+	case Unreachable:
+		return "unreachable"
+	case RolledBack:
+		return "rolledback"
+	case Promoted:
+		return "promoted"
+	}
+	return "unknown"
+}
+
+// Notification describes something that happened to a provider's records
+type Notification struct {
+	Kind      Kind
+	Time      time.Time
+	Provider  string
+	Hostnames []string
+	OldIPs    []net.IP
+	NewIPs    []net.IP
+	Err       error
+	// Changes holds the summarized notifications of a Digest
+	Changes []Notification
+}
+
+// String formats the notification as a single line of text
+func (n Notification) String() string {
+	var target string
+	if len(n.Hostnames) > 0 {
+		target = strings.Join(n.Hostnames, ", ")
+	} else {
+		target = n.Provider
+	}
+	switch n.Kind {
+	case Changed:
+		return fmt.Sprintf("%s: IP changed from %s to %s", target, formatIPs(n.OldIPs), formatIPs(n.NewIPs))
+	case Failed:
+		return fmt.Sprintf("%s: update failed: %v", target, n.Err)
+	case Recovered:
+		return fmt.Sprintf("%s: updates recovered, IP is %s", target, formatIPs(n.NewIPs))
+	case Unreachable:
+		return fmt.Sprintf("%s: %s is not reachable: %v", target, formatIPs(n.NewIPs), n.Err)
+	case RolledBack:
+		return fmt.Sprintf("%s: rolled back from %s to %s: %v", target, formatIPs(n.OldIPs), formatIPs(n.NewIPs), n.Err)
+	case Promoted:
+		return fmt.Sprintf("%s: backup promoted with IP %s after the primary failed: %v", target, formatIPs(n.NewIPs), n.Err)
+	case Digest:
+		lines := []string{fmt.Sprintf("%d change(s) since the last digest:", len(n.Changes))}
+		for _, c := range n.Changes {
+			lines = append(lines, fmt.Sprintf("  %s %s", c.Time.Format(time.RFC3339), c.String()))
+		}
+		return strings.Join(lines, "\n")
+	}
+	return fmt.Sprintf("%s: %v", target, n.Kind)
+}
+
+func formatIPs(ips []net.IP) string {
+	if len(ips) == 0 {
+		return "(none)"
+	}
+	ss := make([]string, len(ips))
+	for i, ip := range ips {
+		ss[i] = ip.String()
+	}
+	return strings.Join(ss, ", ")
+}
+
+// Notifier delivers notifications
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// NotifierFunc adapts a function to the Notifier interface
+type NotifierFunc func(ctx context.Context, n Notification) error
+
+// Notify calls f(ctx, n)
+func (f NotifierFunc) Notify(ctx context.Context, n Notification) error {
+	return f(ctx, n)
+}
+
+// Multi delivers each notification to all notifiers, returning the first error
+func Multi(notifiers ...Notifier) Notifier {
+	return NotifierFunc(func(ctx context.Context, n Notification) error {
+		var first error
+		for _, nt := range notifiers {
+			if err := nt.Notify(ctx, n); err != nil && first == nil {
+				first = err
+			}
+		}
+		return first
+	})
+}
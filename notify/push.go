@@ -0,0 +1,122 @@
+package notify
+
+import (
+	"strings"
+)
+
+// NtfyServer is the public ntfy server
+const NtfyServer = "https://ntfy.sh"
+
+// Ntfy returns a Notifier that publishes each notification to a topic of an ntfy server, or of NtfyServer if
+// server is empty. The token is the access token of a protected topic, or empty. Failures are sent with a high
+// priority, so that they stand out on phones.
+func Ntfy(server, topic, token string, options ...WebhookOption) Notifier {
+	if server == "" {
+		server = NtfyServer
+	}
+	if token != "" {
+		options = append([]WebhookOption{Headers(map[string]string{"Authorization": "Bearer " + token})}, options...)
+	}
+	return newWebhook("ntfy", strings.TrimSuffix(server, "/"), func(n Notification, f Formatter) (interface{}, error) {
+		msg, err := Message(f, n)
+		if err != nil {
+			return nil, err
+		}
+		priority := 3
+		if urgent(n.Kind) {
+			priority = 4
+		}
+		return struct {
+			Topic    string   `json:"topic"`
+			Title    string   `json:"title"`
+			Message  string   `json:"message"`
+			Priority int      `json:"priority"`
+			Tags     []string `json:"tags,omitempty"`
+		}{topic, pushTitle(n.Kind), msg, priority, ntfyTags[n.Kind]}, nil
+	}, options)
+}
+
+// ntfyTags are the emojis shown before the titles of the messages
+var ntfyTags = map[Kind][]string{
+	Changed:     {"globe_with_meridians"},
+	Failed:      {"warning"},
+	Recovered:   {"white_check_mark"},
+	Unreachable: {"warning"},
+	RolledBack:  {"rewind"},
+	Promoted:    {"arrows_counterclockwise"},
+}
+
+// Gotify returns a Notifier that sends each notification as a message to a Gotify server, with the token of
+// an application. Failures are sent with a high priority.
+func Gotify(server, token string, options ...WebhookOption) Notifier {
+	options = append([]WebhookOption{Headers(map[string]string{"X-Gotify-Key": token})}, options...)
+	return newWebhook("gotify", strings.TrimSuffix(server, "/")+"/message", func(n Notification, f Formatter) (interface{}, error) {
+		msg, err := Message(f, n)
+		if err != nil {
+			return nil, err
+		}
+		priority := 5
+		if urgent(n.Kind) {
+			priority = 8
+		}
+		return struct {
+			Title    string `json:"title"`
+			Message  string `json:"message"`
+			Priority int    `json:"priority"`
+		}{pushTitle(n.Kind), msg, priority}, nil
+	}, options)
+}
+
+// PushoverAPI is the Pushover message endpoint
+const PushoverAPI = "https://api.pushover.net/1/messages.json"
+
+// pushoverLimit is the longest message Pushover accepts
+const pushoverLimit = 1024
+
+// Pushover returns a Notifier that sends each notification from the application with the token to the user or
+// group key. Failures are sent with a high priority, and messages longer than Pushover allows are truncated.
+func Pushover(token, user string, options ...WebhookOption) Notifier {
+	return newWebhook("pushover", PushoverAPI, func(n Notification, f Formatter) (interface{}, error) {
+		msg, err := Message(f, n)
+		if err != nil {
+			return nil, err
+		}
+		priority := 0
+		if urgent(n.Kind) {
+			priority = 1
+		}
+		return struct {
+			Token    string `json:"token"`
+			User     string `json:"user"`
+			Title    string `json:"title"`
+			Message  string `json:"message"`
+			Priority int    `json:"priority"`
+		}{token, user, pushTitle(n.Kind), truncate(msg, pushoverLimit), priority}, nil
+	}, options)
+}
+
+// urgent returns true for the kinds of notifications that need attention
+func urgent(k Kind) bool {
+	return k == Failed || k == Unreachable
+}
+
+// pushTitle returns the title of the push notifications of kind k
+func pushTitle(k Kind) string {
+	switch k {
+	case Changed:
+		return "IP address changed"
+	case Failed:
+		return "DNS update failed"
+	case Recovered:
+		return "DNS updates recovered"
+	case Digest:
+		return "IP address changes"
+	case Unreachable:
+		return "IP address unreachable"
+	case RolledBack:
+		return "IP address rolled back"
+	case Promoted:
+		return "Backup provider promoted"
+	}
+	return "ddns"
+}
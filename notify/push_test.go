@@ -0,0 +1,56 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPush(t *testing.T) {
+	var req *http.Request
+	var body map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		b, _ := io.ReadAll(r.Body)
+		body = nil
+		if err := json.Unmarshal(b, &body); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer srv.Close()
+	n := Notification{Kind: Failed, Hostnames: []string{"home.example.com"}, Err: io.ErrUnexpectedEOF}
+
+	if err := Ntfy(srv.URL+"/", "ddns-home", "tk_secret").Notify(context.Background(), n); err != nil {
+		t.Fatal(err)
+	}
+	if req.URL.Path != "/" || req.Header.Get("Authorization") != "Bearer tk_secret" {
+		t.Errorf("unexpected ntfy request to %s with %v", req.URL.Path, req.Header)
+	}
+	if body["topic"] != "ddns-home" || body["title"] != "DNS update failed" || body["message"] != n.String() || body["priority"] != 4.0 {
+		t.Errorf("unexpected ntfy message %v", body)
+	}
+
+	n.Kind = Changed
+	if err := Gotify(srv.URL, "app-token").Notify(context.Background(), n); err != nil {
+		t.Fatal(err)
+	}
+	if req.URL.Path != "/message" || req.Header.Get("X-Gotify-Key") != "app-token" {
+		t.Errorf("unexpected gotify request to %s with %v", req.URL.Path, req.Header)
+	}
+	if body["title"] != "IP address changed" || body["priority"] != 5.0 {
+		t.Errorf("unexpected gotify message %v", body)
+	}
+
+	po := Pushover("app-token", "user-key").(*webhook)
+	po.url = srv.URL + strings.TrimPrefix(po.url, "https://api.pushover.net")
+	if err := po.Notify(context.Background(), n); err != nil {
+		t.Fatal(err)
+	}
+	if req.URL.Path != "/1/messages.json" || body["token"] != "app-token" || body["user"] != "user-key" || body["priority"] != 0.0 {
+		t.Errorf("unexpected pushover request to %s: %v", req.URL.Path, body)
+	}
+}
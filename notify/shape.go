@@ -0,0 +1,181 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ShapeOption sets notification shaping options
+type ShapeOption func(*Shaper)
+
+// Dedup suppresses notifications identical to one already delivered within the window
+func Dedup(window time.Duration) ShapeOption {
+	return func(s *Shaper) {
+		s.dedup = window
+	}
+}
+
+// EscalateAfter delivers Failed notifications only after n consecutive failures of the same provider.
+// Recovered notifications are only delivered if the failure was escalated.
+func EscalateAfter(n int) ShapeOption {
+	return func(s *Shaper) {
+		s.threshold = n
+	}
+}
+
+// DigestEvery collects Changed notifications and delivers them as a single Digest once per interval,
+// instead of delivering each change immediately. A timer delivers the digest when it is due, even if no
+// other notification arrives; Close delivers what is still pending.
+func DigestEvery(interval time.Duration) ShapeOption {
+	return func(s *Shaper) {
+		s.digest = interval
+	}
+}
+
+// Shaper wraps a Notifier to avoid alert fatigue on flaky connections
+type Shaper struct {
+	next      Notifier
+	dedup     time.Duration
+	threshold int
+	digest    time.Duration
+	now       func() time.Time
+
+	mu         sync.Mutex
+	seen       map[string]time.Time
+	failures   map[string]int
+	escalated  map[string]bool
+	pending    []Notification
+	lastDigest time.Time
+	timer      *time.Timer
+}
+
+// Shape wraps the notifier with the given shaping options
+func Shape(next Notifier, options ...ShapeOption) *Shaper {
+	s := &Shaper{
+		next:      next,
+		now:       time.Now,
+		seen:      make(map[string]time.Time),
+		failures:  make(map[string]int),
+		escalated: make(map[string]bool),
+	}
+	for _, opt := range options {
+		opt(s)
+	}
+	return s
+}
+
+// key identifies duplicate notifications
+func key(n Notification) string {
+	errText := ""
+	if n.Err != nil {
+		errText = n.Err.Error()
+	}
+	return fmt.Sprintf("%v|%s|%s|%s|%s|%s", n.Kind, n.Provider, strings.Join(n.Hostnames, ","), formatIPs(n.OldIPs), formatIPs(n.NewIPs), errText)
+}
+
+// Notify applies the shaping rules and delivers the notification if it passes them.
+// Any digest that has become due is delivered as well.
+func (s *Shaper) Notify(ctx context.Context, n Notification) error {
+	if n.Time.IsZero() {
+		n.Time = s.now()
+	}
+	deliver := s.shape(n)
+	var first error
+	if deliver {
+		first = s.next.Notify(ctx, n)
+	}
+	if err := s.flush(ctx, false); err != nil && first == nil {
+		first = err
+	}
+	return first
+}
+
+// shape records the notification and reports whether it should be delivered now
+func (s *Shaper) shape(n Notification) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch n.Kind {
+	case Failed:
+		s.failures[n.Provider]++
+		if s.failures[n.Provider] < s.threshold {
+			return false
+		}
+		s.escalated[n.Provider] = true
+	case Recovered, Changed:
+		escalated := s.escalated[n.Provider]
+		s.failures[n.Provider] = 0
+		s.escalated[n.Provider] = false
+		if n.Kind == Recovered && s.threshold > 0 && !escalated {
+			return false
+		}
+	}
+	if s.dedup > 0 {
+		k := key(n)
+		if last, ok := s.seen[k]; ok && n.Time.Sub(last) < s.dedup {
+			return false
+		}
+		s.seen[k] = n.Time
+		for k, t := range s.seen {
+			if n.Time.Sub(t) >= s.dedup {
+				delete(s.seen, k)
+			}
+		}
+	}
+	if n.Kind == Changed && s.digest > 0 {
+		if s.lastDigest.IsZero() {
+			s.lastDigest = n.Time
+		}
+		s.pending = append(s.pending, n)
+		if s.timer == nil {
+			var t *time.Timer
+			t = time.AfterFunc(s.digest-n.Time.Sub(s.lastDigest), func() {
+				s.mu.Lock()
+				current := s.timer == t
+				s.mu.Unlock()
+				// a timer that fired while its digest was delivered must not deliver the next one early;
+				// there is no caller to return the error to, the notifiers log or retry on their own
+				if current {
+					s.flush(context.Background(), true)
+				}
+			})
+			s.timer = t
+		}
+		return false
+	}
+	return true
+}
+
+// Flush delivers the pending digest immediately, if there is one
+func (s *Shaper) Flush(ctx context.Context) error {
+	return s.flush(ctx, true)
+}
+
+// Close stops the digest timer and delivers the pending digest, if there is one
+func (s *Shaper) Close() error {
+	return s.Flush(context.Background())
+}
+
+func (s *Shaper) flush(ctx context.Context, force bool) error {
+	s.mu.Lock()
+	now := s.now()
+	if len(s.pending) == 0 || (!force && now.Sub(s.lastDigest) < s.digest) {
+		s.mu.Unlock()
+		return nil
+	}
+	d := Notification{
+		Kind:    Digest,
+		Time:    now,
+		Changes: s.pending,
+	}
+	s.pending = nil
+	s.lastDigest = now
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	s.mu.Unlock()
+	return s.next.Notify(ctx, d)
+}
@@ -0,0 +1,74 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+type recorder struct {
+	got []Notification
+}
+
+func (r *recorder) Notify(ctx context.Context, n Notification) error {
+	r.got = append(r.got, n)
+	return nil
+}
+
+func TestShaper(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1000, 0)
+	rec := &recorder{}
+	s := Shape(rec, Dedup(time.Hour), EscalateAfter(3), DigestEvery(24*time.Hour))
+	s.now = func() time.Time { return now }
+
+	fail := Notification{Kind: Failed, Provider: "dynu", Err: errors.New("servererror")}
+	for i := 0; i < 2; i++ {
+		s.Notify(ctx, fail)
+	}
+	if len(rec.got) != 0 {
+		t.Fatalf("expected failures below the threshold to be suppressed, got %v", rec.got)
+	}
+	s.Notify(ctx, fail)
+	s.Notify(ctx, fail)
+	if len(rec.got) != 1 || rec.got[0].Kind != Failed {
+		t.Fatalf("expected one escalated failure, got %v", rec.got)
+	}
+	s.Notify(ctx, Notification{Kind: Recovered, Provider: "dynu"})
+	if len(rec.got) != 2 || rec.got[1].Kind != Recovered {
+		t.Fatalf("expected a recovery after an escalated failure, got %v", rec.got)
+	}
+
+	rec.got = nil
+	for _, ip := range []string{"203.0.113.1", "203.0.113.2"} {
+		now = now.Add(time.Hour)
+		s.Notify(ctx, Notification{Kind: Changed, Provider: "dynu", NewIPs: []net.IP{net.ParseIP(ip)}})
+	}
+	if len(rec.got) != 0 {
+		t.Fatalf("expected changes to be held for the digest, got %v", rec.got)
+	}
+	now = now.Add(24 * time.Hour)
+	s.Notify(ctx, Notification{Kind: Recovered, Provider: "dynu"})
+	if len(rec.got) != 1 || rec.got[0].Kind != Digest || len(rec.got[0].Changes) != 2 {
+		t.Fatalf("expected a digest of two changes, got %v", rec.got)
+	}
+}
+
+func TestShaperDigestTimer(t *testing.T) {
+	got := make(chan Notification, 1)
+	s := Shape(NotifierFunc(func(ctx context.Context, n Notification) error {
+		got <- n
+		return nil
+	}), DigestEvery(10*time.Millisecond))
+	s.Notify(context.Background(), Notification{Kind: Changed, Provider: "dynu", NewIPs: []net.IP{net.ParseIP("203.0.113.1")}})
+	select {
+	case n := <-got:
+		if n.Kind != Digest || len(n.Changes) != 1 {
+			t.Errorf("expected a digest of one change, got %v", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the digest to be delivered without another notification")
+	}
+}
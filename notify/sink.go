@@ -0,0 +1,54 @@
+package notify
+
+import (
+	"context"
+
+	"github.com/justenwalker/ddns/event"
+)
+
+// Sink adapts a Notifier to an event sink.
+// Changed, Failed, Recovered, RolledBack and Promoted events are delivered as notifications, as are Verified events that failed;
+// other events are ignored.
+func Sink(n Notifier) event.Sink {
+	return event.SinkFunc(func(ctx context.Context, ev event.Event) error {
+		nt, ok := FromEvent(ev)
+		if !ok {
+			return nil
+		}
+		return n.Notify(ctx, nt)
+	})
+}
+
+// FromEvent converts an event to a notification.
+// It returns false for events that do not have a corresponding notification kind.
+func FromEvent(ev event.Event) (Notification, bool) {
+	var kind Kind
+	switch ev.Type {
+	case event.Changed:
+		kind = Changed
+	case event.Failed:
+		kind = Failed
+	case event.Recovered:
+		kind = Recovered
+	case event.Verified:
+		if ev.Err == nil {
+			return Notification{}, false
+		}
+		kind = Unreachable
+	case event.RolledBack:
+		kind = RolledBack
+	case event.Promoted:
+		kind = Promoted
+	default:
+		return Notification{}, false
+	}
+	return Notification{
+		Kind:      kind,
+		Time:      ev.Time,
+		Provider:  ev.Provider,
+		Hostnames: ev.Hostnames,
+		OldIPs:    ev.OldIPs,
+		NewIPs:    ev.NewIPs,
+		Err:       ev.Err,
+	}, true
+}
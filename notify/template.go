@@ -0,0 +1,94 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// Formatter renders a notification as message text
+type Formatter interface {
+	Format(n Notification) (string, error)
+}
+
+// FormatterFunc adapts a function to the Formatter interface
+type FormatterFunc func(n Notification) (string, error)
+
+// Format calls f(n)
+func (f FormatterFunc) Format(n Notification) (string, error) {
+	return f(n)
+}
+
+// DefaultFormatter formats notifications using Notification.String
+var DefaultFormatter Formatter = FormatterFunc(func(n Notification) (string, error) {
+	return n.String(), nil
+})
+
+// Message formats n with f, or with DefaultFormatter if f is nil
+func Message(f Formatter, n Notification) (string, error) {
+	if f == nil {
+		f = DefaultFormatter
+	}
+	return f.Format(n)
+}
+
+// Template is a Formatter backed by a text/template.
+//
+// The template is executed with the Notification as its data, so it can refer to
+// {{.Kind}}, {{.Time}}, {{.Provider}}, {{.Hostnames}}, {{.OldIPs}}, {{.NewIPs}}, {{.Err}} and {{.Changes}}.
+// The functions "ips" (formats a list of addresses) and "join" (strings.Join) are available as well, e.g.:
+//
+//	{{join .Hostnames ", "}} is now {{ips .NewIPs}}{{if .Err}} ({{.Err}}){{end}}
+type Template struct {
+	tmpl *template.Template
+}
+
+// TemplateFuncs are the functions available to notification templates
+var TemplateFuncs = template.FuncMap{
+	"ips":  formatIPs,
+	"join": strings.Join,
+}
+
+// ParseTemplate parses a notification template
+func ParseTemplate(text string) (*Template, error) {
+	tmpl, err := template.New("notification").Funcs(TemplateFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &Template{tmpl: tmpl}, nil
+}
+
+// Format executes the template with the notification
+func (t *Template) Format(n Notification) (string, error) {
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, n); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+type writer struct {
+	mu  sync.Mutex
+	w   io.Writer
+	fmt Formatter
+}
+
+// Writer returns a Notifier that writes each formatted notification to w on its own line.
+// If f is nil, DefaultFormatter is used.
+func Writer(w io.Writer, f Formatter) Notifier {
+	return &writer{w: w, fmt: f}
+}
+
+func (w *writer) Notify(ctx context.Context, n Notification) error {
+	msg, err := Message(w.fmt, n)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err = io.WriteString(w.w, strings.TrimRight(msg, "\n")+"\n")
+	return err
+}
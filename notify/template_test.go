@@ -0,0 +1,36 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestTemplate(t *testing.T) {
+	tmpl, err := ParseTemplate(`[{{.Provider}}] {{join .Hostnames ","}}: {{ips .OldIPs}} -> {{ips .NewIPs}}{{if .Err}} ({{.Err}}){{end}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	nt := Writer(&buf, tmpl)
+	nt.Notify(context.Background(), Notification{
+		Kind:      Changed,
+		Provider:  "dynu",
+		Hostnames: []string{"a.example.com", "b.example.com"},
+		OldIPs:    []net.IP{net.ParseIP("203.0.113.1")},
+		NewIPs:    []net.IP{net.ParseIP("203.0.113.2"), net.ParseIP("2001:db8::2")},
+	})
+	nt.Notify(context.Background(), Notification{
+		Kind:      Failed,
+		Provider:  "dynu",
+		Hostnames: []string{"a.example.com"},
+		Err:       errors.New("badauth"),
+	})
+	want := "[dynu] a.example.com,b.example.com: 203.0.113.1 -> 203.0.113.2, 2001:db8::2\n" +
+		"[dynu] a.example.com: (none) -> (none) (badauth)\n"
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
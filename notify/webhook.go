@@ -0,0 +1,242 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/justenwalker/ddns/internal/httpreq"
+)
+
+// HTTPRequester makes http requests and returns responses.
+// *http.Client implicitly implements HTTPRequester and can be provided wherever this interface is requested.
+type HTTPRequester = httpreq.Requester
+
+// SignatureHeader holds the HMAC-SHA256 of the body of a webhook request signed with a Secret, as
+// "sha256=" followed by the signature in hex
+const SignatureHeader = "X-DDNS-Signature"
+
+// WebhookOption sets the options of the webhook, chat and push notifiers
+type WebhookOption func(*webhook)
+
+// Secret signs the body of each request with key in the SignatureHeader, so that the receiver can check that
+// the request comes from ddns and was not altered
+func Secret(key string) WebhookOption {
+	return func(w *webhook) {
+		w.secret = []byte(key)
+	}
+}
+
+// Headers adds headers to each request, such as an Authorization header expected by the receiver
+func Headers(h map[string]string) WebhookOption {
+	return func(w *webhook) {
+		for k, v := range h {
+			w.header.Set(k, v)
+		}
+	}
+}
+
+// Retries sets how many times a request is retried after a network error, or a 429 or 5xx response. The delay
+// before each retry doubles from one second, unless the response has a Retry-After header. The default is 3.
+func Retries(n int) WebhookOption {
+	return func(w *webhook) {
+		w.retries = n
+	}
+}
+
+// WebhookFormat formats the message field of the payloads with f instead of DefaultFormatter
+func WebhookFormat(f Formatter) WebhookOption {
+	return func(w *webhook) {
+		w.fmt = f
+	}
+}
+
+// WebhookClient sets a custom HTTP client for the requests
+func WebhookClient(hc HTTPRequester) WebhookOption {
+	return func(w *webhook) {
+		w.client = hc
+	}
+}
+
+type webhook struct {
+	// name prefixes the errors
+	name    string
+	url     string
+	secret  []byte
+	header  http.Header
+	retries int
+	fmt     Formatter
+	client  HTTPRequester
+	// body encodes the request body of a notification; it is the JSON Payload by default
+	body func(n Notification, f Formatter) (interface{}, error)
+	// retryDelay is the delay before the first retry
+	retryDelay time.Duration
+}
+
+// Webhook returns a Notifier that POSTs each notification to url as a JSON Payload
+func Webhook(url string, options ...WebhookOption) Notifier {
+	return newWebhook("webhook", url, func(n Notification, f Formatter) (interface{}, error) {
+		return NewPayload(n, f)
+	}, options)
+}
+
+func newWebhook(name, url string, body func(Notification, Formatter) (interface{}, error), options []WebhookOption) *webhook {
+	w := &webhook{
+		name:       name,
+		url:        url,
+		header:     make(http.Header),
+		retries:    3,
+		client:     http.DefaultClient,
+		body:       body,
+		retryDelay: time.Second,
+	}
+	for _, opt := range options {
+		opt(w)
+	}
+	return w
+}
+
+// Payload is the JSON body of a webhook request
+type Payload struct {
+	// Kind is changed, failed, recovered, digest, unreachable, rolledback or promoted
+	Kind      string    `json:"kind"`
+	Time      time.Time `json:"time"`
+	Provider  string    `json:"provider,omitempty"`
+	Hostnames []string  `json:"hostnames,omitempty"`
+	OldIPs    []string  `json:"old_ips,omitempty"`
+	NewIPs    []string  `json:"new_ips,omitempty"`
+	// Result is "success", or "failure" if the notification has an error, in Error
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+	// Message is the notification formatted as text
+	Message string `json:"message"`
+	// Changes are the notifications summarized by a digest
+	Changes []Payload `json:"changes,omitempty"`
+}
+
+// NewPayload returns the payload of n, with its message formatted by f
+func NewPayload(n Notification, f Formatter) (Payload, error) {
+	msg, err := Message(f, n)
+	if err != nil {
+		return Payload{}, err
+	}
+	p := Payload{
+		Kind:      n.Kind.String(),
+		Time:      n.Time,
+		Provider:  n.Provider,
+		Hostnames: n.Hostnames,
+		OldIPs:    ipStrings(n.OldIPs),
+		NewIPs:    ipStrings(n.NewIPs),
+		Result:    "success",
+		Message:   msg,
+	}
+	if n.Err != nil {
+		p.Result, p.Error = "failure", n.Err.Error()
+	}
+	for _, c := range n.Changes {
+		cp, err := NewPayload(c, f)
+		if err != nil {
+			return Payload{}, err
+		}
+		p.Changes = append(p.Changes, cp)
+	}
+	return p, nil
+}
+
+func (w *webhook) Notify(ctx context.Context, n Notification) error {
+	v, err := w.body(n, w.fmt)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	delay := w.retryDelay
+	for attempt := 0; ; attempt++ {
+		retryAfter, err := w.post(ctx, body)
+		if err == nil || retryAfter < 0 || attempt >= w.retries {
+			return err
+		}
+		if retryAfter == 0 {
+			retryAfter = delay
+			delay *= 2
+		}
+		t := time.NewTimer(retryAfter)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+}
+
+// post sends body once. If it fails, it returns how long to wait before retrying: zero for the default delay,
+// or a negative duration if the request is not to be retried.
+func (w *webhook) post(ctx context.Context, body []byte) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return -1, err
+	}
+	for k, v := range w.header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(w.secret) > 0 {
+		mac := hmac.New(sha256.New, w.secret)
+		mac.Write(body)
+		req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		// the URL is left out of the error, as it can hold a token
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			err = uerr.Err
+		}
+		return 0, fmt.Errorf("%s: %w", w.name, err)
+	}
+	defer resp.Body.Close()
+	reply, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode/100 == 2 {
+		return 0, nil
+	}
+	err = fmt.Errorf("%s: %s", w.name, resp.Status)
+	if line, _, _ := strings.Cut(strings.TrimSpace(string(reply)), "\n"); line != "" {
+		if len(line) > 200 {
+			line = line[:200] + "..."
+		}
+		err = fmt.Errorf("%s: %s: %s", w.name, resp.Status, line)
+	}
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+		return -1, err
+	}
+	if s, perr := strconv.Atoi(resp.Header.Get("Retry-After")); perr == nil && s > 0 {
+		return time.Duration(s) * time.Second, err
+	}
+	return 0, err
+}
+
+func ipStrings(ips []net.IP) []string {
+	if len(ips) == 0 {
+		return nil
+	}
+	ss := make([]string, len(ips))
+	for i, ip := range ips {
+		ss[i] = ip.String()
+	}
+	return ss
+}
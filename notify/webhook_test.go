@@ -0,0 +1,71 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhook(t *testing.T) {
+	var requests int
+	var body []byte
+	var signature, auth string
+	status := http.StatusServiceUnavailable
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		body, _ = io.ReadAll(r.Body)
+		signature, auth = r.Header.Get(SignatureHeader), r.Header.Get("Authorization")
+		if requests == 1 {
+			w.WriteHeader(status)
+		}
+	}))
+	defer srv.Close()
+	n := Webhook(srv.URL, Secret("key"), Headers(map[string]string{"Authorization": "Bearer token"}))
+	n.(*webhook).retryDelay = time.Millisecond
+
+	changed := Notification{
+		Kind:      Changed,
+		Time:      time.Unix(1000, 0).UTC(),
+		Provider:  "home",
+		Hostnames: []string{"home.example.com"},
+		OldIPs:    []net.IP{net.ParseIP("203.0.113.1")},
+		NewIPs:    []net.IP{net.ParseIP("203.0.113.2")},
+	}
+	if err := n.Notify(context.Background(), changed); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 2 {
+		t.Errorf("expected the request to be retried after a 503, got %d requests", requests)
+	}
+	mac := hmac.New(sha256.New, []byte("key"))
+	mac.Write(body)
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); signature != want || auth != "Bearer token" {
+		t.Errorf("got signature %q and authorization %q, want %q", signature, auth, want)
+	}
+	var p Payload
+	if err := json.Unmarshal(body, &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Kind != "changed" || p.Result != "success" || p.OldIPs[0] != "203.0.113.1" || p.NewIPs[0] != "203.0.113.2" ||
+		p.Provider != "home" || p.Message != changed.String() {
+		t.Errorf("unexpected payload %s", body)
+	}
+
+	requests, status = 0, http.StatusBadRequest
+	failed := Notification{Kind: Failed, Provider: "home", Err: errors.New("badauth")}
+	if err := n.Notify(context.Background(), failed); err == nil || requests != 1 {
+		t.Errorf("expected a 400 to fail without retrying, got %v after %d requests", err, requests)
+	}
+	if err := json.Unmarshal(body, &p); err != nil || p.Result != "failure" || p.Error != "badauth" {
+		t.Errorf("unexpected payload %s (%v)", body, err)
+	}
+}
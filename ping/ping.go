@@ -0,0 +1,195 @@
+// Package ping reports that the daemons are running to a dead man's switch, such as Healthchecks.io or Cronitor,
+// which alerts when the pings stop. After every step of a daemon, the Pinger requests its URL if every daemon
+// is working, or its fail URL with the reason if one is failing:
+//
+//	p := ping.New("https://hc-ping.com/" + uuid)
+//	d := daemon.New(src, providers, daemon.AfterStep(p.Step()))
+//
+// A daemon is failing while its addresses cannot be detected, or one of its providers has failed MaxFailures
+// consecutive times.
+package ping // import "github.com/justenwalker/ddns/ping"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/justenwalker/ddns/internal/httpreq"
+	"github.com/justenwalker/ddns/logging"
+	"github.com/justenwalker/ddns/state"
+)
+
+// Logger for printing the pings that fail
+type Logger interface {
+	Log(format string, v ...interface{})
+}
+
+// HTTPRequester makes http requests and returns responses.
+// *http.Client implicitly implements HTTPRequester and can be provided wherever this interface is requested.
+type HTTPRequester = httpreq.Requester
+
+// Option sets pinger options
+type Option func(*Pinger)
+
+// FailURL sets the URL requested when a daemon is failing. The default appends /fail to the path of the URL,
+// as Healthchecks.io expects; Cronitor telemetry URLs take ?state=fail instead.
+func FailURL(u string) Option {
+	return func(p *Pinger) {
+		p.failURL = u
+	}
+}
+
+// MaxFailures sets how many consecutive times a provider may fail before the fail URL is requested;
+// the default is 1
+func MaxFailures(n int) Option {
+	return func(p *Pinger) {
+		p.maxFailures = n
+	}
+}
+
+// Timeout sets how long a ping may take; the default is 10 seconds
+func Timeout(d time.Duration) Option {
+	return func(p *Pinger) {
+		p.timeout = d
+	}
+}
+
+// Client sets a custom HTTP client for the pings
+func Client(hc HTTPRequester) Option {
+	return func(p *Pinger) {
+		p.client = hc
+	}
+}
+
+// Log logs the pings that fail to l
+func Log(l Logger) Option {
+	return func(p *Pinger) {
+		p.logger = l
+	}
+}
+
+// Pinger pings the URLs of a dead man's switch after the steps of the daemons
+type Pinger struct {
+	url         string
+	failURL     string
+	maxFailures int
+	timeout     time.Duration
+	client      HTTPRequester
+	logger      Logger
+
+	mu sync.Mutex
+	// failing holds why each daemon is failing, or the empty string
+	failing []string
+}
+
+// New constructs a pinger of the URL u for no daemons; each daemon is added with Step
+func New(u string, options ...Option) *Pinger {
+	p := &Pinger{
+		url:         u,
+		maxFailures: 1,
+		timeout:     10 * time.Second,
+		client:      http.DefaultClient,
+	}
+	for _, opt := range options {
+		opt(p)
+	}
+	if p.failURL == "" {
+		p.failURL = failURL(u)
+	}
+	return p
+}
+
+// failURL appends /fail to the path of u
+func failURL(u string) string {
+	pu, err := url.Parse(u)
+	if err != nil {
+		return strings.TrimSuffix(u, "/") + "/fail"
+	}
+	pu.Path = strings.TrimSuffix(pu.Path, "/") + "/fail"
+	pu.RawPath = ""
+	return pu.String()
+}
+
+// Step returns the function for a daemon to call after each of its steps, with daemon.AfterStep
+func (p *Pinger) Step() func(ctx context.Context, s *state.Snapshot) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failing = append(p.failing, "")
+	i := len(p.failing) - 1
+	return func(ctx context.Context, s *state.Snapshot) {
+		p.mu.Lock()
+		p.failing[i] = p.failure(s)
+		var reasons []string
+		for _, r := range p.failing {
+			if r != "" {
+				reasons = append(reasons, r)
+			}
+		}
+		p.mu.Unlock()
+		if err := p.Ping(ctx, strings.Join(reasons, "\n")); err != nil {
+			logging.Print(p.logger, slog.LevelWarn, []slog.Attr{slog.String("error", err.Error())}, "ping: %v", err)
+		}
+	}
+}
+
+// failure returns why the daemon that saved s is failing, or the empty string
+func (p *Pinger) failure(s *state.Snapshot) string {
+	if s.DetectionFailures > 0 {
+		return fmt.Sprintf("detection failed %d consecutive times: %s", s.DetectionFailures, s.DetectionError)
+	}
+	names := make([]string, 0, len(s.Providers))
+	for name := range s.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var reasons []string
+	for _, name := range names {
+		if ps := s.Providers[name]; p.maxFailures > 0 && ps.Failures >= p.maxFailures {
+			reasons = append(reasons, fmt.Sprintf("%s failed %d consecutive times: %s", name, ps.Failures, ps.LastError))
+		}
+	}
+	return strings.Join(reasons, "\n")
+}
+
+// Ping requests the URL if failure is empty, or posts failure to the fail URL otherwise
+func (p *Pinger) Ping(ctx context.Context, failure string) error {
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+	method, u, body := http.MethodGet, p.url, io.Reader(nil)
+	if failure != "" {
+		method, u, body = http.MethodPost, p.failURL, strings.NewReader(failure)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return err
+	}
+	if failure != "" {
+		req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		// the URL is left out of the error, as it identifies the check
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			err = uerr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected response %s", resp.Status)
+	}
+	return nil
+}
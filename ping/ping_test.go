@@ -0,0 +1,52 @@
+package ping
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/justenwalker/ddns/state"
+)
+
+func TestPinger(t *testing.T) {
+	var pings []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		pings = append(pings, strings.TrimSpace(r.Method+" "+r.URL.RequestURI()+" "+string(body)))
+	}))
+	defer srv.Close()
+	p := New(srv.URL + "/ping/check?rid=1")
+	first, second := p.Step(), p.Step()
+	ctx := context.Background()
+
+	first(ctx, state.New())
+	failing := state.New()
+	failing.Providers["home"] = state.Provider{Failures: 2, LastError: "badauth"}
+	second(ctx, failing)
+	first(ctx, &state.Snapshot{DetectionFailures: 1, DetectionError: "timeout"})
+	first(ctx, state.New())
+	second(ctx, state.New())
+	want := []string{
+		"GET /ping/check?rid=1",
+		"POST /ping/check/fail?rid=1 home failed 2 consecutive times: badauth",
+		"POST /ping/check/fail?rid=1 detection failed 1 consecutive times: timeout\nhome failed 2 consecutive times: badauth",
+		"POST /ping/check/fail?rid=1 home failed 2 consecutive times: badauth",
+		"GET /ping/check?rid=1",
+	}
+	if strings.Join(pings, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got pings\n%s\nwant\n%s", strings.Join(pings, "\n"), strings.Join(want, "\n"))
+	}
+
+	p = New(srv.URL+"/p/key/ddns?state=complete", FailURL(srv.URL+"/p/key/ddns?state=fail"), MaxFailures(3))
+	step := p.Step()
+	pings = nil
+	step(ctx, failing)
+	failing.Providers["home"] = state.Provider{Failures: 3, LastError: "badauth"}
+	step(ctx, failing)
+	if len(pings) != 2 || pings[0] != "GET /p/key/ddns?state=complete" || !strings.HasPrefix(pings[1], "POST /p/key/ddns?state=fail home failed 3") {
+		t.Errorf("unexpected pings %q", pings)
+	}
+}
@@ -0,0 +1,38 @@
+// Package power reports whether the host is running on battery or a metered network,
+// so that polling can be reduced on laptops, single-board computers and cellular connections
+package power // import "github.com/justenwalker/ddns/power"
+
+import (
+	"context"
+)
+
+// State is the power and network cost state of the host
+type State struct {
+	OnBattery bool
+	Metered   bool
+}
+
+// Constrained returns true if the host should reduce background activity
+func (s State) Constrained() bool {
+	return s.OnBattery || s.Metered
+}
+
+// Sensor reports the power state
+type Sensor interface {
+	State(ctx context.Context) (State, error)
+}
+
+// SensorFunc adapts a function to the Sensor interface
+type SensorFunc func(ctx context.Context) (State, error)
+
+// State calls f(ctx)
+func (f SensorFunc) State(ctx context.Context) (State, error) {
+	return f(ctx)
+}
+
+// System returns the sensor of the running platform.
+// Conditions the platform cannot report, such as metered networks outside of NetworkManager,
+// are reported as unconstrained.
+func System() Sensor {
+	return SensorFunc(systemState)
+}
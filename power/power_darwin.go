@@ -0,0 +1,15 @@
+package power
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+)
+
+func systemState(ctx context.Context) (State, error) {
+	out, err := exec.CommandContext(ctx, "pmset", "-g", "batt").Output()
+	if err != nil {
+		return State{}, err
+	}
+	return State{OnBattery: strings.Contains(string(out), "'Battery Power'")}, nil
+}
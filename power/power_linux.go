@@ -0,0 +1,69 @@
+package power
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const powerSupplyDir = "/sys/class/power_supply"
+
+func systemState(ctx context.Context) (State, error) {
+	s := State{OnBattery: onBattery(powerSupplyDir)}
+	s.Metered = meteredNetworkManager(ctx)
+	return s, nil
+}
+
+// onBattery reads the power supplies in dir. The host is on battery when a battery is discharging,
+// or when it has mains supplies and none of them is online.
+func onBattery(dir string) bool {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	var mains, mainsOnline, discharging bool
+	for _, e := range entries {
+		read := func(name string) string {
+			data, _ := ioutil.ReadFile(filepath.Join(dir, e.Name(), name))
+			return strings.TrimSpace(string(data))
+		}
+		switch read("type") {
+		case "Mains":
+			mains = true
+			if read("online") == "1" {
+				mainsOnline = true
+			}
+		case "Battery":
+			if read("status") == "Discharging" {
+				discharging = true
+			}
+		}
+	}
+	return discharging || mains && !mainsOnline
+}
+
+// meteredNetworkManager asks NetworkManager whether any device is on a metered connection.
+// Hosts without NetworkManager are reported as unmetered.
+func meteredNetworkManager(ctx context.Context) bool {
+	out, err := exec.CommandContext(ctx, "nmcli", "-t", "-f", "GENERAL.METERED", "device", "show").Output()
+	if err != nil {
+		return false
+	}
+	return parseMetered(out)
+}
+
+// parseMetered parses "GENERAL.METERED:yes (guessed)" lines
+func parseMetered(out []byte) bool {
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		sp := strings.SplitN(sc.Text(), ":", 2)
+		if len(sp) == 2 && strings.HasPrefix(strings.TrimSpace(sp[1]), "yes") {
+			return true
+		}
+	}
+	return false
+}
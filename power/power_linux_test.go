@@ -0,0 +1,42 @@
+package power
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeSupply(t *testing.T, dir, name string, attrs map[string]string) {
+	os.MkdirAll(filepath.Join(dir, name), 0o755)
+	for k, v := range attrs {
+		if err := os.WriteFile(filepath.Join(dir, name, k), []byte(v+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestOnBattery(t *testing.T) {
+	dir := t.TempDir()
+	writeSupply(t, dir, "AC", map[string]string{"type": "Mains", "online": "1"})
+	writeSupply(t, dir, "BAT0", map[string]string{"type": "Battery", "status": "Charging"})
+	if onBattery(dir) {
+		t.Error("expected AC power")
+	}
+	writeSupply(t, dir, "AC", map[string]string{"online": "0"})
+	writeSupply(t, dir, "BAT0", map[string]string{"status": "Discharging"})
+	if !onBattery(dir) {
+		t.Error("expected battery power")
+	}
+	if onBattery(filepath.Join(dir, "missing")) {
+		t.Error("expected hosts without power supplies to be on AC")
+	}
+}
+
+func TestParseMetered(t *testing.T) {
+	if !parseMetered([]byte("GENERAL.METERED:no\nGENERAL.METERED:yes (guessed)\n")) {
+		t.Error("expected a metered connection")
+	}
+	if parseMetered([]byte("GENERAL.METERED:no\nGENERAL.METERED:unknown\n")) {
+		t.Error("expected no metered connection")
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !windows && !darwin
+
+package power
+
+import (
+	"context"
+)
+
+func systemState(ctx context.Context) (State, error) {
+	return State{}, nil
+}
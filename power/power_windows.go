@@ -0,0 +1,34 @@
+package power
+
+import (
+	"context"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32                 = syscall.NewLazyDLL("kernel32.dll")
+	procGetSystemPowerStatus = kernel32.NewProc("GetSystemPowerStatus")
+)
+
+// systemPowerStatus is SYSTEM_POWER_STATUS
+type systemPowerStatus struct {
+	ACLineStatus        byte
+	BatteryFlag         byte
+	BatteryLifePercent  byte
+	SystemStatusFlag    byte
+	BatteryLifeTime     uint32
+	BatteryFullLifeTime uint32
+}
+
+// acOffline is the ACLineStatus value when running on battery
+const acOffline = 0
+
+func systemState(ctx context.Context) (State, error) {
+	var status systemPowerStatus
+	r, _, err := procGetSystemPowerStatus.Call(uintptr(unsafe.Pointer(&status)))
+	if r == 0 {
+		return State{}, err
+	}
+	return State{OnBattery: status.ACLineStatus == acOffline}, nil
+}
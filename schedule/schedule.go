@@ -0,0 +1,227 @@
+// Package schedule parses cron expressions, so updates can follow the time of day
+// instead of a fixed interval, such as every 5 minutes during the day and hourly at night
+package schedule // import "github.com/justenwalker/ddns/schedule"
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSearch bounds how far ahead Next looks for a matching time, so expressions that can never match,
+// such as the 30th of February, do not loop forever
+const maxSearch = 5 * 366 * 24 * time.Hour
+
+// Schedule returns the times at which to run
+type Schedule interface {
+	// Next returns the first time after t, or the zero time if there is none
+	Next(t time.Time) time.Time
+}
+
+// Cron is a schedule given by a standard five-field cron expression:
+// minute, hour, day of month, month and day of week.
+//
+// Fields accept "*", numbers, ranges ("8-20"), steps ("*/5", "0-30/10") and comma-separated lists;
+// months and days of week also accept three-letter names ("jan", "mon"), and Sunday is 0 or 7.
+// As in cron, a time matches if either the day of month or the day of week matches when both are restricted.
+// The macros @yearly, @monthly, @weekly, @daily and @hourly are accepted as well.
+type Cron struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+	loc                           *time.Location
+}
+
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	monthNames = []string{"", "jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	dowNames   = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+// Parse parses one or more cron expressions separated by semicolons, evaluated in loc (time.Local if nil).
+// An expression may be prefixed with "CRON_TZ=Area/City" to evaluate it in another time zone.
+// Several expressions are combined into a Union.
+func Parse(spec string, loc *time.Location) (Schedule, error) {
+	var u Union
+	for _, expr := range strings.Split(spec, ";") {
+		if expr = strings.TrimSpace(expr); expr == "" {
+			continue
+		}
+		c, err := ParseCron(expr, loc)
+		if err != nil {
+			return nil, err
+		}
+		u = append(u, c)
+	}
+	switch len(u) {
+	case 0:
+		return nil, fmt.Errorf("schedule: empty expression")
+	case 1:
+		return u[0], nil
+	}
+	return u, nil
+}
+
+// ParseCron parses a single cron expression evaluated in loc (time.Local if nil)
+func ParseCron(expr string, loc *time.Location) (*Cron, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+	fields := strings.Fields(expr)
+	if len(fields) > 0 && (strings.HasPrefix(fields[0], "CRON_TZ=") || strings.HasPrefix(fields[0], "TZ=")) {
+		name := fields[0][strings.Index(fields[0], "=")+1:]
+		var err error
+		if loc, err = time.LoadLocation(name); err != nil {
+			return nil, fmt.Errorf("schedule: %q: %v", expr, err)
+		}
+		fields = fields[1:]
+	}
+	if len(fields) == 1 {
+		if m, ok := macros[fields[0]]; ok {
+			fields = strings.Fields(m)
+		}
+	}
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("schedule: %q: expected 5 fields, got %d", expr, len(fields))
+	}
+	c := &Cron{loc: loc}
+	var err error
+	for i, f := range []struct {
+		name     string
+		bits     *uint64
+		min, max int
+		names    []string
+	}{
+		{"minute", &c.minute, 0, 59, nil},
+		{"hour", &c.hour, 0, 23, nil},
+		{"day of month", &c.dom, 1, 31, nil},
+		{"month", &c.month, 1, 12, monthNames},
+		{"day of week", &c.dow, 0, 7, dowNames},
+	} {
+		if *f.bits, err = parseField(fields[i], f.min, f.max, f.names); err != nil {
+			return nil, fmt.Errorf("schedule: %q: %s: %v", expr, f.name, err)
+		}
+	}
+	// Sunday may be written as 7
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.domAny = fields[2] == "*" || strings.HasPrefix(fields[2], "*/")
+	c.dowAny = fields[4] == "*" || strings.HasPrefix(fields[4], "*/")
+	return c, nil
+}
+
+// parseField parses a comma-separated list of values, ranges and steps into a bit set
+func parseField(field string, min, max int, names []string) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(field, ",") {
+		rng, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(item[i+1:]); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step in %q", item)
+			}
+			rng = item[:i]
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			bounds := strings.SplitN(rng, "-", 2)
+			var err error
+			if lo, err = parseValue(bounds[0], names); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = parseValue(bounds[1], names); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				// "5/15" means from 5 to the maximum in steps of 15
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q is out of range %d-%d", item, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func parseValue(s string, names []string) (int, error) {
+	for i, name := range names {
+		if name != "" && strings.EqualFold(s, name) {
+			return i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	return v, nil
+}
+
+func has(bits uint64, v int) bool {
+	return bits&(1<<uint(v)) != 0
+}
+
+// dayMatches applies the cron rule that restricted day of month and day of week fields match either way
+func (c *Cron) dayMatches(t time.Time) bool {
+	dom, dow := has(c.dom, t.Day()), has(c.dow, int(t.Weekday()))
+	if c.domAny || c.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next returns the first matching minute after t, or the zero time if there is none within five years
+func (c *Cron) Next(t time.Time) time.Time {
+	t = t.In(c.loc).Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxSearch)
+	for t.Before(limit) {
+		var next time.Time
+		switch {
+		case !has(c.month, int(t.Month())):
+			next = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, c.loc)
+		case !c.dayMatches(t):
+			next = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, c.loc)
+		case !has(c.hour, t.Hour()):
+			next = t.Add(time.Duration(60-t.Minute()) * time.Minute)
+		case !has(c.minute, t.Minute()):
+			next = t.Add(time.Minute)
+		default:
+			return t
+		}
+		// a midnight skipped by a DST change normalizes to a time that may not be later
+		if !next.After(t) {
+			next = t.Add(time.Duration(60-t.Minute()) * time.Minute)
+		}
+		t = next
+	}
+	return time.Time{}
+}
+
+// Union is a schedule that runs at the times of all of its schedules
+type Union []Schedule
+
+// Next returns the earliest next time of the schedules
+func (u Union) Next(t time.Time) time.Time {
+	var next time.Time
+	for _, s := range u {
+		if n := s.Next(t); !n.IsZero() && (next.IsZero() || n.Before(next)) {
+			next = n
+		}
+	}
+	return next
+}
@@ -0,0 +1,79 @@
+package schedule_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/justenwalker/ddns/schedule"
+)
+
+func TestNext(t *testing.T) {
+	from := time.Date(2024, time.March, 15, 19, 58, 30, 0, time.UTC) // a Friday
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/5 * * * *", time.Date(2024, time.March, 15, 20, 0, 0, 0, time.UTC)},
+		{"0 * * * *", time.Date(2024, time.March, 15, 20, 0, 0, 0, time.UTC)},
+		{"30 8-18 * * mon-fri", time.Date(2024, time.March, 18, 8, 30, 0, 0, time.UTC)},
+		{"0 0 1 jan *", time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, time.March, 16, 0, 0, 0, 0, time.UTC)},
+		{"0 12 1 * 7", time.Date(2024, time.March, 17, 12, 0, 0, 0, time.UTC)},
+		{"*/5 8-19 * * *; 0 20-23,0-7 * * *", time.Date(2024, time.March, 15, 20, 0, 0, 0, time.UTC)},
+		{"0 0 30 feb *", time.Time{}},
+	}
+	for _, tc := range tests {
+		s, err := schedule.Parse(tc.expr, time.UTC)
+		if err != nil {
+			t.Errorf("%s: %v", tc.expr, err)
+			continue
+		}
+		if got := s.Next(from); !got.Equal(tc.want) {
+			t.Errorf("%s: Next = %v, want %v", tc.expr, got, tc.want)
+		}
+	}
+}
+
+func TestNextDayAndNight(t *testing.T) {
+	s, err := schedule.Parse("*/5 8-19 * * *; 0 20-23,0-7 * * *", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	night := time.Date(2024, time.March, 15, 20, 1, 0, 0, time.UTC)
+	if got, want := s.Next(night), time.Date(2024, time.March, 15, 21, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("at night: Next = %v, want %v", got, want)
+	}
+	day := time.Date(2024, time.March, 15, 9, 1, 0, 0, time.UTC)
+	if got, want := s.Next(day), time.Date(2024, time.March, 15, 9, 5, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("during the day: Next = %v, want %v", got, want)
+	}
+}
+
+func TestTimezone(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	s, err := schedule.Parse("CRON_TZ=America/New_York 0 9 * * *", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := s.Next(time.Date(2024, time.July, 1, 0, 0, 0, 0, time.UTC))
+	if want := time.Date(2024, time.July, 1, 9, 0, 0, 0, ny); !got.Equal(want) {
+		t.Errorf("Next = %v, want %v", got, want)
+	}
+	// 02:30 does not exist on the day clocks spring forward
+	s, _ = schedule.Parse("30 2 * * *", ny)
+	got = s.Next(time.Date(2024, time.March, 10, 0, 0, 0, 0, ny))
+	if want := time.Date(2024, time.March, 11, 2, 30, 0, 0, ny); !got.Equal(want) {
+		t.Errorf("across DST: Next = %v, want %v", got, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * * * mon-", "*/0 * * * *", "5-1 * * * *", "CRON_TZ=Nowhere/Nothing * * * * *"} {
+		if _, err := schedule.Parse(expr, time.UTC); err == nil {
+			t.Errorf("%q: expected an error", expr)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !windows && !darwin
+
+package service
+
+// Install is not supported on this platform
+func Install(c Config) error {
+	return ErrNotSupported
+}
+
+// Uninstall is not supported on this platform
+func Uninstall(name string) error {
+	return ErrNotSupported
+}
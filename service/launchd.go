@@ -0,0 +1,77 @@
+package service
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// networkConfigDir changes whenever the network configuration of a macOS host changes
+const networkConfigDir = "/Library/Preferences/SystemConfiguration"
+
+// Label returns the launchd label of the named service.
+// Names that are already reverse-DNS labels, such as "com.example.ddns", are used as is.
+func Label(name string) string {
+	if strings.Contains(name, ".") {
+		return name
+	}
+	return "com.github.justenwalker." + name
+}
+
+// launchdJob is a launchd daemon definition
+type launchdJob struct {
+	Label     string
+	Program   string
+	Args      []string
+	LogPath   string
+	WatchPath string
+}
+
+// plist renders the job as a property list. The job starts at boot, restarts if it fails,
+// and is started again when WatchPath changes while it is not running.
+func (j launchdJob) plist() []byte {
+	var b bytes.Buffer
+	str := func(s string) {
+		b.WriteString("<string>")
+		xml.EscapeText(&b, []byte(s))
+		b.WriteString("</string>")
+	}
+	key := func(k string) {
+		fmt.Fprintf(&b, "\t<key>%s</key>\n\t", k)
+	}
+	b.WriteString(xml.Header)
+	b.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+	b.WriteString("<plist version=\"1.0\">\n<dict>\n")
+	key("Label")
+	str(j.Label)
+	b.WriteString("\n")
+	key("ProgramArguments")
+	b.WriteString("<array>\n")
+	for _, arg := range append([]string{j.Program}, j.Args...) {
+		b.WriteString("\t\t")
+		str(arg)
+		b.WriteString("\n")
+	}
+	b.WriteString("\t</array>\n")
+	key("RunAtLoad")
+	b.WriteString("<true/>\n")
+	key("KeepAlive")
+	b.WriteString("<dict>\n\t\t<key>SuccessfulExit</key>\n\t\t<false/>\n\t</dict>\n")
+	if j.WatchPath != "" {
+		key("WatchPaths")
+		b.WriteString("<array>\n\t\t")
+		str(j.WatchPath)
+		b.WriteString("\n\t</array>\n")
+	}
+	if j.LogPath != "" {
+		key("StandardOutPath")
+		str(j.LogPath)
+		b.WriteString("\n")
+		key("StandardErrorPath")
+		str(j.LogPath)
+		b.WriteString("\n")
+	}
+	b.WriteString("</dict>\n</plist>\n")
+	return b.Bytes()
+}
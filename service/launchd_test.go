@@ -0,0 +1,38 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLabel(t *testing.T) {
+	if l := Label("ddns"); l != "com.github.justenwalker.ddns" {
+		t.Errorf("Label(ddns) = %q", l)
+	}
+	if l := Label("com.example.ddns"); l != "com.example.ddns" {
+		t.Errorf("Label(com.example.ddns) = %q", l)
+	}
+}
+
+func TestPlist(t *testing.T) {
+	job := launchdJob{
+		Label:     "com.example.ddns",
+		Program:   "/usr/local/bin/ddns",
+		Args:      []string{"daemon", "-config", "/etc/ddns & co.yaml"},
+		LogPath:   "/Library/Logs/ddns.log",
+		WatchPath: networkConfigDir,
+	}
+	out := string(job.plist())
+	for _, want := range []string{
+		"<key>Label</key>\n\t<string>com.example.ddns</string>",
+		"\t\t<string>/usr/local/bin/ddns</string>\n\t\t<string>daemon</string>",
+		"<string>/etc/ddns &amp; co.yaml</string>",
+		"<key>RunAtLoad</key>\n\t<true/>",
+		"<key>WatchPaths</key>\n\t<array>\n\t\t<string>/Library/Preferences/SystemConfiguration</string>",
+		"<key>StandardErrorPath</key>\n\t<string>/Library/Logs/ddns.log</string>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("plist is missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+// Package service installs and runs the ddns daemon as an operating system service
+package service // import "github.com/justenwalker/ddns/service"
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotSupported is returned on platforms without service support
+var ErrNotSupported = errors.New("service: not supported on this platform")
+
+// Logger for printing logs to the service log
+type Logger interface {
+	Log(format string, v ...interface{})
+}
+
+// Config describes a service to install
+type Config struct {
+	Name        string
+	DisplayName string
+	Description string
+	// Args are passed to the executable when the service starts
+	Args []string
+}
+
+// RunFunc runs the service until ctx is cancelled by a stop or shutdown request
+type RunFunc func(ctx context.Context) error
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/justenwalker/ddns/internal/atomicfile"
+)
+
+// launchDaemonsDir holds the definitions of system-wide launchd daemons
+const launchDaemonsDir = "/Library/LaunchDaemons"
+
+func plistPath(name string) string {
+	return filepath.Join(launchDaemonsDir, Label(name)+".plist")
+}
+
+// Install writes a launchd daemon definition that runs the running executable at boot, restarts it if it fails
+// and starts it when the network configuration changes, then loads it.
+// Output is appended to /Library/Logs/<name>.log.
+func Install(c Config) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return err
+	}
+	path := plistPath(c.Name)
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("service: %s is already installed", path)
+	}
+	job := launchdJob{
+		Label:     Label(c.Name),
+		Program:   exe,
+		Args:      c.Args,
+		LogPath:   filepath.Join("/Library/Logs", c.Name+".log"),
+		WatchPath: networkConfigDir,
+	}
+	if err := atomicfile.Write(path, job.plist(), 0o644); err != nil {
+		return err
+	}
+	if err := launchctl("bootstrap", "system", path); err != nil {
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
+
+// Uninstall unloads the launchd daemon and removes its definition
+func Uninstall(name string) error {
+	path := plistPath(name)
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("service: %s is not installed", Label(name))
+	}
+	if err := launchctl("bootout", "system/"+Label(name)); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func launchctl(args ...string) error {
+	out, err := exec.Command("launchctl", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("service: launchctl %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+//go:build !windows
+
+package service
+
+// IsService returns false on this platform
+func IsService() (bool, error) {
+	return false, nil
+}
+
+// Run is not supported on this platform
+func Run(name string, fn RunFunc) error {
+	return ErrNotSupported
+}
+
+// EventLog is not supported on this platform
+type EventLog struct{}
+
+// OpenEventLog is not supported on this platform
+func OpenEventLog(name string) (*EventLog, error) {
+	return nil, ErrNotSupported
+}
+
+// Log does nothing
+func (l *EventLog) Log(format string, v ...interface{}) {}
+
+// Close does nothing
+func (l *EventLog) Close() error {
+	return nil
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// Install registers the running executable as an automatically started service that restarts on failure,
+// and registers an event log source of the same name
+func Install(c Config) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	if s, err := m.OpenService(c.Name); err == nil {
+		s.Close()
+		return fmt.Errorf("service: %s is already installed", c.Name)
+	}
+	s, err := m.CreateService(c.Name, exe, mgr.Config{
+		DisplayName: c.DisplayName,
+		Description: c.Description,
+		StartType:   mgr.StartAutomatic,
+	}, c.Args...)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	recovery := []mgr.RecoveryAction{{Type: mgr.ServiceRestart, Delay: time.Minute}}
+	if err := s.SetRecoveryActions(recovery, uint32((24 * time.Hour).Seconds())); err != nil {
+		s.Delete()
+		return err
+	}
+	if err := eventlog.InstallAsEventCreate(c.Name, eventlog.Error|eventlog.Warning|eventlog.Info); err != nil {
+		s.Delete()
+		return err
+	}
+	return nil
+}
+
+// Uninstall stops and removes the service and its event log source
+func Uninstall(name string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	s, err := m.OpenService(name)
+	if err != nil {
+		return fmt.Errorf("service: %s is not installed", name)
+	}
+	defer s.Close()
+	// the service may not be running
+	s.Control(svc.Stop)
+	if err := s.Delete(); err != nil {
+		return err
+	}
+	return eventlog.Remove(name)
+}
+
+// IsService returns true if the process was started by the service control manager
+func IsService() (bool, error) {
+	return svc.IsWindowsService()
+}
+
+// Run runs fn under the service control manager until it returns or the service is stopped
+func Run(name string, fn RunFunc) error {
+	return svc.Run(name, handler(fn))
+}
+
+type handler RunFunc
+
+// exitFailed is the service-specific exit code reported when the run function fails
+const exitFailed = 1
+
+func (h handler) Execute(args []string, requests <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	changes <- svc.Status{State: svc.StartPending}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- h(ctx)
+	}()
+	changes <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case err := <-done:
+			if err != nil && !errors.Is(err, context.Canceled) {
+				return true, exitFailed
+			}
+			return false, 0
+		case r := <-requests:
+			switch r.Cmd {
+			case svc.Interrogate:
+				changes <- r.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				changes <- svc.Status{State: svc.StopPending}
+				cancel()
+			}
+		}
+	}
+}
+
+// EventLog is a Logger that writes informational entries to the Windows event log
+type EventLog struct {
+	log *eventlog.Log
+}
+
+// OpenEventLog opens the event log source registered by Install
+func OpenEventLog(name string) (*EventLog, error) {
+	l, err := eventlog.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &EventLog{log: l}, nil
+}
+
+// eventID is the event identifier of every entry; EventCreate sources accept IDs 1 to 1000
+const eventID = 1
+
+// Log writes an informational entry
+func (l *EventLog) Log(format string, v ...interface{}) {
+	l.log.Info(eventID, fmt.Sprintf(format, v...))
+}
+
+// Close closes the event log
+func (l *EventLog) Close() error {
+	return l.log.Close()
+}
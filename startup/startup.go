@@ -0,0 +1,119 @@
+// Package startup waits for the host to be ready before the daemon's first update, as routers and single-board
+// computers often start services before they have a default route, and without a real-time clock, before their
+// clock is synchronized. Updating earlier publishes no address or a wrong one, and fails TLS verification.
+package startup // import "github.com/justenwalker/ddns/startup"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// ErrTimeout is returned by Wait when the check still fails once the timeout has elapsed
+var ErrTimeout = errors.New("startup: timed out")
+
+// Earliest is the time before which the clock is assumed not to be set, on platforms that cannot tell whether
+// it is synchronized
+var Earliest = time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Logger for printing debug logs from this package
+type Logger interface {
+	Log(format string, v ...interface{})
+}
+
+// Check returns nil once the host is ready, or why it is not
+type Check func() error
+
+// probes are documentation addresses: connecting a UDP socket to them sends nothing, but needs a route
+var probes = []string{"192.0.2.1:9", "[2001:db8::1]:9"}
+
+// Network checks that the host has a route to the internet, over IPv4 or IPv6
+func Network() Check {
+	return func() error {
+		var errs []error
+		for _, addr := range probes {
+			c, err := net.Dial("udp", addr)
+			if err == nil {
+				c.Close()
+				return nil
+			}
+			errs = append(errs, err)
+		}
+		return fmt.Errorf("no default route: %w", errors.Join(errs...))
+	}
+}
+
+// TimeSync checks that the clock is synchronized: on Linux, that the kernel clock is marked synchronized by an
+// NTP client such as chrony, ntpd or systemd-timesyncd, and on other platforms that it is later than Earliest
+func TimeSync() Check {
+	return timeSynced
+}
+
+// clockSet checks that the clock is later than Earliest
+func clockSet() error {
+	if now := time.Now(); now.Before(Earliest) {
+		return fmt.Errorf("the clock is not set: it is %s", now.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// Option sets the options of Wait
+type Option func(*waiter)
+
+type waiter struct {
+	logger Logger
+	poll   time.Duration
+}
+
+// Log logs the checks that fail using the given Logger
+func Log(l Logger) Option {
+	return func(w *waiter) {
+		w.logger = l
+	}
+}
+
+// Poll sets how often the check is run; the default is 1 second
+func Poll(d time.Duration) Option {
+	return func(w *waiter) {
+		w.poll = d
+	}
+}
+
+// Wait runs check until it succeeds, ctx is done or timeout has elapsed, returning ErrTimeout wrapping the last
+// failure in the latter case
+func Wait(ctx context.Context, check Check, timeout time.Duration, options ...Option) error {
+	w := waiter{poll: time.Second}
+	for _, opt := range options {
+		opt(&w)
+	}
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(w.poll)
+	defer ticker.Stop()
+	var last error
+	for {
+		err := check()
+		if err == nil {
+			return nil
+		}
+		if last == nil || err.Error() != last.Error() {
+			w.logf("startup: waiting: %v", err)
+		}
+		last = err
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline.C:
+			return fmt.Errorf("%w: %w", ErrTimeout, last)
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *waiter) logf(format string, v ...interface{}) {
+	if w.logger != nil {
+		w.logger.Log(format, v...)
+	}
+}
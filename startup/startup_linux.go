@@ -0,0 +1,26 @@
+package startup
+
+import (
+	"errors"
+	"syscall"
+)
+
+// from linux/timex.h
+const (
+	staUnsync = 0x40
+	timeError = 5
+)
+
+// timeSynced asks the kernel whether an NTP client has synchronized the clock, or if it cannot tell, whether the
+// clock is set
+func timeSynced() error {
+	var tx syscall.Timex
+	state, err := syscall.Adjtimex(&tx)
+	if err != nil {
+		return clockSet()
+	}
+	if state == timeError || tx.Status&staUnsync != 0 {
+		return errors.New("the clock is not synchronized by NTP")
+	}
+	return nil
+}
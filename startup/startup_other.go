@@ -0,0 +1,8 @@
+//go:build !linux
+
+package startup
+
+// timeSynced checks that the clock is set, as the platform does not report whether it is synchronized
+func timeSynced() error {
+	return clockSet()
+}
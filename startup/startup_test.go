@@ -0,0 +1,54 @@
+package startup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type logs []string
+
+func (l *logs) Log(format string, v ...interface{}) {
+	*l = append(*l, fmt.Sprintf(format, v...))
+}
+
+func TestWait(t *testing.T) {
+	var calls int
+	var l logs
+	err := Wait(context.Background(), func() error {
+		if calls++; calls < 3 {
+			return errors.New("no default route")
+		}
+		return nil
+	}, time.Minute, Poll(time.Millisecond), Log(&l))
+	if err != nil || calls != 3 {
+		t.Errorf("expected success on the third check, got %v after %d", err, calls)
+	}
+	if len(l) != 1 {
+		t.Errorf("expected the repeated failure to be logged once, got %q", l)
+	}
+
+	err = Wait(context.Background(), func() error { return errors.New("not synchronized") }, 10*time.Millisecond, Poll(time.Millisecond))
+	if !errors.Is(err, ErrTimeout) || err.Error() != "startup: timed out: not synchronized" {
+		t.Errorf("expected a timeout with the last failure, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := Wait(ctx, func() error { return errors.New("no default route") }, time.Minute); err != context.Canceled {
+		t.Errorf("expected the context error, got %v", err)
+	}
+}
+
+func TestClockSet(t *testing.T) {
+	defer func(earliest time.Time) { Earliest = earliest }(Earliest)
+	if err := clockSet(); err != nil {
+		t.Error(err)
+	}
+	Earliest = time.Now().Add(time.Hour)
+	if err := clockSet(); err == nil {
+		t.Error("expected a clock earlier than Earliest not to be set")
+	}
+}
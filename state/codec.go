@@ -0,0 +1,114 @@
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Codec serializes snapshots. Decode must accept every earlier version of its format, migrating it to the
+// current Snapshot, and reject later ones rather than lose what it does not understand.
+type Codec interface {
+	Encode(w io.Writer, s *Snapshot) error
+	Decode(r io.Reader) (*Snapshot, error)
+}
+
+// JSON is the default codec: indented JSON with a "version" field
+var JSON Codec = jsonCodec{}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Encode(w io.Writer, s *Snapshot) error {
+	out := *s
+	out.Version = Version
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(&out)
+}
+
+func (jsonCodec) Decode(r io.Reader) (*Snapshot, error) {
+	var doc map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("state: %v", err)
+	}
+	var version int
+	if v, ok := doc["version"]; ok {
+		if err := json.Unmarshal(v, &version); err != nil {
+			return nil, fmt.Errorf("state: version: %v", err)
+		}
+	}
+	switch {
+	case version > Version:
+		return nil, fmt.Errorf("state: version %d was written by a newer release of ddns, which reads up to version %d", version, Version)
+	case migrations[version] == nil && version != Version:
+		return nil, fmt.Errorf("state: unsupported version %d", version)
+	}
+	from := version
+	for ; version < Version; version++ {
+		if err := migrations[version](doc); err != nil {
+			return nil, fmt.Errorf("state: migrating from version %d: %v", version, err)
+		}
+	}
+	doc["version"] = json.RawMessage(fmt.Sprint(Version))
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("state: %v", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	s := New()
+	if err := dec.Decode(s); err != nil {
+		return nil, fmt.Errorf("state: %v", err)
+	}
+	if s.Providers == nil {
+		s.Providers = make(map[string]Provider)
+	}
+	if from != Version {
+		s.MigratedFrom = from
+	}
+	return s, nil
+}
+
+// migrations upgrade a decoded JSON snapshot from the version they are indexed by to the next one
+var migrations = map[int]func(doc map[string]json.RawMessage) error{
+	1: migrateV1,
+}
+
+// migrateV1 sets the type of the history entries, which version 1 left to be inferred:
+// an entry with an error is a failure, and one that kept the same addresses a recovery.
+func migrateV1(doc map[string]json.RawMessage) error {
+	raw, ok := doc["history"]
+	if !ok {
+		return nil
+	}
+	var history []map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &history); err != nil {
+		return err
+	}
+	for _, e := range history {
+		var v1 struct {
+			OldIPs []string `json:"old_ips"`
+			NewIPs []string `json:"new_ips"`
+			Error  string   `json:"error"`
+		}
+		data, _ := json.Marshal(e)
+		if err := json.Unmarshal(data, &v1); err != nil {
+			return err
+		}
+		typ := Changed
+		switch {
+		case v1.Error != "":
+			typ = Failed
+		case fmt.Sprint(v1.OldIPs) == fmt.Sprint(v1.NewIPs):
+			typ = Recovered
+		}
+		e["type"], _ = json.Marshal(typ)
+	}
+	data, err := json.Marshal(history)
+	if err != nil {
+		return err
+	}
+	doc["history"] = data
+	return nil
+}
@@ -0,0 +1,139 @@
+package state
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Shared lets several daemons, such as one per profile of a configuration, persist to a single Store.
+// Each daemon saves through its own view, which holds the providers named with the prefix of the view followed
+// by a colon, such as "home:dynu/web", and their history. The view with the empty prefix holds the providers of
+// no other view.
+type Shared struct {
+	mu    sync.Mutex
+	store Store
+	// last is the snapshot last loaded from or saved to the store
+	last *Snapshot
+	// views are the prefixes of the views, with the snapshot each last saved, if any
+	views map[string]*Snapshot
+}
+
+// NewShared returns a Shared store saving to store
+func NewShared(store Store) *Shared {
+	return &Shared{store: store, views: make(map[string]*Snapshot)}
+}
+
+// View returns the Store of the daemon whose providers are named with prefix
+func (s *Shared) View(prefix string) Store {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.views[prefix] = nil
+	return view{shared: s, prefix: prefix}
+}
+
+// owner returns the prefix of the view the provider belongs to
+func (s *Shared) owner(provider string) string {
+	for prefix := range s.views {
+		if prefix != "" && strings.HasPrefix(provider, prefix+":") {
+			return prefix
+		}
+	}
+	return ""
+}
+
+func (s *Shared) load() error {
+	if s.last != nil {
+		return nil
+	}
+	last, err := s.store.Load()
+	if err != nil {
+		return err
+	}
+	s.last = last
+	return nil
+}
+
+type view struct {
+	shared *Shared
+	prefix string
+}
+
+// Load returns the providers and history of the view, and the detection and schedule of the last daemon saved
+func (v view) Load() (*Snapshot, error) {
+	s := v.shared
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	out := New()
+	out.Detected, out.DetectedAt, out.NextRun = s.last.Detected, s.last.DetectedAt, s.last.NextRun
+	for name, p := range s.last.Providers {
+		if s.owner(name) == v.prefix {
+			out.Providers[name] = p
+		}
+	}
+	for _, e := range s.last.History {
+		if s.owner(e.Provider) == v.prefix {
+			out.History = append(out.History, e)
+		}
+	}
+	return out, nil
+}
+
+// Save merges the snapshot of the view with those last saved by the other views, or loaded for those that have
+// not saved yet. The most recent detection is kept, and the earliest next run, so that a single stalled daemon
+// shows in the schedule.
+func (v view) Save(snap *Snapshot) error {
+	s := v.shared
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+	s.views[v.prefix] = snap
+	merged := New()
+	for name, p := range s.last.Providers {
+		if s.views[s.owner(name)] == nil {
+			merged.Providers[name] = p
+		}
+	}
+	for _, e := range s.last.History {
+		if s.views[s.owner(e.Provider)] == nil {
+			merged.History = append(merged.History, e)
+		}
+	}
+	prefixes := make([]string, 0, len(s.views))
+	for prefix := range s.views {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	for _, prefix := range prefixes {
+		saved := s.views[prefix]
+		if saved == nil {
+			continue
+		}
+		for name, p := range saved.Providers {
+			merged.Providers[name] = p
+		}
+		merged.History = append(merged.History, saved.History...)
+		if saved.DetectedAt.After(merged.DetectedAt) {
+			merged.Detected, merged.DetectedAt = saved.Detected, saved.DetectedAt
+		}
+		if saved.DetectionFailures > merged.DetectionFailures {
+			merged.DetectionFailures, merged.DetectionError = saved.DetectionFailures, saved.DetectionError
+		}
+		if !saved.NextRun.IsZero() && (merged.NextRun.IsZero() || saved.NextRun.Before(merged.NextRun)) {
+			merged.NextRun = saved.NextRun
+		}
+	}
+	sort.SliceStable(merged.History, func(i, j int) bool {
+		return merged.History[i].Time.Before(merged.History[j].Time)
+	})
+	if err := s.store.Save(merged); err != nil {
+		return err
+	}
+	s.last = merged
+	return nil
+}
@@ -0,0 +1,67 @@
+package state_test
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/justenwalker/ddns/state"
+)
+
+func TestShared(t *testing.T) {
+	f := state.File{Path: filepath.Join(t.TempDir(), "state.json")}
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	ip := []net.IP{net.ParseIP("203.0.113.1")}
+	old := state.New()
+	old.Providers["dynu/web"] = state.Provider{IPs: ip}
+	old.Providers["office:dynu/vpn"] = state.Provider{IPs: ip}
+	old.Record(state.Entry{Time: now, Provider: "office:dynu/vpn", NewIPs: ip}, 0)
+	if err := f.Save(old); err != nil {
+		t.Fatal(err)
+	}
+
+	shared := state.NewShared(f)
+	top, office := shared.View(""), shared.View("office")
+	s, err := office.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Providers["office:dynu/vpn"]; len(s.Providers) != 1 || !ok || len(s.History) != 1 {
+		t.Fatalf("expected only the providers and history of the view, got %+v", s)
+	}
+
+	s = state.New()
+	s.Providers["dynu/web"] = state.Provider{Failures: 1}
+	s.NextRun = now.Add(time.Hour)
+	s.Record(state.Entry{Time: now.Add(time.Minute), Provider: "dynu/web", Error: "down"}, 0)
+	if err := top.Save(s); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := f.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Providers["dynu/web"].Failures != 1 || saved.Providers["office:dynu/vpn"].IPs == nil {
+		t.Errorf("expected the saved view merged with the loaded state of the other, got %+v", saved.Providers)
+	}
+	if len(saved.History) != 2 || saved.History[0].Provider != "office:dynu/vpn" {
+		t.Errorf("expected the history of both views in order, got %+v", saved.History)
+	}
+
+	s = state.New()
+	s.Providers["office:dynu/vpn"] = state.Provider{IPs: ip}
+	s.NextRun = now.Add(time.Minute)
+	if err := office.Save(s); err != nil {
+		t.Fatal(err)
+	}
+	if saved, err = f.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if !saved.NextRun.Equal(now.Add(time.Minute)) {
+		t.Errorf("expected the earliest next run, got %v", saved.NextRun)
+	}
+	if len(saved.History) != 1 || saved.History[0].Provider != "dynu/web" {
+		t.Errorf("expected the history of the office view replaced by its saved one, got %+v", saved.History)
+	}
+}
@@ -0,0 +1,154 @@
+// Package state persists what the daemon has published, so that a restart, or a move to another machine,
+// does not force every record to be updated again
+package state // import "github.com/justenwalker/ddns/state"
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"github.com/justenwalker/ddns/internal/atomicfile"
+)
+
+// Version of the snapshot format. Version 2 added the type of history entries.
+const Version = 2
+
+// DefaultHistorySize is the number of history entries kept by Record when no limit is given
+const DefaultHistorySize = 100
+
+// Snapshot is the portable state of the daemon
+type Snapshot struct {
+	Version   int                 `json:"version"`
+	Providers map[string]Provider `json:"providers"`
+	// Detected are the addresses found by the last successful detection, at DetectedAt
+	Detected   []net.IP  `json:"detected,omitempty"`
+	DetectedAt time.Time `json:"detected_at,omitempty"`
+	// DetectionFailures is the number of consecutive failed detections, and DetectionError the error of the last
+	DetectionFailures int    `json:"detection_failures,omitempty"`
+	DetectionError    string `json:"detection_error,omitempty"`
+	// NextRun is when the daemon will next detect the addresses
+	NextRun time.Time `json:"next_run,omitempty"`
+	// History lists the most recent changes and failures, oldest first
+	History []Entry `json:"history,omitempty"`
+	// MigratedFrom is the version the snapshot was decoded from, if it was older than Version
+	MigratedFrom int `json:"-"`
+}
+
+// Provider is the state of a single provider
+type Provider struct {
+	Hostnames []string `json:"hostnames,omitempty"`
+	// IPs are the last published addresses
+	IPs       []net.IP  `json:"ips,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+	// Failures is the number of consecutive failed updates
+	Failures int `json:"failures,omitempty"`
+	// RetryAt is the end of the backoff cooldown after a failure
+	RetryAt time.Time `json:"retry_at,omitempty"`
+	// Promoted is set while the provider's backup is promoted
+	Promoted bool `json:"promoted,omitempty"`
+	// LastError is the error of the last failed update, at LastErrorAt. It is kept after the provider recovers.
+	LastError   string    `json:"last_error,omitempty"`
+	LastErrorAt time.Time `json:"last_error_at,omitempty"`
+}
+
+// History entry types
+const (
+	Changed   = "changed"
+	Failed    = "failed"
+	Recovered = "recovered"
+)
+
+// Entry is a history record of an update
+type Entry struct {
+	Time     time.Time `json:"time"`
+	Type     string    `json:"type"`
+	Provider string    `json:"provider"`
+	OldIPs   []net.IP  `json:"old_ips,omitempty"`
+	NewIPs   []net.IP  `json:"new_ips,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// New returns an empty snapshot
+func New() *Snapshot {
+	return &Snapshot{Version: Version, Providers: make(map[string]Provider)}
+}
+
+// Record appends e to the history, keeping at most max entries (DefaultHistorySize if max is 0)
+func (s *Snapshot) Record(e Entry, max int) {
+	if max <= 0 {
+		max = DefaultHistorySize
+	}
+	s.History = append(s.History, e)
+	if n := len(s.History) - max; n > 0 {
+		s.History = append([]Entry(nil), s.History[n:]...)
+	}
+}
+
+// Read decodes and validates a snapshot in the JSON format, migrating it from an earlier version
+func Read(r io.Reader) (*Snapshot, error) {
+	return JSON.Decode(r)
+}
+
+// Write encodes the snapshot in the JSON format, at the current version
+func (s *Snapshot) Write(w io.Writer) error {
+	return JSON.Encode(w, s)
+}
+
+// Store loads and saves snapshots
+type Store interface {
+	Load() (*Snapshot, error)
+	Save(s *Snapshot) error
+}
+
+// File stores the snapshot in a file
+type File struct {
+	Path string
+	// Codec serializes the snapshot; the default is JSON
+	Codec Codec
+}
+
+func (f File) codec() Codec {
+	if f.Codec == nil {
+		return JSON
+	}
+	return f.Codec
+}
+
+// Load reads the file. A missing file is an empty snapshot, and one of an earlier version is migrated.
+func (f File) Load() (*Snapshot, error) {
+	data, err := os.ReadFile(f.Path)
+	if os.IsNotExist(err) {
+		return New(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s, err := f.codec().Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Path, err)
+	}
+	return s, nil
+}
+
+// Save atomically replaces the file. A file of an earlier version is first copied to Path.v<version>,
+// unless that exists, so that the release that wrote it can still be restored after an upgrade.
+func (f File) Save(s *Snapshot) error {
+	if data, err := os.ReadFile(f.Path); err == nil {
+		if old, err := f.codec().Decode(bytes.NewReader(data)); err == nil && old.MigratedFrom > 0 {
+			backup := fmt.Sprintf("%s.v%d", f.Path, old.MigratedFrom)
+			if _, err := os.Stat(backup); os.IsNotExist(err) {
+				if err := atomicfile.Write(backup, data, 0600); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := f.codec().Encode(&buf, s); err != nil {
+		return err
+	}
+	return atomicfile.Write(f.Path, buf.Bytes(), 0600)
+}
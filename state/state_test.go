@@ -0,0 +1,88 @@
+package state_test
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/justenwalker/ddns/state"
+)
+
+func TestFile(t *testing.T) {
+	f := state.File{Path: filepath.Join(t.TempDir(), "state.json")}
+	s, err := f.Load()
+	if err != nil || len(s.Providers) != 0 {
+		t.Fatalf("expected an empty snapshot for a missing file, got %+v, %v", s, err)
+	}
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	s.Providers["home/web"] = state.Provider{IPs: []net.IP{net.ParseIP("203.0.113.1")}, UpdatedAt: now, Failures: 2, RetryAt: now.Add(time.Minute)}
+	for i := 0; i < 3; i++ {
+		s.Record(state.Entry{Time: now, Provider: "home/web", NewIPs: []net.IP{net.IPv4(203, 0, 113, byte(i))}}, 2)
+	}
+	if err := f.Save(s); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := f.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := loaded.Providers["home/web"]
+	if !p.IPs[0].Equal(net.ParseIP("203.0.113.1")) || p.Failures != 2 || !p.RetryAt.Equal(now.Add(time.Minute)) {
+		t.Errorf("unexpected provider state %+v", p)
+	}
+	if len(loaded.History) != 2 || !loaded.History[0].NewIPs[0].Equal(net.IPv4(203, 0, 113, 1)) {
+		t.Errorf("expected the two most recent history entries, got %+v", loaded.History)
+	}
+}
+
+func TestReadVersion(t *testing.T) {
+	if _, err := state.Read(strings.NewReader(`{"version":3,"providers":{}}`)); err == nil {
+		t.Error("expected an error for an unsupported version")
+	}
+}
+
+func TestMigrateV1(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	v1 := `{
+  "version": 1,
+  "providers": {"home/web": {"ips": ["203.0.113.2"], "updated_at": "2026-01-02T03:04:05Z"}},
+  "history": [
+    {"time": "2026-01-02T03:00:00Z", "provider": "home/web", "new_ips": ["203.0.113.1"], "error": "911"},
+    {"time": "2026-01-02T03:01:00Z", "provider": "home/web", "old_ips": ["203.0.113.1"], "new_ips": ["203.0.113.1"]},
+    {"time": "2026-01-02T03:04:05Z", "provider": "home/web", "old_ips": ["203.0.113.1"], "new_ips": ["203.0.113.2"]}
+  ]
+}`
+	if err := os.WriteFile(path, []byte(v1), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	f := state.File{Path: path}
+	s, err := f.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.MigratedFrom != 1 || !s.Providers["home/web"].IPs[0].Equal(net.ParseIP("203.0.113.2")) {
+		t.Errorf("unexpected migrated snapshot %+v", s)
+	}
+	var types []string
+	for _, e := range s.History {
+		types = append(types, e.Type)
+	}
+	if want := []string{state.Failed, state.Recovered, state.Changed}; strings.Join(types, ",") != strings.Join(want, ",") {
+		t.Errorf("got history types %v, want %v", types, want)
+	}
+	if _, err := os.Stat(path + ".v1"); !os.IsNotExist(err) {
+		t.Errorf("expected loading to leave the file alone, got %v", err)
+	}
+	if err := f.Save(s); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(path + ".v1"); err != nil || string(data) != v1 {
+		t.Errorf("expected the original file to be kept, got %q, %v", data, err)
+	}
+	if s, err = f.Load(); err != nil || s.Version != state.Version || s.MigratedFrom != 0 {
+		t.Errorf("expected the saved file at version %d, got %+v, %v", state.Version, s, err)
+	}
+}
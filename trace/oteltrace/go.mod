@@ -0,0 +1,12 @@
+module github.com/justenwalker/ddns/trace/oteltrace
+
+go 1.23.0
+
+require (
+	github.com/justenwalker/ddns v0.0.0-00010101000000-000000000000
+	go.opentelemetry.io/otel/trace v1.38.0
+)
+
+require go.opentelemetry.io/otel v1.38.0 // indirect
+
+replace github.com/justenwalker/ddns => ../..
@@ -0,0 +1,23 @@
+// Package oteltrace makes the spans of the ddns trace package children of the spans of the OpenTelemetry API,
+// so that the update cycles of a daemon embedded in an instrumented program appear in the traces of the program.
+// It is a module of its own so that the ddns module does not depend on OpenTelemetry.
+package oteltrace // import "github.com/justenwalker/ddns/trace/oteltrace"
+
+import (
+	"context"
+
+	"github.com/justenwalker/ddns/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// Parent returns the option making the root spans of a tracer children of the OpenTelemetry span of the context
+// they are started with, if it has a valid one
+func Parent() trace.Option {
+	return trace.Parent(func(ctx context.Context) (trace.TraceID, trace.SpanID, bool) {
+		sc := oteltrace.SpanContextFromContext(ctx)
+		if !sc.IsValid() {
+			return trace.TraceID{}, trace.SpanID{}, false
+		}
+		return trace.TraceID(sc.TraceID()), trace.SpanID(sc.SpanID()), true
+	})
+}
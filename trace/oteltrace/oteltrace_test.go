@@ -0,0 +1,40 @@
+package oteltrace_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/justenwalker/ddns/trace"
+	"github.com/justenwalker/ddns/trace/oteltrace"
+	otel "go.opentelemetry.io/otel/trace"
+)
+
+func TestParent(t *testing.T) {
+	var got []trace.SpanData
+	tracer := trace.New(trace.ExporterFunc(func(ctx context.Context, spans []trace.SpanData) error {
+		got = append(got, spans...)
+		return nil
+	}), oteltrace.Parent())
+	sc := otel.NewSpanContext(otel.SpanContextConfig{
+		TraceID:    otel.TraceID{1, 2, 3},
+		SpanID:     otel.SpanID{4, 5, 6},
+		TraceFlags: otel.FlagsSampled,
+	})
+
+	_, span := tracer.Start(otel.ContextWithSpanContext(context.Background(), sc), "ddns.step")
+	span.End()
+	_, root := tracer.Start(context.Background(), "ddns.step")
+	root.End()
+	if err := tracer.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 spans, got %+v", got)
+	}
+	if got[0].TraceID != trace.TraceID(sc.TraceID()) || got[0].Parent != trace.SpanID(sc.SpanID()) {
+		t.Errorf("expected the child of the OpenTelemetry span, got %+v", got[0])
+	}
+	if got[1].TraceID == trace.TraceID(sc.TraceID()) || !got[1].Parent.IsZero() {
+		t.Errorf("expected a new trace without an OpenTelemetry span, got %+v", got[1])
+	}
+}
@@ -0,0 +1,338 @@
+// Package trace records the update cycles of the daemon as OpenTelemetry spans: a step, the detection of the
+// addresses, the update of each provider and the HTTP requests they make, so that collectors such as the
+// OpenTelemetry Collector, Jaeger or Tempo show where a slow or failing cycle spends its time.
+//
+// A Tracer starts the root span of a cycle; code called with its context starts child spans with Start, which
+// does nothing when the context has no span. Transport propagates the context to HTTP servers in the W3C
+// traceparent header. Ended spans are exported in batches, such as by OTLP.
+package trace // import "github.com/justenwalker/ddns/trace"
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"sync"
+	"time"
+)
+
+// Logger for printing debug logs from this package
+type Logger interface {
+	Log(format string, v ...interface{})
+}
+
+// TraceID identifies the spans of one trace
+type TraceID [16]byte
+
+// String returns the ID in hex
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanID identifies a span within its trace
+type SpanID [8]byte
+
+// String returns the ID in hex
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// IsZero returns true for the parent of a root span
+func (id SpanID) IsZero() bool {
+	return id == SpanID{}
+}
+
+// Kind is the OpenTelemetry kind of a span
+type Kind int
+
+// Kinds of spans, numbered as in OTLP
+const (
+	Internal = Kind(1)
+	Client   = Kind(3)
+)
+
+// Attr is an attribute of a span. Its value is a string, int64, bool, float64 or []string.
+type Attr struct {
+	Key   string
+	Value interface{}
+}
+
+// String returns a string attribute
+func String(key, value string) Attr {
+	return Attr{Key: key, Value: value}
+}
+
+// Int returns an integer attribute
+func Int(key string, value int) Attr {
+	return Attr{Key: key, Value: int64(value)}
+}
+
+// Bool returns a boolean attribute
+func Bool(key string, value bool) Attr {
+	return Attr{Key: key, Value: value}
+}
+
+// Strings returns a string array attribute
+func Strings(key string, values []string) Attr {
+	return Attr{Key: key, Value: append([]string{}, values...)}
+}
+
+// IPs returns a string array attribute of addresses
+func IPs(key string, ips []net.IP) Attr {
+	values := make([]string, len(ips))
+	for i, ip := range ips {
+		values[i] = ip.String()
+	}
+	return Attr{Key: key, Value: values}
+}
+
+// SpanData is an ended span, as it is exported
+type SpanData struct {
+	Name    string
+	Kind    Kind
+	TraceID TraceID
+	SpanID  SpanID
+	Parent  SpanID
+	Start   time.Time
+	End     time.Time
+	Attrs   []Attr
+	// Err is why the operation of the span failed, if it did
+	Err error
+}
+
+// Exporter sends ended spans to a collector
+type Exporter interface {
+	Export(ctx context.Context, spans []SpanData) error
+}
+
+// ExporterFunc adapts a function to the Exporter interface
+type ExporterFunc func(ctx context.Context, spans []SpanData) error
+
+// Export calls f(ctx, spans)
+func (f ExporterFunc) Export(ctx context.Context, spans []SpanData) error {
+	return f(ctx, spans)
+}
+
+// Option sets tracer options
+type Option func(*Tracer)
+
+// Log enables tracer logging using the given Logger, such as when spans cannot be exported
+func Log(l Logger) Option {
+	return func(t *Tracer) {
+		t.logger = l
+	}
+}
+
+// Flush sets how often ended spans are exported; the default is 5 seconds
+func Flush(interval time.Duration) Option {
+	return func(t *Tracer) {
+		t.flush = interval
+	}
+}
+
+// Parent sets how the root spans find their remote parent in the context they are started with, such as the span
+// of another tracing library; the oteltrace module finds those of the OpenTelemetry API. f returns false when ctx
+// has no parent, and the span starts a new trace.
+func Parent(f func(ctx context.Context) (TraceID, SpanID, bool)) Option {
+	return func(t *Tracer) {
+		t.parent = f
+	}
+}
+
+// maxQueue is how many ended spans are kept while they cannot be exported; newer ones are dropped
+const maxQueue = 2048
+
+// Tracer starts the root spans of the cycles and exports the ended spans in the background until it is closed
+type Tracer struct {
+	exporter Exporter
+	logger   Logger
+	flush    time.Duration
+	parent   func(ctx context.Context) (TraceID, SpanID, bool)
+
+	mu      sync.Mutex
+	queue   []SpanData
+	dropped int
+	stop    chan struct{}
+	done    chan struct{}
+	closing sync.Once
+}
+
+// New constructs a tracer exporting its spans with exp
+func New(exp Exporter, options ...Option) *Tracer {
+	t := &Tracer{
+		exporter: exp,
+		flush:    5 * time.Second,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	for _, opt := range options {
+		opt(t)
+	}
+	go t.run()
+	return t
+}
+
+func (t *Tracer) logf(format string, v ...interface{}) {
+	if t.logger != nil {
+		t.logger.Log(format, v...)
+	}
+}
+
+// Start starts a span, the child of the span of ctx if it has one, or of its remote parent, and returns a context
+// holding it.
+// A nil Tracer starts nothing and returns ctx and a nil Span, whose methods do nothing.
+func (t *Tracer) Start(ctx context.Context, name string, attrs ...Attr) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	s := &Span{tracer: t, data: SpanData{Name: name, Kind: Internal, Start: time.Now(), Attrs: attrs}}
+	if parent := FromContext(ctx); parent != nil {
+		s.data.TraceID, s.data.Parent = parent.data.TraceID, parent.data.SpanID
+	} else if traceID, spanID, ok := t.remoteParent(ctx); ok {
+		s.data.TraceID, s.data.Parent = traceID, spanID
+	} else {
+		rand.Read(s.data.TraceID[:])
+	}
+	rand.Read(s.data.SpanID[:])
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+func (t *Tracer) remoteParent(ctx context.Context) (TraceID, SpanID, bool) {
+	if t.parent == nil {
+		return TraceID{}, SpanID{}, false
+	}
+	return t.parent(ctx)
+}
+
+// Close exports the spans that have ended and stops exporting, until ctx is done
+func (t *Tracer) Close(ctx context.Context) error {
+	t.closing.Do(func() { close(t.stop) })
+	select {
+	case <-t.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return t.export(ctx)
+}
+
+func (t *Tracer) run() {
+	defer close(t.done)
+	ticker := time.NewTicker(t.flush)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if err := t.export(ctx); err != nil {
+				t.logf("trace: exporting spans: %v", err)
+			}
+			cancel()
+		}
+	}
+}
+
+// export sends the queued spans; they are dropped if the exporter fails
+func (t *Tracer) export(ctx context.Context) error {
+	t.mu.Lock()
+	spans, dropped := t.queue, t.dropped
+	t.queue, t.dropped = nil, 0
+	t.mu.Unlock()
+	if dropped > 0 {
+		t.logf("trace: dropped %d spans that could not be exported in time", dropped)
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+	return t.exporter.Export(ctx, spans)
+}
+
+func (t *Tracer) end(data SpanData) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.queue) >= maxQueue {
+		t.dropped++
+		return
+	}
+	t.queue = append(t.queue, data)
+}
+
+// Span is an operation of a trace, exported once it ends
+type Span struct {
+	tracer *Tracer
+	mu     sync.Mutex
+	data   SpanData
+	ended  bool
+}
+
+type spanKey struct{}
+
+// FromContext returns the span of ctx, or nil if it has none
+func FromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// Start starts a child of the span of ctx and returns a context holding it.
+// If ctx has no span, it starts nothing and returns ctx and a nil Span, whose methods do nothing.
+func Start(ctx context.Context, name string, attrs ...Attr) (context.Context, *Span) {
+	parent := FromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	return parent.tracer.Start(ctx, name, attrs...)
+}
+
+// TraceID returns the ID of the trace of the span
+func (s *Span) TraceID() TraceID {
+	if s == nil {
+		return TraceID{}
+	}
+	return s.data.TraceID
+}
+
+// SpanID returns the ID of the span
+func (s *Span) SpanID() SpanID {
+	if s == nil {
+		return SpanID{}
+	}
+	return s.data.SpanID
+}
+
+// SetAttributes adds attributes to the span
+func (s *Span) SetAttributes(attrs ...Attr) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Attrs = append(s.data.Attrs, attrs...)
+}
+
+// Fail marks the operation of the span as failed with err, unless err is nil
+func (s *Span) Fail(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Err = err
+}
+
+// End ends the span and queues it for export. Only the first call has an effect.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+	s.tracer.end(data)
+}
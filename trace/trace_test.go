@@ -0,0 +1,129 @@
+package trace
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTransport(t *testing.T) {
+	var got []SpanData
+	tracer := New(ExporterFunc(func(ctx context.Context, spans []SpanData) error {
+		got = append(got, spans...)
+		return nil
+	}))
+	var traceparent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+	client := &http.Client{Transport: Transport(nil)}
+
+	// requests without a span are not recorded
+	resp, err := client.Get(srv.URL + "/nic/update?password=secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if traceparent != "" {
+		t.Errorf("expected no traceparent without a span, got %q", traceparent)
+	}
+
+	ctx, root := tracer.Start(context.Background(), "ddns.step")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/nic/update?password=secret", nil)
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	root.Fail(errors.New("update failed"))
+	root.End()
+	root.End()
+	if err := tracer.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected the request and the root span, got %+v", got)
+	}
+	span := got[0]
+	if span.Name != "HTTP GET" || span.Kind != Client || span.TraceID != root.TraceID() || span.Parent != root.SpanID() {
+		t.Errorf("expected a client span child of the root, got %+v", span)
+	}
+	if want := "00-" + span.TraceID.String() + "-" + span.SpanID.String() + "-01"; traceparent != want {
+		t.Errorf("traceparent = %q, want %q", traceparent, want)
+	}
+	if span.Err == nil {
+		t.Error("expected a 502 response to fail the span")
+	}
+	for _, a := range span.Attrs {
+		if s, ok := a.Value.(string); ok && strings.Contains(s, "secret") {
+			t.Errorf("expected the query to be left out, got %s=%q", a.Key, s)
+		}
+	}
+	if got[1].Name != "ddns.step" || !got[1].Parent.IsZero() || got[1].Err == nil {
+		t.Errorf("expected the failed root span, got %+v", got[1])
+	}
+}
+
+func TestOTLP(t *testing.T) {
+	var path, auth string
+	var body struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceID      string `json:"traceId"`
+					ParentSpanID string `json:"parentSpanId"`
+					Name         string `json:"name"`
+					Attributes   []struct {
+						Key   string                 `json:"key"`
+						Value map[string]interface{} `json:"value"`
+					} `json:"attributes"`
+					Status struct {
+						Code int `json:"code"`
+					} `json:"status"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, auth = r.URL.Path, r.Header.Get("Api-Key")
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer srv.Close()
+	exp, err := OTLP(srv.URL, Headers(map[string]string{"api-key": "key"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracer := New(exp)
+	ctx, root := tracer.Start(context.Background(), "ddns.step")
+	_, child := Start(ctx, "ddns.update", String("ddns.provider", "home"), Int("attempt", 2))
+	child.Fail(errors.New("badauth"))
+	child.End()
+	root.End()
+	if err := tracer.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/v1/traces" || auth != "key" {
+		t.Errorf("expected a request to /v1/traces with the header, got %q with %q", path, auth)
+	}
+	spans := body.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %+v", spans)
+	}
+	if s := spans[0]; s.Name != "ddns.update" || s.TraceID != root.TraceID().String() || s.ParentSpanID != root.SpanID().String() || s.Status.Code != 2 {
+		t.Errorf("unexpected child span %+v", s)
+	}
+	if a := spans[0].Attributes; len(a) != 2 || a[0].Value["stringValue"] != "home" || a[1].Value["intValue"] != "2" {
+		t.Errorf("unexpected attributes %+v", a)
+	}
+	if _, err := OTLP("localhost:4318"); err == nil {
+		t.Error("expected an error for an endpoint without a scheme")
+	}
+}
@@ -0,0 +1,50 @@
+package trace
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Transport returns a RoundTripper that records every request made through rt with a span, when the context of
+// the request has one, and propagates it to the server in the traceparent header. The spans hold the host and
+// path of the URL, never its query, which some providers authenticate with.
+func Transport(rt http.RoundTripper) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return &transport{rt: rt}
+}
+
+type transport struct {
+	rt http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := Start(req.Context(), "HTTP "+req.Method,
+		String("http.request.method", req.Method),
+		String("server.address", req.URL.Hostname()),
+		String("url.path", req.URL.Path),
+	)
+	if span == nil {
+		return t.rt.RoundTrip(req)
+	}
+	defer span.End()
+	span.data.Kind = Client
+	req = req.Clone(ctx)
+	req.Header.Set("traceparent", Traceparent(span))
+	resp, err := t.rt.RoundTrip(req)
+	if err != nil {
+		span.Fail(err)
+		return nil, err
+	}
+	span.SetAttributes(Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= 400 {
+		span.Fail(fmt.Errorf("HTTP %s", resp.Status))
+	}
+	return resp, nil
+}
+
+// Traceparent returns the W3C traceparent header identifying s as the parent of the spans of a server
+func Traceparent(s *Span) string {
+	return "00-" + s.TraceID().String() + "-" + s.SpanID().String() + "-01"
+}
@@ -0,0 +1,93 @@
+package verify
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// HostnameUpdater publishes addresses for specific hostnames
+type HostnameUpdater interface {
+	UpdateHostnames(ctx context.Context, hostnames []string, ips []net.IP) error
+}
+
+// HostnameUpdaterFunc adapts a function to the HostnameUpdater interface
+type HostnameUpdaterFunc func(ctx context.Context, hostnames []string, ips []net.IP) error
+
+// UpdateHostnames calls f(ctx, hostnames, ips)
+func (f HostnameUpdaterFunc) UpdateHostnames(ctx context.Context, hostnames []string, ips []net.IP) error {
+	return f(ctx, hostnames, ips)
+}
+
+// CanaryError is returned when the canary hostname failed, so the remaining hostnames were left untouched
+type CanaryError struct {
+	Hostname string
+	Err      error
+}
+
+func (e CanaryError) Error() string {
+	return fmt.Sprintf("verify: canary %s failed, remaining hostnames not updated: %v", e.Hostname, e.Err)
+}
+
+func (e CanaryError) Unwrap() error {
+	return e.Err
+}
+
+// Canary limits the blast radius of a bad update by updating a single canary hostname first.
+// The remaining hostnames are only updated if the canary update succeeds and, when set, the canary
+// resolves to the new addresses through Resolver and the new addresses pass the Checker.
+type Canary struct {
+	Hostname string
+	Updater  HostnameUpdater
+	// Resolver looks the canary up once updated, every Poll for up to Wait until it resolves to the new
+	// addresses, as WaitConverged does. Zero Wait looks it up once. Poll defaults to 5 seconds.
+	Resolver Resolver
+	Wait     time.Duration
+	Poll     time.Duration
+	Checker  *Checker
+}
+
+// UpdateHostnames updates the canary, verifies it, then updates the rest of hostnames.
+// The canary is always updated, whether or not it is included in hostnames.
+func (c Canary) UpdateHostnames(ctx context.Context, hostnames []string, ips []net.IP) error {
+	if err := c.Updater.UpdateHostnames(ctx, []string{c.Hostname}, ips); err != nil {
+		return CanaryError{Hostname: c.Hostname, Err: err}
+	}
+	if c.Resolver != nil {
+		if err := c.resolves(ctx, ips); err != nil {
+			return CanaryError{Hostname: c.Hostname, Err: err}
+		}
+	}
+	if c.Checker != nil {
+		if err := c.Checker.Check(ctx, ips).Err(); err != nil {
+			return CanaryError{Hostname: c.Hostname, Err: err}
+		}
+	}
+	var rest []string
+	for _, h := range hostnames {
+		if !strings.EqualFold(strings.TrimSuffix(h, "."), strings.TrimSuffix(c.Hostname, ".")) {
+			rest = append(rest, h)
+		}
+	}
+	if len(rest) == 0 {
+		return nil
+	}
+	return c.Updater.UpdateHostnames(ctx, rest, ips)
+}
+
+// resolves returns nil once the canary resolves to ips
+func (c Canary) resolves(ctx context.Context, ips []net.IP) error {
+	hostnames := []string{c.Hostname}
+	if c.Wait <= 0 {
+		return Converged(ctx, c.Resolver, hostnames, ips)
+	}
+	poll := c.Poll
+	if poll <= 0 {
+		poll = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.Wait)
+	defer cancel()
+	return WaitConverged(ctx, c.Resolver, hostnames, ips, poll)
+}
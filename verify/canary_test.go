@@ -0,0 +1,83 @@
+package verify_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/justenwalker/ddns/verify"
+)
+
+func TestCanary(t *testing.T) {
+	ctx := context.Background()
+	ips := []net.IP{net.ParseIP("203.0.113.1")}
+	var batches [][]string
+	updater := verify.HostnameUpdaterFunc(func(ctx context.Context, hostnames []string, ips []net.IP) error {
+		batches = append(batches, hostnames)
+		return nil
+	})
+	reachable := true
+	checker := &verify.Checker{
+		Ports: []int{443},
+		Prober: verify.ProberFunc(func(ctx context.Context, ip net.IP, port int) error {
+			if !reachable {
+				return errors.New("closed")
+			}
+			return nil
+		}),
+	}
+	c := verify.Canary{Hostname: "canary.example.com", Updater: updater, Checker: checker}
+
+	hosts := []string{"a.example.com", "canary.example.com", "b.example.com"}
+	if err := c.UpdateHostnames(ctx, hosts, ips); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"canary.example.com"}, {"a.example.com", "b.example.com"}}
+	if !reflect.DeepEqual(batches, want) {
+		t.Errorf("got batches %v, want %v", batches, want)
+	}
+
+	batches = nil
+	reachable = false
+	err := c.UpdateHostnames(ctx, hosts, ips)
+	var ce verify.CanaryError
+	if !errors.As(err, &ce) {
+		t.Fatalf("expected a CanaryError, got %v", err)
+	}
+	if len(batches) != 1 {
+		t.Errorf("expected only the canary to be updated, got %v", batches)
+	}
+}
+
+func TestCanaryResolves(t *testing.T) {
+	ctx := context.Background()
+	ips := []net.IP{net.ParseIP("203.0.113.1")}
+	var batches [][]string
+	updater := verify.HostnameUpdaterFunc(func(ctx context.Context, hostnames []string, ips []net.IP) error {
+		batches = append(batches, hostnames)
+		return nil
+	})
+	r := resolver{"canary.example.com": {net.ParseIP("203.0.113.9")}}
+	c := verify.Canary{Hostname: "canary.example.com", Updater: updater, Resolver: r}
+
+	hosts := []string{"a.example.com", "Canary.Example.com."}
+	var mismatch *verify.MismatchError
+	if err := c.UpdateHostnames(ctx, hosts, ips); !errors.As(err, &mismatch) {
+		t.Fatalf("expected the canary to fail while it resolves to another address, got %v", err)
+	}
+	if len(batches) != 1 {
+		t.Errorf("expected only the canary to be updated, got %v", batches)
+	}
+
+	batches = nil
+	r["canary.example.com"] = ips
+	if err := c.UpdateHostnames(ctx, hosts, ips); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"canary.example.com"}, {"a.example.com"}}
+	if !reflect.DeepEqual(batches, want) {
+		t.Errorf("got batches %v, want %v", batches, want)
+	}
+}
@@ -0,0 +1,49 @@
+package verify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// CNAMEResolver looks up the canonical name of a hostname, following its chain of CNAME records;
+// *net.Resolver implements it
+type CNAMEResolver interface {
+	LookupCNAME(ctx context.Context, host string) (string, error)
+}
+
+// ChainError is returned when an alias does not lead to its target through its CNAME records
+type ChainError struct {
+	Alias  string
+	Target string
+	// Got is the canonical name the alias resolved to, if the lookup succeeded
+	Got string
+	// Err is the lookup error, if the lookup failed
+	Err error
+}
+
+func (e *ChainError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("CNAME chain of %s to %s is broken: %v", e.Alias, e.Target, e.Err)
+	}
+	return fmt.Sprintf("CNAME chain of %s is broken: it leads to %s, want %s", e.Alias, e.Got, e.Target)
+}
+
+// Unwrap returns the lookup error
+func (e *ChainError) Unwrap() error {
+	return e.Err
+}
+
+// Chain returns nil if the CNAME records of alias lead to target, such as www.example.com to a record
+// updated in a zone delegated to a dynamic DNS provider, like www._dyn.example.com.
+// Otherwise it returns a *ChainError.
+func Chain(ctx context.Context, r CNAMEResolver, alias, target string) error {
+	got, err := r.LookupCNAME(ctx, alias)
+	if err != nil {
+		return &ChainError{Alias: alias, Target: target, Err: err}
+	}
+	if !strings.EqualFold(strings.TrimSuffix(got, "."), strings.TrimSuffix(target, ".")) {
+		return &ChainError{Alias: alias, Target: target, Got: strings.TrimSuffix(got, ".")}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package verify_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/justenwalker/ddns/verify"
+)
+
+// cnames maps hostnames to their CNAME target; names without one are their own canonical name
+type cnames map[string]string
+
+func (c cnames) LookupCNAME(ctx context.Context, host string) (string, error) {
+	for {
+		next, ok := c[host]
+		if !ok {
+			return host + ".", nil
+		}
+		if next == "" {
+			return "", &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+		host = next
+	}
+}
+
+func TestChain(t *testing.T) {
+	ctx := context.Background()
+	r := cnames{
+		"www.example.com":  "edge.example.com",
+		"edge.example.com": "www._dyn.example.com",
+		"vpn.example.com":  "",
+	}
+	if err := verify.Chain(ctx, r, "www.example.com", "WWW._dyn.example.com"); err != nil {
+		t.Errorf("expected the chain to lead to the target: %v", err)
+	}
+	var chain *verify.ChainError
+	err := verify.Chain(ctx, r, "mail.example.com", "mail._dyn.example.com")
+	if !errors.As(err, &chain) || chain.Got != "mail.example.com" {
+		t.Errorf("expected a broken chain for a name without a CNAME, got %v", err)
+	}
+	err = verify.Chain(ctx, r, "vpn.example.com", "vpn._dyn.example.com")
+	var dnsErr *net.DNSError
+	if !errors.As(err, &chain) || !errors.As(err, &dnsErr) {
+		t.Errorf("expected the lookup error, got %v", err)
+	}
+}
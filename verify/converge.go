@@ -0,0 +1,107 @@
+package verify
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+)
+
+// Resolver looks up the addresses of a hostname; *net.Resolver implements it
+type Resolver interface {
+	LookupIP(ctx context.Context, network, host string) ([]net.IP, error)
+}
+
+// MismatchError is returned when a hostname does not resolve to the expected addresses
+type MismatchError struct {
+	Hostname string
+	Want     []net.IP
+	Got      []net.IP
+	// Err is the lookup error, if the lookup failed
+	Err error
+}
+
+func (e *MismatchError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Hostname, e.Err)
+	}
+	return fmt.Sprintf("%s resolves to %v, want %v", e.Hostname, e.Got, e.Want)
+}
+
+// Unwrap returns the lookup error
+func (e *MismatchError) Unwrap() error {
+	return e.Err
+}
+
+// Converged returns nil if every hostname resolves to exactly ips.
+// Only the address families present in ips are compared, so an IPv4-only update ignores AAAA records.
+// Otherwise it returns a *MismatchError for the first hostname that does not match.
+func Converged(ctx context.Context, r Resolver, hostnames []string, ips []net.IP) error {
+	want := map[string][]net.IP{}
+	for _, ip := range ips {
+		network := "ip6"
+		if ip.To4() != nil {
+			network = "ip4"
+		}
+		want[network] = append(want[network], ip)
+	}
+	for _, h := range hostnames {
+		for _, network := range []string{"ip4", "ip6"} {
+			if want[network] == nil {
+				continue
+			}
+			got, err := r.LookupIP(ctx, network, h)
+			if err != nil {
+				return &MismatchError{Hostname: h, Want: want[network], Err: err}
+			}
+			if !sameSet(got, want[network]) {
+				return &MismatchError{Hostname: h, Want: want[network], Got: got}
+			}
+		}
+	}
+	return nil
+}
+
+// WaitConverged checks Converged every interval until it returns nil or ctx is done,
+// in which case the last mismatch is returned
+func WaitConverged(ctx context.Context, r Resolver, hostnames []string, ips []net.IP, interval time.Duration) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		err := Converged(ctx, r, hostnames, ips)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("records did not converge: %w", err)
+		case <-t.C:
+		}
+	}
+}
+
+func sameSet(a, b []net.IP) bool {
+	key := func(ips []net.IP) []string {
+		var out []string
+		seen := map[string]bool{}
+		for _, ip := range ips {
+			if s := ip.String(); !seen[s] {
+				seen[s] = true
+				out = append(out, s)
+			}
+		}
+		sort.Strings(out)
+		return out
+	}
+	ka, kb := key(a), key(b)
+	if len(ka) != len(kb) {
+		return false
+	}
+	for i := range ka {
+		if ka[i] != kb[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,58 @@
+package verify_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/justenwalker/ddns/verify"
+)
+
+type resolver map[string][]net.IP
+
+func (r resolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	var out []net.IP
+	for _, ip := range r[host] {
+		if (ip.To4() != nil) == (network == "ip4") {
+			out = append(out, ip)
+		}
+	}
+	if len(out) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return out, nil
+}
+
+func TestConverged(t *testing.T) {
+	ctx := context.Background()
+	r := resolver{
+		"home.example.com": {net.ParseIP("203.0.113.7"), net.ParseIP("2001:db8::7")},
+		"vpn.example.com":  {net.ParseIP("198.51.100.1")},
+	}
+	if err := verify.Converged(ctx, r, []string{"home.example.com"}, []net.IP{net.ParseIP("203.0.113.7")}); err != nil {
+		t.Errorf("expected the IPv4 record to match regardless of AAAA records: %v", err)
+	}
+	err := verify.Converged(ctx, r, []string{"home.example.com", "vpn.example.com"}, []net.IP{net.ParseIP("203.0.113.7")})
+	var mismatch *verify.MismatchError
+	if !errors.As(err, &mismatch) || mismatch.Hostname != "vpn.example.com" {
+		t.Errorf("expected a mismatch for vpn.example.com, got %v", err)
+	}
+	err = verify.Converged(ctx, r, []string{"vpn.example.com"}, []net.IP{net.ParseIP("2001:db8::1")})
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) {
+		t.Errorf("expected the lookup error, got %v", err)
+	}
+}
+
+func TestWaitConverged(t *testing.T) {
+	r := resolver{"home.example.com": {net.ParseIP("198.51.100.1")}}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := verify.WaitConverged(ctx, r, []string{"home.example.com"}, []net.IP{net.ParseIP("203.0.113.7")}, 10*time.Millisecond)
+	var mismatch *verify.MismatchError
+	if !errors.As(err, &mismatch) {
+		t.Errorf("expected the last mismatch after the deadline, got %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package verify
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/justenwalker/ddns/event"
+)
+
+// Updater publishes addresses for a provider's records
+type Updater interface {
+	UpdateIP(ctx context.Context, ips []net.IP) error
+}
+
+// UpdaterFunc adapts a function to the Updater interface
+type UpdaterFunc func(ctx context.Context, ips []net.IP) error
+
+// UpdateIP calls f(ctx, ips)
+func (f UpdaterFunc) UpdateIP(ctx context.Context, ips []net.IP) error {
+	return f(ctx, ips)
+}
+
+// Policy governs how a Guard reacts to failed verification
+type Policy struct {
+	// Rollback enables restoring the previous addresses. When false, failures are only alerted
+	// through the Verified event.
+	Rollback bool
+	// Threshold is the number of consecutive failed verifications before rolling back. Defaults to 1.
+	Threshold int
+	// Blackout is how long rolled back addresses are reported as blocked, so that the update pipeline
+	// does not immediately publish them again.
+	Blackout time.Duration
+}
+
+// Guard rolls a provider's records back to their previous addresses when verification of the new ones fails.
+// It handles Verified events for its provider and publishes RolledBack, or Failed if the rollback itself fails.
+type Guard struct {
+	Provider  string
+	Updater   Updater
+	Policy    Policy
+	Publisher Publisher
+
+	mu       sync.Mutex
+	failures int
+	blocked  map[string]time.Time
+	now      func() time.Time
+}
+
+func (g *Guard) time() time.Time {
+	if g.now != nil {
+		return g.now()
+	}
+	return time.Now()
+}
+
+// Handle implements event.Sink
+func (g *Guard) Handle(ctx context.Context, ev event.Event) error {
+	if ev.Type != event.Verified || ev.Provider != g.Provider {
+		return nil
+	}
+	g.mu.Lock()
+	if ev.Err == nil {
+		g.failures = 0
+		g.mu.Unlock()
+		return nil
+	}
+	g.failures++
+	threshold := g.Policy.Threshold
+	if threshold < 1 {
+		threshold = 1
+	}
+	if !g.Policy.Rollback || g.failures < threshold || len(ev.OldIPs) == 0 {
+		g.mu.Unlock()
+		return nil
+	}
+	g.failures = 0
+	if g.Policy.Blackout > 0 {
+		if g.blocked == nil {
+			g.blocked = make(map[string]time.Time)
+		}
+		until := g.time().Add(g.Policy.Blackout)
+		for _, ip := range ev.NewIPs {
+			g.blocked[ip.String()] = until
+		}
+	}
+	g.mu.Unlock()
+
+	out := event.Event{
+		Type:      event.RolledBack,
+		Time:      g.time(),
+		Provider:  ev.Provider,
+		Hostnames: ev.Hostnames,
+		OldIPs:    ev.NewIPs,
+		NewIPs:    ev.OldIPs,
+		Err:       ev.Err,
+	}
+	if err := g.Updater.UpdateIP(ctx, ev.OldIPs); err != nil {
+		out.Type = event.Failed
+		out.Err = err
+	}
+	g.Publisher.Publish(out)
+	return nil
+}
+
+// Blocked returns true if any of ips was rolled back within the blackout period
+func (g *Guard) Blocked(ips []net.IP) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.time()
+	for _, ip := range ips {
+		if until, ok := g.blocked[ip.String()]; ok && now.Before(until) {
+			return true
+		}
+	}
+	return false
+}
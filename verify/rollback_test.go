@@ -0,0 +1,55 @@
+package verify
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/justenwalker/ddns/event"
+)
+
+type publisher []event.Event
+
+func (p *publisher) Publish(ev event.Event) {
+	*p = append(*p, ev)
+}
+
+func TestGuardRollback(t *testing.T) {
+	ctx := context.Background()
+	oldIP, newIP := net.ParseIP("203.0.113.1"), net.ParseIP("192.0.2.1")
+	var restored []net.IP
+	var pub publisher
+	now := time.Unix(1000, 0)
+	g := &Guard{
+		Provider:  "dynu",
+		Policy:    Policy{Rollback: true, Threshold: 2, Blackout: time.Hour},
+		Publisher: &pub,
+		Updater: UpdaterFunc(func(ctx context.Context, ips []net.IP) error {
+			restored = ips
+			return nil
+		}),
+		now: func() time.Time { return now },
+	}
+	failed := event.Event{Type: event.Verified, Provider: "dynu", OldIPs: []net.IP{oldIP}, NewIPs: []net.IP{newIP}, Err: errors.New("unreachable")}
+
+	g.Handle(ctx, failed)
+	if restored != nil || len(pub) != 0 {
+		t.Fatal("rolled back before reaching the threshold")
+	}
+	g.Handle(ctx, failed)
+	if len(restored) != 1 || !restored[0].Equal(oldIP) {
+		t.Fatalf("expected rollback to %v, got %v", oldIP, restored)
+	}
+	if len(pub) != 1 || pub[0].Type != event.RolledBack || !pub[0].OldIPs[0].Equal(newIP) {
+		t.Fatalf("unexpected events %+v", pub)
+	}
+	if !g.Blocked([]net.IP{newIP}) || g.Blocked([]net.IP{oldIP}) {
+		t.Error("expected only the rolled back address to be blocked")
+	}
+	now = now.Add(time.Hour)
+	if g.Blocked([]net.IP{newIP}) {
+		t.Error("expected the blackout to expire")
+	}
+}
@@ -0,0 +1,68 @@
+package verify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// RolloutStep is a provider updated by a Rollout
+type RolloutStep struct {
+	Name    string
+	Updater Updater
+	// Verify returns nil once the provider serves the new addresses, such as by waiting for its name server
+	// to answer with them. It is called before the next step; nil skips verification.
+	Verify func(ctx context.Context, ips []net.IP) error
+}
+
+// RolloutError is returned when a step of a Rollout failed, so the following providers were left untouched
+type RolloutError struct {
+	Step string
+	// Done are the steps that were updated and verified before it
+	Done []string
+	Err  error
+}
+
+func (e *RolloutError) Error() string {
+	return fmt.Sprintf("verify: rollout stopped at %s after %d of the providers: %v", e.Step, len(e.Done), e.Err)
+}
+
+func (e *RolloutError) Unwrap() error {
+	return e.Err
+}
+
+// Rollout publishes addresses to several providers serving the same hostnames one after another, such as
+// a primary DNS host and its secondary, verifying each before the next for a controlled cutover.
+type Rollout struct {
+	Steps []RolloutStep
+	// Unchanged is the error an Updater returns when its provider already had the addresses, such as
+	// daemon.ErrUnchanged. It does not stop the rollout, and is returned only if every step returned it.
+	Unchanged error
+}
+
+// UpdateIP updates and verifies the steps in order, stopping at the first that fails with a *RolloutError
+func (r Rollout) UpdateIP(ctx context.Context, ips []net.IP) error {
+	var done []string
+	unchanged := true
+	for i, s := range r.Steps {
+		err := s.Updater.UpdateIP(ctx, ips)
+		switch {
+		case r.Unchanged != nil && errors.Is(err, r.Unchanged):
+		case err != nil:
+			return &RolloutError{Step: s.Name, Done: done, Err: err}
+		default:
+			unchanged = false
+		}
+		if s.Verify != nil && i < len(r.Steps)-1 {
+			if err := s.Verify(ctx, ips); err != nil {
+				return &RolloutError{Step: s.Name, Done: done, Err: err}
+			}
+		}
+		done = append(done, s.Name)
+	}
+	if unchanged && r.Unchanged != nil {
+		return r.Unchanged
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package verify_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/justenwalker/ddns/verify"
+)
+
+func TestRollout(t *testing.T) {
+	ctx := context.Background()
+	ips := []net.IP{net.ParseIP("203.0.113.1")}
+	unchanged := errors.New("unchanged")
+	var calls []string
+	served := map[string]bool{"primary": true, "secondary": true}
+	step := func(name string, err error) verify.RolloutStep {
+		return verify.RolloutStep{
+			Name: name,
+			Updater: verify.UpdaterFunc(func(ctx context.Context, ips []net.IP) error {
+				calls = append(calls, "update "+name)
+				return err
+			}),
+			Verify: func(ctx context.Context, ips []net.IP) error {
+				calls = append(calls, "verify "+name)
+				if !served[name] {
+					return errors.New("not served yet")
+				}
+				return nil
+			},
+		}
+	}
+	r := verify.Rollout{Steps: []verify.RolloutStep{step("primary", nil), step("secondary", unchanged), step("tertiary", nil)}, Unchanged: unchanged}
+	if err := r.UpdateIP(ctx, ips); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"update primary", "verify primary", "update secondary", "verify secondary", "update tertiary"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("got %q, want %q", calls, want)
+	}
+
+	calls = nil
+	served["secondary"] = false
+	err := r.UpdateIP(ctx, ips)
+	var re *verify.RolloutError
+	if !errors.As(err, &re) || re.Step != "secondary" || !reflect.DeepEqual(re.Done, []string{"primary"}) {
+		t.Fatalf("expected the rollout to stop at secondary, got %v", err)
+	}
+	if calls[len(calls)-1] != "verify secondary" {
+		t.Errorf("expected tertiary to be left untouched, got %q", calls)
+	}
+
+	r = verify.Rollout{Steps: []verify.RolloutStep{step("primary", unchanged), step("secondary", unchanged)}, Unchanged: unchanged}
+	served["secondary"] = true
+	if err := r.UpdateIP(ctx, ips); err != unchanged {
+		t.Errorf("expected unchanged when every step is, got %v", err)
+	}
+}
@@ -0,0 +1,33 @@
+package verify
+
+import (
+	"context"
+	"time"
+
+	"github.com/justenwalker/ddns/event"
+)
+
+// Publisher publishes events; *event.Bus implements it
+type Publisher interface {
+	Publish(ev event.Event)
+}
+
+// Sink returns an event sink that verifies the addresses of Updated events and publishes the outcome
+// as a Verified event, with Err set to the UnreachableError if any port was unreachable.
+func Sink(c Checker, pub Publisher) event.Sink {
+	return event.SinkFunc(func(ctx context.Context, ev event.Event) error {
+		if ev.Type != event.Updated || len(ev.NewIPs) == 0 {
+			return nil
+		}
+		pub.Publish(event.Event{
+			Type:      event.Verified,
+			Time:      time.Now(),
+			Provider:  ev.Provider,
+			Hostnames: ev.Hostnames,
+			OldIPs:    ev.OldIPs,
+			NewIPs:    ev.NewIPs,
+			Err:       c.Check(ctx, ev.NewIPs).Err(),
+		})
+		return nil
+	})
+}
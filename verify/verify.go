@@ -0,0 +1,151 @@
+// Package verify checks that published addresses are reachable from outside the network,
+// catching routers that did not forward ports to the new address or records that point at the wrong interface.
+package verify // import "github.com/justenwalker/ddns/verify"
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/justenwalker/ddns/internal/httpreq"
+)
+
+// HTTPRequester makes http requests and returns responses.
+// *http.Client implicitly implements HTTPRequester and can be provided wherever this interface is requested.
+type HTTPRequester = httpreq.Requester
+
+// Prober checks whether a TCP port is reachable on an address
+type Prober interface {
+	// Probe returns nil if the port is reachable
+	Probe(ctx context.Context, ip net.IP, port int) error
+}
+
+// ProberFunc adapts a function to the Prober interface
+type ProberFunc func(ctx context.Context, ip net.IP, port int) error
+
+// Probe calls f(ctx, ip, port)
+func (f ProberFunc) Probe(ctx context.Context, ip net.IP, port int) error {
+	return f(ctx, ip, port)
+}
+
+// ProbeURL asks an external probe service to connect to the port.
+// URL is a template in which {ip} and {port} are replaced, such as
+// "https://probe.example.com/check?host={ip}&port={port}".
+// A 2xx response means the port is reachable; any other status means it is not.
+type ProbeURL struct {
+	URL        string
+	HTTPClient HTTPRequester
+}
+
+// Probe requests the probe URL for ip and port
+func (p ProbeURL) Probe(ctx context.Context, ip net.IP, port int) error {
+	u := strings.NewReplacer("{ip}", ip.String(), "{port}", strconv.Itoa(port)).Replace(p.URL)
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	hc := p.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("probe returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// Dial connects to the port directly.
+// From inside the network this only succeeds if the router supports hairpin NAT,
+// so it is most useful when ddns itself runs outside the network being verified.
+type Dial struct {
+	Timeout time.Duration
+}
+
+// Probe opens and closes a TCP connection to ip and port
+func (d Dial) Probe(ctx context.Context, ip net.IP, port int) error {
+	dialer := net.Dialer{Timeout: d.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip.String(), strconv.Itoa(port)))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// Result is the outcome of probing a single address and port
+type Result struct {
+	IP   net.IP
+	Port int
+	// Err is nil if the port is reachable
+	Err error
+}
+
+// Results of a check
+type Results []Result
+
+// OK returns true if every probed port was reachable
+func (rs Results) OK() bool {
+	return rs.Err() == nil
+}
+
+// Err returns an UnreachableError listing the unreachable ports, or nil if all were reachable
+func (rs Results) Err() error {
+	var failed UnreachableError
+	for _, r := range rs {
+		if r.Err != nil {
+			failed = append(failed, r)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return failed
+}
+
+// UnreachableError lists the ports that failed verification
+type UnreachableError []Result
+
+func (e UnreachableError) Error() string {
+	parts := make([]string, len(e))
+	for i, r := range e {
+		parts[i] = fmt.Sprintf("%s: %v", net.JoinHostPort(r.IP.String(), strconv.Itoa(r.Port)), r.Err)
+	}
+	return "verify: unreachable: " + strings.Join(parts, "; ")
+}
+
+// Checker probes the configured ports on each address
+type Checker struct {
+	Prober Prober
+	Ports  []int
+	// Timeout bounds each probe. Zero means no timeout beyond the context.
+	Timeout time.Duration
+}
+
+// Check probes every port on every address
+func (c Checker) Check(ctx context.Context, ips []net.IP) Results {
+	var rs Results
+	for _, ip := range ips {
+		for _, port := range c.Ports {
+			pctx, cancel := ctx, context.CancelFunc(func() {})
+			if c.Timeout > 0 {
+				pctx, cancel = context.WithTimeout(ctx, c.Timeout)
+			}
+			err := c.Prober.Probe(pctx, ip, port)
+			cancel()
+			rs = append(rs, Result{IP: ip, Port: port, Err: err})
+		}
+	}
+	return rs
+}
@@ -0,0 +1,57 @@
+package verify_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/justenwalker/ddns/event"
+	"github.com/justenwalker/ddns/verify"
+)
+
+func TestProbeURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("host") != "203.0.113.1" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Query().Get("port") != "443" {
+			http.Error(w, "closed", http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+	c := verify.Checker{
+		Prober: verify.ProbeURL{URL: srv.URL + "/?host={ip}&port={port}"},
+		Ports:  []int{443, 22},
+	}
+	rs := c.Check(context.Background(), []net.IP{net.ParseIP("203.0.113.1")})
+	if len(rs) != 2 || rs[0].Err != nil || rs[1].Err == nil {
+		t.Fatalf("unexpected results %+v", rs)
+	}
+	if _, ok := rs.Err().(verify.UnreachableError); !ok || rs.OK() {
+		t.Errorf("expected an UnreachableError, got %v", rs.Err())
+	}
+}
+
+type publisher []event.Event
+
+func (p *publisher) Publish(ev event.Event) {
+	*p = append(*p, ev)
+}
+
+func TestSink(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+	var pub publisher
+	s := verify.Sink(verify.Checker{Prober: verify.Dial{}, Ports: []int{port}}, &pub)
+	s.Handle(context.Background(), event.Event{Type: event.Updated, Provider: "dynu", NewIPs: []net.IP{net.ParseIP("127.0.0.1")}})
+	if len(pub) != 1 || pub[0].Type != event.Verified || pub[0].Err != nil {
+		t.Errorf("unexpected events %+v", pub)
+	}
+}
@@ -0,0 +1,75 @@
+// Package wireguard rewrites the endpoint of a WireGuard peer when the address of a tracked hostname changes,
+// keeping a VPN to a dynamically addressed server connected.
+package wireguard // import "github.com/justenwalker/ddns/wireguard"
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/justenwalker/ddns/event"
+	"golang.zx2c4.com/wireguard/wgctrl"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// Configurer applies device configuration.
+// *wgctrl.Client implicitly implements Configurer and can be provided wherever this interface is requested.
+type Configurer interface {
+	ConfigureDevice(name string, cfg wgtypes.Config) error
+}
+
+// PeerEndpoint identifies the peer whose endpoint is rewritten
+type PeerEndpoint struct {
+	// Device is the WireGuard interface name, e.g. wg0
+	Device string
+	// PublicKey of the peer, base64 encoded as in wg(8) output
+	PublicKey string
+	// Port is the peer's listen port
+	Port int
+	// IPv6 selects the IPv6 address of the hostname instead of the IPv4 address
+	IPv6 bool
+	// Client configures the device; a wgctrl client is opened for each update if nil
+	Client Configurer
+}
+
+// Update points the peer at ip
+func (p PeerEndpoint) Update(ip net.IP) error {
+	key, err := wgtypes.ParseKey(p.PublicKey)
+	if err != nil {
+		return err
+	}
+	client := p.Client
+	if client == nil {
+		c, err := wgctrl.New()
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		client = c
+	}
+	return client.ConfigureDevice(p.Device, wgtypes.Config{
+		Peers: []wgtypes.PeerConfig{{
+			PublicKey:  key,
+			UpdateOnly: true,
+			Endpoint:   &net.UDPAddr{IP: ip, Port: p.Port},
+		}},
+	})
+}
+
+// Sink returns an event sink that updates the peer when a Changed event for hostname is received.
+// Events for every hostname are applied if hostname is empty.
+func Sink(p PeerEndpoint, hostname string) event.Sink {
+	return event.SinkFunc(func(ctx context.Context, ev event.Event) error {
+		if ev.Type != event.Changed || !ev.HasHostname(hostname) {
+			return nil
+		}
+		ip := event.PickIP(ev.NewIPs, p.IPv6)
+		if ip == nil {
+			return nil
+		}
+		if err := p.Update(ip); err != nil {
+			return fmt.Errorf("wireguard: update endpoint of peer %s on %s: %w", p.PublicKey, p.Device, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,57 @@
+package wireguard_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/justenwalker/ddns/event"
+	"github.com/justenwalker/ddns/wireguard"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+type configurer struct {
+	device string
+	cfg    wgtypes.Config
+}
+
+func (c *configurer) ConfigureDevice(name string, cfg wgtypes.Config) error {
+	c.device = name
+	c.cfg = cfg
+	return nil
+}
+
+func TestSink(t *testing.T) {
+	key, err := wgtypes.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &configurer{}
+	sink := wireguard.Sink(wireguard.PeerEndpoint{
+		Device:    "wg0",
+		PublicKey: key.PublicKey().String(),
+		Port:      51820,
+		Client:    c,
+	}, "vpn.example.com")
+
+	ctx := context.Background()
+	sink.Handle(ctx, event.Event{Type: event.Changed, Hostnames: []string{"other.example.com"}, NewIPs: []net.IP{net.ParseIP("203.0.113.1")}})
+	if c.device != "" {
+		t.Fatal("expected events for other hostnames to be ignored")
+	}
+	err = sink.Handle(ctx, event.Event{
+		Type:      event.Changed,
+		Hostnames: []string{"vpn.example.com"},
+		NewIPs:    []net.IP{net.ParseIP("2001:db8::7"), net.ParseIP("203.0.113.7")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.device != "wg0" || len(c.cfg.Peers) != 1 {
+		t.Fatalf("unexpected configuration: %s %+v", c.device, c.cfg)
+	}
+	peer := c.cfg.Peers[0]
+	if peer.PublicKey != key.PublicKey() || !peer.UpdateOnly || peer.Endpoint.String() != "203.0.113.7:51820" {
+		t.Errorf("unexpected peer configuration: %+v", peer)
+	}
+}